@@ -1,4 +1,4 @@
-import { type Files, type NotificationMessage, type RequestMessage, type ResponseMessage, type ResponseError as ResponseErrorObj, type Spxls } from '.'
+import { type Files, type NotificationMessage, type RequestMessage, type ResponseMessage, type ResponseError as ResponseErrorObj, type Spxls, type WorkerInboundMessage, type WorkerOutboundMessage } from '.'
 
 /**
  * Client wrapper for the spxls.
@@ -11,12 +11,18 @@ export class Spxlc {
     reject: (error: any) => void
   }>()
   private notificationHandlers = new Map<string, (params: any) => void>()
+  private requestHandlers = new Map<string, (params: any) => any>()
 
   /**
    * Creates a new client instance.
    * @param filesProvider Function that provides access to workspace files.
+   * @param worker Options to run the language server in a Web Worker instead of the current thread.
    */
-  constructor(filesProvider: () => Files) {
+  constructor(filesProvider: () => Files, worker?: WorkerOptions) {
+    if (worker != null) {
+      this.ls = new WorkerSpxls(filesProvider, this.handleMessage.bind(this), worker)
+      return
+    }
     const ls = NewSpxls(filesProvider, this.handleMessage.bind(this))
     if (ls instanceof Error) throw ls
     this.ls = ls
@@ -27,7 +33,8 @@ export class Spxlc {
    * @param message Message from the server.
    * @throws Error if the message type is unknown.
    */
-  private handleMessage(message: ResponseMessage | NotificationMessage): void {
+  private handleMessage(message: RequestMessage | ResponseMessage | NotificationMessage): void {
+    if ('id' in message && 'method' in message) return this.handleRequestMessage(message)
     if ('id' in message) return this.handleResponseMessage(message)
     if ('method' in message) return this.handleNotificationMessage(message)
     throw new Error('unknown message type')
@@ -52,6 +59,34 @@ export class Spxlc {
     else pending.resolve(message.result)
   }
 
+  /**
+   * Handles request messages from the language server, e.g., `window/workDoneProgress/create`.
+   * @param message Request message from the server.
+   */
+  private handleRequestMessage(message: RequestMessage): void {
+    const handler = this.requestHandlers.get(message.method)
+    let response: ResponseMessage
+    if (handler == null) {
+      response = {
+        jsonrpc: '2.0',
+        id: message.id,
+        error: { code: -32601, message: `method not found: ${message.method}` }
+      }
+    } else {
+      try {
+        response = { jsonrpc: '2.0', id: message.id, result: handler(message.params) ?? null }
+      } catch (err) {
+        response = {
+          jsonrpc: '2.0',
+          id: message.id,
+          error: { code: -32603, message: err instanceof Error ? err.message : String(err) }
+        }
+      }
+    }
+    const err = this.ls.handleMessage(response)
+    if (err != null) console.warn(`[LSP] failed to reply to ${message.method}:`, err)
+  }
+
   /**
    * Handles notification messages from the language server.
    * @param message Notification message from the server.
@@ -128,12 +163,23 @@ export class Spxlc {
     this.notificationHandlers.set(method, handler)
   }
 
+  /**
+   * Registers a handler for server requests. The handler's return value is sent back as the result.
+   * @param method LSP method name.
+   * @param handler Function to handle the request.
+   */
+  onRequest(method: string, handler: (params: any) => any): void {
+    this.requestHandlers.set(method, handler)
+  }
+
   /**
    * Cleans up client resources.
    */
   dispose(): void {
+    if (this.ls instanceof WorkerSpxls) this.ls.terminate()
     this.pendingRequests.clear()
     this.notificationHandlers.clear()
+    this.requestHandlers.clear()
   }
 }
 
@@ -146,3 +192,70 @@ export class ResponseError extends Error {
     this.data = obj.data
   }
 }
+
+/**
+ * Options to run the language server in a Web Worker.
+ */
+export type WorkerOptions = {
+  /** The worker created from worker.ts. */
+  worker: Worker
+  /** The URL of the WebAssembly module. */
+  wasmURL: string
+  /** The URL of the `wasm_exec.js` of the Go version used to build the WebAssembly module. */
+  wasmExecURL: string
+}
+
+/**
+ * Spxls running in a Web Worker. Messages are posted to the worker without waiting, so failures to handle them are
+ * only logged.
+ *
+ * The worker keeps a copy of the workspace files, which is updated before the files are reloaded, i.e., on
+ * {@link invalidateFiles} and `workspace/didChangeWatchedFiles`.
+ */
+class WorkerSpxls implements Spxls {
+  constructor(
+    private filesProvider: () => Files,
+    messageReplier: (message: RequestMessage | ResponseMessage | NotificationMessage) => void,
+    private options: WorkerOptions
+  ) {
+    options.worker.onmessage = (event: MessageEvent<WorkerOutboundMessage>) => {
+      const message = event.data
+      switch (message.type) {
+        case 'message':
+          messageReplier(message.message)
+          break
+        case 'error':
+          console.warn('[LSP] worker error:', message.error)
+          break
+      }
+    }
+    this.post({
+      type: 'init',
+      wasmURL: options.wasmURL,
+      wasmExecURL: options.wasmExecURL,
+      files: filesProvider()
+    })
+  }
+
+  private post(message: WorkerInboundMessage): void {
+    this.options.worker.postMessage(message)
+  }
+
+  handleMessage(message: RequestMessage | ResponseMessage | NotificationMessage): Error | null {
+    if ('method' in message && message.method === 'workspace/didChangeWatchedFiles') {
+      this.post({ type: 'files', files: this.filesProvider() })
+    }
+    this.post({ type: 'message', message })
+    return null
+  }
+
+  invalidateFiles(paths: string[]): Error | null {
+    this.post({ type: 'files', files: this.filesProvider(), paths })
+    return null
+  }
+
+  /** Stops the worker. */
+  terminate(): void {
+    this.options.worker.terminate()
+  }
+}
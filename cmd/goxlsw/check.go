@@ -0,0 +1,146 @@
+//go:build !js
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/server"
+)
+
+// runCheck runs the check command, which prints the diagnostics of the
+// workspaces in the given directories, the current one by default. The whole
+// workspace is checked, so "dir/..." is the same as "dir".
+//
+// The exit code is 1 if there are diagnostics of error severity, and 2 if the
+// check itself fails, which suits CI.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goxlsw check [-format text|sarif] [dir ...]")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "text", "output format, text or sarif (SARIF 2.1.0)")
+	flags.Parse(args)
+	if *format != "text" && *format != "sarif" {
+		flags.Usage()
+		return 2
+	}
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	// SARIF columns are in UTF-16 code units by default, while the text
+	// format follows go vet, whose columns are in bytes.
+	encoding := server.PositionEncodingKind("utf-8")
+	if *format == "sarif" {
+		encoding = "utf-16"
+	}
+	var diags []fileDiagnostic
+	for _, dir := range dirs {
+		dir = strings.TrimSuffix(dir, "...")
+		if dir == "" {
+			dir = "."
+		}
+		dirDiags, err := check(dir, encoding)
+		if err != nil {
+			log.Printf("%s: %v", dir, err)
+			return 2
+		}
+		diags = append(diags, dirDiags...)
+	}
+
+	switch *format {
+	case "text":
+		for _, diag := range diags {
+			fmt.Printf("%s:%d:%d: %s: %s", diag.file, diag.Range.Start.Line+1, diag.Range.Start.Character+1, severityName(diag.Severity), diag.Message)
+			if diag.Source != "" {
+				fmt.Printf(" (%s)", diag.Source)
+			}
+			fmt.Println()
+		}
+	case "sarif":
+		if err := writeSARIF(os.Stdout, diags); err != nil {
+			log.Print(err)
+			return 2
+		}
+	}
+	for _, diag := range diags {
+		if diag.Severity == server.SeverityError {
+			return 1
+		}
+	}
+	return 0
+}
+
+// fileDiagnostic is a diagnostic of a file.
+type fileDiagnostic struct {
+	file string // path of the file, relative to the current directory
+	server.Diagnostic
+}
+
+// workspaceDiagnosticReport is the part of [server.WorkspaceDiagnosticReport]
+// used by the check command. Unlike the former, it can be decoded from JSON.
+type workspaceDiagnosticReport struct {
+	Items []workspaceDocumentDiagnostics `json:"items"`
+}
+
+type workspaceDocumentDiagnostics struct {
+	URI   server.DocumentURI  `json:"uri"`
+	Items []server.Diagnostic `json:"items"`
+}
+
+// check returns the diagnostics of the workspace in dir, in the order of
+// their files and positions, which are in the given encoding.
+func check(dir string, encoding server.PositionEncodingKind) ([]fileDiagnostic, error) {
+	c, err := newLocalClient(dir, encoding)
+	if err != nil {
+		return nil, err
+	}
+	var report workspaceDiagnosticReport
+	if err := c.call("workspace/diagnostic", server.WorkspaceDiagnosticParams{}, &report); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(report.Items, func(a, b workspaceDocumentDiagnostics) int {
+		return strings.Compare(string(a.URI), string(b.URI))
+	})
+	var diags []fileDiagnostic
+	for _, item := range report.Items {
+		file := filepath.Join(dir, filepath.FromSlash(documentPath(item.URI)))
+		slices.SortStableFunc(item.Items, func(a, b server.Diagnostic) int {
+			if a.Range.Start.Line != b.Range.Start.Line {
+				return cmp.Compare(a.Range.Start.Line, b.Range.Start.Line)
+			}
+			return cmp.Compare(a.Range.Start.Character, b.Range.Start.Character)
+		})
+		for _, diag := range item.Items {
+			diags = append(diags, fileDiagnostic{file: file, Diagnostic: diag})
+		}
+	}
+	return diags, nil
+}
+
+// severityName returns the name of the given severity, as in the settings of
+// analyzers.
+func severityName(severity server.DiagnosticSeverity) string {
+	switch severity {
+	case server.SeverityError:
+		return "error"
+	case server.SeverityWarning:
+		return "warning"
+	case server.SeverityInformation:
+		return "info"
+	case server.SeverityHint:
+		return "hint"
+	}
+	return "unknown"
+}
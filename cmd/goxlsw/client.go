@@ -0,0 +1,96 @@
+//go:build !js
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// localClient drives a language server in the same process with LSP
+// messages, as an editor would.
+type localClient struct {
+	server *server.Server
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[jsonrpc2.ID]chan *jsonrpc2.Response
+}
+
+// newLocalClient creates a client of a new language server for the workspace
+// in dir, and initializes it with positions in the given encoding.
+func newLocalClient(dir string, encoding server.PositionEncodingKind) (*localClient, error) {
+	c := &localClient{pending: make(map[jsonrpc2.ID]chan *jsonrpc2.Response)}
+	files := newDirFiles(dir)
+	c.server = server.New(gop.NewProject(nil, files.get, gop.FeatAll), c, files.get)
+
+	var params server.InitializeParams
+	params.Capabilities.General = &server.GeneralClientCapabilities{
+		PositionEncodings: []server.PositionEncodingKind{encoding},
+	}
+	if err := c.call("initialize", params, nil); err != nil {
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+	return c, nil
+}
+
+// ReplyMessage implements [server.MessageReplier]. Notifications and requests
+// from the server are ignored, as nothing is shown to a user.
+func (c *localClient) ReplyMessage(m jsonrpc2.Message) error {
+	resp, ok := m.(*jsonrpc2.Response)
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[resp.ID()]
+	delete(c.pending, resp.ID())
+	c.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return nil
+}
+
+// call calls the given method and waits for the response, whose result is
+// decoded into result if not nil.
+func (c *localClient) call(method string, params, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := jsonrpc2.NewIntID(c.nextID)
+	ch := make(chan *jsonrpc2.Response, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	call, err := jsonrpc2.NewCall(id, method, params)
+	if err != nil {
+		return err
+	}
+	if err := c.server.HandleMessage(call); err != nil {
+		return err
+	}
+	resp := <-ch
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return server.UnmarshalJSON(resp.Result(), result)
+}
+
+// documentURI returns the document URI of the file with the given path
+// relative to the workspace.
+func documentURI(path string) server.DocumentURI {
+	return server.DocumentURI("file:///" + path)
+}
+
+// documentPath returns the path relative to the workspace of the document
+// with the given URI.
+func documentPath(uri server.DocumentURI) string {
+	return strings.TrimPrefix(string(uri), "file:///")
+}
@@ -0,0 +1,121 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// cloudFilesMaxAge is how long the files of a cloud project are used before
+// they are revalidated.
+const cloudFilesMaxAge = 10 * time.Second
+
+// workspaceFiles returns the getter of the files of the workspace at the
+// given location, which is either a directory, a zip archive, or the URL of
+// the listing of a cloud project, see [vfs.HTTPDir].
+func workspaceFiles(location string) (server.FileMapGetter, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		d, err := vfs.NewHTTPDir(location)
+		if err != nil {
+			return nil, err
+		}
+		d.MaxAge = cloudFilesMaxAge
+		if _, err := d.Files(context.Background()); err != nil {
+			return nil, err
+		}
+		return func() map[string]vfs.MapFile {
+			// The files of the last successful sync are used until the
+			// project is reachable again.
+			files, err := d.Files(context.Background())
+			if err != nil {
+				log.Print(err)
+			}
+			return files
+		}, nil
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() && strings.EqualFold(filepath.Ext(location), ".zip") {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+		files, err := vfs.ZipFiles(bytes.NewReader(data), int64(len(data)), vfs.Limits{})
+		if err != nil {
+			return nil, err
+		}
+		return func() map[string]vfs.MapFile { return files }, nil
+	}
+	return newDirFiles(location).get, nil
+}
+
+// dirFiles provides the files of a workspace directory on disk. Files are
+// read again only when their modification times change.
+type dirFiles struct {
+	root string
+
+	mu    sync.Mutex
+	files map[string]vfs.MapFile // by slash separated path relative to root
+}
+
+func newDirFiles(root string) *dirFiles {
+	return &dirFiles{root: root, files: make(map[string]vfs.MapFile)}
+}
+
+// get returns the current files of the workspace. It implements
+// [server.FileMapGetter]. Hidden files and directories are skipped, and so are
+// files that cannot be read.
+func (d *dirFiles) get() map[string]vfs.MapFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files := make(map[string]vfs.MapFile, len(d.files))
+	filepath.WalkDir(d.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != d.root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if file, ok := d.files[rel]; ok && file.ModTime.Equal(info.ModTime()) {
+			files[rel] = file
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		files[rel] = &vfs.MapFileImpl{Content: content, ModTime: info.ModTime()}
+		return nil
+	})
+	d.files = files
+	return files
+}
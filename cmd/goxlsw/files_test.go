@@ -0,0 +1,68 @@
+//go:build !js
+
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceFiles(t *testing.T) {
+	t.Run("Dir", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.spx"), []byte("echo 1"), 0o644))
+		files, err := workspaceFiles(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "echo 1", string(files()["main.spx"].Content))
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "MyGame.zip")
+		f, err := os.Create(name)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("MyGame/main.spx")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("echo 1"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+
+		files, err := workspaceFiles(name)
+		require.NoError(t, err)
+		assert.Equal(t, "echo 1", string(files()["main.spx"].Content))
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/listing":
+				w.Write([]byte(`{"main.spx": "files/main.spx"}`))
+			case "/files/main.spx":
+				w.Write([]byte("echo 1"))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+
+		files, err := workspaceFiles(srv.URL + "/listing")
+		require.NoError(t, err)
+		assert.Equal(t, "echo 1", string(files()["main.spx"].Content))
+
+		_, err = workspaceFiles(srv.URL + "/missing")
+		assert.ErrorContains(t, err, "404")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := workspaceFiles(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,118 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/goplus/goxlsw/internal/server"
+)
+
+// runFmt runs the fmt command, which formats the given spx source files of
+// the workspace, all of them by default, like gofmt does for Go.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goxlsw fmt [-dir dir] [-l] [-w] [file ...]")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", ".", "workspace directory, which file paths are relative to")
+	list := flags.Bool("l", false, "list files whose formatting differs instead of printing them")
+	write := flags.Bool("w", false, "write results to the files instead of printing them")
+	flags.Parse(args)
+
+	files := flags.Args()
+	if len(files) == 0 {
+		for file := range newDirFiles(*dir).get() {
+			if path.Ext(file) == ".spx" {
+				files = append(files, file)
+			}
+		}
+		slices.Sort(files)
+	}
+
+	c, err := newLocalClient(*dir, "utf-8") // See applyTextEdits.
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	exitCode := 0
+	for _, file := range files {
+		file = filepath.ToSlash(file)
+		if err := formatFile(c, *dir, file, *list, *write); err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+		}
+	}
+	return exitCode
+}
+
+// formatFile formats the given file of the workspace in dir.
+func formatFile(c *localClient, dir, file string, list, write bool) error {
+	filename := filepath.Join(dir, filepath.FromSlash(file))
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var edits []server.TextEdit
+	if err := c.call("textDocument/formatting", server.DocumentFormattingParams{
+		TextDocument: server.TextDocumentIdentifier{URI: documentURI(file)},
+	}, &edits); err != nil {
+		return err
+	}
+	formatted := applyTextEdits(original, edits)
+
+	if list && !bytes.Equal(original, formatted) {
+		fmt.Println(filename)
+	}
+	if write {
+		if bytes.Equal(original, formatted) {
+			return nil
+		}
+		return os.WriteFile(filename, formatted, 0o644)
+	}
+	if !list {
+		_, err = os.Stdout.Write(formatted)
+	}
+	return err
+}
+
+// applyTextEdits applies the given non-overlapping edits, whose positions are
+// in UTF-8, to content.
+func applyTextEdits(content []byte, edits []server.TextEdit) []byte {
+	var lineStarts []int
+	lineStarts = append(lineStarts, 0)
+	for i, b := range content {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(pos server.Position) int {
+		if int(pos.Line) >= len(lineStarts) {
+			return len(content)
+		}
+		return min(lineStarts[pos.Line]+int(pos.Character), len(content))
+	}
+
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b server.TextEdit) int {
+		return cmp.Compare(offset(a.Range.Start), offset(b.Range.Start))
+	})
+	var result []byte
+	last := 0
+	for _, edit := range edits {
+		start, end := offset(edit.Range.Start), offset(edit.Range.End)
+		result = append(result, content[last:start]...)
+		result = append(result, edit.NewText...)
+		last = end
+	}
+	return append(result, content[last:]...)
+}
@@ -0,0 +1,32 @@
+//go:build !js
+
+package main
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTextEdits(t *testing.T) {
+	edit := func(startLine, startChar, endLine, endChar uint32, newText string) server.TextEdit {
+		return server.TextEdit{
+			Range: server.Range{
+				Start: server.Position{Line: startLine, Character: startChar},
+				End:   server.Position{Line: endLine, Character: endChar},
+			},
+			NewText: newText,
+		}
+	}
+	content := []byte("func  foo() {\necho  \"héllo\"\n}\n")
+
+	assert.Equal(t, string(content), string(applyTextEdits(content, nil)))
+	assert.Equal(t, "func foo() {\n\techo \"héllo\"\n}\n", string(applyTextEdits(content, []server.TextEdit{
+		edit(1, 0, 2, 0, "\techo \"héllo\"\n"), // Out of order.
+		edit(0, 4, 0, 6, " "),
+	})))
+	assert.Equal(t, string(content)+"echo 1\n", string(applyTextEdits(content, []server.TextEdit{
+		edit(3, 0, 3, 0, "echo 1\n"),
+	})))
+}
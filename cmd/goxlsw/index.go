@@ -0,0 +1,332 @@
+//go:build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/goplus/goxlsw/internal/server"
+)
+
+// lsifVersion is the version of the LSIF format emitted by the index command.
+const lsifVersion = "0.6.0"
+
+// spxResourceMonikerScheme is the scheme of the monikers of spx resources, whose
+// identifiers are spx resource URIs, e.g., "spx://resources/sprites/MySprite".
+const spxResourceMonikerScheme = "spx"
+
+// runIndex runs the index command, which writes an LSIF index of the
+// workspace with the definitions, references and hovers of its symbols, and
+// the references to spx resources.
+func runIndex(args []string) int {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goxlsw index [-dir dir] [-o file]")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", ".", "workspace directory")
+	output := flags.String("o", "", "output file instead of stdout")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := index(root, w); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
+
+// index writes an LSIF index of the workspace in root, which must be an
+// absolute path, to w.
+func index(root string, w io.Writer) error {
+	c, err := newLocalClient(root, "utf-16")
+	if err != nil {
+		return err
+	}
+	var symbols []server.SymbolInformation
+	if err := c.call("workspace/symbol", server.WorkspaceSymbolParams{}, &symbols); err != nil {
+		return err
+	}
+	var resources []server.SpxResourceReferences
+	if err := c.call("workspace/executeCommand", server.ExecuteCommandParams{
+		Command: "spx.getResourceReferences",
+	}, &resources); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	e := newLSIFEmitter(bw, "file://"+filepath.ToSlash(root)+"/")
+	for _, symbol := range symbols {
+		if err := e.emitSymbol(c, symbol); err != nil {
+			return err
+		}
+	}
+	for _, resource := range resources {
+		e.emitResource(resource)
+	}
+	e.emitContains()
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// lsifEmitter emits the vertices and edges of an LSIF index as JSON lines.
+type lsifEmitter struct {
+	enc         *json.Encoder
+	err         error // first error of encoding
+	projectRoot string
+	lastID      int
+
+	projectID  int
+	documents  map[server.DocumentURI]int    // document IDs by URI
+	ranges     map[server.Location]lsifRange // ranges by location
+	docRanges  map[int][]int                 // range IDs by document ID
+	monikerIDs map[[2]int]bool               // emitted moniker edges by result set ID and moniker ID
+}
+
+type lsifRange struct {
+	id          int
+	resultSetID int // 0 if none
+}
+
+func newLSIFEmitter(w io.Writer, projectRoot string) *lsifEmitter {
+	e := &lsifEmitter{
+		enc:         json.NewEncoder(w),
+		projectRoot: projectRoot,
+		documents:   make(map[server.DocumentURI]int),
+		ranges:      make(map[server.Location]lsifRange),
+		docRanges:   make(map[int][]int),
+		monikerIDs:  make(map[[2]int]bool),
+	}
+	e.vertex("metaData", map[string]any{
+		"version":          lsifVersion,
+		"projectRoot":      projectRoot,
+		"positionEncoding": "utf-16",
+		"toolInfo":         map[string]any{"name": "goxlsw"},
+	})
+	e.projectID = e.vertex("project", map[string]any{"kind": "gop"})
+	return e
+}
+
+// emit emits an element with the given type, label and properties, and
+// returns its ID.
+func (e *lsifEmitter) emit(typ, label string, props map[string]any) int {
+	e.lastID++
+	element := map[string]any{"id": e.lastID, "type": typ, "label": label}
+	for k, v := range props {
+		element[k] = v
+	}
+	if err := e.enc.Encode(element); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.lastID
+}
+
+func (e *lsifEmitter) vertex(label string, props map[string]any) int {
+	return e.emit("vertex", label, props)
+}
+
+func (e *lsifEmitter) edge(label string, outV int, inV any, props map[string]any) {
+	if props == nil {
+		props = make(map[string]any)
+	}
+	props["outV"] = outV
+	if inVs, ok := inV.([]int); ok {
+		props["inVs"] = inVs
+	} else {
+		props["inV"] = inV
+	}
+	e.emit("edge", label, props)
+}
+
+// document returns the ID of the document with the given URI of the
+// workspace, emitting it first if needed.
+func (e *lsifEmitter) document(uri server.DocumentURI) int {
+	if id, ok := e.documents[uri]; ok {
+		return id
+	}
+	languageID := "spx"
+	if filepath.Ext(documentPath(uri)) == ".gop" {
+		languageID = "gop"
+	}
+	id := e.vertex("document", map[string]any{
+		"uri":        e.projectRoot + documentPath(uri),
+		"languageId": languageID,
+	})
+	e.documents[uri] = id
+	return id
+}
+
+// rangeOf returns the range at the given location, emitting it first if
+// needed.
+func (e *lsifEmitter) rangeOf(loc server.Location) lsifRange {
+	if r, ok := e.ranges[loc]; ok {
+		return r
+	}
+	docID := e.document(loc.URI)
+	r := lsifRange{id: e.vertex("range", map[string]any{
+		"start": loc.Range.Start,
+		"end":   loc.Range.End,
+	})}
+	e.ranges[loc] = r
+	e.docRanges[docID] = append(e.docRanges[docID], r.id)
+	return r
+}
+
+// attach links the range at the given location to the given result set,
+// unless it is already linked to one, which it then returns.
+func (e *lsifEmitter) attach(loc server.Location, resultSetID int) (rangeID, attachedResultSetID int) {
+	r := e.rangeOf(loc)
+	if r.resultSetID == 0 {
+		r.resultSetID = resultSetID
+		e.ranges[loc] = r
+		e.edge("next", r.id, resultSetID, nil)
+	}
+	return r.id, r.resultSetID
+}
+
+// items emits the item edges from the given result to the ranges at the given
+// locations, grouped by document.
+func (e *lsifEmitter) items(resultID int, locs []server.Location, property string) {
+	byDoc := make(map[int][]int)
+	var docIDs []int
+	for _, loc := range locs {
+		docID := e.document(loc.URI)
+		if _, ok := byDoc[docID]; !ok {
+			docIDs = append(docIDs, docID)
+		}
+		byDoc[docID] = append(byDoc[docID], e.rangeOf(loc).id)
+	}
+	for _, docID := range docIDs {
+		props := map[string]any{"document": docID}
+		if property != "" {
+			props["property"] = property
+		}
+		e.edge("item", resultID, byDoc[docID], props)
+	}
+}
+
+// emitSymbol emits the definition, references and hover of the given symbol.
+func (e *lsifEmitter) emitSymbol(c *localClient, symbol server.SymbolInformation) error {
+	def := symbol.Location
+	if r, ok := e.ranges[def]; ok && r.resultSetID != 0 {
+		return nil // Already emitted, e.g., for a symbol of the same declaration.
+	}
+	var refs []server.Location
+	if err := c.call("textDocument/references", server.ReferenceParams{
+		TextDocumentPositionParams: server.TextDocumentPositionParams{
+			TextDocument: server.TextDocumentIdentifier{URI: def.URI},
+			Position:     def.Range.Start,
+		},
+	}, &refs); err != nil {
+		return fmt.Errorf("failed to get references of %s: %w", symbol.Name, err)
+	}
+	var hover *server.Hover
+	if err := c.call("textDocument/hover", server.HoverParams{
+		TextDocumentPositionParams: server.TextDocumentPositionParams{
+			TextDocument: server.TextDocumentIdentifier{URI: def.URI},
+			Position:     def.Range.Start,
+		},
+	}, &hover); err != nil {
+		return fmt.Errorf("failed to get hover of %s: %w", symbol.Name, err)
+	}
+
+	resultSetID := e.vertex("resultSet", nil)
+	e.attach(def, resultSetID)
+	refs = slices.DeleteFunc(refs, func(ref server.Location) bool { return ref == def })
+	for _, ref := range refs {
+		e.attach(ref, resultSetID)
+	}
+
+	defResultID := e.vertex("definitionResult", nil)
+	e.edge("textDocument/definition", resultSetID, defResultID, nil)
+	e.items(defResultID, []server.Location{def}, "")
+
+	refResultID := e.vertex("referenceResult", nil)
+	e.edge("textDocument/references", resultSetID, refResultID, nil)
+	e.items(refResultID, []server.Location{def}, "definitions")
+	if len(refs) > 0 {
+		e.items(refResultID, refs, "references")
+	}
+
+	if hover != nil {
+		hoverResultID := e.vertex("hoverResult", map[string]any{
+			"result": map[string]any{"contents": hover.Contents},
+		})
+		e.edge("textDocument/hover", resultSetID, hoverResultID, nil)
+	}
+	return nil
+}
+
+// emitResource emits the references to the given spx resource, identified by
+// a moniker. References that are also references to symbols, e.g., the
+// identifier of an auto-binding, share the result set of the symbol, which is
+// given the moniker as well.
+func (e *lsifEmitter) emitResource(resource server.SpxResourceReferences) {
+	monikerID := e.vertex("moniker", map[string]any{
+		"scheme":     spxResourceMonikerScheme,
+		"identifier": string(resource.Resource.URI),
+		"kind":       "export",
+	})
+	resultSetID := e.vertex("resultSet", nil)
+	e.edge("moniker", resultSetID, monikerID, nil)
+	e.monikerIDs[[2]int{resultSetID, monikerID}] = true
+
+	locs := make([]server.Location, 0, len(resource.References))
+	for _, ref := range resource.References {
+		_, attachedResultSetID := e.attach(ref.Location, resultSetID)
+		if key := [2]int{attachedResultSetID, monikerID}; !e.monikerIDs[key] {
+			e.edge("moniker", attachedResultSetID, monikerID, nil)
+			e.monikerIDs[key] = true
+		}
+		locs = append(locs, ref.Location)
+	}
+	refResultID := e.vertex("referenceResult", nil)
+	e.edge("textDocument/references", resultSetID, refResultID, nil)
+	e.items(refResultID, locs, "references")
+}
+
+// emitContains emits the edges from the project to its documents, and from
+// documents to their ranges.
+func (e *lsifEmitter) emitContains() {
+	var docIDs []int
+	for _, id := range e.documents {
+		docIDs = append(docIDs, id)
+	}
+	slices.Sort(docIDs)
+	for _, docID := range docIDs {
+		if rangeIDs := e.docRanges[docID]; len(rangeIDs) > 0 {
+			e.edge("contains", docID, rangeIDs, nil)
+		}
+	}
+	if len(docIDs) > 0 {
+		e.edge("contains", e.projectID, docIDs, nil)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build !js
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.spx": `var (
+	MySprite Sprite
+)
+
+func greet() {}
+
+onStart => {
+	greet
+}
+
+run "assets", {Title: "My Game"}
+`,
+		"MySprite.spx":                       "onStart => {\n\tgreet\n}\n",
+		"assets/index.json":                  `{}`,
+		"assets/sprites/MySprite/index.json": `{}`,
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, index(root, &buf))
+	elements := make(map[int]map[string]any)
+	var (
+		labels         = make(map[string]int)
+		documentURIs   []string
+		monikers       []string
+		referenceItems int
+	)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var element map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &element))
+		id := int(element["id"].(float64))
+		require.NotContains(t, elements, id)
+		elements[id] = element
+		label := element["label"].(string)
+		labels[label]++
+		switch label {
+		case "document":
+			documentURIs = append(documentURIs, element["uri"].(string))
+		case "moniker":
+			if element["type"] == "edge" {
+				break
+			}
+			monikers = append(monikers, element["identifier"].(string))
+		case "item":
+			if element["property"] == "references" {
+				referenceItems++
+			}
+		}
+		// Edges only refer to elements emitted before them.
+		if element["type"] == "edge" {
+			require.Contains(t, elements, int(element["outV"].(float64)))
+		}
+	}
+
+	assert.Equal(t, 1, labels["metaData"])
+	assert.ElementsMatch(t, []string{
+		"file://" + filepath.ToSlash(root) + "/main.spx",
+		"file://" + filepath.ToSlash(root) + "/MySprite.spx",
+	}, documentURIs)
+	assert.Contains(t, monikers, "spx://resources/sprites/MySprite")
+	assert.NotZero(t, labels["hoverResult"])
+	assert.NotZero(t, referenceItems)
+}
@@ -0,0 +1,50 @@
+//go:build !js
+
+// Command goxlsw runs the language server as a standalone process, and
+// provides some of its features on the command line.
+//
+// Usage:
+//
+//	goxlsw [serve] [-dir dir] [-listen addr | -ws addr] [-rpc.trace file]
+//	goxlsw check [-format text|sarif] [dir ...]
+//	goxlsw fmt [-dir dir] [-l] [-w] [file ...]
+//	goxlsw symbols [-dir dir] [query]
+//	goxlsw index [-dir dir] [-o file]
+//
+// The serve command, which is the default, serves the language server over
+// stdio, TCP or WebSocket, for a workspace directory, a zip archive, or a
+// cloud project given by the URL of its listing. The other commands run a language server in the
+// same process for the workspace in the given directory.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// commands are the subcommands by name. Each returns the exit code.
+var commands = map[string]func(args []string) int{
+	"serve":   runServe,
+	"check":   runCheck,
+	"fmt":     runFmt,
+	"symbols": runSymbols,
+	"index":   runIndex,
+}
+
+func main() {
+	log.SetPrefix("goxlsw: ")
+	log.SetFlags(0)
+
+	args := os.Args[1:]
+	if len(args) == 0 || len(args[0]) > 0 && args[0][0] == '-' {
+		os.Exit(runServe(args)) // Editors run the server with no subcommand.
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "goxlsw: unknown command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: goxlsw [serve|check|fmt|symbols|index] [arguments]")
+		os.Exit(2)
+	}
+	os.Exit(cmd(args[1:]))
+}
@@ -0,0 +1,167 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/analysis"
+	"github.com/goplus/goxlsw/internal/server"
+)
+
+// compileRuleID is the SARIF rule ID of diagnostics without a source, which
+// are parse and type errors.
+const compileRuleID = "compile"
+
+// SARIF 2.1.0 log format, limited to what the check command reports. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string              `json:"id"`
+		ShortDescription     sarifMessage        `json:"shortDescription"`
+		FullDescription      *sarifMessage       `json:"fullDescription,omitempty"`
+		HelpURI              string              `json:"helpUri,omitempty"`
+		DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+	}
+	sarifConfiguration struct {
+		Level string `json:"level"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   uint32 `json:"startLine"`
+		StartColumn uint32 `json:"startColumn"`
+		EndLine     uint32 `json:"endLine"`
+		EndColumn   uint32 `json:"endColumn"`
+	}
+)
+
+// writeSARIF writes the given diagnostics, whose positions must be in UTF-16,
+// to w as a SARIF log. Analyzers, which are the sources of diagnostics, are
+// described as rules with their documentation.
+func writeSARIF(w io.Writer, diags []fileDiagnostic) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "goxlsw",
+			InformationURI: "https://github.com/goplus/goxlsw",
+			Rules:          []sarifRule{},
+		}},
+		ColumnKind: "utf16CodeUnits",
+		Results:    []sarifResult{},
+	}
+	ruleIndexes := make(map[string]int)
+	for _, diag := range diags {
+		ruleID := diag.Source
+		if ruleID == "" {
+			ruleID = compileRuleID
+		}
+		ruleIndex, ok := ruleIndexes[ruleID]
+		if !ok {
+			ruleIndex = len(run.Tool.Driver.Rules)
+			ruleIndexes[ruleID] = ruleIndex
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleOf(ruleID))
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleID,
+			RuleIndex: ruleIndex,
+			Level:     sarifLevel(diag.Severity),
+			Message:   sarifMessage{Text: diag.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(diag.file)},
+				Region: sarifRegion{
+					StartLine:   diag.Range.Start.Line + 1,
+					StartColumn: diag.Range.Start.Character + 1,
+					EndLine:     diag.Range.End.Line + 1,
+					EndColumn:   diag.Range.End.Character + 1,
+				},
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifRuleOf returns the SARIF rule with the given ID, which is the name of
+// an analyzer or [compileRuleID].
+func sarifRuleOf(id string) sarifRule {
+	if id == compileRuleID {
+		return sarifRule{
+			ID:                   id,
+			ShortDescription:     sarifMessage{Text: "parse and type errors"},
+			DefaultConfiguration: &sarifConfiguration{Level: "error"},
+		}
+	}
+	a, ok := analysis.DefaultAnalyzers[id]
+	if !ok {
+		a, ok = analysis.StaticcheckAnalyzers[id]
+	}
+	if !ok {
+		return sarifRule{ID: id, ShortDescription: sarifMessage{Text: id}}
+	}
+	// The part of the documentation before the first blank line is its
+	// title.
+	title, _, _ := strings.Cut(a.Analyzer().Doc, "\n\n")
+	return sarifRule{
+		ID:                   id,
+		ShortDescription:     sarifMessage{Text: title},
+		FullDescription:      &sarifMessage{Text: a.Analyzer().Doc},
+		HelpURI:              a.Analyzer().URL,
+		DefaultConfiguration: &sarifConfiguration{Level: sarifLevel(server.DiagnosticSeverity(a.Severity()))},
+	}
+}
+
+// sarifLevel returns the SARIF level of the given severity.
+func sarifLevel(severity server.DiagnosticSeverity) string {
+	switch severity {
+	case server.SeverityError:
+		return "error"
+	case server.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
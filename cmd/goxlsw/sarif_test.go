@@ -0,0 +1,56 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	diag := func(file, source string, severity server.DiagnosticSeverity, line uint32) fileDiagnostic {
+		return fileDiagnostic{file: file, Diagnostic: server.Diagnostic{
+			Range: server.Range{
+				Start: server.Position{Line: line, Character: 1},
+				End:   server.Position{Line: line, Character: 4},
+			},
+			Severity: severity,
+			Source:   source,
+			Message:  "message",
+		}}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSARIF(&buf, []fileDiagnostic{
+		diag("main.spx", "printf", server.SeverityWarning, 0),
+		diag("main.spx", "", server.SeverityError, 1),
+		diag("Sprite.spx", "printf", server.SeverityWarning, 2),
+	}))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+
+	require.Len(t, run.Tool.Driver.Rules, 2)
+	printfRule := run.Tool.Driver.Rules[0]
+	assert.Equal(t, "printf", printfRule.ID)
+	assert.Equal(t, "check consistency of Printf format strings and arguments", printfRule.ShortDescription.Text)
+	require.NotNil(t, printfRule.FullDescription)
+	assert.Contains(t, printfRule.FullDescription.Text, "Go+ builtins")
+	assert.Equal(t, compileRuleID, run.Tool.Driver.Rules[1].ID)
+
+	require.Len(t, run.Results, 3)
+	assert.Equal(t, []int{0, 1, 0}, []int{run.Results[0].RuleIndex, run.Results[1].RuleIndex, run.Results[2].RuleIndex})
+	assert.Equal(t, "warning", run.Results[0].Level)
+	assert.Equal(t, "error", run.Results[1].Level)
+	assert.Equal(t, sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: "Sprite.spx"},
+		Region:           sarifRegion{StartLine: 3, StartColumn: 2, EndLine: 3, EndColumn: 5},
+	}, run.Results[2].Locations[0].PhysicalLocation)
+}
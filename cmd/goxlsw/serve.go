@@ -0,0 +1,196 @@
+//go:build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"golang.org/x/net/websocket"
+)
+
+// runServe runs the serve command.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := flags.String("dir", ".", "workspace directory, zip archive, or URL of the listing of a cloud project")
+	listen := flags.String("listen", "", "serve over TCP on the given address instead of stdio")
+	ws := flags.String("ws", "", "serve over WebSocket on the given address instead of stdio")
+	rpcTrace := flags.String("rpc.trace", "", "mirror all JSON-RPC messages to the given file, one JSON object per line")
+	cacheDir := flags.String("cache.dir", "", "persist compiled Go code and package documentation in the given directory across restarts")
+	cacheSize := flags.Int64("cache.size", gop.DefaultDiskCacheSize>>20, "size limit of the -cache.dir directory in MiB, beyond which the least recently used entries are evicted")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	if *listen != "" && *ws != "" {
+		log.Print("-listen and -ws are mutually exclusive")
+		return 2
+	}
+	files, err := workspaceFiles(*dir)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	var trace io.Writer
+	if *rpcTrace != "" {
+		f, err := os.Create(*rpcTrace)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		defer f.Close()
+		trace = &lockedWriter{w: f}
+	}
+
+	var cache *gop.DiskCache
+	if *cacheDir != "" {
+		if *cacheSize <= 0 {
+			log.Print("-cache.size must be positive")
+			return 2
+		}
+		cache, err = gop.OpenDiskCache(*cacheDir, *cacheSize<<20)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+	}
+
+	switch {
+	case *listen != "":
+		err = serveTCP(files, *listen, trace, cache)
+	case *ws != "":
+		err = serveWebSocket(files, *ws, trace, cache)
+	default:
+		err = serve(files, jsonrpc2.NewHeaderStream(stdio{}), trace, cache)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// serve runs a session over stream with a new project of the workspace with
+// the given files, mirroring its messages to trace if not nil. Clients that
+// open workspace folders get a project per folder instead. Projects use cache
+// if not nil.
+func serve(files server.FileMapGetter, stream jsonrpc2.Stream, trace io.Writer, cache *gop.DiskCache) error {
+	defer stream.Close()
+	proj := gop.NewProject(nil, files, gop.FeatAll)
+	proj.SetDiskCache(cache)
+	return server.Serve(stream, proj, files, &server.ServeOptions{
+		OnError:     func(err error) { log.Print(err) },
+		TraceWriter: trace,
+		Folders: func(uri server.URI) (*vfs.MapFS, server.FileMapGetter, error) {
+			return openFolder(uri, cache)
+		},
+	})
+}
+
+// openFolder returns a new project of the workspace folder with the given
+// file URI, which uses cache if not nil. It implements [server.FolderFactory]
+// along with the cache.
+func openFolder(uri server.URI, cache *gop.DiskCache) (*vfs.MapFS, server.FileMapGetter, error) {
+	u, err := url.Parse(string(uri))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "file" {
+		return nil, nil, fmt.Errorf("unsupported workspace folder URI scheme %q", u.Scheme)
+	}
+	dir := filepath.FromSlash(u.Path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	files := newDirFiles(dir)
+	proj := gop.NewProject(nil, files.get, gop.FeatAll)
+	proj.SetDiskCache(cache)
+	return proj, files.get, nil
+}
+
+// serveTCP serves the workspace with the given files to each connection
+// accepted on addr in its own session.
+func serveTCP(files server.FileMapGetter, addr string, trace io.Writer, cache *gop.DiskCache) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	log.Printf("listening on %s", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := serve(files, jsonrpc2.NewHeaderStream(conn), trace, cache); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+}
+
+// serveWebSocket serves the workspace with the given files to each WebSocket
+// connection to addr in its own session. Each message is sent in its own text
+// frame, without headers.
+func serveWebSocket(files server.FileMapGetter, addr string, trace io.Writer, cache *gop.DiskCache) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening on %s", l.Addr())
+	return http.Serve(l, websocket.Server{
+		// Accept any origin, as the server is usually reached through a
+		// proxy.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			if err := serve(files, jsonrpc2.NewRawStream(conn), trace, cache); err != nil {
+				log.Print(err)
+			}
+		},
+	})
+}
+
+// lockedWriter is an [io.Writer] safe for concurrent use, with which the
+// sessions of a listener share a trace file.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// stdio is an [io.ReadWriteCloser] of the standard input and output.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
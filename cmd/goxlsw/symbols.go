@@ -0,0 +1,48 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/goplus/goxlsw/internal/server"
+)
+
+// runSymbols runs the symbols command, which prints the symbols of the
+// workspace matching the given query, all of them by default, as a JSON array
+// of LSP SymbolInformation.
+func runSymbols(args []string) int {
+	flags := flag.NewFlagSet("symbols", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goxlsw symbols [-dir dir] [query]")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", ".", "workspace directory")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	c, err := newLocalClient(*dir, "utf-16")
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	symbols := []server.SymbolInformation{}
+	if err := c.call("workspace/symbol", server.WorkspaceSymbolParams{Query: flags.Arg(0)}, &symbols); err != nil {
+		log.Print(err)
+		return 2
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(symbols); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
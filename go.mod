@@ -9,6 +9,8 @@ require (
 	github.com/goplus/spx v1.1.1-0.20250214074125-e9e1f6362499
 	github.com/qiniu/x v1.13.12
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/mod v0.23.0
+	golang.org/x/net v0.35.0
 	golang.org/x/tools v0.30.0
 )
 
@@ -32,8 +34,6 @@ require (
 	github.com/srwiley/rasterx v0.0.0-20210519020934-456a8d69b780 // indirect
 	golang.org/x/image v0.18.0 // indirect
 	golang.org/x/mobile v0.0.0-20220518205345-8578da9835fd // indirect
-	golang.org/x/mod v0.23.0 // indirect
-	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sync v0.11.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gop
+
+import (
+	"crypto/sha256"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/printer"
+	"github.com/goplus/gop/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// declSig is the signature of the declarations of a file, see buildDeclSig.
+type declSig [sha256.Size]byte
+
+// buildDeclSig returns the signature of the declarations of a Go+ source file,
+// which only changes if its package-level declarations or any comment outside
+// of function bodies change, e.g., doc comments. Edits confined to function
+// bodies, including the statements of the shadow entry, keep the signature.
+//
+// As the declarations of a file with parse errors are not reliable, its
+// signature is that of its content instead.
+func buildDeclSig(fset *token.FileSet, content []byte, f *ast.File, err error) declSig {
+	h := sha256.New()
+	if err != nil || f == nil {
+		h.Write([]byte("content\x00"))
+		h.Write(content)
+		return declSig(h.Sum(nil))
+	}
+
+	h.Write([]byte("decls\x00"))
+	h.Write([]byte(f.Name.Name))
+	for _, flag := range []bool{f.NoPkgDecl, f.IsClass, f.IsProj, f.IsNormalGox} {
+		if flag {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+
+	var bodies []ast.Node
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			if fn == f.ShadowEntry {
+				// Declarations may be interleaved with the statements of the
+				// shadow entry, so its body is not a single range.
+				if fn.Body != nil {
+					for _, stmt := range fn.Body.List {
+						bodies = append(bodies, stmt)
+					}
+				}
+				continue
+			}
+			if fn.Body != nil {
+				bodies = append(bodies, fn.Body)
+			}
+			outline := *fn
+			outline.Doc, outline.Body = nil, nil
+			decl = &outline
+		}
+		printer.Fprint(h, fset, decl) // writing to a hash never fails
+		h.Write([]byte{0})
+	}
+
+	for _, cg := range f.Comments {
+		inBody := false
+		for _, body := range bodies {
+			if body.Pos() <= cg.Pos() && cg.End() <= body.End() {
+				inBody = true
+				break
+			}
+		}
+		if !inBody {
+			h.Write([]byte(cg.Text()))
+			h.Write([]byte{0})
+		}
+	}
+	return declSig(h.Sum(nil))
+}
+
+// declSignature returns the signature of the declarations of all Go+ source
+// files of the project.
+func (p *Project) declSignature() declSig {
+	h := sha256.New()
+	paths := p.gopFilePaths()
+	for i, ret := range p.parseFiles(paths) {
+		h.Write([]byte(paths[i]))
+		h.Write([]byte{0})
+		h.Write(ret.sig[:])
+	}
+	return declSig(h.Sum(nil))
+}
+
+// -----------------------------------------------------------------------------
+
+// declCache is a project level cache initialized by InitDeclCache.
+type declCache struct {
+	sig  declSig // of the project the cache was built for
+	data any     // dataOrErr
+}
+
+// InitDeclCache initializes a project level cache that only depends on the
+// declarations of the Go+ source files, e.g., the package documentation. Unlike
+// other project level caches, it is kept as long as the signatures of the
+// declarations are unchanged, e.g., for edits confined to function bodies. It
+// requires FeatAST.
+func (p *Project) InitDeclCache(kind string, builder Builder) {
+	p.initCache(kind, builder, declScope)
+}
+
+// declCache gets a project level cache initialized by InitDeclCache.
+func (p *Project) declCache(kind string, builder Builder) (any, error) {
+	sig := p.declSignature()
+	if v, ok := p.declCaches.Load(kind); ok && v.(*declCache).sig == sig {
+		return decodeDataOrErr(v.(*declCache).data)
+	}
+	data, err := p.buildOrLoad(kind, sig, builder)
+	p.declCaches.Store(kind, &declCache{sig: sig, data: encodeDataOrErr(data, err)})
+	return data, err
+}
+
+// -----------------------------------------------------------------------------
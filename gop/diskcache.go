@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gop
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goplus/goxlsw/pkgdoc"
+)
+
+// -----------------------------------------------------------------------------
+
+// DefaultDiskCacheSize is the default size limit of a DiskCache in bytes.
+const DefaultDiskCacheSize = 256 << 20
+
+// DiskCache is a persistent cache of project level caches that can be encoded,
+// e.g., the Go code compiled from Go+ source files and the package
+// documentation. Entries are files in a directory, keyed on hashes of the
+// contents they are built from and of the build of the program, so that
+// restarting on the same project reuses them, while changes of either never
+// do. ASTs and type info refer to each other and to a file set, and are not
+// persisted.
+//
+// Entries are evicted in least recently used order once their total size
+// exceeds the limit, see GC. A DiskCache may be shared by projects and by
+// processes.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	salt    string // hash of the build of the program
+
+	mu   sync.Mutex
+	size int64 // total size of the entries, as of the last GC plus puts since
+}
+
+// OpenDiskCache opens the disk cache in the given directory, creating it if
+// needed, which keeps the total size of its entries within maxSize bytes. A
+// maxSize of 0 means DefaultDiskCacheSize. Entries beyond it are evicted right
+// away.
+func OpenDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if maxSize < 0 {
+		return nil, errors.New("disk cache size must not be negative")
+	}
+	if maxSize == 0 {
+		maxSize = DefaultDiskCacheSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{dir: dir, maxSize: maxSize, salt: buildSalt()}
+	if err := c.GC(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// buildSalt returns a hash of the build of the program, which includes the
+// versions of its dependencies, e.g., the spx classfiles, so that cached
+// entries are never used by another build.
+func buildSalt() string {
+	h := sha256.New()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		h.Write([]byte(info.String()))
+	}
+	if exe, err := os.Executable(); err == nil {
+		if fi, err := os.Stat(exe); err == nil {
+			h.Write([]byte(fi.ModTime().String()))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// key returns the key of the entry of the given kind, built for the package in
+// the given directory from inputs with the given signature.
+func (c *DiskCache) key(kind, pkgPath, dir string, sig declSig) string {
+	h := sha256.New()
+	for _, s := range []string{c.salt, kind, pkgPath, dir} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write(sig[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the path of the file of the entry with the given key.
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the data of the entry with the given key, and marks it as
+// recently used.
+func (c *DiskCache) get(key string) ([]byte, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now) // recency is best effort
+	return data, true
+}
+
+// put stores data as the entry with the given key. The entry is written to a
+// temporary file first, so that readers never see it partially written.
+// Failures are ignored, as the entry can be built again.
+func (c *DiskCache) put(key string, data []byte) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	c.mu.Lock()
+	c.size += int64(len(data))
+	overflow := c.size > c.maxSize
+	c.mu.Unlock()
+	if overflow {
+		c.GC()
+	}
+}
+
+// GC evicts the least recently used entries until the total size of the
+// entries is within the limit, and removes temporary files left by crashed
+// writers.
+func (c *DiskCache) GC() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var (
+		entries []entry
+		size    int64
+	)
+	staleTmp := time.Now().Add(-time.Hour)
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed concurrently
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if filepath.Ext(path) == ".tmp" {
+			if info.ModTime().Before(staleTmp) {
+				os.Remove(path)
+			}
+			return nil
+		}
+		entries = append(entries, entry{path, info.Size(), info.ModTime()})
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	for _, e := range entries {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			size -= e.size
+		}
+	}
+	c.size = size
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// diskCodec encodes and decodes the data of a project level cache of some
+// kind for a DiskCache.
+type diskCodec struct {
+	encode func(data any) ([]byte, error)
+	decode func(b []byte) (any, error)
+}
+
+// diskCodecs are the codecs of the kinds of project level caches persisted by
+// a DiskCache.
+var diskCodecs = map[string]diskCodec{
+	"gocode": {encodeGoCode, decodeGoCode},
+	"pkgdoc": {encodePkgDoc, decodePkgDoc},
+}
+
+// errNotPersisted is returned by encoders for data that is not persisted.
+var errNotPersisted = errors.New("not persisted")
+
+// encodeGoCode encodes a goCodeRet. Go code compiled despite errors is not
+// persisted, as the errors keep their positions and types, which are not
+// encoded.
+func encodeGoCode(data any) ([]byte, error) {
+	ret := data.(*goCodeRet)
+	if len(ret.err) > 0 {
+		return nil, errNotPersisted
+	}
+	return ret.code, nil
+}
+
+func decodeGoCode(b []byte) (any, error) {
+	return &goCodeRet{code: b}, nil
+}
+
+func encodePkgDoc(data any) ([]byte, error) {
+	return json.Marshal(data.(*pkgdoc.PkgDoc))
+}
+
+func decodePkgDoc(b []byte) (any, error) {
+	var doc pkgdoc.PkgDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// SetDiskCache sets the disk cache of the project, which persists its project
+// level caches that can be encoded, see DiskCache. A nil c, which is the
+// default, disables it. Snapshots and packages inherit the disk cache.
+func (p *Project) SetDiskCache(c *DiskCache) {
+	p.diskCache = c
+}
+
+// buildOrLoad builds the project level cache of the given kind with builder
+// from inputs with the given signature, or loads it from the disk cache if it
+// was persisted.
+func (p *Project) buildOrLoad(kind string, sig declSig, builder Builder) (any, error) {
+	codec, ok := diskCodecs[kind]
+	if !ok || p.diskCache == nil {
+		return builder(p)
+	}
+	key := p.diskCache.key(kind, p.Path, p.PackageDir(), sig)
+	if b, ok := p.diskCache.get(key); ok {
+		if data, err := codec.decode(b); err == nil {
+			return data, nil
+		}
+	}
+	data, err := builder(p)
+	if err == nil {
+		if b, e := codec.encode(data); e == nil {
+			p.diskCache.put(key, b)
+		}
+	}
+	return data, err
+}
+
+// -----------------------------------------------------------------------------
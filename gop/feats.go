@@ -17,17 +17,30 @@
 package gop
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
 	"go/types"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/cl"
 	"github.com/goplus/gop/parser"
 	"github.com/goplus/gop/scanner"
 	"github.com/goplus/gop/token"
 	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/goplus/goxlsw/pkgdoc"
+	"github.com/goplus/mod/gopmod"
 	"github.com/qiniu/x/errors"
 )
 
@@ -36,12 +49,18 @@ type supportedFeat struct {
 	kind     string
 	builder  any
 	fileFeat bool
+	scope    cacheScope // of project level caches
 }
 
 var supportedFeats = []supportedFeat{
-	{FeatAST, "ast", buildAST, true},
-	{FeatTypeInfo, "typeinfo", buildTypeInfo, false},
-	{FeatPkgDoc, "pkgdoc", buildPkgDoc, false},
+	{FeatAST, "ast", buildAST, true, projectScope},
+	{FeatAST, "goast", buildGoAST, true, projectScope},
+	{FeatAST, "lineindex", buildLineIndex, true, projectScope},
+	{FeatAST, "inspector", buildInspector, true, projectScope},
+	{FeatAST, "cfg", buildCFG, true, projectScope},
+	{FeatTypeInfo, "typeinfo", buildTypeInfo, false, sourceScope},
+	{FeatTypeInfo, "gocode", buildGoCode, false, sourceScope},
+	{FeatPkgDoc, "pkgdoc", buildPkgDoc, false, declScope},
 }
 
 // -----------------------------------------------------------------------------
@@ -54,32 +73,166 @@ func buildAST(proj *Project, path string, file File) (ret any, err error) {
 			err = fmt.Errorf("parser panic: %v", r)
 		}
 	}()
+	f, e := parseGopFile(proj.Fset, path, file.Content)
+	return &astRet{f, e, buildDeclSig(proj.Fset, file.Content, f, e)}, nil
+}
+
+// parseGopFile parses the Go+ source file with the given path and content.
+func parseGopFile(fset *token.FileSet, path string, content []byte) (*ast.File, error) {
 	mode := parserMode
 	if !strings.HasSuffix(path, ".gop") { // TODO(xsw): use gopmod
 		mode |= parser.ParseGoPlusClass
 	}
-	f, e := parser.ParseEntry(proj.Fset, path, file.Content, parser.Config{
+	return parser.ParseEntry(fset, path, content, parser.Config{
 		Mode: mode,
 	})
-	return &astRet{f, e}, nil
 }
 
 type astRet struct {
 	file *ast.File
 	err  error
+	sig  declSig
 }
 
 // AST returns the AST of a Go+ source file.
 func (p *Project) AST(path string) (file *ast.File, err error) {
+	ret := p.astRet(path)
+	return ret.file, ret.err
+}
+
+func (p *Project) astRet(path string) astRet {
 	c, err := p.FileCache("ast", path)
 	if err != nil {
-		return
+		return astRet{err: err}
 	}
-	ret := c.(*astRet)
-	return ret.file, ret.err
+	return *c.(*astRet)
+}
+
+func buildLineIndex(proj *Project, path string, file File) (any, error) {
+	return position.NewLineIndex(file.Content), nil
+}
+
+// LineIndex returns the line index of a file, which converts between byte
+// offsets in the file and LSP positions. It is built along with the AST, as
+// positions are mostly converted for nodes of the AST.
+func (p *Project) LineIndex(path string) (*position.LineIndex, error) {
+	c, err := p.FileCache("lineindex", path)
+	if err != nil {
+		return nil, err
+	}
+	return c.(*position.LineIndex), nil
+}
+
+type inspectorRet struct {
+	file *ast.File
+	in   *inspector.Inspector
+}
+
+func buildInspector(proj *Project, path string, file File) (any, error) {
+	f, err := proj.AST(path)
+	if f == nil {
+		return nil, err
+	}
+	return &inspectorRet{f, inspector.New([]*ast.File{f})}, nil
+}
+
+// Inspector returns the inspector of the AST of a Go+ source file, which
+// traverses the AST, especially for nodes of given types, faster than
+// ast.Inspect. It is built once per AST and shared by all features. Like the
+// AST, it is available even if the file has syntax errors, which are not
+// reported here.
+func (p *Project) Inspector(path string) (*inspector.Inspector, error) {
+	f, err := p.AST(path)
+	if f == nil {
+		return nil, err
+	}
+	if c, _ := p.FileCache("inspector", path); c != nil {
+		if ret := c.(*inspectorRet); ret.file == f {
+			return ret.in, nil
+		}
+	}
+	// The AST was built again after its cache was evicted alone.
+	return inspector.New([]*ast.File{f}), nil
+}
+
+type cfgRet struct {
+	file *ast.File
+	cfgs sync.Map // *ast.BlockStmt => *cfg.CFG
+}
+
+func buildCFG(proj *Project, path string, file File) (any, error) {
+	f, err := proj.AST(path)
+	if f == nil {
+		return nil, err
+	}
+	return &cfgRet{file: f}, nil
+}
+
+// CFG returns the control-flow graph of a function body in the AST of a Go+
+// source file, e.g., the body of a function declaration, a function literal,
+// a lambda or the ShadowEntry of the file. Graphs are built on demand and
+// cached along with the AST. Calls are assumed to return as reported by
+// [cfg.MayReturn], as types are not needed.
+func (p *Project) CFG(path string, body *ast.BlockStmt) (*cfg.CFG, error) {
+	f, err := p.AST(path)
+	if f == nil {
+		return nil, err
+	}
+	if c, _ := p.FileCache("cfg", path); c != nil {
+		if ret := c.(*cfgRet); ret.file == f {
+			if g, ok := ret.cfgs.Load(body); ok {
+				return g.(*cfg.CFG), nil
+			}
+			g, _ := ret.cfgs.LoadOrStore(body, cfg.New(body, cfg.MayReturn))
+			return g.(*cfg.CFG), nil
+		}
+	}
+	// The AST was built again after its cache was evicted alone.
+	return cfg.New(body, cfg.MayReturn), nil
+}
+
+func buildGoAST(proj *Project, path string, file File) (any, error) {
+	f, err := goparser.ParseFile(proj.Fset, path, file.Content, goparser.ParseComments|goparser.AllErrors)
+	return &goASTRet{f, err}, nil
+}
+
+type goASTRet struct {
+	file *goast.File
+	err  error
+}
+
+// goASTFiles returns the AST of all Go source files of the package of the
+// project, excluding tests, which may be imported by Go+ code, e.g., the ones
+// of vendored packages.
+func (p *Project) goASTFiles() (ret []*goast.File, errs errors.List) {
+	dir := p.PackageDir()
+	var paths []string
+	p.RangeFiles(func(file string) bool {
+		if isGoFile(file) && path.Dir(file) == dir {
+			paths = append(paths, file)
+		}
+		return true
+	})
+	slices.Sort(paths)
+	for _, path := range paths {
+		c, err := p.FileCache("goast", path)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		f := c.(*goASTRet)
+		if f.file != nil {
+			ret = append(ret, f.file)
+		}
+		if f.err != nil {
+			errs.Add(f.err)
+		}
+	}
+	return
 }
 
-// ASTFiles returns the AST of all Go+ source files.
+// ASTFiles returns the AST of all Go+ source files of the package of the
+// project.
 func (p *Project) ASTFiles() (name string, ret []*ast.File, err error) {
 	name, err = p.RangeASTFiles(func(_ string, f *ast.File) {
 		ret = append(ret, f)
@@ -101,14 +254,23 @@ func defaultNewTypeInfo() *typesutil.Info {
 }
 
 func buildTypeInfo(proj *Project) (any, error) {
-	var errs errors.List
+	return buildTypeInfoWith(proj, []string{proj.PackageDir()}), nil
+}
+
+// buildTypeInfoWith builds the type information of a Go+ project, which is the
+// package in the last directory of chain, see packageImporter.
+func buildTypeInfoWith(proj *Project, chain []string) *typeInfoRet {
 	name, files, astErr := proj.ASTFiles()
+	goFiles, errs := proj.goASTFiles()
+	if name == "" && len(goFiles) > 0 {
+		name = goFiles[0].Name.Name
+	}
 	pkg := types.NewPackage(proj.Path, name)
 	info := proj.NewTypeInfo()
 	chk := typesutil.NewChecker(
 		&types.Config{
 			Error:    func(err error) { errs.Add(err) },
-			Importer: proj.Importer,
+			Importer: proj.importer(chain),
 		},
 		&typesutil.Config{
 			Types: pkg,
@@ -118,10 +280,10 @@ func buildTypeInfo(proj *Project) (any, error) {
 		nil,
 		info,
 	)
-	if e := chk.Files(nil, files); e != nil && len(errs) == 0 {
+	if e := chk.Files(goFiles, files); e != nil && len(errs) == 0 {
 		errs.Add(e)
 	}
-	return &typeInfoRet{pkg, info, errs, astErr}, nil
+	return &typeInfoRet{pkg, info, errs, astErr}
 }
 
 type typeInfoRet struct {
@@ -141,35 +303,240 @@ func (p *Project) TypeInfo() (pkg *types.Package, info *typesutil.Info, err, ast
 	return ret.pkg, ret.info, ret.typErr.ToError(), ret.astErr
 }
 
+// typeInfo is like TypeInfo, but builds the type information with the given
+// chain of packages being type checked if it is not cached yet. Like
+// CacheContext, it shares the build in progress if any.
+func (p *Project) typeInfo(chain []string) (*typeInfoRet, error) {
+	if v, ok := p.caches.Load("typeinfo"); ok {
+		c, err := decodeDataOrErr(v)
+		if err != nil {
+			return nil, err
+		}
+		return c.(*typeInfoRet), nil
+	}
+	if _, ok := p.builders["typeinfo"]; !ok {
+		return nil, ErrUnknownKind
+	}
+	v, loaded := p.building.LoadOrStore("typeinfo", &cacheBuild{done: make(chan struct{})})
+	build := v.(*cacheBuild)
+	if !loaded {
+		func() {
+			defer close(build.done)
+			data, _ := p.buildCache("typeinfo", func(p *Project) (any, error) {
+				return buildTypeInfoWith(p, chain), nil
+			})
+			build.data = data
+		}()
+		// Files changed during the build invalidate it, see deleteCache.
+		if p.building.CompareAndDelete("typeinfo", build) {
+			p.caches.Store("typeinfo", build.data)
+		}
+	}
+	<-build.done
+	if build.err != nil {
+		return nil, build.err
+	}
+	return build.data.(*typeInfoRet), nil
+}
+
+// TypeInfoContext is like TypeInfo, but returns ctx.Err() as err as soon as
+// ctx is done. See CacheContext for details.
+func (p *Project) TypeInfoContext(ctx context.Context) (pkg *types.Package, info *typesutil.Info, err, astErr error) {
+	c, err := p.CacheContext(ctx, "typeinfo")
+	if err != nil {
+		return
+	}
+	ret := c.(*typeInfoRet)
+	return ret.pkg, ret.info, ret.typErr.ToError(), ret.astErr
+}
+
+// -----------------------------------------------------------------------------
+
+// buildGoCode compiles the Go+ source files of the package of the project to
+// Go. They are parsed again into a new file set, as compiling them modifies
+// their ASTs, e.g., by adding methods of classes.
+func buildGoCode(proj *Project) (ret any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("compiler panic: %v", r)
+		}
+	}()
+	fset := token.NewFileSet()
+	astPkg := &ast.Package{
+		Files:   make(map[string]*ast.File),
+		GoFiles: make(map[string]*goast.File),
+	}
+	var errs errors.List
+	for _, path := range proj.gopFilePaths() {
+		file, ok := proj.File(path)
+		if !ok {
+			continue
+		}
+		f, e := parseGopFile(fset, path, file.Content)
+		if e != nil {
+			errs.Add(e)
+		}
+		if f != nil {
+			if astPkg.Name == "" {
+				astPkg.Name = f.Name.Name
+			}
+			astPkg.Files[path] = f
+		}
+	}
+	dir := proj.PackageDir()
+	proj.RangeFiles(func(file string) bool {
+		if !isGoFile(file) || path.Dir(file) != dir {
+			return true
+		}
+		content, ok := proj.File(file)
+		if !ok {
+			return true
+		}
+		f, e := goparser.ParseFile(fset, file, content.Content, goparser.ParseComments|goparser.AllErrors)
+		if e != nil {
+			errs.Add(e)
+		}
+		if f != nil {
+			astPkg.GoFiles[file] = f
+		}
+		return true
+	})
+
+	mod := proj.Mod
+	if mod == nil {
+		mod = gopmod.Default
+	}
+	pkg, e := cl.NewPackage(proj.Path, astPkg, &cl.Config{
+		Types:          types.NewPackage(proj.Path, astPkg.Name),
+		Fset:           fset,
+		LookupClass:    mod.LookupClass,
+		Importer:       proj.importer([]string{dir}),
+		NoSkipConstant: true,
+	})
+	if e != nil {
+		errs.Add(e)
+	}
+	if pkg == nil {
+		return nil, errs.ToError()
+	}
+	var buf bytes.Buffer
+	if e := pkg.WriteTo(&buf); e != nil {
+		return nil, e
+	}
+	return &goCodeRet{buf.Bytes(), errs}, nil
+}
+
+type goCodeRet struct {
+	code []byte
+	err  errors.List
+}
+
+// GoCode returns the Go source code compiled from the Go+ source files of the
+// package of the project, with line directives referring to the positions in
+// them, see PackageDir. If the files have errors, they are returned as err
+// along with the code compiled despite them, which may be incomplete.
+func (p *Project) GoCode() (code []byte, err error) {
+	c, err := p.Cache("gocode")
+	if err != nil {
+		return
+	}
+	ret := c.(*goCodeRet)
+	return ret.code, ret.err.ToError()
+}
+
+// GoCodeContext is like GoCode, but returns ctx.Err() as err as soon as ctx
+// is done. See CacheContext for details.
+func (p *Project) GoCodeContext(ctx context.Context) (code []byte, err error) {
+	c, err := p.CacheContext(ctx, "gocode")
+	if err != nil {
+		return
+	}
+	ret := c.(*goCodeRet)
+	return ret.code, ret.err.ToError()
+}
+
 // -----------------------------------------------------------------------------
 
-// RangeASTFiles iterates all Go+ AST files.
+// parseConcurrency is the maximum number of files parsed concurrently by
+// RangeASTFiles.
+var parseConcurrency = runtime.GOMAXPROCS(0)
+
+// RangeASTFiles iterates all Go+ AST files of the package of the project, see
+// PackageDir, in path order. The files are parsed
+// concurrently beforehand, and parse errors are reported in path order too.
 func (p *Project) RangeASTFiles(fn func(path string, f *ast.File)) (name string, err error) {
+	paths := p.gopFilePaths()
 	var errs scanner.ErrorList
-	p.RangeFiles(func(path string) bool {
-		switch filepath.Ext(path) { // TODO(xsw): use gopmod
-		case ".spx", ".gop", ".gox":
-			f, e := p.AST(path)
-			if f != nil {
-				if name == "" {
-					name = f.Name.Name
-				}
-				fn(path, f)
+	for i, ret := range p.parseFiles(paths) {
+		f, e := ret.file, ret.err
+		if f != nil {
+			if name == "" {
+				name = f.Name.Name
 			}
-			if e != nil {
-				if el, ok := e.(scanner.ErrorList); ok {
-					errs = append(errs, el...)
-				} else {
-					errs.Add(token.Position{}, e.Error())
-				}
+			fn(paths[i], f)
+		}
+		if e != nil {
+			if el, ok := e.(scanner.ErrorList); ok {
+				errs = append(errs, el...)
+			} else {
+				errs.Add(token.Position{}, e.Error())
 			}
 		}
+	}
+	err = errs.Err()
+	return
+}
+
+// gopFilePaths returns the sorted paths of the Go+ source files of the
+// package of the project, see PackageDir and gopFileFilter.
+func (p *Project) gopFilePaths() (paths []string) {
+	inPackage := p.gopFileFilter()
+	p.RangeFiles(func(file string) bool {
+		if isGopFile(file) && inPackage(file) {
+			paths = append(paths, file)
+		}
 		return true
 	})
-	err = errs.Err()
+	slices.Sort(paths)
 	return
 }
 
+// isGopFile reports whether the file with the given path is a Go+ source file.
+func isGopFile(path string) bool {
+	switch filepath.Ext(path) { // TODO(xsw): use gopmod
+	case ".spx", ".gop", ".gox":
+		return true
+	}
+	return false
+}
+
+// isGoFile reports whether the file with the given path is a Go source file
+// other than a test.
+func isGoFile(path string) bool {
+	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go")
+}
+
+// parseFiles returns the ASTs of the files with the given paths, parsing at
+// most parseConcurrency files at a time.
+func (p *Project) parseFiles(paths []string) []astRet {
+	rets := make([]astRet, len(paths))
+	sem := make(chan struct{}, parseConcurrency)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			rets[i] = p.astRet(path)
+		}()
+	}
+	wg.Wait()
+	return rets
+}
+
 // ASTPackage returns the AST package of a Go+ project.
 func (p *Project) ASTPackage() (pkg *ast.Package, err error) {
 	pkg = &ast.Package{
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/protocol"
+)
+
+// posFile returns the parsed Go+ source file containing pos. It returns nil if
+// pos is not in any file of the project.
+func posFile(proj *gop.Project, pos token.Pos) *ast.File {
+	f := proj.Fset.File(pos)
+	if f == nil {
+		return nil
+	}
+	ret, _ := proj.AST(f.Name())
+	return ret
+}
+
+// PathEnclosingInterval returns the node of the Go+ source file containing
+// start that encloses the interval [start, end), followed by all its ancestors
+// up to the file, like [util.PathEnclosingInterval]. exact reports whether the
+// interval is exactly the extent of the node. It returns nil if start is not
+// in any file of the project.
+func PathEnclosingInterval(proj *gop.Project, start, end token.Pos) (path []ast.Node, exact bool) {
+	f := posFile(proj, start)
+	if f == nil {
+		return nil, false
+	}
+	return util.PathEnclosingInterval(f, start, end)
+}
+
+// NodePath returns node followed by all its ancestors up to the Go+ source
+// file containing it. It returns nil if node is not in any file of the
+// project.
+func NodePath(proj *gop.Project, node ast.Node) []ast.Node {
+	path, _ := PathEnclosingInterval(proj, node.Pos(), node.End())
+	return path
+}
+
+// NodeAt returns the innermost node at pos followed by all its ancestors up to
+// the Go+ source file containing pos. At the end of an identifier or a basic
+// literal, e.g., right after a name being typed, that identifier or literal
+// is the innermost node, unless another one starts at pos. It returns nil if
+// pos is not in any file of the project.
+func NodeAt(proj *gop.Project, pos token.Pos) []ast.Node {
+	path, _ := PathEnclosingInterval(proj, pos, pos)
+	if len(path) > 0 && isLeaf(path[0]) && path[0].Pos() == pos {
+		return path
+	}
+	if before, _ := PathEnclosingInterval(proj, pos-1, pos); len(before) > 0 && isLeaf(before[0]) && before[0].End() == pos {
+		return before
+	}
+	return path
+}
+
+// NodeAtPosition returns the innermost node at the LSP position p, with
+// character offsets counted in enc, in the Go+ source file at path, followed
+// by all its ancestors, like [NodeAt]. It returns nil if the file is not
+// parsed.
+func NodeAtPosition(proj *gop.Project, path string, p protocol.Position, enc position.Encoding) []ast.Node {
+	pos := PosAtPosition(proj, path, p, enc)
+	if pos == token.NoPos {
+		return nil
+	}
+	return NodeAt(proj, pos)
+}
+
+// EnclosingNode returns the innermost node of type T in the path of nodes
+// returned by [PathEnclosingInterval], [NodePath] or [NodeAt], along with
+// its index in path. It returns -1 if there is none.
+func EnclosingNode[T ast.Node](path []ast.Node) (node T, i int) {
+	for i, n := range path {
+		if node, ok := n.(T); ok {
+			return node, i
+		}
+	}
+	return node, -1
+}
+
+// isLeaf reports whether node is an identifier or a basic literal.
+func isLeaf(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/protocol"
+)
+
+const enclosingSrc = `func add(a, b int) int {
+	return a + b
+}
+`
+
+func enclosingProj(t *testing.T) (*gop.Project, func(substr string) token.Pos) {
+	proj := gop.NewProject(nil, map[string]gop.File{
+		"main.gop": file(enclosingSrc),
+	}, gop.FeatAll)
+	f, err := proj.AST("main.gop")
+	if err != nil {
+		t.Fatal("AST:", err)
+	}
+	tf := proj.Fset.File(f.Pos())
+	return proj, func(substr string) token.Pos {
+		return tf.Pos(strings.Index(enclosingSrc, substr))
+	}
+}
+
+func TestPathEnclosingInterval(t *testing.T) {
+	proj, posOf := enclosingProj(t)
+	start := posOf("a + b")
+	path, exact := PathEnclosingInterval(proj, start, start+5)
+	if _, ok := path[0].(*ast.BinaryExpr); !ok || !exact {
+		t.Fatal("PathEnclosingInterval:", path[0], exact)
+	}
+	if _, ok := path[len(path)-1].(*ast.File); !ok {
+		t.Fatal("PathEnclosingInterval: root:", path[len(path)-1])
+	}
+	path, exact = PathEnclosingInterval(proj, start, start+3)
+	if _, ok := path[0].(*ast.BinaryExpr); !ok || exact {
+		t.Fatal("PathEnclosingInterval: partial:", path[0], exact)
+	}
+	if path, _ := PathEnclosingInterval(proj, token.NoPos, token.NoPos); path != nil {
+		t.Fatal("PathEnclosingInterval: NoPos:", path)
+	}
+}
+
+func TestNodePath(t *testing.T) {
+	proj, _ := enclosingProj(t)
+	f, _ := proj.AST("main.gop")
+	decl := f.Decls[0].(*ast.FuncDecl)
+	path := NodePath(proj, decl.Name)
+	if len(path) != 3 || path[0] != decl.Name || path[1] != decl {
+		t.Fatal("NodePath:", path)
+	}
+}
+
+func TestNodeAt(t *testing.T) {
+	proj, posOf := enclosingProj(t)
+	for _, tt := range []struct {
+		name  string
+		pos   token.Pos
+		ident string
+	}{
+		{"Start", posOf("add"), "add"},
+		{"Inside", posOf("add") + 1, "add"},
+		{"End", posOf("add") + 3, "add"},
+		{"Adjacent", posOf("b int"), "b"},
+		{"EndBeforeSpace", posOf("a + b") + 1, "a"},
+	} {
+		path := NodeAt(proj, tt.pos)
+		if ident, ok := path[0].(*ast.Ident); !ok || ident.Name != tt.ident {
+			t.Fatal("NodeAt:", tt.name, path[0])
+		}
+	}
+	path := NodeAt(proj, posOf("+ b"))
+	if _, ok := path[0].(*ast.BinaryExpr); !ok {
+		t.Fatal("NodeAt: operator:", path[0])
+	}
+}
+
+func TestNodeAtPosition(t *testing.T) {
+	proj, _ := enclosingProj(t)
+	path := NodeAtPosition(proj, "main.gop", protocol.Position{Line: 1, Character: 13}, position.UTF16)
+	if ident, ok := path[0].(*ast.Ident); !ok || ident.Name != "b" {
+		t.Fatal("NodeAtPosition:", path[0])
+	}
+	if ret, i := EnclosingNode[*ast.ReturnStmt](path); ret == nil || i != 2 {
+		t.Fatal("EnclosingNode:", ret, i)
+	}
+	if decl, _ := EnclosingNode[*ast.FuncDecl](path); decl == nil || decl.Name.Name != "add" {
+		t.Fatal("EnclosingNode: FuncDecl:", decl)
+	}
+	if lit, i := EnclosingNode[*ast.FuncLit](path); lit != nil || i != -1 {
+		t.Fatal("EnclosingNode: none:", lit, i)
+	}
+	if path := NodeAtPosition(proj, "notfound.gop", protocol.Position{}, position.UTF16); path != nil {
+		t.Fatal("NodeAtPosition: not found:", path)
+	}
+}
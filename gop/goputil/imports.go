@@ -0,0 +1,259 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"bytes"
+	"cmp"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+)
+
+// importSpec is an import spec kept by OrganizeImports.
+type importSpec struct {
+	name string
+	path string
+	src  string // source of the spec, with its comments
+}
+
+// isStd reports whether the import is of a standard library package, i.e.,
+// whether the first element of its path has no dot.
+func (imp importSpec) isStd() bool {
+	first, _, _ := strings.Cut(imp.path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// importsEdit is an edit of the source of a file by OrganizeImports, which
+// replaces the bytes in [start, end) with text.
+type importsEdit struct {
+	start, end int
+	text       string
+}
+
+// removeDeclEdit returns the edit removing the declaration in [start, end) of
+// code, with the line breaks after it.
+func removeDeclEdit(code []byte, start, end int) importsEdit {
+	for end < len(code) && code[end] == '\n' {
+		end++
+	}
+	return importsEdit{start, end, ""}
+}
+
+// OrganizeImports returns the source of the Go+ source file f of proj with
+// organized imports, or nil if they are already organized. Unused imports
+// are removed, and imports of the packages of unresolved selectors, e.g.,
+// strings.ToUpper, are added with the paths found by findPkgPath, which may
+// be nil. Imports are sorted by path, with the standard library packages
+// grouped before the others.
+//
+// Only the import specs are rewritten: they keep the comments above and after
+// them, and are moved into the first import declaration.
+func OrganizeImports(proj *gop.Project, f *ast.File, findPkgPath func(pkgName, member string) (pkgPath string, ok bool)) []byte {
+	_, info, _, _ := proj.TypeInfo()
+	if info == nil {
+		return nil
+	}
+	code := f.Code
+	offset := func(pos token.Pos) int {
+		return proj.Fset.Position(pos).Offset
+	}
+
+	usedPkgNames := make(map[*types.PkgName]bool)
+	for _, obj := range info.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			usedPkgNames[pkgName] = true
+		}
+	}
+	var (
+		decls         []*ast.GenDecl
+		imports       []importSpec
+		importedNames = make(map[string]bool)
+		seen          = make(map[[2]string]bool) // by name and path
+
+		// specsStart and specsEnd are the offsets of the specs of the first
+		// import declaration, including their comments.
+		specsStart, specsEnd int
+	)
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		decls = append(decls, decl)
+		prevEnd := decl.Lparen
+		for i, spec := range decl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			start, end := spec.Pos(), spec.End()
+			if spec.Comment != nil {
+				end = spec.Comment.End()
+			}
+			if decl.Lparen.IsValid() {
+				// Comments between specs belong to the spec after them.
+				for _, cg := range f.Comments {
+					if cg.Pos() > prevEnd && cg.End() <= start {
+						start = cg.Pos()
+						break
+					}
+				}
+			}
+			prevEnd = end
+			if len(decls) == 1 {
+				if i == 0 {
+					specsStart = offset(start)
+				}
+				specsEnd = offset(end)
+			}
+
+			pkgPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil
+			}
+			imp := importSpec{path: pkgPath, src: string(code[offset(start):offset(end)])}
+			if spec.Name != nil {
+				imp.name = spec.Name.Name
+			}
+			var pkgName *types.PkgName
+			if spec.Name != nil {
+				pkgName, _ = info.Defs[spec.Name].(*types.PkgName)
+			} else {
+				pkgName, _ = info.Implicits[spec].(*types.PkgName)
+			}
+			if pkgName != nil && imp.name != "_" && imp.name != "." && !usedPkgNames[pkgName] {
+				continue // Unused import.
+			}
+			if pkgName != nil {
+				importedNames[pkgName.Name()] = true
+			}
+			if key := [2]string{imp.name, imp.path}; !seen[key] {
+				seen[key] = true
+				imports = append(imports, imp)
+			}
+		}
+	}
+
+	if findPkgPath != nil {
+		ast.Inspect(f, func(node ast.Node) bool {
+			sel, ok := node.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			ident, ok := sel.X.(*ast.Ident)
+			if !ok || info.ObjectOf(ident) != nil || importedNames[ident.Name] {
+				return true
+			}
+			if pkgPath, ok := findPkgPath(ident.Name, sel.Sel.Name); ok {
+				importedNames[ident.Name] = true
+				imports = append(imports, importSpec{path: pkgPath, src: strconv.Quote(pkgPath)})
+			}
+			return true
+		})
+	}
+	slices.SortStableFunc(imports, func(a, b importSpec) int {
+		if a.isStd() != b.isStd() {
+			if a.isStd() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.path, b.path)
+	})
+
+	// Lay out the specs one per line, with their comments, separating the
+	// standard library packages from the others by a blank line.
+	var lines []string
+	for i, imp := range imports {
+		if i > 0 && imp.isStd() != imports[i-1].isStd() {
+			lines = append(lines, "")
+		}
+		for _, line := range strings.Split(imp.src, "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	var specs strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			specs.WriteByte('\n')
+			if line != "" {
+				specs.WriteByte('\t')
+			}
+		}
+		specs.WriteString(line)
+	}
+
+	var edits []importsEdit
+	switch {
+	case len(decls) == 0 && len(imports) == 0:
+		return nil
+	case len(decls) == 0:
+		text := "import " + specs.String()
+		if len(lines) > 1 {
+			text = "import (\n\t" + specs.String() + "\n)"
+		}
+		if f.Package.IsValid() {
+			end := offset(f.Name.End())
+			edits = append(edits, importsEdit{end, end, "\n\n" + text})
+		} else {
+			edits = append(edits, importsEdit{0, 0, text + "\n\n"})
+		}
+	case len(imports) == 0:
+		for _, decl := range decls {
+			edits = append(edits, removeDeclEdit(code, offset(decl.Pos()), offset(decl.End())))
+		}
+	default:
+		first := decls[0]
+		switch {
+		case len(first.Specs) == 0:
+			start := offset(first.Lparen) + 1
+			edits = append(edits, importsEdit{start, offset(first.Rparen), "\n\t" + specs.String() + "\n"})
+		case !first.Lparen.IsValid() && len(lines) > 1:
+			edits = append(edits, importsEdit{specsStart, specsEnd, "(\n\t" + specs.String() + "\n)"})
+		case first.Lparen.IsValid() && len(lines) == 1 &&
+			isBlank(code[offset(first.Lparen)+1:specsStart]) && isBlank(code[specsEnd:offset(first.Rparen)]):
+			// A single import without other comments needs no parentheses.
+			edits = append(edits, importsEdit{offset(first.Lparen), offset(first.Rparen) + 1, specs.String()})
+		default:
+			edits = append(edits, importsEdit{specsStart, specsEnd, specs.String()})
+		}
+		for _, decl := range decls[1:] {
+			edits = append(edits, removeDeclEdit(code, offset(decl.Pos()), offset(decl.End())))
+		}
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		buf.Write(code[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(code[last:])
+	if bytes.Equal(buf.Bytes(), code) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// isBlank reports whether b consists of white space only.
+func isBlank(b []byte) bool {
+	return len(bytes.TrimSpace(b)) == 0
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal"
+)
+
+// organizeImports returns src with organized imports, or "" if they are
+// already organized.
+func organizeImports(t *testing.T, src string) string {
+	proj := gop.NewProject(nil, map[string]gop.File{"main.gop": file(src)}, gop.FeatAll)
+	proj.Importer = internal.Importer
+	f, err := proj.AST("main.gop")
+	if err != nil {
+		t.Fatal("AST:", err)
+	}
+	return string(OrganizeImports(proj, f, func(pkgName, member string) (string, bool) {
+		if pkgName == "strings" && member == "ToUpper" {
+			return "strings", true
+		}
+		return "", false
+	}))
+}
+
+func TestOrganizeImports(t *testing.T) {
+	// Comments are kept with their specs, and the standard library packages
+	// are grouped first.
+	got := organizeImports(t, `// Package main is a test.
+package main
+
+import (
+	"os" // for Args
+	"math"
+
+	// yaml parses configs.
+	"example.com/yaml"
+	"fmt"
+)
+
+fmt.Println os.Args, yaml.Version, strings.ToUpper("a")
+`)
+	if want := `// Package main is a test.
+package main
+
+import (
+	"fmt"
+	"os" // for Args
+	"strings"
+
+	// yaml parses configs.
+	"example.com/yaml"
+)
+
+fmt.Println os.Args, yaml.Version, strings.ToUpper("a")
+`; got != want {
+		t.Fatalf("OrganizeImports: got\n%s\nwant\n%s", got, want)
+	}
+
+	// Imports are moved into the first import declaration.
+	got = organizeImports(t, `import "fmt"
+
+import "os"
+
+echo os.Args
+`)
+	if want := `import "os"
+
+echo os.Args
+`; got != want {
+		t.Fatalf("OrganizeImports: got\n%s\nwant\n%s", got, want)
+	}
+
+	got = organizeImports(t, `import "os"
+
+import "fmt"
+
+fmt.Println os.Args
+`)
+	if want := `import (
+	"fmt"
+	"os"
+)
+
+fmt.Println os.Args
+`; got != want {
+		t.Fatalf("OrganizeImports: got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := organizeImports(t, `import (
+	"fmt"
+	"os"
+)
+
+fmt.Println os.Args
+`); got != "" {
+		t.Fatal("OrganizeImports: organized imports changed:", got)
+	}
+}
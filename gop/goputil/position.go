@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/protocol"
+)
+
+// tokenFile returns the token file of the parsed Go+ source file at path. It
+// returns nil if the file is not parsed.
+func tokenFile(proj *gop.Project, path string) *token.File {
+	f, _ := proj.AST(path)
+	if f == nil {
+		return nil
+	}
+	return proj.Fset.File(f.Pos())
+}
+
+// OffsetOf returns the path of the Go+ source file containing pos and the
+// byte offset of pos in it. It returns false if pos is not in any file of the
+// project.
+func OffsetOf(proj *gop.Project, pos token.Pos) (path string, offset int, ok bool) {
+	f := proj.Fset.File(pos)
+	if f == nil {
+		return "", 0, false
+	}
+	return f.Name(), f.Offset(pos), true
+}
+
+// PosAt returns the position of the byte offset in the Go+ source file at
+// path. Offsets beyond the end of the file are clamped to it. It returns
+// [token.NoPos] if the file is not parsed.
+func PosAt(proj *gop.Project, path string, offset int) token.Pos {
+	f := tokenFile(proj, path)
+	if f == nil {
+		return token.NoPos
+	}
+	return f.Pos(min(max(offset, 0), f.Size()))
+}
+
+// PositionOf returns the path of the Go+ source file containing pos and the
+// LSP position of pos in it, with character offsets counted in enc. It
+// returns false if pos is not in any file of the project.
+func PositionOf(proj *gop.Project, pos token.Pos, enc position.Encoding) (path string, p protocol.Position, ok bool) {
+	path, offset, ok := OffsetOf(proj, pos)
+	if !ok {
+		return "", protocol.Position{}, false
+	}
+	idx, err := proj.LineIndex(path)
+	if err != nil {
+		return "", protocol.Position{}, false
+	}
+	return path, idx.Position(enc, offset), true
+}
+
+// PosAtPosition returns the position of the LSP position p, with character
+// offsets counted in enc, in the Go+ source file at path. Positions beyond
+// the end of a line or the file are clamped to it. It returns [token.NoPos]
+// if the file is not parsed.
+func PosAtPosition(proj *gop.Project, path string, p protocol.Position, enc position.Encoding) token.Pos {
+	idx, err := proj.LineIndex(path)
+	if err != nil {
+		return token.NoPos
+	}
+	return PosAt(proj, path, idx.Offset(enc, p))
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"testing"
+
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/protocol"
+)
+
+func TestPositionOf(t *testing.T) {
+	proj := gop.NewProject(nil, map[string]gop.File{
+		"main.gop": file("echo \"中文\", x\n"),
+	}, gop.FeatAll)
+	pos := PosAt(proj, "main.gop", 14)
+	if pos == token.NoPos {
+		t.Fatal("PosAt: NoPos")
+	}
+	path, offset, ok := OffsetOf(proj, pos)
+	if !ok || path != "main.gop" || offset != 14 {
+		t.Fatal("OffsetOf:", path, offset, ok)
+	}
+	for _, tt := range []struct {
+		enc  position.Encoding
+		want protocol.Position
+	}{
+		{position.UTF8, protocol.Position{Line: 0, Character: 14}},
+		{position.UTF16, protocol.Position{Line: 0, Character: 10}},
+		{position.UTF32, protocol.Position{Line: 0, Character: 10}},
+	} {
+		path, p, ok := PositionOf(proj, pos, tt.enc)
+		if !ok || path != "main.gop" || p != tt.want {
+			t.Fatal("PositionOf:", path, p, ok)
+		}
+		if got := PosAtPosition(proj, "main.gop", tt.want, tt.enc); got != pos {
+			t.Fatal("PosAtPosition:", got, pos)
+		}
+	}
+	if _, _, ok := PositionOf(proj, token.NoPos, position.UTF16); ok {
+		t.Fatal("PositionOf: NoPos")
+	}
+}
+
+func TestPosAtPosition(t *testing.T) {
+	proj := gop.NewProject(nil, map[string]gop.File{
+		"main.gop": file("echo 1\necho 2\n"),
+	}, gop.FeatAll)
+	f, err := proj.AST("main.gop")
+	if err != nil {
+		t.Fatal("AST:", err)
+	}
+	tf := proj.Fset.File(f.Pos())
+	if got := PosAtPosition(proj, "main.gop", protocol.Position{Line: 0, Character: 100}, position.UTF16); got != tf.Pos(6) {
+		t.Fatal("PosAtPosition: end of line:", got)
+	}
+	if got := PosAtPosition(proj, "main.gop", protocol.Position{Line: 100}, position.UTF16); got != tf.Pos(tf.Size()) {
+		t.Fatal("PosAtPosition: end of file:", got)
+	}
+	if got := PosAtPosition(proj, "notfound.gop", protocol.Position{}, position.UTF16); got != token.NoPos {
+		t.Fatal("PosAtPosition: not found:", got)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"go/types"
+	"regexp"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/gogen"
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+)
+
+// InnermostScope returns the innermost lexical scope at pos in the Go+ source
+// file containing it. Unlike looking up the scopes by their extents, it finds
+// the scope of the ShadowEntry of the file, i.e., the function of its
+// top-level statements, which has no valid start position. It returns nil if
+// pos is not in any file of the project or the file is not type checked.
+func InnermostScope(proj *gop.Project, pos token.Pos) *types.Scope {
+	f := posFile(proj, pos)
+	if f == nil {
+		return nil
+	}
+	_, info, _, _ := proj.TypeInfo()
+	if info == nil {
+		return nil
+	}
+	fileScope := info.Scopes[f]
+	if fileScope == nil {
+		return nil
+	}
+	innermost := fileScope
+	for _, scope := range info.Scopes {
+		if scope.Pos().IsValid() && scope.Contains(pos) && fileScope.Contains(scope.Pos()) && innermost.Contains(scope.Pos()) {
+			innermost = scope
+		}
+	}
+	if innermost == fileScope && inShadowEntry(f, pos) {
+		if scope := info.Scopes[f.ShadowEntry.Type]; scope != nil {
+			innermost = scope
+		}
+	}
+	return innermost
+}
+
+// inShadowEntry reports whether pos is among the top-level statements of f,
+// i.e., not in any other declaration.
+func inShadowEntry(f *ast.File, pos token.Pos) bool {
+	if f.ShadowEntry == nil {
+		return false
+	}
+	for _, decl := range f.Decls {
+		if decl != f.ShadowEntry && decl.Pos() <= pos && pos < decl.End() {
+			return false
+		}
+	}
+	return f.Pos() <= pos && pos <= f.End()
+}
+
+// ClassRecv returns the implicit receiver of the Go+ class file f, e.g.,
+// `this` of type `*Game`, whose fields and methods are in scope in the whole
+// file. It returns nil if f is not a class file or is not type checked.
+func ClassRecv(proj *gop.Project, f *ast.File) *types.Var {
+	if !f.IsClass || f.ShadowEntry == nil || f.ShadowEntry.Recv == nil {
+		return nil
+	}
+	recv := f.ShadowEntry.Recv.List
+	if len(recv) == 0 || len(recv[0].Names) == 0 {
+		return nil
+	}
+	_, info, _, _ := proj.TypeInfo()
+	if info == nil {
+		return nil
+	}
+	ret, _ := info.Defs[recv[0].Names[0]].(*types.Var)
+	return ret
+}
+
+// LookupAt returns the object that name denotes at pos, looking it up in the
+// enclosing function scopes, then among the fields and methods of the
+// implicit receiver if pos is in a class file, and then in the file, package
+// and universe scopes. Like Go+, it finds exported methods by their names
+// with a lowercase first letter, e.g., `say` for `Say`. It returns nil if
+// name is not found.
+func LookupAt(proj *gop.Project, pos token.Pos, name string) types.Object {
+	scope := InnermostScope(proj, pos)
+	if scope == nil {
+		return nil
+	}
+	f := posFile(proj, pos)
+	_, info, _, _ := proj.TypeInfo()
+	fileScope := info.Scopes[f]
+	for ; scope != nil && scope != fileScope; scope = scope.Parent() {
+		if obj := scope.Lookup(name); obj != nil && (!obj.Pos().IsValid() || obj.Pos() < pos) {
+			return obj
+		}
+	}
+	if recv := ClassRecv(proj, f); recv != nil {
+		names := []string{name}
+		if r, size := utf8.DecodeRuneInString(name); unicode.IsLower(r) {
+			names = append(names, string(unicode.ToUpper(r))+name[size:])
+		}
+		for _, name := range names {
+			if obj, _, _ := types.LookupFieldOrMethod(recv.Type(), true, recv.Pkg(), name); obj != nil {
+				return obj
+			}
+		}
+	}
+	_, obj := fileScope.LookupParent(name, pos)
+	return obj
+}
+
+// ObjectOf returns the object that ident defines or uses. If it is a function
+// or method of a Go+ overload group, overloads are all the overloads in the
+// group, like [OverloadsOf]. It returns nil if ident is not type checked.
+func ObjectOf(proj *gop.Project, ident *ast.Ident) (obj types.Object, overloads []*types.Func) {
+	_, info, _, _ := proj.TypeInfo()
+	if info == nil {
+		return nil, nil
+	}
+	obj = info.ObjectOf(ident)
+	if fun, ok := obj.(*types.Func); ok {
+		if overloads, _ = OverloadsOf(fun); len(overloads) < 2 {
+			overloads = nil
+		}
+	}
+	return obj, overloads
+}
+
+// overloadFuncNameRE is the regular expression of the names of the functions
+// and methods in Go+ overload groups, e.g., `Foo__0`.
+var overloadFuncNameRE = regexp.MustCompile(`^(.+)__([0-9a-z])$`)
+
+// OverloadsOf returns all overloads in the Go+ overload group of the given
+// function, together with the index of the given function in the group. The
+// index is -1 if the given function is the overload group itself. For
+// functions without overloads, it returns the function itself. It returns no
+// overloads for overload groups that cannot be expanded, e.g., method
+// `GetWidget` of spx `Game`.
+func OverloadsOf(fun *types.Func) (overloads []*types.Func, index int) {
+	sig := fun.Type().(*types.Signature)
+	if _, ok := gogen.CheckSigFuncEx(sig); ok {
+		if overloads := expandOverloads(fun); overloads != nil {
+			return overloads, -1
+		}
+		return nil, -1
+	}
+
+	matches := overloadFuncNameRE.FindStringSubmatch(fun.Name())
+	if len(matches) != 3 {
+		return []*types.Func{fun}, 0
+	}
+	groupName := matches[1]
+
+	var group types.Object
+	if recv := sig.Recv(); recv != nil {
+		group, _, _ = types.LookupFieldOrMethod(recv.Type(), true, fun.Pkg(), groupName)
+	} else if fun.Pkg() != nil {
+		group = fun.Pkg().Scope().Lookup(groupName)
+	}
+	groupFun, ok := group.(*types.Func)
+	if !ok {
+		return []*types.Func{fun}, 0
+	}
+	overloads = expandOverloads(groupFun)
+	index = slices.Index(overloads, fun)
+	if index < 0 {
+		return []*types.Func{fun}, 0
+	}
+	return overloads, index
+}
+
+// expandOverloads expands the Go+ overload group fun, whose signature is like
+// `func(__gop_overload_args__ interface{_()})`, to all its overloads. It
+// returns nil if fun is not an expandable overload group.
+func expandOverloads(fun *types.Func) []*types.Func {
+	typ, objs := gogen.CheckSigFuncExObjects(fun.Type().(*types.Signature))
+	if typ == nil {
+		return nil
+	}
+	overloads := make([]*types.Func, 0, len(objs))
+	for _, obj := range objs {
+		overloads = append(overloads, obj.(*types.Func))
+	}
+	return overloads
+}
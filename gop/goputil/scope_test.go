@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package goputil
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal"
+	"github.com/goplus/mod/gopmod"
+	"github.com/goplus/mod/modload"
+)
+
+const (
+	scopeMainSrc = `var n int
+
+func Foo(x int) {
+	echo x
+}
+
+y := 1
+onStart => {
+	z := 2
+	echo n, y, z
+}
+`
+	scopeSpriteSrc = `onStart => {
+	say "Hi"
+}
+`
+)
+
+func spxProj(t *testing.T, files map[string]string) *gop.Project {
+	m := make(map[string]gop.File, len(files))
+	for path, text := range files {
+		m[path] = file(text)
+	}
+	proj := gop.NewProject(nil, m, gop.FeatAll)
+	mod := gopmod.New(modload.Default)
+	if err := mod.ImportClasses(); err != nil {
+		t.Fatal("ImportClasses:", err)
+	}
+	proj.Path = "main"
+	proj.Mod = mod
+	proj.Importer = internal.Importer
+	if _, _, err, _ := proj.TypeInfo(); err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+	return proj
+}
+
+func scopePosOf(t *testing.T, proj *gop.Project, path, src, substr string) token.Pos {
+	f, err := proj.AST(path)
+	if err != nil {
+		t.Fatal("AST:", err)
+	}
+	return proj.Fset.File(f.Pos()).Pos(strings.Index(src, substr))
+}
+
+func TestInnermostScope(t *testing.T) {
+	proj := spxProj(t, map[string]string{"main.spx": scopeMainSrc, "MySprite.spx": scopeSpriteSrc})
+	if scope := InnermostScope(proj, scopePosOf(t, proj, "main.spx", scopeMainSrc, "echo n")); scope == nil || scope.Lookup("z") == nil {
+		t.Fatal("InnermostScope: lambda:", scope)
+	}
+	if scope := InnermostScope(proj, scopePosOf(t, proj, "main.spx", scopeMainSrc, "onStart")); scope == nil || scope.Lookup("y") == nil {
+		t.Fatal("InnermostScope: ShadowEntry:", scope)
+	}
+	if scope := InnermostScope(proj, scopePosOf(t, proj, "main.spx", scopeMainSrc, "echo x")); scope == nil || scope.Lookup("x") == nil {
+		t.Fatal("InnermostScope: func:", scope)
+	}
+	if scope := InnermostScope(proj, token.NoPos); scope != nil {
+		t.Fatal("InnermostScope: NoPos:", scope)
+	}
+}
+
+func TestClassRecv(t *testing.T) {
+	proj := spxProj(t, map[string]string{"main.spx": scopeMainSrc, "MySprite.spx": scopeSpriteSrc})
+	for path, want := range map[string]string{"main.spx": "*main.Game", "MySprite.spx": "*main.MySprite"} {
+		f, _ := proj.AST(path)
+		if recv := ClassRecv(proj, f); recv == nil || recv.Type().String() != want {
+			t.Fatal("ClassRecv:", path, recv)
+		}
+	}
+}
+
+func TestLookupAt(t *testing.T) {
+	proj := spxProj(t, map[string]string{"main.spx": scopeMainSrc, "MySprite.spx": scopeSpriteSrc})
+	pos := scopePosOf(t, proj, "main.spx", scopeMainSrc, "echo n")
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"z", "var z int"},
+		{"y", "var y int"},
+		{"n", "field n int"},
+		{"Foo", "func (*main.Game).Foo(x int)"},
+		{"int", "type int"},
+	} {
+		if obj := LookupAt(proj, pos, tt.name); obj == nil || obj.String() != tt.want {
+			t.Fatal("LookupAt:", tt.name, obj)
+		}
+	}
+	if obj := LookupAt(proj, pos, "x"); obj != nil {
+		t.Fatal("LookupAt: out of scope:", obj)
+	}
+	if obj := LookupAt(proj, scopePosOf(t, proj, "main.spx", scopeMainSrc, "z := 2"), "z"); obj != nil {
+		t.Fatal("LookupAt: before declaration:", obj)
+	}
+	obj := LookupAt(proj, scopePosOf(t, proj, "MySprite.spx", scopeSpriteSrc, "say"), "say")
+	if fun, ok := obj.(*types.Func); !ok || fun.Name() != "Say" {
+		t.Fatal("LookupAt: method:", obj)
+	}
+}
+
+func TestObjectOf(t *testing.T) {
+	proj := spxProj(t, map[string]string{"main.spx": scopeMainSrc, "MySprite.spx": scopeSpriteSrc})
+	f, _ := proj.AST("MySprite.spx")
+	var say *ast.Ident
+	ast.Inspect(f, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Name == "say" {
+			say = ident
+		}
+		return say == nil
+	})
+	obj, overloads := ObjectOf(proj, say)
+	if obj == nil || len(overloads) < 2 {
+		t.Fatal("ObjectOf:", obj, overloads)
+	}
+	if _, index := OverloadsOf(overloads[0]); index != 0 {
+		t.Fatal("OverloadsOf:", index)
+	}
+
+	f, _ = proj.AST("main.spx")
+	decl := f.Decls[1].(*ast.FuncDecl)
+	obj, overloads = ObjectOf(proj, decl.Name)
+	if obj == nil || obj.Name() != "Foo" || overloads != nil {
+		t.Fatal("ObjectOf: Foo:", obj, overloads)
+	}
+}
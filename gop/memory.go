@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gop
+
+import (
+	"container/list"
+	"sync"
+)
+
+// -----------------------------------------------------------------------------
+
+// fileCacheSizeFactor is the estimated size of a file level cache relative to
+// the size of its file, e.g., an AST is usually about an order of magnitude
+// larger than its source code.
+const fileCacheSizeFactor = 16
+
+// MemoryUsage represents the estimated memory usage of a project.
+type MemoryUsage struct {
+	Files          int   // number of files
+	FileBytes      int64 // total size of files
+	FileCaches     int   // number of file level caches
+	FileCacheBytes int64 // estimated size of file level caches
+	Budget         int64 // see SetMemoryBudget, 0 if unlimited
+	Evictions      int64 // number of file level caches evicted so far
+}
+
+// SetMemoryBudget sets the budget in bytes for the estimated size of the file
+// level caches of the project. A budget of 0, which is the default, means
+// unlimited. Snapshots inherit the budget.
+//
+// The budget is enforced when snapshots are taken, so that caches never change
+// under a project in use: the least recently used caches beyond the budget are
+// not carried over to the snapshot, and are built again on demand. The ASTs
+// project level caches, e.g., type info, are built from are always carried
+// over with them, so the budget may be exceeded while such caches exist.
+func (p *Project) SetMemoryBudget(budget int64) {
+	p.lru.mu.Lock()
+	defer p.lru.mu.Unlock()
+	p.lru.budget = budget
+}
+
+// MemoryUsage returns the estimated memory usage of the project.
+func (p *Project) MemoryUsage() (ret MemoryUsage) {
+	p.RangeFileContents(func(_ string, file File) bool {
+		ret.Files++
+		ret.FileBytes += int64(len(file.Content))
+		return true
+	})
+	p.lru.mu.Lock()
+	defer p.lru.mu.Unlock()
+	ret.FileCaches = len(p.lru.elems)
+	ret.FileCacheBytes = p.lru.used
+	ret.Budget = p.lru.budget
+	ret.Evictions = p.lru.evictions
+	return
+}
+
+// trimFileCaches evicts the least recently used file level caches beyond the
+// budget. ASTs are kept while there are project level caches, as those are
+// built from them and refer to their nodes.
+func (p *Project) trimFileCaches() {
+	hasCaches := false
+	p.caches.Range(func(_, _ any) bool {
+		hasCaches = true
+		return false
+	})
+	pinned := func(key fileKey) bool {
+		return hasCaches && (key.kind == "ast" || key.kind == "goast")
+	}
+	for _, key := range p.lru.trim(pinned) {
+		p.fileCaches.Delete(key)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// fileCacheLRU accounts the estimated sizes of the file level caches of a
+// project in least recently used order.
+type fileCacheLRU struct {
+	mu        sync.Mutex
+	budget    int64                     // 0 if unlimited
+	used      int64                     // total size of entries
+	order     list.List                 // of *lruEntry, most recently used first
+	elems     map[fileKey]*list.Element // elements of order by key
+	evictions int64
+}
+
+type lruEntry struct {
+	key  fileKey
+	size int64
+}
+
+// cloneFrom initializes l, which must be unused, as a copy of src.
+func (l *fileCacheLRU) cloneFrom(src *fileCacheLRU) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	l.budget = src.budget
+	l.used = src.used
+	l.evictions = src.evictions
+	l.elems = make(map[fileKey]*list.Element, len(src.elems))
+	for e := src.order.Front(); e != nil; e = e.Next() {
+		entry := *e.Value.(*lruEntry)
+		l.elems[entry.key] = l.order.PushBack(&entry)
+	}
+}
+
+// use marks the cache with the given key of the given file as the most
+// recently used one.
+func (l *fileCacheLRU) use(key fileKey, file File) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.elems[key]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	if l.elems == nil {
+		l.elems = make(map[fileKey]*list.Element)
+	}
+	size := int64(len(file.Content)) * fileCacheSizeFactor
+	l.elems[key] = l.order.PushFront(&lruEntry{key: key, size: size})
+	l.used += size
+}
+
+// remove removes the entry with the given key, if any.
+func (l *fileCacheLRU) remove(key fileKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.elems[key]; ok {
+		l.removeLocked(e)
+	}
+}
+
+// trim removes the least recently used entries, except pinned ones, until the
+// budget is no longer exceeded, and returns their keys.
+func (l *fileCacheLRU) trim(pinned func(key fileKey) bool) (evicted []fileKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.budget <= 0 {
+		return nil
+	}
+	for e := l.order.Back(); e != nil && l.used > l.budget; {
+		prev := e.Prev()
+		if key := e.Value.(*lruEntry).key; !pinned(key) {
+			evicted = append(evicted, key)
+			l.removeLocked(e)
+			l.evictions++
+		}
+		e = prev
+	}
+	return
+}
+
+func (l *fileCacheLRU) removeLocked(e *list.Element) {
+	entry := l.order.Remove(e).(*lruEntry)
+	delete(l.elems, entry.key)
+	l.used -= entry.size
+}
+
+// -----------------------------------------------------------------------------
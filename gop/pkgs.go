@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gop
+
+import (
+	"fmt"
+	"go/types"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// -----------------------------------------------------------------------------
+
+// modFiles are the files that may declare the module of a project, in order of
+// precedence.
+var modFiles = []string{"gop.mod", "go.mod"}
+
+// ModulePath returns the module path declared by the gop.mod or go.mod file at
+// the root of the project, or "" if there is none. Packages in subdirectories
+// can only be imported if the module path is declared.
+func (p *Project) ModulePath() string {
+	for _, name := range modFiles {
+		if file, ok := p.File(name); ok {
+			return modfile.ModulePath(file.Content)
+		}
+	}
+	return ""
+}
+
+// PackageDir returns the directory of the package of the project, "." for the
+// root package.
+func (p *Project) PackageDir() string {
+	if p.pkgDir == "" {
+		return "."
+	}
+	return p.pkgDir
+}
+
+// gopFileFilter returns a function reporting whether the Go+ source file with
+// the given path belongs to the package of the project. Without ModulePath,
+// packages in subdirectories cannot be imported, so the Go+ source files in
+// subdirectories other than the vendor directory belong to the root package,
+// as if they were in the root directory.
+func (p *Project) gopFileFilter() func(file string) bool {
+	dir := p.PackageDir()
+	flat := p.pkgDir == "" && p.ModulePath() == ""
+	return func(file string) bool {
+		fileDir := path.Dir(file)
+		if fileDir == dir {
+			return true
+		}
+		if !flat {
+			return false
+		}
+		_, vendored := p.vendoredPackagePath(fileDir)
+		return !vendored
+	}
+}
+
+// PackageDirs returns the sorted directories of all packages of the project,
+// i.e., the directories containing Go+ or Go source files, including the ones
+// of vendored packages.
+func (p *Project) PackageDirs() (dirs []string) {
+	p.RangeFiles(func(file string) bool {
+		if isGopFile(file) || isGoFile(file) {
+			if dir := path.Dir(file); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		return true
+	})
+	slices.Sort(dirs)
+	return
+}
+
+// Package returns the project of the package in the given directory, see
+// PackageDirs. It shares the files and settings of p, and its package path is
+// the directory relative to the vendor directory for vendored packages, or
+// the directory joined to ModulePath otherwise. It is dropped once any file
+// of p changes, and must not be modified.
+func (p *Project) Package(dir string) (*Project, error) {
+	dir = path.Clean(dir)
+	if dir == p.PackageDir() {
+		return p, nil
+	}
+	root := p
+	if p.root != nil {
+		root = p.root
+	}
+	if v, ok := root.packages.Load(dir); ok {
+		return v.(*Project), nil
+	}
+	if !slices.Contains(root.PackageDirs(), dir) {
+		return nil, fmt.Errorf("no Go+ or Go source files in %s", dir)
+	}
+	pkg := root.Snapshot()
+	pkg.caches.Clear()
+	pkg.declCaches.Clear()
+	pkg.root = root
+	pkg.pkgDir = dir
+	if pkgPath, ok := root.vendoredPackagePath(dir); ok {
+		pkg.Path = pkgPath
+	} else {
+		pkg.Path = path.Join(root.ModulePath(), dir)
+	}
+	v, _ := root.packages.LoadOrStore(dir, pkg)
+	return v.(*Project), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// SetVendorDir sets the directory of vendored packages, which are imported
+// from source by their path relative to it, e.g., vendor/github.com/foo/bar
+// for "github.com/foo/bar" if dir is "vendor". Clients without file system
+// access, e.g., in the browser, can provide them by unpacking a prefetched
+// archive into it. An empty dir, which is the default, disables vendoring.
+// Snapshots inherit the directory.
+func (p *Project) SetVendorDir(dir string) {
+	if dir != "" {
+		dir = path.Clean(dir)
+	}
+	if dir == p.vendorDir {
+		return
+	}
+	p.vendorDir = dir
+	p.building.Clear()
+	p.caches.Clear()
+	p.packages.Clear()
+}
+
+// VendorDir returns the directory of vendored packages, see SetVendorDir.
+func (p *Project) VendorDir() string {
+	return p.vendorDir
+}
+
+// vendoredPackagePath returns the path of the vendored package in the given
+// directory, and false if dir is not in the vendor directory.
+func (p *Project) vendoredPackagePath(dir string) (string, bool) {
+	if p.vendorDir == "" {
+		return "", false
+	}
+	return strings.CutPrefix(dir, p.vendorDir+"/")
+}
+
+// ResolveImport returns the directory of the package of the project with the
+// given import path, i.e., a package of the module declared by ModulePath, or
+// a vendored package, see SetVendorDir. It returns false if there is no such
+// package, in which case the package is imported by Importer.
+func (p *Project) ResolveImport(pkgPath string) (dir string, ok bool) {
+	var dirs []string
+	if modPath := p.ModulePath(); modPath != "" {
+		if dir, ok := strings.CutPrefix(pkgPath, modPath+"/"); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	if p.vendorDir != "" {
+		dirs = append(dirs, path.Join(p.vendorDir, pkgPath))
+	}
+	pkgDirs := p.PackageDirs()
+	for _, dir := range dirs {
+		if slices.Contains(pkgDirs, dir) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+
+// packageImporter is the importer used to type check the packages of a
+// project. It imports the packages of the project itself from source, see
+// ResolveImport, and the other packages with the Importer of the project.
+type packageImporter struct {
+	proj  *Project
+	chain []string // directories of the packages being type checked
+}
+
+// importer returns the importer used to type check p, which is the package in
+// the last directory of chain.
+func (p *Project) importer(chain []string) types.Importer {
+	if p.ModulePath() == "" && p.vendorDir == "" {
+		return p.Importer
+	}
+	return &packageImporter{proj: p, chain: chain}
+}
+
+// Import implements types.Importer.
+func (imp *packageImporter) Import(pkgPath string) (*types.Package, error) {
+	dir, ok := imp.proj.ResolveImport(pkgPath)
+	if !ok {
+		if imp.proj.Importer == nil {
+			return nil, fmt.Errorf("cannot import %q: no importer", pkgPath)
+		}
+		return imp.proj.Importer.Import(pkgPath)
+	}
+	// Cycles are detected before type checking, as the build of a package in
+	// the cycle may be in progress in another goroutine, which would then wait
+	// for this one.
+	if slices.Contains(imp.chain, dir) || imp.proj.importsAny(dir, imp.chain) {
+		return nil, fmt.Errorf("import cycle not allowed: %s", pkgPath)
+	}
+	pkg, err := imp.proj.Package(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import %q: %w", pkgPath, err)
+	}
+	ret, err := pkg.typeInfo(append(slices.Clip(imp.chain), dir))
+	if err != nil {
+		return nil, err
+	}
+	return ret.pkg, nil
+}
+
+// importsAny reports whether the package in the given directory imports a
+// package in any of dirs, directly or indirectly, see ResolveImport.
+func (p *Project) importsAny(dir string, dirs []string) bool {
+	seen := make(map[string]bool)
+	var imports func(dir string) bool
+	imports = func(dir string) bool {
+		if seen[dir] {
+			return false
+		}
+		seen[dir] = true
+		pkg, err := p.Package(dir)
+		if err != nil {
+			return false
+		}
+		for _, pkgPath := range pkg.importPaths() {
+			if imported, ok := p.ResolveImport(pkgPath); ok && (slices.Contains(dirs, imported) || imports(imported)) {
+				return true
+			}
+		}
+		return false
+	}
+	return imports(dir)
+}
+
+// importPaths returns the paths of the packages imported by the Go+ and Go
+// source files of the package of the project.
+func (p *Project) importPaths() (paths []string) {
+	add := func(lit string) {
+		if pkgPath, err := strconv.Unquote(lit); err == nil && !slices.Contains(paths, pkgPath) {
+			paths = append(paths, pkgPath)
+		}
+	}
+	_, files, _ := p.ASTFiles()
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			add(spec.Path.Value)
+		}
+	}
+	goFiles, _ := p.goASTFiles()
+	for _, f := range goFiles {
+		for _, spec := range f.Imports {
+			add(spec.Path.Value)
+		}
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
@@ -17,10 +17,12 @@
 package gop
 
 import (
+	"context"
 	"errors"
 	"go/token"
 	"go/types"
 	"io/fs"
+	"maps"
 	"sync"
 	"time"
 
@@ -29,7 +31,8 @@ import (
 )
 
 var (
-	// ErrUnknownKind represents an error of unknown kind.
+	// ErrUnknownKind represents an error of unknown kind, which is returned
+	// for kinds not initialized by InitFileCache, InitCache or InitDeclCache.
 	ErrUnknownKind = errors.New("unknown kind")
 )
 
@@ -74,11 +77,24 @@ type Project struct {
 
 	caches     sync.Map // kind => dataOrErr
 	fileCaches sync.Map // (kind, path) => dataOrErr
+	building   sync.Map // kind => *cacheBuild, see CacheContext
+	declCaches sync.Map // kind => *declCache, see InitDeclCache
+	srcCaches  sync.Map // (kind, dir) => *sourceCache, see InitSourceCache
+	packages   sync.Map // dir => *Project, see Package
+	lru        fileCacheLRU
+
+	root      *Project // project of the root package, nil if it is p
+	pkgDir    string   // see PackageDir
+	vendorDir string   // see SetVendorDir
+
+	diskCache *DiskCache // see SetDiskCache
 
 	// kind => builder
 	builders     map[string]Builder
 	fileBuilders map[string]FileBuilder
 
+	scopes map[string]cacheScope // kinds of builders not initialized by InitCache
+
 	// initialized by NewProject
 	Fset *token.FileSet
 
@@ -105,6 +121,7 @@ func NewProject(fset *token.FileSet, files any, feats uint) *Project {
 		Fset:         fset,
 		builders:     make(map[string]Builder),
 		fileBuilders: make(map[string]FileBuilder),
+		scopes:       make(map[string]cacheScope),
 		NewTypeInfo:  defaultNewTypeInfo,
 	}
 	if files != nil {
@@ -125,7 +142,7 @@ func NewProject(fset *token.FileSet, files any, feats uint) *Project {
 			if f.fileFeat {
 				ret.InitFileCache(f.kind, f.builder.(FileBuilder))
 			} else {
-				ret.InitCache(f.kind, f.builder.(Builder))
+				ret.initCache(f.kind, f.builder.(Builder), f.scope)
 			}
 		}
 	}
@@ -139,6 +156,11 @@ func (p *Project) Snapshot() *Project {
 	ret := &Project{
 		builders:     p.builders,
 		fileBuilders: p.fileBuilders,
+		scopes:       p.scopes,
+		root:         p.root,
+		pkgDir:       p.pkgDir,
+		vendorDir:    p.vendorDir,
+		diskCache:    p.diskCache,
 		Fset:         p.Fset,
 		Mod:          p.Mod,
 		Path:         p.Path,
@@ -148,6 +170,10 @@ func (p *Project) Snapshot() *Project {
 	copyMap(&ret.files, &p.files)
 	copyMap(&ret.caches, &p.caches)
 	copyMap(&ret.fileCaches, &p.fileCaches)
+	copyMap(&ret.declCaches, &p.declCaches)
+	copyMap(&ret.srcCaches, &p.srcCaches)
+	ret.lru.cloneFrom(&p.lru)
+	ret.trimFileCaches()
 	return ret
 }
 
@@ -161,10 +187,16 @@ func copyMap(dst, src *sync.Map) {
 // -----------------------------------------------------------------------------
 
 func (p *Project) deleteCache(path string) {
+	p.building.Clear()
 	p.caches.Clear()
+	p.packages.Clear()
 	for kind := range p.fileBuilders {
-		p.fileCaches.Delete(fileKey{kind, path})
+		key := fileKey{kind, path}
+		p.fileCaches.Delete(key)
+		p.lru.remove(key)
 	}
+	// ASTs kept for the project level caches may be evicted now.
+	p.trimFileCaches()
 }
 
 // Rename renames a file in the project.
@@ -252,20 +284,74 @@ func (p *Project) RangeFileContents(f func(path string, file File) bool) {
 
 // -----------------------------------------------------------------------------
 
-// InitFileCache initializes a file level cache.
-func (p *Project) InitFileCache(kind string, builder func(proj *Project, path string, file File) (any, error)) {
+// InitFileCache initializes a file level cache of the given kind, which is
+// also how downstream tools attach their own data derived from a single file,
+// e.g., a control-flow graph. The cache of a file is built by builder on first
+// use by FileCache, and dropped when the file changes or is evicted to stay
+// within the memory budget. Snapshots share the caches of unchanged files.
+//
+// Caches should be initialized before the project is used concurrently. Doing
+// so affects the project and the snapshots taken afterwards, but not the ones
+// taken before, and drops any existing caches of the kind.
+func (p *Project) InitFileCache(kind string, builder FileBuilder) {
+	p.fileBuilders = maps.Clone(p.fileBuilders)
 	p.fileBuilders[kind] = builder
+	p.fileCaches.Range(func(k, _ any) bool {
+		if key := k.(fileKey); key.kind == kind {
+			p.fileCaches.Delete(key)
+			p.lru.remove(key)
+		}
+		return true
+	})
+}
+
+// InitCache initializes a project level cache of the given kind, which is also
+// how downstream tools attach their own data derived from the whole project.
+// The cache is built by builder on first use by Cache or CacheContext, and
+// dropped when any file changes. See InitDeclCache and InitSourceCache for
+// caches that depend on fewer files, and InitFileCache for how initializing a
+// cache affects snapshots.
+func (p *Project) InitCache(kind string, builder Builder) {
+	p.initCache(kind, builder, projectScope)
 }
 
-// InitCache initializes a project level cache.
-func (p *Project) InitCache(kind string, builder func(root *Project) (any, error)) {
+// cacheScope is what a project level cache depends on, which determines how
+// long it is kept.
+type cacheScope int
+
+const (
+	projectScope cacheScope = iota // all files, see InitCache
+	declScope                      // declarations of Go+ source files, see InitDeclCache
+	sourceScope                    // source and module files, see InitSourceCache
+)
+
+func (p *Project) initCache(kind string, builder Builder, scope cacheScope) {
+	p.builders = maps.Clone(p.builders)
 	p.builders[kind] = builder
+	p.scopes = maps.Clone(p.scopes)
+	if scope != projectScope {
+		p.scopes[kind] = scope
+	} else {
+		delete(p.scopes, kind)
+	}
+	p.caches.Delete(kind)
+	p.building.Delete(kind)
+	p.declCaches.Delete(kind)
+	p.srcCaches.Range(func(k, _ any) bool {
+		if k.(fileKey).kind == kind {
+			p.srcCaches.Delete(k)
+		}
+		return true
+	})
 }
 
 // FileCache gets a file level cache.
 func (p *Project) FileCache(kind, path string) (any, error) {
 	key := fileKey{kind, path}
 	if v, ok := p.fileCaches.Load(key); ok {
+		if file, ok := p.File(path); ok {
+			p.lru.use(key, file)
+		}
 		return decodeDataOrErr(v)
 	}
 	builder, ok := p.fileBuilders[kind]
@@ -278,6 +364,7 @@ func (p *Project) FileCache(kind, path string) (any, error) {
 	}
 	data, err := builder(p, path, file)
 	p.fileCaches.Store(key, encodeDataOrErr(data, err))
+	p.lru.use(key, file)
 	return data, err
 }
 
@@ -290,11 +377,55 @@ func (p *Project) Cache(kind string) (any, error) {
 	if !ok {
 		return nil, ErrUnknownKind
 	}
-	data, err := builder(p)
+	if p.scopes[kind] == declScope {
+		return p.declCache(kind, builder)
+	}
+	data, err := p.buildCache(kind, builder)
 	p.caches.Store(kind, encodeDataOrErr(data, err))
 	return data, err
 }
 
+// cacheBuild represents an in-progress build of a project level cache.
+type cacheBuild struct {
+	done chan struct{} // closed when the build completes
+	data any
+	err  error
+}
+
+// CacheContext is like Cache, but returns ctx.Err() as soon as ctx is done.
+// Builders cannot be interrupted, so the build keeps running in the background
+// and its result is still cached. Concurrent calls share a single build.
+func (p *Project) CacheContext(ctx context.Context, kind string) (any, error) {
+	if v, ok := p.caches.Load(kind); ok {
+		return decodeDataOrErr(v)
+	}
+	builder, ok := p.builders[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	if p.scopes[kind] == declScope {
+		return p.declCache(kind, builder)
+	}
+	v, loaded := p.building.LoadOrStore(kind, &cacheBuild{done: make(chan struct{})})
+	build := v.(*cacheBuild)
+	if !loaded {
+		go func() {
+			defer close(build.done)
+			build.data, build.err = p.buildCache(kind, builder)
+			// Files changed during the build invalidate it, see deleteCache.
+			if p.building.CompareAndDelete(kind, build) {
+				p.caches.Store(kind, encodeDataOrErr(build.data, build.err))
+			}
+		}()
+	}
+	select {
+	case <-build.done:
+		return build.data, build.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func decodeDataOrErr(v any) (any, error) {
 	if err, ok := v.(error); ok {
 		return nil, err
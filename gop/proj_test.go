@@ -17,9 +17,23 @@
 package gop
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
 	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/scanner"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
 )
 
 func file(text string) File {
@@ -117,6 +131,29 @@ func TestNewNil(t *testing.T) {
 	})
 }
 
+func TestGoCode(t *testing.T) {
+	proj := NewProject(nil, nil, FeatAll)
+	proj.PutFile("main.gop", file("func add(a, b int) int {\n\treturn a + b\n}\n\nprintln add(1, 2)\n"))
+	code, err := proj.GoCode()
+	if err != nil {
+		t.Fatal("GoCode:", err)
+	}
+	for _, s := range []string{"//line main.gop:1:1\nfunc add(a int, b int) int {", "//line main.gop:5:1\n\tprintln(add(1, 2))"} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Fatalf("GoCode: %q not found in:\n%s", s, code)
+		}
+	}
+	code2, err2 := proj.GoCode()
+	if !bytes.Equal(code2, code) || err2 != nil {
+		t.Fatal("GoCode again:", err2)
+	}
+
+	proj.PutFile("main.gop", file("println undefined\n"))
+	if _, err = proj.GoCode(); err == nil {
+		t.Fatal("GoCode: no error")
+	}
+}
+
 func TestNewCallback(t *testing.T) {
 	proj := NewProject(nil, func() map[string]File {
 		return map[string]File{
@@ -173,6 +210,58 @@ func TestErr(t *testing.T) {
 	}
 }
 
+func TestCacheContext(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"main.spx": file("echo 100"),
+	}, 0)
+	var builds atomic.Int32
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	proj.InitCache("slow", func(*Project) (any, error) {
+		builds.Add(1)
+		started <- struct{}{}
+		<-release
+		return "data", nil
+	})
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := proj.CacheContext(canceled, "slow"); err != context.Canceled {
+		t.Fatal("CacheContext canceled:", err)
+	}
+	close(release)
+	if data, err := proj.CacheContext(context.Background(), "slow"); err != nil || data != "data" {
+		t.Fatal("CacheContext:", data, err)
+	}
+	if n := builds.Load(); n != 1 {
+		t.Fatal("builds:", n)
+	}
+	if data, err := proj.Cache("slow"); err != nil || data != "data" {
+		t.Fatal("Cache:", data, err)
+	}
+
+	// A build abandoned by a file change is not cached.
+	release = make(chan struct{})
+	proj.PutFile("main.spx", file("echo 200"))
+	if _, err := proj.CacheContext(canceled, "slow"); err != context.Canceled {
+		t.Fatal("CacheContext canceled:", err)
+	}
+	<-started
+	<-started
+	proj.PutFile("main.spx", file("echo 300"))
+	close(release)
+	if data, err := proj.CacheContext(context.Background(), "slow"); err != nil || data != "data" {
+		t.Fatal("CacheContext:", data, err)
+	}
+	if n := builds.Load(); n != 3 {
+		t.Fatal("builds:", n)
+	}
+
+	if _, err := proj.CacheContext(context.Background(), "unknown"); err != ErrUnknownKind {
+		t.Fatal("CacheContext unknown:", err)
+	}
+}
+
 func TestUpdateFiles(t *testing.T) {
 	now := time.Now()
 	later := now.Add(time.Hour)
@@ -258,3 +347,694 @@ func TestUpdateFiles(t *testing.T) {
 		t.Fatal("Cache should be invalidated when ModTime changes")
 	}
 }
+
+func TestMemoryBudget(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"a.gop": file("echo 100"),
+		"b.gop": file("echo 200"),
+		"c.gop": file("echo 300"),
+	}, FeatAST)
+	asts := make(map[string]any)
+	for _, path := range []string{"a.gop", "b.gop", "c.gop", "a.gop"} {
+		f, err := proj.AST(path)
+		if err != nil {
+			t.Fatal("AST:", err)
+		}
+		asts[path] = f
+	}
+	usage := proj.MemoryUsage()
+	if usage.Files != 3 || usage.FileBytes != 24 || usage.FileCaches != 3 || usage.FileCacheBytes != 3*8*fileCacheSizeFactor {
+		t.Fatal("MemoryUsage:", usage)
+	}
+
+	// Without a budget, snapshots carry over all caches.
+	if snap := proj.Snapshot(); snap.MemoryUsage() != usage {
+		t.Fatal("Snapshot MemoryUsage:", snap.MemoryUsage())
+	}
+
+	// The least recently used cache, of b.gop, is not carried over, while
+	// the project itself is unaffected.
+	proj.SetMemoryBudget(2 * 8 * fileCacheSizeFactor)
+	snap := proj.Snapshot()
+	if usage := snap.MemoryUsage(); usage.FileCaches != 2 || usage.Evictions != 1 || usage.Budget != 2*8*fileCacheSizeFactor {
+		t.Fatal("Snapshot MemoryUsage:", usage)
+	}
+	if usage := proj.MemoryUsage(); usage.FileCaches != 3 || usage.Evictions != 0 {
+		t.Fatal("MemoryUsage:", usage)
+	}
+	for path, want := range asts {
+		f, err := snap.AST(path)
+		if err != nil {
+			t.Fatal("Snapshot AST:", err)
+		}
+		if (f == want) != (path != "b.gop") {
+			t.Fatal("Snapshot AST reused:", path, f == want)
+		}
+	}
+
+	// Deleted files no longer count.
+	proj.DeleteFile("c.gop")
+	if usage := proj.MemoryUsage(); usage.Files != 2 || usage.FileCaches != 2 {
+		t.Fatal("MemoryUsage after DeleteFile:", usage)
+	}
+}
+
+func TestMemoryBudgetTypeInfo(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"a.gop": file("func a() {}"),
+		"b.gop": file("func b() {}"),
+	}, FeatAll)
+	pkg, _, err, _ := proj.TypeInfo()
+	if err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+	asts := make(map[string]any)
+	for _, path := range []string{"a.gop", "b.gop"} {
+		if _, err := proj.Inspector(path); err != nil {
+			t.Fatal("Inspector:", err)
+		}
+		asts[path], _ = proj.AST(path)
+	}
+
+	// With a budget below the size of the ASTs, the ASTs type info is built
+	// from are carried over along with it, while other caches are evicted.
+	proj.SetMemoryBudget(11 * fileCacheSizeFactor)
+	snap := proj.Snapshot()
+	if pkg2, _, _, _ := snap.TypeInfo(); pkg2 != pkg {
+		t.Fatal("Snapshot TypeInfo not reused")
+	}
+	for path, want := range asts {
+		if f, _ := snap.AST(path); f != want {
+			t.Fatal("Snapshot AST not reused:", path)
+		}
+		if _, ok := snap.fileCaches.Load(fileKey{"inspector", path}); ok {
+			t.Fatal("Snapshot Inspector not evicted:", path)
+		}
+	}
+
+	// Without project level caches, ASTs are evicted too.
+	proj.PutFile("a.gop", file("func c() {}"))
+	if _, err := proj.AST("a.gop"); err != nil {
+		t.Fatal("AST:", err)
+	}
+	if usage := proj.Snapshot().MemoryUsage(); usage.FileCacheBytes > usage.Budget {
+		t.Fatal("Snapshot MemoryUsage:", usage)
+	}
+}
+
+func TestInspector(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"a.gop": file("echo 100"),
+	}, FeatAST)
+	in, err := proj.Inspector("a.gop")
+	if err != nil {
+		t.Fatal("Inspector:", err)
+	}
+	if in2, _ := proj.Inspector("a.gop"); in2 != in {
+		t.Fatal("Inspector not cached")
+	}
+	var calls int
+	for range inspector.All[*ast.CallExpr](in) {
+		calls++
+	}
+	if calls != 1 {
+		t.Fatal("Inspector calls:", calls)
+	}
+	root := func(in *inspector.Inspector) ast.Node {
+		for n := range in.PreorderSeq() {
+			return n
+		}
+		return nil
+	}
+
+	// The AST, used before the inspector, is evicted alone, and the inspector
+	// of the AST built again is not the stale one.
+	proj.SetMemoryBudget(8 * fileCacheSizeFactor)
+	snap := proj.Snapshot()
+	f, _ := snap.AST("a.gop")
+	if in, _ := snap.Inspector("a.gop"); in == nil || root(in) != f {
+		t.Fatal("Snapshot Inspector is stale")
+	}
+
+	proj.PutFile("a.gop", file("echo 200"))
+	if in2, _ := proj.Inspector("a.gop"); in2 == in {
+		t.Fatal("Inspector not rebuilt")
+	}
+	if _, err := proj.Inspector("b.gop"); err == nil {
+		t.Fatal("Inspector of a missing file")
+	}
+}
+
+func TestCFG(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"a.gop": file(`func f() {
+	for {
+		echo 1
+	}
+	echo 2
+}
+
+func g() {
+	os.Exit(1)
+	echo 3
+}
+`),
+	}, FeatAST)
+	f, err := proj.AST("a.gop")
+	if err != nil {
+		t.Fatal("AST:", err)
+	}
+	live := func(body *ast.BlockStmt) (n int) {
+		g, err := proj.CFG("a.gop", body)
+		if err != nil {
+			t.Fatal("CFG:", err)
+		}
+		if g2, _ := proj.CFG("a.gop", body); g2 != g {
+			t.Fatal("CFG not cached")
+		}
+		for _, b := range g.Blocks {
+			if b.Live {
+				n += len(b.Nodes)
+			}
+		}
+		return
+	}
+	if n := live(f.Decls[0].(*ast.FuncDecl).Body); n != 1 {
+		t.Fatal("CFG live nodes of f:", n)
+	}
+	if n := live(f.Decls[1].(*ast.FuncDecl).Body); n != 1 {
+		t.Fatal("CFG live nodes of g:", n)
+	}
+
+	proj.PutFile("a.gop", file("echo 100"))
+	f2, _ := proj.AST("a.gop")
+	// The implicit return at the end of the ShadowEntry is made explicit.
+	if g, _ := proj.CFG("a.gop", f2.ShadowEntry.Body); g == nil || len(g.Blocks[0].Nodes) != 2 {
+		t.Fatal("CFG of ShadowEntry:", g)
+	}
+	if _, err := proj.CFG("b.gop", nil); err == nil {
+		t.Fatal("CFG of a missing file")
+	}
+}
+
+func TestASTPackageConcurrent(t *testing.T) {
+	defer func(n int) { parseConcurrency = n }(parseConcurrency)
+	parseConcurrency = 3
+
+	files := make(map[string]File)
+	for i := range 20 {
+		text := fmt.Sprintf("echo %d", i)
+		if i%4 == 0 {
+			text = "echo ("
+		}
+		files[fmt.Sprintf("f%02d.spx", i)] = file(text)
+	}
+	var want string
+	for range 5 {
+		proj := NewProject(nil, files, FeatAST)
+		pkg, err := proj.ASTPackage()
+		if len(pkg.Files) != len(files) {
+			t.Fatal("Files:", len(pkg.Files))
+		}
+		var errs scanner.ErrorList
+		if !errors.As(err, &errs) || len(errs) == 0 {
+			t.Fatal("ASTPackage:", err)
+		}
+		if !slices.IsSortedFunc(errs, func(a, b *scanner.Error) int {
+			return strings.Compare(a.Pos.Filename, b.Pos.Filename)
+		}) {
+			t.Fatal("errors not in path order:", errs)
+		}
+		if want == "" {
+			want = err.Error()
+		} else if err.Error() != want {
+			t.Fatal("errors not deterministic:", err, want)
+		}
+	}
+}
+
+func TestDeclCache(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"main.spx": file("// Foo does something.\nfunc Foo(n int) {\n\techo n\n}\n\nonStart => {\n\tFoo 1\n}\n"),
+		"bar.spx":  file("echo 200"),
+	}, FeatAll)
+	doc, err := proj.PkgDoc()
+	if err != nil {
+		t.Fatal("PkgDoc:", err)
+	}
+	if game := doc.Types["Game"]; game == nil || game.Methods["Foo"] != "Foo does something.\n" {
+		t.Fatal("doc.Types:", doc.Types)
+	}
+	_, info, _, _ := proj.TypeInfo()
+
+	// Edits confined to function bodies keep the cache, but not TypeInfo.
+	proj.PutFile("main.spx", file("// Foo does something.\nfunc Foo(n int) {\n\t// Print n.\n\techo n, n\n}\n\nonStart => {\n\tFoo 2\n}\n"))
+	if doc2, err := proj.PkgDoc(); err != nil || doc2 != doc {
+		t.Fatal("PkgDoc after editing bodies:", doc2, err)
+	}
+	if _, info2, _, _ := proj.TypeInfo(); info2 == info {
+		t.Fatal("TypeInfo not rebuilt")
+	}
+	if snap := proj.Snapshot(); func() bool { doc2, _ := snap.PkgDoc(); return doc2 != doc }() {
+		t.Fatal("Snapshot PkgDoc not reused")
+	}
+
+	for _, tt := range []struct {
+		name string
+		path string
+		text string
+	}{
+		{"Doc", "main.spx", "// Foo does nothing.\nfunc Foo(n int) {\n\techo n\n}\n"},
+		{"Signature", "main.spx", "// Foo does nothing.\nfunc Foo(n string) {\n\techo n\n}\n"},
+		{"NewFile", "baz.spx", "var (\n\tx int\n)\n"},
+	} {
+		proj.PutFile(tt.path, file(tt.text))
+		doc2, _ := proj.PkgDoc()
+		if doc2 == doc {
+			t.Fatal(tt.name + ": PkgDoc not rebuilt")
+		}
+		doc = doc2
+	}
+	proj.DeleteFile("baz.spx")
+	if doc2, _ := proj.PkgDoc(); doc2 == doc {
+		t.Fatal("DeleteFile: PkgDoc not rebuilt")
+	}
+
+	// Parse errors in function bodies are not confined to them.
+	proj.PutFile("main.spx", file("// Foo does nothing.\nfunc Foo(n string) {\n\techo (\n}\n"))
+	if _, err := proj.PkgDoc(); err == nil {
+		t.Fatal("PkgDoc: no error")
+	}
+}
+
+func TestSourceCache(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"main.spx":          file("echo 100"),
+		"bar.spx":           file("echo 200"),
+		"assets/index.json": file("{}"),
+	}, FeatAll)
+	var builds atomic.Int32
+	proj.InitSourceCache("files", func(proj *Project) (any, error) {
+		builds.Add(1)
+		_, files, err := proj.ASTFiles()
+		return len(files), err
+	})
+	if n, err := proj.Cache("files"); err != nil || n != 2 {
+		t.Fatal("Cache:", n, err)
+	}
+	_, info, _, _ := proj.TypeInfo()
+
+	// Changes of files other than source files keep the cache, in the
+	// project and its snapshots.
+	proj.PutFile("assets/index.json", file(`{"zorder":[]}`))
+	proj.PutFile("assets/sounds/a/index.json", file("{}"))
+	if n, err := proj.Cache("files"); err != nil || n != 2 {
+		t.Fatal("Cache after editing assets:", n, err)
+	}
+	if _, info2, _, _ := proj.TypeInfo(); info2 != info {
+		t.Fatal("TypeInfo rebuilt after editing assets")
+	}
+	snap := proj.Snapshot()
+	snap.DeleteFile("assets/sounds/a/index.json")
+	if n, _ := snap.Cache("files"); n != 2 {
+		t.Fatal("Snapshot Cache:", n)
+	}
+	if _, info2, _, _ := snap.TypeInfo(); info2 != info {
+		t.Fatal("Snapshot TypeInfo rebuilt after deleting assets")
+	}
+	if n := builds.Load(); n != 1 {
+		t.Fatal("builds:", n)
+	}
+
+	// Changes of source and module files invalidate it.
+	for _, tt := range []struct {
+		name string
+		path string
+		text string
+	}{
+		{"Edit", "bar.spx", "echo 300"},
+		{"NewFile", "baz.spx", "echo 400"},
+		{"GoFile", "vendor/example.com/foo/foo.go", "package foo\n"},
+		{"ModFile", "go.mod", "module example.com/bar\n"},
+	} {
+		before := builds.Load()
+		proj.PutFile(tt.path, file(tt.text))
+		proj.Cache("files")
+		if builds.Load() != before+1 {
+			t.Fatal(tt.name+": builds:", builds.Load())
+		}
+		_, info2, _, _ := proj.TypeInfo()
+		if info2 == info {
+			t.Fatal(tt.name + ": TypeInfo not rebuilt")
+		}
+		info = info2
+	}
+	before := builds.Load()
+	proj.DeleteFile("baz.spx")
+	if n, _ := proj.Cache("files"); n != 2 || builds.Load() != before+1 {
+		t.Fatal("DeleteFile:", n, builds.Load())
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := OpenDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal("OpenDiskCache:", err)
+	}
+	entries := func() []string {
+		paths, _ := filepath.Glob(filepath.Join(c.dir, "*", "*"))
+		return paths
+	}
+	newProject := func() *Project {
+		proj := NewProject(nil, map[string]File{
+			"main.gop": file("println 100\n"),
+		}, FeatAll)
+		proj.SetDiskCache(c)
+		return proj
+	}
+	code, err := newProject().GoCode()
+	if err != nil {
+		t.Fatal("GoCode:", err)
+	}
+	paths := entries()
+	if len(paths) != 1 {
+		t.Fatal("entries:", paths)
+	}
+
+	// A new project with the same files, e.g., after a restart, loads the
+	// persisted code instead of compiling it again.
+	tampered := append([]byte("// from disk\n"), code...)
+	if err := os.WriteFile(paths[0], tampered, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	proj := newProject()
+	if code, err := proj.Snapshot().GoCode(); !bytes.Equal(code, tampered) || err != nil {
+		t.Fatalf("GoCode from disk: %v\n%s", err, code)
+	}
+
+	// Changes of source files are not served from disk, nor is code compiled
+	// despite errors persisted.
+	proj.PutFile("main.gop", file("println 200\n"))
+	if code, _ := proj.GoCode(); bytes.HasPrefix(code, []byte("// from disk")) {
+		t.Fatal("GoCode after editing:", string(code))
+	}
+	proj.PutFile("main.gop", file("println undefined\n"))
+	if _, err := proj.GoCode(); err == nil {
+		t.Fatal("GoCode: no error")
+	}
+	if n := len(entries()); n != 2 {
+		t.Fatal("entries after editing:", n)
+	}
+}
+
+func TestDiskCacheGC(t *testing.T) {
+	c, err := OpenDiskCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal("OpenDiskCache:", err)
+	}
+	data := bytes.Repeat([]byte("x"), 40)
+	age := func(key string, d time.Duration) {
+		mtime := time.Now().Add(-d)
+		if err := os.Chtimes(c.path(key), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.put("aa", data)
+	age("aa", 3*time.Hour)
+	c.put("bb", data)
+	age("bb", 2*time.Hour)
+	if _, ok := c.get("aa"); !ok {
+		t.Fatal("get aa: not found")
+	}
+
+	// Going over the limit evicts the least recently used entry.
+	c.put("cc", data)
+	for key, want := range map[string]bool{"aa": true, "bb": false, "cc": true} {
+		if _, ok := c.get(key); ok != want {
+			t.Fatalf("get %s: %v, want %v", key, ok, want)
+		}
+	}
+
+	// So does opening it with a smaller limit.
+	age("aa", time.Hour)
+	c, err = OpenDiskCache(c.dir, 50)
+	if err != nil {
+		t.Fatal("OpenDiskCache:", err)
+	}
+	if _, ok := c.get("aa"); ok {
+		t.Fatal("get aa: found after reopening")
+	}
+	if _, ok := c.get("cc"); !ok {
+		t.Fatal("get cc: not found after reopening")
+	}
+	if _, err := OpenDiskCache(c.dir, -1); err == nil {
+		t.Fatal("OpenDiskCache: no error for negative size")
+	}
+}
+
+func TestCustomCache(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"main.spx": file("echo 100\necho 200"),
+		"bar.spx":  file("echo 300"),
+	}, FeatAST)
+	var builds atomic.Int32
+	proj.InitFileCache("lines", func(proj *Project, path string, file File) (any, error) {
+		builds.Add(1)
+		return strings.Count(string(file.Content), "\n") + 1, nil
+	})
+	proj.InitCache("files", func(proj *Project) (any, error) {
+		_, files, err := proj.ASTFiles()
+		return len(files), err
+	})
+	if _, err := proj.Cache("lines"); !errors.Is(err, ErrUnknownKind) {
+		t.Fatal("Cache:", err)
+	}
+	if n, err := proj.FileCache("lines", "main.spx"); err != nil || n != 2 {
+		t.Fatal("FileCache:", n, err)
+	}
+	if n, err := proj.Cache("files"); err != nil || n != 2 {
+		t.Fatal("Cache:", n, err)
+	}
+
+	// Snapshots share the caches of unchanged files, and registering caches
+	// on them does not affect the original project.
+	snap := proj.Snapshot()
+	snap.FileCache("lines", "main.spx")
+	snap.PutFile("bar.spx", file("echo 300\necho 400\necho 500"))
+	if n, _ := snap.FileCache("lines", "bar.spx"); n != 3 {
+		t.Fatal("Snapshot FileCache:", n)
+	}
+	if builds.Load() != 2 {
+		t.Fatal("builds:", builds.Load())
+	}
+	snap.InitFileCache("chars", func(proj *Project, path string, file File) (any, error) {
+		return len(file.Content), nil
+	})
+	if _, err := proj.FileCache("chars", "main.spx"); !errors.Is(err, ErrUnknownKind) {
+		t.Fatal("FileCache:", err)
+	}
+
+	// Initializing a cache again drops the existing caches of its kind.
+	proj.InitFileCache("lines", func(proj *Project, path string, file File) (any, error) {
+		return -1, nil
+	})
+	if n, _ := proj.FileCache("lines", "main.spx"); n != -1 {
+		t.Fatal("FileCache after InitFileCache:", n)
+	}
+	if n, _ := snap.FileCache("lines", "main.spx"); n != 2 {
+		t.Fatal("Snapshot FileCache after InitFileCache:", n)
+	}
+	proj.InitCache("files", func(proj *Project) (any, error) {
+		return -1, nil
+	})
+	if n, _ := proj.Cache("files"); n != -1 {
+		t.Fatal("Cache after InitCache:", n)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"gop.mod":        file("gop 1.2\n\nmodule example.com/foo\n"),
+		"main.gop":       file("package main\n\nimport \"example.com/foo/util\"\n\nvar x = util.Add(1, 2)\n"),
+		"util/util.gop":  file("package util\n\n// Add adds.\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"),
+		"cycle/a/a.gop":  file("package a\n\nimport \"example.com/foo/cycle/b\"\n\nvar A = b.B\n"),
+		"cycle/b/b.gop":  file("package b\n\nimport \"example.com/foo/cycle/a\"\n\nvar B = a.A\n"),
+		"assets/a.json":  file("{}"),
+		"util/README.md": file(""),
+	}, FeatAll)
+	if mod := proj.ModulePath(); mod != "example.com/foo" {
+		t.Fatal("ModulePath:", mod)
+	}
+	if dirs := proj.PackageDirs(); !slices.Equal(dirs, []string{".", "cycle/a", "cycle/b", "util"}) {
+		t.Fatal("PackageDirs:", dirs)
+	}
+
+	// The root package only contains the files in the root directory, and
+	// imports the other packages from source.
+	pkg, err := proj.ASTPackage()
+	if err != nil || len(pkg.Files) != 1 {
+		t.Fatal("ASTPackage:", pkg.Files, err)
+	}
+	_, info, err, _ := proj.TypeInfo()
+	if err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+	var add types.Object
+	for ident, obj := range info.Uses {
+		if ident.Name == "Add" {
+			add = obj
+		}
+	}
+	if add == nil || add.Pkg().Path() != "example.com/foo/util" {
+		t.Fatal("Uses:", add)
+	}
+	if pos := proj.Fset.Position(add.Pos()); pos.Filename != "util/util.gop" || pos.Line != 4 {
+		t.Fatal("Add position:", pos)
+	}
+
+	util, err := proj.Package("util")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+	if util.PackageDir() != "util" || util.Path != "example.com/foo/util" {
+		t.Fatal("Package:", util.PackageDir(), util.Path)
+	}
+	if utilPkg, _, _, _ := util.TypeInfo(); utilPkg != add.Pkg() {
+		t.Fatal("Package TypeInfo not shared with the importer")
+	}
+	if _, err := proj.Package("assets"); err == nil {
+		t.Fatal("Package: no error")
+	}
+
+	// The import closing a cycle fails.
+	a, err := proj.Package("cycle/a")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+	if _, _, err, _ := a.TypeInfo(); err == nil {
+		t.Fatal("TypeInfo: no error")
+	}
+	b, err := proj.Package("cycle/b")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+	if _, _, err, _ := b.TypeInfo(); err == nil || !strings.Contains(err.Error(), "import cycle not allowed") {
+		t.Fatal("TypeInfo:", err)
+	}
+
+	// Packages are dropped once any file changes.
+	proj.PutFile("util/util.gop", file("package util\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"))
+	if util2, _ := proj.Package("util"); util2 == util {
+		t.Fatal("Package not dropped")
+	}
+}
+
+func TestPackagesWithoutModule(t *testing.T) {
+	// Without a module path, Go+ source files in subdirectories belong to the
+	// root package.
+	proj := NewProject(nil, map[string]File{
+		"main.gop":      file("package main\n\nvar x = add(1, 2)\n"),
+		"util/util.gop": file("package main\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n"),
+	}, FeatAll)
+	pkg, err := proj.ASTPackage()
+	if err != nil || len(pkg.Files) != 2 {
+		t.Fatal("ASTPackage:", pkg.Files, err)
+	}
+	if _, _, err, _ := proj.TypeInfo(); err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+}
+
+func TestPackagesConcurrentTypeInfo(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"gop.mod":       file("gop 1.2\n\nmodule example.com/foo\n"),
+		"main.gop":      file("package main\n\nimport \"example.com/foo/util\"\n\nvar x = util.Add(1, 2)\n"),
+		"util/util.gop": file("package util\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"),
+		"cycle/a/a.gop": file("package a\n\nimport \"example.com/foo/cycle/b\"\n\nvar A = b.B\n"),
+		"cycle/b/b.gop": file("package b\n\nimport \"example.com/foo/cycle/a\"\n\nvar B = a.A\n"),
+	}, FeatAll)
+	util, err := proj.Package("util")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+	a, err := proj.Package("cycle/a")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+	b, err := proj.Package("cycle/b")
+	if err != nil {
+		t.Fatal("Package:", err)
+	}
+
+	// Importing a package shares the build of its type information in
+	// progress, and packages in a cycle do not wait for each other.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errs := make(chan error, 4)
+	for _, pkg := range []*Project{proj, util, a, b} {
+		go func() {
+			_, _, err, _ := pkg.TypeInfoContext(ctx)
+			errs <- err
+		}()
+	}
+	for range 4 {
+		if err := <-errs; errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("TypeInfoContext:", err)
+		}
+	}
+
+	_, info, err, _ := proj.TypeInfo()
+	if err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+	utilPkg, _, _, _ := util.TypeInfo()
+	for ident, obj := range info.Uses {
+		if ident.Name == "Add" && obj.Pkg() != utilPkg {
+			t.Fatal("Package TypeInfo not shared with the importer")
+		}
+	}
+}
+
+func TestVendoredPackages(t *testing.T) {
+	proj := NewProject(nil, map[string]File{
+		"main.gop":                               file("package main\n\nimport (\n\t\"example.com/gopkg\"\n\t\"example.com/gokg\"\n)\n\nvar x = gopkg.Add(1, gokg.Two)\n"),
+		"third_party/example.com/gopkg/a.gop":    file("package gopkg\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"),
+		"third_party/example.com/gokg/b.go":      file("package gokg\n\nconst Two = 2\n"),
+		"third_party/example.com/gokg/b_test.go": file("package gokg\n\nimport \"testing\"\n"),
+	}, FeatAll)
+	if _, err := proj.Package("third_party/example.com/gokg"); err != nil {
+		t.Fatal("Package:", err)
+	}
+	if _, ok := proj.ResolveImport("example.com/gokg"); ok {
+		t.Fatal("ResolveImport: resolved without vendor directory")
+	}
+
+	proj.SetVendorDir("third_party/")
+	if dir := proj.VendorDir(); dir != "third_party" {
+		t.Fatal("VendorDir:", dir)
+	}
+	if dir, ok := proj.ResolveImport("example.com/gokg"); !ok || dir != "third_party/example.com/gokg" {
+		t.Fatal("ResolveImport:", dir, ok)
+	}
+	if _, ok := proj.ResolveImport("example.com/missing"); ok {
+		t.Fatal("ResolveImport: resolved missing package")
+	}
+	_, info, err, _ := proj.TypeInfo()
+	if err != nil {
+		t.Fatal("TypeInfo:", err)
+	}
+	paths := make(map[string]string)
+	for ident, obj := range info.Uses {
+		if obj.Pkg() != nil {
+			paths[ident.Name] = obj.Pkg().Path()
+		}
+	}
+	if paths["Add"] != "example.com/gopkg" || paths["Two"] != "example.com/gokg" {
+		t.Fatal("Uses:", paths)
+	}
+
+	// Snapshots inherit the vendor directory.
+	if dir := proj.Snapshot().VendorDir(); dir != "third_party" {
+		t.Fatal("Snapshot VendorDir:", dir)
+	}
+}
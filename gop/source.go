@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gop
+
+import (
+	"crypto/sha256"
+	goast "go/ast"
+	"slices"
+
+	"github.com/goplus/gop/ast"
+)
+
+// -----------------------------------------------------------------------------
+
+// sourceKey identifies the inputs of a project level cache initialized by
+// InitSourceCache, see buildSourceKey.
+type sourceKey struct {
+	sig     declSig       // of the source and module files of the project
+	files   []*ast.File   // ASTs of the Go+ source files of the package
+	goFiles []*goast.File // ASTs of the Go source files of the package
+}
+
+// buildSourceKey returns the key of the inputs of a project level cache
+// initialized by InitSourceCache, which are the contents of the Go+ and Go
+// source files and module files of the whole project, as packages may import
+// each other, and the vendor directory. The ASTs of the package are part of
+// the key too, as the cache may refer to their nodes, e.g., type info, so that
+// ASTs parsed again after being evicted invalidate it.
+func (p *Project) buildSourceKey() sourceKey {
+	var paths []string
+	p.RangeFiles(func(path string) bool {
+		if isGopFile(path) || isGoFile(path) || slices.Contains(modFiles, path) {
+			paths = append(paths, path)
+		}
+		return true
+	})
+	slices.Sort(paths)
+
+	h := sha256.New()
+	h.Write([]byte(p.vendorDir))
+	h.Write([]byte{0})
+	for _, path := range paths {
+		file, ok := p.File(path)
+		if !ok {
+			continue
+		}
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(file.Content)
+		h.Write([]byte{0})
+	}
+
+	_, files, _ := p.ASTFiles()
+	goFiles, _ := p.goASTFiles()
+	return sourceKey{sig: declSig(h.Sum(nil)), files: files, goFiles: goFiles}
+}
+
+// equal reports whether k and other identify the same inputs.
+func (k sourceKey) equal(other sourceKey) bool {
+	return k.sig == other.sig && slices.Equal(k.files, other.files) && slices.Equal(k.goFiles, other.goFiles)
+}
+
+// -----------------------------------------------------------------------------
+
+// sourceCache is a project level cache initialized by InitSourceCache.
+type sourceCache struct {
+	key  sourceKey // of the project the cache was built for
+	data any       // dataOrErr
+}
+
+// InitSourceCache initializes a project level cache that only depends on the
+// Go+ and Go source files and module files, e.g., the type info. Unlike other
+// project level caches, it is kept as long as none of them change, e.g., for
+// edits of spx resources, and snapshots share it. It requires FeatAST.
+//
+// The fields set by the caller, e.g., Mod and Importer, are assumed to be the
+// same for all snapshots. The cache keeps the ASTs of the package it was built
+// from, which are not accounted by the memory budget, see SetMemoryBudget.
+func (p *Project) InitSourceCache(kind string, builder Builder) {
+	p.initCache(kind, builder, sourceScope)
+}
+
+// buildCache builds the project level cache of the given kind with builder,
+// or reuses the one built for unchanged inputs if it is initialized by
+// InitSourceCache.
+func (p *Project) buildCache(kind string, builder Builder) (any, error) {
+	if p.scopes[kind] != sourceScope {
+		return builder(p)
+	}
+
+	// Packages are dropped once any file changes, see Package, so their
+	// caches are kept by the root project.
+	root := p
+	if p.root != nil {
+		root = p.root
+	}
+	cacheKey := fileKey{kind, p.PackageDir()}
+	key := p.buildSourceKey()
+	if v, ok := root.srcCaches.Load(cacheKey); ok && v.(*sourceCache).key.equal(key) {
+		return decodeDataOrErr(v.(*sourceCache).data)
+	}
+	data, err := p.buildOrLoad(kind, key.sig, builder)
+	root.srcCaches.Store(cacheKey, &sourceCache{key: key, data: encodeDataOrErr(data, err)})
+	return data, err
+}
+
+// -----------------------------------------------------------------------------
@@ -6,8 +6,17 @@ export interface Spxls {
    * Handles incoming LSP messages from the client.
    *
    * @param message - The message to process. Any required response will be sent via the messageReplier callback.
+   *                  Responses answer requests sent by the server via the messageReplier callback.
    */
-  handleMessage(message: RequestMessage | NotificationMessage): Error | null
+  handleMessage(message: RequestMessage | ResponseMessage | NotificationMessage): Error | null
+
+  /**
+   * Reloads the given files or directories from the filesProvider, even if their modification times are unchanged, and
+   * republishes diagnostics once changes settle. It is equivalent to a `workspace/didChangeWatchedFiles` notification.
+   *
+   * @param paths - The paths of the files or directories relative to the workspace root.
+   */
+  invalidateFiles(paths: string[]): Error | null
 }
 
 
@@ -20,9 +29,10 @@ declare global {
    *                       access the file system.
    *
    * @param messageReplier - Function called when the language server needs to reply to the client. The client should
-   *                        handle these messages according to the LSP specification.
+   *                        handle these messages according to the LSP specification, and respond to requests, e.g.,
+   *                        `window/workDoneProgress/create`, via handleMessage.
    */
-  function NewSpxls(filesProvider: () => Files, messageReplier: (message: ResponseMessage | NotificationMessage) => void): Spxls | Error
+  function NewSpxls(filesProvider: () => Files, messageReplier: (message: RequestMessage | ResponseMessage | NotificationMessage) => void): Spxls | Error
 }
 
 /**
@@ -131,3 +141,29 @@ export type File = {
   content: Uint8Array
   modTime: number // unix timestamp in milliseconds
 }
+
+/**
+ * A message posted to the language server running in a Web Worker, see worker.ts.
+ *
+ * - `init`: Loads the language server. `wasmExecURL` is the URL of the `wasm_exec.js` of the Go version used to build
+ *   `wasmURL`. It must be the first message.
+ * - `files`: Replaces the workspace files served to the language server. If `paths` is given, the files or
+ *   directories with these paths are invalidated, see {@link Spxls.invalidateFiles}.
+ * - `message`: An LSP message for the language server, see {@link Spxls.handleMessage}.
+ */
+export type WorkerInboundMessage =
+  | { type: 'init', wasmURL: string, wasmExecURL: string, files: Files }
+  | { type: 'files', files: Files, paths?: string[] }
+  | { type: 'message', message: RequestMessage | ResponseMessage | NotificationMessage }
+
+/**
+ * A message posted by the language server running in a Web Worker, see worker.ts.
+ *
+ * - `ready`: The language server is loaded, in response to `init`.
+ * - `error`: Handling an inbound message failed.
+ * - `message`: An LSP message from the language server, as passed to the messageReplier of {@link NewSpxls}.
+ */
+export type WorkerOutboundMessage =
+  | { type: 'ready' }
+  | { type: 'error', error: string }
+  | { type: 'message', message: RequestMessage | ResponseMessage | NotificationMessage }
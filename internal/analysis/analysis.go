@@ -2,6 +2,13 @@ package analysis
 
 import (
 	"github.com/goplus/goxlsw/internal/analysis/passes/appends"
+	"github.com/goplus/goxlsw/internal/analysis/passes/copylock"
+	"github.com/goplus/goxlsw/internal/analysis/passes/loopclosure"
+	"github.com/goplus/goxlsw/internal/analysis/passes/nilness"
+	"github.com/goplus/goxlsw/internal/analysis/passes/printf"
+	"github.com/goplus/goxlsw/internal/analysis/passes/shadow"
+	"github.com/goplus/goxlsw/internal/analysis/passes/unreachable"
+	"github.com/goplus/goxlsw/internal/analysis/passes/unusedresult"
 	"github.com/goplus/goxlsw/internal/analysis/protocol"
 )
 
@@ -77,6 +84,18 @@ func init() {
 	analyzers := []*Analyzer{
 		// The traditional vet suite:
 		{analyzer: appends.Analyzer},
+		{analyzer: copylock.Analyzer},
+		{analyzer: printf.Analyzer},
+		{analyzer: unreachable.Analyzer, severity: protocol.SeverityHint, tags: []protocol.DiagnosticTag{protocol.Unnecessary}},
+		{analyzer: unusedresult.Analyzer},
+
+		// Loop variables are per-iteration since Go 1.22, so the loopclosure
+		// analyzer only helps code built with older toolchains.
+		{analyzer: loopclosure.Analyzer, nonDefault: true},
+
+		// Non-vet analyzers:
+		{analyzer: nilness.Analyzer},
+		{analyzer: shadow.Analyzer, nonDefault: true}, // very noisy
 	}
 	for _, analyzer := range analyzers {
 		DefaultAnalyzers[analyzer.analyzer.Name] = analyzer
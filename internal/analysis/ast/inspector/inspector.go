@@ -49,6 +49,26 @@ func New(files []*ast.File) *Inspector {
 	return &Inspector{traverse(files)}
 }
 
+// Merge returns an Inspector for the syntax trees of all the given
+// inspectors, in order, without traversing them again. It is how
+// inspectors built once per file, e.g., cached by a project, are
+// combined for a whole package.
+func Merge(ins ...*Inspector) *Inspector {
+	var n int
+	for _, in := range ins {
+		n += len(in.events)
+	}
+	events := make([]event, 0, n)
+	for _, in := range ins {
+		offset := len(events)
+		for _, ev := range in.events {
+			ev.index += offset
+			events = append(events, ev)
+		}
+	}
+	return &Inspector{events}
+}
+
 // An event represents a push or a pop
 // of an ast.Node during a traversal.
 type event struct {
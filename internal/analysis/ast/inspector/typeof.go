@@ -73,6 +73,11 @@ const (
 	nTypeSwitchStmt
 	nUnaryExpr
 	nValueSpec
+
+	// Go+ specific nodes.
+	nForPhraseStmt
+	nLambdaExpr
+	nLambdaExpr2
 )
 
 // typeOf returns a distinct single-bit value that represents the type of n.
@@ -213,6 +218,12 @@ func typeOf(n ast.Node) uint64 {
 		return 1 << nUnaryExpr
 	case *ast.ValueSpec:
 		return 1 << nValueSpec
+	case *ast.ForPhraseStmt:
+		return 1 << nForPhraseStmt
+	case *ast.LambdaExpr:
+		return 1 << nLambdaExpr
+	case *ast.LambdaExpr2:
+		return 1 << nLambdaExpr2
 	}
 	return 0
 }
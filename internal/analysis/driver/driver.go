@@ -0,0 +1,352 @@
+// Package driver runs analyzers over Go+ packages.
+//
+// Unlike the drivers of golang.org/x/tools, it analyzes a single package at a
+// time, which is all a Go+ project consists of. Facts are therefore shared
+// between all files of the package, but not across packages.
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/types"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/analysis/passes/ctrlflow"
+	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+	qerrors "github.com/qiniu/x/errors"
+)
+
+// Package is a type-checked Go+ package to be analyzed.
+type Package struct {
+	Fset       *token.FileSet
+	Files      []*ast.File
+	Types      *types.Package
+	TypesInfo  *typesutil.Info
+	TypeErrors []types.Error
+
+	// Inspector is the inspector of Files, if already built, e.g., merged
+	// from the inspectors the project caches for each file. If set, it is
+	// the result of [inspect.Analyzer] instead of traversing Files again.
+	Inspector *inspector.Inspector
+
+	// CFG returns the control-flow graph of a function body in Files, if set,
+	// e.g., looked up in the graphs the project caches for each file. It then
+	// backs the result of [ctrlflow.Analyzer].
+	CFG func(body *ast.BlockStmt) *cfg.CFG
+}
+
+// Diagnostic is a diagnostic reported by an analyzer.
+type Diagnostic struct {
+	protocol.Diagnostic
+
+	// Analyzer is the analyzer that reported the diagnostic.
+	Analyzer *protocol.Analyzer
+}
+
+// Result is the result of analyzing a package.
+type Result struct {
+	// Fset is the file set the positions of Diagnostics are relative to.
+	Fset *token.FileSet
+
+	// Diagnostics are the diagnostics reported by the requested analyzers,
+	// in the order the analyzers were requested.
+	Diagnostics []Diagnostic
+
+	// Errors records the analyzers that failed, including those that were
+	// skipped because a prerequisite failed.
+	Errors map[*protocol.Analyzer]error
+}
+
+// Analyze runs the given analyzers and their prerequisites on pkg.
+//
+// Only diagnostics of the given analyzers are reported, except for those
+// suppressed by nolint directives such as `//xgo:nolint appends` (see
+// [filterNolint]). Analyzers that do not set RunDespiteErrors are skipped if
+// pkg has type errors. Analyzers that did not start before ctx is done fail
+// with the error of ctx.
+func Analyze(ctx context.Context, pkg *Package, analyzers []*protocol.Analyzer) *Result {
+	a := &analysis{
+		ctx:         ctx,
+		pkg:         pkg,
+		results:     make(map[*protocol.Analyzer]any),
+		errors:      make(map[*protocol.Analyzer]error),
+		done:        make(map[*protocol.Analyzer]bool),
+		diagnostics: make(map[*protocol.Analyzer][]Diagnostic),
+	}
+	result := &Result{
+		Fset:   pkg.Fset,
+		Errors: make(map[*protocol.Analyzer]error),
+	}
+	for _, an := range analyzers {
+		a.run(an)
+		if err := a.errors[an]; err != nil {
+			result.Errors[an] = err
+		}
+		result.Diagnostics = append(result.Diagnostics, a.diagnostics[an]...)
+	}
+	result.Diagnostics = filterNolint(pkg, result.Diagnostics)
+	return result
+}
+
+// ErrTypeErrors is the error of an analyzer that was skipped because the
+// package has type errors.
+var ErrTypeErrors = errors.New("package has type errors")
+
+// errPrerequisite is the error of an analyzer whose prerequisite failed.
+var errPrerequisite = errors.New("prerequisite failed")
+
+// analysis is the state of a single [Analyze] call.
+type analysis struct {
+	ctx         context.Context
+	pkg         *Package
+	results     map[*protocol.Analyzer]any
+	errors      map[*protocol.Analyzer]error
+	done        map[*protocol.Analyzer]bool
+	diagnostics map[*protocol.Analyzer][]Diagnostic
+}
+
+// run runs the analyzer after its prerequisites, unless it already ran.
+func (a *analysis) run(an *protocol.Analyzer) {
+	if a.done[an] {
+		return
+	}
+	a.done[an] = true
+	if err := a.ctx.Err(); err != nil {
+		a.errors[an] = err
+		return
+	}
+
+	resultOf := make(map[*protocol.Analyzer]any, len(an.Requires))
+	for _, req := range an.Requires {
+		a.run(req)
+		if err := a.errors[req]; err != nil {
+			a.errors[an] = fmt.Errorf("%w: %s: %v", errPrerequisite, req.Name, err)
+			return
+		}
+		resultOf[req] = a.results[req]
+	}
+	if an == inspect.Analyzer && a.pkg.Inspector != nil {
+		a.results[an] = a.pkg.Inspector
+		return
+	}
+	if an == ctrlflow.Analyzer && a.pkg.CFG != nil {
+		a.results[an] = ctrlflow.New(a.pkg.CFG)
+		return
+	}
+	if len(a.pkg.TypeErrors) > 0 && !an.RunDespiteErrors {
+		a.errors[an] = ErrTypeErrors
+		return
+	}
+
+	facts := newFactStore(an, a.pkg.Types)
+	pass := &protocol.Pass{
+		Analyzer:   an,
+		Fset:       a.pkg.Fset,
+		Files:      a.pkg.Files,
+		Pkg:        a.pkg.Types,
+		TypesInfo:  a.pkg.TypesInfo,
+		TypeErrors: a.pkg.TypeErrors,
+		Report: func(d protocol.Diagnostic) {
+			a.diagnostics[an] = append(a.diagnostics[an], Diagnostic{
+				Diagnostic: d,
+				Analyzer:   an,
+			})
+		},
+		ResultOf:          resultOf,
+		ImportObjectFact:  facts.importObjectFact,
+		ImportPackageFact: facts.importPackageFact,
+		ExportObjectFact:  facts.exportObjectFact,
+		ExportPackageFact: facts.exportPackageFact,
+		AllObjectFacts:    facts.allObjectFacts,
+		AllPackageFacts:   facts.allPackageFacts,
+	}
+	result, err := runPass(pass)
+	if err != nil {
+		a.errors[an] = err
+		return
+	}
+	a.results[an] = result
+}
+
+// PanicError is the error of an analyzer that panicked.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// runPass runs the analyzer of the given pass, turning panics into
+// [PanicError]s so that a faulty analyzer cannot bring the server down.
+func runPass(pass *protocol.Pass) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return pass.Analyzer.Run(pass)
+}
+
+// maxCacheEntries is the maximum number of results kept by a [Driver].
+const maxCacheEntries = 8
+
+// Driver runs analyzers over Go+ projects. It caches analysis results keyed
+// by the contents of the analyzed files, so that unchanged snapshots are not
+// analyzed again.
+//
+// It is safe for concurrent use.
+type Driver struct {
+	mu    sync.Mutex
+	cache map[cacheKey]*Result
+	keys  []cacheKey // in insertion order, for eviction
+}
+
+// cacheKey identifies an analysis of a set of file contents by a set of
+// analyzers.
+type cacheKey [sha256.Size]byte
+
+// New creates a new [Driver].
+func New() *Driver {
+	return &Driver{cache: make(map[cacheKey]*Result)}
+}
+
+// Run runs the given analyzers on the main package of proj. It returns a
+// cached result if the same analyzers already ran on the same file contents.
+//
+// It returns the error of ctx if ctx is done before all analyzers ran, in
+// which case the partial result is not cached.
+func (d *Driver) Run(ctx context.Context, proj *gop.Project, analyzers []*protocol.Analyzer) (*Result, error) {
+	astPkg, err := proj.ASTPackage()
+	if err != nil && astPkg == nil {
+		return nil, fmt.Errorf("failed to get AST package: %w", err)
+	}
+	key := computeCacheKey(proj, astPkg, analyzers)
+
+	d.mu.Lock()
+	result, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok {
+		return result, nil
+	}
+
+	pkg, err := newPackage(proj, astPkg)
+	if err != nil {
+		return nil, err
+	}
+	result = Analyze(ctx, pkg, analyzers)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.cache[key]; !ok {
+		if len(d.keys) >= maxCacheEntries {
+			delete(d.cache, d.keys[0])
+			d.keys = d.keys[1:]
+		}
+		d.cache[key] = result
+		d.keys = append(d.keys, key)
+	}
+	return result, nil
+}
+
+// newPackage creates a [Package] from the main package of proj.
+func newPackage(proj *gop.Project, astPkg *ast.Package) (*Package, error) {
+	typesPkg, typesInfo, err, _ := proj.TypeInfo()
+	if typesPkg == nil || typesInfo == nil {
+		return nil, fmt.Errorf("failed to get type info: %w", err)
+	}
+	pkg := &Package{
+		Fset:      proj.Fset,
+		Types:     typesPkg,
+		TypesInfo: typesInfo,
+	}
+	ins := make([]*inspector.Inspector, 0, len(astPkg.Files))
+	shadowEntryFiles := make(map[*ast.BlockStmt]string) // whose bodies may have no positions
+	for _, filename := range sortedFilenames(astPkg) {
+		f := astPkg.Files[filename]
+		pkg.Files = append(pkg.Files, f)
+		if in, _ := proj.Inspector(filename); in != nil {
+			ins = append(ins, in)
+		}
+		if f.ShadowEntry != nil {
+			shadowEntryFiles[f.ShadowEntry.Body] = filename
+		}
+	}
+	if len(ins) == len(pkg.Files) {
+		pkg.Inspector = inspector.Merge(ins...)
+	}
+	pkg.CFG = func(body *ast.BlockStmt) *cfg.CFG {
+		filename, ok := shadowEntryFiles[body]
+		if !ok {
+			if f := proj.Fset.File(body.Pos()); f != nil {
+				filename = f.Name()
+			}
+		}
+		if g, _ := proj.CFG(filename, body); g != nil {
+			return g
+		}
+		return cfg.New(body, cfg.MayReturn)
+	}
+	errs, ok := err.(qerrors.List)
+	if !ok && err != nil {
+		errs = qerrors.List{err}
+	}
+	for _, err := range errs {
+		if typeErr, ok := err.(types.Error); ok {
+			pkg.TypeErrors = append(pkg.TypeErrors, typeErr)
+		}
+	}
+	return pkg, nil
+}
+
+// computeCacheKey computes the cache key for running the given analyzers on
+// astPkg.
+func computeCacheKey(proj *gop.Project, astPkg *ast.Package, analyzers []*protocol.Analyzer) cacheKey {
+	h := sha256.New()
+	writeString := func(s string) {
+		binary.Write(h, binary.LittleEndian, uint64(len(s)))
+		h.Write([]byte(s))
+	}
+	for _, filename := range sortedFilenames(astPkg) {
+		writeString(filename)
+		if file, ok := proj.File(filename); ok {
+			writeString(string(file.Content))
+		}
+	}
+	names := make([]string, 0, len(analyzers))
+	for _, an := range analyzers {
+		names = append(names, an.Name)
+	}
+	writeString(strings.Join(names, ","))
+
+	var key cacheKey
+	h.Sum(key[:0])
+	return key
+}
+
+// sortedFilenames returns the filenames of astPkg in sorted order.
+func sortedFilenames(astPkg *ast.Package) []string {
+	filenames := make([]string, 0, len(astPkg.Files))
+	for filename := range astPkg.Files {
+		filenames = append(filenames, filename)
+	}
+	slices.Sort(filenames)
+	return filenames
+}
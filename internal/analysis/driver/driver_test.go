@@ -0,0 +1,251 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/internal"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/analysis/passes/ctrlflow"
+	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/passes/printf"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+	"github.com/goplus/mod/gopmod"
+	"github.com/goplus/mod/modload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProject(t *testing.T, files map[string]string) *gop.Project {
+	t.Helper()
+	projFiles := make(map[string]gop.File, len(files))
+	for name, content := range files {
+		projFiles[name] = &gop.FileImpl{Content: []byte(content), ModTime: time.Now()}
+	}
+	proj := gop.NewProject(nil, projFiles, gop.FeatAll)
+	proj.Path = "main"
+	proj.Mod = gopmod.New(modload.Default)
+	proj.Importer = internal.Importer
+	return proj
+}
+
+func messages(result *Result) []string {
+	var msgs []string
+	for _, d := range result.Diagnostics {
+		msgs = append(msgs, d.Message)
+	}
+	return msgs
+}
+
+func TestDriverRun(t *testing.T) {
+	t.Run("FactsAcrossFiles", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"log.gop": `
+func logf(format string, args ...any) {
+	printf format, args...
+}
+`,
+			"main.gop": `
+logf "%d", "hello"
+`,
+		})
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{printf.Analyzer})
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Equal(t, []string{`logf format %d has arg "hello" of wrong type untyped string`}, messages(result))
+		assert.Equal(t, "main.gop", result.Fset.Position(result.Diagnostics[0].Pos).Filename)
+	})
+
+	t.Run("FailedPrerequisite", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"main.gop": `echo "hello"`,
+		})
+		panicking := &protocol.Analyzer{
+			Name:             "panicking",
+			RunDespiteErrors: true,
+			Run: func(*protocol.Pass) (any, error) {
+				panic("oops")
+			},
+		}
+		dependent := &protocol.Analyzer{
+			Name:             "dependent",
+			Requires:         []*protocol.Analyzer{panicking},
+			RunDespiteErrors: true,
+			Run: func(pass *protocol.Pass) (any, error) {
+				pass.ReportRangef(pass.Files[0], "unexpected")
+				return nil, nil
+			},
+		}
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{dependent, panicking})
+		require.NoError(t, err)
+		assert.Empty(t, result.Diagnostics)
+		require.Len(t, result.Errors, 2)
+		assert.ErrorContains(t, result.Errors[panicking], "panic: oops")
+		var panicErr *PanicError
+		require.ErrorAs(t, result.Errors[panicking], &panicErr)
+		assert.Equal(t, "oops", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "runPass")
+		assert.True(t, errors.Is(result.Errors[dependent], errPrerequisite))
+		assert.False(t, errors.As(result.Errors[dependent], &panicErr))
+	})
+
+	t.Run("TypeErrors", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"main.gop": `
+var n int = "hello"
+printf "%d\n", "hello"
+`,
+		})
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{printf.Analyzer})
+		require.NoError(t, err)
+		assert.Empty(t, result.Diagnostics)
+		assert.ErrorIs(t, result.Errors[printf.Analyzer], ErrTypeErrors)
+	})
+
+	t.Run("Nolint", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"a.gop": `
+//xgo:nolint printf
+printf "%d\n", "suppressed"
+
+//xgo:nolint unusedresult // stacked directives
+//xgo:nolint
+printf "%d\n", "suppressed by all"
+
+printf "%d\n", "suppressed by trailing" //xgo:nolint printf
+printf "%d\n", "reported"
+
+//xgo:nolint unusedresult
+printf "%d\n", "not suppressed by other analyzers"
+`,
+			"b.gop": `
+//xgo:nolintfile printf, unusedresult
+
+func f() {
+	printf "%d\n", "suppressed"
+}
+`,
+		})
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{printf.Analyzer})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`printf format %d has arg "reported" of wrong type untyped string`,
+			`printf format %d has arg "not suppressed by other analyzers" of wrong type untyped string`,
+		}, messages(result))
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		files := map[string]string{
+			"main.gop": `printf "%d\n", "hello"`,
+		}
+		analyzers := []*protocol.Analyzer{printf.Analyzer}
+		d := New()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := d.Run(ctx, newTestProject(t, files), analyzers)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, result)
+
+		result, err = d.Run(context.Background(), newTestProject(t, files), analyzers)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Len(t, result.Diagnostics, 1)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		files := map[string]string{
+			"main.gop": `printf "%d\n", "hello"`,
+		}
+		analyzers := []*protocol.Analyzer{printf.Analyzer}
+		d := New()
+
+		result1, err := d.Run(context.Background(), newTestProject(t, files), analyzers)
+		require.NoError(t, err)
+		result2, err := d.Run(context.Background(), newTestProject(t, files), analyzers)
+		require.NoError(t, err)
+		assert.Same(t, result1, result2)
+
+		proj := newTestProject(t, files)
+		proj.PutFile("main.gop", &gop.FileImpl{Content: []byte(`printf "%s\n", "hello"`)})
+		result3, err := d.Run(context.Background(), proj, analyzers)
+		require.NoError(t, err)
+		assert.NotSame(t, result1, result3)
+		assert.Empty(t, result3.Diagnostics)
+
+		result4, err := d.Run(context.Background(), newTestProject(t, files), nil)
+		require.NoError(t, err)
+		assert.NotSame(t, result1, result4)
+		assert.Empty(t, result4.Diagnostics)
+	})
+	t.Run("CachedInspectors", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"a.gop":    `echo "a"`,
+			"main.gop": `echo "main"` + "\n" + `echo len("main")`,
+		})
+		calls := &protocol.Analyzer{
+			Name:             "calls",
+			Requires:         []*protocol.Analyzer{inspect.Analyzer},
+			RunDespiteErrors: true,
+			Run: func(pass *protocol.Pass) (any, error) {
+				in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+				for call := range inspector.All[*ast.CallExpr](in) {
+					pass.ReportRangef(call, "%s", pass.Fset.Position(call.Pos()))
+				}
+				return nil, nil
+			},
+		}
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{calls})
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Equal(t, []string{"a.gop:1:1", "main.gop:1:1", "main.gop:2:1", "main.gop:2:6"}, messages(result))
+	})
+
+	t.Run("CachedCFGs", func(t *testing.T) {
+		proj := newTestProject(t, map[string]string{
+			"main.spx":          "func f() {\n\treturn\n\techo \"dead\"\n}\n\necho \"main\"\n",
+			"assets/index.json": `{}`,
+		})
+		require.NoError(t, proj.Mod.ImportClasses())
+		f, err := proj.AST("main.spx")
+		require.NoError(t, err)
+		// The body of the ShadowEntry of a class file has no positions.
+		bodies := []*ast.BlockStmt{f.Decls[0].(*ast.FuncDecl).Body, f.ShadowEntry.Body}
+		var cached []*cfg.CFG
+		for _, body := range bodies {
+			g, err := proj.CFG("main.spx", body)
+			require.NoError(t, err)
+			cached = append(cached, g)
+		}
+
+		var got []*cfg.CFG
+		graphs := &protocol.Analyzer{
+			Name:             "graphs",
+			Requires:         []*protocol.Analyzer{ctrlflow.Analyzer},
+			RunDespiteErrors: true,
+			Run: func(pass *protocol.Pass) (any, error) {
+				for _, body := range bodies {
+					got = append(got, pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs).Body(body))
+				}
+				return nil, nil
+			},
+		}
+
+		result, err := New().Run(context.Background(), proj, []*protocol.Analyzer{graphs})
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		require.Len(t, got, 2)
+		assert.Same(t, cached[0], got[0])
+		assert.Same(t, cached[1], got[1])
+	})
+}
@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+// factStore stores the facts of an analyzer.
+type factStore struct {
+	analyzer     *protocol.Analyzer
+	pkg          *types.Package
+	factTypes    map[reflect.Type]bool
+	objectFacts  map[objectFactKey]protocol.Fact
+	packageFacts map[packageFactKey]protocol.Fact
+}
+
+// objectFactKey is the key of an object fact.
+type objectFactKey struct {
+	obj types.Object
+	typ reflect.Type
+}
+
+// packageFactKey is the key of a package fact.
+type packageFactKey struct {
+	pkg *types.Package
+	typ reflect.Type
+}
+
+// newFactStore creates a new [factStore] for the analyzer analyzing pkg.
+func newFactStore(analyzer *protocol.Analyzer, pkg *types.Package) *factStore {
+	factTypes := make(map[reflect.Type]bool, len(analyzer.FactTypes))
+	for _, fact := range analyzer.FactTypes {
+		factTypes[reflect.TypeOf(fact)] = true
+	}
+	return &factStore{
+		analyzer:     analyzer,
+		pkg:          pkg,
+		factTypes:    factTypes,
+		objectFacts:  make(map[objectFactKey]protocol.Fact),
+		packageFacts: make(map[packageFactKey]protocol.Fact),
+	}
+}
+
+// checkFactType panics if fact is not of a type declared in the FactTypes of
+// the analyzer.
+func (s *factStore) checkFactType(fact protocol.Fact) {
+	if !s.factTypes[reflect.TypeOf(fact)] {
+		panic(fmt.Sprintf("analyzer %s: fact type %T not declared in FactTypes", s.analyzer.Name, fact))
+	}
+}
+
+// importObjectFact implements [protocol.Pass.ImportObjectFact].
+func (s *factStore) importObjectFact(obj types.Object, ptr protocol.Fact) bool {
+	if obj == nil {
+		panic("nil object")
+	}
+	s.checkFactType(ptr)
+	fact, ok := s.objectFacts[objectFactKey{obj, reflect.TypeOf(ptr)}]
+	if ok {
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(fact).Elem())
+	}
+	return ok
+}
+
+// exportObjectFact implements [protocol.Pass.ExportObjectFact].
+func (s *factStore) exportObjectFact(obj types.Object, fact protocol.Fact) {
+	if obj.Pkg() != s.pkg {
+		panic(fmt.Sprintf("analyzer %s: exporting fact for object %s of another package", s.analyzer.Name, obj))
+	}
+	s.checkFactType(fact)
+	s.objectFacts[objectFactKey{obj, reflect.TypeOf(fact)}] = fact
+}
+
+// importPackageFact implements [protocol.Pass.ImportPackageFact].
+func (s *factStore) importPackageFact(pkg *types.Package, ptr protocol.Fact) bool {
+	if pkg == nil {
+		panic("nil package")
+	}
+	s.checkFactType(ptr)
+	fact, ok := s.packageFacts[packageFactKey{pkg, reflect.TypeOf(ptr)}]
+	if ok {
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(fact).Elem())
+	}
+	return ok
+}
+
+// exportPackageFact implements [protocol.Pass.ExportPackageFact].
+func (s *factStore) exportPackageFact(fact protocol.Fact) {
+	s.checkFactType(fact)
+	s.packageFacts[packageFactKey{s.pkg, reflect.TypeOf(fact)}] = fact
+}
+
+// allObjectFacts implements [protocol.Pass.AllObjectFacts].
+func (s *factStore) allObjectFacts() []protocol.ObjectFact {
+	facts := make([]protocol.ObjectFact, 0, len(s.objectFacts))
+	for key, fact := range s.objectFacts {
+		facts = append(facts, protocol.ObjectFact{Object: key.obj, Fact: fact})
+	}
+	return facts
+}
+
+// allPackageFacts implements [protocol.Pass.AllPackageFacts].
+func (s *factStore) allPackageFacts() []protocol.PackageFact {
+	facts := make([]protocol.PackageFact, 0, len(s.packageFacts))
+	for key, fact := range s.packageFacts {
+		facts = append(facts, protocol.PackageFact{Package: key.pkg, Fact: fact})
+	}
+	return facts
+}
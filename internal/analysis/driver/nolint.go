@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"strings"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+const (
+	// nolintDirective suppresses diagnostics on the line following the
+	// comment group containing it, e.g. `//xgo:nolint appends,printf`, or on
+	// its own line if it trails code.
+	nolintDirective = "//xgo:nolint"
+
+	// nolintFileDirective suppresses diagnostics in the whole file containing
+	// it, e.g. `//xgo:nolintfile shadow`.
+	nolintFileDirective = "//xgo:nolintfile"
+)
+
+// nolintNames is a set of analyzer names listed by a nolint directive. An
+// empty set means all analyzers.
+type nolintNames map[string]bool
+
+// has reports whether the analyzer of the given name is in the set.
+func (names nolintNames) has(name string) bool {
+	return len(names) == 0 || names[name]
+}
+
+// merge returns the union of names and other.
+func (names nolintNames) merge(other nolintNames) nolintNames {
+	if len(names) == 0 || len(other) == 0 {
+		return nolintNames{}
+	}
+	for name := range other {
+		names[name] = true
+	}
+	return names
+}
+
+// fileNolint records the nolint directives of a file.
+type fileNolint struct {
+	file  nolintNames         // nil if there is no file-level directive
+	lines map[int]nolintNames // keyed by suppressed line
+}
+
+// suppressed reports whether a diagnostic of the named analyzer at the given
+// line is suppressed.
+func (n *fileNolint) suppressed(name string, line int) bool {
+	if n.file != nil && n.file.has(name) {
+		return true
+	}
+	names, ok := n.lines[line]
+	return ok && names.has(name)
+}
+
+// parseNolint parses the nolint directives in the given files, keyed by
+// filename.
+func parseNolint(fset *token.FileSet, files []*ast.File) map[string]*fileNolint {
+	nolints := make(map[string]*fileNolint)
+	for _, f := range files {
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				fileNames, isFile := parseNolintDirective(c.Text, nolintFileDirective)
+				lineNames, isLine := parseNolintDirective(c.Text, nolintDirective)
+				if !isFile && !isLine {
+					continue
+				}
+
+				filename := fset.Position(c.Slash).Filename
+				n, ok := nolints[filename]
+				if !ok {
+					n = &fileNolint{lines: make(map[int]nolintNames)}
+					nolints[filename] = n
+				}
+				if isFile {
+					if n.file == nil {
+						n.file = fileNames
+					} else {
+						n.file = n.file.merge(fileNames)
+					}
+					continue
+				}
+
+				// Directives on their own lines apply to the first line
+				// after the comment group, so that several of them can be
+				// stacked. Trailing directives apply to their own line.
+				line := fset.Position(group.End()).Line + 1
+				if position := fset.Position(c.Slash); !isOnOwnLine(f.Code, position.Offset) {
+					line = position.Line
+				}
+				if existing, ok := n.lines[line]; ok {
+					lineNames = existing.merge(lineNames)
+				}
+				n.lines[line] = lineNames
+			}
+		}
+	}
+	return nolints
+}
+
+// isOnOwnLine reports whether only whitespace precedes the given offset on
+// its line of code.
+func isOnOwnLine(code []byte, offset int) bool {
+	if offset > len(code) {
+		return true
+	}
+	for i := offset - 1; i >= 0 && code[i] != '\n'; i-- {
+		if code[i] != ' ' && code[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNolintDirective parses the comment text as the given nolint directive
+// and returns the analyzer names it lists. Names are separated by commas or
+// spaces, and an optional explanation may follow after "//".
+func parseNolintDirective(text, directive string) (nolintNames, bool) {
+	rest, ok := strings.CutPrefix(text, directive)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return nil, false
+	}
+	rest, _, _ = strings.Cut(rest, "//")
+	names := make(nolintNames)
+	for _, name := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		names[name] = true
+	}
+	return names, true
+}
+
+// filterNolint removes the diagnostics suppressed by nolint directives in the
+// files of pkg.
+func filterNolint(pkg *Package, diagnostics []Diagnostic) []Diagnostic {
+	nolints := parseNolint(pkg.Fset, pkg.Files)
+	if len(nolints) == 0 {
+		return diagnostics
+	}
+
+	filtered := diagnostics[:0]
+	for _, d := range diagnostics {
+		position := pkg.Fset.Position(d.Pos)
+		if n, ok := nolints[position.Filename]; ok && n.suppressed(d.Analyzer.Name, position.Line) {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+	return filtered
+}
@@ -1,10 +1,13 @@
 package appends
 
 import (
+	"bytes"
 	_ "embed"
+	"go/types"
 
 	"github.com/goplus/gogen"
 	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/format"
 	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
 	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
 	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysisutil"
@@ -29,16 +32,100 @@ func run(pass *protocol.Pass) (any, error) {
 	nodeFilter := []ast.Node{
 		(*ast.CallExpr)(nil),
 	}
-	inspect.Preorder(nodeFilter, func(n ast.Node) {
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
 		call := n.(*ast.CallExpr)
-		// ast.Print(pass.Fset, call)
-		// fmt.Printf("%T\n", typeutil.Callee(pass.TypesInfo, call))
 		b, ok := typeutil.Callee(pass.TypesInfo, call).(*gogen.TemplateFunc)
-		// fmt.Println(ok, b.Name())
 		if ok && b.Name() == "append" && len(call.Args) == 1 {
-			pass.ReportRangef(call, "append with no values")
+			var stmt *ast.ExprStmt
+			if len(stack) >= 2 {
+				stmt, _ = stack[len(stack)-2].(*ast.ExprStmt)
+			}
+			pass.Report(protocol.Diagnostic{
+				Pos:            call.Pos(),
+				End:            call.End(),
+				Message:        "append with no values",
+				SuggestedFixes: suggestedFixes(pass, call, stmt),
+			})
 		}
+		return true
 	})
 
 	return nil, nil
 }
+
+// suggestedFixes returns the fixes for the given no-op append call. If the
+// result of the call is discarded, stmt is the statement of the call.
+func suggestedFixes(pass *protocol.Pass, call *ast.CallExpr, stmt *ast.ExprStmt) []protocol.SuggestedFix {
+	slice := call.Args[0]
+	placeholder := "nil"
+	if typ := pass.TypesInfo.TypeOf(slice); typ != nil {
+		if sliceType, ok := typ.Underlying().(*types.Slice); ok {
+			placeholder = zeroValue(pass, sliceType.Elem())
+		}
+	}
+
+	if stmt == nil {
+		// Add a value to the call, e.g. `s = append(s)` -> `s = append(s, 0)`.
+		pos := call.Rparen
+		if call.IsCommand() {
+			pos = call.End()
+		}
+		return []protocol.SuggestedFix{{
+			Message: "Add a value to append",
+			TextEdits: []protocol.TextEdit{{
+				Pos:     pos,
+				End:     pos,
+				NewText: []byte(", " + placeholder),
+			}},
+		}}
+	}
+
+	var sliceSrc bytes.Buffer
+	if err := format.Node(&sliceSrc, pass.Fset, slice); err != nil {
+		return nil
+	}
+	return []protocol.SuggestedFix{
+		{
+			Message: "Assign the result of append with a value",
+			TextEdits: []protocol.TextEdit{{
+				Pos:     stmt.Pos(),
+				End:     stmt.End(),
+				NewText: []byte(sliceSrc.String() + " = append(" + sliceSrc.String() + ", " + placeholder + ")"),
+			}},
+		},
+		{
+			Message: "Remove the no-op append call",
+			TextEdits: []protocol.TextEdit{{
+				Pos: stmt.Pos(),
+				End: stmt.End(),
+			}},
+		},
+	}
+}
+
+// zeroValue returns the source of the zero value of the given type, which is
+// used as a placeholder for the value to append.
+func zeroValue(pass *protocol.Pass, typ types.Type) string {
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(typ, func(pkg *types.Package) string {
+			if pkg == pass.Pkg {
+				return ""
+			}
+			return pkg.Name()
+		}) + "{}"
+	}
+	return "nil"
+}
@@ -2,6 +2,7 @@ package appends
 
 import (
 	"go/types"
+	"slices"
 	"testing"
 
 	"github.com/goplus/gop/ast"
@@ -15,9 +16,10 @@ import (
 
 func TestAppends(t *testing.T) {
 	tests := []struct {
-		name     string
-		src      string
-		wantDiag bool
+		name      string
+		src       string
+		wantDiag  bool
+		wantFixes []string
 	}{
 		{
 			name: "append without values",
@@ -26,6 +28,25 @@ var s []int
 _ = append(s)
 `,
 			wantDiag: true,
+			wantFixes: []string{`
+var s []int
+_ = append(s, 0)
+`},
+		},
+		{
+			name: "append without values and discarded result",
+			src: `
+var s []string
+append(s)
+`,
+			wantDiag: true,
+			wantFixes: []string{`
+var s []string
+s = append(s, "")
+`, `
+var s []string
+
+`},
 		},
 		{
 			name: "append with values",
@@ -93,6 +114,25 @@ _ = append(s, 1)
 			if hasDiag != tt.wantDiag {
 				t.Errorf("got diagnostic = %v, want %v", hasDiag, tt.wantDiag)
 			}
+			if !hasDiag {
+				return
+			}
+
+			fixes := diagnostics[0].SuggestedFixes
+			if len(fixes) != len(tt.wantFixes) {
+				t.Fatalf("got %d suggested fixes, want %d", len(fixes), len(tt.wantFixes))
+			}
+			for i, fix := range fixes {
+				src := []byte(tt.src)
+				for _, edit := range slices.Backward(fix.TextEdits) {
+					start := fset.Position(edit.Pos).Offset
+					end := fset.Position(edit.End).Offset
+					src = slices.Concat(src[:start], edit.NewText, src[end:])
+				}
+				if got := string(src); got != tt.wantFixes[i] {
+					t.Errorf("got fixed source %q for fix %q, want %q", got, fix.Message, tt.wantFixes[i])
+				}
+			}
 		})
 	}
 }
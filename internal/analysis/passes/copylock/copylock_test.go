@@ -0,0 +1,75 @@
+package copylock
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyLocks(t *testing.T) {
+	t.Run("Assign", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "sync"
+
+var mu sync.Mutex
+mu2 := mu
+echo &mu2
+`)
+		assert.Equal(t, []string{"assignment copies lock value to mu2: sync.Mutex"}, result.Messages())
+	})
+
+	t.Run("FuncParam", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "sync"
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func inc(c Counter) {
+	c.n++
+}
+`)
+		assert.Equal(t, []string{"inc passes lock by value: test.Counter contains sync.Mutex"}, result.Messages())
+	})
+
+	t.Run("CallArg", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "sync"
+
+func use(v any) {}
+
+var wg sync.WaitGroup
+use wg
+`)
+		assert.Equal(t, []string{"call of use copies lock value: sync.WaitGroup contains sync.noCopy"}, result.Messages())
+	})
+
+	t.Run("ForPhrase", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "sync"
+
+var mus []sync.Mutex
+for mu <- mus {
+	echo &mu
+}
+`)
+		assert.Equal(t, []string{"range var mu copies lock: sync.Mutex"}, result.Messages())
+	})
+
+	t.Run("Pointer", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "sync"
+
+func lock(mu *sync.Mutex) {
+	mu.Lock()
+}
+
+var mu sync.Mutex
+lock &mu
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
@@ -0,0 +1,11 @@
+// Package copylock defines an Analyzer that checks for locks
+// erroneously passed by value.
+//
+// # Analyzer copylocks
+//
+// copylocks: check for locks erroneously passed by value
+//
+// Inadvertently copying a value containing a lock, such as sync.Mutex or
+// sync.WaitGroup, may cause both copies to malfunction. Generally such
+// values should be referred to through a pointer.
+package copylock
@@ -0,0 +1,69 @@
+// Package ctrlflow defines an Analyzer that provides the control-flow graphs
+// of the function bodies in the syntax trees of a package. It is only a
+// building block for other analyzers.
+//
+// Example of use in another analysis:
+//
+//	var Analyzer = &protocol.Analyzer{
+//		...
+//		Requires: []*protocol.Analyzer{ctrlflow.Analyzer},
+//	}
+//
+//	func run(pass *protocol.Pass) (any, error) {
+//		cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+//		g := cfgs.Body(decl.Body)
+//		...
+//	}
+package ctrlflow
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+var Analyzer = &protocol.Analyzer{
+	Name:             "ctrlflow",
+	Doc:              "build a control-flow graph",
+	URL:              "https://pkg.go.dev/golang.org/x/tools/go/analysis/passes/ctrlflow",
+	Run:              run,
+	RunDespiteErrors: true,
+	ResultType:       reflect.TypeOf(new(CFGs)),
+}
+
+// CFGs provides the control-flow graphs of the function bodies of a package,
+// which are built on demand.
+type CFGs struct {
+	build func(body *ast.BlockStmt) *cfg.CFG
+
+	mu   sync.Mutex
+	cfgs map[*ast.BlockStmt]*cfg.CFG
+}
+
+// New returns the [CFGs] whose graphs are built by build, e.g., looked up in
+// a cache of the project.
+func New(build func(body *ast.BlockStmt) *cfg.CFG) *CFGs {
+	return &CFGs{build: build, cfgs: make(map[*ast.BlockStmt]*cfg.CFG)}
+}
+
+// Body returns the control-flow graph of the given function body, e.g., the
+// body of a function declaration, a function literal or a lambda.
+func (c *CFGs) Body(body *ast.BlockStmt) *cfg.CFG {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g, ok := c.cfgs[body]
+	if !ok {
+		g = c.build(body)
+		c.cfgs[body] = g
+	}
+	return g
+}
+
+func run(pass *protocol.Pass) (any, error) {
+	return New(func(body *ast.BlockStmt) *cfg.CFG {
+		return cfg.New(body, cfg.MayReturn)
+	}), nil
+}
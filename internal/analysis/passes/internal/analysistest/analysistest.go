@@ -0,0 +1,112 @@
+// Package analysistest provides utilities for testing analyzers on Go+
+// source files.
+package analysistest
+
+import (
+	"context"
+	"go/types"
+	"slices"
+	"testing"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/parser"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/internal"
+	"github.com/goplus/goxlsw/internal/analysis/driver"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+// Result is the result of running an analyzer on a Go+ source file.
+type Result struct {
+	Fset        *token.FileSet
+	Src         string
+	Diagnostics []protocol.Diagnostic
+}
+
+// Run parses and type-checks the given Go+ source, runs the analyzer on it,
+// and returns the reported diagnostics. Type checking errors are logged but
+// do not fail the test. Analyzers that do not run despite errors fail the
+// test if there are any.
+func Run(t *testing.T, a *protocol.Analyzer, src string) *Result {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.gop", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := &driver.Package{
+		Fset:  fset,
+		Files: []*ast.File{f},
+		Types: types.NewPackage("test", "test"),
+		TypesInfo: &typesutil.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	checker := typesutil.NewChecker(
+		&types.Config{
+			Importer: internal.Importer,
+			Error: func(err error) {
+				if typeErr, ok := err.(types.Error); ok {
+					pkg.TypeErrors = append(pkg.TypeErrors, typeErr)
+				}
+			},
+		},
+		&typesutil.Config{
+			Fset:  fset,
+			Types: pkg.Types,
+		},
+		nil,
+		pkg.TypesInfo,
+	)
+	if err := checker.Files(nil, []*ast.File{f}); err != nil {
+		t.Log("type checking error:", err)
+	}
+
+	analysisResult := driver.Analyze(context.Background(), pkg, []*protocol.Analyzer{a})
+	if err := analysisResult.Errors[a]; err != nil {
+		t.Fatal(err)
+	}
+	result := &Result{Fset: fset, Src: src}
+	for _, d := range analysisResult.Diagnostics {
+		result.Diagnostics = append(result.Diagnostics, d.Diagnostic)
+	}
+	for _, d := range result.Diagnostics {
+		t.Logf("got diagnostic: %s: %s", fset.Position(d.Pos), d.Message)
+	}
+	return result
+}
+
+// Messages returns the messages of the reported diagnostics.
+func (r *Result) Messages() []string {
+	messages := make([]string, 0, len(r.Diagnostics))
+	for _, d := range r.Diagnostics {
+		messages = append(messages, d.Message)
+	}
+	return messages
+}
+
+// ApplyFix returns the source with the text edits of the given fix applied.
+func (r *Result) ApplyFix(fix protocol.SuggestedFix) string {
+	src := []byte(r.Src)
+	edits := slices.Clone(fix.TextEdits)
+	slices.SortStableFunc(edits, func(a, b protocol.TextEdit) int {
+		return int(a.Pos - b.Pos)
+	})
+	for _, edit := range slices.Backward(edits) {
+		start := r.Fset.Position(edit.Pos).Offset
+		end := start
+		if edit.End.IsValid() {
+			end = r.Fset.Position(edit.End).Offset
+		}
+		src = slices.Concat(src[:start], edit.NewText, src[end:])
+	}
+	return string(src)
+}
@@ -3,10 +3,16 @@
 package analysisutil
 
 import (
+	"bytes"
 	"fmt"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	gopformat "github.com/goplus/gop/format"
+	goptoken "github.com/goplus/gop/token"
 )
 
 // MustExtractDoc is like [ExtractDoc] but it panics on error.
@@ -110,3 +116,29 @@ func ExtractDoc(content, name string) (string, error) {
 	}
 	return "", fmt.Errorf("package doc comment contains no 'Analyzer %s' heading", name)
 }
+
+// Format returns a string representation of the expression x.
+func Format(fset *goptoken.FileSet, x gopast.Expr) string {
+	var b bytes.Buffer
+	gopformat.Node(&b, fset, x)
+	return b.String()
+}
+
+// IsNamedType reports whether t is the named type with the given package
+// path and one of the given names.
+func IsNamedType(t types.Type, pkgPath string, names ...string) bool {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != pkgPath {
+		return false
+	}
+	for _, name := range names {
+		if obj.Name() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Package loopclosure defines an Analyzer that checks for references to
+// enclosing loop variables from within nested functions.
+//
+// # Analyzer loopclosure
+//
+// loopclosure: check references to loop variables from within nested functions
+//
+// This analyzer reports places where a function literal references the
+// iteration variable of an enclosing loop, and the loop calls the function
+// in such a way (e.g. with go or defer) that it may outlive the loop
+// iteration and possibly observe the wrong value of the variable.
+//
+// Note: An iteration variable can only outlive a loop iteration when the
+// code is compiled with Go versions <=1.21. In Go 1.22 and later, the loop
+// variable lifetimes changed to create a new iteration variable per loop
+// iteration. (See go.dev/issue/60078.)
+//
+// In this example, all the deferred functions run after the loop has
+// completed, so all observe the final value of v [<go1.22].
+//
+//	for _, v := range list {
+//	    defer func() {
+//	        use(v) // incorrect
+//	    }()
+//	}
+//
+// One fix is to create a new variable for each iteration of the loop:
+//
+//	for _, v := range list {
+//	    v := v // new var per iteration
+//	    defer func() {
+//	        use(v) // ok
+//	    }()
+//	}
+//
+// The same applies to Go+ for phrase loops:
+//
+//	for v <- list {
+//	    go func() {
+//	        use(v) // incorrect
+//	    }()
+//	}
+//
+// The analyzer reports references only in the last statement,
+// as it is not deep enough to understand the effects of subsequent
+// statements that might render the reference benign.
+// ("Last statement" is defined recursively in compound
+// statements such as if, switch, and select.)
+//
+// See: https://golang.org/doc/go_faq.html#closures_and_goroutines
+package loopclosure
@@ -0,0 +1,77 @@
+package loopclosure
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoopClosure(t *testing.T) {
+	t.Run("GoStmtInRange", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(list []int) {
+	for _, v := range list {
+		go func() {
+			echo v
+		}()
+	}
+}
+`)
+		assert.Equal(t, []string{"loop variable v captured by func literal"}, result.Messages())
+	})
+
+	t.Run("DeferStmtInForPhrase", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(list []int) {
+	for v <- list {
+		defer func() {
+			echo v
+		}()
+	}
+}
+`)
+		assert.Equal(t, []string{"loop variable v captured by func literal"}, result.Messages())
+	})
+
+	t.Run("ForStmt", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	for i := 0; i < 3; i++ {
+		go func() {
+			echo i
+		}()
+	}
+}
+`)
+		assert.Equal(t, []string{"loop variable i captured by func literal"}, result.Messages())
+	})
+
+	t.Run("NotLastStmt", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(list []int) {
+	for v <- list {
+		go func() {
+			echo v
+		}()
+		echo "waiting"
+	}
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+
+	t.Run("NewVarPerIteration", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(list []int) {
+	for v <- list {
+		v := v
+		go func() {
+			echo v
+		}()
+	}
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
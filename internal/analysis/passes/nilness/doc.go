@@ -0,0 +1,31 @@
+// Package nilness defines an Analyzer that checks for nil dereferences and
+// uses of variables before they are assigned, using the control-flow graphs
+// of function bodies.
+//
+// # Analyzer nilness
+//
+// nilness: check for nil dereferences and uses of unassigned variables
+//
+// The nilness analyzer tracks local variables of pointer, map, function and
+// interface types along the paths of the control-flow graph of each function,
+// learning from assignments and from comparisons with nil. It reports
+// dereferences of variables that are nil on every path, which always panic:
+//
+//	var p *Point
+//	if p == nil {
+//		echo p.X // nil dereference in field selection
+//	}
+//
+// It also reports dereferences of variables that are not assigned on every
+// path to them, and so still hold the nil value of their declaration on some
+// path, a common mistake that type checking alone does not catch:
+//
+//	var m map[string]int
+//	if ok {
+//		m = {}
+//	}
+//	m["score"] = 100 // m may be nil here, as it is not assigned on every path
+//
+// Variables whose address is taken, or that are used in function literals,
+// are not tracked, as they may be assigned elsewhere.
+package nilness
@@ -0,0 +1,466 @@
+package nilness
+
+import (
+	_ "embed"
+	"go/types"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/analysis/passes/ctrlflow"
+	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysisutil"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &protocol.Analyzer{
+	Name:     "nilness",
+	Doc:      analysisutil.MustExtractDoc(doc, "nilness"),
+	URL:      "https://pkg.go.dev/golang.org/x/tools/go/analysis/passes/nilness",
+	Requires: []*protocol.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+	Run:      run,
+}
+
+func run(pass *protocol.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+		(*ast.LambdaExpr2)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		case *ast.LambdaExpr2:
+			body = n.Body
+		}
+		if body == nil {
+			return
+		}
+		c := &checker{pass: pass, vars: trackedVars(pass.TypesInfo.Uses, pass.TypesInfo.Defs, body)}
+		if len(c.vars) > 0 {
+			c.check(cfgs.Body(body))
+		}
+	})
+	return nil, nil
+}
+
+// A fact is the set of kinds of values a variable may hold at some point.
+// The zero fact means that nothing is known, e.g., before the declaration of
+// the variable.
+type fact uint8
+
+const (
+	unassigned fact = 1 << iota // the nil value of its declaration
+	isNil                       // nil, assigned or learned from a comparison
+	nonNil                      // any other value, possibly non-nil
+)
+
+// A state maps tracked variables to their facts at some point.
+type state map[*types.Var]fact
+
+func (s state) clone() state {
+	clone := make(state, len(s))
+	for v, f := range s {
+		clone[v] = f
+	}
+	return clone
+}
+
+// join adds the facts of t to s, and reports whether s changed. A nil s is
+// the state of a block not reached yet.
+func join(s, t state) (state, bool) {
+	if s == nil {
+		return t.clone(), true
+	}
+	changed := false
+	for v, f := range t {
+		if s[v]|f != s[v] {
+			s[v] |= f
+			changed = true
+		}
+	}
+	return s, changed
+}
+
+type checker struct {
+	pass *protocol.Pass
+	vars map[*types.Var]bool
+}
+
+// check computes the states at the entries of the blocks of g to a fixed
+// point, then reports dereferences of nil variables in live blocks.
+func (c *checker) check(g *cfg.CFG) {
+	in := make([]state, len(g.Blocks))
+	in[0] = state{}
+	work := []*cfg.Block{g.Blocks[0]}
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		s := in[b.Index].clone()
+		c.transfer(b, s, false)
+		for i, succ := range b.Succs {
+			out := s
+			if len(b.Succs) == 2 {
+				if out = c.refine(b, s, i == 0); out == nil {
+					continue // The edge is infeasible.
+				}
+			}
+			var changed bool
+			if in[succ.Index], changed = join(in[succ.Index], out); changed {
+				work = append(work, succ)
+			}
+		}
+	}
+	for _, b := range g.Blocks {
+		if b.Live && in[b.Index] != nil {
+			c.transfer(b, in[b.Index].clone(), true)
+		}
+	}
+}
+
+// transfer updates s with the effects of the nodes of b, reporting
+// dereferences of nil variables if report is set.
+func (c *checker) transfer(b *cfg.Block, s state, report bool) {
+	for _, n := range b.Nodes {
+		c.checkDerefs(n, s, report)
+
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				v := c.trackedVar(name)
+				if v == nil {
+					continue
+				}
+				switch len(n.Values) {
+				case 0:
+					s[v] = unassigned
+				case len(n.Names):
+					s[v] = c.eval(n.Values[i], s)
+				default:
+					s[v] = nonNil
+				}
+			}
+		case *ast.AssignStmt:
+			// All right-hand sides are evaluated before any assignment.
+			facts := make([]fact, len(n.Lhs))
+			for i := range n.Lhs {
+				facts[i] = nonNil
+				if (n.Tok == token.ASSIGN || n.Tok == token.DEFINE) && len(n.Lhs) == len(n.Rhs) {
+					facts[i] = c.eval(n.Rhs[i], s)
+				}
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					if v := c.trackedVar(id); v != nil {
+						s[v] = facts[i]
+					}
+				}
+			}
+		}
+	}
+}
+
+// refine returns the state on the true or false edge of the conditional block
+// b, learning from comparisons of tracked variables with nil. It returns nil
+// if the edge is infeasible.
+func (c *checker) refine(b *cfg.Block, s state, cond bool) state {
+	if len(b.Nodes) == 0 {
+		return s
+	}
+	e, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr)
+	if !ok {
+		return s
+	}
+	e = unparen(e)
+	for {
+		not, ok := e.(*ast.UnaryExpr)
+		if !ok || not.Op != token.NOT {
+			break
+		}
+		e, cond = unparen(not.X), !cond
+	}
+	cmp, ok := e.(*ast.BinaryExpr)
+	if !ok || cmp.Op != token.EQL && cmp.Op != token.NEQ {
+		return s
+	}
+	x, y := unparen(cmp.X), unparen(cmp.Y)
+	if c.isNil(x) {
+		x, y = y, x
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok || !c.isNil(y) {
+		return s
+	}
+	v := c.trackedVar(id)
+	if v == nil || s[v] == 0 {
+		return s
+	}
+	if cmp.Op == token.NEQ {
+		cond = !cond
+	}
+	f := s[v] & nonNil
+	if cond {
+		f = s[v] &^ nonNil
+		if s[v]&nonNil != 0 {
+			f |= isNil // Other values may be nil too.
+		}
+	}
+	if f == 0 {
+		return nil
+	}
+	s = s.clone()
+	s[v] = f
+	return s
+}
+
+// checkDerefs reports dereferences of nil variables in n, outside of function
+// literals, if report is set. Variables are known not to be nil after they
+// are dereferenced.
+func (c *checker) checkDerefs(n ast.Node, s state, report bool) {
+	var derefs []*types.Var
+	deref := func(n ast.Node, id *ast.Ident, op string) {
+		v := c.trackedVar(id)
+		if v == nil {
+			return
+		}
+		if report {
+			c.report(n, v, s[v], op)
+		}
+		derefs = append(derefs, v)
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.LambdaExpr, *ast.LambdaExpr2:
+			return false
+		case *ast.StarExpr:
+			if id, ok := unparen(n.X).(*ast.Ident); ok && isPointer(c.pass.TypesInfo.TypeOf(id)) {
+				deref(n, id, "load")
+			}
+		case *ast.SelectorExpr:
+			// Selections are not recorded for all selectors, e.g., fields.
+			id, ok := unparen(n.X).(*ast.Ident)
+			if !ok {
+				break
+			}
+			switch obj := c.pass.TypesInfo.Uses[n.Sel].(type) {
+			case *types.Var:
+				if obj.IsField() && isPointer(c.pass.TypesInfo.TypeOf(id)) {
+					deref(n, id, "field selection")
+				}
+			case *types.Func:
+				if t := c.pass.TypesInfo.TypeOf(id); t != nil && types.IsInterface(t) {
+					deref(n, id, "dynamic method call")
+				}
+			}
+		case *ast.CallExpr:
+			if id, ok := unparen(n.Fun).(*ast.Ident); ok && isFunc(c.pass.TypesInfo.TypeOf(id)) {
+				deref(n, id, "dynamic function call")
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				c.checkMapUpdate(lhs, deref)
+			}
+		case *ast.IncDecStmt:
+			c.checkMapUpdate(n.X, deref)
+		}
+		return true
+	})
+	for _, v := range derefs {
+		s[v] = nonNil
+	}
+}
+
+// checkMapUpdate calls deref if x is an element of a map variable, which is
+// updated.
+func (c *checker) checkMapUpdate(x ast.Expr, deref func(n ast.Node, id *ast.Ident, op string)) {
+	index, ok := unparen(x).(*ast.IndexExpr)
+	if !ok {
+		return
+	}
+	id, ok := unparen(index.X).(*ast.Ident)
+	if !ok {
+		return
+	}
+	if t := c.pass.TypesInfo.TypeOf(id); t != nil {
+		if _, ok := t.Underlying().(*types.Map); ok {
+			deref(index, id, "map update")
+		}
+	}
+}
+
+// report reports the dereference n of v with the fact f, if v is nil on
+// every path, or is not assigned on some path.
+func (c *checker) report(n ast.Node, v *types.Var, f fact, op string) {
+	switch {
+	case f == unassigned:
+		c.pass.ReportRangef(n, "nil dereference in %s, as %s is never assigned", op, v.Name())
+	case f != 0 && f&nonNil == 0:
+		c.pass.ReportRangef(n, "nil dereference in %s", op)
+	case f&unassigned != 0:
+		c.pass.ReportRangef(n, "%s may be nil here, as it is not assigned on every path", v.Name())
+	}
+}
+
+// eval returns the fact of the value of e in s.
+func (c *checker) eval(e ast.Expr, s state) fact {
+	e = unparen(e)
+	if c.isNil(e) {
+		return isNil
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		if v := c.trackedVar(id); v != nil && s[v] != 0 {
+			// The nil value of its declaration is assigned explicitly now.
+			f := s[v] &^ unassigned
+			if s[v]&unassigned != 0 {
+				f |= isNil
+			}
+			return f
+		}
+	}
+	return nonNil
+}
+
+// isNil reports whether e is the predeclared nil.
+func (c *checker) isNil(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = c.pass.TypesInfo.Uses[id].(*types.Nil)
+	return ok
+}
+
+// trackedVar returns the tracked variable defined or used by id, if any.
+func (c *checker) trackedVar(id *ast.Ident) *types.Var {
+	obj := c.pass.TypesInfo.Defs[id]
+	if obj == nil {
+		obj = c.pass.TypesInfo.Uses[id]
+	}
+	if v, ok := obj.(*types.Var); ok && c.vars[v] {
+		return v
+	}
+	return nil
+}
+
+// trackedVars returns the local variables of nillable types declared in body,
+// outside of function literals, whose assignments are all tracked, i.e., are
+// not taken the address of, not used in function literals, and not assigned
+// by range statements.
+func trackedVars(uses, defs map[*ast.Ident]types.Object, body *ast.BlockStmt) map[*types.Var]bool {
+	vars := make(map[*types.Var]bool)
+	define := func(id *ast.Ident) {
+		if v, ok := defs[id].(*types.Var); ok && isNillable(v.Type()) {
+			vars[v] = true
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.LambdaExpr, *ast.LambdaExpr2:
+			return false
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				define(name)
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						define(id)
+					}
+				}
+			}
+		}
+		return true
+	})
+	if len(vars) == 0 {
+		return nil
+	}
+
+	untrack := func(e ast.Expr) {
+		if id, ok := unparen(e).(*ast.Ident); ok {
+			if v, ok := uses[id].(*types.Var); ok {
+				delete(vars, v)
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.LambdaExpr, *ast.LambdaExpr2:
+			ast.Inspect(n, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					untrack(id)
+				}
+				return true
+			})
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				untrack(n.X)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					untrack(n.Key)
+				}
+				if n.Value != nil {
+					untrack(n.Value)
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// isNillable reports whether values of t may be nil and are dereferenced by
+// some operations.
+func isNillable(t types.Type) bool {
+	if _, ok := t.(*types.TypeParam); ok {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Map, *types.Signature, *types.Interface:
+		return true
+	}
+	return false
+}
+
+// isPointer reports whether t is a pointer type.
+func isPointer(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+// isFunc reports whether t is a function type.
+func isFunc(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Signature)
+	return ok
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		paren, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = paren.X
+	}
+}
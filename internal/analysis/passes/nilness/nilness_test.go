@@ -0,0 +1,154 @@
+package nilness
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNilness(t *testing.T) {
+	t.Run("NeverAssigned", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+type Point struct {
+	X int
+}
+
+func f() {
+	var p *Point
+	echo p.X
+	echo p.X
+}
+`)
+		require.Len(t, result.Diagnostics, 1)
+		assert.Equal(t, "nil dereference in field selection, as p is never assigned", result.Diagnostics[0].Message)
+		assert.Equal(t, "p.X", result.Src[result.Diagnostics[0].Pos-1:result.Diagnostics[0].End-1])
+	})
+
+	t.Run("ComparedWithNil", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+type Point struct {
+	X int
+}
+
+func f(q *Point) {
+	p := q
+	if p == nil {
+		echo *p
+	}
+	if nil != p {
+		echo p.X
+	}
+}
+`)
+		assert.Equal(t, []string{"nil dereference in load"}, result.Messages())
+	})
+
+	t.Run("AssignedNil", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	fn := func() {}
+	fn = nil
+	fn()
+}
+`)
+		assert.Equal(t, []string{"nil dereference in dynamic function call"}, result.Messages())
+	})
+
+	t.Run("NotAssignedOnEveryPath", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(ok bool) {
+	var m map[string]int
+	if ok {
+		m = {}
+	}
+	m["score"] = 100
+}
+`)
+		assert.Equal(t, []string{"m may be nil here, as it is not assigned on every path"}, result.Messages())
+	})
+
+	t.Run("InterfaceInLoop", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(xs []error) {
+	var err error
+	for x <- xs {
+		echo err.Error()
+		err = x
+	}
+}
+`)
+		assert.Equal(t, []string{"err may be nil here, as it is not assigned on every path"}, result.Messages())
+	})
+
+	t.Run("InLambda", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+type Point struct {
+	X int
+}
+
+func run(fn func()) {
+	fn()
+}
+
+run => {
+	var p *Point
+	p.X = 1
+}
+`)
+		assert.Equal(t, []string{"nil dereference in field selection, as p is never assigned"}, result.Messages())
+	})
+
+	t.Run("NoDiagnostics", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+type Point struct {
+	X int
+}
+
+func (p *Point) Reset() {
+	p.X = 0
+}
+
+func assign(p **Point) {
+	*p = &Point{}
+}
+
+func f(ok bool, q *Point) {
+	var p *Point
+	if ok {
+		p = q
+	} else {
+		p = &Point{}
+	}
+	echo p.X
+
+	var r *Point
+	if r == nil {
+		r = &Point{}
+	}
+	echo r.X
+
+	var s *Point
+	s.Reset()
+
+	var t *Point
+	assign(&t)
+	echo t.X
+
+	var u *Point
+	func() {
+		u = &Point{}
+	}()
+	echo u.X
+
+	var m map[string]int
+	echo m["score"]
+	if m != nil {
+		m["score"] = 100
+	}
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
@@ -0,0 +1,903 @@
+package printf
+
+import (
+	_ "embed"
+	"fmt"
+	"go/constant"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysisutil"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/fmtstr"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/typeutil"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+func init() {
+	Analyzer.Flags.Var(isPrint, "funcs", "comma-separated list of print function names to check")
+}
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &protocol.Analyzer{
+	Name:      "printf",
+	Doc:       analysisutil.MustExtractDoc(doc, "printf"),
+	URL:       "https://pkg.go.dev/golang.org/x/tools/go/analysis/passes/printf",
+	Requires:  []*protocol.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []protocol.Fact{new(isWrapper)},
+}
+
+// kind is a kind of fmt function behavior.
+type kind int
+
+const (
+	kindNone   kind = iota // not a fmt wrapper function
+	kindPrint              // function behaves like fmt.Print
+	kindPrintf             // function behaves like fmt.Printf
+	kindErrorf             // function behaves like fmt.Errorf
+)
+
+// isWrapper is a fact indicating that a function is a print or printf wrapper.
+type isWrapper struct{ Kind kind }
+
+func (f *isWrapper) AFact() {}
+
+func (f *isWrapper) String() string {
+	switch f.Kind {
+	case kindPrintf:
+		return "printfWrapper"
+	case kindPrint:
+		return "printWrapper"
+	case kindErrorf:
+		return "errorfWrapper"
+	default:
+		return "unknownWrapper"
+	}
+}
+
+func run(pass *protocol.Pass) (any, error) {
+	findPrintfLike(pass)
+	checkCalls(pass)
+	return nil, nil
+}
+
+type printfWrapper struct {
+	obj     *types.Func
+	fdecl   *ast.FuncDecl
+	format  *types.Var
+	args    *types.Var
+	callers []printfCaller
+	failed  bool // if true, not a printf wrapper
+}
+
+type printfCaller struct {
+	w    *printfWrapper
+	call *ast.CallExpr
+}
+
+// maybePrintfWrapper decides whether decl (a declared function) may be a wrapper
+// around a fmt.Printf or fmt.Print function. If so it returns a printfWrapper
+// function describing the declaration. Later processing will analyze the
+// graph of potential printf wrappers to pick out the ones that are true wrappers.
+// A function may be a Printf or Print wrapper if its last argument is ...any.
+// If the next-to-last argument is a string, then this may be a Printf wrapper.
+// Otherwise it may be a Print wrapper.
+func maybePrintfWrapper(pass *protocol.Pass, decl ast.Decl) *printfWrapper {
+	// Look for functions with final argument type ...any.
+	fdecl, ok := decl.(*ast.FuncDecl)
+	if !ok || fdecl.Body == nil {
+		return nil
+	}
+	fn, ok := pass.TypesInfo.Defs[fdecl.Name].(*types.Func)
+	// Type information may be incomplete.
+	if !ok {
+		return nil
+	}
+
+	sig := fn.Type().(*types.Signature)
+	if !sig.Variadic() {
+		return nil // not variadic
+	}
+
+	params := sig.Params()
+	nparams := params.Len() // variadic => nonzero
+
+	// Check final parameter is "args ...any".
+	args := params.At(nparams - 1)
+	slice, ok := args.Type().(*types.Slice)
+	if !ok {
+		return nil
+	}
+	iface, ok := types.Unalias(slice.Elem()).(*types.Interface)
+	if !ok || !iface.Empty() {
+		return nil
+	}
+
+	// Is second last param 'format string'?
+	var format *types.Var
+	if nparams >= 2 {
+		if p := params.At(nparams - 2); p.Type() == types.Typ[types.String] {
+			format = p
+		}
+	}
+
+	return &printfWrapper{
+		obj:    fn,
+		fdecl:  fdecl,
+		format: format,
+		args:   args,
+	}
+}
+
+// findPrintfLike scans the entire package to find printf-like functions, and
+// exports an [isWrapper] fact for each of them.
+func findPrintfLike(pass *protocol.Pass) {
+	// Gather potential wrappers and call graph between them.
+	byObj := make(map[*types.Func]*printfWrapper)
+	var wrappers []*printfWrapper
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			w := maybePrintfWrapper(pass, decl)
+			if w == nil {
+				continue
+			}
+			byObj[w.obj] = w
+			wrappers = append(wrappers, w)
+		}
+	}
+
+	// Walk the graph to figure out which are really printf wrappers.
+	for _, w := range wrappers {
+		// Scan function for calls that could be to other printf-like functions.
+		ast.Inspect(w.fdecl.Body, func(n ast.Node) bool {
+			if w.failed {
+				return false
+			}
+
+			if assign, ok := n.(*ast.AssignStmt); ok {
+				for _, lhs := range assign.Lhs {
+					if match(pass, lhs, w.format) ||
+						match(pass, lhs, w.args) {
+						// Modifies the format
+						// string or args in
+						// some way, so not a
+						// simple wrapper.
+						w.failed = true
+						return false
+					}
+				}
+			}
+			if un, ok := n.(*ast.UnaryExpr); ok && un.Op == token.AND {
+				if match(pass, un.X, w.format) ||
+					match(pass, un.X, w.args) {
+					// Taking the address of the
+					// format string or args,
+					// so not a simple wrapper.
+					w.failed = true
+					return false
+				}
+			}
+
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 || !match(pass, call.Args[len(call.Args)-1], w.args) {
+				return true
+			}
+
+			fn, _, kind := printfNameAndKind(pass, call)
+			if kind != kindNone {
+				checkPrintfFwd(pass, w, call, kind)
+				return true
+			}
+
+			// If the call is to another function in this package,
+			// maybe we will find out it is printf-like later.
+			// Remember this call for later checking.
+			if fn != nil && fn.Pkg() == pass.Pkg && byObj[fn] != nil {
+				callee := byObj[fn]
+				callee.callers = append(callee.callers, printfCaller{w, call})
+			}
+
+			return true
+		})
+	}
+}
+
+func match(pass *protocol.Pass, arg ast.Expr, param *types.Var) bool {
+	id, ok := arg.(*ast.Ident)
+	return ok && param != nil && pass.TypesInfo.ObjectOf(id) == param
+}
+
+// checkPrintfFwd checks that a printf-forwarding wrapper is forwarding correctly.
+// It diagnoses writing fmt.Printf(format, args) instead of fmt.Printf(format, args...).
+func checkPrintfFwd(pass *protocol.Pass, w *printfWrapper, call *ast.CallExpr, kind kind) {
+	matched := kind == kindPrint ||
+		kind != kindNone && len(call.Args) >= 2 && match(pass, call.Args[len(call.Args)-2], w.format)
+	if !matched {
+		return
+	}
+
+	if !call.Ellipsis.IsValid() {
+		typ, ok := pass.TypesInfo.Types[call.Fun].Type.(*types.Signature)
+		if !ok {
+			return
+		}
+		if len(call.Args) > typ.Params().Len() {
+			// If we're passing more arguments than what the
+			// print/printf function can take, adding an ellipsis
+			// would break the program. For example:
+			//
+			//   func foo(arg1 string, arg2 ...any) {
+			//       printf "%s %v", arg1, arg2
+			//   }
+			return
+		}
+		desc := "printf"
+		if kind == kindPrint {
+			desc = "print"
+		}
+		pass.ReportRangef(call, "missing ... in args forwarded to %s-like function", desc)
+		return
+	}
+	fn := w.obj
+	var fact isWrapper
+	if !pass.ImportObjectFact(fn, &fact) {
+		fact.Kind = kind
+		pass.ExportObjectFact(fn, &fact)
+		for _, caller := range w.callers {
+			checkPrintfFwd(pass, caller.w, caller.call, kind)
+		}
+	}
+}
+
+// checkCalls triggers the print-specific checks for calls that invoke a print
+// function.
+func checkCalls(pass *protocol.Pass) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		_, name, kind := printfNameAndKind(pass, call)
+		switch kind {
+		case kindPrintf, kindErrorf:
+			checkPrintf(pass, kind, call, name)
+		case kindPrint:
+			checkPrint(pass, call, name)
+		}
+	})
+}
+
+// isPrint records the print functions.
+// If a key ends in 'f' then it is assumed to be a formatted print.
+var isPrint = stringSet{
+	"fmt.Appendf":  true,
+	"fmt.Append":   true,
+	"fmt.Appendln": true,
+	"fmt.Errorf":   true,
+	"fmt.Fprint":   true,
+	"fmt.Fprintf":  true,
+	"fmt.Fprintln": true,
+	"fmt.Print":    true,
+	"fmt.Printf":   true,
+	"fmt.Println":  true,
+	"fmt.Sprint":   true,
+	"fmt.Sprintf":  true,
+	"fmt.Sprintln": true,
+
+	"runtime/trace.Logf": true,
+
+	"log.Print":             true,
+	"log.Printf":            true,
+	"log.Println":           true,
+	"log.Fatal":             true,
+	"log.Fatalf":            true,
+	"log.Fatalln":           true,
+	"log.Panic":             true,
+	"log.Panicf":            true,
+	"log.Panicln":           true,
+	"(*log.Logger).Fatal":   true,
+	"(*log.Logger).Fatalf":  true,
+	"(*log.Logger).Fatalln": true,
+	"(*log.Logger).Panic":   true,
+	"(*log.Logger).Panicf":  true,
+	"(*log.Logger).Panicln": true,
+	"(*log.Logger).Print":   true,
+	"(*log.Logger).Printf":  true,
+	"(*log.Logger).Println": true,
+
+	"(*testing.common).Error":  true,
+	"(*testing.common).Errorf": true,
+	"(*testing.common).Fatal":  true,
+	"(*testing.common).Fatalf": true,
+	"(*testing.common).Log":    true,
+	"(*testing.common).Logf":   true,
+	"(*testing.common).Skip":   true,
+	"(*testing.common).Skipf":  true,
+	"(testing.TB).Error":       true,
+	"(testing.TB).Errorf":      true,
+	"(testing.TB).Fatal":       true,
+	"(testing.TB).Fatalf":      true,
+	"(testing.TB).Log":         true,
+	"(testing.TB).Logf":        true,
+	"(testing.TB).Skip":        true,
+	"(testing.TB).Skipf":       true,
+}
+
+// formatStringIndex returns the index of the format string (the last
+// non-variadic parameter) within the given printf-like call
+// expression, or -1 if unknown.
+func formatStringIndex(pass *protocol.Pass, call *ast.CallExpr) int {
+	typ := pass.TypesInfo.Types[call.Fun].Type
+	if typ == nil {
+		return -1 // missing type
+	}
+	sig, ok := typ.(*types.Signature)
+	if !ok {
+		return -1 // ill-typed
+	}
+	if !sig.Variadic() {
+		// Skip checking non-variadic functions.
+		return -1
+	}
+	idx := sig.Params().Len() - 2
+	if idx < 0 {
+		// Skip checking variadic functions without
+		// fixed arguments.
+		return -1
+	}
+	return idx
+}
+
+// stringConstantExpr returns expression's string constant value.
+//
+// ("", false) is returned if expression isn't a string
+// constant.
+func stringConstantExpr(pass *protocol.Pass, expr ast.Expr) (string, bool) {
+	lit := pass.TypesInfo.Types[expr].Value
+	if lit != nil && lit.Kind() == constant.String {
+		return constant.StringVal(lit), true
+	}
+	return "", false
+}
+
+// printfNameAndKind returns the function called by call, the name used in
+// diagnostics and the kind of the print function.
+//
+// For Go+ builtins such as echo and printf, which map to functions of the
+// fmt package, the name is the builtin name as written in the source. For
+// functions of the analyzed package, it is the unqualified function name.
+func printfNameAndKind(pass *protocol.Pass, call *ast.CallExpr) (fn *types.Func, name string, k kind) {
+	fn, _ = typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if fn == nil || fn.Pkg() == nil {
+		return nil, "", kindNone
+	}
+
+	// Facts are associated with generic declarations, not instantiations.
+	fn = fn.Origin()
+
+	name = fn.FullName()
+	if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name != fn.Name() {
+		name = ident.Name
+	} else if fn.Pkg() == pass.Pkg {
+		name = fn.Name()
+	}
+
+	_, ok := isPrint[fn.FullName()]
+	if !ok {
+		// Next look up just "printf", for use with -printf.funcs.
+		_, ok = isPrint[strings.ToLower(fn.Name())]
+	}
+	if ok {
+		switch {
+		case fn.FullName() == "fmt.Errorf":
+			k = kindErrorf
+		case strings.HasSuffix(fn.Name(), "f"):
+			k = kindPrintf
+		default:
+			k = kindPrint
+		}
+		return fn, name, k
+	}
+
+	var fact isWrapper
+	if pass.ImportObjectFact(fn, &fact) {
+		return fn, name, fact.Kind
+	}
+
+	return fn, name, kindNone
+}
+
+// isFormatter reports whether t could satisfy fmt.Formatter.
+// The only interface method to look for is "Format(State, rune)".
+func isFormatter(typ types.Type) bool {
+	// If the type is an interface, the value it holds might satisfy fmt.Formatter.
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		// Don't assume type parameters could be formatters. With the greater
+		// expressiveness of constraint interface syntax we expect more type safety
+		// when using type parameters.
+		if _, ok := types.Unalias(typ).(*types.TypeParam); !ok {
+			return true
+		}
+	}
+	obj, _, _ := types.LookupFieldOrMethod(typ, false, nil, "Format")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 2 &&
+		sig.Results().Len() == 0 &&
+		analysisutil.IsNamedType(sig.Params().At(0).Type(), "fmt", "State") &&
+		types.Identical(sig.Params().At(1).Type(), types.Typ[types.Rune])
+}
+
+// checkPrintf checks a call to a formatted print routine such as Printf.
+func checkPrintf(pass *protocol.Pass, kind kind, call *ast.CallExpr, name string) {
+	idx := formatStringIndex(pass, call)
+	if idx < 0 || idx >= len(call.Args) {
+		return
+	}
+	format, ok := stringConstantExpr(pass, call.Args[idx])
+	if !ok {
+		// Format string argument is non-constant. There is nothing we can check.
+		return
+	}
+
+	firstArg := idx + 1 // Arguments are immediately after format string.
+	if !strings.Contains(format, "%") {
+		if len(call.Args) > firstArg {
+			pass.ReportRangef(call, "%s call has arguments but no formatting directives", name)
+		}
+		return
+	}
+
+	// Pass the string constant value so
+	// fmt.Sprintf("%"+("s"), "hi", 3) can be reported as
+	// "fmt.Sprintf call needs 1 arg but has 2 args".
+	operations, err := fmtstr.Parse(format, idx)
+	if err != nil {
+		// All error messages are in predicate form ("call has a problem")
+		// so that they may be affixed into a subject ("log.Printf ").
+		pass.ReportRangef(call.Args[idx], "%s %s", name, err)
+		return
+	}
+
+	// index of the highest used index.
+	maxArgIndex := firstArg - 1
+	anyIndex := false
+	// Check formats against args.
+	for _, operation := range operations {
+		if operation.Prec.Index != -1 ||
+			operation.Width.Index != -1 ||
+			operation.Verb.Index != -1 {
+			anyIndex = true
+		}
+		if !okPrintfArg(pass, call, &maxArgIndex, firstArg, name, operation) {
+			// One error per format is enough.
+			return
+		}
+		if operation.Verb.Verb == 'w' {
+			switch kind {
+			case kindNone, kindPrint, kindPrintf:
+				pass.ReportRangef(call, "%s does not support error-wrapping directive %%w", name)
+				return
+			}
+		}
+	}
+	// Dotdotdot is hard.
+	if call.Ellipsis.IsValid() && maxArgIndex >= len(call.Args)-2 {
+		return
+	}
+	// If any formats are indexed, extra arguments are ignored.
+	if anyIndex {
+		return
+	}
+	// There should be no leftover arguments.
+	if maxArgIndex+1 < len(call.Args) {
+		expect := maxArgIndex + 1 - firstArg
+		numArgs := len(call.Args) - firstArg
+		pass.ReportRangef(call, "%s call needs %v but has %v", name, count(expect, "arg"), count(numArgs, "arg"))
+	}
+}
+
+// printfArgType encodes the types of expressions a printf verb accepts. It is a bitmask.
+type printfArgType int
+
+const (
+	argBool printfArgType = 1 << iota
+	argInt
+	argRune
+	argString
+	argFloat
+	argComplex
+	argPointer
+	argError
+	anyType printfArgType = ^0
+)
+
+type printVerb struct {
+	verb  rune   // User may provide verb through Formatter; could be a rune.
+	flags string // known flags are all ASCII
+	typ   printfArgType
+}
+
+// Common flag sets for printf verbs.
+const (
+	noFlag       = ""
+	numFlag      = " -+.0"
+	sharpNumFlag = " -+.0#"
+	allFlags     = " -+.0#"
+)
+
+// printVerbs identifies which flags are known to printf for each verb.
+var printVerbs = []printVerb{
+	// '-' is a width modifier, always valid.
+	// '.' is a precision for float, max width for strings.
+	// '+' is required sign for numbers, Go format for %v.
+	// '#' is alternate format for several verbs.
+	// ' ' is spacer for numbers
+	{'%', noFlag, 0},
+	{'b', sharpNumFlag, argInt | argFloat | argComplex | argPointer},
+	{'c', "-", argRune | argInt},
+	{'d', numFlag, argInt | argPointer},
+	{'e', sharpNumFlag, argFloat | argComplex},
+	{'E', sharpNumFlag, argFloat | argComplex},
+	{'f', sharpNumFlag, argFloat | argComplex},
+	{'F', sharpNumFlag, argFloat | argComplex},
+	{'g', sharpNumFlag, argFloat | argComplex},
+	{'G', sharpNumFlag, argFloat | argComplex},
+	{'o', sharpNumFlag, argInt | argPointer},
+	{'O', sharpNumFlag, argInt | argPointer},
+	{'p', "-#", argPointer},
+	{'q', " -+.0#", argRune | argInt | argString},
+	{'s', " -+.0", argString},
+	{'t', "-", argBool},
+	{'T', "-", anyType},
+	{'U', "-#", argRune | argInt},
+	{'v', allFlags, anyType},
+	{'w', allFlags, argError},
+	{'x', sharpNumFlag, argRune | argInt | argString | argPointer | argFloat | argComplex},
+	{'X', sharpNumFlag, argRune | argInt | argString | argPointer | argFloat | argComplex},
+}
+
+// okPrintfArg compares the operation to the arguments actually present,
+// reporting any discrepancies it can discern, maxArgIndex was the index of the highest used index.
+// If the final argument is ellipsissed, there's little it can do for that.
+func okPrintfArg(pass *protocol.Pass, call *ast.CallExpr, maxArgIndex *int, firstArg int, name string, operation *fmtstr.Operation) (ok bool) {
+	verb := operation.Verb.Verb
+	var v printVerb
+	found := false
+	// Linear scan is fast enough for a small list.
+	for _, v = range printVerbs {
+		if v.verb == verb {
+			found = true
+			break
+		}
+	}
+
+	// Could verb's arg implement fmt.Formatter?
+	// Skip check for the %w verb, which requires an error.
+	formatter := false
+	if v.typ != argError && operation.Verb.ArgIndex < len(call.Args) {
+		if tv, ok := pass.TypesInfo.Types[call.Args[operation.Verb.ArgIndex]]; ok && tv.Type != nil {
+			formatter = isFormatter(tv.Type)
+		}
+	}
+
+	if !formatter {
+		if !found {
+			pass.ReportRangef(call, "%s format %s has unknown verb %c", name, operation.Text, verb)
+			return false
+		}
+		for _, flag := range operation.Flags {
+			// TODO: Disable complaint about '0' for Go 1.10. To be fixed properly in 1.11.
+			// See issues 23598 and 23605.
+			if flag == '0' {
+				continue
+			}
+			if !strings.ContainsRune(v.flags, rune(flag)) {
+				pass.ReportRangef(call, "%s format %s has unrecognized flag %c", name, operation.Text, flag)
+				return false
+			}
+		}
+	}
+
+	var argIndexes []int
+	// First check for *.
+	if operation.Width.Dynamic != -1 {
+		argIndexes = append(argIndexes, operation.Width.Dynamic)
+	}
+	if operation.Prec.Dynamic != -1 {
+		argIndexes = append(argIndexes, operation.Prec.Dynamic)
+	}
+	// If len(argIndexes)>0, we have something like %.*s and all
+	// indexes in argIndexes must be an integer.
+	for _, argIndex := range argIndexes {
+		if !argCanBeChecked(pass, call, argIndex, firstArg, operation, name) {
+			return
+		}
+		arg := call.Args[argIndex]
+		if reason, ok := matchArgType(pass, argInt, arg); !ok {
+			details := ""
+			if reason != "" {
+				details = " (" + reason + ")"
+			}
+			pass.ReportRangef(call, "%s format %s uses non-int %s%s as argument of *", name, operation.Text, analysisutil.Format(pass.Fset, arg), details)
+			return false
+		}
+	}
+
+	// Collect to update maxArgNum in one loop.
+	if operation.Verb.ArgIndex != -1 && verb != '%' {
+		argIndexes = append(argIndexes, operation.Verb.ArgIndex)
+	}
+	for _, index := range argIndexes {
+		*maxArgIndex = max(*maxArgIndex, index)
+	}
+
+	// Special case for '%', go will print "fmt.Printf("%10.2%%dhello", 4)"
+	// as "%4hello", discard any runes between the two '%'s, and treat the verb '%'
+	// as an ordinary rune, so early return to skip the type check.
+	if verb == '%' || formatter {
+		return true
+	}
+
+	// Now check verb's type.
+	verbArgIndex := operation.Verb.ArgIndex
+	if !argCanBeChecked(pass, call, verbArgIndex, firstArg, operation, name) {
+		return false
+	}
+	arg := call.Args[verbArgIndex]
+	if isFunctionValue(pass, arg) && verb != 'p' && verb != 'T' {
+		pass.ReportRangef(call, "%s format %s arg %s is a func value, not called", name, operation.Text, analysisutil.Format(pass.Fset, arg))
+		return false
+	}
+	if reason, ok := matchArgType(pass, v.typ, arg); !ok {
+		typeString := ""
+		if typ := pass.TypesInfo.Types[arg].Type; typ != nil {
+			typeString = typ.String()
+		}
+		details := ""
+		if reason != "" {
+			details = " (" + reason + ")"
+		}
+		pass.ReportRangef(call, "%s format %s has arg %s of wrong type %s%s", name, operation.Text, analysisutil.Format(pass.Fset, arg), typeString, details)
+		return false
+	}
+	if v.typ&argString != 0 && v.verb != 'T' && !strings.Contains(operation.Flags, "#") {
+		if methodName, ok := recursiveStringer(pass, arg); ok {
+			pass.ReportRangef(call, "%s format %s with arg %s causes recursive %s method call", name, operation.Text, analysisutil.Format(pass.Fset, arg), methodName)
+			return false
+		}
+	}
+	return true
+}
+
+// recursiveStringer reports whether the argument e is a potential
+// recursive call to stringer or is an error, such as t and &t in these examples:
+//
+//	func (t *T) String() string { printf("%s",  t) }
+//	func (t  T) Error() string { printf("%s",  t) }
+//	func (t  T) String() string { printf("%s", &t) }
+func recursiveStringer(pass *protocol.Pass, e ast.Expr) (string, bool) {
+	typ := pass.TypesInfo.Types[e].Type
+	if typ == nil {
+		return "", false
+	}
+
+	// It's unlikely to be a recursive stringer if it has a Format method.
+	if isFormatter(typ) {
+		return "", false
+	}
+
+	// Does e allow e.String() or e.Error()?
+	strObj, _, _ := types.LookupFieldOrMethod(typ, false, pass.Pkg, "String")
+	strMethod, strOk := strObj.(*types.Func)
+	errObj, _, _ := types.LookupFieldOrMethod(typ, false, pass.Pkg, "Error")
+	errMethod, errOk := errObj.(*types.Func)
+	if !strOk && !errOk {
+		return "", false
+	}
+
+	// inScope returns true if e is in the scope of f.
+	inScope := func(e ast.Expr, f *types.Func) bool {
+		return f.Scope() != nil && f.Scope().Contains(e.Pos())
+	}
+
+	// Is the expression e within the body of that String or Error method?
+	var method *types.Func
+	if strOk && strMethod.Pkg() == pass.Pkg && inScope(e, strMethod) {
+		method = strMethod
+	} else if errOk && errMethod.Pkg() == pass.Pkg && inScope(e, errMethod) {
+		method = errMethod
+	} else {
+		return "", false
+	}
+
+	sig := method.Type().(*types.Signature)
+	if !isStringer(sig) {
+		return "", false
+	}
+
+	// Is it the receiver r, or &r?
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		e = u.X // strip off & from &r
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		if pass.TypesInfo.Uses[id] == sig.Recv() {
+			return method.FullName(), true
+		}
+	}
+	return "", false
+}
+
+// isStringer reports whether the method signature matches the String() definition in fmt.Stringer.
+func isStringer(sig *types.Signature) bool {
+	return sig.Params().Len() == 0 &&
+		sig.Results().Len() == 1 &&
+		sig.Results().At(0).Type() == types.Typ[types.String]
+}
+
+// isFunctionValue reports whether the expression is a function as opposed to a function call.
+// It is almost always a mistake to print a function value.
+func isFunctionValue(pass *protocol.Pass, e ast.Expr) bool {
+	if typ := pass.TypesInfo.Types[e].Type; typ != nil {
+		// Don't call Underlying: a named func type with a String method is ok.
+		_, ok := typ.(*types.Signature)
+		return ok
+	}
+	return false
+}
+
+// argCanBeChecked reports whether the specified argument is statically present;
+// it may be beyond the list of arguments or in a terminal slice... argument, which
+// means we can't see it.
+func argCanBeChecked(pass *protocol.Pass, call *ast.CallExpr, argIndex, firstArg int, operation *fmtstr.Operation, name string) bool {
+	if argIndex <= 0 {
+		// Shouldn't happen, so catch it with prejudice.
+		panic("negative argIndex")
+	}
+	if argIndex < len(call.Args)-1 {
+		return true // Always OK.
+	}
+	if call.Ellipsis.IsValid() {
+		return false // We just can't tell; there could be many more arguments.
+	}
+	if argIndex < len(call.Args) {
+		return true
+	}
+	// There are bad indexes in the format or there are fewer arguments than the format needs.
+	// This is the argument number relative to the format: Printf("%s", "hi") will give 1 for the "hi".
+	arg := argIndex - firstArg + 1 // People think of arguments as 1-indexed.
+	pass.ReportRangef(call, "%s format %s reads arg #%d, but call has %v", name, operation.Text, arg, count(len(call.Args)-firstArg, "arg"))
+	return false
+}
+
+// printFormatRE is the regexp we match and report as a possible format string
+// in the first argument to unformatted prints like fmt.Print.
+// We exclude the space flag, so that printing a string like "x % y" is not reported as a format.
+var printFormatRE = regexp.MustCompile(`%` + flagsRE + numOptRE + `\.?` + numOptRE + indexOptRE + verbRE)
+
+const (
+	flagsRE    = `[+\-#]*`
+	indexOptRE = `(\[[0-9]+\])?`
+	numOptRE   = `([0-9]+|` + indexOptRE + `\*)?`
+	verbRE     = `[bcdefgopqstvxEFGTUX]`
+)
+
+// checkPrint checks a call to an unformatted print routine such as Println.
+func checkPrint(pass *protocol.Pass, call *ast.CallExpr, name string) {
+	firstArg := 0
+	typ := pass.TypesInfo.Types[call.Fun].Type
+	if typ == nil {
+		// Skip checking functions with unknown type.
+		return
+	}
+	if sig, ok := typ.Underlying().(*types.Signature); ok {
+		if !sig.Variadic() {
+			// Skip checking non-variadic functions.
+			return
+		}
+		params := sig.Params()
+		firstArg = params.Len() - 1
+
+		typ := params.At(firstArg).Type()
+		typ = typ.(*types.Slice).Elem()
+		it, ok := types.Unalias(typ).(*types.Interface)
+		if !ok || !it.Empty() {
+			// Skip variadic functions accepting non-interface{} args.
+			return
+		}
+	}
+	args := call.Args
+	if len(args) <= firstArg {
+		// Skip calls without variadic args.
+		return
+	}
+	args = args[firstArg:]
+
+	if firstArg == 0 {
+		if sel, ok := call.Args[0].(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if x.Name == "os" && strings.HasPrefix(sel.Sel.Name, "Std") {
+					pass.ReportRangef(call, "%s does not take io.Writer but has first arg %s", name, analysisutil.Format(pass.Fset, call.Args[0]))
+				}
+			}
+		}
+	}
+
+	arg := args[0]
+	if s, ok := stringConstantExpr(pass, arg); ok {
+		// Ignore trailing % character
+		// The % in "abc 0.0%" couldn't be a formatting directive.
+		s = strings.TrimSuffix(s, "%")
+		if strings.Contains(s, "%") {
+			m := printFormatRE.FindStringSubmatch(s)
+			if m != nil {
+				pass.ReportRangef(call, "%s call has possible Printf formatting directive %s", name, m[0])
+			}
+		}
+	}
+	if strings.HasSuffix(name, "ln") || name == "echo" {
+		// The last item, if a string, should not have a newline.
+		arg = args[len(args)-1]
+		if s, ok := stringConstantExpr(pass, arg); ok {
+			if strings.HasSuffix(s, "\n") {
+				pass.ReportRangef(call, "%s arg list ends with redundant newline", name)
+			}
+		}
+	}
+	for _, arg := range args {
+		if isFunctionValue(pass, arg) {
+			pass.ReportRangef(call, "%s arg %s is a func value, not called", name, analysisutil.Format(pass.Fset, arg))
+		}
+		if methodName, ok := recursiveStringer(pass, arg); ok {
+			pass.ReportRangef(call, "%s arg %s causes recursive call to %s method", name, analysisutil.Format(pass.Fset, arg), methodName)
+		}
+	}
+}
+
+// count(n, what) returns "1 what" or "N whats"
+// (assuming the plural of what is whats).
+func count(n int, what string) string {
+	if n == 1 {
+		return "1 " + what
+	}
+	return fmt.Sprintf("%d %ss", n, what)
+}
+
+// stringSet is a set-of-nonempty-strings-valued flag.
+// Note: elements without a '.' get lower-cased.
+type stringSet map[string]bool
+
+func (ss stringSet) String() string {
+	var list []string
+	for name := range ss {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func (ss stringSet) Set(flag string) error {
+	for _, name := range strings.Split(flag, ",") {
+		if len(name) == 0 {
+			return fmt.Errorf("empty string")
+		}
+		if !strings.Contains(name, ".") {
+			name = strings.ToLower(name)
+		}
+		ss[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package printf
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintf(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "WrongArgType",
+			src: `
+import "fmt"
+
+fmt.Printf("%d\n", "hello")
+`,
+			want: []string{`fmt.Printf format %d has arg "hello" of wrong type untyped string`},
+		},
+		{
+			name: "GopBuiltinWrongArgType",
+			src: `
+printf "%d\n", "hello"
+`,
+			want: []string{`printf format %d has arg "hello" of wrong type untyped string`},
+		},
+		{
+			name: "MissingArg",
+			src: `
+s := sprintf("%d %s", 1)
+echo s
+`,
+			want: []string{"sprintf format %s reads arg #2, but call has 1 arg"},
+		},
+		{
+			name: "ExtraArg",
+			src: `
+printf "%d\n", 1, 2
+`,
+			want: []string{"printf call needs 1 arg but has 2 args"},
+		},
+		{
+			name: "NoDirectives",
+			src: `
+printf "hello\n", 1
+`,
+			want: []string{"printf call has arguments but no formatting directives"},
+		},
+		{
+			name: "BadFormat",
+			src: `
+printf "%[0]d\n", 1
+`,
+			want: []string{"printf format has invalid argument index [0]"},
+		},
+		{
+			name: "WrapDirective",
+			src: `
+import "errors"
+
+err := errors.New("oops")
+printf "%w\n", err
+e := errorf("failed: %w", err)
+echo e
+`,
+			want: []string{"printf does not support error-wrapping directive %w"},
+		},
+		{
+			name: "PrintWithDirective",
+			src: `
+echo "%d", 123
+`,
+			want: []string{"echo call has possible Printf formatting directive %d"},
+		},
+		{
+			name: "PrintlnRedundantNewline",
+			src: `
+echo "hello\n"
+`,
+			want: []string{"echo arg list ends with redundant newline"},
+		},
+		{
+			name: "FuncValue",
+			src: `
+func f() int { return 1 }
+
+echo f
+`,
+			want: []string{"echo arg f is a func value, not called"},
+		},
+		{
+			name: "Wrapper",
+			src: `
+func logf(level int, format string, args ...any) {
+	if level > 0 {
+		printf format, args...
+	}
+}
+
+func warnf(format string, args ...any) {
+	logf 1, format, args...
+}
+
+logf 3, "invalid request: %v"
+warnf "%d", "hello"
+`,
+			want: []string{
+				"logf format %v reads arg #1, but call has 0 args",
+				`warnf format %d has arg "hello" of wrong type untyped string`,
+			},
+		},
+		{
+			name: "WrapperMissingEllipsis",
+			src: `
+func logf(format string, args ...any) {
+	printf format, args
+}
+`,
+			want: []string{"missing ... in args forwarded to printf-like function"},
+		},
+		{
+			name: "Valid",
+			src: `
+import "fmt"
+
+name := "world"
+printf "hello %s %d %v %5.2f%%\n", name, 1, []int{1}, 3.14
+fmt.Println(fmt.Sprintf("%[1]s %[1]q", name))
+echo "hello", name
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result := analysistest.Run(t, Analyzer, tt.src)
+			if len(tt.want) == 0 {
+				assert.Empty(t, result.Diagnostics)
+				return
+			}
+			assert.Equal(t, tt.want, result.Messages())
+		})
+	}
+}
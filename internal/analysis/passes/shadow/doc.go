@@ -0,0 +1,26 @@
+// Package shadow defines an Analyzer that checks for shadowed variables.
+//
+// # Analyzer shadow
+//
+// shadow: check for possible unintended shadowing of variables
+//
+// This analyzer check for shadowed variables.
+// A shadowed variable is a variable declared in an inner scope
+// with the same name and type as a variable in an outer scope,
+// and where the outer variable is mentioned after the inner one
+// is declared.
+//
+// (This definition can be refined; the module generates too many
+// false positives and is not yet enabled by default.)
+//
+// For example:
+//
+//	func total(scores []int) int {
+//		sum := 0
+//		for score <- scores {
+//			sum := sum + score // shadows the function variable 'sum'
+//			echo sum
+//		}
+//		return sum
+//	}
+package shadow
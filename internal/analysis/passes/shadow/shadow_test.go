@@ -0,0 +1,80 @@
+package shadow
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadow(t *testing.T) {
+	t.Run("ShortVarDecl", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func total(scores []int) int {
+	sum := 0
+	for score <- scores {
+		sum := sum + score
+		echo sum
+	}
+	return sum
+}
+`)
+		assert.Equal(t, []string{`declaration of "sum" shadows declaration at line 3`}, result.Messages())
+	})
+
+	t.Run("VarDecl", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() string {
+	s := "a"
+	if true {
+		var s = "b"
+		echo s
+	}
+	return s
+}
+`)
+		assert.Equal(t, []string{`declaration of "s" shadows declaration at line 3`}, result.Messages())
+	})
+
+	t.Run("IdiomaticRedecl", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() int {
+	n := 1
+	if true {
+		n := n
+		echo n
+	}
+	return n
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+
+	t.Run("DifferentType", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() int {
+	n := 1
+	if true {
+		n := "one"
+		echo n
+	}
+	return n
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+
+	t.Run("NotUsedAfter", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	n := 1
+	echo n
+	if true {
+		n := 2
+		echo n
+	}
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
@@ -0,0 +1,96 @@
+package unreachable
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnreachable(t *testing.T) {
+	t.Run("AfterReturn", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() int {
+	return 1
+	echo "dead"
+}
+`)
+		require.Len(t, result.Diagnostics, 1)
+		assert.Equal(t, "unreachable code", result.Diagnostics[0].Message)
+		require.Len(t, result.Diagnostics[0].SuggestedFixes, 1)
+		assert.Equal(t, `
+func f() int {
+	return 1
+	
+}
+`, result.ApplyFix(result.Diagnostics[0].SuggestedFixes[0]))
+	})
+
+	t.Run("AfterPanicCommand", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	panic "oops"
+	echo "dead"
+	echo "also dead"
+}
+`)
+		assert.Equal(t, []string{"unreachable code"}, result.Messages())
+	})
+
+	t.Run("InLambda", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func run(fn func()) {
+	fn()
+}
+
+run => {
+	return
+	echo "dead"
+}
+`)
+		assert.Equal(t, []string{"unreachable code"}, result.Messages())
+	})
+
+	t.Run("AfterForPhraseLoop", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	for x <- [1, 2, 3] {
+		echo x
+		continue
+		echo "dead"
+	}
+	echo "reachable"
+}
+`)
+		assert.Equal(t, []string{"unreachable code"}, result.Messages())
+	})
+
+	t.Run("AfterInfiniteLoop", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f() {
+	for {
+	}
+	echo "dead"
+}
+`)
+		assert.Equal(t, []string{"unreachable code"}, result.Messages())
+	})
+
+	t.Run("Reachable", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+func f(ok bool) int {
+	if ok {
+		return 1
+	}
+	for {
+		if ok {
+			break
+		}
+	}
+	return 0
+}
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
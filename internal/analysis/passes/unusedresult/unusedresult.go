@@ -0,0 +1,143 @@
+package unusedresult
+
+// It is tempting to make this analysis inductive: for each function
+// that tail-calls one of the functions that we check, check those
+// functions too. However, just because you must use the result of
+// fmt.Sprintf doesn't mean you need to use the result of every
+// function that returns a formatted string: it may have other results
+// and effects.
+
+import (
+	_ "embed"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/analysis/ast/astutil"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysisutil"
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/typeutil"
+	"github.com/goplus/goxlsw/internal/analysis/protocol"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &protocol.Analyzer{
+	Name:     "unusedresult",
+	Doc:      analysisutil.MustExtractDoc(doc, "unusedresult"),
+	URL:      "https://pkg.go.dev/golang.org/x/tools/go/analysis/passes/unusedresult",
+	Requires: []*protocol.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// flags
+var funcs, stringMethods stringSetFlag
+
+func init() {
+	// List standard library functions here.
+	// The context.With{Cancel,Deadline,Timeout} entries are
+	// effectively redundant wrt the lostcancel analyzer.
+	funcs = stringSetFlag{
+		"context.WithCancel":   true,
+		"context.WithDeadline": true,
+		"context.WithTimeout":  true,
+		"context.WithValue":    true,
+		"errors.New":           true,
+		"fmt.Errorf":           true,
+		"fmt.Sprint":           true,
+		"fmt.Sprintf":          true,
+		"slices.Clip":          true,
+		"slices.Compact":       true,
+		"slices.CompactFunc":   true,
+		"slices.Delete":        true,
+		"slices.DeleteFunc":    true,
+		"slices.Grow":          true,
+		"slices.Insert":        true,
+		"slices.Replace":       true,
+		"sort.Reverse":         true,
+	}
+	Analyzer.Flags.Var(&funcs, "funcs",
+		"comma-separated list of functions whose results must be used")
+
+	stringMethods.Set("Error,String")
+	Analyzer.Flags.Var(&stringMethods, "stringmethods",
+		"comma-separated list of names of methods of type func() string whose results must be used")
+}
+
+func run(pass *protocol.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// Split functions into (pkg, name) pairs to save allocation later.
+	pkgFuncs := make(map[[2]string]bool, len(funcs))
+	for s := range funcs {
+		if i := strings.LastIndexByte(s, '.'); i > 0 {
+			pkgFuncs[[2]string{s[:i], s[i+1:]}] = true
+		}
+	}
+
+	nodeFilter := []ast.Node{
+		(*ast.ExprStmt)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call, ok := astutil.Unparen(n.(*ast.ExprStmt).X).(*ast.CallExpr)
+		if !ok {
+			return // not a call statement
+		}
+
+		// Call to function or method?
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil {
+			return // e.g. var or builtin
+		}
+		if sig := fn.Type().(*types.Signature); sig.Recv() != nil {
+			// method (e.g. foo.String())
+			if types.Identical(sig, sigNoArgsStringResult) {
+				if stringMethods[fn.Name()] {
+					pass.ReportRangef(call, "result of (%s).%s call not used",
+						sig.Recv().Type(), fn.Name())
+				}
+			}
+		} else {
+			// package-level function (e.g. fmt.Errorf)
+			if pkgFuncs[[2]string{fn.Pkg().Path(), fn.Name()}] {
+				pass.ReportRangef(call, "result of %s.%s call not used",
+					fn.Pkg().Path(), fn.Name())
+			}
+		}
+	})
+	return nil, nil
+}
+
+// func() string
+var sigNoArgsStringResult = types.NewSignature(nil, nil,
+	types.NewTuple(types.NewParam(token.NoPos, nil, "", types.Typ[types.String])),
+	false)
+
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	m := make(map[string]bool) // clobber previous value
+	if s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name == "" {
+				continue
+			}
+			m[name] = true
+		}
+	}
+	*ss = m
+	return nil
+}
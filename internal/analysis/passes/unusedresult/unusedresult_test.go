@@ -0,0 +1,53 @@
+package unusedresult
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/analysis/passes/internal/analysistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnusedResult(t *testing.T) {
+	t.Run("PackageFunc", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "fmt"
+
+fmt.Sprintf("%d", 1)
+`)
+		assert.Equal(t, []string{"result of fmt.Sprintf call not used"}, result.Messages())
+	})
+
+	t.Run("GopBuiltin", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+sprintf "%d", 1
+errorf "failed: %d", 1
+`)
+		assert.Equal(t, []string{
+			"result of fmt.Sprintf call not used",
+			"result of fmt.Errorf call not used",
+		}, result.Messages())
+	})
+
+	t.Run("StringMethod", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+type T struct{}
+
+func (T) String() string { return "T" }
+
+var t T
+t.String()
+`)
+		assert.Equal(t, []string{"result of (test.T).String call not used"}, result.Messages())
+	})
+
+	t.Run("ResultUsed", func(t *testing.T) {
+		result := analysistest.Run(t, Analyzer, `
+import "fmt"
+
+s := fmt.Sprintf("%d", 1)
+echo s
+fmt.Println(s)
+`)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"bytes"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"slices"
 	"strings"
 	"sync"
 
@@ -24,69 +26,91 @@ const (
 	pkgDocSuffix    = ".pkgdoc"
 )
 
-// ListPkgs lists all packages in the pkgdata.zip file.
-func ListPkgs() ([]string, error) {
+// index is the index of the files in the pkgdata.zip file, which is built only
+// once as the file is embedded.
+var index = sync.OnceValues(func() (map[string]*zip.File, error) {
 	zr, err := zip.NewReader(bytes.NewReader(pkgdataZip), int64(len(pkgdataZip)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zip reader: %w", err)
 	}
-	pkgs := make([]string, 0, len(zr.File)/2)
+	files := make(map[string]*zip.File, len(zr.File))
 	for _, f := range zr.File {
-		if strings.HasSuffix(f.Name, pkgExportSuffix) {
-			pkgs = append(pkgs, strings.TrimSuffix(f.Name, pkgExportSuffix))
+		files[f.Name] = f
+	}
+	return files, nil
+})
+
+// ListPkgs lists all packages in the pkgdata.zip file.
+func ListPkgs() ([]string, error) {
+	files, err := index()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]string, 0, len(files)/2)
+	for name := range files {
+		if strings.HasSuffix(name, pkgExportSuffix) {
+			pkgs = append(pkgs, strings.TrimSuffix(name, pkgExportSuffix))
 		}
 	}
+	slices.Sort(pkgs)
 	return pkgs, nil
 }
 
 // OpenExport opens a package export file.
 func OpenExport(pkgPath string) (io.ReadCloser, error) {
-	zr, err := zip.NewReader(bytes.NewReader(pkgdataZip), int64(len(pkgdataZip)))
+	files, err := index()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+		return nil, err
 	}
-	pkgExportFile := pkgPath + pkgExportSuffix
-	for _, f := range zr.File {
-		if f.Name == pkgExportFile {
-			return f.Open()
-		}
+	f, ok := files[pkgPath+pkgExportSuffix]
+	if !ok {
+		return nil, fmt.Errorf("failed to find export file for package %q: %w", pkgPath, fs.ErrNotExist)
 	}
-	return nil, fmt.Errorf("failed to find export file for package %q: %w", pkgPath, fs.ErrNotExist)
+	return f.Open()
 }
 
 // pkgDocCache is a cache for package documentation.
 var pkgDocCache sync.Map // map[string]*pkgdoc.PkgDoc
 
 // GetPkgDoc gets the documentation for a package.
-func GetPkgDoc(pkgPath string) (pkgDoc *pkgdoc.PkgDoc, err error) {
+func GetPkgDoc(pkgPath string) (*pkgdoc.PkgDoc, error) {
 	if pkgDocIface, ok := pkgDocCache.Load(pkgPath); ok {
 		return pkgDocIface.(*pkgdoc.PkgDoc), nil
 	}
-	defer func() {
-		if err == nil {
-			pkgDocCache.Store(pkgPath, pkgDoc)
-		}
-	}()
-
-	zr, err := zip.NewReader(bytes.NewReader(pkgdataZip), int64(len(pkgdataZip)))
+	files, err := index()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+		return nil, err
 	}
-	pkgDocFile := pkgPath + pkgDocSuffix
-	for _, f := range zr.File {
-		if f.Name == pkgDocFile {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open doc file for package %q: %w", pkgPath, err)
-			}
-			defer rc.Close()
+	f, ok := files[pkgPath+pkgDocSuffix]
+	if !ok {
+		return nil, fmt.Errorf("failed to find doc file for package %q: %w", pkgPath, fs.ErrNotExist)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open doc file for package %q: %w", pkgPath, err)
+	}
+	defer rc.Close()
+
+	var pkgDoc pkgdoc.PkgDoc
+	if err := json.NewDecoder(rc).Decode(&pkgDoc); err != nil {
+		return nil, fmt.Errorf("failed to decode doc for package %q: %w", pkgPath, err)
+	}
+	pkgDocIface, _ := pkgDocCache.LoadOrStore(pkgPath, &pkgDoc)
+	return pkgDocIface.(*pkgdoc.PkgDoc), nil
+}
 
-			var pkgDoc pkgdoc.PkgDoc
-			if err := json.NewDecoder(rc).Decode(&pkgDoc); err != nil {
-				return nil, fmt.Errorf("failed to decode doc for package %q: %w", pkgPath, err)
-			}
-			return &pkgDoc, nil
+// LoadPkgDocs loads the documentation for all packages in the pkgdata.zip
+// file into memory, so that later lookups, e.g., for hover and completion, do
+// not pay for decoding. It is safe to call it more than once.
+func LoadPkgDocs() error {
+	pkgs, err := ListPkgs()
+	if err != nil {
+		return err
+	}
+	for _, pkgPath := range pkgs {
+		if _, err := GetPkgDoc(pkgPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
 		}
 	}
-	return nil, fmt.Errorf("failed to find doc file for package %q: %w", pkgPath, fs.ErrNotExist)
+	return nil
 }
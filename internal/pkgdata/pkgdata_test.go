@@ -0,0 +1,33 @@
+package pkgdata
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPkgData(t *testing.T) {
+	pkgs, err := ListPkgs()
+	require.NoError(t, err)
+	assert.Contains(t, pkgs, "fmt")
+	assert.Contains(t, pkgs, "github.com/goplus/spx")
+	assert.IsIncreasing(t, pkgs)
+
+	rc, err := OpenExport("fmt")
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	_, err = OpenExport("unknown")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, LoadPkgDocs())
+	pkgDoc, err := GetPkgDoc("github.com/goplus/spx")
+	require.NoError(t, err)
+	assert.Equal(t, "spx", pkgDoc.Name)
+	again, err := GetPkgDoc("github.com/goplus/spx")
+	require.NoError(t, err)
+	assert.Same(t, pkgDoc, again)
+	_, err = GetPkgDoc("unknown")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
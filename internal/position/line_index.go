@@ -0,0 +1,95 @@
+package position
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/goplus/goxlsw/protocol"
+)
+
+// LineIndex is an index of the lines of a document, which converts between
+// UTF-8 byte offsets and positions in time logarithmic in the number of lines,
+// unlike [Encoding.Position] and [Encoding.Offset], which scan the document.
+// It is meant to be built once per document content and shared, e.g., cached
+// with the file it indexes.
+type LineIndex struct {
+	content    []byte
+	lineStarts []int // byte offsets of the starts of lines
+}
+
+// NewLineIndex returns the line index of content, which must not be modified
+// afterwards.
+func NewLineIndex(content []byte) *LineIndex {
+	lineStarts := make([]int, 1, bytes.Count(content, []byte{'\n'})+1)
+	for i, c := range content {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return &LineIndex{content: content, lineStarts: lineStarts}
+}
+
+// LineCount returns the number of lines, including the empty line after a
+// trailing newline.
+func (idx *LineIndex) LineCount() int {
+	return len(idx.lineStarts)
+}
+
+// LineStart returns the UTF-8 byte offset of the start of the zero-based line.
+// Lines beyond the end of the document start at its end.
+func (idx *LineIndex) LineStart(line int) int {
+	if line >= len(idx.lineStarts) {
+		return len(idx.content)
+	}
+	return idx.lineStarts[max(line, 0)]
+}
+
+// Line returns the content of the zero-based line, without its newline. It
+// returns nil if the line is out of the document.
+func (idx *LineIndex) Line(line int) []byte {
+	if line < 0 || line >= len(idx.lineStarts) {
+		return nil
+	}
+	start := idx.lineStarts[line]
+	end := len(idx.content)
+	if line+1 < len(idx.lineStarts) {
+		end = idx.lineStarts[line+1] - 1
+	}
+	return idx.content[start:end]
+}
+
+// Position converts the UTF-8 byte offset in the document to a position in
+// enc, like [Encoding.Position].
+func (idx *LineIndex) Position(enc Encoding, offset int) protocol.Position {
+	offset = min(max(offset, 0), len(idx.content))
+	line := sort.SearchInts(idx.lineStarts, offset+1) - 1
+	lineStart := idx.lineStarts[line]
+	return protocol.Position{
+		Line:      uint32(line),
+		Character: uint32(enc.FromUTF8(idx.Line(line), offset-lineStart)),
+	}
+}
+
+// LinePosition converts the zero-based line and the UTF-8 byte offset in it to
+// a position in enc. Like the columns of token files, the offset may go past
+// the end of the line, e.g., to the end of a document ending with a newline,
+// and the position is then still on the line.
+func (idx *LineIndex) LinePosition(enc Encoding, line, offset int) protocol.Position {
+	start := idx.LineStart(line)
+	end := min(start+max(offset, 0), len(idx.content))
+	return protocol.Position{
+		Line:      uint32(max(line, 0)),
+		Character: uint32(enc.FromUTF8(idx.content[start:end], end-start)),
+	}
+}
+
+// Offset converts the position in enc in the document to a UTF-8 byte offset,
+// like [Encoding.Offset]. Positions beyond the end of a line or the document
+// are clamped to it.
+func (idx *LineIndex) Offset(enc Encoding, pos protocol.Position) int {
+	line := int(pos.Line)
+	if line >= len(idx.lineStarts) {
+		return len(idx.content)
+	}
+	return idx.lineStarts[line] + enc.ToUTF8(idx.Line(line), int(pos.Character))
+}
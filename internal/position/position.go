@@ -0,0 +1,128 @@
+// Package position converts between UTF-8 byte offsets in documents and LSP
+// positions, whose character offsets are counted in the position encoding
+// negotiated with the client.
+package position
+
+import (
+	"bytes"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/goplus/goxlsw/protocol"
+)
+
+// Encoding is a position encoding, i.e., the unit in which the character
+// offsets of positions are counted.
+type Encoding string
+
+const (
+	UTF8  Encoding = "utf-8"  // bytes
+	UTF16 Encoding = "utf-16" // UTF-16 code units, the default
+	UTF32 Encoding = "utf-32" // Unicode code points
+)
+
+// Negotiate returns the encoding to use with a client that supports the given
+// encodings. It prefers UTF-8, which needs no conversion, then UTF-16, which
+// every client supports and is the default.
+func Negotiate(clientEncodings []protocol.PositionEncodingKind) Encoding {
+	if slices.Contains(clientEncodings, protocol.UTF8) {
+		return UTF8
+	}
+	return UTF16
+}
+
+// runeLen returns the number of units of r in enc.
+func (enc Encoding) runeLen(r rune) int {
+	switch enc {
+	case UTF8:
+		return utf8.RuneLen(r)
+	case UTF32:
+		return 1
+	}
+	if n := utf16.RuneLen(r); n > 0 {
+		return n
+	}
+	return 1 // Invalid runes are encoded as U+FFFD.
+}
+
+// Len returns the number of units of s in enc.
+func (enc Encoding) Len(s []byte) int {
+	if enc == UTF8 {
+		return len(s)
+	}
+	var n int
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		n += enc.runeLen(r)
+		s = s[size:]
+	}
+	return n
+}
+
+// FromUTF8 converts the UTF-8 byte offset in line to a character offset in
+// enc. Offsets inside a multi-byte character round down to its start.
+func (enc Encoding) FromUTF8(line []byte, utf8Offset int) int {
+	utf8Offset = min(max(utf8Offset, 0), len(line))
+	if enc == UTF8 {
+		return utf8Offset
+	}
+	var offset, n int
+	for n < utf8Offset {
+		r, size := utf8.DecodeRune(line[n:])
+		if n+size > utf8Offset {
+			break
+		}
+		n += size
+		offset += enc.runeLen(r)
+	}
+	return offset
+}
+
+// ToUTF8 converts the character offset in enc in line to a UTF-8 byte offset.
+// Offsets beyond the end of line are clamped to it, and offsets inside a
+// character, e.g., between the UTF-16 surrogates of an emoji, round up to its
+// end.
+func (enc Encoding) ToUTF8(line []byte, offset int) int {
+	if offset <= 0 {
+		return 0
+	}
+	if enc == UTF8 {
+		return min(offset, len(line))
+	}
+	var units, n int
+	for units < offset && n < len(line) {
+		r, size := utf8.DecodeRune(line[n:])
+		units += enc.runeLen(r)
+		n += size
+	}
+	return n
+}
+
+// Position converts the UTF-8 byte offset in content to a position.
+func (enc Encoding) Position(content []byte, offset int) protocol.Position {
+	offset = min(max(offset, 0), len(content))
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+	return protocol.Position{
+		Line:      uint32(bytes.Count(content[:lineStart], []byte{'\n'})),
+		Character: uint32(enc.FromUTF8(content[lineStart:offset], offset-lineStart)),
+	}
+}
+
+// Offset converts the position in content to a UTF-8 byte offset. Positions
+// beyond the end of a line or content are clamped to it.
+func (enc Encoding) Offset(content []byte, pos protocol.Position) int {
+	lineStart := 0
+	for range pos.Line {
+		i := bytes.IndexByte(content[lineStart:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		lineStart += i + 1
+	}
+	line := content[lineStart:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return lineStart + enc.ToUTF8(line, int(pos.Character))
+}
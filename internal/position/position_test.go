@@ -0,0 +1,96 @@
+package position
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, UTF16, Negotiate(nil))
+	assert.Equal(t, UTF16, Negotiate([]protocol.PositionEncodingKind{protocol.UTF16, protocol.UTF32}))
+	assert.Equal(t, UTF8, Negotiate([]protocol.PositionEncodingKind{protocol.UTF16, protocol.UTF8}))
+}
+
+func TestEncoding(t *testing.T) {
+	// "a" is 1 byte, "你" is 3 bytes and 1 UTF-16 unit, and "😀" is 4 bytes
+	// and 2 UTF-16 units.
+	line := []byte("a你😀b")
+
+	for _, tt := range []struct {
+		enc     Encoding
+		len     int
+		offsets []int // of each character boundary
+	}{
+		{UTF8, 9, []int{0, 1, 4, 8, 9}},
+		{UTF16, 5, []int{0, 1, 2, 4, 5}},
+		{UTF32, 4, []int{0, 1, 2, 3, 4}},
+	} {
+		t.Run(string(tt.enc), func(t *testing.T) {
+			assert.Equal(t, tt.len, tt.enc.Len(line))
+			for i, utf8Offset := range []int{0, 1, 4, 8, 9} {
+				assert.Equal(t, tt.offsets[i], tt.enc.FromUTF8(line, utf8Offset))
+				assert.Equal(t, utf8Offset, tt.enc.ToUTF8(line, tt.offsets[i]))
+			}
+			assert.Equal(t, len(line), tt.enc.ToUTF8(line, 100))
+			assert.Equal(t, 0, tt.enc.ToUTF8(line, -1))
+		})
+	}
+
+	t.Run("InsideCharacter", func(t *testing.T) {
+		assert.Equal(t, 2, UTF16.FromUTF8(line, 6))
+		assert.Equal(t, 8, UTF16.ToUTF8(line, 3))
+	})
+}
+
+func TestPositionAndOffset(t *testing.T) {
+	content := []byte("echo 1\necho \"😀\", 2\n")
+	idx := NewLineIndex(content)
+	for _, tt := range []struct {
+		enc    Encoding
+		offset int
+		pos    protocol.Position
+	}{
+		{UTF16, 0, protocol.Position{Line: 0, Character: 0}},
+		{UTF16, 7, protocol.Position{Line: 1, Character: 0}},
+		{UTF16, 17, protocol.Position{Line: 1, Character: 8}},
+		{UTF8, 17, protocol.Position{Line: 1, Character: 10}},
+		{UTF32, 17, protocol.Position{Line: 1, Character: 7}},
+		{UTF16, len(content), protocol.Position{Line: 2, Character: 0}},
+	} {
+		assert.Equal(t, tt.pos, tt.enc.Position(content, tt.offset))
+		assert.Equal(t, tt.offset, tt.enc.Offset(content, tt.pos))
+		assert.Equal(t, tt.pos, idx.Position(tt.enc, tt.offset))
+		assert.Equal(t, tt.offset, idx.Offset(tt.enc, tt.pos))
+	}
+
+	t.Run("Clamped", func(t *testing.T) {
+		assert.Equal(t, 6, UTF16.Offset(content, protocol.Position{Line: 0, Character: 100}))
+		assert.Equal(t, len(content), UTF16.Offset(content, protocol.Position{Line: 100, Character: 0}))
+		assert.Equal(t, protocol.Position{Line: 2, Character: 0}, UTF16.Position(content, 100))
+		assert.Equal(t, 6, idx.Offset(UTF16, protocol.Position{Line: 0, Character: 100}))
+		assert.Equal(t, len(content), idx.Offset(UTF16, protocol.Position{Line: 100, Character: 0}))
+		assert.Equal(t, protocol.Position{Line: 2, Character: 0}, idx.Position(UTF16, 100))
+		assert.Equal(t, protocol.Position{Line: 0, Character: 0}, idx.Position(UTF16, -1))
+	})
+}
+
+func TestLineIndex(t *testing.T) {
+	idx := NewLineIndex([]byte("a\nbc\n"))
+	assert.Equal(t, 3, idx.LineCount())
+	assert.Equal(t, 0, idx.LineStart(0))
+	assert.Equal(t, 2, idx.LineStart(1))
+	assert.Equal(t, 5, idx.LineStart(2))
+	assert.Equal(t, 5, idx.LineStart(3))
+	assert.Equal(t, []byte("bc"), idx.Line(1))
+	assert.Empty(t, idx.Line(2))
+	assert.Nil(t, idx.Line(3))
+	assert.Equal(t, protocol.Position{Line: 1, Character: 3}, idx.LinePosition(UTF16, 1, 3))
+	assert.Equal(t, protocol.Position{Line: 1, Character: 3}, idx.LinePosition(UTF16, 1, 10))
+
+	empty := NewLineIndex(nil)
+	assert.Equal(t, 1, empty.LineCount())
+	assert.Equal(t, protocol.Position{}, empty.Position(UTF16, 0))
+	assert.Equal(t, 0, empty.Offset(UTF16, protocol.Position{Line: 1, Character: 1}))
+}
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"context"
+	"go/types"
+	"path"
+	"strconv"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// callHierarchyNode is a node in the call hierarchy. Exactly one of its fields
+// is set:
+//   - fn: a function or method declared in the workspace.
+//   - handler: an spx event handler registration call, e.g. `onStart => {}`.
+//   - file: the top-level statements of an spx file.
+type callHierarchyNode struct {
+	fn      *types.Func
+	handler *gopast.CallExpr
+	file    *gopast.File
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_prepareCallHierarchy
+func (s *Server) textDocumentPrepareCallHierarchy(ctx context.Context, params *CallHierarchyPrepareParams) ([]CallHierarchyItem, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	pos := result.posAt(astFile, params.Position)
+	if !pos.IsValid() {
+		return nil, nil
+	}
+
+	if call := result.spxEventHandlerCallAt(astFile, pos); call != nil {
+		return []CallHierarchyItem{result.callHierarchyItemFor(callHierarchyNode{handler: call})}, nil
+	}
+
+	position := result.toPosition(astFile, params.Position)
+	fn, ok := getTypeInfo(result.proj).ObjectOf(result.identAtASTFilePosition(astFile, position)).(*types.Func)
+	if !ok || !isMainPkgObject(fn) {
+		return nil, nil
+	}
+	if defIdent := result.defIdentFor(fn); defIdent == nil || !result.isInFset(defIdent.Pos()) {
+		return nil, nil
+	}
+	return []CallHierarchyItem{result.callHierarchyItemFor(callHierarchyNode{fn: fn})}, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#callHierarchy_incomingCalls
+func (s *Server) callHierarchyIncomingCalls(ctx context.Context, params *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error) {
+	result, node, err := s.resolveCallHierarchyItem(ctx, params.Item)
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	var calls callHierarchyCalls
+	typeInfo := getTypeInfo(result.proj)
+	switch {
+	case node.fn != nil:
+		for _, refIdent := range result.refIdentsFor(node.fn) {
+			if !result.isCallSiteIdent(refIdent) {
+				continue
+			}
+			calls.add(result.callHierarchyCallerAt(refIdent.Pos()), result.rangeForNode(refIdent))
+		}
+	case node.handler != nil:
+		switch node.handler.Fun.(*gopast.Ident).Name {
+		case "onStart":
+			// Synthetic trigger edge: onStart handlers are triggered once the
+			// game is started by `run` in main.spx.
+			mainASTFile := getASTPkg(result.proj).Files[result.mainSpxFile]
+			gopast.Inspect(mainASTFile, func(n gopast.Node) bool {
+				call, ok := n.(*gopast.CallExpr)
+				if !ok {
+					return true
+				}
+				if ident, ok := call.Fun.(*gopast.Ident); ok && ident.Name == "run" && isSpxPkgObject(typeInfo.ObjectOf(ident)) {
+					calls.add(callHierarchyNode{file: mainASTFile}, result.rangeForNode(ident))
+				}
+				return true
+			})
+		case "onMsg":
+			// Synthetic trigger edges: onMsg handlers are triggered by
+			// broadcasts of the same message.
+			msg, ok := result.spxEventHandlerMsg(node.handler)
+			if !ok {
+				break
+			}
+			for _, astFile := range getASTPkg(result.proj).Files {
+				gopast.Inspect(astFile, func(n gopast.Node) bool {
+					call, ok := n.(*gopast.CallExpr)
+					if !ok {
+						return true
+					}
+					if ident, ok := result.spxBroadcastCallIdent(call); ok {
+						if broadcastMsg, ok := result.spxEventHandlerMsg(call); ok && broadcastMsg == msg {
+							calls.add(result.callHierarchyCallerAt(call.Pos()), result.rangeForNode(ident))
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	incomingCalls := make([]CallHierarchyIncomingCall, 0, len(calls.nodes))
+	for _, caller := range calls.nodes {
+		incomingCalls = append(incomingCalls, CallHierarchyIncomingCall{
+			From:       result.callHierarchyItemFor(caller),
+			FromRanges: calls.ranges[caller],
+		})
+	}
+	return incomingCalls, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#callHierarchy_outgoingCalls
+func (s *Server) callHierarchyOutgoingCalls(ctx context.Context, params *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error) {
+	result, node, err := s.resolveCallHierarchyItem(ctx, params.Item)
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	var body *gopast.BlockStmt
+	switch {
+	case node.fn != nil:
+		if funcDecl := result.funcDeclFor(node.fn); funcDecl != nil {
+			body = funcDecl.Body
+		}
+	case node.handler != nil:
+		body = spxEventHandlerBody(node.handler)
+	case node.file != nil:
+		if node.file.ShadowEntry != nil {
+			body = node.file.ShadowEntry.Body
+		}
+	}
+	if body == nil {
+		return nil, nil
+	}
+
+	var calls callHierarchyCalls
+	typeInfo := getTypeInfo(result.proj)
+	addFuncCall := func(ident *gopast.Ident) {
+		fn, ok := typeInfo.ObjectOf(ident).(*types.Func)
+		if !ok || !isMainPkgObject(fn) {
+			return
+		}
+		if defIdent := result.defIdentFor(fn); defIdent == nil || !result.isInFset(defIdent.Pos()) {
+			return
+		}
+		calls.add(callHierarchyNode{fn: fn}, result.rangeForNode(ident))
+	}
+	gopast.Inspect(body, func(n gopast.Node) bool {
+		switch n := n.(type) {
+		case *gopast.CallExpr:
+			if result.isSpxEventHandlerCall(n) {
+				return false // Nested event handlers are call hierarchy items on their own.
+			}
+			if ident, ok := result.spxBroadcastCallIdent(n); ok {
+				if msg, ok := result.spxEventHandlerMsg(n); ok {
+					for _, handler := range result.spxMsgHandlerCalls(msg) {
+						calls.add(callHierarchyNode{handler: handler}, result.rangeForNode(ident))
+					}
+				}
+				return true
+			}
+			if ident := funcIdentOfCallExpr(n); ident != nil {
+				addFuncCall(ident)
+			}
+		case *gopast.ExprStmt:
+			// Command-style calls without arguments, e.g. `greet`.
+			switch x := n.X.(type) {
+			case *gopast.Ident:
+				addFuncCall(x)
+			case *gopast.SelectorExpr:
+				addFuncCall(x.Sel)
+			}
+		}
+		return true
+	})
+
+	outgoingCalls := make([]CallHierarchyOutgoingCall, 0, len(calls.nodes))
+	for _, callee := range calls.nodes {
+		outgoingCalls = append(outgoingCalls, CallHierarchyOutgoingCall{
+			To:         result.callHierarchyItemFor(callee),
+			FromRanges: calls.ranges[callee],
+		})
+	}
+	return outgoingCalls, nil
+}
+
+// callHierarchyCalls collects call ranges grouped by call hierarchy nodes in
+// the order they are first seen.
+type callHierarchyCalls struct {
+	nodes  []callHierarchyNode
+	ranges map[callHierarchyNode][]Range
+}
+
+// add adds a call range for the given node.
+func (c *callHierarchyCalls) add(node callHierarchyNode, rng Range) {
+	if c.ranges == nil {
+		c.ranges = make(map[callHierarchyNode][]Range)
+	}
+	if _, ok := c.ranges[node]; !ok {
+		c.nodes = append(c.nodes, node)
+	}
+	c.ranges[node] = append(c.ranges[node], rng)
+}
+
+// resolveCallHierarchyItem resolves the given call hierarchy item, which was
+// previously returned by [Server.textDocumentPrepareCallHierarchy], to a
+// call hierarchy node. It returns a nil node if the item no longer exists.
+func (s *Server) resolveCallHierarchyItem(ctx context.Context, item CallHierarchyItem) (*compileResult, *callHierarchyNode, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, item.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	if astFile == nil {
+		return nil, nil, nil
+	}
+
+	switch item.Kind {
+	case File:
+		return result, &callHierarchyNode{file: astFile}, nil
+	case Event:
+		pos := result.posAt(astFile, item.SelectionRange.Start)
+		var handler *gopast.CallExpr
+		gopast.Inspect(astFile, func(n gopast.Node) bool {
+			if handler != nil {
+				return false
+			}
+			if call, ok := n.(*gopast.CallExpr); ok && call.Fun.Pos() == pos && result.isSpxEventHandlerCall(call) {
+				handler = call
+			}
+			return true
+		})
+		if handler == nil {
+			return result, nil, nil
+		}
+		return result, &callHierarchyNode{handler: handler}, nil
+	}
+
+	position := result.toPosition(astFile, item.SelectionRange.Start)
+	fn, ok := getTypeInfo(result.proj).ObjectOf(result.identAtASTFilePosition(astFile, position)).(*types.Func)
+	if !ok || !isMainPkgObject(fn) {
+		return result, nil, nil
+	}
+	return result, &callHierarchyNode{fn: fn}, nil
+}
+
+// callHierarchyItemFor returns the [CallHierarchyItem] for the given node.
+func (r *compileResult) callHierarchyItemFor(node callHierarchyNode) CallHierarchyItem {
+	switch {
+	case node.fn != nil:
+		defIdent := r.defIdentFor(node.fn)
+		kind := Function
+		if node.fn.Type().(*types.Signature).Recv() != nil {
+			kind = Method
+		}
+		item := CallHierarchyItem{
+			Name:           node.fn.Name(),
+			Kind:           kind,
+			Detail:         getSimplifiedTypeString(node.fn.Type()),
+			URI:            r.nodeDocumentURI(defIdent),
+			Range:          r.rangeForNode(defIdent),
+			SelectionRange: r.rangeForNode(defIdent),
+		}
+		if funcDecl := r.funcDeclFor(node.fn); funcDecl != nil {
+			item.Range = r.rangeForNode(funcDecl)
+		}
+		return item
+	case node.handler != nil:
+		funIdent := node.handler.Fun.(*gopast.Ident)
+		name := funIdent.Name
+		if msg, ok := r.spxEventHandlerMsg(node.handler); ok {
+			name += " " + strconv.Quote(msg)
+		}
+		return CallHierarchyItem{
+			Name:           name,
+			Kind:           Event,
+			Detail:         path.Base(r.nodeFilename(node.handler)),
+			URI:            r.nodeDocumentURI(node.handler),
+			Range:          r.rangeForNode(node.handler),
+			SelectionRange: r.rangeForNode(funIdent),
+		}
+	default:
+		spxFile := r.nodeFilename(node.file)
+		return CallHierarchyItem{
+			Name: path.Base(spxFile),
+			Kind: File,
+			URI:  r.documentURIs[spxFile],
+		}
+	}
+}
+
+// callHierarchyCallerAt returns the call hierarchy node that encloses the
+// given position, i.e. the innermost function declaration or spx event
+// handler. It falls back to the top-level statements of the spx file.
+func (r *compileResult) callHierarchyCallerAt(pos goptoken.Pos) callHierarchyNode {
+	astFile := r.posASTFile(pos)
+	path, _ := goputil.PathEnclosingInterval(r.proj, pos, pos)
+	for i, node := range path {
+		switch node := node.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr2:
+			if i+1 < len(path) {
+				if call, ok := path[i+1].(*gopast.CallExpr); ok && r.isSpxEventHandlerCall(call) && spxEventHandlerBody(call) != nil {
+					return callHierarchyNode{handler: call}
+				}
+			}
+		case *gopast.FuncDecl:
+			if node.Shadow {
+				return callHierarchyNode{file: astFile}
+			}
+			if fn, ok := getTypeInfo(r.proj).Defs[node.Name].(*types.Func); ok {
+				return callHierarchyNode{fn: fn}
+			}
+		}
+	}
+	return callHierarchyNode{file: astFile}
+}
+
+// funcDeclFor returns the function declaration of the given function. It
+// returns nil if not found.
+func (r *compileResult) funcDeclFor(fn *types.Func) *gopast.FuncDecl {
+	defIdent := r.defIdentFor(fn)
+	if defIdent == nil || !r.isInFset(defIdent.Pos()) {
+		return nil
+	}
+	funcDecl, _ := goputil.EnclosingNode[*gopast.FuncDecl](goputil.NodePath(r.proj, defIdent))
+	if funcDecl == nil || funcDecl.Name != defIdent {
+		return nil
+	}
+	return funcDecl
+}
+
+// isCallSiteIdent reports whether the given identifier is the callee of a
+// call, including command-style calls without arguments.
+func (r *compileResult) isCallSiteIdent(ident *gopast.Ident) bool {
+	path := goputil.NodePath(r.proj, ident)
+	for i, node := range path[1:] {
+		switch node := node.(type) {
+		case *gopast.SelectorExpr:
+			if node.Sel != path[i] {
+				return false
+			}
+			continue
+		case *gopast.CallExpr:
+			return node.Fun == path[i]
+		case *gopast.ExprStmt:
+			return node.X == path[i]
+		}
+		return false
+	}
+	return false
+}
+
+// isSpxEventHandlerCall reports whether the given call expression registers an
+// spx event handler, e.g. `onStart => {}`.
+func (r *compileResult) isSpxEventHandlerCall(call *gopast.CallExpr) bool {
+	ident, ok := call.Fun.(*gopast.Ident)
+	if !ok || !isSpxEventHandlerFuncName(ident.Name) {
+		return false
+	}
+	return isSpxPkgObject(getTypeInfo(r.proj).ObjectOf(ident))
+}
+
+// spxEventHandlerCallAt returns the spx event handler registration call whose
+// function name contains the given position. It returns nil if not found.
+func (r *compileResult) spxEventHandlerCallAt(astFile *gopast.File, pos goptoken.Pos) *gopast.CallExpr {
+	var handler *gopast.CallExpr
+	gopast.Inspect(astFile, func(n gopast.Node) bool {
+		if handler != nil {
+			return false
+		}
+		call, ok := n.(*gopast.CallExpr)
+		if !ok || call.Fun.Pos() > pos || call.Fun.End() < pos {
+			return true
+		}
+		if r.isSpxEventHandlerCall(call) && spxEventHandlerBody(call) != nil {
+			handler = call
+		}
+		return true
+	})
+	return handler
+}
+
+// spxEventHandlerMsg returns the message name of the given spx event handler
+// registration or broadcast call, e.g. "hello" for `onMsg "hello", => {}`.
+func (r *compileResult) spxEventHandlerMsg(call *gopast.CallExpr) (string, bool) {
+	if len(call.Args) == 0 {
+		return "", false
+	}
+	arg := call.Args[0]
+	tv, ok := getTypeInfo(r.proj).Types[arg]
+	if !ok || tv.Type == nil || !types.AssignableTo(tv.Type, types.Typ[types.String]) {
+		return "", false
+	}
+	return getStringLitOrConstValue(arg, tv)
+}
+
+// spxBroadcastCallIdent returns the function identifier of the given call if
+// it is an spx broadcast call.
+func (r *compileResult) spxBroadcastCallIdent(call *gopast.CallExpr) (*gopast.Ident, bool) {
+	ident := funcIdentOfCallExpr(call)
+	if ident == nil {
+		return nil, false
+	}
+	switch ident.Name {
+	case "broadcast", "broadcastAndWait":
+		return ident, isSpxPkgObject(getTypeInfo(r.proj).ObjectOf(ident))
+	}
+	return nil, false
+}
+
+// spxMsgHandlerCalls returns all `onMsg` handler registration calls in the
+// workspace that handle the given message.
+func (r *compileResult) spxMsgHandlerCalls(msg string) []*gopast.CallExpr {
+	var handlers []*gopast.CallExpr
+	for _, astFile := range getASTPkg(r.proj).Files {
+		gopast.Inspect(astFile, func(n gopast.Node) bool {
+			call, ok := n.(*gopast.CallExpr)
+			if !ok || !r.isSpxEventHandlerCall(call) || call.Fun.(*gopast.Ident).Name != "onMsg" {
+				return true
+			}
+			if handlerMsg, ok := r.spxEventHandlerMsg(call); ok && handlerMsg == msg {
+				handlers = append(handlers, call)
+			}
+			return true
+		})
+	}
+	return handlers
+}
+
+// spxEventHandlerBody returns the body of the callback passed to the given spx
+// event handler registration call. It returns nil if not found.
+func spxEventHandlerBody(call *gopast.CallExpr) *gopast.BlockStmt {
+	if len(call.Args) == 0 {
+		return nil
+	}
+	switch lit := call.Args[len(call.Args)-1].(type) {
+	case *gopast.FuncLit:
+		return lit.Body
+	case *gopast.LambdaExpr2:
+		return lit.Body
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCallHierarchy(t *testing.T) {
+	t.Run("Function", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+func helper() {}
+
+func greet() {
+	helper
+	helper()
+}
+
+greet
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentPrepareCallHierarchy(context.Background(), &CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		greetItem := items[0]
+		assert.Equal(t, "greet", greetItem.Name)
+		assert.Equal(t, DocumentURI("file:///main.spx"), greetItem.URI)
+		assert.Equal(t, Range{
+			Start: Position{Line: 3, Character: 5},
+			End:   Position{Line: 3, Character: 10},
+		}, greetItem.SelectionRange)
+		assert.Equal(t, Range{
+			Start: Position{Line: 3, Character: 0},
+			End:   Position{Line: 6, Character: 1},
+		}, greetItem.Range)
+
+		outgoingCalls, err := s.callHierarchyOutgoingCalls(context.Background(), &CallHierarchyOutgoingCallsParams{Item: greetItem})
+		require.NoError(t, err)
+		require.Len(t, outgoingCalls, 1)
+		assert.Equal(t, "helper", outgoingCalls[0].To.Name)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 4, Character: 1}, End: Position{Line: 4, Character: 7}},
+			{Start: Position{Line: 5, Character: 1}, End: Position{Line: 5, Character: 7}},
+		}, outgoingCalls[0].FromRanges)
+
+		incomingCalls, err := s.callHierarchyIncomingCalls(context.Background(), &CallHierarchyIncomingCallsParams{Item: greetItem})
+		require.NoError(t, err)
+		require.Len(t, incomingCalls, 1)
+		assert.Equal(t, "main.spx", incomingCalls[0].From.Name)
+		assert.Equal(t, File, incomingCalls[0].From.Kind)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 8, Character: 0}, End: Position{Line: 8, Character: 5}},
+		}, incomingCalls[0].FromRanges)
+
+		helperIncomingCalls, err := s.callHierarchyIncomingCalls(context.Background(), &CallHierarchyIncomingCallsParams{Item: outgoingCalls[0].To})
+		require.NoError(t, err)
+		require.Len(t, helperIncomingCalls, 1)
+		assert.Equal(t, "greet", helperIncomingCalls[0].From.Name)
+		assert.Len(t, helperIncomingCalls[0].FromRanges, 2)
+	})
+
+	t.Run("SpxEventHandler", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	broadcast "hello"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func greet() {}
+
+onMsg "hello", => {
+	greet
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		onStartItems, err := s.textDocumentPrepareCallHierarchy(context.Background(), &CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, onStartItems, 1)
+		onStartItem := onStartItems[0]
+		assert.Equal(t, "onStart", onStartItem.Name)
+		assert.Equal(t, Event, onStartItem.Kind)
+
+		onStartIncomingCalls, err := s.callHierarchyIncomingCalls(context.Background(), &CallHierarchyIncomingCallsParams{Item: onStartItem})
+		require.NoError(t, err)
+		require.Len(t, onStartIncomingCalls, 1)
+		assert.Equal(t, "main.spx", onStartIncomingCalls[0].From.Name)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 4, Character: 0}, End: Position{Line: 4, Character: 3}},
+		}, onStartIncomingCalls[0].FromRanges)
+
+		onStartOutgoingCalls, err := s.callHierarchyOutgoingCalls(context.Background(), &CallHierarchyOutgoingCallsParams{Item: onStartItem})
+		require.NoError(t, err)
+		require.Len(t, onStartOutgoingCalls, 1)
+		onMsgItem := onStartOutgoingCalls[0].To
+		assert.Equal(t, `onMsg "hello"`, onMsgItem.Name)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), onMsgItem.URI)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 10}},
+		}, onStartOutgoingCalls[0].FromRanges)
+
+		onMsgIncomingCalls, err := s.callHierarchyIncomingCalls(context.Background(), &CallHierarchyIncomingCallsParams{Item: onMsgItem})
+		require.NoError(t, err)
+		require.Len(t, onMsgIncomingCalls, 1)
+		assert.Equal(t, "onStart", onMsgIncomingCalls[0].From.Name)
+
+		onMsgOutgoingCalls, err := s.callHierarchyOutgoingCalls(context.Background(), &CallHierarchyOutgoingCallsParams{Item: onMsgItem})
+		require.NoError(t, err)
+		require.Len(t, onMsgOutgoingCalls, 1)
+		assert.Equal(t, "greet", onMsgOutgoingCalls[0].To.Name)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), onMsgOutgoingCalls[0].To.URI)
+	})
+
+	t.Run("InvalidPosition", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var x int
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentPrepareCallHierarchy(context.Background(), &CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 4},
+			},
+		})
+		require.NoError(t, err)
+		require.Nil(t, items)
+	})
+}
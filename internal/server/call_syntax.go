@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// Go+ calls may be written in command style, e.g., `echo "hi"`, as in spx
+// code, or in call style, e.g., `echo("hi")`, as in Go code.
+const (
+	// refactorRewriteToCallSyntax is the kind of code actions that convert
+	// command-style calls to call style.
+	refactorRewriteToCallSyntax = RefactorRewrite + ".toCallSyntax"
+
+	// refactorRewriteToCommandSyntax is the kind of code actions that
+	// convert call-style calls to command style.
+	refactorRewriteToCommandSyntax = RefactorRewrite + ".toCommandSyntax"
+)
+
+// spxCallSyntaxCodeActions returns the code actions that convert the calls in
+// the given range, or at its start if empty, of the spx source file of the
+// given document URI between command style and call style, and those that
+// convert all calls of the file if there are others.
+func (s *Server) spxCallSyntaxCodeActions(ctx context.Context, uri DocumentURI, rng Range, only []CodeActionKind) ([]CodeAction, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	start, end := result.posAt(astFile, rng.Start), result.posAt(astFile, rng.End)
+	tokenFile := result.proj.Fset.File(astFile.Pos())
+	fileStart, fileEnd := tokenFile.Pos(0), tokenFile.Pos(len(astFile.Code))
+	var codeActions []CodeAction
+	for _, conv := range []struct {
+		kind    CodeActionKind
+		title   string
+		allText string
+		calls   func(astFile *gopast.File) []*gopast.CallExpr
+		edits   func(astFile *gopast.File, call *gopast.CallExpr) []TextEdit
+	}{
+		{
+			kind:    refactorRewriteToCallSyntax,
+			title:   "Convert to call syntax",
+			allText: "Convert all calls in file to call syntax",
+			calls:   commandStyleCalls,
+			edits:   result.toCallSyntaxEdits,
+		},
+		{
+			kind:    refactorRewriteToCommandSyntax,
+			title:   "Convert to command syntax",
+			allText: "Convert all calls in file to command syntax",
+			calls:   callStyleCalls,
+			edits:   result.toCommandSyntaxEdits,
+		},
+	} {
+		if !isCodeActionKindRequested(only, conv.kind) {
+			continue
+		}
+		calls := conv.calls(astFile)
+		selected := callsInRange(calls, start, end)
+		if len(selected) == 0 {
+			continue
+		}
+		var edits, allEdits []TextEdit
+		for _, call := range selected {
+			edits = append(edits, conv.edits(astFile, call)...)
+		}
+		for _, call := range callsInRange(calls, fileStart, fileEnd) {
+			allEdits = append(allEdits, conv.edits(astFile, call)...)
+		}
+		if len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: conv.title,
+				Kind:  conv.kind,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{uri: edits},
+				},
+			})
+		}
+		if len(allEdits) > len(edits) {
+			codeActions = append(codeActions, CodeAction{
+				Title: conv.allText,
+				Kind:  conv.kind,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{uri: allEdits},
+				},
+			})
+		}
+	}
+	return codeActions, nil
+}
+
+// callsInRange returns the given calls within the range between start and
+// end, or the innermost one containing it if there is none.
+func callsInRange(calls []*gopast.CallExpr, start, end goptoken.Pos) []*gopast.CallExpr {
+	var (
+		within    []*gopast.CallExpr
+		innermost *gopast.CallExpr
+	)
+	for _, call := range calls {
+		switch {
+		case start < end && start <= call.Pos() && call.End() <= end:
+			within = append(within, call)
+		case call.Pos() <= start && end <= call.End():
+			if innermost == nil || innermost.Pos() <= call.Pos() && call.End() <= innermost.End() {
+				innermost = call
+			}
+		}
+	}
+	if len(within) == 0 && innermost != nil {
+		within = append(within, innermost)
+	}
+	return within
+}
+
+// commandStyleCalls returns the command-style calls in the given AST file.
+func commandStyleCalls(astFile *gopast.File) (calls []*gopast.CallExpr) {
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if call, ok := node.(*gopast.CallExpr); ok && call.IsCommand() && len(call.Args) > 0 {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	return
+}
+
+// callStyleCalls returns the call-style calls in the given AST file that may
+// be written in command style, i.e., calls of identifiers or selectors with
+// arguments as expression statements.
+func callStyleCalls(astFile *gopast.File) (calls []*gopast.CallExpr) {
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		stmt, ok := node.(*gopast.ExprStmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.X.(*gopast.CallExpr)
+		if !ok || call.IsCommand() || !call.Lparen.IsValid() || len(call.Args) == 0 {
+			return true
+		}
+		switch call.Fun.(type) {
+		case *gopast.Ident, *gopast.SelectorExpr:
+			calls = append(calls, call)
+		}
+		return true
+	})
+	return
+}
+
+// callArgsEnd returns the end position of the arguments of the given call,
+// including the ellipsis, if any.
+func callArgsEnd(call *gopast.CallExpr) goptoken.Pos {
+	if call.Ellipsis.IsValid() {
+		return call.Ellipsis + goptoken.Pos(len(goptoken.ELLIPSIS.String()))
+	}
+	return call.Args[len(call.Args)-1].End()
+}
+
+// toCallSyntaxEdits returns the edits that convert the given command-style
+// call to call style, e.g., `echo "hi"` to `echo("hi")`. It returns nil if
+// comments would be lost.
+func (r *compileResult) toCallSyntaxEdits(astFile *gopast.File, call *gopast.CallExpr) []TextEdit {
+	code := astFile.Code
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	text := func(start, end goptoken.Pos) []byte {
+		return code[tokenFile.Offset(start):tokenFile.Offset(end)]
+	}
+	if call.Lparen.IsValid() {
+		// The arguments are already parenthesized, e.g., `echo (1, 2)`.
+		if hasComment(text(call.Fun.End(), call.Lparen)) {
+			return nil
+		}
+		return []TextEdit{{
+			Range:   r.rangeForStartEnd(astFile, call.Fun.End(), call.Lparen),
+			NewText: "",
+		}}
+	}
+
+	argsStart, argsEnd := call.Args[0].Pos(), callArgsEnd(call)
+	if hasComment(text(call.Fun.End(), argsStart)) {
+		return nil
+	}
+	return []TextEdit{
+		{
+			Range:   r.rangeForStartEnd(astFile, call.Fun.End(), argsStart),
+			NewText: "(",
+		},
+		{
+			Range:   r.rangeForStartEnd(astFile, argsEnd, argsEnd),
+			NewText: ")",
+		},
+	}
+}
+
+// toCommandSyntaxEdits returns the edits that convert the given call-style
+// call to command style, e.g., `echo("hi")` to `echo "hi"`. It returns nil if
+// the arguments would not be parsed as such, e.g., `echo(- 1)`, whose command
+// style is a binary expression, or if comments would be lost.
+func (r *compileResult) toCommandSyntaxEdits(astFile *gopast.File, call *gopast.CallExpr) []TextEdit {
+	code := astFile.Code
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	text := func(start, end goptoken.Pos) []byte {
+		return code[tokenFile.Offset(start):tokenFile.Offset(end)]
+	}
+	argsStart, argsEnd := call.Args[0].Pos(), callArgsEnd(call)
+	if hasComment(text(call.Fun.End(), argsStart)) || hasComment(text(argsEnd, call.Rparen)) {
+		return nil
+	}
+
+	// Arguments starting with an operator are parsed as arguments of a
+	// command only if the operator is followed by its operand, and those
+	// starting with "(" may be parsed as the parenthesized arguments.
+	args := text(argsStart, argsEnd)
+	if args[0] == '(' {
+		return nil
+	}
+	if strings.IndexByte("-+*&^<", args[0]) >= 0 {
+		op := strings.TrimLeft(string(args), "-+*&^<")
+		if op == "" || op[0] == ' ' || op[0] == '\t' || op[0] == '\n' {
+			return nil
+		}
+	}
+
+	return []TextEdit{
+		{
+			Range:   r.rangeForStartEnd(astFile, call.Fun.End(), argsStart),
+			NewText: " ",
+		},
+		{
+			Range:   r.rangeForStartEnd(astFile, argsEnd, call.Rparen+1),
+			NewText: "",
+		},
+	}
+}
+
+// hasComment reports whether the given source text contains a comment.
+func hasComment(text []byte) bool {
+	return bytes.Contains(text, []byte("//")) || bytes.Contains(text, []byte("/*"))
+}
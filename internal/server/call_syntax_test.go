@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxCallSyntaxCodeActions(t *testing.T) {
+	codeActions := func(t *testing.T, content string, rng Range, kind CodeActionKind) map[string]string {
+		m := map[string][]byte{"main.spx": []byte(content)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        rng,
+			Context:      CodeActionContext{Only: []CodeActionKind{kind}},
+		})
+		require.NoError(t, err)
+		results := make(map[string]string, len(codeActions))
+		for _, codeAction := range codeActions {
+			assert.Equal(t, kind, codeAction.Kind)
+			require.NotNil(t, codeAction.Edit)
+			results[codeAction.Title] = applyTextEdits(m["main.spx"], codeAction.Edit.Changes["file:///main.spx"])
+		}
+		return results
+	}
+	at := func(line, character uint32) Range {
+		return Range{Start: Position{Line: line, Character: character}, End: Position{Line: line, Character: character}}
+	}
+
+	t.Run("ToCallSyntax", func(t *testing.T) {
+		got := codeActions(t, `echo "hi"
+echo 1, 2
+`, at(0, 1), refactorRewriteToCallSyntax)
+		assert.Equal(t, map[string]string{
+			"Convert to call syntax": `echo("hi")
+echo 1, 2
+`,
+			"Convert all calls in file to call syntax": `echo("hi")
+echo(1, 2)
+`,
+		}, got)
+	})
+
+	t.Run("ToCallSyntaxSelection", func(t *testing.T) {
+		got := codeActions(t, `onStart => {
+	echo -1
+	echo (1+2)*3
+}
+`, Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 3, Character: 0}}, refactorRewriteToCallSyntax)
+		assert.Equal(t, map[string]string{
+			"Convert to call syntax": `onStart => {
+	echo(-1)
+	echo((1+2)*3)
+}
+`,
+			"Convert all calls in file to call syntax": `onStart(=> {
+	echo(-1)
+	echo((1+2)*3)
+})
+`,
+		}, got)
+	})
+
+	t.Run("ToCallSyntaxParenthesized", func(t *testing.T) {
+		got := codeActions(t, `echo (1, 2)
+`, at(0, 0), refactorRewriteToCallSyntax)
+		assert.Equal(t, map[string]string{
+			"Convert to call syntax": `echo(1, 2)
+`,
+		}, got)
+	})
+
+	t.Run("ToCommandSyntax", func(t *testing.T) {
+		got := codeActions(t, `echo("hi")
+echo(1, 2)
+x := len("hi")
+echo(x)
+`, at(1, 6), refactorRewriteToCommandSyntax)
+		assert.Equal(t, map[string]string{
+			"Convert to command syntax": `echo("hi")
+echo 1, 2
+x := len("hi")
+echo(x)
+`,
+			"Convert all calls in file to command syntax": `echo "hi"
+echo 1, 2
+x := len("hi")
+echo x
+`,
+		}, got)
+	})
+
+	t.Run("ToCommandSyntaxAmbiguous", func(t *testing.T) {
+		for _, content := range []string{
+			"echo((1+2)*3)\n",
+			"echo(- 1)\n",
+			"echo(1 /* one */)\n",
+		} {
+			assert.Empty(t, codeActions(t, content, at(0, 0), refactorRewriteToCommandSyntax), content)
+		}
+		assert.Equal(t, map[string]string{
+			"Convert to command syntax": "echo -1\n",
+		}, codeActions(t, "echo(-1)\n", at(0, 0), refactorRewriteToCommandSyntax))
+	})
+
+	t.Run("NoCall", func(t *testing.T) {
+		assert.Empty(t, codeActions(t, `x := 1
+echo x
+`, at(0, 0), RefactorRewrite))
+	})
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	gopast "github.com/goplus/gop/ast"
+	gopscanner "github.com/goplus/gop/scanner"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/qiniu/x/errors"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_codeAction
+func (s *Server) textDocumentCodeAction(ctx context.Context, params *CodeActionParams) ([]CodeAction, error) {
+	if s.isReadOnly(params.TextDocument.URI) {
+		return nil, nil // All code actions edit the document.
+	}
+
+	var codeActions []CodeAction
+
+	if isCodeActionKindRequested(params.Context.Only, SourceOrganizeImports) {
+		edits, err := s.spxOrganizeImportsEdits(ctx, params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: "Organize imports",
+				Kind:  SourceOrganizeImports,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+
+	if isCodeActionKindRequested(params.Context.Only, SourceFixAll) {
+		result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if edits := result.fixAllEdits[params.TextDocument.URI]; astFile != nil && len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: "Fix all",
+				Kind:  SourceFixAll,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+
+	if isCodeActionKindRequested(params.Context.Only, QuickFix) {
+		result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if astFile != nil {
+			for _, fix := range result.quickFixes[params.TextDocument.URI] {
+				if !rangesOverlap(fix.diagnostic.Range, params.Range) {
+					continue
+				}
+				codeAction := CodeAction{
+					Title:       fix.title,
+					Kind:        QuickFix,
+					Diagnostics: []Diagnostic{fix.diagnostic},
+					IsPreferred: fix.isPreferred,
+					Command:     fix.command,
+				}
+				if len(fix.edits) > 0 {
+					codeAction.Edit = &WorkspaceEdit{
+						Changes: map[DocumentURI][]TextEdit{
+							params.TextDocument.URI: fix.edits,
+						},
+					}
+				}
+				codeActions = append(codeActions, codeAction)
+			}
+		}
+	}
+
+	if isCodeActionKindRequested(params.Context.Only, refactorExtractVariable) && params.Range.Start != params.Range.End {
+		edits, err := s.spxExtractVariableEdits(ctx, params.TextDocument.URI, params.Range)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: "Extract variable",
+				Kind:  refactorExtractVariable,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+
+	if isCodeActionKindRequested(params.Context.Only, refactorExtractFunction) && params.Range.Start != params.Range.End {
+		edits, err := s.spxExtractFunctionEdits(ctx, params.TextDocument.URI, params.Range)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: "Extract function",
+				Kind:  refactorExtractFunction,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+
+	if isCodeActionKindRequested(params.Context.Only, refactorInlineVariable) {
+		edits, err := s.spxInlineVariableEdits(ctx, params.TextDocument.URI, params.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) > 0 {
+			codeActions = append(codeActions, CodeAction{
+				Title: "Inline variable",
+				Kind:  refactorInlineVariable,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+
+	callSyntaxActions, err := s.spxCallSyntaxCodeActions(ctx, params.TextDocument.URI, params.Range, params.Context.Only)
+	if err != nil {
+		return nil, err
+	}
+	codeActions = append(codeActions, callSyntaxActions...)
+
+	return codeActions, nil
+}
+
+// isCodeActionKindRequested reports whether code actions of the given kind are
+// requested by the given kind filter. An empty filter requests all kinds.
+func isCodeActionKindRequested(only []CodeActionKind, kind CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, k := range only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// rangesOverlap reports whether the given ranges overlap or touch each other.
+func rangesOverlap(a, b Range) bool {
+	return !positionLess(a.End, b.Start) && !positionLess(b.End, a.Start)
+}
+
+// positionLess reports whether position a is before position b.
+func positionLess(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+// trimSelection returns the given selection in the given AST file without its
+// leading and trailing whitespace. It reports false if the selection is empty
+// or not in the file.
+func (r *compileResult) trimSelection(astFile *gopast.File, start, end goptoken.Pos) (goptoken.Pos, goptoken.Pos, bool) {
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	code := astFile.Code
+	if !start.IsValid() || !end.IsValid() || tokenFile.Pos(0) > start || end > tokenFile.Pos(len(code)) {
+		return start, end, false
+	}
+	startOffset, endOffset := tokenFile.Offset(start), tokenFile.Offset(end)
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';' }
+	for startOffset < endOffset && isSpace(code[startOffset]) {
+		startOffset++
+	}
+	for endOffset > startOffset && isSpace(code[endOffset-1]) {
+		endOffset--
+	}
+	if startOffset == endOffset {
+		return start, end, false
+	}
+	return tokenFile.Pos(startOffset), tokenFile.Pos(endOffset), true
+}
+
+// typeChecksAfterEdits reports whether the project of the result type checks
+// with no more errors than before once the given edits are applied to the
+// given spx source file, so that refactorings computed from the result never
+// break the code.
+func (r *compileResult) typeChecksAfterEdits(ctx context.Context, spxFile string, edits []TextEdit) bool {
+	file, ok := r.proj.File(spxFile)
+	if !ok {
+		return false
+	}
+	content := applyTextEditsToContent(r.posEncoding, file.Content, edits)
+
+	proj := r.proj.Snapshot()
+	proj.PutFile(spxFile, &vfs.MapFileImpl{Content: content, ModTime: time.Now()})
+	_, _, err, astErr := proj.TypeInfoContext(ctx)
+	_, _, origErr, origASTErr := r.proj.TypeInfo()
+	return ctx.Err() == nil &&
+		errorCount(astErr) <= errorCount(origASTErr) &&
+		errorCount(err) <= errorCount(origErr)
+}
+
+// applyTextEditsToContent returns a copy of content with the given
+// non-overlapping edits, whose ranges are in posEncoding, applied.
+func applyTextEditsToContent(posEncoding position.Encoding, content []byte, edits []TextEdit) []byte {
+	content = slices.Clone(content)
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b TextEdit) int {
+		return cmp.Or(
+			cmp.Compare(b.Range.Start.Line, a.Range.Start.Line),
+			cmp.Compare(b.Range.Start.Character, a.Range.Start.Character),
+		)
+	})
+	for _, edit := range edits {
+		start := posEncoding.Offset(content, edit.Range.Start)
+		end := posEncoding.Offset(content, edit.Range.End)
+		content = slices.Concat(content[:start], []byte(edit.NewText), content[end:])
+	}
+	return content
+}
+
+// errorCount returns the number of errors in the given error list, if any.
+func errorCount(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case errors.List:
+		return len(err)
+	case gopscanner.ErrorList:
+		return len(err)
+	}
+	return 1
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCodeAction(t *testing.T) {
+	t.Run("OrganizeImports", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`import (
+	"math"
+	"fmt"
+	"os"
+)
+
+fmt.Println strings.ToUpper("hi"), math.Pi
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceOrganizeImports}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Organize imports", codeActions[0].Title)
+		assert.Equal(t, SourceOrganizeImports, codeActions[0].Kind)
+		require.NotNil(t, codeActions[0].Edit)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Equal(t, `import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+fmt.Println strings.ToUpper("hi"), math.Pi
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("AddFirstImport", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`echo strings.ToUpper("hi")
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceOrganizeImports}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Equal(t, `import "strings"
+
+echo strings.ToUpper("hi")
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("RemoveAllImports", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`import "fmt"
+
+echo "hi"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceOrganizeImports}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Equal(t, `echo "hi"
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("AlreadyOrganized", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`import "fmt"
+
+fmt.Println "hi"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, codeActions)
+	})
+
+	t.Run("OtherKindRequested", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`import "fmt"
+
+echo "hi"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, codeActions)
+	})
+
+	t.Run("AppendQuickFix", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`var nums []int
+
+nums = append(nums)
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 2, Character: 7},
+				End:   Position{Line: 2, Character: 7},
+			},
+			Context: CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Add a value to append", codeActions[0].Title)
+		assert.Equal(t, QuickFix, codeActions[0].Kind)
+		assert.True(t, codeActions[0].IsPreferred)
+		require.Len(t, codeActions[0].Diagnostics, 1)
+		require.NotNil(t, codeActions[0].Edit)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Equal(t, `var nums []int
+
+nums = append(nums, 0)
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("AppendQuickFixOutOfRange", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`var nums []int
+
+nums = append(nums)
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 0, Character: 0},
+				End:   Position{Line: 0, Character: 3},
+			},
+			Context: CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, codeActions)
+	})
+	t.Run("InfiniteLoopQuickFix", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`onStart => {
+	for {
+		echo "spinning"
+	}
+}
+onClick => {
+	for true {}
+}
+onKey KeySpace, => {
+	for {
+		wait 1
+	}
+	for {
+		if true {
+			break
+		}
+	}
+	for {
+		continue
+		wait 1
+	}
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport := report.Value.(RelatedFullDocumentDiagnosticReport)
+		var loopDiagnostics []Diagnostic
+		for _, diagnostic := range fullReport.Items {
+			if diagnostic.Message == "infinite loop in event handler never yields to the spx runtime" {
+				loopDiagnostics = append(loopDiagnostics, diagnostic)
+			}
+		}
+		require.Len(t, loopDiagnostics, 3)
+		assert.Equal(t, Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 4}}, loopDiagnostics[0].Range)
+		assert.Equal(t, Range{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 4}}, loopDiagnostics[1].Range)
+		assert.Equal(t, Range{Start: Position{Line: 17, Character: 1}, End: Position{Line: 17, Character: 4}}, loopDiagnostics[2].Range) // The wait call is unreachable.
+
+		for _, tt := range []struct {
+			line uint32
+			want string
+		}{
+			{1, `onStart => {
+	for {
+		echo "spinning"
+		waitNextFrame
+	}
+}`},
+			{6, `onClick => {
+	for true {
+		waitNextFrame
+	}
+}`},
+		} {
+			codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Range: Range{
+					Start: Position{Line: tt.line, Character: 2},
+					End:   Position{Line: tt.line, Character: 2},
+				},
+				Context: CodeActionContext{Only: []CodeActionKind{QuickFix}},
+			})
+			require.NoError(t, err)
+			require.Len(t, codeActions, 1)
+			assert.Equal(t, "Insert `waitNextFrame` at the end of the loop", codeActions[0].Title)
+			edits := codeActions[0].Edit.Changes["file:///main.spx"]
+			assert.Contains(t, applyTextEdits(m["main.spx"], edits), tt.want)
+		}
+	})
+
+	t.Run("InfiniteLoopQuickFixWithLoopYieldCall", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`onStart => {
+	for {}
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		require.NoError(t, s.applySettings(&Settings{LoopYieldCall: "wait 0.01"}))
+
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 1, Character: 1},
+				End:   Position{Line: 1, Character: 1},
+			},
+			Context: CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Equal(t, `onStart => {
+	for {
+		wait 0.01
+	}
+}
+run "assets", {Title: "My Game"}
+`, applyTextEdits(m["main.spx"], edits))
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"path"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_codeLens
+func (s *Server) textDocumentCodeLens(ctx context.Context, params *CodeLensParams) ([]CodeLens, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	var codeLenses []CodeLens
+	typeInfo := getTypeInfo(result.proj)
+	addReferencesCodeLens := func(ident *gopast.Ident) {
+		obj := typeInfo.Defs[ident]
+		if obj == nil || ident.Name == "_" {
+			return
+		}
+		codeLenses = append(codeLenses, CodeLens{
+			Range: result.rangeForNode(ident),
+			Command: &Command{
+				Title: fmt.Sprintf("references: %d", s.countReferences(result, obj)),
+			},
+		})
+	}
+	for _, decl := range astFile.Decls {
+		switch decl := decl.(type) {
+		case *gopast.FuncDecl:
+			if decl.Shadow {
+				continue
+			}
+			addReferencesCodeLens(decl.Name)
+		case *gopast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *gopast.TypeSpec:
+					addReferencesCodeLens(spec.Name)
+				case *gopast.ValueSpec:
+					for _, name := range spec.Names {
+						addReferencesCodeLens(name)
+					}
+				}
+			}
+		}
+	}
+
+	runCommand, err := result.spxRunCommandForSpxFile(spxFile)
+	if err != nil {
+		return nil, err
+	}
+	if runCommand != nil {
+		for call := range inspector.All[*gopast.CallExpr](result.inspector(astFile)) {
+			if !result.isSpxEventHandlerCall(call) || call.Fun.(*gopast.Ident).Name != "onStart" {
+				continue
+			}
+			codeLenses = append(codeLenses, CodeLens{
+				Range:   result.rangeForNode(call.Fun),
+				Command: runCommand,
+			})
+		}
+	}
+	return codeLenses, nil
+}
+
+// countReferences returns the number of references to the given object,
+// excluding its declaration.
+func (s *Server) countReferences(result *compileResult, obj types.Object) int {
+	locations := s.findReferenceLocations(result, obj)
+	if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+		locations = append(locations, s.handleMethodReferences(result, fn)...)
+		locations = append(locations, s.handleEmbeddedFieldReferences(result, obj)...)
+	}
+	return len(deduplicateLocations(locations))
+}
+
+// spxRunCommandForSpxFile returns the command to run the given spx file. For
+// main.spx it is `spx.runProject`, and for sprite files it is `spx.runSprite`.
+// It returns nil if the spx file cannot be run on its own.
+func (r *compileResult) spxRunCommandForSpxFile(spxFile string) (*Command, error) {
+	if spxFile == r.mainSpxFile {
+		return &Command{
+			Title:   "Run project",
+			Command: "spx.runProject",
+		}, nil
+	}
+
+	spriteName := strings.TrimSuffix(path.Base(spxFile), ".spx")
+	if r.spxResourceSet.Sprite(spriteName) == nil {
+		return nil, nil
+	}
+	arg, err := json.Marshal(SpxRunSpriteParams{
+		Sprite: SpxResourceIdentifier{URI: SpxSpriteResourceID{SpriteName: spriteName}.URI()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spx.runSprite argument: %w", err)
+	}
+	return &Command{
+		Title:     "Run sprite",
+		Command:   "spx.runSprite",
+		Arguments: []json.RawMessage{arg},
+	}, nil
+}
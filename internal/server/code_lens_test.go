@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCodeLens(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+	count    int
+)
+
+func greet() {
+	count++
+}
+
+onStart => {
+	greet
+	greet()
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	say "Hi"
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		mainSpxCodeLenses, err := s.textDocumentCodeLens(context.Background(), &CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, mainSpxCodeLenses, 4)
+		assert.Contains(t, mainSpxCodeLenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 3, Character: 1},
+				End:   Position{Line: 3, Character: 6},
+			},
+			Command: &Command{Title: "references: 1"},
+		})
+		assert.Contains(t, mainSpxCodeLenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 6, Character: 5},
+				End:   Position{Line: 6, Character: 10},
+			},
+			Command: &Command{Title: "references: 2"},
+		})
+		assert.Contains(t, mainSpxCodeLenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 10, Character: 0},
+				End:   Position{Line: 10, Character: 7},
+			},
+			Command: &Command{
+				Title:   "Run project",
+				Command: "spx.runProject",
+			},
+		})
+
+		mySpriteCodeLenses, err := s.textDocumentCodeLens(context.Background(), &CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, mySpriteCodeLenses, 1)
+		assert.Equal(t, Range{
+			Start: Position{Line: 1, Character: 0},
+			End:   Position{Line: 1, Character: 7},
+		}, mySpriteCodeLenses[0].Range)
+		require.NotNil(t, mySpriteCodeLenses[0].Command)
+		assert.Equal(t, "Run sprite", mySpriteCodeLenses[0].Command.Title)
+		assert.Equal(t, "spx.runSprite", mySpriteCodeLenses[0].Command.Command)
+		require.Len(t, mySpriteCodeLenses[0].Command.Arguments, 1)
+		var runSpriteParams SpxRunSpriteParams
+		require.NoError(t, json.Unmarshal(mySpriteCodeLenses[0].Command.Arguments[0], &runSpriteParams))
+		assert.Equal(t, SpxResourceURI("spx://resources/sprites/MySprite"), runSpriteParams.Sprite.URI)
+	})
+
+	t.Run("NonExistentDocument", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		codeLenses, err := s.textDocumentCodeLens(context.Background(), &CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, codeLenses)
+	})
+}
@@ -1,46 +1,45 @@
 package server
 
 import (
-	"encoding/json"
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"go/types"
+	"maps"
 	"slices"
 	"strings"
 
 	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/jsonrpc2"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_executeCommand
-func (s *Server) workspaceExecuteCommand(params *ExecuteCommandParams) (any, error) {
-	switch params.Command {
-	case "spx.renameResources":
-		var cmdParams []SpxRenameResourceParams
-		for _, arg := range params.Arguments {
-			var cmdParam SpxRenameResourceParams
-			if err := json.Unmarshal(arg, &cmdParam); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal command argument as SpxRenameResourceParams: %w", err)
-			}
-			cmdParams = append(cmdParams, cmdParam)
-		}
-		return s.spxRenameResources(cmdParams)
-	case "spx.getDefinitions":
-		var cmdParams []SpxGetDefinitionsParams
-		for _, arg := range params.Arguments {
-			var cmdParam SpxGetDefinitionsParams
-			if err := json.Unmarshal(arg, &cmdParam); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal command argument as SpxGetDefinitionsParams: %w", err)
-			}
-			cmdParams = append(cmdParams, cmdParam)
+func (s *Server) workspaceExecuteCommand(ctx context.Context, params *ExecuteCommandParams) (any, error) {
+	result, err := s.executeCommand(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if edit, ok := result.(*WorkspaceEdit); ok {
+		if err := s.checkWritable(edit); err != nil {
+			return nil, err
 		}
-		return s.spxGetDefinitions(cmdParams)
 	}
-	return nil, fmt.Errorf("unknown command: %s", params.Command)
+	return result, nil
+}
+
+// executeCommand executes the command of the given params.
+func (s *Server) executeCommand(ctx context.Context, params *ExecuteCommandParams) (any, error) {
+	cmd := lookupSpxCommand(params.Command)
+	if cmd == nil {
+		return nil, fmt.Errorf("unknown command: %s", params.Command)
+	}
+	return cmd.execute(s, ctx, params.Arguments)
 }
 
 // spxRenameResources renames spx resources in the workspace.
-func (s *Server) spxRenameResources(params []SpxRenameResourceParams) (*WorkspaceEdit, error) {
-	result, err := s.compile()
+func (s *Server) spxRenameResources(ctx context.Context, params []SpxRenameResourceParams) (*WorkspaceEdit, error) {
+	result, err := s.compile(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -58,6 +57,9 @@ func (s *Server) spxRenameResourcesWithCompileResult(result *compileResult, para
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
 		}
+		if err := checkSpxResourceName(param.NewName); err != nil {
+			return nil, fmt.Errorf("%w: %w", jsonrpc2.ErrInvalidParams, err)
+		}
 		var changes map[DocumentURI][]TextEdit
 		switch id := id.(type) {
 		case SpxBackdropResourceID:
@@ -95,8 +97,101 @@ func (s *Server) spxRenameResourcesWithCompileResult(result *compileResult, para
 	return &workspaceEdit, nil
 }
 
+// spxRenameResource renames an spx resource in the workspace. Unlike
+// [Server.spxRenameResources], the returned [WorkspaceEdit] also covers the
+// resource metadata files, so the whole rename can be applied atomically.
+func (s *Server) spxRenameResource(ctx context.Context, params []SpxRenameResourceParams) (*WorkspaceEdit, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.renameResource only supports one resource at a time")
+	}
+
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.spxRenameResourceWithCompileResult(result, params[0])
+}
+
+// spxRenameResourceWithCompileResult renames an spx resource in the workspace
+// with the given compile result, covering both the code and the resource
+// metadata files.
+func (s *Server) spxRenameResourceWithCompileResult(result *compileResult, param SpxRenameResourceParams) (*WorkspaceEdit, error) {
+	codeEdit, err := s.spxRenameResourcesWithCompileResult(result, []SpxRenameResourceParams{param})
+	if err != nil {
+		return nil, err
+	}
+	id, err := ParseSpxResourceURI(param.Resource.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+	metadataChanges, err := s.spxRenameResourceMetadata(result, id, param.NewName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename spx resource %q: %w", param.Resource.URI, err)
+	}
+
+	// Text edits must come first, as some of them may target files that are
+	// renamed by the metadata changes.
+	documentURIs := slices.Sorted(maps.Keys(codeEdit.Changes))
+	documentChanges := make([]DocumentChange, 0, len(documentURIs)+len(metadataChanges))
+	for _, documentURI := range documentURIs {
+		documentChanges = append(documentChanges, newTextDocumentEditChange(documentURI, codeEdit.Changes[documentURI]))
+	}
+	documentChanges = append(documentChanges, metadataChanges...)
+	return &WorkspaceEdit{DocumentChanges: documentChanges}, nil
+}
+
+// spxPreviewRenameResource reports the impact of renaming an spx resource
+// without renaming it, so clients can warn about what will break beforehand.
+func (s *Server) spxPreviewRenameResource(ctx context.Context, params []SpxRenameResourceParams) (*SpxRenameResourcePreview, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.previewRenameResource only supports one resource at a time")
+	}
+	param := params[0]
+
+	id, err := ParseSpxResourceURI(param.Resource.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes map[DocumentURI][]TextEdit
+	if spriteID, ok := id.(SpxSpriteResourceID); ok {
+		changes = s.spxRenameSpriteResourceEdits(result, spriteID, param.NewName)
+	} else {
+		changes = s.spxRenameResourceAtRefs(result, id, param.NewName)
+	}
+	preview := &SpxRenameResourcePreview{
+		Locations:          []Location{},
+		Conflicts:          spxResourceRenameConflicts(result, id, param.NewName),
+		ChangesAutoBinding: hasSpxResourceAutoBinding(result, id),
+	}
+	if preview.Conflicts == nil {
+		preview.Conflicts = []string{}
+	}
+	for _, documentURI := range slices.Sorted(maps.Keys(changes)) {
+		textEdits := changes[documentURI]
+		slices.SortFunc(textEdits, func(a, b TextEdit) int {
+			return cmp.Or(
+				cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+				cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+			)
+		})
+		for _, textEdit := range textEdits {
+			preview.Locations = append(preview.Locations, Location{URI: documentURI, Range: textEdit.Range})
+		}
+	}
+	return preview, nil
+}
+
 // spxGetDefinitions gets spx definitions at a specific position in a document.
-func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefinitionIdentifier, error) {
+func (s *Server) spxGetDefinitions(ctx context.Context, params []SpxGetDefinitionsParams) ([]SpxDefinitionIdentifier, error) {
 	if l := len(params); l == 0 {
 		return nil, nil
 	} else if l > 1 {
@@ -104,7 +199,7 @@ func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefin
 	}
 	param := params[0]
 
-	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(param.TextDocument.URI)
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, param.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -189,3 +284,248 @@ func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefin
 
 	return defIDs, nil
 }
+
+// spxOrganizeImports organizes the imports of a document.
+func (s *Server) spxOrganizeImports(ctx context.Context, params []SpxOrganizeImportsParams) (*WorkspaceEdit, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.organizeImports only supports one document at a time")
+	}
+	param := params[0]
+
+	edits, err := s.spxOrganizeImportsEdits(ctx, param.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			param.TextDocument.URI: edits,
+		},
+	}, nil
+}
+
+// spxRunProject checks that the project can be run. The project itself is run
+// by the client, which owns the spx runtime.
+func (s *Server) spxRunProject(ctx context.Context) (any, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.hasErrorSeverityDiagnostic {
+		return nil, errors.New("cannot run project with errors")
+	}
+	return nil, nil
+}
+
+// spxRunSprite checks that the given sprite can be run. Like
+// [Server.spxRunProject], the sprite itself is run by the client.
+func (s *Server) spxRunSprite(ctx context.Context, params []SpxRunSpriteParams) (any, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.runSprite only supports one sprite at a time")
+	}
+	param := params[0]
+
+	id, err := ParseSpxResourceURI(param.Sprite.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+	spriteID, ok := id.(SpxSpriteResourceID)
+	if !ok {
+		return nil, fmt.Errorf("expected spx sprite resource, got %T", id)
+	}
+
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.spxResourceSet.Sprite(spriteID.SpriteName) == nil {
+		return nil, fmt.Errorf("sprite resource %q not found", spriteID.SpriteName)
+	}
+	if result.hasErrorSeverityDiagnostic {
+		return nil, errors.New("cannot run project with errors")
+	}
+	return nil, nil
+}
+
+// spxGetResourceDetail returns the detail of the given spx resource, including
+// the size, bitmap resolution and rotation center of its image and the frames
+// of animations. It returns nil if the resource does not exist.
+func (s *Server) spxGetResourceDetail(ctx context.Context, params []SpxGetResourceDetailParams) (*SpxResourceDetail, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.getResourceDetail only supports one resource at a time")
+	}
+	param := params[0]
+
+	id, err := ParseSpxResourceURI(param.Resource.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !result.spxResourceSet.Contains(id) {
+		return nil, nil
+	}
+
+	detail := &SpxResourceDetail{
+		Resource: SpxResourceIdentifier{URI: id.URI()},
+		Kind:     spxResourceKind(id),
+	}
+	def, err := s.spxResourceDefinitionLocation(result, id)
+	if err != nil {
+		return nil, err
+	}
+	if loc, ok := def.(Location); ok {
+		detail.Location = &loc
+	}
+	if image := spxResourceImageOf(result, id); image != nil {
+		imageDetail := &SpxResourceImageDetail{
+			URI:              s.toDocumentURI(image.path),
+			BitmapResolution: image.scale(),
+			FaceRight:        image.faceRight,
+		}
+		if width, height, ok := image.size(result.proj); ok {
+			imageDetail.Width, imageDetail.Height = width, height
+		}
+		if image.hasPivot {
+			x, y := image.pivot()
+			imageDetail.Pivot = &SpxPoint{X: x, Y: y}
+		}
+		detail.Image = imageDetail
+	}
+	if animationID, ok := id.(SpxSpriteAnimationResourceID); ok {
+		sprite := result.spxResourceSet.Sprite(animationID.SpriteName)
+		for _, costume := range sprite.Animation(animationID.AnimationName).Costumes(sprite) {
+			detail.Frames = append(detail.Frames, SpxResourceIdentifier{URI: costume.ID.URI()})
+		}
+	}
+	return detail, nil
+}
+
+// spxGenerateBindings declares the missing auto-binding variables of all
+// sprite and sound resources in the main spx file. It returns nil if there is
+// nothing to declare.
+func (s *Server) spxGenerateBindings(ctx context.Context) (*WorkspaceEdit, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	edits := s.spxGenerateBindingsEdits(result)
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			s.toDocumentURI(result.mainSpxFile): edits,
+		},
+	}, nil
+}
+
+// spxGetUnusedResources returns the backdrops, sounds, sprite costumes and
+// widgets that are never referenced from code, sorted by URI.
+func (s *Server) spxGetUnusedResources(ctx context.Context) ([]SpxResourceIdentifier, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result.unusedSpxResources(), nil
+}
+
+// unusedSpxResources returns the backdrops, sounds, sprite costumes and
+// widgets that are never referenced from code, sorted by URI.
+func (r *compileResult) unusedSpxResources() []SpxResourceIdentifier {
+	set := r.spxResourceSet
+
+	usedURIs := make(map[SpxResourceURI]struct{}, len(r.spxResourceRefs))
+	for _, ref := range r.spxResourceRefs {
+		usedURIs[ref.ID.URI()] = struct{}{}
+	}
+	// Resources used implicitly by the spx runtime, i.e. the default backdrop
+	// and the default costume of each sprite, are never unused.
+	if backdrop := set.DefaultBackdrop(); backdrop != nil {
+		usedURIs[backdrop.ID.URI()] = struct{}{}
+	}
+	for _, sprite := range set.sprites {
+		if idx := sprite.CostumeIndex; idx >= 0 && idx < len(sprite.Costumes) {
+			usedURIs[sprite.Costumes[idx].ID.URI()] = struct{}{}
+		}
+	}
+
+	var unusedIDs []SpxResourceID
+	for _, backdrop := range set.backdrops {
+		unusedIDs = append(unusedIDs, backdrop.ID)
+	}
+	for _, sound := range set.sounds {
+		unusedIDs = append(unusedIDs, sound.ID)
+	}
+	for _, sprite := range set.sprites {
+		// Costumes of animations are used by the animations.
+		for _, costume := range sprite.NormalCostumes {
+			unusedIDs = append(unusedIDs, costume.ID)
+		}
+	}
+	for _, widget := range set.widgets {
+		unusedIDs = append(unusedIDs, widget.ID)
+	}
+
+	var unused []SpxResourceIdentifier
+	for _, id := range unusedIDs {
+		if _, ok := usedURIs[id.URI()]; ok {
+			continue
+		}
+		unused = append(unused, SpxResourceIdentifier{URI: id.URI()})
+	}
+	slices.SortFunc(unused, func(a, b SpxResourceIdentifier) int {
+		return strings.Compare(string(a.URI), string(b.URI))
+	})
+	return unused
+}
+
+// spxGetResourceReferences returns the references to each existing spx
+// resource in the workspace, grouped by resource and sorted by resource URI.
+// Resources that are never referenced are omitted.
+func (s *Server) spxGetResourceReferences(ctx context.Context) ([]SpxResourceReferences, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refsByURI := make(map[SpxResourceURI][]SpxResourceReference)
+	for _, ref := range result.spxResourceRefs {
+		if !result.spxResourceSet.Contains(ref.ID) {
+			continue
+		}
+		uri := ref.ID.URI()
+		refsByURI[uri] = append(refsByURI[uri], SpxResourceReference{
+			Location: result.locationForNode(ref.Node),
+			Kind:     ref.Kind,
+		})
+	}
+
+	var refs []SpxResourceReferences
+	for _, uri := range slices.Sorted(maps.Keys(refsByURI)) {
+		resourceRefs := refsByURI[uri]
+		slices.SortFunc(resourceRefs, func(a, b SpxResourceReference) int {
+			return cmp.Or(
+				strings.Compare(string(a.URI), string(b.URI)),
+				cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+				cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+			)
+		})
+		refs = append(refs, SpxResourceReferences{
+			Resource:   SpxResourceIdentifier{URI: uri},
+			References: resourceRefs,
+		})
+	}
+	return refs, nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SpxCommandDescription describes a command that can be executed with
+// workspace/executeCommand. Descriptions of all commands are advertised as
+// the "spxCommands" experimental server capability.
+type SpxCommandDescription struct {
+	// The name of the command, e.g., "spx.renameResources".
+	Command string `json:"command"`
+
+	// The JSON schema of each argument of the command. It is omitted if the
+	// command takes no arguments.
+	ArgumentSchema map[string]any `json:"argumentSchema,omitempty"`
+}
+
+// spxCommand is a command that can be executed with workspace/executeCommand.
+type spxCommand struct {
+	// name is the name of the command, e.g., "spx.renameResources".
+	name string
+
+	// argumentSchema is the JSON schema of each argument of the command, or
+	// nil if the command takes no arguments.
+	argumentSchema map[string]any
+
+	// execute executes the command with the given arguments.
+	execute func(s *Server, ctx context.Context, args []json.RawMessage) (any, error)
+}
+
+// newSpxCommand returns a command with the given name whose arguments are
+// each unmarshaled as P and passed to the given handler.
+func newSpxCommand[P, R any](name string, handler func(s *Server, ctx context.Context, params []P) (R, error)) *spxCommand {
+	paramsType := reflect.TypeFor[P]()
+	return &spxCommand{
+		name:           name,
+		argumentSchema: jsonSchemaOf(paramsType),
+		execute: func(s *Server, ctx context.Context, args []json.RawMessage) (any, error) {
+			params := make([]P, 0, len(args))
+			for _, arg := range args {
+				var param P
+				if err := json.Unmarshal(arg, &param); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal command argument as %s: %w", paramsType.Name(), err)
+				}
+				params = append(params, param)
+			}
+			return handler(s, ctx, params)
+		},
+	}
+}
+
+// newSpxCommandWithoutArgs returns a command with the given name that takes
+// no arguments and is executed by the given handler. Arguments passed to it
+// are ignored.
+func newSpxCommandWithoutArgs[R any](name string, handler func(s *Server, ctx context.Context) (R, error)) *spxCommand {
+	return &spxCommand{
+		name: name,
+		execute: func(s *Server, ctx context.Context, args []json.RawMessage) (any, error) {
+			return handler(s, ctx)
+		},
+	}
+}
+
+// spxCommands is the registry of commands that can be executed with
+// workspace/executeCommand.
+var spxCommands = []*spxCommand{
+	newSpxCommand("spx.renameResources", (*Server).spxRenameResources),
+	newSpxCommand("spx.renameResource", (*Server).spxRenameResource),
+	newSpxCommand("spx.previewRenameResource", (*Server).spxPreviewRenameResource),
+	newSpxCommand("spx.getDefinitions", (*Server).spxGetDefinitions),
+	newSpxCommand("spx.organizeImports", (*Server).spxOrganizeImports),
+	newSpxCommandWithoutArgs("spx.getUnusedResources", (*Server).spxGetUnusedResources),
+	newSpxCommandWithoutArgs("spx.getResourceReferences", (*Server).spxGetResourceReferences),
+	newSpxCommand("spx.getResourceDetail", (*Server).spxGetResourceDetail),
+	newSpxCommandWithoutArgs("spx.generateBindings", (*Server).spxGenerateBindings),
+	newSpxCommand("spx.addDependency", (*Server).spxAddDependency),
+	newSpxCommandWithoutArgs("spx.runProject", (*Server).spxRunProject),
+	newSpxCommand("spx.runSprite", (*Server).spxRunSprite),
+}
+
+// lookupSpxCommand returns the command with the given name. It returns nil if
+// there is no such command.
+func lookupSpxCommand(name string) *spxCommand {
+	for _, cmd := range spxCommands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// spxCommandNames returns the names of all commands in the registry.
+func spxCommandNames() []string {
+	names := make([]string, 0, len(spxCommands))
+	for _, cmd := range spxCommands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// spxCommandDescriptions returns the descriptions of all commands in the
+// registry.
+func spxCommandDescriptions() []SpxCommandDescription {
+	descs := make([]SpxCommandDescription, 0, len(spxCommands))
+	for _, cmd := range spxCommands {
+		descs = append(descs, SpxCommandDescription{
+			Command:        cmd.name,
+			ArgumentSchema: cmd.argumentSchema,
+		})
+	}
+	return descs
+}
+
+// jsonSchemaOf returns the JSON schema of the JSON encoding of values of the
+// given type. Fields of structs are required unless tagged with omitempty.
+func jsonSchemaOf(typ reflect.Type) map[string]any {
+	if typ == reflect.TypeFor[json.RawMessage]() {
+		return map[string]any{}
+	}
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(typ.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(typ.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(typ.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		addJSONSchemaProperties(typ, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{} // Any value, e.g., of an interface type.
+}
+
+// addJSONSchemaProperties adds the JSON schemas of the fields of the given
+// struct type to properties, and the names of the required ones to required.
+// Fields of embedded structs without JSON names are added as if they were
+// fields of the struct, like encoding/json does.
+func addJSONSchemaProperties(typ reflect.Type, properties map[string]any, required *[]string) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addJSONSchemaProperties(fieldType, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchemaOf(field.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpxCommandRegistry(t *testing.T) {
+	t.Run("UniqueNames", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for _, name := range spxCommandNames() {
+			assert.False(t, seen[name], "duplicate command %s", name)
+			seen[name] = true
+			assert.NotNil(t, lookupSpxCommand(name))
+		}
+		assert.Nil(t, lookupSpxCommand("spx.unknown"))
+	})
+
+	t.Run("Advertised", func(t *testing.T) {
+		m := map[string][]byte{"main.spx": []byte(`echo "main"`)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		initResult, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		require.NotNil(t, initResult.Capabilities.ExecuteCommandProvider)
+		assert.Contains(t, initResult.Capabilities.ExecuteCommandProvider.Commands, "spx.renameResources")
+		assert.Contains(t, initResult.Capabilities.ExecuteCommandProvider.Commands, "spx.generateBindings")
+
+		data, err := json.Marshal(initResult.Capabilities.Experimental)
+		require.NoError(t, err)
+		var experimental struct {
+			SpxCommands []SpxCommandDescription `json:"spxCommands"`
+		}
+		require.NoError(t, json.Unmarshal(data, &experimental))
+		require.Len(t, experimental.SpxCommands, len(spxCommands))
+		for _, desc := range experimental.SpxCommands {
+			switch desc.Command {
+			case "spx.renameResources":
+				assert.Equal(t, "object", desc.ArgumentSchema["type"])
+				assert.ElementsMatch(t, []any{"resource", "newName"}, desc.ArgumentSchema["required"])
+			case "spx.generateBindings":
+				assert.Nil(t, desc.ArgumentSchema)
+			}
+		}
+	})
+
+	t.Run("UnknownCommand", func(t *testing.T) {
+		m := map[string][]byte{"main.spx": []byte(`echo "main"`)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		_, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.unknown"})
+		assert.EqualError(t, err, "unknown command: spx.unknown")
+	})
+
+	t.Run("InvalidArgument", func(t *testing.T) {
+		m := map[string][]byte{"main.spx": []byte(`echo "main"`)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		_, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.getResourceDetail",
+			Arguments: []json.RawMessage{json.RawMessage(`"spx://resources/sounds/MySound"`)},
+		})
+		assert.ErrorContains(t, err, "failed to unmarshal command argument as SpxGetResourceDetailParams")
+	})
+}
+
+func TestJSONSchemaOf(t *testing.T) {
+	type embedded struct {
+		Line uint32 `json:"line"`
+	}
+	type params struct {
+		embedded
+		Name     string             `json:"name"`
+		Tags     []string           `json:"tags,omitempty"`
+		Scores   map[string]float64 `json:"scores"`
+		Enabled  *bool              `json:"enabled,omitempty"`
+		Raw      json.RawMessage    `json:"raw,omitempty"`
+		Ignored  int                `json:"-"`
+		internal int
+	}
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"line":    map[string]any{"type": "integer"},
+			"name":    map[string]any{"type": "string"},
+			"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"scores":  map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number"}},
+			"enabled": map[string]any{"type": "boolean"},
+			"raw":     map[string]any{},
+		},
+		"required": []string{"line", "name", "scores"},
+	}, jsonSchemaOf(reflect.TypeFor[params]()))
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
 	"slices"
 	"testing"
 
 	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/jsonrpc2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,7 +43,7 @@ onStart => {
 				},
 			},
 		}
-		mainSpxFileScopeDefs, err := s.spxGetDefinitions(mainSpxFileScopeParams)
+		mainSpxFileScopeDefs, err := s.spxGetDefinitions(context.Background(), mainSpxFileScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mainSpxFileScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mainSpxFileScopeDefs, SpxDefinitionIdentifier{
@@ -79,7 +85,7 @@ onStart => {
 				},
 			},
 		}
-		mySpriteSpxFileScopeDefs, err := s.spxGetDefinitions(mySpriteSpxFileScopeParams)
+		mySpriteSpxFileScopeDefs, err := s.spxGetDefinitions(context.Background(), mySpriteSpxFileScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mySpriteSpxFileScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mySpriteSpxFileScopeDefs, SpxDefinitionIdentifier{
@@ -117,7 +123,7 @@ onStart => {
 				},
 			},
 		}
-		mySpriteSpxOnStartScopeDefs, err := s.spxGetDefinitions(mySpriteSpxOnStartScopeParams)
+		mySpriteSpxOnStartScopeDefs, err := s.spxGetDefinitions(context.Background(), mySpriteSpxOnStartScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mySpriteSpxOnStartScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mySpriteSpxOnStartScopeDefs, SpxDefinitionIdentifier{
@@ -175,7 +181,7 @@ var (
 				},
 			},
 		}
-		mainSpxFileScopeDefs, err := s.spxGetDefinitions(mainSpxFileScopeParams)
+		mainSpxFileScopeDefs, err := s.spxGetDefinitions(context.Background(), mainSpxFileScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mainSpxFileScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mainSpxFileScopeDefs, SpxDefinitionIdentifier{
@@ -225,7 +231,7 @@ onStart => {
 				},
 			},
 		}
-		mySpriteSpxFileScopeDefs, err := s.spxGetDefinitions(mySpriteSpxFileScopeParams)
+		mySpriteSpxFileScopeDefs, err := s.spxGetDefinitions(context.Background(), mySpriteSpxFileScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mySpriteSpxFileScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mySpriteSpxFileScopeDefs, SpxDefinitionIdentifier{
@@ -263,7 +269,7 @@ onStart => {}
 				},
 			},
 		}
-		mainSpxOnStartScopeDefs, err := s.spxGetDefinitions(mainSpxOnStartScopeParams)
+		mainSpxOnStartScopeDefs, err := s.spxGetDefinitions(context.Background(), mainSpxOnStartScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mainSpxOnStartScopeDefs)
 		assert.False(t, spxDefinitionIdentifierSliceContains(mainSpxOnStartScopeDefs, SpxDefinitionIdentifier{
@@ -279,7 +285,7 @@ onStart => {}
 				},
 			},
 		}
-		mainSpxFileScopeDefs, err := s.spxGetDefinitions(mainSpxFileScopeParams)
+		mainSpxFileScopeDefs, err := s.spxGetDefinitions(context.Background(), mainSpxFileScopeParams)
 		require.NoError(t, err)
 		require.NotNil(t, mainSpxFileScopeDefs)
 		assert.True(t, spxDefinitionIdentifierSliceContains(mainSpxFileScopeDefs, SpxDefinitionIdentifier{
@@ -312,7 +318,7 @@ onTouchStart "" => {
 				},
 			},
 		}
-		defs, err := s.spxGetDefinitions(params)
+		defs, err := s.spxGetDefinitions(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, defs)
 		assert.False(t, spxDefinitionIdentifierSliceContains(defs, SpxDefinitionIdentifier{
@@ -336,3 +342,966 @@ func spxDefinitionIdentifierSliceContains(defs []SpxDefinitionIdentifier, def Sp
 			util.FromPtr(d.OverloadID) == util.FromPtr(def.OverloadID)
 	})
 }
+
+func TestServerSpxRenameResource(t *testing.T) {
+	t.Run("Sprite", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Right
+	println "MySprite"
+}
+`),
+			"assets/index.json": []byte(`{
+  "zorder": [
+    "MySprite",
+    {"name": "MyWidget", "type": "monitor"}
+  ]
+}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"},
+				NewName:  "NewSprite",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		assert.Nil(t, workspaceEdit.Changes)
+		require.Len(t, workspaceEdit.DocumentChanges, 5)
+
+		textEditsOf := func(change DocumentChange) []TextEdit {
+			require.NotNil(t, change.TextDocumentEdit)
+			var textEdits []TextEdit
+			for _, edit := range change.TextDocumentEdit.Edits {
+				textEdits = append(textEdits, edit.Value.(TextEdit))
+			}
+			return textEdits
+		}
+
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 9},
+				},
+				NewText: "NewSprite",
+			},
+		}, textEditsOf(workspaceEdit.DocumentChanges[0]))
+
+		assert.Equal(t, DocumentURI("file:///main.spx"), workspaceEdit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+		assert.Len(t, textEditsOf(workspaceEdit.DocumentChanges[1]), 2)
+
+		assert.Equal(t, DocumentURI("file:///assets/index.json"), workspaceEdit.DocumentChanges[2].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 5},
+					End:   Position{Line: 2, Character: 13},
+				},
+				NewText: "NewSprite",
+			},
+		}, textEditsOf(workspaceEdit.DocumentChanges[2]))
+
+		assert.Equal(t, &RenameFile{
+			Kind:   "rename",
+			OldURI: "file:///assets/sprites/MySprite",
+			NewURI: "file:///assets/sprites/NewSprite",
+		}, workspaceEdit.DocumentChanges[3].RenameFile)
+		assert.Equal(t, &RenameFile{
+			Kind:   "rename",
+			OldURI: "file:///MySprite.spx",
+			NewURI: "file:///NewSprite.spx",
+		}, workspaceEdit.DocumentChanges[4].RenameFile)
+	})
+
+	t.Run("Sound", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+				NewName:  "Sound2",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 2)
+		require.NotNil(t, workspaceEdit.DocumentChanges[0].TextDocumentEdit)
+		assert.Equal(t, &RenameFile{
+			Kind:   "rename",
+			OldURI: "file:///assets/sounds/Sound1",
+			NewURI: "file:///assets/sounds/Sound2",
+		}, workspaceEdit.DocumentChanges[1].RenameFile)
+	})
+
+	t.Run("InvalidNewName", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		for _, newName := range []string{"", "a/b", `a\b`, "..", "../x"} {
+			workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+				{
+					Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"},
+					NewName:  newName,
+				},
+			})
+			assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, newName)
+			assert.Nil(t, workspaceEdit, newName)
+		}
+	})
+
+	t.Run("Backdrop", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+startBackdrop "Backdrop1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{
+  "backdrops": [
+    {"name": "Backdrop1", "path": "backdrop1.png"}
+  ]
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/backdrops/Backdrop1"},
+				NewName:  "Backdrop2",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 2)
+		assert.Equal(t, DocumentURI("file:///main.spx"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		require.NotNil(t, workspaceEdit.DocumentChanges[1].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/index.json"), workspaceEdit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []Or_TextDocumentEdit_edits_Elem{
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 2, Character: 14},
+					End:   Position{Line: 2, Character: 23},
+				},
+				NewText: "Backdrop2",
+			}},
+		}, workspaceEdit.DocumentChanges[1].TextDocumentEdit.Edits)
+	})
+
+	t.Run("SoundPlayedByAnimation", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	play "Sound1"
+}
+`),
+			"assets/index.json":               []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumes": [{"name": "c0"}, {"name": "c1"}],
+  "fAnimations": {
+    "walk": {"frameFrom": "c0", "frameTo": "c1", "onStart": {"play": "Sound1"}}
+  }
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+				NewName:  "Sound2",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 3)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		require.NotNil(t, workspaceEdit.DocumentChanges[1].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/sprites/MySprite/index.json"), workspaceEdit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []Or_TextDocumentEdit_edits_Elem{
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 3, Character: 70},
+					End:   Position{Line: 3, Character: 76},
+				},
+				NewText: "Sound2",
+			}},
+		}, workspaceEdit.DocumentChanges[1].TextDocumentEdit.Edits)
+		assert.Equal(t, &RenameFile{
+			Kind:   "rename",
+			OldURI: "file:///assets/sounds/Sound1",
+			NewURI: "file:///assets/sounds/Sound2",
+		}, workspaceEdit.DocumentChanges[2].RenameFile)
+	})
+
+	t.Run("Costume", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume "c0"
+}
+`),
+			"assets/index.json": []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumes": [{"name": "c0"}, {"name": "c1"}],
+  "fAnimations": {"walk": {"frameFrom": "c0", "frameTo": "c1"}}
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/costumes/c0"},
+				NewName:  "idle",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 2)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		require.NotNil(t, workspaceEdit.DocumentChanges[1].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/sprites/MySprite/index.json"), workspaceEdit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []Or_TextDocumentEdit_edits_Elem{
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 1, Character: 25},
+					End:   Position{Line: 1, Character: 27},
+				},
+				NewText: "idle",
+			}},
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 2, Character: 41},
+					End:   Position{Line: 2, Character: 43},
+				},
+				NewText: "idle",
+			}},
+		}, workspaceEdit.DocumentChanges[1].TextDocumentEdit.Edits)
+	})
+
+	t.Run("CostumeOfCostumeGroup", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":      []byte(``),
+			"assets/index.json": []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumeSet": {"path": "walk.png", "nx": 2, "items": [{"namePrefix": "walk", "n": 2}]}
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/costumes/walk0"},
+				NewName:  "idle",
+			},
+		})
+		require.EqualError(t, err, `failed to rename spx resource "spx://resources/sprites/MySprite/costumes/walk0": failed to parse assets/sprites/MySprite/index.json: sprite costume resource "walk0" is part of a costume group and cannot be renamed`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("Animation", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	animate "walk"
+}
+`),
+			"assets/index.json": []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumes": [{"name": "c0"}, {"name": "c1"}],
+  "fAnimations": {"idle": {}, "walk": {"frameFrom": "c0", "frameTo": "c1"}},
+  "defaultAnimation": "walk",
+  "animBindings": {"step": "walk"}
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/animations/walk"},
+				NewName:  "run",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 2)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		require.NotNil(t, workspaceEdit.DocumentChanges[1].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/sprites/MySprite/index.json"), workspaceEdit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []Or_TextDocumentEdit_edits_Elem{
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 2, Character: 31},
+					End:   Position{Line: 2, Character: 35},
+				},
+				NewText: "run",
+			}},
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 3, Character: 23},
+					End:   Position{Line: 3, Character: 27},
+				},
+				NewText: "run",
+			}},
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 4, Character: 28},
+					End:   Position{Line: 4, Character: 32},
+				},
+				NewText: "run",
+			}},
+		}, workspaceEdit.DocumentChanges[1].TextDocumentEdit.Edits)
+	})
+
+	t.Run("Widget", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{
+  "zorder": [
+    {"name": "MyWidget", "type": "monitor"}
+  ]
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/widgets/MyWidget"},
+				NewName:  "Score",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.Len(t, workspaceEdit.DocumentChanges, 1)
+		require.NotNil(t, workspaceEdit.DocumentChanges[0].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/index.json"), workspaceEdit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+		assert.Equal(t, []Or_TextDocumentEdit_edits_Elem{
+			{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 2, Character: 14},
+					End:   Position{Line: 2, Character: 22},
+				},
+				NewText: "Score",
+			}},
+		}, workspaceEdit.DocumentChanges[0].TextDocumentEdit.Edits)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), []SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"},
+				NewName:  "NewSprite",
+			},
+		})
+		require.EqualError(t, err, `failed to rename spx resource "spx://resources/sprites/MySprite": sprite resource "MySprite" not found`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("MultipleResources", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil, fileMapGetter(map[string][]byte{}))
+
+		workspaceEdit, err := s.spxRenameResource(context.Background(), make([]SpxRenameResourceParams, 2))
+		require.EqualError(t, err, "spx.renameResource only supports one resource at a time")
+		require.Nil(t, workspaceEdit)
+	})
+}
+
+func TestServerSpxRunSprite(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx": []byte(`
+onStart => {
+	say "Hi"
+}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	t.Run("Normal", func(t *testing.T) {
+		_, err := s.spxRunSprite(context.Background(), []SpxRunSpriteParams{
+			{Sprite: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"}},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := s.spxRunSprite(context.Background(), []SpxRunSpriteParams{
+			{Sprite: SpxResourceIdentifier{URI: "spx://resources/sprites/NotFound"}},
+		})
+		require.EqualError(t, err, `sprite resource "NotFound" not found`)
+	})
+
+	t.Run("NonSpriteResource", func(t *testing.T) {
+		_, err := s.spxRunSprite(context.Background(), []SpxRunSpriteParams{
+			{Sprite: SpxResourceIdentifier{URI: "spx://resources/sounds/MySound"}},
+		})
+		require.EqualError(t, err, "expected spx sprite resource, got server.SpxSoundResourceID")
+	})
+}
+
+func TestServerSpxRunProject(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		_, err := s.spxRunProject(context.Background())
+		require.NoError(t, err)
+	})
+
+	t.Run("CompileError", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var x int = "hello"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		_, err := s.spxRunProject(context.Background())
+		require.EqualError(t, err, "cannot run project with errors")
+	})
+}
+
+func TestServerSpxGetResourceDetail(t *testing.T) {
+	var costumePNG bytes.Buffer
+	require.NoError(t, png.Encode(&costumePNG, image.NewRGBA(image.Rect(0, 0, 120, 80))))
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                         []byte(``),
+		"assets/index.json":                    []byte(`{}`),
+		"assets/sounds/MySound/index.json":     []byte(`{"path":"MySound.wav"}`),
+		"assets/sprites/MySprite/index.json":   []byte(`{"costumes":[{"name":"costume1","path":"costume1.png","bitmapResolution":2,"x":60,"y":41,"faceRight":90},{"name":"walk1"},{"name":"walk2"}],"costumeIndex":0,"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk2"}}}`),
+		"assets/sprites/MySprite/costume1.png": costumePNG.Bytes(),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	getResourceDetail := func(uri SpxResourceURI) *SpxResourceDetail {
+		arg, err := json.Marshal(SpxGetResourceDetailParams{Resource: SpxResourceIdentifier{URI: uri}})
+		require.NoError(t, err)
+		detail, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.getResourceDetail",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.NoError(t, err)
+		return detail.(*SpxResourceDetail)
+	}
+
+	t.Run("Costume", func(t *testing.T) {
+		detail := getResourceDetail("spx://resources/sprites/MySprite/costumes/costume1")
+		require.NotNil(t, detail)
+		assert.Equal(t, "Costume", detail.Kind)
+		require.NotNil(t, detail.Location)
+		assert.Equal(t, DocumentURI("file:///assets/sprites/MySprite/index.json"), detail.Location.URI)
+		assert.Equal(t, &SpxResourceImageDetail{
+			URI:              "file:///assets/sprites/MySprite/costume1.png",
+			Width:            60,
+			Height:           40,
+			BitmapResolution: 2,
+			Pivot:            &SpxPoint{X: 30, Y: 20.5},
+			FaceRight:        90,
+		}, detail.Image)
+	})
+
+	t.Run("Sprite", func(t *testing.T) {
+		detail := getResourceDetail("spx://resources/sprites/MySprite")
+		require.NotNil(t, detail)
+		assert.Equal(t, "Sprite", detail.Kind)
+		require.NotNil(t, detail.Image)
+		assert.Equal(t, &SpxPoint{X: 30, Y: 20.5}, detail.Image.Pivot)
+	})
+
+	t.Run("Animation", func(t *testing.T) {
+		detail := getResourceDetail("spx://resources/sprites/MySprite/animations/walk")
+		require.NotNil(t, detail)
+		assert.Equal(t, "Animation", detail.Kind)
+		assert.Nil(t, detail.Image)
+		assert.Equal(t, []SpxResourceIdentifier{
+			{URI: "spx://resources/sprites/MySprite/costumes/walk1"},
+			{URI: "spx://resources/sprites/MySprite/costumes/walk2"},
+		}, detail.Frames)
+	})
+
+	t.Run("Sound", func(t *testing.T) {
+		detail := getResourceDetail("spx://resources/sounds/MySound")
+		require.NotNil(t, detail)
+		assert.Equal(t, "Sound", detail.Kind)
+		assert.Nil(t, detail.Image)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert.Nil(t, getResourceDetail("spx://resources/sprites/MySprite/costumes/costume2"))
+	})
+
+	t.Run("MultipleResources", func(t *testing.T) {
+		_, err := s.spxGetResourceDetail(context.Background(), make([]SpxGetResourceDetailParams, 2))
+		require.EqualError(t, err, "spx.getResourceDetail only supports one resource at a time")
+	})
+}
+
+func TestServerSpxGenerateBindings(t *testing.T) {
+	newFileMap := func(mainSpx string) map[string][]byte {
+		return map[string][]byte{
+			"main.spx":                              []byte(mainSpx),
+			"MySprite.spx":                          []byte(``),
+			"assets/index.json":                     []byte(`{}`),
+			"assets/sounds/MySound/index.json":      []byte(`{}`),
+			"assets/sounds/my-sound/index.json":     []byte(`{}`),
+			"assets/sprites/MySprite/index.json":    []byte(`{}`),
+			"assets/sprites/OtherSprite/index.json": []byte(`{}`),
+		}
+	}
+	generateBindings := func(t *testing.T, m map[string][]byte) string {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		workspaceEdit, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.generateBindings"})
+		require.NoError(t, err)
+		edit, ok := workspaceEdit.(*WorkspaceEdit)
+		require.True(t, ok)
+		if edit == nil {
+			return string(m["main.spx"])
+		}
+		require.Len(t, edit.Changes, 1)
+		return applyTextEdits(m["main.spx"], edit.Changes["file:///main.spx"])
+	}
+
+	t.Run("ExistingBlock", func(t *testing.T) {
+		m := newFileMap(`
+var (
+	MySprite MySprite
+	score    int
+)
+
+run "assets", {Title: "My Game"}
+`)
+		assert.Equal(t, `
+var (
+	MySprite MySprite
+	score    int
+	OtherSprite Sprite
+	MySound Sound
+)
+
+run "assets", {Title: "My Game"}
+`, generateBindings(t, m))
+	})
+
+	t.Run("SingleVarDecl", func(t *testing.T) {
+		m := newFileMap(`
+var score int
+
+run "assets", {Title: "My Game"}
+`)
+		assert.Equal(t, `
+var (
+	score int
+	MySprite MySprite
+	OtherSprite Sprite
+	MySound Sound
+)
+
+run "assets", {Title: "My Game"}
+`, generateBindings(t, m))
+	})
+
+	t.Run("NoBlock", func(t *testing.T) {
+		m := newFileMap(`import "fmt"
+
+fmt.Println("Hi")
+run "assets", {Title: "My Game"}
+`)
+		assert.Equal(t, `import "fmt"
+
+var (
+	MySprite MySprite
+	OtherSprite Sprite
+	MySound Sound
+)
+
+fmt.Println("Hi")
+run "assets", {Title: "My Game"}
+`, generateBindings(t, m))
+	})
+
+	t.Run("NoBlockNorImports", func(t *testing.T) {
+		m := newFileMap(`run "assets", {Title: "My Game"}
+`)
+		assert.Equal(t, `var (
+	MySprite MySprite
+	OtherSprite Sprite
+	MySound Sound
+)
+
+run "assets", {Title: "My Game"}
+`, generateBindings(t, m))
+	})
+
+	t.Run("NothingMissing", func(t *testing.T) {
+		m := newFileMap(`
+var (
+	MySprite    MySprite
+	OtherSprite Sprite
+	MySound     Sound
+)
+
+run "assets", {Title: "My Game"}
+`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		edit, err := s.spxGenerateBindings(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, edit)
+	})
+}
+
+func TestServerSpxGetUnusedResources(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Sound1   Sound
+)
+onClick => {
+	startBackdrop "backdrop2"
+	play Sound1
+	play "sound2"
+	getWidget Monitor, "widget1"
+}
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume2"
+	animate "walk"
+}
+`),
+		"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1"},{"name":"backdrop2"},{"name":"backdrop3"}],"backdropIndex":0,"zorder":[{"name":"widget1","type":"monitor"},{"name":"widget2","type":"monitor"}]}`),
+		"assets/sounds/sound1/index.json":    []byte(`{}`),
+		"assets/sounds/sound2/index.json":    []byte(`{}`),
+		"assets/sounds/sound3/index.json":    []byte(`{}`),
+		"assets/sounds/Sound1/index.json":    []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"},{"name":"costume3"},{"name":"walk1"},{"name":"walk2"}],"costumeIndex":0,"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk2"}}}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	unused, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.getUnusedResources"})
+	require.NoError(t, err)
+	assert.Equal(t, []SpxResourceIdentifier{
+		{URI: "spx://resources/backdrops/backdrop3"},
+		{URI: "spx://resources/sounds/sound1"},
+		{URI: "spx://resources/sounds/sound3"},
+		{URI: "spx://resources/sprites/MySprite/costumes/costume3"},
+		{URI: "spx://resources/widgets/widget2"},
+	}, unused)
+}
+
+func TestServerSpxGetResourceReferences(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+onClick => {
+	play "sound1"
+	play "sound2"
+}
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx": []byte(`
+onStart => {
+	play "sound1"
+	setCostume "costume1"
+}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sounds/sound1/index.json":    []byte(`{}`),
+		"assets/sounds/sound3/index.json":    []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	refs, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.getResourceReferences"})
+	require.NoError(t, err)
+	assert.Equal(t, []SpxResourceReferences{
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/sound1"},
+			References: []SpxResourceReference{
+				{
+					Location: Location{
+						URI: "file:///MySprite.spx",
+						Range: Range{
+							Start: Position{Line: 2, Character: 6},
+							End:   Position{Line: 2, Character: 14},
+						},
+					},
+					Kind: SpxResourceRefKindStringLiteral,
+				},
+				{
+					Location: Location{
+						URI: "file:///main.spx",
+						Range: Range{
+							Start: Position{Line: 5, Character: 6},
+							End:   Position{Line: 5, Character: 14},
+						},
+					},
+					Kind: SpxResourceRefKindStringLiteral,
+				},
+			},
+		},
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"},
+			References: []SpxResourceReference{
+				{
+					Location: Location{
+						URI: "file:///main.spx",
+						Range: Range{
+							Start: Position{Line: 2, Character: 1},
+							End:   Position{Line: 2, Character: 9},
+						},
+					},
+					Kind: SpxResourceRefKindAutoBinding,
+				},
+			},
+		},
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/costumes/costume1"},
+			References: []SpxResourceReference{
+				{
+					Location: Location{
+						URI: "file:///MySprite.spx",
+						Range: Range{
+							Start: Position{Line: 3, Character: 12},
+							End:   Position{Line: 3, Character: 22},
+						},
+					},
+					Kind: SpxResourceRefKindStringLiteral,
+				},
+			},
+		},
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/stage"},
+			References: []SpxResourceReference{
+				{
+					Location: Location{
+						URI: "file:///main.spx",
+						Range: Range{
+							Start: Position{Line: 8, Character: 4},
+							End:   Position{Line: 8, Character: 12},
+						},
+					},
+					Kind: SpxResourceRefKindStringLiteral,
+				},
+			},
+		},
+	}, refs)
+}
+
+func TestServerSpxPreviewRenameResource(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Other    Other
+	MySound  Sound
+	score    int
+)
+play MySound
+MySprite.setCostume "costume1"
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`echo "MySprite"`),
+		"Other.spx":                          []byte(``),
+		"assets/index.json":                  []byte(`{"zorder":["MySprite","Other"]}`),
+		"assets/sounds/MySound/index.json":   []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`),
+		"assets/sprites/Other/index.json":    []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	preview := func(uri SpxResourceURI, newName string) *SpxRenameResourcePreview {
+		arg, err := json.Marshal(SpxRenameResourceParams{
+			Resource: SpxResourceIdentifier{URI: uri},
+			NewName:  newName,
+		})
+		require.NoError(t, err)
+		got, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.previewRenameResource",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SpxRenameResourcePreview{}, got)
+		return got.(*SpxRenameResourcePreview)
+	}
+
+	t.Run("Sprite", func(t *testing.T) {
+		got := preview("spx://resources/sprites/MySprite", "Hero")
+		assert.Empty(t, got.Conflicts)
+		assert.True(t, got.ChangesAutoBinding)
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 9},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 10},
+					End:   Position{Line: 2, Character: 18},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 8, Character: 0},
+					End:   Position{Line: 8, Character: 8},
+				},
+			},
+		}, got.Locations)
+	})
+
+	t.Run("SpriteConflicts", func(t *testing.T) {
+		got := preview("spx://resources/sprites/MySprite", "Other")
+		assert.Equal(t, []string{
+			`resource "spx://resources/sprites/Other" already exists`,
+			`new name "Other" conflicts with existing Game member "Other"`,
+			`new name "Other" conflicts with existing declaration "Other" in the main package`,
+		}, got.Conflicts)
+		assert.Len(t, got.Locations, 3)
+	})
+
+	t.Run("SoundConflicts", func(t *testing.T) {
+		got := preview("spx://resources/sounds/MySound", "score")
+		assert.Equal(t, []string{`new name "score" conflicts with existing Game member "score"`}, got.Conflicts)
+		assert.True(t, got.ChangesAutoBinding)
+
+		got = preview("spx://resources/sounds/MySound", "my sound")
+		assert.Equal(t, []string{`new name "my sound" is not a valid identifier for auto-binding variable "MySound"`}, got.Conflicts)
+	})
+
+	t.Run("Costume", func(t *testing.T) {
+		got := preview("spx://resources/sprites/MySprite/costumes/costume1", "costume2")
+		assert.Equal(t, []string{`resource "spx://resources/sprites/MySprite/costumes/costume2" already exists`}, got.Conflicts)
+		assert.False(t, got.ChangesAutoBinding)
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 8, Character: 21},
+					End:   Position{Line: 8, Character: 29},
+				},
+			},
+		}, got.Locations)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		got := preview("spx://resources/sounds/NoSound", "NewSound")
+		assert.Equal(t, []string{`resource "spx://resources/sounds/NoSound" does not exist`}, got.Conflicts)
+		assert.Empty(t, got.Locations)
+	})
+}
+
+func TestServerSpxOrganizeImports(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`import (
+	"os"
+	"fmt"
+)
+
+fmt.Println "hi"
+`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	workspaceEdit, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+		Command:   "spx.organizeImports",
+		Arguments: []json.RawMessage{json.RawMessage(`{"textDocument":{"uri":"file:///main.spx"}}`)},
+	})
+	require.NoError(t, err)
+	require.IsType(t, &WorkspaceEdit{}, workspaceEdit)
+	edits := workspaceEdit.(*WorkspaceEdit).Changes["file:///main.spx"]
+	assert.Equal(t, `import "fmt"
+
+fmt.Println "hi"
+`, applyTextEdits(m["main.spx"], edits))
+}
@@ -2,8 +2,12 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"go/constant"
 	"go/types"
+	"log/slog"
+	"maps"
 	"path"
 	"slices"
 	"strconv"
@@ -19,10 +23,11 @@ import (
 	"github.com/goplus/goxlsw/gop/goputil"
 	"github.com/goplus/goxlsw/internal"
 	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
-	"github.com/goplus/goxlsw/internal/analysis/passes/inspect"
+	"github.com/goplus/goxlsw/internal/analysis/cfg"
+	"github.com/goplus/goxlsw/internal/analysis/driver"
 	"github.com/goplus/goxlsw/internal/analysis/protocol"
 	"github.com/goplus/goxlsw/internal/pkgdata"
-	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/goplus/goxlsw/internal/vfs"
 	"github.com/goplus/goxlsw/pkgdoc"
 	"github.com/goplus/mod/gopmod"
@@ -59,9 +64,16 @@ func getASTPkg(proj *gop.Project) *gopast.Package {
 type compileResult struct {
 	proj *gop.Project
 
+	// posEncoding is the position encoding of the protocol positions in the
+	// result.
+	posEncoding position.Encoding
+
 	// mainSpxFile is the main.spx file path.
 	mainSpxFile string
 
+	// spxResourceRootDir is the root directory of spx resources.
+	spxResourceRootDir string
+
 	// spxResourceSet is the set of spx resources.
 	spxResourceSet SpxResourceSet
 
@@ -84,10 +96,23 @@ type compileResult struct {
 	// seenDiagnostics stores already reported diagnostics to avoid duplicates.
 	seenDiagnostics map[DocumentURI]map[string]struct{}
 
+	// quickFixes stores quick fixes suggested by analyzers for each document.
+	quickFixes map[DocumentURI][]quickFix
+
+	// fixAllEdits stores the edits of the quick fixes for each document that
+	// are safe to apply all at once with the source.fixAll code action.
+	fixAllEdits map[DocumentURI][]TextEdit
+
 	// hasErrorSeverityDiagnostic is true if the compile result has any
 	// diagnostics with error severity.
 	hasErrorSeverityDiagnostic bool
 
+	// parseErrorCount is the number of errors of parsing spx source files.
+	parseErrorCount int
+
+	// typeErrorCount is the number of type errors of the package.
+	typeErrorCount int
+
 	// computedCache is the cache for computed results.
 	computedCache compileResultComputedCache
 
@@ -95,6 +120,25 @@ type compileResult struct {
 	documentURIs map[string]DocumentURI
 }
 
+// quickFix represents a fix suggested by an analyzer for a diagnostic.
+type quickFix struct {
+	// diagnostic is the diagnostic the fix addresses.
+	diagnostic Diagnostic
+
+	// title describes the fix.
+	title string
+
+	// edits are the text edits of the fix.
+	edits []TextEdit
+
+	// command is the command executed by the fix after applying edits, if
+	// any.
+	command *Command
+
+	// isPreferred is true if the fix is the only fix for the diagnostic.
+	isPreferred bool
+}
+
 // compileResultComputedCache represents the computed cache for [compileResult].
 type compileResultComputedCache struct {
 	// identsAtASTFileLines stores the identifiers at the given AST file line.
@@ -106,8 +150,8 @@ type compileResultComputedCache struct {
 	// documentLinks stores document links for each document URI.
 	documentLinks sync.Map // map[DocumentURI][]DocumentLink
 
-	// semanticTokens stores semantic tokens for each document URI.
-	semanticTokens sync.Map // map[DocumentURI][]uint32
+	// semanticTokens stores semantic token infos for each document URI.
+	semanticTokens sync.Map // map[DocumentURI][]semanticTokenInfo
 }
 
 // astFileLine represents an AST file line.
@@ -117,12 +161,15 @@ type astFileLine struct {
 }
 
 // newCompileResult creates a new [compileResult].
-func newCompileResult(proj *gop.Project) *compileResult {
+func newCompileResult(proj *gop.Project, posEncoding position.Encoding) *compileResult {
 	return &compileResult{
 		proj:                          proj,
+		posEncoding:                   posEncoding,
 		spxSoundResourceAutoBindings:  make(map[types.Object]struct{}),
 		spxSpriteResourceAutoBindings: make(map[types.Object]struct{}),
 		diagnostics:                   make(map[DocumentURI][]Diagnostic),
+		quickFixes:                    make(map[DocumentURI][]quickFix),
+		fixAllEdits:                   make(map[DocumentURI][]TextEdit),
 		documentURIs:                  make(map[string]DocumentURI),
 	}
 }
@@ -235,6 +282,64 @@ func (r *compileResult) refIdentsFor(obj types.Object) []*gopast.Ident {
 	return idents
 }
 
+// mainPkgNamedTypes returns all named types defined in the main package,
+// including Go+ class file types that have no explicit type declarations.
+func (r *compileResult) mainPkgNamedTypes() []*types.Named {
+	var (
+		nameds     []*types.Named
+		seenNameds = make(map[*types.Named]struct{})
+	)
+	addTypeName := func(obj types.Object) {
+		typeName, ok := obj.(*types.TypeName)
+		if !ok || !isMainPkgObject(typeName) {
+			return
+		}
+		named, ok := typeName.Type().(*types.Named)
+		if !ok {
+			return
+		}
+		if _, ok := seenNameds[named]; ok {
+			return
+		}
+		seenNameds[named] = struct{}{}
+		nameds = append(nameds, named)
+	}
+
+	scope := getPkg(r.proj).Scope()
+	for _, name := range scope.Names() {
+		addTypeName(scope.Lookup(name))
+	}
+	for _, obj := range getTypeInfo(r.proj).Defs {
+		addTypeName(obj)
+	}
+	return nameds
+}
+
+// locationForNamedType returns the [Location] where the given named type is
+// defined. For Go+ class file types, it is the start of the corresponding spx
+// file. It returns false if no location can be determined.
+func (r *compileResult) locationForNamedType(named *types.Named) (Location, bool) {
+	obj := named.Obj()
+	if r.isInFset(obj.Pos()) {
+		return r.locationForPos(obj.Pos()), true
+	}
+	if !isMainPkgObject(obj) {
+		return Location{}, false
+	}
+
+	var spxFile string
+	if obj.Name() == "Game" {
+		spxFile = r.mainSpxFile
+	} else if vfs.HasSpriteType(r.proj, named) {
+		spxFile = obj.Name() + ".spx"
+	}
+	documentURI, ok := r.documentURIs[spxFile]
+	if !ok {
+		return Location{}, false
+	}
+	return Location{URI: documentURI}, true
+}
+
 // selectorTypeNameForIdent returns the selector type name for the given
 // identifier. It returns empty string if no selector can be inferred.
 func (r *compileResult) selectorTypeNameForIdent(ident *gopast.Ident) string {
@@ -243,7 +348,7 @@ func (r *compileResult) selectorTypeNameForIdent(ident *gopast.Ident) string {
 		return ""
 	}
 
-	if path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End()); len(path) > 0 {
+	if path := goputil.NodePath(r.proj, ident); len(path) > 0 {
 		for _, node := range slices.Backward(path) {
 			sel, ok := node.(*gopast.SelectorExpr)
 			if !ok {
@@ -433,13 +538,8 @@ func (r *compileResult) spxDefinitionsForNamedStruct(named *types.Named) (defs [
 // isInSpxEventHandler checks if the given position is inside an spx event
 // handler callback.
 func (r *compileResult) isInSpxEventHandler(pos goptoken.Pos) bool {
-	astFile := r.posASTFile(pos)
-	if astFile == nil {
-		return false
-	}
-
 	typeInfo := getTypeInfo(r.proj)
-	path, _ := util.PathEnclosingInterval(astFile, pos-1, pos)
+	path, _ := goputil.PathEnclosingInterval(r.proj, pos-1, pos)
 	for _, node := range path {
 		callExpr, ok := node.(*gopast.CallExpr)
 		if !ok || len(callExpr.Args) == 0 {
@@ -488,6 +588,17 @@ func (r *compileResult) spxResourceRefAtASTFilePosition(astFile *gopast.File, po
 	return bestRef
 }
 
+// spxResourceRefRange returns the [Range] of the given spx resource reference.
+// For string literals, the surrounding quotes are excluded.
+func (r *compileResult) spxResourceRefRange(ref SpxResourceRef) Range {
+	rng := r.rangeForNode(ref.Node)
+	if lit, ok := ref.Node.(*gopast.BasicLit); ok && lit.Kind == goptoken.STRING {
+		rng.Start.Character++
+		rng.End.Character--
+	}
+	return rng
+}
+
 // spxImportsAtASTFilePosition returns the import at the given position in the given AST file.
 func (r *compileResult) spxImportsAtASTFilePosition(astFile *gopast.File, position goptoken.Position) *SpxReferencePkg {
 	fset := r.proj.Fset
@@ -584,6 +695,30 @@ func (r *compileResult) nodeASTFile(node gopast.Node) *gopast.File {
 	return r.posASTFile(node.Pos())
 }
 
+// inspector returns the inspector of the given AST file, which is cached by
+// the project for the ASTs it parsed.
+func (r *compileResult) inspector(astFile *gopast.File) *inspector.Inspector {
+	path := r.proj.Fset.File(astFile.Pos()).Name()
+	if f, _ := r.proj.AST(path); f == astFile {
+		if in, _ := r.proj.Inspector(path); in != nil {
+			return in
+		}
+	}
+	return inspector.New([]*gopast.File{astFile})
+}
+
+// cfg returns the cached control-flow graph of the given function body in the
+// given AST file. It returns nil if the graph is not available, e.g., the AST
+// file is not the one of the project.
+func (r *compileResult) cfg(astFile *gopast.File, body *gopast.BlockStmt) *cfg.CFG {
+	path := r.proj.Fset.File(astFile.Pos()).Name()
+	if f, _ := r.proj.AST(path); f != astFile {
+		return nil
+	}
+	g, _ := r.proj.CFG(path, body)
+	return g
+}
+
 // posDocumentURI returns the [DocumentURI] for the given position.
 func (r *compileResult) posDocumentURI(pos goptoken.Pos) DocumentURI {
 	return r.documentURIs[r.posFilename(pos)]
@@ -594,51 +729,34 @@ func (r *compileResult) nodeDocumentURI(node gopast.Node) DocumentURI {
 	return r.posDocumentURI(node.Pos())
 }
 
-// fromPosition converts a [goptoken.Position] to a protocol [Position].
+// fromPosition converts a [goptoken.Position] in the given AST file, or in the
+// file it names, to a protocol [Position]. Like the positions of token files,
+// the end of a file ending with a newline is on its last line.
 func (r *compileResult) fromPosition(astFile *gopast.File, position goptoken.Position) Position {
-	tokenFile := r.proj.Fset.File(astFile.Pos())
-
-	line := position.Line
-	lineStart := int(tokenFile.LineStart(line))
-	relLineStart := lineStart - tokenFile.Base()
-	lineContent := astFile.Code[relLineStart : relLineStart+position.Column-1]
-	utf16Offset := utf8OffsetToUTF16(string(lineContent), position.Column-1)
-
-	return Position{
-		Line:      uint32(position.Line - 1),
-		Character: uint32(utf16Offset),
+	path := position.Filename
+	if path == "" {
+		path = r.proj.Fset.File(astFile.Pos()).Name()
 	}
+	idx, err := r.proj.LineIndex(path)
+	if err != nil {
+		return Position{}
+	}
+	return idx.LinePosition(r.posEncoding, position.Line-1, position.Column-1)
 }
 
-// toPosition converts a protocol [Position] to a [goptoken.Position].
+// toPosition converts a protocol [Position] to a [goptoken.Position]. Positions
+// beyond the last line of the token file are on its last line.
 func (r *compileResult) toPosition(astFile *gopast.File, position Position) goptoken.Position {
 	tokenFile := r.proj.Fset.File(astFile.Pos())
-
-	line := min(int(position.Line)+1, tokenFile.LineCount())
-	lineStart := int(tokenFile.LineStart(line))
-	relLineStart := lineStart - tokenFile.Base()
-	lineContent := astFile.Code[relLineStart:]
-	if i := bytes.IndexByte(lineContent, '\n'); i >= 0 {
-		lineContent = lineContent[:i]
-	}
-	utf8Offset := utf16OffsetToUTF8(string(lineContent), int(position.Character))
-	column := utf8Offset + 1
-
-	return goptoken.Position{
-		Filename: tokenFile.Name(),
-		Offset:   relLineStart + utf8Offset,
-		Line:     line,
-		Column:   column,
+	if n := tokenFile.LineCount(); n > 0 && int(position.Line) >= n {
+		position.Line = uint32(n - 1)
 	}
+	return r.proj.Fset.PositionFor(r.posAt(astFile, position), false)
 }
 
 // posAt returns the [goptoken.Pos] of the given position in the given AST file.
 func (r *compileResult) posAt(astFile *gopast.File, position Position) goptoken.Pos {
-	tokenFile := r.proj.Fset.File(astFile.Pos())
-	if int(position.Line) > tokenFile.LineCount()-1 {
-		return goptoken.Pos(tokenFile.Base() + tokenFile.Size()) // EOF
-	}
-	return tokenFile.Pos(r.toPosition(astFile, position).Offset)
+	return goputil.PosAtPosition(r.proj, r.proj.Fset.File(astFile.Pos()).Name(), position, r.posEncoding)
 }
 
 // rangeForASTFilePosition returns a [Range] for the given [goptoken.Position]
@@ -692,20 +810,32 @@ func (r *compileResult) locationForNode(node gopast.Node) Location {
 	}
 }
 
-// compile compiles spx source files and returns compile result. It uses cached
-// result if available.
-func (s *Server) compile() (*compileResult, error) {
-	// NOTE(xsw): don't create a snapshot
-	snapshot := s.workspaceRootFS // .Snapshot()
+// compile compiles spx source files and returns compile result. It gives up
+// as soon as ctx is done, returning the error of ctx.
+func (s *Server) compile(ctx context.Context) (*compileResult, error) {
+	return s.compileWithProgress(ctx, nil)
+}
 
-	// TODO(wyvern): remove this once we have a better way to update files.
-	snapshot.UpdateFiles(s.fileMapGetter())
-	return s.compileAt(snapshot)
+// compileWithProgress is like [Server.compile], but reports the progress of
+// the compilation phases to progress, which may be nil.
+func (s *Server) compileWithProgress(ctx context.Context, progress *workDoneProgress) (*compileResult, error) {
+	return s.compileAt(ctx, s.snapshot(), progress)
 }
 
 // compileAt compiles spx source files at the given snapshot and returns the
 // compile result.
-func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
+//
+// Parsing and type checking cannot be interrupted, so ctx is checked between
+// files and compilation phases. A type check abandoned because of ctx keeps
+// running in the background, so that its result is cached for the next
+// compilation. If ctx is done, compileAt returns the error of ctx.
+//
+// The progress of the compilation phases is reported to progress, which may
+// be nil.
+func (s *Server) compileAt(ctx context.Context, snapshot *vfs.MapFS, progress *workDoneProgress) (result *compileResult, err error) {
+	ctx, end := s.startSpan(ctx, "compile")
+	defer func() { end(err) }()
+
 	spxFiles, err := vfs.ListSpxFiles(snapshot)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get spx files: %w", err)
@@ -714,8 +844,14 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 		return nil, errNoMainSpxFile
 	}
 
-	result := newCompileResult(snapshot)
-	for _, spxFile := range spxFiles {
+	result = newCompileResult(snapshot, s.getPositionEncoding())
+	_, endParse := s.startSpan(ctx, "parse", slog.Int("files", len(spxFiles)))
+	for i, spxFile := range spxFiles {
+		if err := ctx.Err(); err != nil {
+			endParse(err)
+			return nil, err
+		}
+		progress.report(fmt.Sprintf("Parsing %s (%d/%d)", spxFile, i+1, len(spxFiles)), uint32(30*i/len(spxFiles)))
 		documentURI := s.toDocumentURI(spxFile)
 		result.diagnostics[documentURI] = []Diagnostic{}
 		result.documentURIs[spxFile] = documentURI
@@ -728,6 +864,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 			)
 			if errors.As(err, &errorList) {
 				// Handle parse errors.
+				result.parseErrorCount += len(errorList)
 				for _, e := range errorList {
 					result.addDiagnostics(documentURI, Diagnostic{
 						Severity: SeverityError,
@@ -737,6 +874,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 				}
 			} else if errors.As(err, &codeError) {
 				// Handle code generation errors.
+				result.parseErrorCount++
 				result.addDiagnostics(documentURI, Diagnostic{
 					Severity: SeverityError,
 					Range:    result.rangeForPos(codeError.Pos),
@@ -744,6 +882,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 				})
 			} else {
 				// Handle unknown errors (including recovered panics).
+				result.parseErrorCount++
 				result.addDiagnostics(documentURI, Diagnostic{
 					Severity: SeverityError,
 					Message:  fmt.Sprintf("failed to parse spx file: %v", err),
@@ -766,6 +905,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 			result.mainSpxFile = spxFile
 		}
 	}
+	endParse(nil)
 	if result.mainSpxFile == "" {
 		if len(result.diagnostics) == 0 {
 			return nil, errNoMainSpxFile
@@ -777,21 +917,39 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 	if err := mod.ImportClasses(); err != nil {
 		return nil, fmt.Errorf("failed to import classes: %w", err)
 	}
+	missingImports := s.inspectForMissingImports(snapshot, result)
 	handleErr := func(err error) {
 		if typeErr, ok := err.(types.Error); ok {
+			if _, ok := missingImports[typeErr.Pos]; ok {
+				return // Reported by inspectForMissingImports.
+			}
+			result.typeErrorCount++
 			position := typeErr.Fset.Position(typeErr.Pos)
-			result.addDiagnosticsForSpxFile(position.Filename, Diagnostic{
+			diagnostic := Diagnostic{
 				Severity: SeverityError,
 				Range:    result.rangeForPos(typeErr.Pos),
 				Message:  typeErr.Msg,
-			})
+			}
+			result.addDiagnosticsForSpxFile(position.Filename, diagnostic)
+			if name, ok := strings.CutPrefix(typeErr.Msg, "undefined: "); ok {
+				result.addUndefinedIdentQuickFixes(position.Filename, diagnostic, typeErr.Pos, name)
+			}
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	snapshot.Path = "main"
 	snapshot.Mod = mod
 	snapshot.Importer = internal.Importer
-	_, _, err, _ = snapshot.TypeInfo()
+	progress.report("Type checking", 30)
+	typecheckCtx, endTypecheck := s.startSpan(ctx, "typecheck")
+	_, _, err, _ = snapshot.TypeInfoContext(typecheckCtx)
+	endTypecheck(ctx.Err()) // Type errors are reported as diagnostics.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if err != nil {
 		switch err := err.(type) {
 		case errors.List:
@@ -803,9 +961,23 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 		}
 	}
 
+	// Inspected before other errors are reported, so that only files with
+	// parse or type errors are skipped.
+	s.inspectForUnusedDecls(result)
+
+	progress.report("Checking resources", 60)
 	s.inspectForSpxResourceSet(snapshot, result)
+	s.inspectForSpxResourceMetadata(result)
 	s.inspectForSpxResourceRefs(result)
-	s.inspectDiagnosticsAnalyzers(result)
+	s.inspectForSpxMsgs(result)
+	s.inspectForSpxInfiniteLoops(result)
+	progress.report("Running analyzers", 80)
+	analysisCtx, endAnalysis := s.startSpan(ctx, "analysis")
+	s.inspectDiagnosticsAnalyzers(analysisCtx, result)
+	endAnalysis(ctx.Err())
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
@@ -813,7 +985,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 // compileAndGetASTFileForDocumentURI handles common compilation and file
 // retrieval logic for a given document URI. The returned astFile is probably
 // nil even if the compilation succeeded.
-func (s *Server) compileAndGetASTFileForDocumentURI(uri DocumentURI) (result *compileResult, spxFile string, astFile *gopast.File, err error) {
+func (s *Server) compileAndGetASTFileForDocumentURI(ctx context.Context, uri DocumentURI) (result *compileResult, spxFile string, astFile *gopast.File, err error) {
 	spxFile, err = s.fromDocumentURI(uri)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to get file path from document URI %q: %w", uri, err)
@@ -821,7 +993,7 @@ func (s *Server) compileAndGetASTFileForDocumentURI(uri DocumentURI) (result *co
 	if path.Ext(spxFile) != ".spx" {
 		return nil, "", nil, fmt.Errorf("file %q does not have .spx extension", spxFile)
 	}
-	result, err = s.compile()
+	result, err = s.compile(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to compile: %w", err)
 	}
@@ -832,7 +1004,10 @@ func (s *Server) compileAndGetASTFileForDocumentURI(uri DocumentURI) (result *co
 // inspectForSpxResourceSet inspects for spx resource set in main.spx.
 func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileResult) {
 	var typeInfo = getTypeInfo(snapshot)
-	var spxResourceRootDir string
+	var (
+		spxResourceRootDir     string
+		spxResourceRootDirExpr gopast.Expr
+	)
 	gopast.Inspect(getASTPkg(result.proj).Files[result.mainSpxFile], func(node gopast.Node) bool {
 		callExpr, ok := node.(*gopast.CallExpr)
 		if !ok {
@@ -853,7 +1028,10 @@ func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileRe
 		}
 
 		if types.AssignableTo(firstArgTV.Type, types.Typ[types.String]) {
-			spxResourceRootDir, _ = getStringLitOrConstValue(firstArg, firstArgTV)
+			var ok bool
+			if spxResourceRootDir, ok = getStringLitOrConstValue(firstArg, firstArgTV); ok {
+				spxResourceRootDirExpr = firstArg
+			}
 		} else {
 			result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
 				Severity: SeverityError,
@@ -866,9 +1044,9 @@ func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileRe
 	if spxResourceRootDir == "" {
 		spxResourceRootDir = "assets"
 	}
-	spxResourceRootFS := vfs.Sub(snapshot, spxResourceRootDir)
+	result.spxResourceRootDir = spxResourceRootDir
 
-	spxResourceSet, err := NewSpxResourceSet(spxResourceRootFS)
+	spxResourceSet, err := s.spxResources.get(snapshot, spxResourceRootDir)
 	if err != nil {
 		result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
 			Severity: SeverityError,
@@ -877,58 +1055,396 @@ func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileRe
 		return
 	}
 	result.spxResourceSet = *spxResourceSet
+
+	// The resource root given to run refers to the stage, whose
+	// configuration is in the index.json of the resource root.
+	if spxResourceRootDirExpr != nil {
+		spxResourceRefKind := SpxResourceRefKindStringLiteral
+		if _, ok := spxResourceRootDirExpr.(*gopast.Ident); ok {
+			spxResourceRefKind = SpxResourceRefKindConstantReference
+		}
+		result.addSpxResourceRef(SpxResourceRef{
+			ID:   SpxStageResourceID{},
+			Kind: spxResourceRefKind,
+			Node: spxResourceRootDirExpr,
+		})
+	}
 }
 
-// inspectDiagnosticsAnalyzers runs registered analyzers on each spx source file
-// and collects diagnostics.
+// inspectForSpxMsgs inspects for broadcast messages that are never handled by
+// `onMsg` handlers, and for `onMsg` handlers of messages that are never
+// broadcast.
 //
-// For each spx file in the main package, it:
-// 1. Creates an analysis pass with file-specific information
-// 2. Runs all registered analyzers on the file
-// 3. Collects diagnostics from analyzers
-// 4. Reports any analyzer errors as diagnostics
+// Only messages given as string literals or constants are checked. A handler
+// of all messages, e.g. `onMsg (msg, data) => {}`, handles every broadcast,
+// and a broadcast of a non-constant message may trigger every handler.
+func (s *Server) inspectForSpxMsgs(result *compileResult) {
+	type msgCall struct {
+		call *gopast.CallExpr
+		msg  string
+	}
+	var (
+		broadcasts          []msgCall
+		handlers            []msgCall
+		hasCatchAllHandler  bool
+		hasDynamicBroadcast bool
+	)
+	astPkg := getASTPkg(result.proj)
+	for _, spxFile := range slices.Sorted(maps.Keys(astPkg.Files)) {
+		gopast.Inspect(astPkg.Files[spxFile], func(n gopast.Node) bool {
+			call, ok := n.(*gopast.CallExpr)
+			if !ok {
+				return true
+			}
+			msg, hasMsg := result.spxEventHandlerMsg(call)
+			if _, ok := result.spxBroadcastCallIdent(call); ok {
+				if hasMsg {
+					broadcasts = append(broadcasts, msgCall{call, msg})
+				} else {
+					hasDynamicBroadcast = true
+				}
+			} else if result.isSpxEventHandlerCall(call) && call.Fun.(*gopast.Ident).Name == "onMsg" {
+				if hasMsg {
+					handlers = append(handlers, msgCall{call, msg})
+				} else {
+					hasCatchAllHandler = true
+				}
+			}
+			return true
+		})
+	}
+
+	handledMsgs := make(map[string]struct{}, len(handlers))
+	for _, handler := range handlers {
+		handledMsgs[handler.msg] = struct{}{}
+	}
+	broadcastMsgs := make(map[string]struct{}, len(broadcasts))
+	for _, broadcast := range broadcasts {
+		broadcastMsgs[broadcast.msg] = struct{}{}
+	}
+	if !hasCatchAllHandler {
+		for _, broadcast := range broadcasts {
+			if _, ok := handledMsgs[broadcast.msg]; ok {
+				continue
+			}
+			arg := broadcast.call.Args[0]
+			result.addDiagnostics(result.nodeDocumentURI(arg), Diagnostic{
+				Severity: SeverityWarning,
+				Range:    result.rangeForNode(arg),
+				Message:  fmt.Sprintf("message %q is broadcast but never handled by onMsg", broadcast.msg),
+			})
+		}
+	}
+	if !hasDynamicBroadcast {
+		for _, handler := range handlers {
+			if _, ok := broadcastMsgs[handler.msg]; ok {
+				continue
+			}
+			arg := handler.call.Args[0]
+			result.addDiagnostics(result.nodeDocumentURI(arg), Diagnostic{
+				Severity: SeverityWarning,
+				Range:    result.rangeForNode(arg),
+				Message:  fmt.Sprintf("message %q is handled by onMsg but never broadcast", handler.msg),
+			})
+		}
+	}
+}
+
+// inspectForSpxInfiniteLoops inspects for infinite loops in spx event handlers
+// that never yield to the spx runtime, which freeze the game. Each of them gets
+// a quick fix inserting the configured [Settings.LoopYieldCall] at the end of
+// the loop body.
+func (s *Server) inspectForSpxInfiniteLoops(result *compileResult) {
+	loopYieldCall := s.getLoopYieldCall()
+	astPkg := getASTPkg(result.proj)
+	for _, spxFile := range slices.Sorted(maps.Keys(astPkg.Files)) {
+		astFile := astPkg.Files[spxFile]
+		gopast.Inspect(astFile, func(n gopast.Node) bool {
+			call, ok := n.(*gopast.CallExpr)
+			if !ok || !result.isSpxEventHandlerCall(call) {
+				return true
+			}
+			body := spxEventHandlerBody(call)
+			if body == nil {
+				return true
+			}
+			gopast.Inspect(body, func(n gopast.Node) bool {
+				switch n := n.(type) {
+				case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+					return false // Nested event handlers are inspected on their own.
+				case *gopast.ForStmt:
+					if result.isInfiniteLoop(n) && !result.loopExitsOrYields(astFile, body, n) {
+						result.addSpxInfiniteLoopDiagnostic(astFile, n, loopYieldCall)
+					}
+				}
+				return true
+			})
+			return true
+		})
+	}
+}
+
+// isInfiniteLoop reports whether the given for statement has no condition or
+// a constant true condition.
+func (r *compileResult) isInfiniteLoop(loop *gopast.ForStmt) bool {
+	if loop.Cond == nil {
+		return true
+	}
+	tv, ok := getTypeInfo(r.proj).Types[loop.Cond]
+	return ok && tv.Value != nil && tv.Value.Kind() == constant.Bool && constant.BoolVal(tv.Value)
+}
+
+// loopExitsOrYields reports whether the body of the given loop in the given
+// event handler body may exit the loop, or may yield to the spx runtime, on
+// some path of the control-flow graph of the handler. It errs on the side of
+// true.
+func (r *compileResult) loopExitsOrYields(astFile *gopast.File, handlerBody *gopast.BlockStmt, loop *gopast.ForStmt) bool {
+	g := r.cfg(astFile, handlerBody)
+	if g == nil {
+		return true
+	}
+	var entry *cfg.Block
+	for _, b := range g.Blocks {
+		if b.Stmt == loop && b.Kind == cfg.KindForBody {
+			entry = b
+			break
+		}
+	}
+	if entry == nil {
+		return true
+	}
+
+	seen := make(map[*cfg.Block]bool)
+	var visit func(b *cfg.Block) bool
+	visit = func(b *cfg.Block) bool {
+		if seen[b] {
+			return false
+		}
+		seen[b] = true
+		if b.Stmt == loop && b.Kind == cfg.KindForLoop {
+			// The back-edge. The condition of the loop is known to be true.
+			return false
+		}
+		if b.Stmt == loop && b.Kind == cfg.KindForDone || len(b.Succs) == 0 {
+			return true // Exits the loop, or returns.
+		}
+		if slices.ContainsFunc(b.Nodes, r.nodeYields) {
+			return true
+		}
+		return slices.ContainsFunc(b.Succs, visit)
+	}
+	return visit(entry)
+}
+
+// nodeYields reports whether the given node of a control-flow graph contains a
+// call that may yield to the spx runtime, outside of function literals.
+func (r *compileResult) nodeYields(node gopast.Node) bool {
+	var found bool
+	gopast.Inspect(node, func(n gopast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return false
+		case *gopast.CallExpr:
+			if ident := funcIdentOfCallExpr(n); ident != nil {
+				found = r.isYieldingCall(ident, len(n.Args))
+			}
+		case *gopast.ExprStmt:
+			// Command-style calls without arguments, e.g. `waitNextFrame`.
+			switch x := n.X.(type) {
+			case *gopast.Ident:
+				found = r.isYieldingCall(x, 0)
+			case *gopast.SelectorExpr:
+				found = r.isYieldingCall(x.Sel, 0)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isYieldingCall reports whether a call of the function identified by the
+// given identifier with the given number of arguments may yield to the spx
+// runtime. Calls of functions defined in the main package, and of function
+// values, are assumed to yield.
+func (r *compileResult) isYieldingCall(ident *gopast.Ident, nargs int) bool {
+	obj := getTypeInfo(r.proj).ObjectOf(ident)
+	if obj == nil {
+		return false
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		_, isFuncValue := obj.Type().Underlying().(*types.Signature)
+		return isFuncValue
+	}
+	if isMainPkgObject(fn) {
+		return true
+	}
+	if fn.Pkg() != nil && fn.Pkg().Path() == "time" && fn.Name() == "Sleep" {
+		return true
+	}
+	if !isSpxPkgObject(fn) {
+		return false
+	}
+	name, _, _ := strings.Cut(fn.Name(), "__")
+	switch name {
+	case "Wait", "WaitUntil", "WaitNextFrame", "Glide", "Ask", "BroadcastAndWait":
+		return true
+	case "Say", "Think", "Play", "Broadcast":
+		return nargs > 1 // With a duration or a wait option.
+	}
+	return false
+}
+
+// addSpxInfiniteLoopDiagnostic adds the diagnostic of the given infinite loop
+// in the given AST file, with a quick fix inserting loopYieldCall at the end
+// of the loop body.
+func (r *compileResult) addSpxInfiniteLoopDiagnostic(astFile *gopast.File, loop *gopast.ForStmt, loopYieldCall string) {
+	documentURI := r.nodeDocumentURI(loop)
+	diagnostic := Diagnostic{
+		Severity: SeverityWarning,
+		Range:    r.rangeForStartEnd(astFile, loop.For, loop.For+goptoken.Pos(len("for"))),
+		Message:  "infinite loop in event handler never yields to the spx runtime",
+	}
+	r.addDiagnostics(documentURI, diagnostic)
+
+	fset := r.proj.Fset
+	indent := lineIndent(astFile.Code, fset.Position(loop.For).Offset)
+	newText := "\t" + loopYieldCall + "\n" + indent
+	if rbrace := fset.Position(loop.Body.Rbrace); len(lineIndent(astFile.Code, rbrace.Offset)) != rbrace.Column-1 {
+		// The closing brace does not start its line, e.g. `for {}`.
+		newText = "\n" + indent + newText
+	}
+	r.quickFixes[documentURI] = append(r.quickFixes[documentURI], quickFix{
+		diagnostic: diagnostic,
+		title:      fmt.Sprintf("Insert `%s` at the end of the loop", loopYieldCall),
+		edits: []TextEdit{{
+			Range:   r.rangeForPos(loop.Body.Rbrace),
+			NewText: newText,
+		}},
+		isPreferred: true,
+	})
+}
+
+// lineIndent returns the leading whitespace of the line containing the given
+// offset in code.
+func lineIndent(code []byte, offset int) string {
+	start := bytes.LastIndexByte(code[:offset], '\n') + 1
+	end := start
+	for end < len(code) && (code[end] == ' ' || code[end] == '\t') {
+		end++
+	}
+	return string(code[start:end])
+}
+
+// wholeLinesRange returns the given range of offsets in code extended to the
+// whole lines containing it, including the trailing newline, if nothing else
+// is on them. Otherwise, it returns the range unchanged.
+func wholeLinesRange(code []byte, start, end int) (int, int) {
+	lineStart := start - len(lineIndent(code, start))
+	lineEnd := end
+	for lineEnd < len(code) && (code[lineEnd] == ' ' || code[lineEnd] == '\t' || code[lineEnd] == '\r') {
+		lineEnd++
+	}
+	if (lineStart == 0 || code[lineStart-1] == '\n') && (lineEnd == len(code) || code[lineEnd] == '\n') {
+		return lineStart, min(lineEnd+1, len(code))
+	}
+	return start, end
+}
+
+// inspectDiagnosticsAnalyzers runs the registered analyzers on the main
+// package and collects their diagnostics and quick fixes.
 //
-// Parameters:
-//   - result: The compilation result containing AST and type information
+// The analyzers run through the analysis driver, which analyzes all files of
+// the package at once so that facts are shared between files, and reuses the
+// results of a previous compilation if the file contents did not change.
 //
-// The function updates result.diagnostics with any issues found by analyzers.
-// Diagnostic severity levels include:
-//   - Error: For analyzer failures or serious code issues
-//   - Warning: For potential problems that don't prevent compilation
-func (s *Server) inspectDiagnosticsAnalyzers(result *compileResult) {
-	proj := result.proj
-	fset := proj.Fset
-	typeInfo := getTypeInfo(proj)
-	for spxFile, astFile := range getASTPkg(proj).Files {
-
-		var diagnostics []Diagnostic
-		pass := &protocol.Pass{
-			Fset:      fset,
-			Files:     []*gopast.File{astFile},
-			TypesInfo: typeInfo,
-			Report: func(d protocol.Diagnostic) {
-				diagnostics = append(diagnostics, Diagnostic{
-					Range:    result.rangeForStartEnd(astFile, d.Pos, d.End),
-					Severity: SeverityError,
-					Message:  d.Message,
-				})
-			},
-			ResultOf: map[*protocol.Analyzer]any{
-				inspect.Analyzer: inspector.New([]*gopast.File{astFile}),
-			},
+// Diagnostics use the configured severity of the analyzer that reports them.
+// Analyzer failures are reported as errors on the main spx file, except for
+// analyzers skipped due to type errors, which are already reported.
+func (s *Server) inspectDiagnosticsAnalyzers(ctx context.Context, result *compileResult) {
+	configs := s.getAnalyzers()
+	analyzers := make([]*protocol.Analyzer, 0, len(configs))
+	byAnalyzer := make(map[*protocol.Analyzer]analyzerConfig, len(configs))
+	for _, config := range configs {
+		analyzers = append(analyzers, config.analyzer.Analyzer())
+		byAnalyzer[config.analyzer.Analyzer()] = config
+	}
+	analysisResult, err := s.analysisDriver.Run(ctx, result.proj, analyzers)
+	if ctx.Err() != nil {
+		return // The caller discards the result.
+	}
+	if err != nil {
+		result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("failed to run analyzers: %v", err),
+		})
+		return
+	}
+
+	astPkg := getASTPkg(result.proj)
+	fset := analysisResult.Fset
+	rangeForStartEnd := func(astFile *gopast.File, start, end goptoken.Pos) Range {
+		if !end.IsValid() {
+			end = start
+		}
+		return Range{
+			Start: result.fromPosition(astFile, fset.Position(start)),
+			End:   result.fromPosition(astFile, fset.Position(end)),
+		}
+	}
+	for _, d := range analysisResult.Diagnostics {
+		spxFile := fset.Position(d.Pos).Filename
+		astFile, ok := astPkg.Files[spxFile]
+		if !ok {
+			continue
 		}
+		documentURI := result.documentURIs[spxFile]
+		analyzer := byAnalyzer[d.Analyzer]
 
-		for _, analyzer := range s.analyzers {
-			an := analyzer.Analyzer()
-			if _, err := an.Run(pass); err != nil {
-				diagnostics = append(diagnostics, Diagnostic{
-					Severity: SeverityError,
-					Message:  fmt.Sprintf("analyzer %q failed: %v", an.Name, err),
+		var tags []DiagnosticTag
+		for _, tag := range analyzer.analyzer.Tags() {
+			tags = append(tags, DiagnosticTag(tag))
+		}
+		diagnostic := Diagnostic{
+			Range:    rangeForStartEnd(astFile, d.Pos, d.End),
+			Severity: analyzer.severity,
+			Source:   d.Analyzer.Name,
+			Message:  d.Message,
+			Tags:     tags,
+		}
+		result.addDiagnosticsForSpxFile(spxFile, diagnostic)
+
+		for _, fix := range d.SuggestedFixes {
+			edits := make([]TextEdit, 0, len(fix.TextEdits))
+			for _, edit := range fix.TextEdits {
+				edits = append(edits, TextEdit{
+					Range:   rangeForStartEnd(astFile, edit.Pos, edit.End),
+					NewText: string(edit.NewText),
 				})
 			}
+			result.quickFixes[documentURI] = append(result.quickFixes[documentURI], quickFix{
+				diagnostic:  diagnostic,
+				title:       fix.Message,
+				edits:       edits,
+				isPreferred: len(d.SuggestedFixes) == 1,
+			})
 		}
-
-		result.addDiagnosticsForSpxFile(spxFile, diagnostics...)
+	}
+	for _, an := range analyzers {
+		err := analysisResult.Errors[an]
+		if err == nil || errors.Is(err, driver.ErrTypeErrors) {
+			continue
+		}
+		if panicErr, ok := err.(*driver.PanicError); ok {
+			s.recordAnalyzerPanic(an.Name, panicErr)
+		}
+		result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("analyzer %q failed: %v", an.Name, err),
+		})
 	}
 }
 
@@ -979,14 +1495,39 @@ func (s *Server) inspectForSpxResourceRefs(result *compileResult) {
 		var (
 			isSpxSoundResourceAutoBinding  bool
 			isSpxSpriteResourceAutoBinding bool
+			missingSpxResourceID           SpxResourceID
 		)
 		switch varType {
 		case GetSpxSoundType():
 			isSpxSoundResourceAutoBinding = result.spxResourceSet.Sound(v.Name()) != nil
+			if !isSpxSoundResourceAutoBinding {
+				missingSpxResourceID = SpxSoundResourceID{SoundName: v.Name()}
+			}
 		case GetSpxSpriteType():
 			isSpxSpriteResourceAutoBinding = result.spxResourceSet.Sprite(v.Name()) != nil
+			if !isSpxSpriteResourceAutoBinding {
+				missingSpxResourceID = SpxSpriteResourceID{SpriteName: v.Name()}
+			}
 		default:
 			isSpxSpriteResourceAutoBinding = v.Name() == varType.Obj().Name() && vfs.HasSpriteType(result.proj, varType)
+			if isSpxSpriteResourceAutoBinding && result.spxResourceSet.Sprite(v.Name()) == nil {
+				missingSpxResourceID = SpxSpriteResourceID{SpriteName: v.Name()}
+			}
+		}
+
+		// Variables in the first var block are auto-bound to the resources
+		// of the same name, which must exist.
+		if missingSpxResourceID != nil && result.isDefinedInFirstVarBlock(obj) {
+			kind := "sound"
+			if _, ok := missingSpxResourceID.(SpxSpriteResourceID); ok {
+				kind = "sprite"
+			}
+			result.addDiagnosticsForSpxFile(spxFile, Diagnostic{
+				Severity:           SeverityError,
+				Range:              result.rangeForNode(ident),
+				Message:            fmt.Sprintf("%s %q does not exist", kind, v.Name()),
+				RelatedInformation: spxResourceRelatedInformation(missingSpxResourceID),
+			})
 		}
 		if !isSpxSoundResourceAutoBinding && !isSpxSpriteResourceAutoBinding {
 			continue
@@ -1093,14 +1634,9 @@ func (s *Server) inspectSpxResourceRefForTypeAtExpr(result *compileResult, expr
 		case GetSpxBackdropNameType(),
 			GetSpxSpriteNameType(),
 			GetSpxSoundNameType(),
-			GetSpxWidgetNameType():
-			astFile := result.nodeASTFile(ident)
-			if astFile == nil {
-				return
-			}
-
-			path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
-			for _, node := range path {
+			GetSpxWidgetNameType(),
+			GetSpxFontNameType():
+			for _, node := range goputil.NodePath(result.proj, ident) {
 				assignStmt, ok := node.(*gopast.AssignStmt)
 				if !ok {
 					continue
@@ -1135,6 +1671,8 @@ func (s *Server) inspectSpxResourceRefForTypeAtExpr(result *compileResult, expr
 		s.inspectSpxSoundResourceRefAtExpr(result, expr, typ)
 	case GetSpxWidgetNameType():
 		s.inspectSpxWidgetResourceRefAtExpr(result, expr, typ)
+	case GetSpxFontNameType():
+		s.inspectSpxFontResourceRefAtExpr(result, expr, typ)
 	default:
 		if vfs.HasSpriteType(result.proj, typ) {
 			s.inspectSpxSpriteResourceRefAtExpr(result, expr, typ)
@@ -1142,9 +1680,21 @@ func (s *Server) inspectSpxResourceRefForTypeAtExpr(result *compileResult, expr
 	}
 }
 
+// spxResourceRelatedInformation returns the related information of a
+// diagnostic that refers to the spx resource identified by id.
+func spxResourceRelatedInformation(id SpxResourceID) []DiagnosticRelatedInformation {
+	return []DiagnosticRelatedInformation{{
+		Location: Location{URI: DocumentURI(id.URI())},
+		Message:  fmt.Sprintf("referenced %s", id.URI()),
+	}}
+}
+
 // inspectSpxBackdropResourceRefAtExpr inspects an spx backdrop resource
 // reference at an expression. It returns the spx backdrop resource if it was
 // successfully retrieved.
+//
+// A backdrop name may also refer to a scene, the legacy form of backdrops, in
+// which case the reference is recorded as a scene reference.
 func (s *Server) inspectSpxBackdropResourceRefAtExpr(result *compileResult, expr gopast.Expr, declaredType types.Type) *SpxBackdropResource {
 	exprDocumentURI := result.nodeDocumentURI(expr)
 	exprRange := result.rangeForNode(expr)
@@ -1174,19 +1724,28 @@ func (s *Server) inspectSpxBackdropResourceRefAtExpr(result *compileResult, expr
 	if _, ok := expr.(*gopast.Ident); ok {
 		spxResourceRefKind = SpxResourceRefKindConstantReference
 	}
+	spxBackdropResource := result.spxResourceSet.Backdrop(spxBackdropName)
+	if spxBackdropResource == nil && result.spxResourceSet.Scene(spxBackdropName) != nil {
+		result.addSpxResourceRef(SpxResourceRef{
+			ID:   SpxSceneResourceID{SceneName: spxBackdropName},
+			Kind: spxResourceRefKind,
+			Node: expr,
+		})
+		return nil
+	}
 	result.addSpxResourceRef(SpxResourceRef{
 		ID:   SpxBackdropResourceID{BackdropName: spxBackdropName},
 		Kind: spxResourceRefKind,
 		Node: expr,
 	})
 
-	spxBackdropResource := result.spxResourceSet.Backdrop(spxBackdropName)
 	if spxBackdropResource == nil {
-		result.addDiagnostics(exprDocumentURI, Diagnostic{
-			Severity: SeverityError,
-			Range:    exprRange,
-			Message:  fmt.Sprintf("backdrop resource %q not found", spxBackdropName),
-		})
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("backdrop %q does not exist", spxBackdropName),
+			RelatedInformation: spxResourceRelatedInformation(SpxBackdropResourceID{BackdropName: spxBackdropName}),
+		}, maps.Keys(result.spxResourceSet.backdrops))
 		return nil
 	}
 	return spxBackdropResource
@@ -1265,11 +1824,12 @@ func (s *Server) inspectSpxSpriteResourceRefAtExpr(result *compileResult, expr g
 
 	spxSpriteResource := result.spxResourceSet.Sprite(spxSpriteName)
 	if spxSpriteResource == nil {
-		result.addDiagnostics(exprDocumentURI, Diagnostic{
-			Severity: SeverityError,
-			Range:    exprRange,
-			Message:  fmt.Sprintf("sprite resource %q not found", spxSpriteName),
-		})
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("sprite %q does not exist", spxSpriteName),
+			RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: spxSpriteName}),
+		}, maps.Keys(result.spxResourceSet.sprites))
 		return nil
 	}
 	return spxSpriteResource
@@ -1316,11 +1876,12 @@ func (s *Server) inspectSpxSpriteCostumeResourceRefAtExpr(result *compileResult,
 
 	spxSpriteCostumeResource := spxSpriteResource.Costume(spxSpriteCostumeName)
 	if spxSpriteCostumeResource == nil {
-		result.addDiagnostics(exprDocumentURI, Diagnostic{
-			Severity: SeverityError,
-			Range:    exprRange,
-			Message:  fmt.Sprintf("costume resource %q not found in sprite %q", spxSpriteCostumeName, spxSpriteResource.Name),
-		})
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("sprite %q has no costume %q", spxSpriteResource.Name, spxSpriteCostumeName),
+			RelatedInformation: spxResourceRelatedInformation(SpxSpriteCostumeResourceID{SpriteName: spxSpriteResource.Name, CostumeName: spxSpriteCostumeName}),
+		}, spxSpriteResource.costumeNames())
 		return nil
 	}
 	return spxSpriteCostumeResource
@@ -1367,12 +1928,21 @@ func (s *Server) inspectSpxSpriteAnimationResourceRefAtExpr(result *compileResul
 
 	spxSpriteAnimationResource := spxSpriteResource.Animation(spxSpriteAnimationName)
 	if spxSpriteAnimationResource == nil {
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("sprite %q has no animation %q", spxSpriteResource.Name, spxSpriteAnimationName),
+			RelatedInformation: spxResourceRelatedInformation(SpxSpriteAnimationResourceID{SpriteName: spxSpriteResource.Name, AnimationName: spxSpriteAnimationName}),
+		}, spxSpriteResource.animationNames())
+		return nil
+	}
+	if spxSpriteAnimationResource.HasMissingFrames() {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
-			Severity: SeverityError,
-			Range:    exprRange,
-			Message:  fmt.Sprintf("animation resource %q not found in sprite %q", spxSpriteAnimationName, spxSpriteResource.Name),
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("animation %q of sprite %q refers to costumes that do not exist", spxSpriteAnimationName, spxSpriteResource.Name),
+			RelatedInformation: spxResourceRelatedInformation(spxSpriteAnimationResource.ID),
 		})
-		return nil
 	}
 	return spxSpriteAnimationResource
 }
@@ -1439,11 +2009,12 @@ func (s *Server) inspectSpxSoundResourceRefAtExpr(result *compileResult, expr go
 
 	spxSoundResource := result.spxResourceSet.Sound(spxSoundName)
 	if spxSoundResource == nil {
-		result.addDiagnostics(exprDocumentURI, Diagnostic{
-			Severity: SeverityError,
-			Range:    exprRange,
-			Message:  fmt.Sprintf("sound resource %q not found", spxSoundName),
-		})
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("sound %q does not exist", spxSoundName),
+			RelatedInformation: spxResourceRelatedInformation(SpxSoundResourceID{SoundName: spxSoundName}),
+		}, maps.Keys(result.spxResourceSet.sounds))
 		return nil
 	}
 	return spxSoundResource
@@ -1490,12 +2061,65 @@ func (s *Server) inspectSpxWidgetResourceRefAtExpr(result *compileResult, expr g
 
 	spxWidgetResource := result.spxResourceSet.Widget(spxWidgetName)
 	if spxWidgetResource == nil {
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("widget %q does not exist", spxWidgetName),
+			RelatedInformation: spxResourceRelatedInformation(SpxWidgetResourceID{WidgetName: spxWidgetName}),
+		}, maps.Keys(result.spxResourceSet.widgets))
+		return nil
+	}
+	return spxWidgetResource
+}
+
+// inspectSpxFontResourceRefAtExpr inspects an spx font resource reference at
+// an expression. It returns the spx font resource if it was successfully
+// retrieved.
+func (s *Server) inspectSpxFontResourceRefAtExpr(result *compileResult, expr gopast.Expr, declaredType types.Type) *SpxFontResource {
+	typeInfo := getTypeInfo(result.proj)
+	exprDocumentURI := result.nodeDocumentURI(expr)
+	exprRange := result.rangeForNode(expr)
+	exprTV := typeInfo.Types[expr]
+
+	typ := exprTV.Type
+	if declaredType != nil {
+		typ = declaredType
+	}
+	if fontNameType := GetSpxFontNameType(); fontNameType == nil || typ != fontNameType {
+		return nil
+	}
+
+	spxFontName, ok := getStringLitOrConstValue(expr, exprTV)
+	if !ok {
+		return nil
+	}
+	spxResourceRefKind := SpxResourceRefKindStringLiteral
+	if _, ok := expr.(*gopast.Ident); ok {
+		spxResourceRefKind = SpxResourceRefKindConstantReference
+	}
+	if spxFontName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
 			Range:    exprRange,
-			Message:  fmt.Sprintf("widget resource %q not found", spxWidgetName),
+			Message:  "font resource name cannot be empty",
 		})
 		return nil
 	}
-	return spxWidgetResource
+	result.addSpxResourceRef(SpxResourceRef{
+		ID:   SpxFontResourceID{FontName: spxFontName},
+		Kind: spxResourceRefKind,
+		Node: expr,
+	})
+
+	spxFontResource := result.spxResourceSet.Font(spxFontName)
+	if spxFontResource == nil {
+		result.addSpxMissingResourceDiagnostic(expr, Diagnostic{
+			Severity:           SeverityError,
+			Range:              exprRange,
+			Message:            fmt.Sprintf("font %q does not exist", spxFontName),
+			RelatedInformation: spxResourceRelatedInformation(SpxFontResourceID{FontName: spxFontName}),
+		}, maps.Keys(result.spxResourceSet.fonts))
+		return nil
+	}
+	return spxFontResource
 }
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"go/types"
 	"path"
@@ -8,20 +10,30 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	gopast "github.com/goplus/gop/ast"
 	gopscanner "github.com/goplus/gop/scanner"
 	goptoken "github.com/goplus/gop/token"
 	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/gop/goputil"
 	"github.com/goplus/goxlsw/internal/pkgdata"
+	"github.com/goplus/goxlsw/internal/server/ranking"
 	"github.com/goplus/goxlsw/internal/util"
 	"github.com/goplus/goxlsw/internal/vfs"
 	"github.com/goplus/goxlsw/pkgdoc"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_completion
-func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionItem, error) {
-	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentCompletion(ctx context.Context, params *CompletionParams) (items []CompletionItem, err error) {
+	ctx, end := s.startSpan(ctx, "textDocument/completion")
+	defer func() { end(err) }()
+
+	if path.Ext(string(params.TextDocument.URI)) == ".json" {
+		return s.completeSpxResourceMetadata(ctx, params)
+	}
+
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +51,8 @@ func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionI
 	}
 
 	proj := result.proj
-	ctx := &completionContext{
-		proj:           s.getProj(),
+	compCtx := &completionContext{
+		proj:           proj,
 		itemSet:        newCompletionItemSet(),
 		result:         result,
 		spxFile:        spxFile,
@@ -50,11 +62,101 @@ func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionI
 		pos:            pos,
 		innermostScope: innermostScope,
 	}
-	ctx.analyze()
-	if err := ctx.collect(); err != nil {
+	compCtx.analyze()
+	if err := compCtx.collect(); err != nil {
 		return nil, fmt.Errorf("failed to collect completion items: %w", err)
 	}
-	return ctx.sortedItems(), nil
+	s.lastCompletion.Store(&completionResolveState{
+		documentURI: params.TextDocument.URI,
+		spxDefs:     compCtx.itemSet.spxDefs,
+	})
+	return s.adaptCompletionItems(compCtx.sortedItems()), nil
+}
+
+// completionResolveState is the state of the latest completion, which is
+// needed to resolve its items.
+type completionResolveState struct {
+	documentURI DocumentURI
+	spxDefs     map[string]SpxDefinition // keyed by definition ID string
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#completionItem_resolve
+func (s *Server) completionItemResolve(ctx context.Context, params *CompletionItem) (*CompletionItem, error) {
+	item := *params
+	data, err := decodeCompletionItemData(item.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode completion item data: %w", err)
+	}
+	if data == nil {
+		return &item, nil
+	}
+
+	if data.Resource != nil {
+		item.Documentation = &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: data.Resource.URI.HTML()}}
+		return &item, nil
+	}
+	if data.Definition == nil {
+		return &item, nil
+	}
+
+	state := s.lastCompletion.Load()
+	if state == nil {
+		return &item, nil
+	}
+	spxDef, ok := state.spxDefs[data.Definition.String()]
+	if !ok {
+		return &item, nil // Not from the latest completion.
+	}
+
+	pkgPath, isPkg := spxDefinitionPkgPath(spxDef.ID)
+	if isPkg && spxDef.Detail == "" {
+		if pkgDoc, err := pkgdata.GetPkgDoc(pkgPath); err == nil {
+			spxDef.Detail = pkgDoc.Doc
+		}
+	}
+	item.Detail = spxDef.Overview
+	item.Documentation = &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: spxDef.HTML()}}
+
+	if isPkg {
+		result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, state.documentURI)
+		if err != nil {
+			return nil, err
+		}
+		if astFile != nil {
+			item.AdditionalTextEdits = result.addImportEdits(astFile, pkgPath)
+		}
+	}
+	return &item, nil
+}
+
+// decodeCompletionItemData decodes the data of a completion item, which is a
+// [CompletionItemData] as created, or its JSON object form as sent back by
+// the client. It returns nil if there is no data.
+func decodeCompletionItemData(data any) (*CompletionItemData, error) {
+	switch data := data.(type) {
+	case nil:
+		return nil, nil
+	case *CompletionItemData:
+		return data, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var itemData CompletionItemData
+	if err := json.Unmarshal(b, &itemData); err != nil {
+		return nil, err
+	}
+	return &itemData, nil
+}
+
+// spxDefinitionPkgPath returns the package path of the given definition
+// identifier if it identifies a package.
+func spxDefinitionPkgPath(id SpxDefinitionIdentifier) (string, bool) {
+	if id.Package == nil || id.Name != nil {
+		return "", false
+	}
+	return *id.Package, true
 }
 
 // completionKind represents different kinds of completion contexts.
@@ -93,6 +195,7 @@ type completionContext struct {
 
 	enclosingNode      gopast.Node
 	selectorExpr       *gopast.SelectorExpr
+	selectorExprIsStmt bool
 	expectedTypes      []types.Type
 	expectedStructType *types.Struct
 	assignTargets      []*gopast.Ident
@@ -111,7 +214,7 @@ func (ctx *completionContext) pkgDoc() *pkgdoc.PkgDoc {
 // analyze analyzes the completion context to determine the kind of completion needed.
 func (ctx *completionContext) analyze() {
 	typeInfo := getTypeInfo(ctx.proj)
-	path, _ := util.PathEnclosingInterval(ctx.astFile, ctx.pos-1, ctx.pos)
+	path := goputil.NodeAt(ctx.proj, ctx.pos)
 	for i, node := range slices.Backward(path) {
 		switch node := node.(type) {
 		case *gopast.ImportSpec:
@@ -120,6 +223,10 @@ func (ctx *completionContext) analyze() {
 			if node.Sel == nil || node.Sel.End() >= ctx.pos {
 				ctx.kind = completionKindDot
 				ctx.selectorExpr = node
+				if i+1 < len(path) {
+					exprStmt, ok := path[i+1].(*gopast.ExprStmt)
+					ctx.selectorExprIsStmt = ok && exprStmt.X == node
+				}
 			}
 		case *gopast.CallExpr:
 			ctx.kind = completionKindCall
@@ -476,17 +583,110 @@ func (ctx *completionContext) collectGeneral() error {
 		})
 	}
 
+	// Add standard packages that are not imported yet. Their details are
+	// loaded on resolve, which also adds the missing imports.
+	if err := ctx.collectUnimportedPkgs(); err != nil {
+		return err
+	}
+
 	// Add other definitions.
 	ctx.itemSet.addSpxDefs(GetSpxPkgDefinitions()...)
 	ctx.itemSet.addSpxDefs(GetBuiltinSpxDefinitions()...)
 	ctx.itemSet.addSpxDefs(GeneralSpxDefinitions...)
 	if ctx.innermostScope == ctx.astFileScope {
 		ctx.itemSet.addSpxDefs(FileScopeSpxDefinitions...)
+		ctx.collectSpxEventHandlerSnippets()
 	}
 
 	return nil
 }
 
+// collectSpxEventHandlerSnippets collects event handler snippets that are
+// valid for the class of the current spx file.
+func (ctx *completionContext) collectSpxEventHandlerSnippets() {
+	classType := ctx.spxFileClassType()
+	if classType == nil {
+		return
+	}
+	for _, def := range SpxEventHandlerSnippetDefinitions {
+		if hasSpxEventHandlerMethod(classType, def.CompletionItemLabel) {
+			ctx.itemSet.addSpxDefs(def)
+		}
+	}
+}
+
+// spxFileClassType returns the class type of the current spx file. It returns
+// nil if the class type cannot be found.
+func (ctx *completionContext) spxFileClassType() *types.Named {
+	typeName, ok := getPkg(ctx.proj).Scope().Lookup(ctx.spxFileClassName()).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := typeName.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	return named
+}
+
+// spxFileClassName returns the class name of the current spx file.
+func (ctx *completionContext) spxFileClassName() string {
+	if ctx.spxFile == ctx.result.mainSpxFile {
+		return "Game"
+	}
+	return strings.TrimSuffix(ctx.spxFile, ".spx")
+}
+
+// hasSpxEventHandlerMethod reports whether the given class type has the spx
+// event handler method of the given name, including overloaded ones.
+func hasSpxEventHandlerMethod(classType *types.Named, name string) bool {
+	methodName := string(name[0]&^32) + name[1:]
+	for _, methodName := range []string{methodName, methodName + "__0"} {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(classType), true, nil, methodName)
+		if _, ok := obj.(*types.Func); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// collectUnimportedPkgs collects standard packages that are not imported by
+// the current file and whose names are not in use.
+func (ctx *completionContext) collectUnimportedPkgs() error {
+	pkgs, err := pkgdata.ListPkgs()
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	for _, pkgPath := range pkgs {
+		if !isStdPkgPath(pkgPath) {
+			continue
+		}
+		pkgName := path.Base(pkgPath)
+		if _, obj := ctx.innermostScope.LookupParent(pkgName, ctx.pos); obj != nil {
+			continue
+		}
+		ctx.itemSet.addSpxDefs(SpxDefinition{
+			ID: SpxDefinitionIdentifier{
+				Package: &pkgPath,
+			},
+			Overview: "package " + pkgName,
+
+			CompletionItemLabel:            pkgName,
+			CompletionItemKind:             ModuleCompletion,
+			CompletionItemInsertText:       pkgName,
+			CompletionItemInsertTextFormat: PlainTextTextFormat,
+		})
+	}
+	return nil
+}
+
+// isStdPkgPath reports whether the given package path is of a standard
+// package.
+func isStdPkgPath(pkgPath string) bool {
+	firstElem, _, _ := strings.Cut(pkgPath, "/")
+	return !strings.Contains(firstElem, ".")
+}
+
 // collectImport collects import completions.
 func (ctx *completionContext) collectImport() error {
 	pkgs, err := pkgdata.ListPkgs()
@@ -533,6 +733,9 @@ func (ctx *completionContext) collectDot() error {
 	if !ok {
 		return nil
 	}
+	if tv.IsValue() {
+		ctx.collectPostfix(tv.Type)
+	}
 	typ := unwrapPointerType(tv.Type)
 	if named, ok := typ.(*types.Named); ok && isSpxPkgObject(named.Obj()) && named.Obj().Name() == "Sprite" {
 		typ = GetSpxSpriteImplType()
@@ -554,6 +757,119 @@ func (ctx *completionContext) collectDot() error {
 	return nil
 }
 
+// postfixTemplate is a template of a postfix completion, which rewrites the
+// receiver expression of a member access like `expr.for`.
+type postfixTemplate struct {
+	label  string
+	detail string
+
+	// isStmt reports whether the template expands to a statement, so that
+	// it is only available when the member access is a statement itself.
+	isStmt bool
+
+	// appliesTo reports whether the template applies to the receiver type.
+	appliesTo func(typ types.Type) bool
+
+	// expand returns the snippet replacing the member access.
+	expand func(expr string, typ types.Type) string
+}
+
+// postfixTemplates are the available postfix completion templates.
+var postfixTemplates = []postfixTemplate{
+	{
+		label:  "for",
+		detail: "for i, v <- expr { ... }",
+		isStmt: true,
+		appliesTo: func(typ types.Type) bool {
+			switch typ := typ.Underlying().(type) {
+			case *types.Slice, *types.Array, *types.Map:
+				return true
+			case *types.Basic:
+				return typ.Info()&types.IsString != 0
+			}
+			return false
+		},
+		expand: func(expr string, typ types.Type) string {
+			if _, ok := typ.Underlying().(*types.Map); ok {
+				return "for ${1:k}, ${2:v} <- " + expr + " {\n\t$0\n}"
+			}
+			return "for ${1:i}, ${2:v} <- " + expr + " {\n\t$0\n}"
+		},
+	},
+	{
+		label:  "if",
+		detail: "if expr { ... }",
+		isStmt: true,
+		appliesTo: func(typ types.Type) bool {
+			basic, ok := typ.Underlying().(*types.Basic)
+			return ok && basic.Info()&types.IsBoolean != 0
+		},
+		expand: func(expr string, typ types.Type) string {
+			return "if " + expr + " {\n\t$0\n}"
+		},
+	},
+	{
+		label:  "print",
+		detail: "echo expr",
+		isStmt: true,
+		appliesTo: func(typ types.Type) bool {
+			_, isTuple := typ.(*types.Tuple)
+			return !isTuple
+		},
+		expand: func(expr string, typ types.Type) string {
+			return "echo " + expr
+		},
+	},
+	{
+		label:  "len",
+		detail: "len(expr)",
+		appliesTo: func(typ types.Type) bool {
+			switch typ := typ.Underlying().(type) {
+			case *types.Slice, *types.Array, *types.Map, *types.Chan:
+				return true
+			case *types.Basic:
+				return typ.Info()&types.IsString != 0
+			}
+			return false
+		},
+		expand: func(expr string, typ types.Type) string {
+			return "len(" + expr + ")$0"
+		},
+	},
+}
+
+// collectPostfix collects postfix completions for the receiver expression of
+// the selector expression of the given type.
+func (ctx *completionContext) collectPostfix(typ types.Type) {
+	if typ == nil || typ == types.Typ[types.Invalid] {
+		return
+	}
+
+	fset := ctx.proj.Fset
+	x := ctx.selectorExpr.X
+	exprSrc := string(ctx.astFile.Code[fset.Position(x.Pos()).Offset:fset.Position(x.End()).Offset])
+	editRange := ctx.result.rangeForStartEnd(ctx.astFile, x.Pos(), ctx.pos)
+	for _, tmpl := range postfixTemplates {
+		if tmpl.isStmt && !ctx.selectorExprIsStmt {
+			continue
+		}
+		if !tmpl.appliesTo(typ) {
+			continue
+		}
+		ctx.itemSet.add(CompletionItem{
+			Label:      tmpl.label,
+			Kind:       SnippetCompletion,
+			Detail:     tmpl.detail,
+			FilterText: exprSrc + "." + tmpl.label,
+			TextEdit: &Or_CompletionItem_textEdit{Value: TextEdit{
+				Range:   editRange,
+				NewText: tmpl.expand(exprSrc, typ),
+			}},
+			InsertTextFormat: util.ToPtr(SnippetTextFormat),
+		})
+	}
+}
+
 // collectPackageMembers collects members of a package.
 func (ctx *completionContext) collectPackageMembers(pkg *types.Package) error {
 	if pkg == nil {
@@ -581,20 +897,11 @@ func (ctx *completionContext) collectCall() error {
 	if !ok {
 		return nil
 	}
-	typeInfo := getTypeInfo(ctx.proj)
-	tv, ok := typeInfo.Types[callExpr.Fun]
-	if !ok {
-		return nil
-	}
-	sig, ok := tv.Type.(*types.Signature)
-	if !ok {
-		// TODO: Handle invalid type with no signature, like `println`.
-		return nil
-	}
 	argIndex := ctx.getCurrentArgIndex(callExpr)
 	if argIndex < 0 {
 		return nil
 	}
+	typeInfo := getTypeInfo(ctx.proj)
 
 	var fun *types.Func
 	switch expr := callExpr.Fun.(type) {
@@ -607,31 +914,112 @@ func (ctx *completionContext) collectCall() error {
 			fun, _ = obj.(*types.Func)
 		}
 	}
+
+	// Overloaded functions may have no signature recorded for their calls,
+	// so their overloads are tried first.
+	var sigs []*types.Signature
 	if fun != nil {
-		funcOverloads := expandGopOverloadableFunc(fun)
-		if len(funcOverloads) > 0 {
-			expectedTypes := make([]types.Type, 0, len(funcOverloads))
+		if funcOverloads, _ := goputil.OverloadsOf(fun); len(funcOverloads) > 1 {
 			for _, funcOverload := range funcOverloads {
-				sig := funcOverload.Type().(*types.Signature)
-				if argIndex < sig.Params().Len() {
-					expectedTypes = append(expectedTypes, sig.Params().At(argIndex).Type())
-				} else if sig.Variadic() && argIndex >= sig.Params().Len()-1 {
-					expectedTypes = append(expectedTypes, sig.Params().At(sig.Params().Len()-1).Type().(*types.Slice).Elem())
-				}
+				sigs = append(sigs, funcOverload.Type().(*types.Signature))
 			}
-			ctx.expectedTypes = slices.Compact(expectedTypes)
-			return ctx.collectGeneral()
 		}
 	}
+	if len(sigs) == 0 {
+		tv, ok := typeInfo.Types[callExpr.Fun]
+		if !ok {
+			return nil
+		}
+		sig, ok := tv.Type.(*types.Signature)
+		if !ok {
+			// TODO: Handle invalid type with no signature, like `println`.
+			return nil
+		}
+		sigs = []*types.Signature{sig}
+	}
 
-	if argIndex < sig.Params().Len() {
-		ctx.expectedTypes = []types.Type{sig.Params().At(argIndex).Type()}
-	} else if sig.Variadic() && argIndex >= sig.Params().Len()-1 {
-		ctx.expectedTypes = []types.Type{sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice).Elem()}
+	expectedTypes := make([]types.Type, 0, len(sigs))
+	for _, sig := range sigs {
+		if argIndex < sig.Params().Len() {
+			expectedTypes = append(expectedTypes, sig.Params().At(argIndex).Type())
+		} else if sig.Variadic() && argIndex >= sig.Params().Len()-1 {
+			expectedTypes = append(expectedTypes, sig.Params().At(sig.Params().Len()-1).Type().(*types.Slice).Elem())
+		}
+	}
+	ctx.expectedTypes = slices.Compact(expectedTypes)
+	if !ctx.inStringLit && ctx.hasEnumConsts() {
+		for _, expectedType := range ctx.expectedTypes {
+			if err := ctx.collectTypeSpecific(expectedType); err != nil {
+				return err
+			}
+		}
+		ctx.collectEnumConsts()
+		return nil
 	}
 	return ctx.collectGeneral()
 }
 
+// hasEnumConsts reports whether there are constants declared with any of the
+// expected types, see [enumConsts].
+func (ctx *completionContext) hasEnumConsts() bool {
+	for _, expectedType := range ctx.expectedTypes {
+		if len(enumConsts(expectedType)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEnumConsts collects the constants declared with the expected types,
+// e.g., [spx.KeyA] for [spx.Key] and [spx.Left] for the special directions.
+// They are offered in place of general completions.
+func (ctx *completionContext) collectEnumConsts() {
+	for _, expectedType := range ctx.expectedTypes {
+		consts := enumConsts(expectedType)
+		if len(consts) == 0 {
+			continue
+		}
+		pkgPath := consts[0].Pkg().Path()
+		var pkgDoc *pkgdoc.PkgDoc
+		if pkgPath == "main" {
+			pkgDoc = ctx.pkgDoc()
+		} else {
+			pkgDoc, _ = pkgdata.GetPkgDoc(pkgPath)
+		}
+		for _, c := range consts {
+			ctx.itemSet.addSpxDefs(GetSpxDefinitionForConst(c, pkgDoc))
+		}
+	}
+}
+
+// enumConsts returns the constants declared with the given named or alias
+// type of a basic type in the package of the type, in name order.
+func enumConsts(typ types.Type) (consts []*types.Const) {
+	var typeName *types.TypeName
+	switch t := typ.(type) {
+	case *types.Alias:
+		typeName = t.Obj()
+	case *types.Named:
+		typeName = t.Obj()
+	}
+	if typeName == nil || typeName.Pkg() == nil {
+		return nil
+	}
+	if _, ok := typ.Underlying().(*types.Basic); !ok {
+		return nil
+	}
+	scope := typeName.Pkg().Scope()
+	for _, name := range scope.Names() {
+		// Aliases are compared by identity, as they are identical to the
+		// types they denote, e.g., int for the special directions.
+		c, ok := scope.Lookup(name).(*types.Const)
+		if ok && c.Type() == typ && isExportedOrMainPkgObject(c) {
+			consts = append(consts, c)
+		}
+	}
+	return
+}
+
 // getCurrentArgIndex gets the current argument index in a function call.
 func (ctx *completionContext) getCurrentArgIndex(callExpr *gopast.CallExpr) int {
 	if len(callExpr.Args) == 0 {
@@ -719,9 +1107,12 @@ func (ctx *completionContext) collectTypeSpecific(typ types.Type) error {
 			spxResourceIds = append(spxResourceIds, SpxSoundResourceID{spxSoundName})
 		}
 	case GetSpxWidgetNameType():
+		expectedWidgetType := ctx.getSpxWidgetType()
 		spxResourceIds = slices.Grow(spxResourceIds, len(ctx.result.spxResourceSet.widgets))
-		for spxWidgetName := range ctx.result.spxResourceSet.widgets {
-			spxResourceIds = append(spxResourceIds, SpxWidgetResourceID{spxWidgetName})
+		for spxWidgetName, spxWidget := range ctx.result.spxResourceSet.widgets {
+			if expectedWidgetType == nil || spxWidget.WidgetType == expectedWidgetType {
+				spxResourceIds = append(spxResourceIds, SpxWidgetResourceID{spxWidgetName})
+			}
 		}
 	}
 	for _, spxResourceId := range spxResourceIds {
@@ -732,9 +1123,11 @@ func (ctx *completionContext) collectTypeSpecific(typ types.Type) error {
 		ctx.itemSet.add(CompletionItem{
 			Label:            name,
 			Kind:             TextCompletion,
-			Documentation:    &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: spxResourceId.URI().HTML()}},
 			InsertText:       name,
 			InsertTextFormat: util.ToPtr(PlainTextTextFormat),
+			Data: &CompletionItemData{
+				Resource: &SpxResourceIdentifier{URI: spxResourceId.URI()},
+			},
 		})
 	}
 	return nil
@@ -781,6 +1174,38 @@ func (ctx *completionContext) getSpxSpriteResource() *SpxSpriteResource {
 	return nil
 }
 
+// getSpxWidgetType returns the [SpxWidgetType] expected by the call in the
+// current context from the type passed before the widget name, e.g., the
+// monitor type for `getWidget Monitor, ""`. It returns nil if no widget type
+// can be inferred.
+func (ctx *completionContext) getSpxWidgetType() *SpxWidgetType {
+	if ctx.kind != completionKindCall {
+		return nil
+	}
+	callExpr, ok := ctx.enclosingNode.(*gopast.CallExpr)
+	if !ok {
+		return nil
+	}
+
+	if len(callExpr.Args) == 0 || ctx.pos <= callExpr.Args[0].End() {
+		return nil
+	}
+	var ident *gopast.Ident
+	switch expr := callExpr.Args[0].(type) {
+	case *gopast.Ident:
+		ident = expr
+	case *gopast.SelectorExpr:
+		ident = expr.Sel
+	default:
+		return nil
+	}
+	typeName, ok := getTypeInfo(ctx.proj).ObjectOf(ident).(*types.TypeName)
+	if !ok || typeName.Pkg() != GetSpxPkg() {
+		return nil
+	}
+	return lookupSpxWidgetTypeByGoTypeName(typeName.Name())
+}
+
 // collectStructLit collects struct literal completions.
 func (ctx *completionContext) collectStructLit() error {
 	if ctx.expectedStructType == nil {
@@ -898,23 +1323,114 @@ var completionItemKindPriority = map[CompletionItemKind]int{
 	InterfaceCompletion: 7,
 	ModuleCompletion:    8,
 	KeywordCompletion:   9,
+	SnippetCompletion:   10,
 }
 
-// sortedItems returns the sorted items.
+// Ranking boosts of completion items, see [completionContext.rankingBoost].
+const (
+	completionBoostCurrentClass = 0.3
+	completionBoostMainPkg      = 0.15
+	completionBoostInternal     = -0.5
+)
+
+// sortedItems returns the items that match the identifier prefix before the
+// position, ranked from the best to the worst. Their sort texts are set to
+// keep the ranking on the client side.
 func (ctx *completionContext) sortedItems() []CompletionItem {
-	slices.SortStableFunc(ctx.itemSet.items, func(a, b CompletionItem) int {
+	items := ctx.itemSet.items
+	slices.SortStableFunc(items, func(a, b CompletionItem) int {
 		if p1, p2 := completionItemKindPriority[a.Kind], completionItemKindPriority[b.Kind]; p1 != p2 {
 			return p1 - p2
 		}
 		return strings.Compare(a.Label, b.Label)
 	})
-	return ctx.itemSet.items
+
+	candidates := make([]ranking.Candidate, len(items))
+	for i, item := range items {
+		text := item.Label
+		if item.FilterText != "" {
+			text = item.FilterText
+		}
+		candidates[i] = ranking.Candidate{Text: text, Boost: ctx.rankingBoost(item)}
+	}
+	indices := ranking.Rank(ctx.identPrefix(), candidates)
+	sortTextWidth := len(strconv.Itoa(len(indices)))
+	rankedItems := make([]CompletionItem, len(indices))
+	for i, idx := range indices {
+		item := items[idx]
+		item.SortText = fmt.Sprintf("%0*d", sortTextWidth, i)
+		rankedItems[i] = item
+	}
+	return rankedItems
+}
+
+// identPrefix returns the part of the identifier before the position.
+func (ctx *completionContext) identPrefix() string {
+	offset := ctx.proj.Fset.Position(ctx.pos).Offset
+	if offset > len(ctx.astFile.Code) {
+		return ""
+	}
+	code := ctx.astFile.Code[:offset]
+	start := len(code)
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(code[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= size
+	}
+	return string(code[start:])
+}
+
+// rankingBoost returns the ranking boost of the given item. Definitions of
+// the class of the current spx file and local ones are boosted the most, then
+// other definitions of the main package. Internal definitions, like the ones
+// generated for classes, are deprioritized.
+func (ctx *completionContext) rankingBoost(item CompletionItem) float64 {
+	data, ok := item.Data.(*CompletionItemData)
+	if !ok || data.Definition == nil || data.Definition.Name == nil {
+		return 0
+	}
+	id := data.Definition
+	name := *id.Name
+
+	var boost float64
+	if util.FromPtr(id.Package) == "main" {
+		if !strings.Contains(name, ".") || strings.HasPrefix(name, ctx.spxFileClassName()+".") {
+			boost += completionBoostCurrentClass
+		} else {
+			boost += completionBoostMainPkg
+		}
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if isInternalSpxDefinitionName(name) {
+		boost += completionBoostInternal
+	}
+	return boost
+}
+
+// isInternalSpxDefinitionName reports whether the given definition name is of
+// an internal definition that is rarely used directly.
+func isInternalSpxDefinitionName(name string) bool {
+	if strings.HasPrefix(name, "_") ||
+		strings.HasPrefix(name, util.GoptPrefix) ||
+		strings.HasPrefix(name, util.GopoPrefix) ||
+		strings.HasPrefix(name, util.GopxPrefix) {
+		return true
+	}
+	switch toLowerCamelCase(name) {
+	case "classfname", "classclone", "main":
+		return true
+	}
+	return false
 }
 
 // completionItemSet is a set of completion items.
 type completionItemSet struct {
 	items                         []CompletionItem
-	seenSpxDefs                   map[string]struct{}
+	spxDefs                       map[string]SpxDefinition // keyed by definition ID string
 	supportedKinds                map[CompletionItemKind]struct{}
 	isCompatibleWithExpectedTypes func(typ types.Type) bool
 }
@@ -922,8 +1438,8 @@ type completionItemSet struct {
 // newCompletionItemSet creates a new [completionItemSet].
 func newCompletionItemSet() *completionItemSet {
 	return &completionItemSet{
-		items:       []CompletionItem{},
-		seenSpxDefs: make(map[string]struct{}),
+		items:   []CompletionItem{},
+		spxDefs: make(map[string]SpxDefinition),
 	}
 }
 
@@ -975,10 +1491,10 @@ func (s *completionItemSet) addSpxDefs(spxDefs ...SpxDefinition) {
 		}
 
 		spxDefIDKey := spxDef.ID.String()
-		if _, ok := s.seenSpxDefs[spxDefIDKey]; ok {
+		if _, ok := s.spxDefs[spxDefIDKey]; ok {
 			continue
 		}
-		s.seenSpxDefs[spxDefIDKey] = struct{}{}
+		s.spxDefs[spxDefIDKey] = spxDef
 
 		s.add(spxDef.CompletionItem())
 	}
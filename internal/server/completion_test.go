@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/goplus/goxlsw/internal/util"
@@ -30,7 +33,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		emptyLineItems, err := s.textDocumentCompletion(&CompletionParams{
+		emptyLineItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 0},
@@ -45,7 +48,7 @@ onStart => {
 			Name:    util.ToPtr("MySprite"),
 		}))
 
-		assert.Contains(t, emptyLineItems, SpxDefinition{
+		assert.Contains(t, withoutSortText(emptyLineItems), SpxDefinition{
 			ID: SpxDefinitionIdentifier{
 				Package: util.ToPtr("github.com/goplus/spx"),
 				Name:    util.ToPtr("Game.getWidget"),
@@ -58,7 +61,7 @@ onStart => {
 			CompletionItemInsertTextFormat: PlainTextTextFormat,
 		}.CompletionItem())
 
-		mySpriteDotItems, err := s.textDocumentCompletion(&CompletionParams{
+		mySpriteDotItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 9},
@@ -106,7 +109,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -133,7 +136,7 @@ run "a
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -153,7 +156,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 11},
@@ -172,7 +175,7 @@ run "a
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -191,7 +194,7 @@ import "f
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 9},
@@ -212,7 +215,7 @@ import (
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -233,7 +236,7 @@ fmt.
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 4},
@@ -257,7 +260,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 1},
@@ -268,7 +271,7 @@ run "assets", {Title: "My Game"}
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "len"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 12},
@@ -278,7 +281,7 @@ run "assets", {Title: "My Game"}
 		require.NotNil(t, items2)
 		assert.Empty(t, items2)
 
-		items3, err := s.textDocumentCompletion(&CompletionParams{
+		items3, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 1},
@@ -306,7 +309,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 8},
@@ -338,7 +341,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 22},
@@ -361,7 +364,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 7},
@@ -373,6 +376,50 @@ run "assets", {Title: "My Game"}
 		assert.True(t, containsCompletionItemLabel(items, "recording"))
 	})
 
+	t.Run("SpxResourceItemData", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+onBackdrop "b", => {}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	animate "w"
+}
+`),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1"}]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"fAnimations":{"walk":{}}}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		backdropItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 13},
+			},
+		})
+		require.NoError(t, err)
+		backdropItem := findCompletionItem(backdropItems, "backdrop1")
+		require.NotNil(t, backdropItem)
+		assert.Equal(t, &CompletionItemData{
+			Resource: &SpxResourceIdentifier{URI: "spx://resources/backdrops/backdrop1"},
+		}, backdropItem.Data)
+		assert.Nil(t, backdropItem.Documentation)
+
+		animationItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 10},
+			},
+		})
+		require.NoError(t, err)
+		animationItem := findCompletionItem(animationItems, "walk")
+		require.NotNil(t, animationItem)
+		assert.Equal(t, &CompletionItemData{
+			Resource: &SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/animations/walk"},
+		}, animationItem.Data)
+	})
+
 	t.Run("FuncOverloads", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -388,7 +435,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 6},
@@ -416,7 +463,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 14},
@@ -445,7 +492,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 22},
@@ -457,6 +504,30 @@ run "assets", {Title: "My Game"}
 		assert.True(t, containsCompletionItemLabel(items, "costume"))
 	})
 
+	t.Run("SpxWidgetResourceOfType", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+getWidget Monitor, ""
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{"zorder":[{"name":"score","type":"monitor"},{"name":"lives","type":"stageMonitor"},{"name":"knob","type":"knob"},{"name":"ruler","type":"measure"}]}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 20},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, items)
+		assert.True(t, containsCompletionItemLabel(items, "score"))
+		assert.True(t, containsCompletionItemLabel(items, "lives"))
+		assert.False(t, containsCompletionItemLabel(items, "knob"))
+		assert.False(t, containsCompletionItemLabel(items, "ruler"))
+	})
+
 	t.Run("WithCrossSpxSpriteResource", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -479,7 +550,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///Sprite1.spx"},
 				Position:     Position{Line: 2, Character: 22},
@@ -491,6 +562,212 @@ onClick => {
 		assert.True(t, containsCompletionItemLabel(items, "Sprite2Costume"))
 	})
 
+	t.Run("SpxEventHandlerSnippets", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		spriteItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 1, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, withoutSortText(spriteItems), SpxDefinition{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onMsg_snippet")},
+			Overview: "onMsg msg, => { ... }",
+			Detail:   "Listen to specific message broadcasted",
+
+			CompletionItemLabel:            "onMsg",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onMsg \"${1:msg}\", => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		}.CompletionItem())
+		for _, name := range []string{"onStart", "onClick", "onKey", "onTouchStart"} {
+			assert.True(t, containsCompletionSpxDefinitionID(spriteItems, SpxDefinitionIdentifier{Name: util.ToPtr(name + "_snippet")}), name)
+		}
+
+		gameItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, containsCompletionSpxDefinitionID(gameItems, SpxDefinitionIdentifier{Name: util.ToPtr("onStart_snippet")}))
+		assert.False(t, containsCompletionSpxDefinitionID(gameItems, SpxDefinitionIdentifier{Name: util.ToPtr("onTouchStart_snippet")}))
+	})
+
+	t.Run("NoSpxEventHandlerSnippetsInFunc", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func f() {
+
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, items)
+		assert.False(t, containsCompletionSpxDefinitionID(items, SpxDefinitionIdentifier{Name: util.ToPtr("onStart_snippet")}))
+	})
+
+	t.Run("Postfix", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	scores := {"a": 1}
+	scores.
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 8},
+			},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, withoutSortText(items), CompletionItem{
+			Label:      "for",
+			Kind:       SnippetCompletion,
+			Detail:     "for i, v <- expr { ... }",
+			FilterText: "scores.for",
+			TextEdit: &Or_CompletionItem_textEdit{Value: TextEdit{
+				Range: Range{
+					Start: Position{Line: 3, Character: 1},
+					End:   Position{Line: 3, Character: 8},
+				},
+				NewText: "for ${1:k}, ${2:v} <- scores {\n\t$0\n}",
+			}},
+			InsertTextFormat: util.ToPtr(SnippetTextFormat),
+		})
+		assert.True(t, containsCompletionItemLabel(items, "print"))
+		assert.True(t, containsCompletionItemLabel(items, "len"))
+		assert.False(t, containsCompletionItemLabel(items, "if"))
+	})
+
+	t.Run("PostfixInExpr", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	names := ["a", "b"]
+	echo names.l
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 13},
+			},
+		})
+		require.NoError(t, err)
+		lenItem := findCompletionItem(items, "len")
+		require.NotNil(t, lenItem)
+		assert.Equal(t, &Or_CompletionItem_textEdit{Value: TextEdit{
+			Range: Range{
+				Start: Position{Line: 3, Character: 6},
+				End:   Position{Line: 3, Character: 13},
+			},
+			NewText: "len(names)$0",
+		}}, lenItem.TextEdit)
+		assert.False(t, containsCompletionItemLabel(items, "for"))
+		assert.False(t, containsCompletionItemLabel(items, "print"))
+	})
+
+	t.Run("Ranking", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+var turnSpeed int
+
+onStart => {
+	tu
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 4, Character: 3},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.Equal(t, "turnSpeed", items[0].Label)
+		assert.True(t, containsCompletionItemLabel(items, "turn"))
+		assert.False(t, containsCompletionItemLabel(items, "say"))
+		assert.True(t, slices.IsSortedFunc(items, func(a, b CompletionItem) int {
+			return strings.Compare(a.SortText, b.SortText)
+		}))
+	})
+
+	t.Run("RankingInternalDefinitions", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	cl
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 3},
+			},
+		})
+		require.NoError(t, err)
+		cloneIdx := slices.IndexFunc(items, func(item CompletionItem) bool { return item.Label == "clone" })
+		classfnameIdx := slices.IndexFunc(items, func(item CompletionItem) bool { return item.Label == "Classfname" })
+		require.GreaterOrEqual(t, cloneIdx, 0)
+		require.GreaterOrEqual(t, classfnameIdx, 0)
+		assert.Less(t, cloneIdx, classfnameIdx)
+	})
+
 	t.Run("AtLineStartWithAnIdentifier", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -501,7 +778,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -530,7 +807,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 14},
@@ -541,7 +818,7 @@ onClick => {
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "setCostume"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 15},
@@ -553,6 +830,61 @@ onClick => {
 		assert.True(t, containsCompletionItemLabel(items2, "setCostume"))
 	})
 
+	t.Run("EnumConsts", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	score int
+)
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	if keyPressed(KeyA) {
+		turn Left
+		touching Mouse
+	}
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		complete := func(position Position) []CompletionItem {
+			items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+					Position:     position,
+				},
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, items)
+			assert.False(t, containsCompletionItemLabel(items, "score"))
+			assert.False(t, containsCompletionItemLabel(items, "println"))
+			return items
+		}
+
+		keyItems := complete(Position{Line: 2, Character: 15})
+		assert.True(t, containsCompletionItemLabel(keyItems, "KeyA"))
+		assert.True(t, containsCompletionItemLabel(keyItems, "KeySpace"))
+		resolved, err := s.completionItemResolve(context.Background(), findCompletionItem(keyItems, "KeyA"))
+		require.NoError(t, err)
+		assert.Contains(t, resolved.Detail, "const KeyA = ")
+		assert.NotNil(t, resolved.Documentation)
+
+		dirItems := complete(Position{Line: 3, Character: 7})
+		for _, label := range []string{"Left", "Right", "Up", "Down"} {
+			assert.True(t, containsCompletionItemLabel(dirItems, label), label)
+		}
+		assert.False(t, containsCompletionItemLabel(dirItems, "KeyA"))
+
+		objItems := complete(Position{Line: 4, Character: 11})
+		for _, label := range []string{"Mouse", "Edge", "EdgeLeft"} {
+			assert.True(t, containsCompletionItemLabel(objItems, label), label)
+		}
+		assert.False(t, containsCompletionItemLabel(objItems, "Left"))
+		assert.True(t, containsCompletionItemLabel(objItems, `"MySprite"`))
+	})
+
 	t.Run("WithGopBuiltins", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -570,7 +902,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 9},
@@ -581,7 +913,7 @@ onClick => {
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "int128"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -594,6 +926,110 @@ onClick => {
 	})
 }
 
+func TestServerCompletionItemResolve(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+import "fmt"
+
+onStart => {
+
+}
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sounds/recording/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 4, Character: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	// resolve round-trips the item through JSON like a client does.
+	resolve := func(t *testing.T, label string, kind CompletionItemKind) *CompletionItem {
+		idx := slices.IndexFunc(items, func(item CompletionItem) bool {
+			return item.Label == label && item.Kind == kind
+		})
+		require.GreaterOrEqual(t, idx, 0, label)
+		assert.Nil(t, items[idx].Documentation)
+
+		b, err := json.Marshal(items[idx])
+		require.NoError(t, err)
+		var item CompletionItem
+		require.NoError(t, json.Unmarshal(b, &item))
+		resolved, err := s.completionItemResolve(context.Background(), &item)
+		require.NoError(t, err)
+		require.NotNil(t, resolved)
+		return resolved
+	}
+
+	t.Run("Definition", func(t *testing.T) {
+		item := resolve(t, "play", FunctionCompletion)
+		assert.Contains(t, item.Detail, "func play(")
+		require.NotNil(t, item.Documentation)
+		assert.Contains(t, item.Documentation.Value.(MarkupContent).Value, "<definition-item def-id=")
+		assert.Empty(t, item.AdditionalTextEdits)
+	})
+
+	t.Run("ImportedPkg", func(t *testing.T) {
+		item := resolve(t, "fmt", ModuleCompletion)
+		assert.Equal(t, "package fmt", item.Detail)
+		assert.Empty(t, item.AdditionalTextEdits)
+	})
+
+	t.Run("UnimportedPkg", func(t *testing.T) {
+		item := resolve(t, "strings", ModuleCompletion)
+		assert.Equal(t, "package strings", item.Detail)
+		require.NotNil(t, item.Documentation)
+		assert.Contains(t, item.Documentation.Value.(MarkupContent).Value, "Package strings implements")
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 0},
+					End:   Position{Line: 2, Character: 0},
+				},
+				NewText: "import \"strings\"\n",
+			},
+		}, item.AdditionalTextEdits)
+	})
+
+	t.Run("NotFromLatestCompletion", func(t *testing.T) {
+		item, err := s.completionItemResolve(context.Background(), &CompletionItem{
+			Label: "unknown",
+			Data:  &CompletionItemData{Definition: &SpxDefinitionIdentifier{Name: util.ToPtr("unknown")}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &CompletionItem{
+			Label: "unknown",
+			Data:  &CompletionItemData{Definition: &SpxDefinitionIdentifier{Name: util.ToPtr("unknown")}},
+		}, item)
+	})
+
+	t.Run("Resource", func(t *testing.T) {
+		item, err := s.completionItemResolve(context.Background(), &CompletionItem{
+			Label: "recording",
+			Data:  map[string]any{"resource": map[string]any{"uri": "spx://resources/sounds/recording"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &Or_CompletionItem_documentation{Value: MarkupContent{
+			Kind:  Markdown,
+			Value: "<resource-preview resource=\"spx://resources/sounds/recording\" />\n",
+		}}, item.Documentation)
+	})
+}
+
+func withoutSortText(items []CompletionItem) []CompletionItem {
+	items = slices.Clone(items)
+	for i := range items {
+		items[i].SortText = ""
+	}
+	return items
+}
+
 func containsCompletionItemLabel(items []CompletionItem, label string) bool {
 	return slices.ContainsFunc(items, func(item CompletionItem) bool {
 		return item.Label == label
@@ -603,9 +1039,19 @@ func containsCompletionItemLabel(items []CompletionItem, label string) bool {
 func containsCompletionSpxDefinitionID(items []CompletionItem, id SpxDefinitionIdentifier) bool {
 	return slices.ContainsFunc(items, func(item CompletionItem) bool {
 		itemData, ok := item.Data.(*CompletionItemData)
-		if !ok {
+		if !ok || itemData.Definition == nil {
 			return false
 		}
 		return itemData.Definition.String() == id.String()
 	})
 }
+
+func findCompletionItem(items []CompletionItem, label string) *CompletionItem {
+	idx := slices.IndexFunc(items, func(item CompletionItem) bool {
+		return item.Label == label
+	})
+	if idx < 0 {
+		return nil
+	}
+	return &items[idx]
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/goplus/goxlsw/internal/analysis/driver"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// The server recovers from panics in the handling of each message and in
+// background work, so that a bug in one feature cannot bring the whole server
+// down. Calls that panic fail with [jsonrpc2.ErrInternal], and each panic is
+// reported to the client with the xgo/crashReport notification.
+//
+// Panics of analyzers are recovered by the analysis driver. An analyzer that
+// keeps panicking is disabled for the rest of the session.
+
+// maxAnalyzerPanics is the number of panics after which an analyzer is
+// disabled for the session.
+const maxAnalyzerPanics = 3
+
+// CrashReport is the params of the xgo/crashReport notification, which the
+// server sends to the client after recovering from a panic.
+type CrashReport struct {
+	// Operation is what panicked, e.g., the method of a message, or the name
+	// of an analyzer in the form "analyzer printf".
+	Operation string `json:"operation"`
+
+	// Message is the value passed to panic.
+	Message string `json:"message"`
+
+	// Stack is the stack trace of the panicking goroutine.
+	Stack string `json:"stack"`
+
+	// AnalyzerDisabled reports whether the panicking analyzer has been
+	// disabled for the session because it panicked too many times.
+	AnalyzerDisabled bool `json:"analyzerDisabled,omitempty"`
+}
+
+// panicError is the error of an operation that panicked.
+type panicError struct {
+	operation string
+	value     any
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.operation, e.value)
+}
+
+// Unwrap returns [jsonrpc2.ErrInternal], so that the error is sent to the
+// client with its code.
+func (e *panicError) Unwrap() error {
+	return jsonrpc2.ErrInternal
+}
+
+// recoverPanic recovers from a panic of the given operation, if any, reports
+// it as a crash, and sets *err to a [panicError] if err is not nil. It must
+// be called directly by a deferred function call.
+func (s *Server) recoverPanic(operation string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	s.reportCrash(CrashReport{
+		Operation: operation,
+		Message:   fmt.Sprint(r),
+		Stack:     string(debug.Stack()),
+	})
+	if err != nil {
+		*err = &panicError{operation: operation, value: r}
+	}
+}
+
+// reportCrash logs the given crash and sends it to the client.
+func (s *Server) reportCrash(report CrashReport) {
+	s.logger.Error("recovered from panic", "operation", report.Operation, "panic", report.Message)
+	n, err := jsonrpc2.NewNotification("xgo/crashReport", report)
+	if err != nil {
+		return
+	}
+	_ = s.replier.ReplyMessage(n)
+}
+
+// analyzerCrashes keeps track of the panics of analyzers.
+type analyzerCrashes struct {
+	mu        sync.Mutex
+	panics    map[string]int                // number of panics by analyzer name
+	lastPanic map[string]*driver.PanicError // last panic by analyzer name
+	disabled  map[string]bool               // analyzers disabled for the session
+}
+
+// recordAnalyzerPanic records a panic of the analyzer with the given name,
+// which is disabled for the session once it panicked [maxAnalyzerPanics]
+// times. Panics are counted once even if the analysis driver returns them
+// again from its cache.
+func (s *Server) recordAnalyzerPanic(name string, panicErr *driver.PanicError) {
+	c := &s.analyzerCrashes
+	c.mu.Lock()
+	if c.lastPanic[name] == panicErr {
+		c.mu.Unlock()
+		return
+	}
+	if c.panics == nil {
+		c.panics = make(map[string]int)
+		c.lastPanic = make(map[string]*driver.PanicError)
+		c.disabled = make(map[string]bool)
+	}
+	c.lastPanic[name] = panicErr
+	c.panics[name]++
+	disabled := c.panics[name] >= maxAnalyzerPanics
+	if disabled {
+		c.disabled[name] = true
+	}
+	c.mu.Unlock()
+
+	s.reportCrash(CrashReport{
+		Operation:        "analyzer " + name,
+		Message:          fmt.Sprint(panicErr.Value),
+		Stack:            string(panicErr.Stack),
+		AnalyzerDisabled: disabled,
+	})
+}
+
+// isAnalyzerDisabled reports whether the analyzer with the given name has
+// been disabled for the session because it kept panicking.
+func (s *Server) isAnalyzerDisabled(name string) bool {
+	c := &s.analyzerCrashes
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.disabled[name]
+}
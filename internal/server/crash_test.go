@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/analysis/driver"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCrashRecovery(t *testing.T) {
+	crashReports := func(t *testing.T, replier *recordingReplier) []CrashReport {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		var reports []CrashReport
+		for _, m := range replier.messages {
+			if n, ok := m.(*jsonrpc2.Notification); ok && n.Method() == "xgo/crashReport" {
+				var report CrashReport
+				require.NoError(t, json.Unmarshal(n.Params(), &report))
+				reports = append(reports, report)
+			}
+		}
+		return reports
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(nil), replier, fileMapGetter(nil))
+
+		call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "textDocument/hover", nil)
+		require.NoError(t, err)
+		s.traceReceived(call)
+		s.runWithResponse(call.ID(), func(ctx context.Context) (any, error) {
+			panic("boom")
+		})
+
+		var resp *jsonrpc2.Response
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			for _, m := range replier.messages {
+				if r, ok := m.(*jsonrpc2.Response); ok {
+					resp = r
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, jsonrpc2.NewIntID(1), resp.ID())
+		assert.ErrorIs(t, resp.Err(), jsonrpc2.ErrInternal)
+		assert.EqualError(t, resp.Err(), "textDocument/hover panicked: boom")
+
+		reports := crashReports(t, replier)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "textDocument/hover", reports[0].Operation)
+		assert.Equal(t, "boom", reports[0].Message)
+		assert.Contains(t, reports[0].Stack, "TestServerCrashRecovery")
+		assert.False(t, reports[0].AnalyzerDisabled)
+
+		// The server keeps serving.
+		s.runWithResponse(jsonrpc2.NewIntID(2), func(ctx context.Context) (any, error) {
+			return "ok", nil
+		})
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			r, ok := replier.messages[len(replier.messages)-1].(*jsonrpc2.Response)
+			return ok && r.ID() == jsonrpc2.NewIntID(2) && r.Err() == nil
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Analyzer", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(nil), replier, fileMapGetter(nil))
+		assert.Contains(t, analyzerSeverities(s), "printf")
+
+		for i := range maxAnalyzerPanics {
+			panicErr := &driver.PanicError{Value: "oops", Stack: []byte("stack")}
+			s.recordAnalyzerPanic("printf", panicErr)
+			// Cached results of the analysis driver return the same panic.
+			s.recordAnalyzerPanic("printf", panicErr)
+
+			reports := crashReports(t, replier)
+			require.Len(t, reports, i+1)
+			assert.Equal(t, CrashReport{
+				Operation:        "analyzer printf",
+				Message:          "oops",
+				Stack:            "stack",
+				AnalyzerDisabled: i == maxAnalyzerPanics-1,
+			}, reports[i])
+		}
+		assert.NotContains(t, analyzerSeverities(s), "printf")
+		assert.Contains(t, analyzerSeverities(s), "appends")
+
+		// Settings do not enable it again.
+		require.NoError(t, s.applySettings(nil))
+		assert.NotContains(t, analyzerSeverities(s), "printf")
+	})
+}
@@ -1,10 +1,19 @@
 package server
 
-import "go/types"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"path"
+
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_declaration
-func (s *Server) textDocumentDeclaration(params *DeclarationParams) (any, error) {
-	return s.textDocumentDefinition(&DefinitionParams{
+func (s *Server) textDocumentDeclaration(ctx context.Context, params *DeclarationParams) (any, error) {
+	return s.textDocumentDefinition(ctx, &DefinitionParams{
 		TextDocumentPositionParams: params.TextDocumentPositionParams,
 		WorkDoneProgressParams:     params.WorkDoneProgressParams,
 		PartialResultParams:        params.PartialResultParams,
@@ -12,8 +21,8 @@ func (s *Server) textDocumentDeclaration(params *DeclarationParams) (any, error)
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_definition
-func (s *Server) textDocumentDefinition(params *DefinitionParams) (any, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentDefinition(ctx context.Context, params *DefinitionParams) (any, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -22,8 +31,15 @@ func (s *Server) textDocumentDefinition(params *DefinitionParams) (any, error) {
 	}
 	position := result.toPosition(astFile, params.Position)
 
+	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil && spxResourceRef.Kind == SpxResourceRefKindStringLiteral {
+		return s.spxResourceDefinitionLocation(result, spxResourceRef.ID)
+	}
+
 	obj := getTypeInfo(result.proj).ObjectOf(result.identAtASTFilePosition(astFile, position))
 	if !isMainPkgObject(obj) {
+		if loc := s.workspacePkgObjectLocation(result, obj); loc != nil {
+			return *loc, nil
+		}
 		return nil, nil
 	}
 
@@ -40,9 +56,143 @@ func (s *Server) textDocumentDefinition(params *DefinitionParams) (any, error) {
 	return result.locationForNode(defIdent), nil
 }
 
+// workspacePkgObjectLocation returns the location of the given object if it is
+// defined in another package of the workspace, i.e., a package in a
+// subdirectory of the module declared by gop.mod or go.mod, or a vendored
+// package. It returns nil otherwise.
+func (s *Server) workspacePkgObjectLocation(result *compileResult, obj types.Object) *Location {
+	if obj == nil || obj.Pkg() == nil {
+		return nil
+	}
+	if _, ok := result.proj.ResolveImport(obj.Pkg().Path()); !ok {
+		return nil
+	}
+	if !result.isInFset(obj.Pos()) {
+		return nil
+	}
+	filename := result.posFilename(obj.Pos())
+	astFile, _ := result.proj.AST(filename)
+	if astFile == nil {
+		return nil
+	}
+	return &Location{
+		URI:   s.toDocumentURI(filename),
+		Range: result.rangeForStartEnd(astFile, obj.Pos(), obj.Pos()+goptoken.Pos(len(obj.Name()))),
+	}
+}
+
+// spxResourceDefinitionLocation returns the location of the metadata entry of
+// the spx resource identified by id. It returns nil if the resource does not
+// exist.
+//
+// Sprites and sounds resolve to their own index.json files, while other
+// resources resolve to the name of their entries in the index.json of either
+// the resource root or their sprite.
+func (s *Server) spxResourceDefinitionLocation(result *compileResult, id SpxResourceID) (any, error) {
+	rootDir := result.spxResourceRootDir
+	var (
+		metadataFile string
+		match        func(path []string) bool
+	)
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		if result.spxResourceSet.Backdrop(id.BackdropName) == nil {
+			return nil, nil
+		}
+		metadataFile = path.Join(rootDir, "index.json")
+		match = func(path []string) bool {
+			return len(path) == 3 && path[0] == "backdrops" && path[2] == "name"
+		}
+	case SpxSoundResourceID:
+		if result.spxResourceSet.Sound(id.SoundName) == nil {
+			return nil, nil
+		}
+		return Location{URI: s.toDocumentURI(path.Join(rootDir, "sounds", id.SoundName, "index.json"))}, nil
+	case SpxSpriteResourceID:
+		if result.spxResourceSet.Sprite(id.SpriteName) == nil {
+			return nil, nil
+		}
+		return Location{URI: s.toDocumentURI(path.Join(rootDir, "sprites", id.SpriteName, "index.json"))}, nil
+	case SpxSpriteCostumeResourceID:
+		sprite := result.spxResourceSet.Sprite(id.SpriteName)
+		if sprite == nil || sprite.Costume(id.CostumeName) == nil {
+			return nil, nil
+		}
+		metadataFile = path.Join(rootDir, "sprites", id.SpriteName, "index.json")
+		match = func(path []string) bool {
+			return len(path) == 3 && path[0] == "costumes" && path[2] == "name"
+		}
+	case SpxSpriteAnimationResourceID:
+		sprite := result.spxResourceSet.Sprite(id.SpriteName)
+		if sprite == nil || sprite.Animation(id.AnimationName) == nil {
+			return nil, nil
+		}
+		metadataFile = path.Join(rootDir, "sprites", id.SpriteName, "index.json")
+		metadata, err := vfs.ReadFile(result.proj, metadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", metadataFile, err)
+		}
+		location := Location{URI: s.toDocumentURI(metadataFile)}
+		if err := walkJSON(metadata, func(path []string, _ json.RawMessage, start, end int) bool {
+			if len(path) == 2 && path[0] == "fAnimations" && path[1] == id.AnimationName {
+				location.Range = Range{
+					Start: result.posEncoding.Position(metadata, start),
+					End:   result.posEncoding.Position(metadata, end),
+				}
+			}
+			return len(path) < 2
+		}); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", metadataFile, err)
+		}
+		return location, nil
+	case SpxWidgetResourceID:
+		if result.spxResourceSet.Widget(id.WidgetName) == nil {
+			return nil, nil
+		}
+		metadataFile = path.Join(rootDir, "index.json")
+		match = func(path []string) bool {
+			return len(path) == 3 && path[0] == "zorder" && path[2] == "name"
+		}
+	case SpxSceneResourceID:
+		if result.spxResourceSet.Scene(id.SceneName) == nil {
+			return nil, nil
+		}
+		metadataFile = path.Join(rootDir, "index.json")
+		match = func(path []string) bool {
+			return len(path) == 3 && path[0] == "scenes" && path[2] == "name"
+		}
+	case SpxFontResourceID:
+		if result.spxResourceSet.Font(id.FontName) == nil {
+			return nil, nil
+		}
+		return Location{URI: s.toDocumentURI(path.Join(rootDir, "fonts", id.FontName, "index.json"))}, nil
+	case SpxStageResourceID:
+		if result.spxResourceSet.Stage() == nil {
+			return nil, nil
+		}
+		return Location{URI: s.toDocumentURI(path.Join(rootDir, "index.json"))}, nil
+	default:
+		return nil, nil
+	}
+
+	metadata, err := vfs.ReadFile(result.proj, metadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", metadataFile, err)
+	}
+	ranges, err := jsonStringValueRanges(result.posEncoding, metadata, id.Name(), match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", metadataFile, err)
+	}
+	location := Location{URI: s.toDocumentURI(metadataFile)}
+	if len(ranges) > 0 {
+		location.Range = ranges[0]
+	}
+	return location, nil
+}
+
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_typeDefinition
-func (s *Server) textDocumentTypeDefinition(params *TypeDefinitionParams) (any, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentTypeDefinition(ctx context.Context, params *TypeDefinitionParams) (any, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -62,9 +212,9 @@ func (s *Server) textDocumentTypeDefinition(params *TypeDefinitionParams) (any,
 		return nil, nil
 	}
 
-	objPos := named.Obj().Pos()
-	if !result.isInFset(objPos) {
+	loc, ok := result.locationForNamedType(named)
+	if !ok {
 		return nil, nil
 	}
-	return result.locationForPos(objPos), nil
+	return loc, nil
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mainSpxMySpriteDef, err := s.textDocumentDefinition(&DefinitionParams{
+		mainSpxMySpriteDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 0},
@@ -44,7 +45,7 @@ onStart => {
 			},
 		}, mainSpxMySpriteDef.(Location))
 
-		mainSpxMySpriteTurnDef, err := s.textDocumentDefinition(&DefinitionParams{
+		mainSpxMySpriteTurnDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 9},
@@ -53,7 +54,7 @@ onStart => {
 		require.NoError(t, err)
 		require.Nil(t, mainSpxMySpriteTurnDef)
 
-		mySpriteSpxMySpriteDef, err := s.textDocumentDefinition(&DefinitionParams{
+		mySpriteSpxMySpriteDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -71,6 +72,83 @@ onStart => {
 		}, mainSpxMySpriteDef.(Location))
 	})
 
+	t.Run("SpxResource", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+onBackdrop "backdrop1", func() {}
+play "Sound2"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume1"
+}
+`),
+			"assets/index.json": []byte(`{
+  "backdrops": [
+    {"name": "backdrop1", "path": "backdrop1.png"}
+  ]
+}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumes": [
+    {"name": "costume0", "path": "costume0.png"},
+    {"name": "costume1", "path": "costume1.png"}
+  ]
+}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		soundDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{URI: "file:///assets/sounds/Sound1/index.json"}, soundDef)
+
+		backdropDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{
+			URI: "file:///assets/index.json",
+			Range: Range{
+				Start: Position{Line: 2, Character: 14},
+				End:   Position{Line: 2, Character: 23},
+			},
+		}, backdropDef)
+
+		costumeDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{
+			URI: "file:///assets/sprites/MySprite/index.json",
+			Range: Range{
+				Start: Position{Line: 3, Character: 14},
+				End:   Position{Line: 3, Character: 22},
+			},
+		}, costumeDef)
+
+		missingSoundDef, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, missingSoundDef)
+	})
+
 	t.Run("BuiltinType", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -79,7 +157,7 @@ var x int
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentDefinition(&DefinitionParams{
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -97,7 +175,7 @@ this.run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentDefinition(&DefinitionParams{
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 0},
@@ -115,7 +193,7 @@ var x int
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentDefinition(&DefinitionParams{
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 99, Character: 99},
@@ -134,7 +212,7 @@ fmt.println "Hello, spx!"
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentDefinition(&DefinitionParams{
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 0},
@@ -161,7 +239,7 @@ fmt2.println "Hello, spx!"
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentDefinition(&DefinitionParams{
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 0},
@@ -178,6 +256,42 @@ fmt2.println "Hello, spx!"
 			},
 		}, def.(Location))
 	})
+	t.Run("WorkspacePackage", func(t *testing.T) {
+		m := map[string][]byte{
+			"gop.mod": []byte("gop 1.2\n\nmodule example.com/game\n"),
+			"main.spx": []byte(`
+import "example.com/game/util"
+echo util.Add(1, 2)
+run "assets", {Title: "My Game"}
+`),
+			"util/util.gop": []byte(`package util
+
+// Add adds.
+func Add(a, b int) int {
+	return a + b
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 10},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, def)
+		require.IsType(t, Location{}, def)
+		assert.Equal(t, Location{
+			URI: "file:///util/util.gop",
+			Range: Range{
+				Start: Position{Line: 3, Character: 5},
+				End:   Position{Line: 3, Character: 8},
+			},
+		}, def.(Location))
+	})
 }
 
 func TestServerTextDocumentTypeDefinition(t *testing.T) {
@@ -192,7 +306,7 @@ var x MyType
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentTypeDefinition(&TypeDefinitionParams{
+		def, err := s.textDocumentTypeDefinition(context.Background(), &TypeDefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 6},
@@ -222,7 +336,7 @@ var (
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentTypeDefinition(&TypeDefinitionParams{
+		def, err := s.textDocumentTypeDefinition(context.Background(), &TypeDefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 10},
@@ -232,6 +346,32 @@ var (
 		require.Nil(t, def)
 	})
 
+	t.Run("SpriteClassType", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		def, err := s.textDocumentTypeDefinition(context.Background(), &TypeDefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{URI: "file:///MySprite.spx"}, def)
+	})
+
 	t.Run("BuiltinType", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -240,7 +380,7 @@ var x int
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentTypeDefinition(&TypeDefinitionParams{
+		def, err := s.textDocumentTypeDefinition(context.Background(), &TypeDefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -258,7 +398,7 @@ var x int
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		def, err := s.textDocumentTypeDefinition(&TypeDefinitionParams{
+		def, err := s.textDocumentTypeDefinition(context.Background(), &TypeDefinitionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 99, Character: 99},
@@ -267,4 +407,29 @@ var x int
 		require.NoError(t, err)
 		require.Nil(t, def)
 	})
+
+	t.Run("VendoredPackage", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+import "github.com/foo/bar"
+echo bar.Answer
+run "assets", {Title: "My Game"}
+`),
+			"vendor/github.com/foo/bar/bar.go": []byte("package bar\n\nconst Answer = 42\n"),
+			"assets/index.json":                []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		def, err := s.textDocumentDefinition(context.Background(), &DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 10},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, def)
+		require.IsType(t, Location{}, def)
+		assert.Equal(t, "file:///vendor/github.com/foo/bar/bar.go", string(def.(Location).URI))
+		assert.Equal(t, Position{Line: 2, Character: 6}, def.(Location).Range.Start)
+	})
 }
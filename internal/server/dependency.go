@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/pkgdata"
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// defaultVendorDir is the default of [Settings.VendorDir].
+const defaultVendorDir = "vendor"
+
+// isPkgAvailable reports whether the package with the given import path can be
+// imported by the project at snapshot, i.e., it is builtin, or provided by the
+// workspace as a package of its module or a vendored package.
+func isPkgAvailable(snapshot *vfs.MapFS, builtinPkgPaths []string, pkgPath string) bool {
+	if pkgPath == "unsafe" || slices.Contains(builtinPkgPaths, pkgPath) {
+		return true
+	}
+	_, ok := snapshot.ResolveImport(pkgPath)
+	return ok
+}
+
+// inspectForMissingImports inspects for imports of packages that are not
+// available, see [isPkgAvailable], and suggests adding them as dependencies.
+// It returns the positions of the paths of such imports, for which errors of
+// type checking are superseded.
+func (s *Server) inspectForMissingImports(snapshot *vfs.MapFS, result *compileResult) map[goptoken.Pos]struct{} {
+	builtinPkgPaths, err := pkgdata.ListPkgs()
+	if err != nil {
+		return nil
+	}
+	vendorDir := snapshot.VendorDir()
+	missing := make(map[goptoken.Pos]struct{})
+	for spxFile, astFile := range getASTPkg(snapshot).Files {
+		for _, imp := range astFile.Imports {
+			pkgPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || isPkgAvailable(snapshot, builtinPkgPaths, pkgPath) {
+				continue
+			}
+			missing[imp.Path.Pos()] = struct{}{}
+
+			diagnostic := Diagnostic{
+				Severity: SeverityError,
+				Range:    result.rangeForASTFileNode(astFile, imp.Path),
+				Message:  fmt.Sprintf("package %s is not available", pkgPath),
+			}
+			if vendorDir == "" {
+				result.addDiagnosticsForSpxFile(spxFile, diagnostic)
+				continue
+			}
+			dir := path.Join(vendorDir, pkgPath)
+			diagnostic.Message += fmt.Sprintf(", add it as a dependency in %s", dir)
+			result.addDiagnosticsForSpxFile(spxFile, diagnostic)
+
+			arg, err := json.Marshal(SpxAddDependencyParams{ImportPath: pkgPath, Dir: dir})
+			if err != nil {
+				continue
+			}
+			title := fmt.Sprintf("Add dependency %s", pkgPath)
+			documentURI := result.documentURIs[spxFile]
+			result.quickFixes[documentURI] = append(result.quickFixes[documentURI], quickFix{
+				diagnostic: diagnostic,
+				title:      title,
+				command: &Command{
+					Title:     title,
+					Command:   "spx.addDependency",
+					Arguments: []json.RawMessage{arg},
+				},
+				isPreferred: true,
+			})
+		}
+	}
+	return missing
+}
+
+// spxAddDependency adds the package with the given import path as a
+// dependency. As the server has no access to module proxies, it asks the
+// client to fetch the module and provide the package in the given directory
+// with a spx/fetchDependency call, and reloads the directory once the client
+// has written the files of the package.
+func (s *Server) spxAddDependency(ctx context.Context, params []SpxAddDependencyParams) (any, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.addDependency only supports one dependency at a time")
+	}
+	param := params[0]
+
+	snapshot := s.snapshot()
+	vendorDir := snapshot.VendorDir()
+	if vendorDir == "" {
+		return nil, errors.New("vendoring is disabled")
+	}
+	if param.Dir != path.Join(vendorDir, param.ImportPath) {
+		return nil, fmt.Errorf("%w: dependency %s must be provided in %s", jsonrpc2.ErrInvalidParams, param.ImportPath, path.Join(vendorDir, param.ImportPath))
+	}
+	builtinPkgPaths, err := pkgdata.ListPkgs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builtin packages: %w", err)
+	}
+	if isPkgAvailable(snapshot, builtinPkgPaths, param.ImportPath) {
+		return nil, fmt.Errorf("package %s is already available", param.ImportPath)
+	}
+	if _, err := s.callClient(ctx, "spx/fetchDependency", &param); err != nil {
+		return nil, fmt.Errorf("failed to fetch dependency %s: %w", param.ImportPath, err)
+	}
+	s.InvalidateFiles(param.Dir)
+	return nil, nil
+}
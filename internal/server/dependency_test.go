@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dependencyClientReplier is a [recordingReplier] that responds to
+// spx/fetchDependency calls like a client, by writing the files of the
+// package to files.
+type dependencyClientReplier struct {
+	recordingReplier
+	s       *Server
+	files   map[string][]byte
+	fetched []SpxAddDependencyParams
+	refuse  bool
+}
+
+func (r *dependencyClientReplier) ReplyMessage(m jsonrpc2.Message) error {
+	if err := r.recordingReplier.ReplyMessage(m); err != nil {
+		return err
+	}
+	call, ok := m.(*jsonrpc2.Call)
+	if !ok || call.Method() != "spx/fetchDependency" {
+		return nil
+	}
+	var params SpxAddDependencyParams
+	if err := UnmarshalJSON(call.Params(), &params); err != nil {
+		return err
+	}
+	var err error
+	if r.refuse {
+		err = errors.New("module not found")
+	} else {
+		r.fetched = append(r.fetched, params)
+		pkgName := path.Base(params.ImportPath)
+		r.files[path.Join(params.Dir, pkgName+".go")] = []byte("package " + pkgName + "\n\nconst X = 1\n")
+	}
+	resp, err := jsonrpc2.NewResponse(call.ID(), nil, err)
+	if err != nil {
+		return err
+	}
+	return r.s.HandleMessage(resp)
+}
+
+func TestServerMissingImports(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+import (
+	"fmt"
+	"github.com/foo/bar"
+	"github.com/foo/baz"
+)
+fmt.println bar.X, baz.Y
+run "assets", {Title: "My Game"}
+`),
+		"vendor/github.com/foo/baz/baz.go": []byte("package baz\n\nconst Y = 1\n"),
+		"assets/index.json":                []byte(`{}`),
+	}
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []Diagnostic{{
+			Severity: SeverityError,
+			Range: Range{
+				Start: Position{Line: 3, Character: 1},
+				End:   Position{Line: 3, Character: 21},
+			},
+			Message: "package github.com/foo/bar is not available, add it as a dependency in vendor/github.com/foo/bar",
+		}, {
+			Severity: SeverityError,
+			Range: Range{
+				Start: Position{Line: 6, Character: 12},
+				End:   Position{Line: 6, Character: 12},
+			},
+			Message: "undefined: bar",
+		}}, result.diagnostics["file:///main.spx"])
+	})
+
+	t.Run("QuickFix", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		actions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        Range{Start: Position{Line: 3, Character: 5}, End: Position{Line: 3, Character: 5}},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		assert.Equal(t, "Add dependency github.com/foo/bar", actions[0].Title)
+		assert.Nil(t, actions[0].Edit)
+		require.NotNil(t, actions[0].Command)
+		assert.Equal(t, "spx.addDependency", actions[0].Command.Command)
+		require.Len(t, actions[0].Command.Arguments, 1)
+		var params SpxAddDependencyParams
+		require.NoError(t, json.Unmarshal(actions[0].Command.Arguments[0], &params))
+		assert.Equal(t, SpxAddDependencyParams{ImportPath: "github.com/foo/bar", Dir: "vendor/github.com/foo/bar"}, params)
+
+		arg, err := json.Marshal(SpxAddDependencyParams{ImportPath: "github.com/foo/baz", Dir: "vendor/github.com/foo/baz"})
+		require.NoError(t, err)
+		_, err = s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.addDependency",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.EqualError(t, err, "package github.com/foo/baz is already available")
+	})
+
+	t.Run("AddDependency", func(t *testing.T) {
+		m := maps.Clone(m)
+		replier := &dependencyClientReplier{files: m}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		replier.s = s
+
+		arg, err := json.Marshal(SpxAddDependencyParams{ImportPath: "github.com/foo/bar", Dir: "vendor/github.com/foo/bar"})
+		require.NoError(t, err)
+		_, err = s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.addDependency",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []SpxAddDependencyParams{{ImportPath: "github.com/foo/bar", Dir: "vendor/github.com/foo/bar"}}, replier.fetched)
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+	})
+
+	t.Run("AddDependencyFetchFailed", func(t *testing.T) {
+		m := maps.Clone(m)
+		replier := &dependencyClientReplier{files: m, refuse: true}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		replier.s = s
+
+		arg, err := json.Marshal(SpxAddDependencyParams{ImportPath: "github.com/foo/bar", Dir: "vendor/github.com/foo/bar"})
+		require.NoError(t, err)
+		_, err = s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.addDependency",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.EqualError(t, err, "failed to fetch dependency github.com/foo/bar: module not found")
+	})
+
+	t.Run("AddDependencyOutsideVendorDir", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		arg, err := json.Marshal(SpxAddDependencyParams{ImportPath: "github.com/foo/bar", Dir: "assets"})
+		require.NoError(t, err)
+		_, err = s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.addDependency",
+			Arguments: []json.RawMessage{arg},
+		})
+		require.ErrorIs(t, err, jsonrpc2.ErrInvalidParams)
+	})
+
+	t.Run("CustomVendorDir", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{"vendorDir": "third_party"}
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		var messages []string
+		for _, diagnostic := range result.diagnostics["file:///main.spx"] {
+			messages = append(messages, diagnostic.Message)
+		}
+		assert.Contains(t, messages, "package github.com/foo/baz is not available, add it as a dependency in third_party/github.com/foo/baz")
+	})
+}
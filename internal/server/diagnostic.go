@@ -1,38 +1,210 @@
 package server
 
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// snapshotDiagnostics are the diagnostics of all documents at a snapshot of
+// the workspace, along with their result IDs.
+type snapshotDiagnostics struct {
+	snapshot        *vfs.MapFS
+	settingsVersion uint64 // see [Server.getSettingsVersion]
+	diagnostics     map[DocumentURI][]Diagnostic
+	resultIDs       map[DocumentURI]string
+}
+
+// newSnapshotDiagnostics creates a new [snapshotDiagnostics] of the given
+// compile result.
+func newSnapshotDiagnostics(result *compileResult, settingsVersion uint64) *snapshotDiagnostics {
+	resultIDs := make(map[DocumentURI]string, len(result.diagnostics))
+	for documentURI, diags := range result.diagnostics {
+		resultIDs[documentURI] = diagnosticsResultID(diags)
+	}
+	return &snapshotDiagnostics{
+		snapshot:        result.proj,
+		settingsVersion: settingsVersion,
+		diagnostics:     result.diagnostics,
+		resultIDs:       resultIDs,
+	}
+}
+
+// pullDiagnostics returns the diagnostics of the latest snapshot of the
+// workspace. They are compiled only if the snapshot or the settings changed
+// since the last diagnostics were computed, so that clients pulling again
+// without any changes in between are answered right away.
+func (s *Server) pullDiagnostics(ctx context.Context) (*snapshotDiagnostics, error) {
+	settingsVersion := s.getSettingsVersion()
+	snapshot := s.snapshot()
+	if last := s.lastDiagnostics.Load(); last != nil && last.snapshot == snapshot && last.settingsVersion == settingsVersion {
+		return last, nil
+	}
+	result, err := s.compileAt(ctx, snapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	diags := newSnapshotDiagnostics(result, settingsVersion)
+	s.lastDiagnostics.Store(diags)
+	return diags, nil
+}
+
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_diagnostic
-func (s *Server) textDocumentDiagnostic(params *DocumentDiagnosticParams) (*DocumentDiagnosticReport, error) {
-	result, err := s.compile()
+func (s *Server) textDocumentDiagnostic(ctx context.Context, params *DocumentDiagnosticParams) (*DocumentDiagnosticReport, error) {
+	snapshotDiags, err := s.pullDiagnostics(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	diags, ok := snapshotDiags.diagnostics[params.TextDocument.URI]
+	resultID := snapshotDiags.resultIDs[params.TextDocument.URI]
+	if !ok {
+		resultID = diagnosticsResultID(nil)
+	}
+	if params.PreviousResultID == resultID {
+		return &DocumentDiagnosticReport{Value: RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+				Kind:     string(DiagnosticUnchanged),
+				ResultID: resultID,
+			},
+		}}, nil
+	}
 	return &DocumentDiagnosticReport{Value: RelatedFullDocumentDiagnosticReport{
 		FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
-			Kind:  string(DiagnosticFull),
-			Items: result.diagnostics[params.TextDocument.URI],
+			Kind:     string(DiagnosticFull),
+			ResultID: resultID,
+			Items:    diags,
 		},
 	}}, nil
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_diagnostic
-func (s *Server) workspaceDiagnostic(params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
-	result, err := s.compile()
+func (s *Server) workspaceDiagnostic(ctx context.Context, params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
+	snapshotDiags, err := s.pullDiagnostics(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	items := make([]WorkspaceDocumentDiagnosticReport, 0, len(result.diagnostics))
-	for file, fileDiags := range result.diagnostics {
+	previousResultIDs := make(map[DocumentURI]string, len(params.PreviousResultIds))
+	for _, id := range params.PreviousResultIds {
+		previousResultIDs[id.URI] = id.Value
+	}
+
+	items := make([]WorkspaceDocumentDiagnosticReport, 0, len(snapshotDiags.diagnostics))
+	for file, fileDiags := range snapshotDiags.diagnostics {
+		resultID := snapshotDiags.resultIDs[file]
+		if previousResultIDs[file] == resultID {
+			items = append(items, WorkspaceDocumentDiagnosticReport{
+				Value: WorkspaceUnchangedDocumentDiagnosticReport{
+					URI: file,
+					UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+						Kind:     string(DiagnosticUnchanged),
+						ResultID: resultID,
+					},
+				},
+			})
+			continue
+		}
 		items = append(items, WorkspaceDocumentDiagnosticReport{
 			Value: WorkspaceFullDocumentDiagnosticReport{
 				URI: DocumentURI(file),
 				FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
-					Kind:  string(DiagnosticFull),
-					Items: fileDiags,
+					Kind:     string(DiagnosticFull),
+					ResultID: resultID,
+					Items:    fileDiags,
+				},
+			},
+		})
+	}
+	// Clear diagnostics of documents that were reported before but are no
+	// longer, e.g., metadata files of spx resources that became valid.
+	for _, id := range params.PreviousResultIds {
+		if _, ok := snapshotDiags.diagnostics[id.URI]; ok {
+			continue
+		}
+		items = append(items, WorkspaceDocumentDiagnosticReport{
+			Value: WorkspaceFullDocumentDiagnosticReport{
+				URI: id.URI,
+				FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
+					Kind:     string(DiagnosticFull),
+					ResultID: diagnosticsResultID(nil),
+					Items:    []Diagnostic{},
 				},
 			},
 		})
 	}
 	return &WorkspaceDiagnosticReport{Items: items}, nil
 }
+
+// diagnosticsResultID returns the result ID of a diagnostic report containing
+// the given diagnostics. It only depends on the content of the diagnostics, so
+// that clients can be answered with an "unchanged" report when a compilation
+// yields the same diagnostics in a different order.
+func diagnosticsResultID(diags []Diagnostic) string {
+	encodedDiags := make([]string, 0, len(diags))
+	for _, diag := range diags {
+		b, err := json.Marshal(diag)
+		if err != nil {
+			panic(err) // Diagnostics are always encodable.
+		}
+		encodedDiags = append(encodedDiags, string(b))
+	}
+	slices.Sort(encodedDiags)
+
+	h := sha256.New()
+	for _, encodedDiag := range encodedDiags {
+		h.Write([]byte(encodedDiag))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// publishAllDiagnostics compiles the workspace and publishes the diagnostics
+// of all documents. Nothing is published if ctx is done before the
+// compilation completes. The progress of the compilation is reported to the
+// client if it supports work done progress.
+func (s *Server) publishAllDiagnostics(ctx context.Context) error {
+	progress := s.beginWorkDoneProgress(ctx, "Checking project")
+	defer progress.end("")
+	settingsVersion := s.getSettingsVersion()
+	result, err := s.compileWithProgress(ctx, progress)
+	if err != nil {
+		return err
+	}
+	s.lastDiagnostics.Store(newSnapshotDiagnostics(result, settingsVersion))
+	s.publishedDiagnosticsMu.Lock()
+	defer s.publishedDiagnosticsMu.Unlock()
+
+	// Clear diagnostics of documents that are no longer reported, e.g.,
+	// metadata files of spx resources that became valid.
+	for _, documentURI := range slices.Sorted(maps.Keys(s.publishedDiagnostics)) {
+		if _, ok := result.diagnostics[documentURI]; ok {
+			continue
+		}
+		if err := s.publishDiagnostics(documentURI, []Diagnostic{}); err != nil {
+			return err
+		}
+		delete(s.publishedDiagnostics, documentURI)
+	}
+
+	for _, documentURI := range slices.Sorted(maps.Keys(result.diagnostics)) {
+		diags := result.diagnostics[documentURI]
+		if err := s.publishDiagnostics(documentURI, diags); err != nil {
+			return err
+		}
+		if len(diags) > 0 {
+			if s.publishedDiagnostics == nil {
+				s.publishedDiagnostics = make(map[DocumentURI]struct{})
+			}
+			s.publishedDiagnostics[documentURI] = struct{}{}
+		} else {
+			delete(s.publishedDiagnostics, documentURI)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// diagnosticDelay is the delay after the last document change before
+// diagnostics are published.
+const diagnosticDelay = 200 * time.Millisecond
+
+// diagnosticScheduler debounces and coalesces diagnostics runs, so that a
+// burst of document changes, e.g. from a fast typist, results in a single run
+// after the changes settle.
+//
+// Scheduling a run cancels the in-flight one, if any. Runs never overlap: a
+// new run starts only after the canceled one returned.
+type diagnosticScheduler struct {
+	delay time.Duration
+	run   func(ctx context.Context)
+
+	runMu sync.Mutex // held while a run is in flight
+
+	mu     sync.Mutex // guards the fields below
+	timer  *time.Timer
+	ctx    context.Context    // context of the latest run
+	cancel context.CancelFunc // cancels the latest run
+}
+
+// newDiagnosticScheduler creates a new [diagnosticScheduler] that calls run
+// the given delay after the last call to [diagnosticScheduler.schedule].
+func newDiagnosticScheduler(delay time.Duration, run func(ctx context.Context)) *diagnosticScheduler {
+	return &diagnosticScheduler{delay: delay, run: run}
+}
+
+// schedule schedules a run, replacing any pending one and canceling the
+// in-flight one.
+func (d *diagnosticScheduler) schedule() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.ctx, d.cancel = ctx, cancel
+	d.timer = time.AfterFunc(d.delay, func() {
+		d.runMu.Lock()
+		defer d.runMu.Unlock()
+		if ctx.Err() != nil {
+			return // Superseded while waiting for the previous run.
+		}
+		d.run(ctx)
+	})
+}
+
+// flush runs the pending run, if any, right away instead of after the delay,
+// and waits for it to return. If a run is already in flight, it waits for it
+// instead.
+func (d *diagnosticScheduler) flush() {
+	d.mu.Lock()
+	pending := d.timer != nil && d.timer.Stop()
+	ctx := d.ctx
+	d.mu.Unlock()
+
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+	if pending && ctx.Err() == nil {
+		d.run(ctx)
+	}
+}
+
+// stop cancels the pending and in-flight runs, if any.
+func (d *diagnosticScheduler) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticScheduler(t *testing.T) {
+	t.Run("Debounce", func(t *testing.T) {
+		var runs atomic.Int32
+		d := newDiagnosticScheduler(20*time.Millisecond, func(ctx context.Context) {
+			runs.Add(1)
+		})
+
+		for range 5 {
+			d.schedule()
+		}
+		require.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		assert.EqualValues(t, 1, runs.Load())
+	})
+
+	t.Run("CancelInFlight", func(t *testing.T) {
+		var (
+			runs     atomic.Int32
+			canceled atomic.Int32
+		)
+		started := make(chan struct{})
+		d := newDiagnosticScheduler(0, func(ctx context.Context) {
+			if runs.Add(1) == 1 {
+				close(started)
+				<-ctx.Done()
+				canceled.Add(1)
+			}
+		})
+
+		d.schedule()
+		<-started
+		d.schedule()
+		require.Eventually(t, func() bool { return runs.Load() == 2 }, time.Second, time.Millisecond)
+		assert.EqualValues(t, 1, canceled.Load())
+	})
+}
+
+func TestServerTextDocumentDidChange(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+echo undefinedVar
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	replier := &recordingReplier{}
+	s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+	initializeServer(t, s)
+	s.diagnosticScheduler.delay = 10 * time.Millisecond
+
+	for range 3 {
+		n, err := jsonrpc2.NewNotification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": "file:///main.spx", "version": 1},
+			"contentChanges": []any{},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+	}
+
+	require.Eventually(t, func() bool {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		return len(replier.messages) > 0
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	replier.mu.Lock()
+	defer replier.mu.Unlock()
+	require.Len(t, replier.messages, 1)
+	published, ok := replier.messages[0].(*jsonrpc2.Notification)
+	require.True(t, ok)
+	assert.Equal(t, "textDocument/publishDiagnostics", published.Method())
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -57,7 +59,7 @@ func TestServerTextDocumentDiagnostic(t *testing.T) {
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -79,7 +81,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -105,6 +107,108 @@ var (
 		})
 	})
 
+	t.Run("AnalyzerDiagnostics", func(t *testing.T) {
+		fileMap := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	printf "%d\n", "hello"
+	return
+	echo "unreachable"
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(fileMap), nil, fileMapGetter(fileMap))
+		params := &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		assert.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		require.Len(t, fullReport.Items, 2)
+		assert.Contains(t, fullReport.Items, Diagnostic{
+			Severity: SeverityWarning,
+			Source:   "printf",
+			Message:  `printf format %d has arg "hello" of wrong type untyped string`,
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 23},
+			},
+		})
+		assert.Contains(t, fullReport.Items, Diagnostic{
+			Severity: SeverityHint,
+			Source:   "unreachable",
+			Message:  "unreachable code",
+			Tags:     []DiagnosticTag{Unnecessary},
+			Range: Range{
+				Start: Position{Line: 4, Character: 1},
+				End:   Position{Line: 4, Character: 19},
+			},
+		})
+	})
+
+	t.Run("NilnessDiagnostics", func(t *testing.T) {
+		fileMap := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	var scores map[string]int
+	scores["alice"] = 1
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(fileMap), nil, fileMapGetter(fileMap))
+		params := &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		assert.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		assert.Equal(t, []Diagnostic{{
+			Severity: SeverityWarning,
+			Source:   "nilness",
+			Message:  "nil dereference in map update, as scores is never assigned",
+			Range: Range{
+				Start: Position{Line: 3, Character: 1},
+				End:   Position{Line: 3, Character: 16},
+			},
+		}}, fullReport.Items)
+	})
+
+	t.Run("AnalyzerDiagnosticsSuppressed", func(t *testing.T) {
+		fileMap := map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	//xgo:nolint printf
+	printf "%d\n", "hello"
+	return
+	echo "unreachable" //xgo:nolint unreachable
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(fileMap), nil, fileMapGetter(fileMap))
+		params := &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		assert.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		assert.Empty(t, fullReport.Items)
+	})
+
 	t.Run("NonSpxFile", func(t *testing.T) {
 		fileMap := newTestFileMap()
 		fileMap["main.gop"] = []byte(`echo "Hello, Go+!"`)
@@ -113,7 +217,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -131,7 +235,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -155,7 +259,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -164,13 +268,133 @@ var (
 		assert.Equal(t, string(DiagnosticFull), fullReport.Kind)
 		assert.Empty(t, fullReport.Items)
 	})
+
+	t.Run("UnchangedResult", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+echo undefinedVar
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		params := &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		require.NotEmpty(t, fullReport.ResultID)
+		require.Len(t, fullReport.Items, 1)
+
+		params.PreviousResultID = fullReport.ResultID
+		report, err = s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		unchangedReport, ok := report.Value.(RelatedUnchangedDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedUnchangedDocumentDiagnosticReport")
+		assert.Equal(t, string(DiagnosticUnchanged), unchangedReport.Kind)
+		assert.Equal(t, fullReport.ResultID, unchangedReport.ResultID)
+
+		m["main.spx"] = []byte(`
+echo "defined"
+run "assets", {Title: "My Game"}
+`)
+		s.diagnosticScheduler.delay = time.Hour
+		s.InvalidateFiles("main.spx")
+		report, err = s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		fullReport, ok = report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		assert.NotEqual(t, params.PreviousResultID, fullReport.ResultID)
+		assert.Empty(t, fullReport.Items)
+	})
+
+	t.Run("UnchangedSnapshot", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+echo undefinedVar
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+
+		diags1, err := s.pullDiagnostics(context.Background())
+		require.NoError(t, err)
+		require.Len(t, diags1.diagnostics["file:///main.spx"], 1)
+
+		// Nothing is compiled again until the snapshot changes.
+		diags2, err := s.pullDiagnostics(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, diags1, diags2)
+
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: diags1.resultIDs["file:///main.spx"],
+		})
+		require.NoError(t, err)
+		assert.IsType(t, RelatedUnchangedDocumentDiagnosticReport{}, report.Value)
+		assert.Same(t, diags1, s.lastDiagnostics.Load())
+
+		// Settings that change the diagnostics invalidate them.
+		require.NoError(t, s.applySettings(&Settings{}))
+		diags3, err := s.pullDiagnostics(context.Background())
+		require.NoError(t, err)
+		assert.NotSame(t, diags1, diags3)
+		assert.Equal(t, diags1.resultIDs, diags3.resultIDs)
+
+		m["main.spx"] = []byte(`
+echo "defined"
+run "assets", {Title: "My Game"}
+`)
+		s.InvalidateFiles("main.spx")
+		diags4, err := s.pullDiagnostics(context.Background())
+		require.NoError(t, err)
+		assert.NotSame(t, diags3, diags4)
+		assert.Empty(t, diags4.diagnostics["file:///main.spx"])
+	})
 }
 
 func TestServerWorkspaceDiagnostic(t *testing.T) {
+	t.Run("UnchangedResult", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil, fileMapGetter(newTestFileMap()))
+
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		require.Len(t, report.Items, 3)
+		var previousResultIDs []PreviousResultID
+		for _, item := range report.Items {
+			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
+			require.NotEmpty(t, fullReport.ResultID)
+			if fullReport.URI != "file:///main.spx" {
+				previousResultIDs = append(previousResultIDs, PreviousResultID{URI: fullReport.URI, Value: fullReport.ResultID})
+			}
+		}
+
+		report, err = s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{PreviousResultIds: previousResultIDs})
+		require.NoError(t, err)
+		require.Len(t, report.Items, 3)
+		for _, item := range report.Items {
+			switch value := item.Value.(type) {
+			case WorkspaceFullDocumentDiagnosticReport:
+				assert.Equal(t, DocumentURI("file:///main.spx"), value.URI)
+			case WorkspaceUnchangedDocumentDiagnosticReport:
+				assert.NotEqual(t, DocumentURI("file:///main.spx"), value.URI)
+				assert.Equal(t, string(DiagnosticUnchanged), value.Kind)
+			default:
+				t.Fatalf("unexpected report type %T", value)
+			}
+		}
+	})
+
 	t.Run("Normal", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(newTestFileMap()), nil, fileMapGetter(newTestFileMap()))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 3)
@@ -200,14 +424,23 @@ var (
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
 		for _, item := range report.Items {
 			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
 			if fullReport.URI == "file:///main.spx" {
-				require.Len(t, fullReport.Items, 2)
+				require.Len(t, fullReport.Items, 3)
+				assert.Contains(t, fullReport.Items, Diagnostic{
+					Severity:           SeverityError,
+					Message:            `sprite "MyAircraft" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MyAircraft"}),
+					Range: Range{
+						Start: Position{Line: 3, Character: 1},
+						End:   Position{Line: 3, Character: 11},
+					},
+				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
 					Message:  "expected ')', found 'EOF'",
@@ -233,7 +466,7 @@ var (
 	t.Run("EmptyWorkspace", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil, fileMapGetter(map[string][]byte{}))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.EqualError(t, err, "no valid main.spx file found in main package")
 		require.Nil(t, report)
 	})
@@ -267,7 +500,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -275,6 +508,18 @@ onStart => {
 			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
 			assert.Equal(t, string(DiagnosticFull), fullReport.Kind)
 			switch fullReport.URI {
+			case "file:///main.spx":
+				assert.Equal(t, []Diagnostic{
+					{
+						Severity:           SeverityError,
+						Message:            `sound "Sound1" does not exist`,
+						RelatedInformation: spxResourceRelatedInformation(SpxSoundResourceID{SoundName: "Sound1"}),
+						Range: Range{
+							Start: Position{Line: 2, Character: 1},
+							End:   Position{Line: 2, Character: 7},
+						},
+					},
+				}, fullReport.Items)
 			case "file:///MySprite.spx":
 				require.Len(t, fullReport.Items, 3)
 				assert.Contains(t, fullReport.Items, Diagnostic{
@@ -286,16 +531,18 @@ onStart => {
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sound resource "ConstSoundName" not found`,
+					Severity:           SeverityError,
+					Message:            `sound "ConstSoundName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSoundResourceID{SoundName: "ConstSoundName"}),
 					Range: Range{
 						Start: Position{Line: 9, Character: 6},
 						End:   Position{Line: 9, Character: 20},
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sound resource "LiteralSoundName" not found`,
+					Severity:           SeverityError,
+					Message:            `sound "LiteralSoundName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSoundResourceID{SoundName: "LiteralSoundName"}),
 					Range: Range{
 						Start: Position{Line: 10, Character: 6},
 						End:   Position{Line: 10, Character: 24},
@@ -328,7 +575,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -347,8 +594,9 @@ onStart => {
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `backdrop resource "NonExistentBackdrop" not found`,
+					Severity:           SeverityError,
+					Message:            `backdrop "NonExistentBackdrop" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxBackdropResourceID{BackdropName: "NonExistentBackdrop"}),
 					Range: Range{
 						Start: Position{Line: 2, Character: 11},
 						End:   Position{Line: 2, Character: 32},
@@ -357,16 +605,18 @@ onStart => {
 			case "file:///MySprite.spx":
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `backdrop resource "ConstBackdropName" not found`,
+					Severity:           SeverityError,
+					Message:            `backdrop "ConstBackdropName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxBackdropResourceID{BackdropName: "ConstBackdropName"}),
 					Range: Range{
 						Start: Position{Line: 5, Character: 12},
 						End:   Position{Line: 5, Character: 29},
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `backdrop resource "LiteralBackdropName" not found`,
+					Severity:           SeverityError,
+					Message:            `backdrop "LiteralBackdropName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxBackdropResourceID{BackdropName: "LiteralBackdropName"}),
 					Range: Range{
 						Start: Position{Line: 6, Character: 12},
 						End:   Position{Line: 6, Character: 33},
@@ -405,7 +655,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 3)
@@ -413,18 +663,40 @@ onStart => {
 			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
 			assert.Equal(t, string(DiagnosticFull), fullReport.Kind)
 			switch fullReport.URI {
+			case "file:///main.spx":
+				require.Len(t, fullReport.Items, 2)
+				assert.Contains(t, fullReport.Items, Diagnostic{
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite1" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite1"}),
+					Range: Range{
+						Start: Position{Line: 2, Character: 1},
+						End:   Position{Line: 2, Character: 10},
+					},
+				})
+				assert.Contains(t, fullReport.Items, Diagnostic{
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite2" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite2"}),
+					Range: Range{
+						Start: Position{Line: 3, Character: 1},
+						End:   Position{Line: 3, Character: 10},
+					},
+				})
 			case "file:///MySprite1.spx":
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sprite resource "MySprite1" not found`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite1" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite1"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 1},
 						End:   Position{Line: 3, Character: 18},
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sprite resource "MySprite2" not found`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite2" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 4, Character: 1},
 						End:   Position{Line: 4, Character: 10},
@@ -432,16 +704,18 @@ onStart => {
 				})
 			case "file:///MySprite2.spx":
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sprite resource "MySprite2" not found`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite2" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 1},
 						End:   Position{Line: 3, Character: 18},
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `sprite resource "MySprite2" not found`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite2" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteResourceID{SpriteName: "MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 4, Character: 1},
 						End:   Position{Line: 4, Character: 10},
@@ -469,7 +743,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -488,8 +762,9 @@ onStart => {
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `costume resource "NonExistentCostume" not found in sprite "MySprite"`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite" has no costume "NonExistentCostume"`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteCostumeResourceID{SpriteName: "MySprite", CostumeName: "NonExistentCostume"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 12},
 						End:   Position{Line: 3, Character: 32},
@@ -517,7 +792,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -536,8 +811,9 @@ onStart => {
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `animation resource "roll-in" not found in sprite "MySprite"`,
+					Severity:           SeverityError,
+					Message:            `sprite "MySprite" has no animation "roll-in"`,
+					RelatedInformation: spxResourceRelatedInformation(SpxSpriteAnimationResourceID{SpriteName: "MySprite", AnimationName: "roll-in"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 9},
 						End:   Position{Line: 3, Character: 18},
@@ -569,7 +845,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -588,16 +864,18 @@ onStart => {
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `widget resource "ConstWidgetName" not found`,
+					Severity:           SeverityError,
+					Message:            `widget "ConstWidgetName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxWidgetResourceID{WidgetName: "ConstWidgetName"}),
 					Range: Range{
 						Start: Position{Line: 6, Character: 20},
 						End:   Position{Line: 6, Character: 35},
 					},
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
-					Severity: SeverityError,
-					Message:  `widget resource "LiteralWidgetName" not found`,
+					Severity:           SeverityError,
+					Message:            `widget "LiteralWidgetName" does not exist`,
+					RelatedInformation: spxResourceRelatedInformation(SpxWidgetResourceID{WidgetName: "LiteralWidgetName"}),
 					Range: Range{
 						Start: Position{Line: 7, Character: 20},
 						End:   Position{Line: 7, Character: 39},
@@ -609,6 +887,81 @@ onStart => {
 		}
 	})
 
+	t.Run("MsgMismatch", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+const Hello = "hello"
+onStart => {
+	broadcast Hello
+	broadcast "unhandled"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onMsg "hello", => {}
+onMsg "unsent", => {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		assert.Len(t, report.Items, 2)
+		for _, item := range report.Items {
+			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
+			switch fullReport.URI {
+			case "file:///main.spx":
+				assert.Equal(t, []Diagnostic{
+					{
+						Severity: SeverityWarning,
+						Message:  `message "unhandled" is broadcast but never handled by onMsg`,
+						Range: Range{
+							Start: Position{Line: 4, Character: 11},
+							End:   Position{Line: 4, Character: 22},
+						},
+					},
+				}, fullReport.Items)
+			case "file:///MySprite.spx":
+				assert.Equal(t, []Diagnostic{
+					{
+						Severity: SeverityWarning,
+						Message:  `message "unsent" is handled by onMsg but never broadcast`,
+						Range: Range{
+							Start: Position{Line: 2, Character: 6},
+							End:   Position{Line: 2, Character: 14},
+						},
+					},
+				}, fullReport.Items)
+			}
+		}
+	})
+
+	t.Run("MsgMismatchWithCatchAllHandler", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var msg string
+onStart => {
+	broadcast "hello"
+	broadcast msg
+}
+onMsg (msg, data) => {}
+onMsg "unsent", => {}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		require.Len(t, report.Items, 1)
+		assert.Empty(t, report.Items[0].Value.(WorkspaceFullDocumentDiagnosticReport).Items)
+	})
+
 	t.Run("WithNonBasicTypeAliases", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -626,7 +979,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -650,7 +1003,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 1)
@@ -677,7 +1030,7 @@ run "assets", {Title: "My Game"}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -1,14 +1,15 @@
 package server
 
 import (
+	"context"
 	"slices"
 
 	gopast "github.com/goplus/gop/ast"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_documentLink
-func (s *Server) textDocumentDocumentLink(params *DocumentLinkParams) (links []DocumentLink, err error) {
-	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentDocumentLink(ctx context.Context, params *DocumentLinkParams) (links []DocumentLink, err error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -25,12 +26,16 @@ func (s *Server) textDocumentDocumentLink(params *DocumentLinkParams) (links []D
 		}
 	}()
 
-	// Add links for spx resource references.
+	// Add links for spx resource references. References to missing resources
+	// are left unlinked as there is nothing to open.
 	links = slices.Grow(links, len(result.spxResourceRefs))
 	for _, spxResourceRef := range result.spxResourceRefs {
 		if result.nodeFilename(spxResourceRef.Node) != spxFile {
 			continue
 		}
+		if !result.spxResourceSet.Contains(spxResourceRef.ID) {
+			continue
+		}
 		target := URI(spxResourceRef.ID.URI())
 		links = append(links, DocumentLink{
 			Range:  result.rangeForNode(spxResourceRef.Node),
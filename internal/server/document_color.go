@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go/constant"
+	"math"
+	"strconv"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// spxColor represents a color in spx source code, which is either a call to
+// spx.RGB or spx.RGBA with constant arguments, or a hex color string literal
+// like "#ff0000" or "#f00".
+type spxColor struct {
+	node gopast.Expr
+
+	// funcName is the name of the called function as written, e.g., "RGB".
+	// It is empty for hex color string literals.
+	funcName string
+
+	// hasAlpha reports whether the called function takes an alpha component.
+	hasAlpha bool
+
+	color Color
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_documentColor
+func (s *Server) textDocumentDocumentColor(ctx context.Context, params *DocumentColorParams) ([]ColorInformation, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	var colorInfos []ColorInformation
+	for _, c := range result.spxColors(astFile) {
+		colorInfos = append(colorInfos, ColorInformation{
+			Range: result.rangeForASTFileNode(astFile, c.node),
+			Color: c.color,
+		})
+	}
+	return colorInfos, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_colorPresentation
+func (s *Server) textDocumentColorPresentation(ctx context.Context, params *ColorPresentationParams) ([]ColorPresentation, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	for _, c := range result.spxColors(astFile) {
+		if result.rangeForASTFileNode(astFile, c.node) != params.Range {
+			continue
+		}
+		r, g, b, a := colorComponent(params.Color.Red), colorComponent(params.Color.Green), colorComponent(params.Color.Blue), colorComponent(params.Color.Alpha)
+
+		var labels []string
+		switch {
+		case c.funcName == "":
+			// Hex color strings have no alpha.
+			labels = append(labels, strconv.Quote(fmt.Sprintf("#%02x%02x%02x", r, g, b)))
+		case c.hasAlpha:
+			labels = append(labels, fmt.Sprintf("%s(%d, %d, %d, %d)", c.funcName, r, g, b, a))
+		default:
+			if a != 0xff && c.funcName == "RGB" {
+				labels = append(labels, fmt.Sprintf("RGBA(%d, %d, %d, %d)", r, g, b, a))
+			}
+			labels = append(labels, fmt.Sprintf("%s(%d, %d, %d)", c.funcName, r, g, b))
+		}
+
+		presentations := make([]ColorPresentation, 0, len(labels))
+		for _, label := range labels {
+			presentations = append(presentations, ColorPresentation{
+				Label:    label,
+				TextEdit: &TextEdit{Range: params.Range, NewText: label},
+			})
+		}
+		return presentations, nil
+	}
+	return nil, nil
+}
+
+// spxColors returns the colors in the given AST file.
+func (r *compileResult) spxColors(astFile *gopast.File) []spxColor {
+	typeInfo := getTypeInfo(r.proj)
+
+	var colors []spxColor
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.CallExpr:
+			funcIdent := funcIdentOfCallExpr(node)
+			if funcIdent == nil {
+				return true
+			}
+			funcObj := typeInfo.ObjectOf(funcIdent)
+			if !isSpxPkgObject(funcObj) {
+				return true
+			}
+			var wantArgs int
+			switch funcObj.Name() {
+			case "RGB":
+				wantArgs = 3
+			case "RGBA":
+				wantArgs = 4
+			default:
+				return true
+			}
+			if len(node.Args) != wantArgs {
+				return true
+			}
+			components := []uint8{0, 0, 0, 0xff}
+			for i, arg := range node.Args {
+				tv := typeInfo.Types[arg]
+				if tv.Value == nil {
+					return true
+				}
+				v, ok := constant.Int64Val(constant.ToInt(tv.Value))
+				if !ok || v < 0 || v > 0xff {
+					return true
+				}
+				components[i] = uint8(v)
+			}
+			colors = append(colors, spxColor{
+				node:     node,
+				funcName: funcIdent.Name,
+				hasAlpha: wantArgs == 4,
+				color:    newColor(components[0], components[1], components[2], components[3]),
+			})
+			return false
+		case *gopast.BasicLit:
+			if node.Kind != goptoken.STRING {
+				return true
+			}
+			s, err := strconv.Unquote(node.Value)
+			if err != nil {
+				return true
+			}
+			if rgb, ok := parseHexColor(s); ok {
+				colors = append(colors, spxColor{
+					node:  node,
+					color: newColor(rgb[0], rgb[1], rgb[2], 0xff),
+				})
+			}
+		}
+		return true
+	})
+	return colors
+}
+
+// parseHexColor parses a hex color string of the form "#rrggbb" or "#rgb",
+// which are the ones spx accepts.
+func parseHexColor(s string) (rgb [3]uint8, ok bool) {
+	if len(s) != 7 && len(s) != 4 || s[0] != '#' {
+		return rgb, false
+	}
+	digits := s[1:]
+	for i := range rgb {
+		var part string
+		if len(digits) == 6 {
+			part = digits[2*i : 2*i+2]
+		} else {
+			part = digits[i : i+1]
+			part += part
+		}
+		v, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return rgb, false
+		}
+		rgb[i] = uint8(v)
+	}
+	return rgb, true
+}
+
+// newColor returns the [Color] of the given 8-bit components.
+func newColor(r, g, b, a uint8) Color {
+	return Color{
+		Red:   float64(r) / 0xff,
+		Green: float64(g) / 0xff,
+		Blue:  float64(b) / 0xff,
+		Alpha: float64(a) / 0xff,
+	}
+}
+
+// colorComponent returns the 8-bit value of the given [Color] component in the
+// range [0, 1].
+func colorComponent(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 1) * 0xff))
+}
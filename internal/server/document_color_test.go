@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentDocumentColor(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`const Half = 128
+
+var (
+	bg Color
+)
+
+onStart => {
+	bg = RGB(255, 0, Half)
+	bg = RGBA(0, 0, 255, 128)
+	name := "#00ff00"
+	short := "#f00"
+	echo name, short, "#nothex", "#12345"
+	n := 300
+	bg = RGB(n, 0, 0)
+}
+`),
+		"MySprite.spx": []byte(`onClick => {
+	setPenColor RGB(1, 2, 3)
+}
+`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	t.Run("Normal", func(t *testing.T) {
+		colorInfos, err := s.textDocumentDocumentColor(context.Background(), &DocumentColorParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []ColorInformation{
+			{
+				Range: Range{Start: Position{Line: 7, Character: 6}, End: Position{Line: 7, Character: 23}},
+				Color: Color{Red: 1, Green: 0, Blue: 128.0 / 255, Alpha: 1},
+			},
+			{
+				Range: Range{Start: Position{Line: 8, Character: 6}, End: Position{Line: 8, Character: 26}},
+				Color: Color{Red: 0, Green: 0, Blue: 1, Alpha: 128.0 / 255},
+			},
+			{
+				Range: Range{Start: Position{Line: 9, Character: 9}, End: Position{Line: 9, Character: 18}},
+				Color: Color{Red: 0, Green: 1, Blue: 0, Alpha: 1},
+			},
+			{
+				Range: Range{Start: Position{Line: 10, Character: 10}, End: Position{Line: 10, Character: 16}},
+				Color: Color{Red: 1, Green: 0, Blue: 0, Alpha: 1},
+			},
+		}, colorInfos)
+	})
+
+	t.Run("CommandCall", func(t *testing.T) {
+		colorInfos, err := s.textDocumentDocumentColor(context.Background(), &DocumentColorParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, colorInfos, 1)
+		assert.Equal(t, Range{Start: Position{Line: 1, Character: 13}, End: Position{Line: 1, Character: 25}}, colorInfos[0].Range)
+	})
+
+	t.Run("Presentation", func(t *testing.T) {
+		presentations := func(rng Range, color Color) []string {
+			ps, err := s.textDocumentColorPresentation(context.Background(), &ColorPresentationParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Color:        color,
+				Range:        rng,
+			})
+			require.NoError(t, err)
+			var labels []string
+			for _, p := range ps {
+				require.NotNil(t, p.TextEdit)
+				assert.Equal(t, rng, p.TextEdit.Range)
+				assert.Equal(t, p.Label, p.TextEdit.NewText)
+				labels = append(labels, p.Label)
+			}
+			return labels
+		}
+
+		rgbRange := Range{Start: Position{Line: 7, Character: 6}, End: Position{Line: 7, Character: 23}}
+		assert.Equal(t, []string{"RGB(10, 20, 30)"}, presentations(rgbRange, newColor(10, 20, 30, 255)))
+		assert.Equal(t, []string{"RGBA(10, 20, 30, 40)", "RGB(10, 20, 30)"}, presentations(rgbRange, newColor(10, 20, 30, 40)))
+
+		rgbaRange := Range{Start: Position{Line: 8, Character: 6}, End: Position{Line: 8, Character: 26}}
+		assert.Equal(t, []string{"RGBA(10, 20, 30, 255)"}, presentations(rgbaRange, newColor(10, 20, 30, 255)))
+
+		hexRange := Range{Start: Position{Line: 9, Character: 9}, End: Position{Line: 9, Character: 18}}
+		assert.Equal(t, []string{`"#0a141e"`}, presentations(hexRange, newColor(10, 20, 30, 40)))
+
+		assert.Empty(t, presentations(Range{}, newColor(10, 20, 30, 255)))
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,9 +40,9 @@ onStart => {
 		paramsForMainSpx := &DocumentLinkParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
-		linksForMainSpx, err := s.textDocumentDocumentLink(paramsForMainSpx)
+		linksForMainSpx, err := s.textDocumentDocumentLink(context.Background(), paramsForMainSpx)
 		require.NoError(t, err)
-		require.Len(t, linksForMainSpx, 14)
+		require.Len(t, linksForMainSpx, 15)
 		assert.Contains(t, linksForMainSpx, DocumentLink{
 			Range: Range{
 				Start: Position{Line: 1, Character: 6},
@@ -138,6 +139,16 @@ onStart => {
 			},
 			Target: toURI("gop:github.com/goplus/spx?Sprite"),
 		})
+		assert.Contains(t, linksForMainSpx, DocumentLink{
+			Range: Range{
+				Start: Position{Line: 7, Character: 4},
+				End:   Position{Line: 7, Character: 12},
+			},
+			Target: toURI("spx://resources/stage"),
+			Data: SpxResourceRefDocumentLinkData{
+				Kind: SpxResourceRefKindStringLiteral,
+			},
+		})
 		assert.Contains(t, linksForMainSpx, DocumentLink{
 			Range: Range{
 				Start: Position{Line: 7, Character: 0},
@@ -156,7 +167,7 @@ onStart => {
 		paramsForMySpriteSpx := &DocumentLinkParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 		}
-		linksForMySpriteSpx, err := s.textDocumentDocumentLink(paramsForMySpriteSpx)
+		linksForMySpriteSpx, err := s.textDocumentDocumentLink(context.Background(), paramsForMySpriteSpx)
 		require.NoError(t, err)
 		require.Len(t, linksForMySpriteSpx, 21)
 		assert.Contains(t, linksForMySpriteSpx, DocumentLink{
@@ -258,6 +269,32 @@ onStart => {
 		})
 	})
 
+	t.Run("MissingResources", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+play "MySound"
+play "NoSound"
+startBackdrop "NoBackdrop"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                []byte(`{}`),
+			"assets/sounds/MySound/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		links, err := s.textDocumentDocumentLink(context.Background(), &DocumentLinkParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		var resourceTargets []URI
+		for _, link := range links {
+			if link.Data != nil {
+				resourceTargets = append(resourceTargets, *link.Target)
+			}
+		}
+		assert.Equal(t, []URI{"spx://resources/stage", "spx://resources/sounds/MySound"}, resourceTargets)
+	})
+
 	t.Run("NonSpxFile", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.gop": []byte(`echo "Hello, Go+!"`),
@@ -267,7 +304,7 @@ onStart => {
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
 		}
 
-		links, err := s.textDocumentDocumentLink(params)
+		links, err := s.textDocumentDocumentLink(context.Background(), params)
 		assert.EqualError(t, err, `file "main.gop" does not have .spx extension`)
 		assert.Nil(t, links)
 	})
@@ -278,7 +315,7 @@ onStart => {
 			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
 		}
 
-		links, err := s.textDocumentDocumentLink(params)
+		links, err := s.textDocumentDocumentLink(context.Background(), params)
 		assert.ErrorIs(t, err, errNoMainSpxFile)
 		assert.Nil(t, links)
 	})
@@ -298,7 +335,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		links, err := s.textDocumentDocumentLink(params)
+		links, err := s.textDocumentDocumentLink(context.Background(), params)
 		require.NoError(t, err)
 		require.Len(t, links, 3)
 		assert.Contains(t, links, DocumentLink{
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/scanner"
+	gotoken "go/token"
+	"go/types"
+	"unicode"
+	"unicode/utf8"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// XGoEvaluateParams represents parameters to evaluate an expression in the
+// scope at a position of a document.
+type XGoEvaluateParams struct {
+	TextDocumentPositionParams
+
+	// The expression, in Go syntax.
+	Expression string `json:"expression"`
+}
+
+// XGoEvaluateResult represents the result of evaluating an expression.
+type XGoEvaluateResult struct {
+	// The type of the expression.
+	Type string `json:"type"`
+
+	// The value of the expression if it is constant.
+	Value string `json:"value,omitempty"`
+}
+
+// xgoEvaluate handles the xgo/evaluate request, which type-checks an
+// expression in the scope at the given position and returns its type and the
+// value if it is constant, e.g., for clients to display watch expressions.
+// Identifiers of fields and methods of the class at the position may be used
+// without "this.", like in Go+ code.
+func (s *Server) xgoEvaluate(ctx context.Context, params *XGoEvaluateParams) (*XGoEvaluateResult, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, fmt.Errorf("failed to get file: %s", spxFile)
+	}
+
+	fset := gotoken.NewFileSet()
+	expr, err := goparser.ParseExprFrom(fset, "", params.Expression, goparser.SkipObjectResolution)
+	if err != nil {
+		var errorList scanner.ErrorList
+		if errors.As(err, &errorList) && len(errorList) > 0 {
+			return nil, errors.New(errorList[0].Msg)
+		}
+		return nil, err
+	}
+
+	pos := result.posAt(astFile, params.Position)
+	scope := result.evaluationScope(astFile, pos)
+	expr = qualifyClassMembers(scope, expr)
+
+	info := &types.Info{Types: make(map[goast.Expr]types.TypeAndValue)}
+	if err := types.CheckExpr(fset, scope.pkg, gotoken.NoPos, expr, info); err != nil {
+		var typeErr types.Error
+		if errors.As(err, &typeErr) {
+			return nil, errors.New(typeErr.Msg)
+		}
+		return nil, err
+	}
+	tv := info.Types[expr]
+	if tv.IsType() {
+		return nil, fmt.Errorf("%s is a type, not an expression", params.Expression)
+	}
+	if tv.Type == nil {
+		return nil, fmt.Errorf("%s is not an expression", params.Expression)
+	}
+	evalResult := &XGoEvaluateResult{Type: getSimplifiedTypeString(tv.Type)}
+	if tv.Value != nil {
+		evalResult.Value = tv.Value.String()
+	}
+	return evalResult, nil
+}
+
+// evaluationScope represents the scope to evaluate expressions in.
+type evaluationScope struct {
+	// pkg is a package with the path of the package being compiled, whose
+	// scope has the objects visible in the scope, so that expressions
+	// checked in it may refer to unexported ones too.
+	pkg *types.Package
+
+	// this is the receiver of the class method the scope is in, if any.
+	this *types.Var
+}
+
+// evaluationScope returns the scope to evaluate expressions at the given
+// position of the given AST file in.
+func (r *compileResult) evaluationScope(astFile *gopast.File, pos goptoken.Pos) *evaluationScope {
+	pkg := getPkg(r.proj)
+	evalPkg := types.NewPackage(pkg.Path(), pkg.Name())
+	evalScope := &evaluationScope{pkg: evalPkg}
+
+	// Inner objects shadow outer ones, as the latter are not inserted if
+	// their names are in use.
+	for scope := r.innermostScopeAt(pos); scope != nil && scope != pkg.Scope() && scope != types.Universe; scope = scope.Parent() {
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if obj.Pos() > pos {
+				continue // Declared later.
+			}
+			evalPkg.Scope().Insert(obj)
+			if v, ok := obj.(*types.Var); ok && name == "this" && evalScope.this == nil {
+				evalScope.this = v
+			}
+		}
+	}
+	typeInfo := getTypeInfo(r.proj)
+	for _, importSpec := range astFile.Imports {
+		var pkgName *types.PkgName
+		if importSpec.Name != nil {
+			pkgName, _ = typeInfo.Defs[importSpec.Name].(*types.PkgName)
+		} else {
+			pkgName, _ = typeInfo.Implicits[importSpec].(*types.PkgName)
+		}
+		if pkgName != nil && pkgName.Name() != "_" && pkgName.Name() != "." {
+			// Package names must belong to the package being checked.
+			evalPkg.Scope().Insert(types.NewPkgName(pkgName.Pos(), evalPkg, pkgName.Name(), pkgName.Imported()))
+		}
+	}
+	for _, name := range pkg.Scope().Names() {
+		evalPkg.Scope().Insert(pkg.Scope().Lookup(name))
+	}
+	return evalScope
+}
+
+// qualifyClassMembers returns the given expression with identifiers of fields
+// and methods of the class, which are not otherwise declared in the scope,
+// replaced by selectors of "this". The exported Go methods may be referred to
+// by lowercase names, e.g., "step" for "Step".
+func qualifyClassMembers(scope *evaluationScope, expr goast.Expr) goast.Expr {
+	if scope.this == nil {
+		return expr
+	}
+	return astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		switch c.Parent().(type) {
+		case *goast.SelectorExpr:
+			if c.Name() == "Sel" {
+				return false
+			}
+		case *goast.KeyValueExpr:
+			if c.Name() == "Key" {
+				return false // Possibly a field name of a composite literal.
+			}
+		}
+		ident, ok := c.Node().(*goast.Ident)
+		if !ok || ident.Name == "_" {
+			return true
+		}
+		if _, obj := scope.pkg.Scope().LookupParent(ident.Name, gotoken.NoPos); obj != nil {
+			return true
+		}
+		for _, name := range []string{ident.Name, capitalize(ident.Name)} {
+			if obj, _, _ := types.LookupFieldOrMethod(scope.this.Type(), true, scope.pkg, name); obj != nil {
+				c.Replace(&goast.SelectorExpr{
+					X:   goast.NewIdent("this"),
+					Sel: goast.NewIdent(name),
+				})
+				break
+			}
+		}
+		return true
+	}, nil).(goast.Expr)
+}
+
+// capitalize returns s with its first letter in upper case.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerEvaluate(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`import "math"
+
+var (
+	score int
+)
+
+const Max = 10
+
+func add(n int) int {
+	return n + score
+}
+
+onStart => {
+	x := 3
+	echo x, score
+	y := "later"
+	echo y
+}
+`),
+		"MySprite.spx": []byte(`onClick => {
+	say "hi"
+}
+`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	evaluate := func(uri DocumentURI, position Position, expression string) (*XGoEvaluateResult, error) {
+		return s.xgoEvaluate(context.Background(), &XGoEvaluateParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Position:     position,
+			},
+			Expression: expression,
+		})
+	}
+
+	for _, tt := range []struct {
+		name       string
+		uri        DocumentURI
+		position   Position
+		expression string
+		want       *XGoEvaluateResult
+	}{
+		{"Constant", "file:///main.spx", Position{Line: 14, Character: 1}, "Max * 2", &XGoEvaluateResult{Type: "untyped int", Value: "20"}},
+		{"Builtin", "file:///main.spx", Position{Line: 14, Character: 1}, `len("abc")`, &XGoEvaluateResult{Type: "int", Value: "3"}},
+		{"Import", "file:///main.spx", Position{Line: 14, Character: 1}, "math.Pi > 3", &XGoEvaluateResult{Type: "untyped bool", Value: "true"}},
+		{"LocalVariable", "file:///main.spx", Position{Line: 14, Character: 1}, "x + 1", &XGoEvaluateResult{Type: "int"}},
+		{"Parameter", "file:///main.spx", Position{Line: 9, Character: 1}, "n", &XGoEvaluateResult{Type: "int"}},
+		{"Field", "file:///main.spx", Position{Line: 14, Character: 1}, "score", &XGoEvaluateResult{Type: "int"}},
+		{"Method", "file:///main.spx", Position{Line: 14, Character: 1}, "add(x)", &XGoEvaluateResult{Type: "int"}},
+		{"GoMethod", "file:///MySprite.spx", Position{Line: 1, Character: 1}, "heading()", &XGoEvaluateResult{Type: "float64"}},
+		{"GameField", "file:///MySprite.spx", Position{Line: 1, Character: 1}, "score * 2", &XGoEvaluateResult{Type: "int"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluate(tt.uri, tt.position, tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := evaluate("file:///main.spx", Position{Line: 14, Character: 1}, "y")
+		assert.EqualError(t, err, "undefined: y")
+
+		_, err = evaluate("file:///main.spx", Position{Line: 14, Character: 1}, "x +")
+		assert.EqualError(t, err, "expected operand, found 'EOF'")
+
+		_, err = evaluate("file:///main.spx", Position{Line: 14, Character: 1}, "int")
+		assert.EqualError(t, err, "int is a type, not an expression")
+	})
+}
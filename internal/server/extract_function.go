@@ -0,0 +1,437 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// refactorExtractFunction is the kind of code actions that extract the
+// selected statements into a new function.
+const refactorExtractFunction = RefactorExtract + ".function"
+
+// spxExtractFunctionEdits returns the edits that extract the statements in the
+// given range of the spx source file of the given document URI into a new
+// function, replacing them with a call of it. It returns nil if they can not
+// be extracted.
+func (s *Server) spxExtractFunctionEdits(ctx context.Context, uri DocumentURI, rng Range) ([]TextEdit, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	extracted := result.extractFunction(astFile, result.posAt(astFile, rng.Start), result.posAt(astFile, rng.End))
+	if extracted == nil {
+		return nil, nil
+	}
+	return computeTextEdits(result.posEncoding, astFile.Code, extracted), nil
+}
+
+// extractFunction returns the content of the given AST file with the
+// statements between start and end extracted into a new function. It returns
+// nil if the range does not cover whole statements of the same block, or if
+// they can not be moved into a function of their own, e.g., when they return
+// from or branch out of the enclosing function.
+//
+// Local variables declared outside the statements are passed as parameters,
+// and those declared or mutated in them and used after them are returned.
+// Variables are mutated by assignments to them, their fields or elements, and
+// by calls of methods with pointer receivers on them.
+// In spx source files, the new function is a method of the class, so fields
+// and methods of the class are used as is.
+func (r *compileResult) extractFunction(astFile *gopast.File, start, end goptoken.Pos) []byte {
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	code := astFile.Code
+	start, end, ok := r.trimSelection(astFile, start, end)
+	if !ok {
+		return nil
+	}
+
+	stmts := selectedStmts(astFile, start, end)
+	if len(stmts) == 0 {
+		return nil
+	}
+	var enclosingDecl gopast.Decl
+	for _, decl := range astFile.Decls {
+		if decl.Pos() <= start && end <= decl.End() || isShadowEntryStartingBefore(decl, start) {
+			enclosingDecl = decl
+		}
+	}
+	if enclosingDecl == nil {
+		return nil
+	}
+
+	typeInfo := getTypeInfo(r.proj)
+	pkg := getPkg(r.proj)
+	isInSelection := func(pos goptoken.Pos) bool { return start <= pos && pos < end }
+	isLocal := func(obj types.Object) bool {
+		return obj.Pkg() == pkg && obj.Parent() != nil && obj.Parent() != pkg.Scope() && obj.Parent() != types.Universe
+	}
+
+	var (
+		params       []*types.Var
+		mutated      = make(map[*types.Var]struct{})
+		defined      []*types.Var
+		definedOther = make(map[types.Object]struct{}) // local constants and types
+		stack        []gopast.Node
+		extractable  = true
+	)
+	for _, stmt := range stmts {
+		gopast.Inspect(stmt, func(node gopast.Node) bool {
+			if node == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			stack = append(stack, node)
+
+			switch node := node.(type) {
+			case *gopast.ReturnStmt, *gopast.DeferStmt:
+				// Both would act on the new function instead of the
+				// enclosing one.
+				if !hasEnclosingNode(stack, isFuncNode) {
+					extractable = false
+				}
+			case *gopast.BranchStmt:
+				if node.Label != nil {
+					label := typeInfo.Uses[node.Label]
+					if label == nil || !isInSelection(label.Pos()) {
+						extractable = false
+					}
+				} else if !hasEnclosingNode(stack, branchTargetFor(node.Tok)) {
+					extractable = false
+				}
+			case *gopast.Ident:
+				if obj := typeInfo.Defs[node]; obj != nil && isLocal(obj) {
+					if v, ok := obj.(*types.Var); ok && !v.IsField() {
+						defined = append(defined, v)
+					} else {
+						definedOther[obj] = struct{}{}
+					}
+				}
+				obj := typeInfo.Uses[node]
+				if obj == nil || !isLocal(obj) || isInSelection(obj.Pos()) {
+					break
+				}
+				switch obj := obj.(type) {
+				case *types.Var:
+					if obj.IsField() {
+						break
+					}
+					if !slices.Contains(params, obj) {
+						params = append(params, obj)
+					}
+					if r.isMutatedVarIdent(node) {
+						mutated[obj] = struct{}{}
+					}
+				case *types.Const, *types.TypeName:
+					extractable = false
+				}
+			}
+			return true
+		})
+	}
+	if !extractable {
+		return nil
+	}
+
+	// Local objects can only be used in the file declaring them.
+	usedAfter := make(map[types.Object]struct{})
+	for ident, obj := range typeInfo.Uses {
+		if ident.Pos() >= end && ident.Pos() < tokenFile.Pos(len(code)) {
+			usedAfter[obj] = struct{}{}
+		}
+	}
+	for obj := range definedOther {
+		if _, ok := usedAfter[obj]; ok {
+			return nil
+		}
+	}
+	var definedResults, mutatedResults []*types.Var
+	for _, v := range defined {
+		if _, ok := usedAfter[v]; ok {
+			definedResults = append(definedResults, v)
+		}
+	}
+	for _, v := range params {
+		_, isMutated := mutated[v]
+		_, isUsedAfter := usedAfter[v]
+		if isMutated && isUsedAfter {
+			mutatedResults = append(mutatedResults, v)
+		}
+	}
+	results := slices.Concat(definedResults, mutatedResults)
+
+	name := r.newFuncName(start)
+	qualifier := func(p *types.Package) string {
+		if p == pkg || p == GetSpxPkg() {
+			return "" // Spx objects are accessible without qualifiers in spx source files.
+		}
+		return p.Name()
+	}
+	varNames := func(vars []*types.Var) string {
+		names := make([]string, 0, len(vars))
+		for _, v := range vars {
+			names = append(names, v.Name())
+		}
+		return strings.Join(names, ", ")
+	}
+
+	// Generate the new function.
+	firstOffset := tokenFile.Offset(stmts[0].Pos())
+	lastOffset := tokenFile.Offset(stmts[len(stmts)-1].End())
+	indent := lineIndent(code, firstOffset)
+	var fn strings.Builder
+	fmt.Fprintf(&fn, "func %s(", name)
+	for i, v := range params {
+		if i > 0 {
+			fn.WriteString(", ")
+		}
+		fmt.Fprintf(&fn, "%s %s", v.Name(), types.TypeString(v.Type(), qualifier))
+	}
+	fn.WriteString(")")
+	if len(results) > 0 {
+		resultTypes := make([]string, 0, len(results))
+		for _, v := range results {
+			resultTypes = append(resultTypes, types.TypeString(v.Type(), qualifier))
+		}
+		if len(results) == 1 {
+			fmt.Fprintf(&fn, " %s", resultTypes[0])
+		} else {
+			fmt.Fprintf(&fn, " (%s)", strings.Join(resultTypes, ", "))
+		}
+	}
+	fn.WriteString(" {\n")
+	for i, line := range strings.Split(string(code[firstOffset:lastOffset]), "\n") {
+		if i > 0 {
+			line = strings.TrimPrefix(line, indent)
+		}
+		if strings.TrimSpace(line) == "" {
+			fn.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&fn, "\t%s\n", strings.TrimRight(line, "\r"))
+	}
+	if len(results) > 0 {
+		fmt.Fprintf(&fn, "\treturn %s\n", varNames(results))
+	}
+	fn.WriteString("}\n\n")
+
+	// Generate the call replacing the statements.
+	var call strings.Builder
+	callExpr := fmt.Sprintf("%s(%s)", name, varNames(params))
+	switch {
+	case len(results) == 0:
+		call.WriteString(callExpr)
+	case len(mutatedResults) == 0:
+		fmt.Fprintf(&call, "%s := %s", varNames(results), callExpr)
+	default:
+		// Variables assigned in the statements are declared outside them,
+		// so the defined ones are declared separately to not shadow them.
+		for _, v := range definedResults {
+			fmt.Fprintf(&call, "var %s %s\n%s", v.Name(), types.TypeString(v.Type(), qualifier), indent)
+		}
+		fmt.Fprintf(&call, "%s = %s", varNames(results), callExpr)
+	}
+
+	// Functions are declared before the enclosing declaration, as no
+	// declaration may follow the statements of the ShadowEntry.
+	declOffset := r.declStartOffset(astFile, enclosingDecl)
+
+	var extracted []byte
+	extracted = append(extracted, code[:declOffset]...)
+	extracted = append(extracted, fn.String()...)
+	extracted = append(extracted, code[declOffset:firstOffset]...)
+	extracted = append(extracted, call.String()...)
+	extracted = append(extracted, code[lastOffset:]...)
+	return extracted
+}
+
+// isMutatedVarIdent reports whether the variable of the given identifier may
+// be changed by the expression it is in, i.e., whether it, one of its fields
+// or elements is assigned, its address is taken, or a method with a pointer
+// receiver is called on it, e.g., p.X = 1, a[0]++ or p.Move(1).
+func (r *compileResult) isMutatedVarIdent(ident *gopast.Ident) bool {
+	if isAssignedIdent(r.proj, ident) || isAddressTakenIdent(r.proj, ident) {
+		return true
+	}
+	path := goputil.NodePath(r.proj, ident)
+	if len(path) == 0 {
+		return false
+	}
+	typeInfo := getTypeInfo(r.proj)
+	var expr gopast.Expr = ident
+	for _, parent := range path[1:] {
+		switch p := parent.(type) {
+		case *gopast.ParenExpr:
+			expr = p
+			continue
+		case *gopast.IndexExpr:
+			if p.X == expr {
+				expr = p
+				continue
+			}
+		case *gopast.SelectorExpr:
+			t := typeInfo.TypeOf(expr)
+			if p.X != expr || t == nil {
+				break
+			}
+			if _, ok := t.Underlying().(*types.Pointer); ok {
+				break // Changes through pointers are not changes of the variable.
+			}
+			switch obj := typeInfo.Uses[p.Sel].(type) {
+			case *types.Var:
+				if obj.IsField() {
+					expr = p
+					continue
+				}
+			case *types.Func:
+				if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+					_, isPtrRecv := recv.Type().(*types.Pointer)
+					return isPtrRecv
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// declStartOffset returns the offset of the start of the line of the given
+// declaration in the given AST file, including its doc comment. For the
+// ShadowEntry, it is the line of its first statement.
+func (r *compileResult) declStartOffset(astFile *gopast.File, decl gopast.Decl) int {
+	declPos := decl.Pos()
+	if funcDecl, ok := decl.(*gopast.FuncDecl); ok {
+		if funcDecl.Doc != nil {
+			declPos = funcDecl.Doc.Pos()
+		} else if funcDecl.Shadow && len(funcDecl.Body.List) > 0 {
+			declPos = funcDecl.Body.List[0].Pos()
+		}
+	} else if genDecl, ok := decl.(*gopast.GenDecl); ok && genDecl.Doc != nil {
+		declPos = genDecl.Doc.Pos()
+	}
+	offset := r.proj.Fset.File(astFile.Pos()).Offset(declPos)
+	return offset - len(lineIndent(astFile.Code, offset))
+}
+
+// selectedStmts returns the statements of the same statement list in the
+// given AST file that exactly cover the range between start and end.
+func selectedStmts(astFile *gopast.File, start, end goptoken.Pos) (stmts []gopast.Stmt) {
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		var list []gopast.Stmt
+		switch node := node.(type) {
+		case *gopast.BlockStmt:
+			list = node.List
+		case *gopast.CaseClause:
+			list = node.Body
+		case *gopast.CommClause:
+			list = node.Body
+		default:
+			return true
+		}
+		i := slices.IndexFunc(list, func(stmt gopast.Stmt) bool { return stmt.Pos() == start })
+		if i < 0 {
+			return true
+		}
+		j := slices.IndexFunc(list[i:], func(stmt gopast.Stmt) bool { return stmt.End() == end })
+		if j >= 0 {
+			stmts = list[i : i+j+1]
+		}
+		return true
+	})
+	return
+}
+
+// isShadowEntryStartingBefore reports whether the given declaration is the
+// ShadowEntry of its file, i.e., the function of its top-level statements,
+// whose first statement starts at or before pos. The ShadowEntry has no
+// brackets and therefore no valid start or end position of its own. Other
+// shadow functions, e.g., Classfname of class files, have no statements with
+// valid positions.
+func isShadowEntryStartingBefore(decl gopast.Decl, pos goptoken.Pos) bool {
+	funcDecl, ok := decl.(*gopast.FuncDecl)
+	if !ok || !funcDecl.Shadow || len(funcDecl.Body.List) == 0 {
+		return false
+	}
+	start := funcDecl.Body.List[0].Pos()
+	return start.IsValid() && start <= pos
+}
+
+// isFuncNode reports whether the given node is a function with a body of its
+// own.
+func isFuncNode(node gopast.Node) bool {
+	switch node.(type) {
+	case *gopast.FuncLit, *gopast.LambdaExpr2:
+		return true
+	}
+	return false
+}
+
+// branchTargetFor returns a function that reports whether a node is the
+// target of an unlabeled branch statement with the given token. Functions are
+// reported as targets, too, as branches never leave them.
+func branchTargetFor(tok goptoken.Token) func(node gopast.Node) bool {
+	return func(node gopast.Node) bool {
+		switch node.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr2:
+			return tok != goptoken.FALLTHROUGH
+		case *gopast.ForStmt, *gopast.RangeStmt, *gopast.ForPhraseStmt:
+			return tok == goptoken.BREAK || tok == goptoken.CONTINUE
+		case *gopast.SwitchStmt, *gopast.TypeSwitchStmt, *gopast.SelectStmt:
+			return tok == goptoken.BREAK
+		case *gopast.CaseClause:
+			return tok == goptoken.FALLTHROUGH
+		}
+		return false
+	}
+}
+
+// hasEnclosingNode reports whether a node of the given stack, from the
+// innermost one, satisfies f before the statements being inspected are left.
+// The innermost node itself is skipped.
+func hasEnclosingNode(stack []gopast.Node, f func(node gopast.Node) bool) bool {
+	for i := len(stack) - 2; i >= 0; i-- {
+		if f(stack[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFuncName returns a name for a new function that is not used by any
+// object of the main package or visible at the given position.
+func (r *compileResult) newFuncName(pos goptoken.Pos) string {
+	typeInfo := getTypeInfo(r.proj)
+	used := make(map[string]struct{})
+	for _, obj := range typeInfo.Defs {
+		if obj != nil {
+			used[obj.Name()] = struct{}{}
+		}
+	}
+	scope := r.innermostScopeAt(pos)
+	isUsed := func(name string) bool {
+		if _, ok := used[name]; ok {
+			return true
+		}
+		if scope != nil {
+			_, obj := scope.LookupParent(name, goptoken.NoPos)
+			return obj != nil
+		}
+		return false
+	}
+
+	name := "newFunction"
+	for i := 1; isUsed(name); i++ {
+		name = fmt.Sprintf("newFunction%d", i)
+	}
+	return name
+}
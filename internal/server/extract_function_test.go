@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxExtractFunction(t *testing.T) {
+	extract := func(t *testing.T, content string, rng Range) (string, bool) {
+		m := map[string][]byte{"main.spx": []byte(content)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        rng,
+			Context:      CodeActionContext{Only: []CodeActionKind{refactorExtractFunction}},
+		})
+		require.NoError(t, err)
+		if len(codeActions) == 0 {
+			return "", false
+		}
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Extract function", codeActions[0].Title)
+		assert.Equal(t, refactorExtractFunction, codeActions[0].Kind)
+		require.NotNil(t, codeActions[0].Edit)
+		return applyTextEdits(m["main.spx"], codeActions[0].Edit.Changes["file:///main.spx"]), true
+	}
+	lines := func(startLine, endLine uint32) Range {
+		return Range{
+			Start: Position{Line: startLine},
+			End:   Position{Line: endLine + 1},
+		}
+	}
+
+	t.Run("ParamsAndResult", func(t *testing.T) {
+		got, ok := extract(t, `var (
+	count int
+)
+
+x := 1
+y := x + 2
+echo y
+count = y
+`, lines(5, 5))
+		require.True(t, ok)
+		assert.Equal(t, `var (
+	count int
+)
+
+func newFunction(x int) int {
+	y := x + 2
+	return y
+}
+
+x := 1
+y := newFunction(x)
+echo y
+count = y
+`, got)
+	})
+
+	t.Run("FieldsAndUnusedResults", func(t *testing.T) {
+		got, ok := extract(t, `var (
+	count int
+)
+
+x := 1
+y := x + 2
+count = y
+echo count
+`, lines(5, 6))
+		require.True(t, ok)
+		assert.Equal(t, `var (
+	count int
+)
+
+func newFunction(x int) {
+	y := x + 2
+	count = y
+}
+
+x := 1
+newFunction(x)
+echo count
+`, got)
+	})
+
+	t.Run("MutatedVarInLambda", func(t *testing.T) {
+		got, ok := extract(t, `onStart => {
+	n := 0
+	for i := 1; i <= 3; i++ {
+		n += i
+	}
+	echo n
+}
+`, lines(2, 4))
+		require.True(t, ok)
+		assert.Equal(t, `func newFunction(n int) int {
+	for i := 1; i <= 3; i++ {
+		n += i
+	}
+	return n
+}
+
+onStart => {
+	n := 0
+	n = newFunction(n)
+	echo n
+}
+`, got)
+	})
+
+	t.Run("DefinedAndMutatedResults", func(t *testing.T) {
+		got, ok := extract(t, `a := 1
+a++
+b := a * 2
+echo a, b
+`, Range{Start: Position{Line: 1}, End: Position{Line: 2, Character: 10}})
+		require.True(t, ok)
+		assert.Equal(t, `func newFunction(a int) (int, int) {
+	a++
+	b := a * 2
+	return b, a
+}
+
+a := 1
+var b int
+b, a = newFunction(a)
+echo a, b
+`, got)
+	})
+
+	t.Run("MutatedField", func(t *testing.T) {
+		got, ok := extract(t, `type P struct {
+	X int
+}
+
+p := P{}
+p.X = 5
+echo p.X
+`, lines(5, 5))
+		require.True(t, ok)
+		assert.Equal(t, `type P struct {
+	X int
+}
+
+func newFunction(p P) P {
+	p.X = 5
+	return p
+}
+
+p := P{}
+p = newFunction(p)
+echo p.X
+`, got)
+	})
+
+	t.Run("MutatedArrayElement", func(t *testing.T) {
+		got, ok := extract(t, `a := [3]int{}
+a[0]++
+echo a
+`, lines(1, 1))
+		require.True(t, ok)
+		assert.Equal(t, `func newFunction(a [3]int) [3]int {
+	a[0]++
+	return a
+}
+
+a := [3]int{}
+a = newFunction(a)
+echo a
+`, got)
+	})
+
+	t.Run("PointerReceiverCall", func(t *testing.T) {
+		got, ok := extract(t, `type P struct {
+	X int
+}
+
+func (p *P) Inc() {
+	p.X++
+}
+
+p := P{}
+p.Inc()
+echo p.X
+`, lines(9, 9))
+		require.True(t, ok)
+		assert.Equal(t, `type P struct {
+	X int
+}
+
+func (p *P) Inc() {
+	p.X++
+}
+
+func newFunction(p P) P {
+	p.Inc()
+	return p
+}
+
+p := P{}
+p = newFunction(p)
+echo p.X
+`, got)
+	})
+
+	t.Run("NameConflict", func(t *testing.T) {
+		got, ok := extract(t, `func newFunction() {}
+
+echo 1
+echo 2
+`, lines(3, 3))
+		require.True(t, ok)
+		assert.Equal(t, `func newFunction() {}
+
+func newFunction1() {
+	echo 2
+}
+
+echo 1
+newFunction1()
+`, got)
+	})
+
+	t.Run("InsideFunc", func(t *testing.T) {
+		got, ok := extract(t, `// greet greets.
+func greet(name string) {
+	msg := "Hi, " + name
+	echo msg
+}
+
+greet "Go+"
+`, lines(2, 2))
+		require.True(t, ok)
+		assert.Equal(t, `func newFunction(name string) string {
+	msg := "Hi, " + name
+	return msg
+}
+
+// greet greets.
+func greet(name string) {
+	msg := newFunction(name)
+	echo msg
+}
+
+greet "Go+"
+`, got)
+	})
+
+	t.Run("Unextractable", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			content string
+			rng     Range
+		}{
+			{
+				name: "PartialStatement",
+				content: `x := 1
+echo x + 2
+`,
+				rng: Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 10}},
+			},
+			{
+				name: "Return",
+				content: `func f(x int) int {
+	if x > 0 {
+		return x
+	}
+	return 0
+}
+`,
+				rng: lines(1, 3),
+			},
+			{
+				name: "Break",
+				content: `for i := 0; i < 3; i++ {
+	if i == 1 {
+		break
+	}
+}
+`,
+				rng: lines(1, 3),
+			},
+			{
+				name: "LocalConst",
+				content: `func f() {
+	const n = 1
+	echo n
+}
+`,
+				rng: lines(2, 2),
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				_, ok := extract(t, tt.content, tt.rng)
+				assert.False(t, ok)
+			})
+		}
+	})
+}
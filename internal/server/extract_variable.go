@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// refactorExtractVariable is the kind of code actions that extract the
+// selected expression into a new local variable.
+const refactorExtractVariable = RefactorExtract + ".variable"
+
+// spxExtractVariableEdits returns the edits that extract the expression in the
+// given range of the spx source file of the given document URI into a new
+// local variable declared before the statement containing it. It returns nil
+// if the expression can not be extracted.
+func (s *Server) spxExtractVariableEdits(ctx context.Context, uri DocumentURI, rng Range) ([]TextEdit, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	edits := result.extractVariable(astFile, result.posAt(astFile, rng.Start), result.posAt(astFile, rng.End))
+	if len(edits) == 0 || !result.typeChecksAfterEdits(ctx, spxFile, edits) {
+		return nil, nil
+	}
+	return edits, nil
+}
+
+// extractVariable returns the edits that extract the expression between start
+// and end in the given AST file into a new local variable. It returns nil if
+// the range does not cover a whole expression with a value, or if evaluating
+// it once before its statement may change the behavior, e.g., for a loop
+// condition.
+func (r *compileResult) extractVariable(astFile *gopast.File, start, end goptoken.Pos) []TextEdit {
+	start, end, ok := r.trimSelection(astFile, start, end)
+	if !ok {
+		return nil
+	}
+	path, _ := goputil.PathEnclosingInterval(r.proj, start, end)
+	if len(path) < 2 {
+		return nil
+	}
+	expr, ok := path[0].(gopast.Expr)
+	if !ok || expr.Pos() != start || expr.End() != end || !isExtractableExpr(expr, path[1]) {
+		return nil
+	}
+	typeInfo := getTypeInfo(r.proj)
+	tv, ok := typeInfo.Types[expr]
+	if !ok || !tv.IsValue() || tv.IsNil() {
+		return nil
+	}
+	if _, ok := tv.Type.(*types.Tuple); ok {
+		return nil
+	}
+
+	// Find the statement to declare the variable before.
+	var (
+		stmt  gopast.Stmt
+		stmts gopast.Node // the node of the statement list of stmt
+		child gopast.Node = expr
+	)
+	for i, node := range path[1:] {
+		switch node := node.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return nil // Not evaluated where the function is.
+		case *gopast.ForStmt:
+			if child == node.Cond || child == node.Post {
+				return nil // Evaluated for each iteration.
+			}
+		}
+		if s, ok := node.(gopast.Stmt); ok && i+2 < len(path) {
+			switch s.(type) {
+			case *gopast.CaseClause, *gopast.CommClause:
+			default:
+				if list := stmtListNode(path[i+2]); list != nil {
+					stmt, stmts = s, list
+				}
+			}
+		}
+		if stmt != nil {
+			break
+		}
+		child = node
+	}
+	if stmt == nil {
+		return nil
+	}
+
+	// Local objects used by the expression must be declared before the
+	// statement, e.g., not in the init statement of an if statement.
+	declaredAfter := false
+	gopast.Inspect(expr, func(node gopast.Node) bool {
+		if ident, ok := node.(*gopast.Ident); ok {
+			if obj := typeInfo.Uses[ident]; obj != nil && obj.Pkg() != nil && obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope() && obj.Pos() >= stmt.Pos() {
+				declaredAfter = true
+			}
+		}
+		return !declaredAfter
+	})
+	if declaredAfter {
+		return nil
+	}
+
+	name := r.newVarName(stmts, stmt.Pos(), placeholderName(expr, tv.Type))
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	indent := lineIndent(astFile.Code, tokenFile.Offset(stmt.Pos()))
+	exprText := astFile.Code[tokenFile.Offset(start):tokenFile.Offset(end)]
+	return []TextEdit{
+		{
+			Range:   r.rangeForStartEnd(astFile, stmt.Pos(), stmt.Pos()),
+			NewText: fmt.Sprintf("%s := %s\n%s", name, exprText, indent),
+		},
+		{
+			Range:   r.rangeForStartEnd(astFile, start, end),
+			NewText: name,
+		},
+	}
+}
+
+// stmtListNode returns the node of the statement list of the statements with
+// the given parent node in a path of [goputil.PathEnclosingInterval], or nil if
+// their parent node has no statement list. The body of the ShadowEntry has no
+// valid position, so its statements are direct children of the ShadowEntry in
+// paths.
+func stmtListNode(parent gopast.Node) gopast.Node {
+	switch parent := parent.(type) {
+	case *gopast.BlockStmt, *gopast.CaseClause, *gopast.CommClause:
+		return parent
+	case *gopast.FuncDecl:
+		if parent.Shadow {
+			return parent.Body
+		}
+	}
+	return nil
+}
+
+// isExtractableExpr reports whether the given expression with the given
+// parent node may be replaced with a variable holding its value.
+func isExtractableExpr(expr gopast.Expr, parent gopast.Node) bool {
+	switch parent := parent.(type) {
+	case *gopast.AssignStmt:
+		for _, lhs := range parent.Lhs {
+			if lhs == expr {
+				return false
+			}
+		}
+	case *gopast.IncDecStmt:
+		return parent.X != expr
+	case *gopast.RangeStmt:
+		return parent.Key != expr && parent.Value != expr
+	case *gopast.UnaryExpr:
+		return parent.Op != goptoken.AND
+	case *gopast.SelectorExpr:
+		return parent.Sel != expr
+	case *gopast.KeyValueExpr:
+		return parent.Key != expr
+	case *gopast.Field, *gopast.ValueSpec, *gopast.TypeSpec, *gopast.LabeledStmt, *gopast.BranchStmt:
+		return false
+	}
+	return true
+}
+
+// placeholderName returns a name for a variable holding the value of the given
+// expression of the given type, e.g., costume for getCostume() and s for a
+// string.
+func placeholderName(expr gopast.Expr, typ types.Type) string {
+	var name string
+	switch expr := expr.(type) {
+	case *gopast.CallExpr:
+		switch fun := expr.Fun.(type) {
+		case *gopast.Ident:
+			name = fun.Name
+		case *gopast.SelectorExpr:
+			name = fun.Sel.Name
+		}
+		for _, prefix := range []string{"get", "Get"} {
+			if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" && rest[0] >= 'A' && rest[0] <= 'Z' {
+				name = rest
+			}
+		}
+	case *gopast.SelectorExpr:
+		name = expr.Sel.Name
+	}
+	if name == "" || isBuiltinName(name) {
+		name = typePlaceholderName(typ)
+	}
+	name = toLowerCamelCase(name)
+	if !goptoken.IsIdentifier(name) {
+		return "v"
+	}
+	return name
+}
+
+// isBuiltinName reports whether the given name is the name of a builtin
+// object, e.g., len, whose calls do not name their results.
+func isBuiltinName(name string) bool {
+	obj := types.Universe.Lookup(name)
+	return obj != nil && isBuiltinObject(obj)
+}
+
+// typePlaceholderName returns a name for a variable of the given type.
+func typePlaceholderName(typ types.Type) string {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Pointer:
+		return typePlaceholderName(t.Elem())
+	case *types.Named:
+		return t.Obj().Name()
+	case *types.Basic:
+		switch info := t.Info(); {
+		case info&types.IsString != 0:
+			return "s"
+		case info&types.IsBoolean != 0:
+			return "ok"
+		case info&types.IsInteger != 0:
+			return "n"
+		case info&types.IsFloat != 0:
+			return "f"
+		}
+	case *types.Slice:
+		if elem, ok := types.Unalias(t.Elem()).(*types.Named); ok {
+			return elem.Obj().Name() + "s"
+		}
+		return "items"
+	case *types.Map:
+		return "m"
+	case *types.Signature:
+		return "fn"
+	case *types.Chan:
+		return "ch"
+	}
+	return "v"
+}
+
+// newVarName returns the given name, or the name with the smallest number
+// suffix, that is not used in the given statement list node and does not
+// refer to any object visible at the given position, so that declaring a
+// variable with it there shadows nothing.
+func (r *compileResult) newVarName(stmts gopast.Node, pos goptoken.Pos, name string) string {
+	used := make(map[string]struct{})
+	var visit func(node gopast.Node) bool
+	visit = func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.Ident:
+			used[node.Name] = struct{}{}
+		case *gopast.SelectorExpr:
+			// Selected names are not shadowed.
+			gopast.Inspect(node.X, visit)
+			return false
+		}
+		return true
+	}
+	gopast.Inspect(stmts, visit)
+	scope := r.innermostScopeAt(pos)
+	isUsed := func(name string) bool {
+		if _, ok := used[name]; ok || goptoken.IsKeyword(name) {
+			return true
+		}
+		if scope != nil {
+			_, obj := scope.LookupParent(name, goptoken.NoPos)
+			return obj != nil
+		}
+		return false
+	}
+
+	newName := name
+	for i := 1; isUsed(newName); i++ {
+		newName = fmt.Sprintf("%s%d", name, i)
+	}
+	return newName
+}
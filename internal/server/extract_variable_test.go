@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxExtractVariable(t *testing.T) {
+	extract := func(t *testing.T, content string, rng Range) (string, bool) {
+		m := map[string][]byte{"main.spx": []byte(content)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        rng,
+			Context:      CodeActionContext{Only: []CodeActionKind{refactorExtractVariable}},
+		})
+		require.NoError(t, err)
+		if len(codeActions) == 0 {
+			return "", false
+		}
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Extract variable", codeActions[0].Title)
+		require.NotNil(t, codeActions[0].Edit)
+		edits := codeActions[0].Edit.Changes["file:///main.spx"]
+		assert.Len(t, edits, 2, "edits are minimal")
+		return applyTextEdits(m["main.spx"], edits), true
+	}
+	span := func(line, startChar, endChar uint32) Range {
+		return Range{
+			Start: Position{Line: line, Character: startChar},
+			End:   Position{Line: line, Character: endChar},
+		}
+	}
+
+	t.Run("BinaryExpr", func(t *testing.T) {
+		got, ok := extract(t, `x := 1
+echo x + 2
+`, span(1, 5, 10))
+		require.True(t, ok)
+		assert.Equal(t, `x := 1
+n := x + 2
+echo n
+`, got)
+	})
+
+	t.Run("CallName", func(t *testing.T) {
+		got, ok := extract(t, `func getScore() int {
+	return 1
+}
+
+onStart => {
+	if getScore() > 0 {
+		echo "win"
+	}
+}
+`, span(5, 4, 14))
+		require.True(t, ok)
+		assert.Equal(t, `func getScore() int {
+	return 1
+}
+
+onStart => {
+	score := getScore()
+	if score > 0 {
+		echo "win"
+	}
+}
+`, got)
+	})
+
+	t.Run("NameConflict", func(t *testing.T) {
+		got, ok := extract(t, `s := "a"
+echo s + "b"
+`, span(1, 5, 12))
+		require.True(t, ok)
+		assert.Equal(t, `s := "a"
+s1 := s + "b"
+echo s1
+`, got)
+	})
+
+	t.Run("Unextractable", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			content string
+			rng     Range
+		}{
+			{
+				name: "PartialExpr",
+				content: `x := 1
+echo x + 2
+`,
+				rng: span(1, 5, 8),
+			},
+			{
+				name: "AssignedVar",
+				content: `x := 1
+x = 2
+echo x
+`,
+				rng: span(1, 0, 1),
+			},
+			{
+				name: "LoopCondition",
+				content: `for i := 0; i < 3; i++ {
+	echo i
+}
+`,
+				rng: span(0, 12, 17),
+			},
+			{
+				name: "IfInitVar",
+				content: `if x := 1; x > 0 {
+	echo x
+}
+`,
+				rng: span(0, 11, 16),
+			},
+			{
+				name: "Type",
+				content: `var x int = 1
+echo x
+`,
+				rng: span(0, 6, 9),
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				_, ok := extract(t, tt.content, tt.rng)
+				assert.False(t, ok)
+			})
+		}
+	})
+}
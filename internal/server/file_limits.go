@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// The host of the server may limit the sizes of the files of the workspace
+// and mount parts of it read-only, e.g., when untrusted projects are loaded
+// by a multi-tenant host. Files exceeding the limits are left out of the
+// workspace, which is shown to the user with window/showMessage, and edits
+// of read-only files fail with the RequestFailed error.
+
+// SetFileLimits sets the limits of the sizes of the files of the workspace.
+func (s *Server) SetFileLimits(limits vfs.Limits) {
+	s.settingsMu.Lock()
+	s.fileLimits = limits
+	s.settingsMu.Unlock()
+	s.scheduleWorkspaceChecks()
+}
+
+// SetReadOnly sets the read-only mounts of the workspace, whose files are
+// never changed by edits of the server.
+func (s *Server) SetReadOnly(readOnly vfs.ReadOnly) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.readOnly = slices.Clone(readOnly)
+}
+
+// applyFileLimits returns files without the files exceeding the limits of
+// the workspace, showing them to the user.
+func (s *Server) applyFileLimits(files map[string]vfs.MapFile) map[string]vfs.MapFile {
+	s.settingsMu.RLock()
+	limits := s.fileLimits
+	s.settingsMu.RUnlock()
+	files, err := limits.Apply(files)
+	s.showFileLimitError(err)
+	return files
+}
+
+// showFileLimitError shows the given error of [vfs.Limits.Apply], if not nil,
+// to the user, unless it was the last one shown. Files are applied the limits
+// for each snapshot, so the same error is only shown again once resolved.
+func (s *Server) showFileLimitError(err error) {
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	s.fileLimitErrorMu.Lock()
+	if msg == s.fileLimitError {
+		s.fileLimitErrorMu.Unlock()
+		return
+	}
+	s.fileLimitError = msg
+	s.fileLimitErrorMu.Unlock()
+	if err == nil {
+		return
+	}
+
+	s.logger.Warn("files left out of the workspace", "error", err)
+	n, err := jsonrpc2.NewNotification("window/showMessage", &ShowMessageParams{
+		Type:    Warning,
+		Message: "Some files are too large to be loaded:\n" + msg,
+	})
+	if err != nil {
+		return
+	}
+	_ = s.replier.ReplyMessage(n)
+}
+
+// checkWritable returns an error if the given edit changes files in the
+// read-only mounts of the workspace.
+func (s *Server) checkWritable(edit *WorkspaceEdit) error {
+	s.settingsMu.RLock()
+	readOnly := s.readOnly
+	s.settingsMu.RUnlock()
+	if edit == nil || len(readOnly) == 0 {
+		return nil
+	}
+
+	uris := slices.Collect(maps.Keys(edit.Changes))
+	for _, change := range edit.DocumentChanges {
+		switch {
+		case change.TextDocumentEdit != nil:
+			uris = append(uris, change.TextDocumentEdit.TextDocument.URI)
+		case change.CreateFile != nil:
+			uris = append(uris, change.CreateFile.URI)
+		case change.RenameFile != nil:
+			uris = append(uris, change.RenameFile.OldURI, change.RenameFile.NewURI)
+		case change.DeleteFile != nil:
+			uris = append(uris, change.DeleteFile.URI)
+		}
+	}
+	slices.Sort(uris)
+	for _, uri := range uris {
+		path, err := s.fromDocumentURI(uri)
+		if err != nil {
+			continue
+		}
+		if err := readOnly.Check(path); err != nil {
+			return fmt.Errorf("%w: %w", jsonrpc2.ErrRequestFailed, err)
+		}
+	}
+	return nil
+}
+
+// isReadOnly reports whether the document with the given URI is in the
+// read-only mounts of the workspace.
+func (s *Server) isReadOnly(uri DocumentURI) bool {
+	path, err := s.fromDocumentURI(uri)
+	if err != nil {
+		return false
+	}
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.readOnly.Contains(path)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSetFileLimits(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`println "` + strings.Repeat("x", 1024) + `"`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+	replier := &recordingReplier{}
+	s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+	initializeServer(t, s)
+	s.diagnosticScheduler.delay = time.Hour
+	s.indexScheduler.delay = time.Hour
+
+	showMessages := func() (msgs []ShowMessageParams) {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		for _, msg := range replier.messages {
+			if n, ok := msg.(*jsonrpc2.Notification); ok && n.Method() == "window/showMessage" {
+				var params ShowMessageParams
+				require.NoError(t, UnmarshalJSON(n.Params(), &params))
+				msgs = append(msgs, params)
+			}
+		}
+		return
+	}
+
+	_, ok := s.getProj().File("MySprite.spx")
+	require.True(t, ok)
+
+	s.SetFileLimits(vfs.Limits{MaxFileSize: 1024})
+	_, err := s.compile(context.Background())
+	require.NoError(t, err)
+	_, ok = s.getProj().File("MySprite.spx")
+	assert.False(t, ok)
+
+	// The same error is only shown once.
+	_, err = s.compile(context.Background())
+	require.NoError(t, err)
+	msgs := showMessages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, Warning, msgs[0].Type)
+	assert.Contains(t, msgs[0].Message, "MySprite.spx: file size of 1034 bytes exceeds the limit of 1024 bytes")
+
+	s.SetFileLimits(vfs.Limits{})
+	_, err = s.compile(context.Background())
+	require.NoError(t, err)
+	_, ok = s.getProj().File("MySprite.spx")
+	assert.True(t, ok)
+	assert.Len(t, showMessages(), 1)
+}
+
+func TestServerSetReadOnly(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+const Foo = "bar"
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`println Foo`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	s.SetReadOnly(vfs.ReadOnly{"MySprite.spx"})
+
+	t.Run("Rename", func(t *testing.T) {
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 4, Character: 6},
+			NewName:      "Bar",
+		})
+		assert.ErrorIs(t, err, jsonrpc2.ErrRequestFailed)
+		var roErr *vfs.ReadOnlyError
+		require.True(t, errors.As(err, &roErr))
+		assert.Equal(t, "MySprite.spx", roErr.Path)
+		assert.Nil(t, workspaceEdit)
+	})
+
+	t.Run("Formatting", func(t *testing.T) {
+		edits, err := s.textDocumentFormatting(context.Background(), &DocumentFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, edits)
+	})
+
+	t.Run("CodeAction", func(t *testing.T) {
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, codeActions)
+	})
+
+	t.Run("Writable", func(t *testing.T) {
+		s.SetReadOnly(nil)
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 4, Character: 6},
+			NewName:      "Bar",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		assert.Contains(t, workspaceEdit.Changes, DocumentURI("file:///MySprite.spx"))
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_foldingRange
+func (s *Server) textDocumentFoldingRange(ctx context.Context, params *FoldingRangeParams) ([]FoldingRange, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	var foldingRanges []FoldingRange
+
+	// addBracketFoldingRange adds a folding range for the content between the
+	// given brackets, keeping the closing bracket line visible.
+	addBracketFoldingRange := func(lbrack, rbrack goptoken.Pos) {
+		if !lbrack.IsValid() || !rbrack.IsValid() {
+			// The ShadowEntry body of Go+ files has no brackets. It is
+			// folded below.
+			return
+		}
+		rng := result.rangeForStartEnd(astFile, lbrack, rbrack)
+		if rng.End.Line <= rng.Start.Line+1 {
+			return
+		}
+		foldingRanges = append(foldingRanges, FoldingRange{
+			StartLine: rng.Start.Line,
+			EndLine:   rng.End.Line - 1,
+		})
+	}
+	for node := range result.inspector(astFile).PreorderSeq((*gopast.BlockStmt)(nil), (*gopast.CompositeLit)(nil)) {
+		switch node := node.(type) {
+		case *gopast.BlockStmt:
+			addBracketFoldingRange(node.Lbrace, node.Rbrace)
+		case *gopast.CompositeLit:
+			addBracketFoldingRange(node.Lbrace, node.Rbrace)
+		}
+	}
+
+	// The ShadowEntry body is folded from its first statement to its last
+	// one.
+	if entry := astFile.ShadowEntry; entry != nil && entry.Body != nil && len(entry.Body.List) > 0 {
+		stmts := entry.Body.List
+		rng := result.rangeForStartEnd(astFile, stmts[0].Pos(), stmts[len(stmts)-1].End())
+		if rng.End.Line > rng.Start.Line {
+			foldingRanges = append(foldingRanges, FoldingRange{
+				StartLine: rng.Start.Line,
+				EndLine:   rng.End.Line,
+			})
+		}
+	}
+
+	for _, cg := range astFile.Comments {
+		rng := result.rangeForASTFileNode(astFile, cg)
+		if rng.End.Line <= rng.Start.Line {
+			continue
+		}
+		foldingRanges = append(foldingRanges, FoldingRange{
+			StartLine: rng.Start.Line,
+			EndLine:   rng.End.Line,
+			Kind:      string(Comment),
+		})
+	}
+	return foldingRanges, nil
+}
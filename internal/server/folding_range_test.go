@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentFoldingRange(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`// Leading comment
+// spanning multiple lines.
+var (
+	nums []int
+)
+
+func greet(name string) {
+	if name != "" {
+		echo "Hi", name
+	}
+	for i := 0; i < 3; i++ {
+		echo i
+	}
+}
+
+onStart => {
+	nums = [
+		1,
+		2,
+	]
+	greet "Go+"
+}
+
+run "assets", {
+	Title: "My Game",
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		foldingRanges, err := s.textDocumentFoldingRange(context.Background(), &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []FoldingRange{
+			{StartLine: 0, EndLine: 1, Kind: string(Comment)},
+			{StartLine: 6, EndLine: 12},
+			{StartLine: 7, EndLine: 8},
+			{StartLine: 10, EndLine: 11},
+			{StartLine: 15, EndLine: 20},
+			{StartLine: 15, EndLine: 25},
+			{StartLine: 23, EndLine: 24},
+		}, foldingRanges)
+	})
+
+	t.Run("ShadowEntry", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+func greet() {}
+
+greet
+echo "Hi"
+
+// Trailing comment.
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		foldingRanges, err := s.textDocumentFoldingRange(context.Background(), &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []FoldingRange{
+			{StartLine: 3, EndLine: 4},
+		}, foldingRanges)
+	})
+
+	t.Run("SingleLineBlocks", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+func greet() {}
+
+onStart => { greet }
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		foldingRanges, err := s.textDocumentFoldingRange(context.Background(), &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, foldingRanges)
+	})
+
+	t.Run("NonExistentDocument", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		foldingRanges, err := s.textDocumentFoldingRange(context.Background(), &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, foldingRanges)
+	})
+}
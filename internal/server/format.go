@@ -2,35 +2,106 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go/types"
 	"path"
 	"slices"
+	"strings"
 	"time"
 
 	gopast "github.com/goplus/gop/ast"
 	gopfmt "github.com/goplus/gop/format"
 	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/goplus/goxlsw/internal/vfs"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_formatting
-func (s *Server) textDocumentFormatting(params *DocumentFormattingParams) ([]TextEdit, error) {
-	spxFile, err := s.fromDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentFormatting(ctx context.Context, params *DocumentFormattingParams) ([]TextEdit, error) {
+	return s.spxFormattingEdits(ctx, params.TextDocument.URI, s.formatSpx)
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_rangeFormatting
+func (s *Server) textDocumentRangeFormatting(ctx context.Context, params *DocumentRangeFormattingParams) ([]TextEdit, error) {
+	// Only the Go+ formatter is applied, as the other formatters may move
+	// code across the document.
+	edits, err := s.spxFormattingEdits(ctx, params.TextDocument.URI, s.formatSpxGop)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+		return nil, err
+	}
+	return textEditsInLineRange(edits, params.Range.Start.Line, params.Range.End.Line), nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_onTypeFormatting
+func (s *Server) textDocumentOnTypeFormatting(ctx context.Context, params *DocumentOnTypeFormattingParams) ([]TextEdit, error) {
+	var startLine, endLine uint32
+	switch params.Ch {
+	case "\n":
+		// Only format the line before the new line, so that the indentation
+		// inserted by the editor on the new line is kept.
+		if params.Position.Line == 0 {
+			return nil, nil
+		}
+		startLine = params.Position.Line - 1
+		endLine = startLine
+	case "}":
+		// Format the whole block closed by the typed brace.
+		startLine, endLine = params.Position.Line, params.Position.Line
+		result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+		if err != nil || astFile == nil {
+			break
+		}
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			var lbrace, rbrace goptoken.Pos
+			switch node := node.(type) {
+			case *gopast.BlockStmt:
+				lbrace, rbrace = node.Lbrace, node.Rbrace
+			case *gopast.CompositeLit:
+				lbrace, rbrace = node.Lbrace, node.Rbrace
+			}
+			if !lbrace.IsValid() || !rbrace.IsValid() {
+				return true
+			}
+			rng := result.rangeForStartEnd(astFile, lbrace, rbrace)
+			if rng.End.Line == params.Position.Line {
+				startLine = min(startLine, rng.Start.Line)
+			}
+			return true
+		})
+	default:
+		return nil, nil
+	}
+
+	edits, err := s.spxFormattingEdits(ctx, params.TextDocument.URI, s.formatSpxGop)
+	if err != nil {
+		// Code being typed is often incomplete and cannot be formatted yet.
+		return nil, nil
+	}
+	return textEditsInLineRange(edits, startLine, endLine), nil
+}
+
+// spxFormattingEdits formats the spx source file of the given document URI
+// with the given formatter and returns the edits to apply.
+func (s *Server) spxFormattingEdits(ctx context.Context, uri DocumentURI, formatter spxFormatter) ([]TextEdit, error) {
+	spxFile, err := s.fromDocumentURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", uri, err)
 	}
 	if path.Ext(spxFile) != ".spx" {
 		return nil, nil // Not an spx source file.
 	}
+	if s.isReadOnly(uri) {
+		return nil, nil // Read-only files are left as is.
+	}
 
-	snapshot := s.workspaceRootFS.Snapshot()
+	snapshot := s.snapshot()
 	original, err := vfs.ReadFile(snapshot, spxFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read spx source file: %w", err)
 	}
 
-	formatted, err := s.formatSpx(snapshot, spxFile)
+	formatted, err := formatter(ctx, snapshot, spxFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to format spx source file: %w", err)
 	}
@@ -38,32 +109,137 @@ func (s *Server) textDocumentFormatting(params *DocumentFormattingParams) ([]Tex
 	if bytes.Equal(formatted, original) {
 		return nil, nil // No changes.
 	}
+	return computeTextEdits(s.getPositionEncoding(), original, formatted), nil
+}
+
+// textEditsInLineRange returns the edits that touch any line between startLine
+// and endLine, inclusive.
+func textEditsInLineRange(edits []TextEdit, startLine, endLine uint32) []TextEdit {
+	var result []TextEdit
+	for _, edit := range edits {
+		lastLine := edit.Range.End.Line
+		if edit.Range.End.Character == 0 && lastLine > edit.Range.Start.Line {
+			lastLine-- // The end position is the start of the next line.
+		}
+		if edit.Range.Start.Line <= endLine && lastLine >= startLine {
+			result = append(result, edit)
+		}
+	}
+	return result
+}
+
+// maxTextEditsLCSCells is the maximum number of cells of the LCS table of
+// computeTextEdits, beyond which the changed lines are replaced as a whole.
+const maxTextEditsLCSCells = 1 << 20
+
+// computeTextEdits computes line-based [TextEdit]s, with ranges in
+// posEncoding, that transform original into formatted. Only changed lines are
+// replaced, so that cursors and selections in unchanged lines are kept stable.
+func computeTextEdits(posEncoding position.Encoding, original, formatted []byte) []TextEdit {
+	a, b := splitLines(original), splitLines(formatted)
+
+	// Lines in the common prefix and suffix are unchanged, so the LCS table
+	// below only needs to cover the lines in between.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	am, bm := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
 
-	// Simply replace the entire document.
-	lines := bytes.Count(original, []byte("\n"))
-	lastNewLine := bytes.LastIndex(original, []byte("\n"))
-	lastLineContent := original
-	if lastNewLine >= 0 {
-		lastLineContent = lastLineContent[lastNewLine+1:]
+	lineOffsets := make([]int, len(a)+1)
+	for i, line := range a {
+		lineOffsets[i+1] = lineOffsets[i] + len(line)
 	}
-	utf16Offset := utf8OffsetToUTF16(string(lastLineContent), len(lastLineContent))
-	return []TextEdit{
-		{
+	if (len(am)+1)*(len(bm)+1) > maxTextEditsLCSCells {
+		// The LCS table would take too much time and memory.
+		return []TextEdit{{
 			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End: Position{
-					Line:      uint32(lines),
-					Character: uint32(utf16Offset),
-				},
+				Start: posEncoding.Position(original, lineOffsets[prefix]),
+				End:   posEncoding.Position(original, lineOffsets[prefix+len(am)]),
 			},
-			NewText: string(formatted),
-		},
-	}, nil
+			NewText: strings.Join(bm, ""),
+		}}
+	}
+
+	// lcs[i*(len(bm)+1)+j] is the length of the longest common subsequence
+	// of am[i:] and bm[j:].
+	stride := len(bm) + 1
+	lcs := make([]int, (len(am)+1)*stride)
+	for i := len(am) - 1; i >= 0; i-- {
+		for j := len(bm) - 1; j >= 0; j-- {
+			if am[i] == bm[j] {
+				lcs[i*stride+j] = lcs[(i+1)*stride+j+1] + 1
+			} else {
+				lcs[i*stride+j] = max(lcs[(i+1)*stride+j], lcs[i*stride+j+1])
+			}
+		}
+	}
+
+	var (
+		edits   []TextEdit
+		addEdit func(i1, i2, j1, j2 int)
+	)
+	addEdit = func(i1, i2, j1, j2 int) {
+		if i1 == i2 && j1 == j2 {
+			return
+		}
+		if i2-i1 == j2-j1 && i2-i1 > 1 {
+			// Replace line by line, so that edits can be picked per line,
+			// e.g. for range formatting.
+			for k := range i2 - i1 {
+				addEdit(i1+k, i1+k+1, j1+k, j1+k+1)
+			}
+			return
+		}
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: posEncoding.Position(original, lineOffsets[prefix+i1]),
+				End:   posEncoding.Position(original, lineOffsets[prefix+i2]),
+			},
+			NewText: strings.Join(bm[j1:j2], ""),
+		})
+	}
+	i, j := 0, 0
+	hunkI, hunkJ := 0, 0
+	for i < len(am) || j < len(bm) {
+		switch {
+		case i < len(am) && j < len(bm) && am[i] == bm[j]:
+			addEdit(hunkI, i, hunkJ, j)
+			i++
+			j++
+			hunkI, hunkJ = i, j
+		case j < len(bm) && (i == len(am) || lcs[i*stride+j+1] >= lcs[(i+1)*stride+j]):
+			j++
+		default:
+			i++
+		}
+	}
+	addEdit(hunkI, i, hunkJ, j)
+	return edits
+}
+
+// splitLines splits content into lines, keeping the trailing newline of each
+// line.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		n := bytes.IndexByte(content, '\n') + 1
+		if n == 0 {
+			n = len(content)
+		}
+		lines = append(lines, string(content[:n]))
+		content = content[n:]
+	}
+	return lines
 }
 
 // spxFormatter defines a function that formats an spx source file in the given
 // root file system snapshot.
-type spxFormatter func(snapshot *vfs.MapFS, spxFile string) (formatted []byte, err error)
+type spxFormatter func(ctx context.Context, snapshot *vfs.MapFS, spxFile string) (formatted []byte, err error)
 
 // formatSpx applies a series of formatters to an spx source file in order.
 //
@@ -71,14 +247,14 @@ type spxFormatter func(snapshot *vfs.MapFS, spxFile string) (formatted []byte, e
 //  1. Go+ formatter
 //  2. Lambda parameter elimination
 //  3. Declaration reordering
-func (s *Server) formatSpx(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+func (s *Server) formatSpx(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
 	var formatted []byte
 	for _, formatter := range []spxFormatter{
 		s.formatSpxGop,
 		s.formatSpxLambda,
 		s.formatSpxDecls,
 	} {
-		subFormatted, err := formatter(snapshot, spxFile)
+		subFormatted, err := formatter(ctx, snapshot, spxFile)
 		if err != nil {
 			return nil, err
 		}
@@ -103,7 +279,7 @@ func (s *Server) formatSpx(snapshot *vfs.MapFS, spxFile string) ([]byte, error)
 }
 
 // formatSpxGop formats an spx source file with Go+ formatter.
-func (s *Server) formatSpxGop(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+func (s *Server) formatSpxGop(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
 	original, err := vfs.ReadFile(snapshot, spxFile)
 	if err != nil {
 		return nil, err
@@ -112,8 +288,8 @@ func (s *Server) formatSpxGop(snapshot *vfs.MapFS, spxFile string) ([]byte, erro
 }
 
 // formatSpxLambda formats an spx source file by eliminating unused lambda parameters.
-func (s *Server) formatSpxLambda(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
-	compileResult, err := s.compileAt(snapshot)
+func (s *Server) formatSpxLambda(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	compileResult, err := s.compileAt(ctx, snapshot, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +315,7 @@ func (s *Server) formatSpxLambda(snapshot *vfs.MapFS, spxFile string) ([]byte, e
 }
 
 // formatSpxDecls formats an spx source file by reordering declarations.
-func (s *Server) formatSpxDecls(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+func (s *Server) formatSpxDecls(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
 	astFile, ok := getASTPkg(snapshot).Files[spxFile]
 	if !ok {
 		return nil, nil
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -27,15 +32,10 @@ run "assets",    { Title:    "Bullet (by Go+)" }
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 9, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 type Score int
 
@@ -45,8 +45,7 @@ var (
 )
 
 run "assets", {Title: "Bullet (by Go+)"}
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("NonSpxFile", func(t *testing.T) {
@@ -58,7 +57,7 @@ run "assets", {Title: "Bullet (by Go+)"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
 		require.Nil(t, edits)
 	})
@@ -69,7 +68,7 @@ run "assets", {Title: "Bullet (by Go+)"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.ErrorIs(t, err, fs.ErrNotExist)
 		require.Nil(t, edits)
 	})
@@ -83,7 +82,7 @@ run "assets", {Title: "Bullet (by Go+)"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
 		require.Nil(t, edits)
 	})
@@ -101,23 +100,17 @@ var MyAircraft MyAircraft
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 4, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 var (
 	MyAircraft MyAircraft
 )
 
 !InvalidSyntax
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("WithFormatSpx", func(t *testing.T) {
@@ -159,15 +152,10 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 30, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 var (
 	// The first var block.
@@ -202,8 +190,7 @@ var (
 
 	// Trailing comment for the last var block.
 )
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("VarBlockWithoutDoc", func(t *testing.T) {
@@ -223,15 +210,10 @@ var Bullet Bullet
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 8, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 var (
 	// The aircraft.
@@ -239,8 +221,7 @@ var (
 
 	Bullet Bullet
 )
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("NoTypeSpriteVarDeclaration", func(t *testing.T) {
@@ -259,7 +240,7 @@ run "assets", {Title: "My Game"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
 		require.Nil(t, edits)
 	})
@@ -281,7 +262,7 @@ run "assets", {Title: "My Game"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
 		require.Nil(t, edits)
 	})
@@ -303,15 +284,10 @@ onKey [KeyLeft, KeyRight], (key) => {
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 8, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 onKey [KeyLeft, KeyRight], () => {
 	println "key"
@@ -320,8 +296,7 @@ onKey [KeyLeft, KeyRight], () => {
 onKey [KeyLeft, KeyRight], (key) => {
 	println key
 }
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("WithUnusedLambdaParamsForSprite", func(t *testing.T) {
@@ -347,15 +322,10 @@ onTouchStart 123, (s) => { // type mismatch
 			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 13, Character: 0},
-			},
-			NewText: `// An spx game.
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `// An spx game.
 
 onKey [KeyLeft, KeyRight], () => {
 	println "key"
@@ -371,8 +341,7 @@ onTouchStart (s, t) => { // type mismatch
 }
 onTouchStart 123, (s) => { // type mismatch
 }
-`,
-		})
+`, applyTextEdits(m["MySprite.spx"], edits))
 	})
 
 	t.Run("EmptyFile", func(t *testing.T) {
@@ -384,7 +353,7 @@ onTouchStart 123, (s) => { // type mismatch
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
 		require.Nil(t, edits)
 	})
@@ -398,16 +367,10 @@ onTouchStart 123, (s) => { // type mismatch
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 0, Character: 1},
-			},
-			NewText: ``,
-		})
+		require.NotEmpty(t, edits)
+		assert.Equal(t, ``, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("WithFloatingComments", func(t *testing.T) {
@@ -443,15 +406,10 @@ run "assets", {Title: "My Game"}
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 24, Character: 0},
-			},
-			NewText: `import "fmt"
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `import "fmt"
 
 // floating comment1
 
@@ -477,8 +435,7 @@ func test() {
 run "assets", {Title: "My Game"}
 
 // floating comment5
-`,
-		})
+`, applyTextEdits(m["main.spx"], edits))
 	})
 
 	t.Run("WithTrailingComments", func(t *testing.T) {
@@ -497,15 +454,10 @@ func test() {} // trailing comment for func test
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		edits, err := s.textDocumentFormatting(params)
+		edits, err := s.textDocumentFormatting(context.Background(), params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 7, Character: 0},
-			},
-			NewText: `import "fmt" // trailing comment for import "fmt"
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `import "fmt" // trailing comment for import "fmt"
 
 const foo = "bar" // trailing comment for const foo
 
@@ -514,7 +466,254 @@ var (
 )
 
 func test() {} // trailing comment for func test
-`,
+`, applyTextEdits(m["main.spx"], edits))
+	})
+}
+
+func TestComputeTextEdits(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		original  string
+		formatted string
+		want      []TextEdit
+	}{
+		{
+			name:      "ChangedLine",
+			original:  "a\nb\nc\n",
+			formatted: "a\nB\nc\n",
+			want: []TextEdit{
+				{
+					Range:   Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 2, Character: 0}},
+					NewText: "B\n",
+				},
+			},
+		},
+		{
+			name:      "ChangedLines",
+			original:  "a\nb\nc\n",
+			formatted: "A\nB\nc\n",
+			want: []TextEdit{
+				{
+					Range:   Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 1, Character: 0}},
+					NewText: "A\n",
+				},
+				{
+					Range:   Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 2, Character: 0}},
+					NewText: "B\n",
+				},
+			},
+		},
+		{
+			name:      "InsertedAndDeletedLines",
+			original:  "a\nb\nc\nd\n",
+			formatted: "a\nx\nb\nd\n",
+			want: []TextEdit{
+				{
+					Range:   Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 0}},
+					NewText: "x\n",
+				},
+				{
+					Range:   Range{Start: Position{Line: 2, Character: 0}, End: Position{Line: 3, Character: 0}},
+					NewText: "",
+				},
+			},
+		},
+		{
+			name:      "MissingTrailingNewline",
+			original:  "a\nb",
+			formatted: "a\nb\n",
+			want: []TextEdit{
+				{
+					Range:   Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 1}},
+					NewText: "b\n",
+				},
+			},
+		},
+		{
+			name:      "NoChanges",
+			original:  "a\nb\n",
+			formatted: "a\nb\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := computeTextEdits(position.UTF16, []byte(tt.original), []byte(tt.formatted))
+			assert.Equal(t, tt.want, edits)
+			assert.Equal(t, tt.formatted, applyTextEdits([]byte(tt.original), edits))
+		})
+	}
+
+	t.Run("LargeChange", func(t *testing.T) {
+		var original, formatted strings.Builder
+		original.WriteString("package main\n")
+		formatted.WriteString("package main\n")
+		for i := range 1100 {
+			fmt.Fprintf(&original, "a%d\n", i)
+		}
+		for i := range 1000 {
+			fmt.Fprintf(&formatted, "b%d\n", i)
+		}
+		original.WriteString("end\n")
+		formatted.WriteString("end\n")
+
+		// The changed lines are replaced as a whole rather than diffed.
+		edits := computeTextEdits(position.UTF16, []byte(original.String()), []byte(formatted.String()))
+		require.Len(t, edits, 1)
+		assert.Equal(t, Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1101, Character: 0}}, edits[0].Range)
+		assert.Equal(t, formatted.String(), applyTextEdits([]byte(original.String()), edits))
+	})
+}
+
+// applyTextEdits applies the given non-overlapping text edits to content.
+func applyTextEdits(content []byte, edits []TextEdit) string {
+	offsetForPosition := func(pos Position) int {
+		return position.UTF16.Offset(content, pos)
+	}
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b TextEdit) int {
+		return offsetForPosition(b.Range.Start) - offsetForPosition(a.Range.Start)
+	})
+	result := slices.Clone(content)
+	for _, edit := range edits {
+		start, end := offsetForPosition(edit.Range.Start), offsetForPosition(edit.Range.End)
+		result = slices.Concat(result[:start], []byte(edit.NewText), result[end:])
+	}
+	return string(result)
+}
+
+func TestServerTextDocumentRangeFormatting(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`var  a   int
+var  b   int
+onStart => {
+echo   a
+echo   b
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentRangeFormatting(context.Background(), &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 3, Character: 0},
+				End:   Position{Line: 3, Character: 8},
+			},
 		})
+		require.NoError(t, err)
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `var  a   int
+var  b   int
+onStart => {
+	echo a
+echo   b
+}
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.gop": []byte(`echo  "Hello, Go+!"`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentRangeFormatting(context.Background(), &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
+		})
+		require.NoError(t, err)
+		require.Nil(t, edits)
+	})
+}
+
+func TestServerTextDocumentOnTypeFormatting(t *testing.T) {
+	t.Run("ClosingBrace", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`var  a   int
+
+onStart => {
+echo   a
+if a > 0 {
+echo   "positive"
+}
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentOnTypeFormatting(context.Background(), &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 7, Character: 1},
+			Ch:           "}",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `var  a   int
+
+onStart => {
+	echo a
+	if a > 0 {
+		echo "positive"
+	}
+}
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("NewLine", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`onStart => {
+	echo   "a"
+echo   "b"
+	
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentOnTypeFormatting(context.Background(), &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 3, Character: 1},
+			Ch:           "\n",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, edits)
+		assert.Equal(t, `onStart => {
+	echo   "a"
+	echo "b"
+	
+}
+`, applyTextEdits(m["main.spx"], edits))
+	})
+
+	t.Run("IncompleteCode", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`onStart => {
+echo   "a"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentOnTypeFormatting(context.Background(), &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 2, Character: 0},
+			Ch:           "\n",
+		})
+		require.NoError(t, err)
+		require.Nil(t, edits)
+	})
+
+	t.Run("OtherCharacter", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`echo   "a"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		edits, err := s.textDocumentOnTypeFormatting(context.Background(), &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 0, Character: 10},
+			Ch:           ";",
+		})
+		require.NoError(t, err)
+		require.Nil(t, edits)
 	})
 }
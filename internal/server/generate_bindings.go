@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// spxBinding represents an auto-binding variable declaration of an spx
+// resource, e.g., "MySprite MySprite" or "MySound Sound".
+type spxBinding struct {
+	name string
+	typ  string
+}
+
+// spxGenerateBindingsEdits returns the edits that declare the missing
+// auto-binding variables of all sprite and sound resources in the class fields
+// declaration of the main spx file, which is created if it does not exist.
+func (s *Server) spxGenerateBindingsEdits(result *compileResult) []TextEdit {
+	if result.mainSpxFile == "" {
+		return nil
+	}
+	astFile := getASTPkg(result.proj).Files[result.mainSpxFile]
+	if astFile == nil {
+		return nil
+	}
+
+	classFieldsDecl := goputil.ClassFieldsDecl(astFile)
+	bindings := result.missingSpxBindings(classFieldsDecl)
+	if len(bindings) == 0 {
+		return nil
+	}
+	var lines strings.Builder
+	for _, binding := range bindings {
+		lines.WriteString("\t" + binding.name + " " + binding.typ + "\n")
+	}
+
+	code := astFile.Code
+	start, end, newText := result.classFieldsInsertion(astFile, classFieldsDecl, lines.String())
+	newCode := make([]byte, 0, len(code)+len(newText))
+	newCode = append(newCode, code[:start]...)
+	newCode = append(newCode, newText...)
+	newCode = append(newCode, code[end:]...)
+	return computeTextEdits(result.posEncoding, code, newCode)
+}
+
+// classFieldsInsertion returns the offsets of the code in the given AST file
+// to replace with the returned text to declare the given lines of fields in
+// its class fields declaration, which is created if it does not exist.
+func (r *compileResult) classFieldsInsertion(astFile *gopast.File, classFieldsDecl *gopast.GenDecl, lines string) (start, end int, newText string) {
+	code := astFile.Code
+	offset := func(pos goptoken.Pos) int {
+		return r.proj.Fset.Position(pos).Offset
+	}
+	switch {
+	case classFieldsDecl != nil && classFieldsDecl.Lparen.IsValid():
+		// Insert before the closing parenthesis, on its own line.
+		start = offset(classFieldsDecl.Rparen)
+		lineStart := bytes.LastIndexByte(code[:start], '\n') + 1
+		if len(bytes.TrimSpace(code[lineStart:start])) == 0 {
+			start = lineStart
+			newText = lines
+		} else {
+			newText = "\n" + lines
+		}
+		end = start
+	case classFieldsDecl != nil:
+		// Turn the single variable declaration into a parenthesized one.
+		start, end = offset(classFieldsDecl.Pos()), offset(classFieldsDecl.End())
+		spec := code[offset(classFieldsDecl.Specs[0].Pos()):end]
+		newText = "var (\n\t" + string(spec) + "\n" + lines + ")"
+	default:
+		// Insert a new declaration after the package clause and imports,
+		// which are the only declarations allowed before it.
+		start = -1
+		if astFile.Package.IsValid() && astFile.Name != nil {
+			start = offset(astFile.Name.End())
+		}
+		for _, decl := range astFile.Decls {
+			if genDecl, ok := decl.(*gopast.GenDecl); ok && genDecl.Tok == goptoken.IMPORT {
+				start = offset(genDecl.End())
+			}
+		}
+		if start < 0 {
+			start = 0
+			newText = "var (\n" + lines + ")\n\n"
+		} else {
+			newText = "\n\nvar (\n" + lines + ")"
+		}
+		end = start
+	}
+	return
+}
+
+// missingSpxBindings returns the auto-binding variable declarations of the
+// sprite and sound resources that are not declared in the given class fields
+// declaration of the main spx file. Sprites come first, each sorted by name.
+// Resources whose names are not valid identifiers are skipped.
+func (r *compileResult) missingSpxBindings(classFieldsDecl *gopast.GenDecl) []spxBinding {
+	declared := make(map[string]struct{})
+	if classFieldsDecl != nil {
+		for _, spec := range classFieldsDecl.Specs {
+			valueSpec, ok := spec.(*gopast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, name := range valueSpec.Names {
+				declared[name.Name] = struct{}{}
+			}
+		}
+	}
+
+	var bindings []spxBinding
+	add := func(name, typ string) {
+		if _, ok := declared[name]; ok || !token.IsIdentifier(name) {
+			return
+		}
+		bindings = append(bindings, spxBinding{name: name, typ: typ})
+	}
+	pkg := getPkg(r.proj)
+	for _, name := range slices.Sorted(maps.Keys(r.spxResourceSet.sprites)) {
+		typ := "Sprite"
+		if obj, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok && vfs.HasSpriteType(r.proj, obj.Type()) {
+			typ = name
+		}
+		add(name, typ)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.spxResourceSet.sounds)) {
+		add(name, "Sound")
+	}
+	return bindings
+}
@@ -1,16 +1,18 @@
 package server
 
 import (
+	"context"
 	"slices"
 
 	gopast "github.com/goplus/gop/ast"
-	goptoken "github.com/goplus/gop/token"
-	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/gop/goputil"
+	"github.com/goplus/goxlsw/internal/analysis/ast/inspector"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_documentHighlight
-func (s *Server) textDocumentDocumentHighlight(params *DocumentHighlightParams) (*[]DocumentHighlight, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentDocumentHighlight(ctx context.Context, params *DocumentHighlightParams) (*[]DocumentHighlight, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -26,130 +28,69 @@ func (s *Server) textDocumentDocumentHighlight(params *DocumentHighlightParams)
 	}
 
 	var highlights []DocumentHighlight
-	gopast.Inspect(astFile, func(node gopast.Node) bool {
-		if node == nil {
-			return true
-		}
-		ident, ok := node.(*gopast.Ident)
-		if !ok {
-			return true
-		}
-		obj := typeInfo.ObjectOf(ident)
-		if obj != targetObj {
-			return true
-		}
-		path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
-		if len(path) < 2 {
-			return true
+	for ident := range inspector.All[*gopast.Ident](result.inspector(astFile)) {
+		if typeInfo.ObjectOf(ident) != targetObj {
+			continue
 		}
 
-		kind := Text
-
-		for _, parent := range slices.Backward(path[:len(path)-1]) {
-			switch p := parent.(type) {
-			case *gopast.ValueSpec:
-				for _, name := range p.Names {
-					if name == ident {
-						kind = Write
-						break
-					}
-				}
-			case *gopast.Field:
-				if p.Names != nil {
-					for _, name := range p.Names {
-						if name == ident {
-							kind = Write
-							break
-						}
-					}
-				}
-			case *gopast.FuncDecl:
-				if p.Name == ident {
-					kind = Write
-				}
-			case *gopast.TypeSpec:
-				if p.Name == ident {
-					kind = Write
-				}
-			case *gopast.LabeledStmt:
-				if p.Label == ident {
-					kind = Write
-				}
-			case *gopast.AssignStmt:
-				switch p.Tok {
-				case goptoken.ASSIGN:
-					for _, lhs := range p.Lhs {
-						if lhs == ident {
-							kind = Write
-							break
-						}
-					}
-					if kind != Write {
-						for _, rhs := range p.Rhs {
-							if rhs == ident {
-								kind = Read
-								break
-							}
-						}
-					}
-				case goptoken.DEFINE:
-					for _, lhs := range p.Lhs {
-						if lhs == ident {
-							kind = Write
-							break
-						}
-					}
-				default:
-					kind = Write
-				}
-			case *gopast.IncDecStmt:
-				if p.X == ident {
-					kind = Write
-				}
-			case *gopast.RangeStmt:
-				if p.X == ident {
-					kind = Read
-				} else if p.Key == ident || p.Value == ident {
-					kind = Write
-				}
-			case *gopast.TypeSwitchStmt:
-				if p.Assign != nil {
-					if assign, ok := p.Assign.(*gopast.AssignStmt); ok {
-						for _, lhs := range assign.Lhs {
-							if lhs == ident {
-								kind = Write
-								break
-							}
-						}
-					}
-				}
-			case *gopast.BinaryExpr,
-				*gopast.UnaryExpr,
-				*gopast.CallExpr,
-				*gopast.CompositeLit,
-				*gopast.IndexExpr,
-				*gopast.ReturnStmt,
-				*gopast.SendStmt:
-				kind = Read
-			case *gopast.KeyValueExpr:
-				if p.Key == ident || p.Value == ident {
-					kind = Read
-				}
-			case *gopast.SelectorExpr:
-				if p.X == ident {
-					kind = Read
-				}
-			}
-			if kind != Text {
-				break
-			}
+		kind := Read
+		if typeInfo.Defs[ident] == targetObj || isAssignedIdent(result.proj, ident) {
+			kind = Write
 		}
-
 		highlights = append(highlights, DocumentHighlight{
 			Range: result.rangeForNode(ident),
 			Kind:  kind,
 		})
-		return true
-	})
+	}
 	return &highlights, nil
 }
+
+// isAssignedIdent reports whether the given identifier is assigned a new value,
+// e.g. the left-hand side of an assignment or the operand of an inc/dec
+// statement. Assignments to its fields or elements, e.g. p.X = 1 or
+// s[i]++, count as well.
+func isAssignedIdent(proj *gop.Project, ident *gopast.Ident) bool {
+	return isWrittenIdent(proj, ident, true)
+}
+
+// isReassignedIdent is like [isAssignedIdent], but only reports assignments
+// of a new value to the identifier itself.
+func isReassignedIdent(proj *gop.Project, ident *gopast.Ident) bool {
+	return isWrittenIdent(proj, ident, false)
+}
+
+// isWrittenIdent reports whether the given identifier is assigned, and if
+// parts is true, whether its fields or elements are.
+func isWrittenIdent(proj *gop.Project, ident *gopast.Ident, parts bool) bool {
+	path := goputil.NodePath(proj, ident)
+	if len(path) == 0 {
+		return false
+	}
+	var expr gopast.Node = ident
+	for _, parent := range path[1:] {
+		switch p := parent.(type) {
+		case *gopast.ParenExpr:
+			expr = p
+			continue
+		case *gopast.SelectorExpr:
+			// The selected field is written along with the selector.
+			if p.Sel == expr || (parts && p.X == expr) {
+				expr = p
+				continue
+			}
+		case *gopast.IndexExpr:
+			if parts && p.X == expr {
+				expr = p
+				continue
+			}
+		case *gopast.AssignStmt:
+			return slices.Contains(p.Lhs, expr.(gopast.Expr))
+		case *gopast.IncDecStmt:
+			return p.X == expr
+		case *gopast.RangeStmt:
+			return p.Key == expr || p.Value == expr
+		}
+		return false
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mySpriteHighlights, err := s.textDocumentDocumentHighlight(&DocumentHighlightParams{
+		mySpriteHighlights, err := s.textDocumentDocumentHighlight(context.Background(), &DocumentHighlightParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -51,7 +52,7 @@ onStart => {
 			Kind: Read,
 		})
 
-		leftHighlights, err := s.textDocumentDocumentHighlight(&DocumentHighlightParams{
+		leftHighlights, err := s.textDocumentDocumentHighlight(context.Background(), &DocumentHighlightParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 14},
@@ -68,4 +69,90 @@ onStart => {
 			Kind: Read,
 		})
 	})
+
+	t.Run("ReadWrite", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var count int
+
+func inc() {
+	count++
+	count = count + 1
+	count += 2
+	echo count
+	for count = range 3 {
+	}
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		countHighlights, err := s.textDocumentDocumentHighlight(context.Background(), &DocumentHighlightParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 4},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, countHighlights)
+		assert.ElementsMatch(t, []DocumentHighlight{
+			{Range: Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 9}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 4, Character: 1}, End: Position{Line: 4, Character: 6}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 5, Character: 1}, End: Position{Line: 5, Character: 6}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 5, Character: 9}, End: Position{Line: 5, Character: 14}}, Kind: Read},
+			{Range: Range{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 6}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 7, Character: 6}, End: Position{Line: 7, Character: 11}}, Kind: Read},
+			{Range: Range{Start: Position{Line: 8, Character: 5}, End: Position{Line: 8, Character: 10}}, Kind: Write},
+		}, *countHighlights)
+	})
+
+	t.Run("FieldAndElementWrites", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+type P struct {
+	X int
+}
+
+func f() {
+	p := P{}
+	p.X = 1
+	p.X++
+	echo p.X
+	s := []int{1}
+	s[0] = 2
+	echo s[0]
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		highlights := func(line, character uint32) []DocumentHighlight {
+			hs, err := s.textDocumentDocumentHighlight(context.Background(), &DocumentHighlightParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+					Position:     Position{Line: line, Character: character},
+				},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, hs)
+			return *hs
+		}
+		assert.ElementsMatch(t, []DocumentHighlight{
+			{Range: Range{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 7, Character: 1}, End: Position{Line: 7, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 8, Character: 1}, End: Position{Line: 8, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 9, Character: 6}, End: Position{Line: 9, Character: 7}}, Kind: Read},
+		}, highlights(6, 1))
+		assert.ElementsMatch(t, []DocumentHighlight{
+			{Range: Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 7, Character: 3}, End: Position{Line: 7, Character: 4}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 8, Character: 3}, End: Position{Line: 8, Character: 4}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 9, Character: 8}, End: Position{Line: 9, Character: 9}}, Kind: Read},
+		}, highlights(7, 3))
+		assert.ElementsMatch(t, []DocumentHighlight{
+			{Range: Range{Start: Position{Line: 10, Character: 1}, End: Position{Line: 10, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 11, Character: 1}, End: Position{Line: 11, Character: 2}}, Kind: Write},
+			{Range: Range{Start: Position{Line: 12, Character: 6}, End: Position{Line: 12, Character: 7}}, Kind: Read},
+		}, highlights(10, 1))
+	})
 }
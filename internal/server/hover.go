@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"go/doc"
+	"go/doc/comment"
+	"go/types"
 	"strings"
+	"time"
+
+	"github.com/goplus/goxlsw/gop/goputil"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_hover
-func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentHover(ctx context.Context, params *HoverParams) (*Hover, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -15,14 +22,12 @@ func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
 		return nil, nil
 	}
 	position := result.toPosition(astFile, params.Position)
+	markdown := s.getClientCapabilities().markdownHover
 
 	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil {
 		return &Hover{
-			Contents: MarkupContent{
-				Kind:  Markdown,
-				Value: spxResourceRef.ID.URI().HTML(),
-			},
-			Range: result.rangeForNode(spxResourceRef.Node),
+			Contents: hoverContent(markdown, s.spxResourceHoverContent(result, spxResourceRef.ID, markdown)),
+			Range:    result.rangeForNode(spxResourceRef.Node),
 		}, nil
 	}
 
@@ -33,11 +38,8 @@ func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
 		rpkg := result.spxImportsAtASTFilePosition(astFile, position)
 		if rpkg != nil {
 			return &Hover{
-				Contents: MarkupContent{
-					Kind:  Markdown,
-					Value: doc.Synopsis(rpkg.Pkg.Doc),
-				},
-				Range: result.rangeForNode(rpkg.Node),
+				Contents: hoverContent(markdown, doc.Synopsis(rpkg.Pkg.Doc)),
+				Range:    result.rangeForNode(rpkg.Node),
 			}, nil
 		}
 		return nil, nil
@@ -48,15 +50,138 @@ func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
 		return nil, nil
 	}
 
-	var hoverContent strings.Builder
-	for _, spxDef := range spxDefs {
-		hoverContent.WriteString(spxDef.HTML())
+	// List the whole overload set of a resolved Go+ overloaded function.
+	if fun, ok := getTypeInfo(result.proj).ObjectOf(ident).(*types.Func); ok {
+		if overloads, index := goputil.OverloadsOf(fun); index >= 0 && len(overloads) > 1 {
+			selectorTypeName := result.selectorTypeNameForIdent(ident)
+			spxDefs = spxDefs[:0:0]
+			for _, overload := range overloads {
+				spxDefs = append(spxDefs, result.spxDefinitionsFor(overload, selectorTypeName)...)
+			}
+		}
+	}
+
+	var content strings.Builder
+	for i, spxDef := range spxDefs {
+		if markdown {
+			spxDef.Detail = renderDocMarkdown(spxDef.Detail)
+			content.WriteString(spxDef.HTML())
+			continue
+		}
+		if i > 0 {
+			content.WriteString("\n")
+		}
+		content.WriteString(spxDef.Overview + "\n")
+		if spxDef.Detail != "" {
+			content.WriteString("\n" + renderDocText(spxDef.Detail))
+		}
 	}
 	return &Hover{
-		Contents: MarkupContent{
-			Kind:  Markdown,
-			Value: hoverContent.String(),
-		},
-		Range: result.rangeForNode(ident),
+		Contents: hoverContent(markdown, content.String()),
+		Range:    result.rangeForNode(ident),
 	}, nil
 }
+
+// hoverContent returns the hover content of the given value, which is in
+// Markdown if markdown is true, and in plain text otherwise, see
+// [clientCapabilities.markdownHover].
+func hoverContent(markdown bool, value string) MarkupContent {
+	if markdown {
+		return MarkupContent{Kind: Markdown, Value: value}
+	}
+	return MarkupContent{Kind: PlainText, Value: value}
+}
+
+// renderDocText renders the given Go doc comment text as plain text.
+func renderDocText(text string) string {
+	var parser comment.Parser
+	var printer comment.Printer
+	return string(printer.Text(parser.Parse(text)))
+}
+
+// renderDocMarkdown renders the given Go doc comment text as Markdown. Doc
+// links like `[strings.ToUpper]` link to pkg.go.dev.
+func renderDocMarkdown(text string) string {
+	var parser comment.Parser
+	printer := comment.Printer{DocLinkBaseURL: "https://pkg.go.dev"}
+	return string(printer.Markdown(parser.Parse(text)))
+}
+
+// spxResourceHoverContent returns the hover content of the spx resource
+// identified by id. Besides the resource preview, it includes the resource
+// kind and URI, the sound duration or image size read from the resource
+// metadata and files, the rotation center of costumes, and a Markdown image of
+// the image resource so capable clients can render a thumbnail. Unless
+// markdown is true, it is in plain text, without the preview and the image.
+func (s *Server) spxResourceHoverContent(result *compileResult, id SpxResourceID, markdown bool) string {
+	var (
+		duration  time.Duration
+		stageSize string
+	)
+	switch id := id.(type) {
+	case SpxSoundResourceID:
+		if sound := result.spxResourceSet.Sound(id.SoundName); sound != nil {
+			duration = sound.Duration()
+		}
+	case SpxStageResourceID:
+		if stage := result.spxResourceSet.Stage(); stage != nil && stage.Map.Width > 0 && stage.Map.Height > 0 {
+			stageSize = fmt.Sprintf("%d × %d", stage.Map.Width, stage.Map.Height)
+		}
+	}
+
+	var sb strings.Builder
+	if markdown {
+		sb.WriteString(id.URI().HTML())
+		fmt.Fprintf(&sb, "**%s** `%s`\n", spxResourceKind(id), id.URI())
+	} else {
+		fmt.Fprintf(&sb, "%s %s\n", spxResourceKind(id), id.URI())
+	}
+	if stageSize != "" {
+		fmt.Fprintf(&sb, "\nSize: %s\n", stageSize)
+	}
+	if duration > 0 {
+		fmt.Fprintf(&sb, "\nDuration: %s\n", duration.Round(time.Millisecond))
+	}
+	if image := spxResourceImageOf(result, id); image != nil {
+		if width, height, ok := image.size(result.proj); ok {
+			fmt.Fprintf(&sb, "\nSize: %d × %d\n", width, height)
+		}
+		if _, ok := id.(SpxSpriteCostumeResourceID); ok {
+			x, y := image.pivot()
+			fmt.Fprintf(&sb, "\nPivot: (%g, %g)\n", x, y)
+			if image.faceRight != 0 {
+				fmt.Fprintf(&sb, "\nFace right: %g°\n", image.faceRight)
+			}
+		}
+		if markdown {
+			fmt.Fprintf(&sb, "\n![%s](%s)\n", image.name, s.toDocumentURI(image.path))
+		}
+	}
+	return sb.String()
+}
+
+// spxResourceKind returns the human-readable kind of the spx resource
+// identified by id, e.g., "Costume".
+func spxResourceKind(id SpxResourceID) string {
+	switch id.(type) {
+	case SpxBackdropResourceID:
+		return "Backdrop"
+	case SpxSoundResourceID:
+		return "Sound"
+	case SpxSpriteResourceID:
+		return "Sprite"
+	case SpxSpriteCostumeResourceID:
+		return "Costume"
+	case SpxSpriteAnimationResourceID:
+		return "Animation"
+	case SpxWidgetResourceID:
+		return "Widget"
+	case SpxSceneResourceID:
+		return "Scene"
+	case SpxFontResourceID:
+		return "Font"
+	case SpxStageResourceID:
+		return "Stage"
+	}
+	return "Resource"
+}
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,7 +73,7 @@ onTouchStart ["MySprite"], => {}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mySoundHover, err := s.textDocumentHover(&HoverParams{
+		mySoundHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 7, Character: 1},
@@ -80,7 +84,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sounds/MySound\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sounds/MySound\" />\n**Sound** `spx://resources/sounds/MySound`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 7, Character: 1},
@@ -88,7 +92,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySoundHover)
 
-		mySpriteHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 8, Character: 1},
@@ -99,7 +103,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n**Sprite** `spx://resources/sprites/MySprite`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 8, Character: 1},
@@ -107,7 +111,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteHover)
 
-		varHover, err := s.textDocumentHover(&HoverParams{
+		varHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 11, Character: 1},
@@ -126,7 +130,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, varHover)
 
-		constHover, err := s.textDocumentHover(&HoverParams{
+		constHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 17, Character: 6},
@@ -145,7 +149,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, constHover)
 
-		funcHover, err := s.textDocumentHover(&HoverParams{
+		funcHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 20, Character: 5},
@@ -164,7 +168,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, funcHover)
 
-		typeHover, err := s.textDocumentHover(&HoverParams{
+		typeHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 25, Character: 5},
@@ -183,7 +187,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, typeHover)
 
-		typeFieldHover, err := s.textDocumentHover(&HoverParams{
+		typeFieldHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 27, Character: 1},
@@ -202,7 +206,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, typeFieldHover)
 
-		pkgHover, err := s.textDocumentHover(&HoverParams{
+		pkgHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 33, Character: 0},
@@ -215,7 +219,7 @@ onTouchStart ["MySprite"], => {}
 			End:   Position{Line: 33, Character: 3},
 		}, pkgHover.Range)
 
-		pkgFuncHover, err := s.textDocumentHover(&HoverParams{
+		pkgFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 33, Character: 4},
@@ -228,7 +232,7 @@ onTouchStart ["MySprite"], => {}
 			End:   Position{Line: 33, Character: 11},
 		}, pkgFuncHover.Range)
 
-		builtinFuncHover, err := s.textDocumentHover(&HoverParams{
+		builtinFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 33, Character: 12},
@@ -239,7 +243,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<definition-item def-id=\"gop:builtin?int8\" overview=\"type int8\">\nint8 is the set of all signed 8-bit integers.\nRange: -128 through 127.\n</definition-item>\n",
+				Value: "<definition-item def-id=\"gop:builtin?int8\" overview=\"type int8\">\nint8 is the set of all signed 8-bit integers. Range: -128 through 127.\n</definition-item>\n",
 			},
 			Range: Range{
 				Start: Position{Line: 33, Character: 12},
@@ -247,7 +251,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, builtinFuncHover)
 
-		mySoundRefHover, err := s.textDocumentHover(&HoverParams{
+		mySoundRefHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 35, Character: 5},
@@ -258,7 +262,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sounds/MySound\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sounds/MySound\" />\n**Sound** `spx://resources/sounds/MySound`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 35, Character: 5},
@@ -266,7 +270,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySoundRefHover)
 
-		mySpriteRefHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteRefHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 36, Character: 0},
@@ -277,7 +281,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n**Sprite** `spx://resources/sprites/MySprite`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 36, Character: 0},
@@ -285,7 +289,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteRefHover)
 
-		mySpriteCostumeRefHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteCostumeRefHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 37, Character: 20},
@@ -296,7 +300,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" />\n**Costume** `spx://resources/sprites/MySprite/costumes/costume1`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 37, Character: 20},
@@ -304,7 +308,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteCostumeRefHover)
 
-		mySpriteSetCostumeFuncHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteSetCostumeFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 37, Character: 9},
@@ -317,7 +321,7 @@ onTouchStart ["MySprite"], => {}
 			End:   Position{Line: 37, Character: 19},
 		}, mySpriteSetCostumeFuncHover.Range)
 
-		GameOnClickHover, err := s.textDocumentHover(&HoverParams{
+		GameOnClickHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 38, Character: 5},
@@ -336,7 +340,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, GameOnClickHover)
 
-		mainSpxOnClickHover, err := s.textDocumentHover(&HoverParams{
+		mainSpxOnClickHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 39, Character: 0},
@@ -355,7 +359,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mainSpxOnClickHover)
 
-		mainSpxOnHover, err := s.textDocumentHover(&HoverParams{
+		mainSpxOnHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 40, Character: 0},
@@ -369,7 +373,7 @@ onTouchStart ["MySprite"], => {}
 			End:   Position{Line: 40, Character: 2},
 		}, mainSpxOnHover.Range)
 
-		mySpriteOnClickFuncHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteOnClickFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 1, Character: 9},
@@ -388,7 +392,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteOnClickFuncHover)
 
-		mySpriteSpxOnClickFuncHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteSpxOnClickFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 0},
@@ -407,7 +411,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteSpxOnClickFuncHover)
 
-		mySpriteCloneFuncHover, err := s.textDocumentHover(&HoverParams{
+		mySpriteCloneFuncHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 5, Character: 1},
@@ -417,8 +421,9 @@ onTouchStart ["MySprite"], => {}
 		require.NotNil(t, mySpriteCloneFuncHover)
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
-				Kind:  Markdown,
-				Value: "<definition-item def-id=\"gop:github.com/goplus/spx?Sprite.clone#0\" overview=\"func clone()\">\n</definition-item>\n",
+				Kind: Markdown,
+				Value: "<definition-item def-id=\"gop:github.com/goplus/spx?Sprite.clone#0\" overview=\"func clone()\">\n</definition-item>\n" +
+					"<definition-item def-id=\"gop:github.com/goplus/spx?Sprite.clone#1\" overview=\"func clone(data interface{})\">\n</definition-item>\n",
 			},
 			Range: Range{
 				Start: Position{Line: 5, Character: 1},
@@ -426,7 +431,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, mySpriteCloneFuncHover)
 
-		imagePointFieldHover, err := s.textDocumentHover(&HoverParams{
+		imagePointFieldHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 6, Character: 12},
@@ -445,7 +450,7 @@ onTouchStart ["MySprite"], => {}
 			},
 		}, imagePointFieldHover)
 
-		onTouchStartFirstArgHover, err := s.textDocumentHover(&HoverParams{
+		onTouchStartFirstArgHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 8, Character: 14},
@@ -456,7 +461,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n**Sprite** `spx://resources/sprites/MySprite`\n",
 			},
 			Range: Range{
 				Start: Position{Line: 8, Character: 14},
@@ -471,7 +476,7 @@ onTouchStart ["MySprite"], => {}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		hover, err := s.textDocumentHover(&HoverParams{
+		hover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 99, Character: 99},
@@ -499,7 +504,7 @@ fmt.Println("Hello, World!")
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		importHover, err := s.textDocumentHover(&HoverParams{
+		importHover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -519,6 +524,94 @@ fmt.Println("Hello, World!")
 		}, importHover)
 	})
 
+	t.Run("FormattedDoc", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+import "strings"
+
+// shout returns s in upper case, see [strings.ToUpper].
+//
+// Example:
+//
+//	shout "hi"
+func shout(s string) string {
+	return strings.ToUpper(s)
+}
+
+echo shout("hi")
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		hover, err := s.textDocumentHover(context.Background(), &HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 13, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, "<definition-item def-id=\"gop:main?Game.shout\" overview=\"func shout(s string) string\">\n"+
+			"shout returns s in upper case, see [strings.ToUpper](https://pkg.go.dev/strings#ToUpper).\n\n"+
+			"Example:\n\n"+
+			"\tshout \"hi\"\n"+
+			"</definition-item>\n", hover.Contents.Value)
+	})
+
+	t.Run("SpxResourceMetadata", func(t *testing.T) {
+		var costumePNG bytes.Buffer
+		require.NoError(t, png.Encode(&costumePNG, image.NewRGBA(image.Rect(0, 0, 120, 80))))
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+play "MySound"
+MySprite.setCostume "costume1"
+startBackdrop "backdrop1"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                         []byte(``),
+			"assets/index.json":                    []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.svg"}]}`),
+			"assets/backdrop1.svg":                 []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 480 360"></svg>`),
+			"assets/sprites/MySprite/index.json":   []byte(`{"costumes":[{"name":"costume1","path":"costume1.png","bitmapResolution":2,"x":60,"y":41,"faceRight":90}]}`),
+			"assets/sprites/MySprite/costume1.png": costumePNG.Bytes(),
+			"assets/sounds/MySound/index.json":     []byte(`{"path":"MySound.wav","rate":44100,"sampleCount":66150}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		hoverAt := func(position Position) string {
+			hover, err := s.textDocumentHover(context.Background(), &HoverParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+					Position:     position,
+				},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, hover)
+			return hover.Contents.Value
+		}
+
+		assert.Equal(t, "<resource-preview resource=\"spx://resources/sounds/MySound\" />\n"+
+			"**Sound** `spx://resources/sounds/MySound`\n\n"+
+			"Duration: 1.5s\n", hoverAt(Position{Line: 5, Character: 6}))
+		assert.Equal(t, "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" />\n"+
+			"**Costume** `spx://resources/sprites/MySprite/costumes/costume1`\n\n"+
+			"Size: 60 × 40\n\n"+
+			"Pivot: (30, 20.5)\n\n"+
+			"Face right: 90°\n\n"+
+			"![costume1](file:///assets/sprites/MySprite/costume1.png)\n", hoverAt(Position{Line: 6, Character: 22}))
+		assert.Equal(t, "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n"+
+			"**Sprite** `spx://resources/sprites/MySprite`\n\n"+
+			"Size: 60 × 40\n\n"+
+			"![MySprite](file:///assets/sprites/MySprite/costume1.png)\n", hoverAt(Position{Line: 6, Character: 1}))
+		assert.Equal(t, "<resource-preview resource=\"spx://resources/backdrops/backdrop1\" />\n"+
+			"**Backdrop** `spx://resources/backdrops/backdrop1`\n\n"+
+			"Size: 480 × 360\n\n"+
+			"![backdrop1](file:///assets/backdrop1.svg)\n", hoverAt(Position{Line: 7, Character: 16}))
+	})
+
 	t.Run("Append", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -528,7 +621,7 @@ nums = append(nums, 1)
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		hover, err := s.textDocumentHover(&HoverParams{
+		hover, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 7},
@@ -552,7 +645,7 @@ echo num
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		hover1, err := s.textDocumentHover(&HoverParams{
+		hover1, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 8},
@@ -566,7 +659,7 @@ echo num
 			End:   Position{Line: 1, Character: 14},
 		}, hover1.Range)
 
-		hover2, err := s.textDocumentHover(&HoverParams{
+		hover2, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 0},
@@ -593,7 +686,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		hover1, err := s.textDocumentHover(&HoverParams{
+		hover1, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 15},
@@ -607,7 +700,7 @@ onStart => {
 			End:   Position{Line: 3, Character: 15},
 		}, hover1.Range)
 
-		hover2, err := s.textDocumentHover(&HoverParams{
+		hover2, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 18},
@@ -616,7 +709,7 @@ onStart => {
 		require.NoError(t, err)
 		require.Nil(t, hover2)
 
-		hover3, err := s.textDocumentHover(&HoverParams{
+		hover3, err := s.textDocumentHover(context.Background(), &HoverParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 18},
@@ -1,10 +1,13 @@
 package server
 
-import "go/types"
+import (
+	"context"
+	"go/types"
+)
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_implementation
-func (s *Server) textDocumentImplementation(params *ImplementationParams) (any, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentImplementation(ctx context.Context, params *ImplementationParams) (any, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -19,9 +22,17 @@ func (s *Server) textDocumentImplementation(params *ImplementationParams) (any,
 		return nil, nil
 	}
 
-	if method, ok := obj.(*types.Func); ok && method.Type().(*types.Signature).Recv() != nil {
-		if recv := method.Type().(*types.Signature).Recv().Type(); types.IsInterface(recv) {
-			locations := s.findImplementingMethodDefinitions(result, recv.(*types.Interface), method.Name())
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			if iface, ok := recv.Type().Underlying().(*types.Interface); ok {
+				locations := s.findImplementingMethodDefinitions(result, iface, obj.Name())
+				return deduplicateLocations(locations), nil
+			}
+		}
+	case *types.TypeName:
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			locations := s.findImplementingTypeDefinitions(result, iface)
 			return deduplicateLocations(locations), nil
 		}
 	}
@@ -33,27 +44,37 @@ func (s *Server) textDocumentImplementation(params *ImplementationParams) (any,
 // methods that implement the given interface method.
 func (s *Server) findImplementingMethodDefinitions(result *compileResult, iface *types.Interface, methodName string) []Location {
 	var implementations []Location
-	typeInfo := getTypeInfo(result.proj)
-	for _, obj := range typeInfo.Defs {
-		if obj == nil {
+	for _, named := range result.mainPkgNamedTypes() {
+		if types.IsInterface(named) || !implementsInterface(named, iface) {
 			continue
 		}
-		named, ok := obj.Type().(*types.Named)
-		if !ok {
+
+		method, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, named.Obj().Pkg(), methodName)
+		if method == nil || !result.isInFset(method.Pos()) {
 			continue
 		}
-		if !types.Implements(named, iface) {
+		implementations = append(implementations, result.locationForPos(method.Pos()))
+	}
+	return implementations
+}
+
+// findImplementingTypeDefinitions finds the definition locations of all types
+// that implement the given interface, including Go+ class file types.
+func (s *Server) findImplementingTypeDefinitions(result *compileResult, iface *types.Interface) []Location {
+	var implementations []Location
+	for _, named := range result.mainPkgNamedTypes() {
+		if types.IsInterface(named) || !implementsInterface(named, iface) {
 			continue
 		}
-
-		for i := range named.NumMethods() {
-			method := named.Method(i)
-			if method.Name() != methodName {
-				continue
-			}
-
-			implementations = append(implementations, result.locationForPos(method.Pos()))
+		if loc, ok := result.locationForNamedType(named); ok {
+			implementations = append(implementations, loc)
 		}
 	}
 	return implementations
 }
+
+// implementsInterface reports whether the given named type, or a pointer to
+// it, implements the given interface.
+func implementsInterface(named *types.Named, iface *types.Interface) bool {
+	return types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface)
+}
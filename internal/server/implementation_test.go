@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,7 +29,7 @@ var x MyInterface
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		implementations, err := s.textDocumentImplementation(&ImplementationParams{
+		implementations, err := s.textDocumentImplementation(context.Background(), &ImplementationParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -55,6 +56,76 @@ var x MyInterface
 		})
 	})
 
+	t.Run("SpriteClass", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+type Greeter interface {
+	greet()
+}
+
+type MyType struct{}
+
+func (t *MyType) greet() {}
+
+var (
+	MySprite MySprite
+)
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func greet() {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		methodImplementations, err := s.textDocumentImplementation(context.Background(), &ImplementationParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		require.IsType(t, []Location{}, methodImplementations)
+		methodLocations := methodImplementations.([]Location)
+		require.Len(t, methodLocations, 2)
+		assert.Contains(t, methodLocations, Location{
+			URI: "file:///main.spx",
+			Range: Range{
+				Start: Position{Line: 7, Character: 17},
+				End:   Position{Line: 7, Character: 17},
+			},
+		})
+		assert.Contains(t, methodLocations, Location{
+			URI: "file:///MySprite.spx",
+			Range: Range{
+				Start: Position{Line: 1, Character: 5},
+				End:   Position{Line: 1, Character: 5},
+			},
+		})
+
+		typeImplementations, err := s.textDocumentImplementation(context.Background(), &ImplementationParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.IsType(t, []Location{}, typeImplementations)
+		typeLocations := typeImplementations.([]Location)
+		require.Len(t, typeLocations, 2)
+		assert.Contains(t, typeLocations, Location{
+			URI: "file:///main.spx",
+			Range: Range{
+				Start: Position{Line: 5, Character: 5},
+				End:   Position{Line: 5, Character: 5},
+			},
+		})
+		assert.Contains(t, typeLocations, Location{URI: "file:///MySprite.spx"})
+	})
+
 	t.Run("NonInterfaceMethod", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -65,7 +136,7 @@ func (t MyType) myMethod() {}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		implementation, err := s.textDocumentImplementation(&ImplementationParams{
+		implementation, err := s.textDocumentImplementation(context.Background(), &ImplementationParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 16},
@@ -92,7 +163,7 @@ type MyType struct{}
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		implementation, err := s.textDocumentImplementation(&ImplementationParams{
+		implementation, err := s.textDocumentImplementation(context.Background(), &ImplementationParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 99, Character: 99},
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// indexDelay is the delay after the last workspace change before the
+// workspace is indexed in the background. It is much shorter than
+// [diagnosticDelay], so that open documents are ready as soon as possible.
+const indexDelay = 20 * time.Millisecond
+
+// indexProgress represents the progress of indexing a snapshot of the
+// workspace.
+type indexProgress struct {
+	snapshot *vfs.MapFS
+	files    int // number of spx files
+
+	parsedFiles atomic.Int32 // number of spx files parsed so far
+	done        atomic.Bool  // whether the snapshot is fully indexed
+}
+
+// scheduleWorkspaceChecks schedules indexing the workspace in the background
+// and republishing diagnostics once changes settle.
+func (s *Server) scheduleWorkspaceChecks() {
+	s.indexScheduler.schedule()
+	s.diagnosticScheduler.schedule()
+}
+
+// indexWorkspace parses, type-checks and analyzes the latest snapshot of the
+// workspace and builds its workspace symbol index in the background, so that
+// requests, including workspace diagnostics and symbol search, find the
+// results in the caches of the snapshot instead of computing them first.
+//
+// Open documents are parsed right away, as they are the most likely to be
+// requested. Everything else runs at low priority: each step waits until no
+// call from the client is in flight, so that interactive requests are never
+// slowed down by the indexing. Indexing stops as soon as ctx is done, which
+// happens once the workspace changes again.
+func (s *Server) indexWorkspace(ctx context.Context) {
+	snapshot := s.snapshot()
+	spxFiles, err := vfs.ListSpxFiles(snapshot)
+	if err != nil {
+		return
+	}
+	openFiles := s.openDocumentPaths()
+	isOpen := func(spxFile string) bool {
+		_, found := slices.BinarySearch(openFiles, spxFile)
+		return found
+	}
+	slices.SortFunc(spxFiles, func(a, b string) int {
+		switch aOpen, bOpen := isOpen(a), isOpen(b); {
+		case aOpen && !bOpen:
+			return -1
+		case !aOpen && bOpen:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	progress := &indexProgress{snapshot: snapshot, files: len(spxFiles)}
+	s.indexProgress.Store(progress)
+
+	for _, spxFile := range spxFiles {
+		if !isOpen(spxFile) {
+			if err := s.waitForNoCalls(ctx); err != nil {
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+		// Parse errors are reported by the compilation below.
+		_, _ = snapshot.AST(spxFile)
+		progress.parsedFiles.Add(1)
+	}
+
+	// Type checking and analyzing happen during the compilation, whose
+	// results are cached by the snapshot and the analysis driver.
+	if err := s.waitForNoCalls(ctx); err != nil {
+		return
+	}
+	if _, err := s.compileAt(ctx, snapshot, nil); err != nil {
+		return
+	}
+
+	if err := s.waitForNoCalls(ctx); err != nil {
+		return
+	}
+	_, _ = getWorkspaceSymbolIndex(snapshot)
+	progress.done.Store(true)
+}
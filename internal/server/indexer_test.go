@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerIndexWorkspace(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`onStart => {}`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+	newServer := func(t *testing.T) (*Server, *atomic.Int32) {
+		s := New(newMapFSWithoutModTime(m), &recordingReplier{}, fileMapGetter(m))
+		initializeServer(t, s)
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+
+		// Count the builds of the workspace symbol index, which is the last
+		// step of indexing.
+		var builds atomic.Int32
+		s.getProj().InitCache(workspaceSymbolIndexCacheKind, func(proj *vfs.MapFS) (any, error) {
+			builds.Add(1)
+			return buildWorkspaceSymbolIndex(proj)
+		})
+		return s, &builds
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s, builds := newServer(t)
+
+		s.indexWorkspace(context.Background())
+		assert.EqualValues(t, 1, builds.Load())
+
+		// Requests use the results of indexing.
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "MySprite"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, symbols)
+		assert.EqualValues(t, 1, builds.Load())
+	})
+
+	t.Run("WaitsForCalls", func(t *testing.T) {
+		s, builds := newServer(t)
+
+		release := make(chan struct{})
+		s.runWithResponse(jsonrpc2.NewIntID(1), func(ctx context.Context) (any, error) {
+			<-release
+			return nil, nil
+		})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.indexWorkspace(context.Background())
+		}()
+		assert.Never(t, func() bool { return builds.Load() > 0 }, 50*time.Millisecond, time.Millisecond)
+
+		close(release)
+		<-done
+		assert.EqualValues(t, 1, builds.Load())
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s, builds := newServer(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.indexWorkspace(ctx)
+		assert.Zero(t, builds.Load())
+	})
+
+	t.Run("ScheduledOnChange", func(t *testing.T) {
+		s, builds := newServer(t)
+		s.indexScheduler.delay = indexDelay
+
+		n, err := jsonrpc2.NewNotification("textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: "file:///MySprite.spx", Version: 1, Text: `onStart => { echo "hi" }`},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+		require.Eventually(t, func() bool { return builds.Load() == 1 }, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"go/types"
+	"path"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_inlayHint
+func (s *Server) textDocumentInlayHint(ctx context.Context, params *InlayHintParams) ([]InlayHint, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	rangeStart := result.posAt(astFile, params.Range.Start)
+	rangeEnd := result.posAt(astFile, params.Range.End)
+
+	var (
+		spriteName string
+		spriteType types.Type
+	)
+	if spxFile != result.mainSpxFile {
+		spriteName = strings.TrimSuffix(path.Base(spxFile), ".spx")
+		if obj, ok := getPkg(result.proj).Scope().Lookup(spriteName).(*types.TypeName); ok {
+			spriteType = obj.Type()
+		}
+	}
+
+	typeInfo := getTypeInfo(result.proj)
+	var hints []InlayHint
+	addVarTypeHint := func(ident *gopast.Ident) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		obj, ok := typeInfo.Defs[ident].(*types.Var)
+		if !ok || obj.Type() == nil || obj.Type() == types.Typ[types.Invalid] {
+			return
+		}
+		hints = append(hints, InlayHint{
+			Position:    result.fromPosition(astFile, result.proj.Fset.Position(ident.End())),
+			Label:       []InlayHintLabelPart{{Value: getSimplifiedTypeString(obj.Type())}},
+			Kind:        TypeInlayHint,
+			PaddingLeft: true,
+		})
+	}
+
+	selectorIdents := make(map[*gopast.Ident]struct{})
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if node == nil {
+			return true
+		}
+		if node.End() < rangeStart || node.Pos() > rangeEnd {
+			return false
+		}
+
+		switch node := node.(type) {
+		case *gopast.AssignStmt:
+			if node.Tok != goptoken.DEFINE {
+				return true
+			}
+			for _, lhs := range node.Lhs {
+				ident, ok := lhs.(*gopast.Ident)
+				if !ok {
+					continue
+				}
+				addVarTypeHint(ident)
+			}
+		case *gopast.ValueSpec:
+			if node.Type != nil || len(node.Values) == 0 {
+				return true
+			}
+			for _, name := range node.Names {
+				addVarTypeHint(name)
+			}
+		case *gopast.RangeStmt:
+			if node.Tok != goptoken.DEFINE {
+				return true
+			}
+			if ident, ok := node.Key.(*gopast.Ident); ok {
+				addVarTypeHint(ident)
+			}
+			if ident, ok := node.Value.(*gopast.Ident); ok {
+				addVarTypeHint(ident)
+			}
+		case *gopast.SelectorExpr:
+			selectorIdents[node.Sel] = struct{}{}
+		case *gopast.Ident:
+			if spriteType == nil {
+				return true
+			}
+			if _, ok := selectorIdents[node]; ok {
+				return true
+			}
+			if !isSpxSpriteMember(spriteType, typeInfo.Uses[node]) || !result.isInSpxEventHandler(node.Pos()) {
+				return true
+			}
+			hints = append(hints, InlayHint{
+				Position: result.fromPosition(astFile, result.proj.Fset.Position(node.Pos())),
+				Label:    []InlayHintLabelPart{{Value: spriteName + "."}},
+				Tooltip:  &OrPTooltip_textDocument_inlayHint{Value: "Implicit receiver: this sprite"},
+			})
+		}
+		return true
+	})
+	return hints, nil
+}
+
+// isSpxSpriteMember reports whether the given object is a field or method of
+// the given sprite type, which can be accessed through the implicit sprite
+// receiver. Members promoted from the game are excluded.
+func isSpxSpriteMember(spriteType types.Type, obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); !ok || sig.Recv() == nil {
+			return false
+		}
+	case *types.Var:
+		if !obj.IsField() {
+			return false
+		}
+	default:
+		return false
+	}
+
+	found, index, _ := types.LookupFieldOrMethod(spriteType, true, obj.Pkg(), obj.Name())
+	if found != obj {
+		return false
+	}
+	typ := spriteType
+	for _, i := range index[:len(index)-1] {
+		st, ok := unwrapPointerType(typ).Underlying().(*types.Struct)
+		if !ok {
+			return false
+		}
+		field := st.Field(i)
+		if named, ok := unwrapPointerType(field.Type()).(*types.Named); ok {
+			if named == GetSpxGameType() || (isMainPkgObject(named.Obj()) && named.Obj().Name() == "Game") {
+				return false
+			}
+		}
+		typ = field.Type()
+	}
+	return true
+}
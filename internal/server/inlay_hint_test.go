@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentInlayHint(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+count := 1
+var name = "foo"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	turn Right
+	MySprite.turn Left
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		mainSpxHints, err := s.textDocumentInlayHint(context.Background(), &InlayHintParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 0, Character: 0},
+				End:   Position{Line: 7, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []InlayHint{
+			{
+				Position:    Position{Line: 4, Character: 5},
+				Label:       []InlayHintLabelPart{{Value: "int"}},
+				Kind:        TypeInlayHint,
+				PaddingLeft: true,
+			},
+			{
+				Position:    Position{Line: 5, Character: 8},
+				Label:       []InlayHintLabelPart{{Value: "string"}},
+				Kind:        TypeInlayHint,
+				PaddingLeft: true,
+			},
+		}, mainSpxHints)
+
+		mySpriteHints, err := s.textDocumentInlayHint(context.Background(), &InlayHintParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+			Range: Range{
+				Start: Position{Line: 0, Character: 0},
+				End:   Position{Line: 5, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, mySpriteHints, 1)
+		assert.Equal(t, Position{Line: 2, Character: 1}, mySpriteHints[0].Position)
+		assert.Equal(t, []InlayHintLabelPart{{Value: "MySprite."}}, mySpriteHints[0].Label)
+	})
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+count := 1
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		hints, err := s.textDocumentInlayHint(context.Background(), &InlayHintParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 2, Character: 0},
+				End:   Position{Line: 3, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, hints)
+	})
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"go/types"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// refactorInlineVariable is the kind of code actions that replace the uses of
+// a local variable with its initial value and remove its declaration.
+const refactorInlineVariable = RefactorInline + ".variable"
+
+// spxInlineVariableEdits returns the edits that inline the local variable at
+// the given position of the spx source file of the given document URI. It
+// returns nil if there is no variable that can be inlined.
+func (s *Server) spxInlineVariableEdits(ctx context.Context, uri DocumentURI, position Position) ([]TextEdit, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	ident := result.identAtASTFilePosition(astFile, result.toPosition(astFile, position))
+	if ident == nil {
+		return nil, nil
+	}
+	edits := result.inlineVariable(astFile, ident)
+	if len(edits) == 0 || !result.typeChecksAfterEdits(ctx, spxFile, edits) {
+		return nil, nil
+	}
+	return edits, nil
+}
+
+// inlineVariable returns the edits that replace the uses of the local variable
+// of the given identifier with its initial value and remove its declaration.
+// It returns nil if the variable is not declared alone with an initial value
+// in a statement of its own, if it is mutated after its declaration, if the
+// objects used by its initial value are reassigned or shadowed at its uses,
+// or if evaluating the initial value at the uses instead of once may change
+// the behavior, e.g., for a call used twice or a fresh slice.
+func (r *compileResult) inlineVariable(astFile *gopast.File, ident *gopast.Ident) []TextEdit {
+	typeInfo := getTypeInfo(r.proj)
+	v, ok := typeInfo.ObjectOf(ident).(*types.Var)
+	if !ok || v.IsField() || v.Pkg() == nil || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+		return nil
+	}
+	defIdent := r.defIdentFor(v)
+	if defIdent == nil || r.nodeASTFile(defIdent) != astFile {
+		return nil
+	}
+
+	// Find the declaration of the variable.
+	var (
+		declStmt gopast.Stmt
+		value    gopast.Expr
+	)
+	path := goputil.NodePath(r.proj, defIdent)
+	switch parent := path[1].(type) {
+	case *gopast.AssignStmt:
+		if parent.Tok == goptoken.DEFINE && len(parent.Lhs) == 1 && len(parent.Rhs) == 1 {
+			declStmt, value = parent, parent.Rhs[0]
+		}
+	case *gopast.ValueSpec:
+		if len(path) > 3 && len(parent.Names) == 1 && len(parent.Values) == 1 && parent.Type == nil {
+			if genDecl, ok := path[2].(*gopast.GenDecl); ok && len(genDecl.Specs) == 1 {
+				declStmt, _ = path[3].(*gopast.DeclStmt)
+				value = parent.Values[0]
+			}
+		}
+	}
+	if declStmt == nil || value == nil {
+		return nil
+	}
+	declIndex := slices.Index(path, gopast.Node(declStmt))
+	if declIndex+1 >= len(path) {
+		return nil
+	}
+	if stmtListNode(path[declIndex+1]) == nil {
+		return nil // E.g., the init statement of an if statement.
+	}
+
+	uses := r.refIdentsFor(v)
+	if len(uses) == 0 {
+		return nil
+	}
+	slices.SortFunc(uses, func(a, b *gopast.Ident) int { return int(a.Pos() - b.Pos()) })
+	for _, use := range uses {
+		if r.isMutatedVarIdent(use) {
+			return nil
+		}
+	}
+	if len(uses) > 1 && (isFreshValue(typeInfo, value) || r.hasSideEffects(value)) {
+		return nil
+	}
+	if r.hasSideEffects(value) && !r.isEvaluatedOnceAfter(declStmt, path[declIndex+1], uses[0]) {
+		return nil // The side effects would happen later or more than once.
+	}
+
+	// Objects used by the value must refer to the same values at the uses.
+	inlinable := true
+	gopast.Inspect(value, func(node gopast.Node) bool {
+		ident, ok := node.(*gopast.Ident)
+		if !ok {
+			return true
+		}
+		obj := typeInfo.Uses[ident]
+		if obj == nil || obj.Pkg() == nil || obj.Parent() == nil || obj.Parent() == obj.Pkg().Scope() {
+			return true
+		}
+		for _, ref := range r.refIdentsFor(obj) {
+			if ref.Pos() > declStmt.End() && isAssignedIdent(r.proj, ref) {
+				inlinable = false
+			}
+		}
+		for _, use := range uses {
+			// Scopes of the ShadowEntry have no valid positions, so objects
+			// of it may not be found.
+			if scope := r.innermostScopeAt(use.Pos()); scope != nil {
+				if _, found := scope.LookupParent(ident.Name, use.Pos()); found != nil && found != obj {
+					inlinable = false
+				}
+			}
+		}
+		return inlinable
+	})
+	if !inlinable {
+		return nil
+	}
+
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	code := astFile.Code
+	valueText := string(code[tokenFile.Offset(value.Pos()):tokenFile.Offset(value.End())])
+	edits := make([]TextEdit, 0, len(uses)+1)
+
+	// Remove the declaration, with its line if nothing else is on it.
+	start, end := wholeLinesRange(code, tokenFile.Offset(declStmt.Pos()), tokenFile.Offset(declStmt.End()))
+	edits = append(edits, TextEdit{
+		Range:   r.rangeForStartEnd(astFile, tokenFile.Pos(start), tokenFile.Pos(end)),
+		NewText: "",
+	})
+
+	for _, use := range uses {
+		newText := valueText
+		usePath := goputil.NodePath(r.proj, use)
+		if len(usePath) > 1 && needsParens(value, use, usePath[1]) {
+			newText = "(" + newText + ")"
+		}
+		edits = append(edits, TextEdit{
+			Range:   r.rangeForNode(use),
+			NewText: newText,
+		})
+	}
+	return edits
+}
+
+// isFreshValue reports whether evaluating the given expression builds a new
+// value with an identity of its own, e.g., a slice, a map or a function, so
+// that evaluating it more than once is not the same as evaluating it once.
+func isFreshValue(typeInfo *typesutil.Info, expr gopast.Expr) bool {
+	switch expr := expr.(type) {
+	case *gopast.ParenExpr:
+		return isFreshValue(typeInfo, expr.X)
+	case *gopast.CompositeLit, *gopast.SliceLit, *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+		return true
+	case *gopast.UnaryExpr:
+		return expr.Op == goptoken.AND
+	case *gopast.CallExpr:
+		if ident, ok := expr.Fun.(*gopast.Ident); ok && isBuiltinObject(typeInfo.ObjectOf(ident)) {
+			switch ident.Name {
+			case "make", "new", "append":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEvaluatedOnceAfter reports whether the given use is evaluated once right
+// after the given declaration statement of the given statement list node,
+// i.e., whether it is in the next statement of the list, and not in a loop
+// or a function of it.
+func (r *compileResult) isEvaluatedOnceAfter(declStmt gopast.Stmt, listNode gopast.Node, use *gopast.Ident) bool {
+	var list []gopast.Stmt
+	switch node := stmtListNode(listNode).(type) {
+	case *gopast.BlockStmt:
+		list = node.List
+	case *gopast.CaseClause:
+		list = node.Body
+	case *gopast.CommClause:
+		list = node.Body
+	}
+	i := slices.Index(list, declStmt)
+	if i < 0 || i+1 >= len(list) {
+		return false
+	}
+	next := list[i+1]
+	for _, node := range goputil.NodePath(r.proj, use) {
+		switch node.(type) {
+		case *gopast.ForStmt, *gopast.RangeStmt, *gopast.ForPhraseStmt, *gopast.ComprehensionExpr,
+			*gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return false
+		}
+		if node == next {
+			return true
+		}
+	}
+	return false
+}
+
+// isAddressTakenIdent reports whether the address of the given identifier is
+// taken, so that the variable it refers to may be changed through a pointer.
+func isAddressTakenIdent(proj *gop.Project, ident *gopast.Ident) bool {
+	path := goputil.NodePath(proj, ident)
+	if len(path) < 2 {
+		return false
+	}
+	unary, ok := path[1].(*gopast.UnaryExpr)
+	return ok && unary.Op == goptoken.AND
+}
+
+// needsParens reports whether the given expression needs parentheses to
+// replace the given operand of the given parent node.
+func needsParens(expr, operand gopast.Expr, parent gopast.Node) bool {
+	switch expr.(type) {
+	case *gopast.BinaryExpr, *gopast.UnaryExpr, *gopast.StarExpr, *gopast.FuncLit, *gopast.CompositeLit:
+	default:
+		return false
+	}
+	switch parent := parent.(type) {
+	case *gopast.ParenExpr, *gopast.AssignStmt, *gopast.ReturnStmt, *gopast.ValueSpec,
+		*gopast.KeyValueExpr, *gopast.CompositeLit, *gopast.ExprStmt, *gopast.SendStmt:
+		return false
+	case *gopast.CallExpr:
+		return parent.Fun == operand
+	case *gopast.IndexExpr:
+		return parent.X == operand
+	}
+	return true
+}
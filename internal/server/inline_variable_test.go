@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxInlineVariable(t *testing.T) {
+	inline := func(t *testing.T, content string, position Position) (string, bool) {
+		m := map[string][]byte{"main.spx": []byte(content)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{RefactorInline}},
+		})
+		require.NoError(t, err)
+		if len(codeActions) == 0 {
+			return "", false
+		}
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Inline variable", codeActions[0].Title)
+		assert.Equal(t, refactorInlineVariable, codeActions[0].Kind)
+		require.NotNil(t, codeActions[0].Edit)
+		return applyTextEdits(m["main.spx"], codeActions[0].Edit.Changes["file:///main.spx"]), true
+	}
+
+	t.Run("Use", func(t *testing.T) {
+		got, ok := inline(t, `x := 1
+n := x + 2
+echo n
+echo 3 * n
+`, Position{Line: 2, Character: 5})
+		require.True(t, ok)
+		assert.Equal(t, `x := 1
+echo x + 2
+echo 3 * (x + 2)
+`, got)
+	})
+
+	t.Run("Declaration", func(t *testing.T) {
+		got, ok := inline(t, `onStart => {
+	var msg = "hi"
+	echo msg
+}
+`, Position{Line: 1, Character: 5})
+		require.True(t, ok)
+		assert.Equal(t, `onStart => {
+	echo "hi"
+}
+`, got)
+	})
+
+	t.Run("ExtractedVariable", func(t *testing.T) {
+		// Inlining is the inverse of extracting.
+		got, ok := inline(t, `func getScore() int {
+	return 1
+}
+
+onStart => {
+	score := getScore()
+	if score > 0 {
+		echo "win"
+	}
+}
+`, Position{Line: 5, Character: 1})
+		require.True(t, ok)
+		assert.Equal(t, `func getScore() int {
+	return 1
+}
+
+onStart => {
+	if getScore() > 0 {
+		echo "win"
+	}
+}
+`, got)
+	})
+
+	t.Run("Uninlinable", func(t *testing.T) {
+		for _, tt := range []struct {
+			name     string
+			content  string
+			position Position
+		}{
+			{
+				name: "Reassigned",
+				content: `n := 1
+n = 2
+echo n
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+			{
+				name: "ValueReassigned",
+				content: `x := 1
+n := x + 1
+x = 5
+echo n
+`,
+				position: Position{Line: 1, Character: 0},
+			},
+			{
+				name: "ValueShadowed",
+				content: `x := 1
+n := x + 1
+if true {
+	x := 2
+	echo x, n
+}
+`,
+				position: Position{Line: 1, Character: 0},
+			},
+			{
+				name: "MultipleVars",
+				content: `a, b := 1, 2
+echo a, b
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+			{
+				name: "Unused",
+				content: `n := 1
+_ = 1
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+			{
+				name: "CallUsedTwice",
+				content: `func next() int {
+	return 1
+}
+
+n := next()
+echo n, n
+`,
+				position: Position{Line: 4, Character: 0},
+			},
+			{
+				name: "CallUsedLater",
+				content: `func next() int {
+	return 1
+}
+
+n := next()
+echo "first"
+echo n
+`,
+				position: Position{Line: 4, Character: 0},
+			},
+			{
+				name: "CallUsedInLoop",
+				content: `func next() int {
+	return 1
+}
+
+n := next()
+for i := 0; i < 3; i++ {
+	echo n
+}
+`,
+				position: Position{Line: 4, Character: 0},
+			},
+			{
+				name: "FreshValueUsedTwice",
+				content: `s := []int{1, 2}
+echo s, s
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+			{
+				name: "ElementAssigned",
+				content: `s := make([]int, 3)
+s[0] = 1
+echo s
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+			{
+				name: "FieldAssigned",
+				content: `type P struct {
+	X int
+}
+
+p := P{}
+p.X = 1
+echo p
+`,
+				position: Position{Line: 4, Character: 0},
+			},
+			{
+				name: "AddressTaken",
+				content: `n := 1
+p := &n
+echo *p
+`,
+				position: Position{Line: 0, Character: 0},
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				_, ok := inline(t, tt.content, tt.position)
+				assert.False(t, ok)
+			})
+		}
+	})
+}
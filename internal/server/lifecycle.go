@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// The lifecycle of a server follows the LSP: it serves requests only once
+// initialized by the initialize request, until the shutdown request, after
+// which the client sends the exit notification. Calls out of order fail, and
+// notifications out of order, except exit, are dropped.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#lifeCycleMessages
+
+// lifecycleState is the state of the lifecycle of a server.
+type lifecycleState int32
+
+const (
+	lifecycleUninitialized lifecycleState = iota // before initialize
+	lifecycleInitialized                         // after initialize, before shutdown
+	lifecycleShutdown                            // after shutdown
+)
+
+// errServerNotInitialized is the error of calls before initialize.
+var errServerNotInitialized = jsonrpc2.NewError(-32002, "JSON RPC server not initialized")
+
+// ErrExitWithoutShutdown is returned by [Serve] if the client sent the exit
+// notification without the shutdown request first, in which case the process
+// should exit with code 1.
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// lifecycleCallError returns the error of a call of the given method in the
+// current state of the lifecycle, or nil if the call is served.
+func (s *Server) lifecycleCallError(method string) error {
+	switch lifecycleState(s.lifecycle.Load()) {
+	case lifecycleUninitialized:
+		if method != "initialize" {
+			return errServerNotInitialized
+		}
+	case lifecycleInitialized:
+		if method == "initialize" {
+			return fmt.Errorf("%w: server already initialized", jsonrpc2.ErrInvalidRequest)
+		}
+	case lifecycleShutdown:
+		return fmt.Errorf("%w: server is shut down", jsonrpc2.ErrInvalidRequest)
+	}
+	return nil
+}
+
+// dropsNotification reports whether a notification of the given method is
+// dropped in the current state of the lifecycle.
+func (s *Server) dropsNotification(method string) bool {
+	return method != "exit" && lifecycleState(s.lifecycle.Load()) != lifecycleInitialized
+}
+
+// isShutDown implements [messageHandler].
+func (s *Server) isShutDown() bool {
+	return lifecycleState(s.lifecycle.Load()) == lifecycleShutdown
+}
+
+// shutdown stops the background work of the server after the shutdown
+// request, publishing the pending diagnostics, if any, before it returns.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#shutdown
+func (s *Server) shutdown() {
+	s.indexScheduler.stop()
+	s.diagnosticScheduler.flush()
+}
+
+// snippetToPlainText returns the text inserted by the given snippet with the
+// default values of its placeholders, for clients without snippet support.
+// Choices are replaced by their first option, and variables by their default
+// values, if any.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#snippet_syntax
+func snippetToPlainText(snippet string) string {
+	var sb strings.Builder
+	for i := 0; i < len(snippet); i++ {
+		c := snippet[i]
+		switch {
+		case c == '\\' && i+1 < len(snippet) && strings.IndexByte(`$}\,|`, snippet[i+1]) >= 0:
+			i++
+			sb.WriteByte(snippet[i])
+		case c == '$' && i+1 < len(snippet) && isSnippetNameByte(snippet[i+1]):
+			// A tab stop or a variable without default value.
+			for i+1 < len(snippet) && isSnippetNameByte(snippet[i+1]) {
+				i++
+			}
+		case c == '$' && i+1 < len(snippet) && snippet[i+1] == '{':
+			// A placeholder, choice or variable, of which the default value
+			// or first option is kept.
+			i += 2
+			for i < len(snippet) && isSnippetNameByte(snippet[i]) {
+				i++
+			}
+			end := placeholderEnd(snippet, i)
+			if i < end {
+				switch value := snippet[i+1 : end]; snippet[i] {
+				case ':':
+					sb.WriteString(snippetToPlainText(value))
+				case '|':
+					option, _, _ := strings.Cut(strings.TrimSuffix(value, "|"), ",")
+					sb.WriteString(option)
+				}
+			}
+			i = end
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// placeholderEnd returns the index of the closing brace of the placeholder
+// whose value starts at the given index of snippet, or len(snippet) if there
+// is none.
+func placeholderEnd(snippet string, start int) int {
+	depth := 0
+	for i := start; i < len(snippet); i++ {
+		switch snippet[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(snippet)
+}
+
+// isSnippetNameByte reports whether c may be part of the number of a tab stop
+// or the name of a variable of a snippet.
+func isSnippetNameByte(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+// adaptCompletionItems adapts the given completion items to the capabilities
+// of the client, turning snippets into plain text if the client does not
+// support them.
+func (s *Server) adaptCompletionItems(items []CompletionItem) []CompletionItem {
+	if s.getClientCapabilities().snippetSupport {
+		return items
+	}
+	for i, item := range items {
+		if item.InsertTextFormat == nil || *item.InsertTextFormat != SnippetTextFormat {
+			continue
+		}
+		item.InsertText = snippetToPlainText(item.InsertText)
+		if item.TextEdit != nil {
+			if edit, ok := item.TextEdit.Value.(TextEdit); ok {
+				edit.NewText = snippetToPlainText(edit.NewText)
+				item.TextEdit = &Or_CompletionItem_textEdit{Value: edit}
+			}
+		}
+		format := PlainTextTextFormat
+		item.InsertTextFormat = &format
+		items[i] = item
+	}
+	return items
+}
+
+// clientCapabilities are the capabilities of the client that change the
+// responses of the server.
+type clientCapabilities struct {
+	// snippetSupport reports whether completion items may be snippets.
+	snippetSupport bool
+
+	// markdownHover reports whether hovers may be in Markdown.
+	markdownHover bool
+}
+
+// fullClientCapabilities are the capabilities assumed until initialize.
+var fullClientCapabilities = clientCapabilities{snippetSupport: true, markdownHover: true}
+
+// clientCapabilitiesOf returns the capabilities of the client of the given
+// initialize params.
+func clientCapabilitiesOf(params *InitializeParams) clientCapabilities {
+	caps := params.Capabilities.TextDocument
+	c := clientCapabilities{snippetSupport: caps.Completion.CompletionItem.SnippetSupport}
+	if caps.Hover != nil {
+		c.markdownHover = slices.Contains(caps.Hover.ContentFormat, Markdown)
+	}
+	return c
+}
+
+// getClientCapabilities returns the capabilities of the client.
+func (s *Server) getClientCapabilities() clientCapabilities {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.clientCapabilities
+}
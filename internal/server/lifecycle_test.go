@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerLifecycle(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	// count is a variable.
+	count int
+)
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	newServer := func(t *testing.T) (*Server, *recordingReplier) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		return s, replier
+	}
+	call := func(t *testing.T, s *Server, replier *recordingReplier, id int64, method string, params any) *jsonrpc2.Response {
+		c, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(id), method, params)
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(c))
+		var resp *jsonrpc2.Response
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			for _, m := range replier.messages {
+				if r, ok := m.(*jsonrpc2.Response); ok && r.ID() == jsonrpc2.NewIntID(id) {
+					resp = r
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+		return resp
+	}
+	notify := func(t *testing.T, s *Server, method string, params any) {
+		n, err := jsonrpc2.NewNotification(method, params)
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+	}
+	hoverParams := &HoverParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 3, Character: 1},
+		},
+	}
+
+	t.Run("BeforeInitialize", func(t *testing.T) {
+		s, replier := newServer(t)
+
+		resp := call(t, s, replier, 1, "textDocument/hover", hoverParams)
+		assert.ErrorIs(t, resp.Err(), errServerNotInitialized)
+
+		// Notifications are dropped.
+		notify(t, s, "textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: "file:///main.spx", Version: 1, Text: "echo 1"},
+		})
+		assert.Empty(t, s.openDocumentPaths())
+
+		resp = call(t, s, replier, 2, "initialize", InitializeParams{})
+		require.NoError(t, resp.Err())
+		resp = call(t, s, replier, 3, "initialize", InitializeParams{})
+		assert.ErrorIs(t, resp.Err(), jsonrpc2.ErrInvalidRequest)
+		resp = call(t, s, replier, 4, "textDocument/hover", hoverParams)
+		assert.NoError(t, resp.Err())
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		s, replier := newServer(t)
+		resp := call(t, s, replier, 1, "initialize", InitializeParams{})
+		require.NoError(t, resp.Err())
+		notify(t, s, "initialized", InitializedParams{})
+
+		// The pending diagnostics are published before the response.
+		resp = call(t, s, replier, 2, "shutdown", nil)
+		require.NoError(t, resp.Err())
+		replier.mu.Lock()
+		var published, responded bool
+		for _, m := range replier.messages {
+			switch m := m.(type) {
+			case *jsonrpc2.Notification:
+				if m.Method() == "textDocument/publishDiagnostics" {
+					published = true
+					assert.False(t, responded, "diagnostics published after the response")
+				}
+			case *jsonrpc2.Response:
+				responded = m.ID() == jsonrpc2.NewIntID(2)
+			}
+		}
+		replier.mu.Unlock()
+		assert.True(t, published)
+
+		resp = call(t, s, replier, 3, "textDocument/hover", hoverParams)
+		assert.ErrorIs(t, resp.Err(), jsonrpc2.ErrInvalidRequest)
+	})
+}
+
+func TestServerClientCapabilities(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	// count is a variable.
+	count int
+)
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	hoverParams := &HoverParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 3, Character: 1},
+		},
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		var params InitializeParams
+		params.Capabilities.TextDocument.Completion.CompletionItem.SnippetSupport = true
+		params.Capabilities.TextDocument.Hover = &HoverClientCapabilities{ContentFormat: []MarkupKind{Markdown, PlainText}}
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+
+		hover, err := s.textDocumentHover(context.Background(), hoverParams)
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, Markdown, hover.Contents.Kind)
+
+		items := s.adaptCompletionItems([]CompletionItem{{Label: "default", InsertText: "default:$0", InsertTextFormat: util.ToPtr(SnippetTextFormat)}})
+		assert.Equal(t, "default:$0", items[0].InsertText)
+		assert.Equal(t, SnippetTextFormat, *items[0].InsertTextFormat)
+	})
+
+	t.Run("None", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		_, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+
+		hover, err := s.textDocumentHover(context.Background(), hoverParams)
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, MarkupContent{
+			Kind:  PlainText,
+			Value: "var count int\n\ncount is a variable.\n",
+		}, hover.Contents)
+
+		items := s.adaptCompletionItems([]CompletionItem{
+			{Label: "default", InsertText: "default:$0", InsertTextFormat: util.ToPtr(SnippetTextFormat)},
+			{Label: "plain", InsertText: "a$0", InsertTextFormat: util.ToPtr(PlainTextTextFormat)},
+			{Label: "edit", TextEdit: &Or_CompletionItem_textEdit{Value: TextEdit{NewText: "len(${1:x})"}}, InsertTextFormat: util.ToPtr(SnippetTextFormat)},
+		})
+		assert.Equal(t, "default:", items[0].InsertText)
+		assert.Equal(t, PlainTextTextFormat, *items[0].InsertTextFormat)
+		assert.Equal(t, "a$0", items[1].InsertText)
+		assert.Equal(t, "len(x)", items[2].TextEdit.Value.(TextEdit).NewText)
+	})
+}
+
+func TestSnippetToPlainText(t *testing.T) {
+	for _, tt := range []struct {
+		snippet string
+		want    string
+	}{
+		{"default:$0", "default:"},
+		{"case ${1:ch} <- ${2:value}:$0", "case ch <- value:"},
+		{"${1:outer ${2:inner}}", "outer inner"},
+		{"${1|one,two|}", "one"},
+		{"${1}x", "x"},
+		{"$TM_SELECTED_TEXT${TM_FILENAME:main.spx}", "main.spx"},
+		{`\$1 \} \\`, `$1 } \`},
+		{"${1:unclosed", "unclosed"},
+		{"price: $", "price: $"},
+	} {
+		assert.Equal(t, tt.want, snippetToPlainText(tt.snippet), tt.snippet)
+	}
+}
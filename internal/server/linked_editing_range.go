@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_linkedEditingRange
+func (s *Server) textDocumentLinkedEditingRange(ctx context.Context, params *LinkedEditingRangeParams) (*LinkedEditingRanges, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	position := result.toPosition(astFile, params.Position)
+
+	spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position)
+	if spxResourceRef == nil || spxResourceRef.Kind != SpxResourceRefKindStringLiteral {
+		return nil, nil
+	}
+	lit, ok := spxResourceRef.Node.(*gopast.BasicLit)
+	if !ok || lit.Kind != goptoken.STRING {
+		return nil, nil
+	}
+
+	// Linked ranges must have identical text content, so only string literals
+	// spelled exactly the same way in the same file are linked.
+	var ranges []Range
+	seenNodes := make(map[gopast.Node]struct{})
+	for _, ref := range result.spxResourceRefs {
+		if ref.ID != spxResourceRef.ID || ref.Kind != SpxResourceRefKindStringLiteral {
+			continue
+		}
+		refLit, ok := ref.Node.(*gopast.BasicLit)
+		if !ok || refLit.Value != lit.Value || result.nodeASTFile(refLit) != astFile {
+			continue
+		}
+		if _, ok := seenNodes[refLit]; ok {
+			continue
+		}
+		seenNodes[refLit] = struct{}{}
+		ranges = append(ranges, result.spxResourceRefRange(ref))
+	}
+	if len(ranges) < 2 {
+		return nil, nil
+	}
+	return &LinkedEditingRanges{
+		Ranges:      ranges,
+		WordPattern: `[^"\\]*`,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentLinkedEditingRange(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.setCostume "costume1"
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume1"
+	wait 1
+	setCostume "costume1"
+	setCostume "costume2"
+}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	t.Run("Normal", func(t *testing.T) {
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(context.Background(), &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, linkedEditingRanges)
+		assert.ElementsMatch(t, []Range{
+			{Start: Position{Line: 2, Character: 13}, End: Position{Line: 2, Character: 21}},
+			{Start: Position{Line: 4, Character: 13}, End: Position{Line: 4, Character: 21}},
+		}, linkedEditingRanges.Ranges)
+		assert.Equal(t, `[^"\\]*`, linkedEditingRanges.WordPattern)
+	})
+
+	t.Run("SingleOccurrence", func(t *testing.T) {
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(context.Background(), &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 5, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, linkedEditingRanges)
+	})
+
+	t.Run("NonResourcePosition", func(t *testing.T) {
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(context.Background(), &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 3, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, linkedEditingRanges)
+	})
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// The server logs events of interest, e.g., handled requests and failures of
+// background work, with [Server.logger]. Records at or above the level set by
+// [Settings.LogLevel] are sent to the client with window/logMessage.
+//
+// Independently, the JSON-RPC traffic is traced to the client with $/logTrace
+// as requested by the client with the trace of initialize or $/setTrace, and
+// mirrored to the writer set by [Server.SetTraceWriter], if any.
+
+// logLevelOff is the log level above all records, with which nothing is
+// logged.
+const logLevelOff = slog.Level(math.MaxInt)
+
+// parseLogLevel parses a log level name of [Settings.LogLevel].
+func parseLogLevel(name string) (slog.Level, error) {
+	switch name {
+	case "", "off":
+		return logLevelOff, nil
+	case "error":
+		return slog.LevelError, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// clientLogHandler is a [slog.Handler] that sends records to the client with
+// window/logMessage, as the message followed by its attributes in key=value
+// form.
+type clientLogHandler struct {
+	s      *Server
+	attrs  string // preformatted attributes from WithAttrs
+	prefix string // key prefix from WithGroup
+}
+
+func (h *clientLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.s.logLevel.Level()
+}
+
+func (h *clientLogHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		appendLogAttr(&b, h.prefix, attr)
+		return true
+	})
+
+	msgType := Log
+	switch {
+	case r.Level >= slog.LevelError:
+		msgType = Error
+	case r.Level >= slog.LevelWarn:
+		msgType = Warning
+	case r.Level >= slog.LevelInfo:
+		msgType = Info
+	}
+	n, err := jsonrpc2.NewNotification("window/logMessage", &LogMessageParams{Type: msgType, Message: b.String()})
+	if err != nil {
+		return err
+	}
+	return h.s.replier.ReplyMessage(n)
+}
+
+func (h *clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, attr := range attrs {
+		appendLogAttr(&b, h.prefix, attr)
+	}
+	return &clientLogHandler{s: h.s, attrs: b.String(), prefix: h.prefix}
+}
+
+func (h *clientLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &clientLogHandler{s: h.s, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// appendLogAttr appends the given attribute to b as " key=value", quoting the
+// value if needed.
+func appendLogAttr(b *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, attr := range attr.Value.Group() {
+			appendLogAttr(b, prefix, attr)
+		}
+		return
+	}
+	value := attr.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, attr.Key, value)
+}
+
+// -----------------------------------------------------------------------------
+
+// tracer traces the JSON-RPC traffic of a server.
+type tracer struct {
+	mu       sync.Mutex
+	value    TraceValue                 // trace setting of the client
+	w        io.Writer                  // mirror of the traffic, nil if none
+	received map[jsonrpc2.ID]tracedCall // calls from the client in flight
+	sent     map[jsonrpc2.ID]tracedCall // calls to the client in flight
+}
+
+// tracedCall is a call in flight, whose response is traced with the method
+// and the time taken.
+type tracedCall struct {
+	method string
+	start  time.Time
+}
+
+// SetTraceWriter sets the writer to which all messages received and sent by
+// the server are mirrored, one JSON object per line with the time, the
+// direction ("recv" or "send") and the message, e.g., for debugging editor
+// integrations. A nil writer stops mirroring.
+func (s *Server) SetTraceWriter(w io.Writer) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.w = w
+}
+
+// setTrace sets the trace setting of the client.
+func (s *Server) setTrace(value TraceValue) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.value = value
+}
+
+// traceReceived traces the given message received from the client.
+func (s *Server) traceReceived(m jsonrpc2.Message) {
+	now := time.Now()
+	var call *tracedCall
+	switch m := m.(type) {
+	case *jsonrpc2.Call:
+		s.tracer.start(&s.tracer.received, m.ID(), m.Method(), now)
+	case *jsonrpc2.Response:
+		call = s.tracer.end(s.tracer.sent, m.ID())
+	}
+	s.trace("recv", m, now, func() (msg, verbose string) {
+		switch m := m.(type) {
+		case *jsonrpc2.Call:
+			return fmt.Sprintf("Received request '%s - (%v)'.", m.Method(), m.ID()), traceParams(m.Params())
+		case *jsonrpc2.Notification:
+			return fmt.Sprintf("Received notification '%s'.", m.Method()), traceParams(m.Params())
+		case *jsonrpc2.Response:
+			if call == nil {
+				return fmt.Sprintf("Received response '(%v)'.", m.ID()), traceResult(m)
+			}
+			return fmt.Sprintf("Received response '%s - (%v)' in %dms.", call.method, m.ID(), now.Sub(call.start).Milliseconds()), traceResult(m)
+		}
+		return "", ""
+	})
+}
+
+// traceSent traces the given message sent to the client. Responses are also
+// logged at debug level with the time taken to handle their calls.
+func (s *Server) traceSent(m jsonrpc2.Message) {
+	now := time.Now()
+	var call *tracedCall
+	switch m := m.(type) {
+	case *jsonrpc2.Call:
+		s.tracer.start(&s.tracer.sent, m.ID(), m.Method(), now)
+	case *jsonrpc2.Response:
+		call = s.tracer.end(s.tracer.received, m.ID())
+		if call != nil {
+			attrs := []any{"method", call.method, "id", fmt.Sprint(m.ID()), "duration", now.Sub(call.start)}
+			if err := m.Err(); err != nil {
+				attrs = append(attrs, "error", err)
+			}
+			s.logger.Debug("handled request", attrs...)
+		}
+	}
+	s.trace("send", m, now, func() (msg, verbose string) {
+		switch m := m.(type) {
+		case *jsonrpc2.Call:
+			return fmt.Sprintf("Sending request '%s - (%v)'.", m.Method(), m.ID()), traceParams(m.Params())
+		case *jsonrpc2.Notification:
+			return fmt.Sprintf("Sending notification '%s'.", m.Method()), traceParams(m.Params())
+		case *jsonrpc2.Response:
+			if call == nil {
+				return fmt.Sprintf("Sending response '(%v)'.", m.ID()), traceResult(m)
+			}
+			return fmt.Sprintf("Sending response '%s - (%v)'. Processing request took %dms", call.method, m.ID(), now.Sub(call.start).Milliseconds()), traceResult(m)
+		}
+		return "", ""
+	})
+}
+
+// start records the start of the call with the given ID and method in calls.
+func (t *tracer) start(calls *map[jsonrpc2.ID]tracedCall, id jsonrpc2.ID, method string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if *calls == nil {
+		*calls = make(map[jsonrpc2.ID]tracedCall)
+	}
+	(*calls)[id] = tracedCall{method: method, start: now}
+}
+
+// end removes the call with the given ID from calls and returns it, or nil if
+// there is no such call.
+func (t *tracer) end(calls map[jsonrpc2.ID]tracedCall, id jsonrpc2.ID) *tracedCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	call, ok := calls[id]
+	if !ok {
+		return nil
+	}
+	delete(calls, id)
+	return &call
+}
+
+// receivedMethod returns the method of the call from the client in flight
+// with the given ID, or "" if there is no such call.
+func (t *tracer) receivedMethod(id jsonrpc2.ID) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.received[id].method
+}
+
+// trace mirrors the given message, sent or received at the given time as
+// given by dir, and traces it to the client with the message and verbose
+// details returned by describe.
+func (s *Server) trace(dir string, m jsonrpc2.Message, now time.Time, describe func() (msg, verbose string)) {
+	t := &s.tracer
+	t.mu.Lock()
+	if t.w != nil {
+		if raw, err := json.Marshal(m); err == nil {
+			line, _ := json.Marshal(struct {
+				Time    time.Time       `json:"time"`
+				Dir     string          `json:"dir"`
+				Message json.RawMessage `json:"message"`
+			}{now, dir, raw})
+			t.w.Write(append(line, '\n'))
+		}
+	}
+	value := t.value
+	t.mu.Unlock()
+
+	if value != Messages && value != Verbose {
+		return
+	}
+	msg, verbose := describe()
+	if msg == "" {
+		return
+	}
+	params := &LogTraceParams{Message: msg}
+	if value == Verbose {
+		params.Verbose = verbose
+	}
+	n, err := jsonrpc2.NewNotification("$/logTrace", params)
+	if err != nil {
+		return
+	}
+	// Sent with the replier of the client directly, as traces are not traced.
+	_ = s.clientReplier.ReplyMessage(n)
+}
+
+// traceParams returns the verbose trace of the given params.
+func traceParams(params json.RawMessage) string {
+	if len(params) == 0 {
+		return "No parameters provided."
+	}
+	return "Params: " + string(params)
+}
+
+// traceResult returns the verbose trace of the result of the given response.
+func traceResult(r *jsonrpc2.Response) string {
+	if err := r.Err(); err != nil {
+		return "Error: " + err.Error()
+	}
+	return "Result: " + string(r.Result())
+}
+
+// tracingReplier is the [MessageReplier] of a server, which traces messages
+// before sending them with the replier of the client.
+type tracingReplier struct {
+	s *Server
+}
+
+// ReplyMessage implements [MessageReplier].
+func (r tracingReplier) ReplyMessage(m jsonrpc2.Message) error {
+	r.s.traceSent(m)
+	return r.s.clientReplier.ReplyMessage(m)
+}
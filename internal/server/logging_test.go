@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerLogging(t *testing.T) {
+	newServer := func(t *testing.T) (*Server, *recordingReplier) {
+		m := map[string][]byte{
+			"main.spx": []byte(`echo "Hello, spx!"`),
+		}
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		return s, replier
+	}
+	initialize := func(t *testing.T, s *Server, params InitializeParams) {
+		call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "initialize", params)
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(call))
+	}
+	notifications := func(replier *recordingReplier, method string) []*jsonrpc2.Notification {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		var ns []*jsonrpc2.Notification
+		for _, m := range replier.messages {
+			if n, ok := m.(*jsonrpc2.Notification); ok && n.Method() == method {
+				ns = append(ns, n)
+			}
+		}
+		return ns
+	}
+	hasResponse := func(replier *recordingReplier) func() bool {
+		return func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			for _, m := range replier.messages {
+				if _, ok := m.(*jsonrpc2.Response); ok {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		s, replier := newServer(t)
+		initialize(t, s, InitializeParams{})
+		require.Eventually(t, hasResponse(replier), time.Second, time.Millisecond)
+
+		assert.Empty(t, notifications(replier, "$/logTrace"))
+		assert.Empty(t, notifications(replier, "window/logMessage"))
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		s, replier := newServer(t)
+		trace := Verbose
+		var initParams InitializeParams
+		initParams.Trace = &trace
+		initialize(t, s, initParams)
+		require.Eventually(t, hasResponse(replier), time.Second, time.Millisecond)
+
+		traces := notifications(replier, "$/logTrace")
+		require.Len(t, traces, 1)
+		var params LogTraceParams
+		require.NoError(t, json.Unmarshal(traces[0].Params(), &params))
+		assert.True(t, strings.HasPrefix(params.Message, "Sending response 'initialize - (1)'."), params.Message)
+		assert.True(t, strings.HasPrefix(params.Verbose, "Result: "), params.Verbose)
+
+		n, err := jsonrpc2.NewNotification("$/setTrace", SetTraceParams{Value: Messages})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+		n, err = jsonrpc2.NewNotification("initialized", InitializedParams{})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+
+		traces = notifications(replier, "$/logTrace")
+		require.Len(t, traces, 3)
+		// $/setTrace itself is traced with the previous value.
+		require.NoError(t, json.Unmarshal(traces[1].Params(), &params))
+		assert.Equal(t, LogTraceParams{
+			Message: "Received notification '$/setTrace'.",
+			Verbose: `Params: {"value":"messages"}`,
+		}, params)
+		params = LogTraceParams{}
+		require.NoError(t, json.Unmarshal(traces[2].Params(), &params))
+		assert.Equal(t, LogTraceParams{Message: "Received notification 'initialized'."}, params)
+
+		n, err = jsonrpc2.NewNotification("$/setTrace", SetTraceParams{Value: Off})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+		n, err = jsonrpc2.NewNotification("initialized", InitializedParams{})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+		assert.Len(t, notifications(replier, "$/logTrace"), 4)
+	})
+
+	t.Run("TraceWriter", func(t *testing.T) {
+		s, replier := newServer(t)
+		var buf lockedBuffer
+		s.SetTraceWriter(&buf)
+		initialize(t, s, InitializeParams{})
+		require.Eventually(t, hasResponse(replier), time.Second, time.Millisecond)
+		assert.Empty(t, notifications(replier, "$/logTrace"))
+
+		var dirs []string
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			var entry struct {
+				Time    time.Time      `json:"time"`
+				Dir     string         `json:"dir"`
+				Message map[string]any `json:"message"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			assert.False(t, entry.Time.IsZero())
+			assert.EqualValues(t, 1, entry.Message["id"])
+			dirs = append(dirs, entry.Dir)
+		}
+		assert.Equal(t, []string{"recv", "send"}, dirs)
+	})
+
+	t.Run("LogLevel", func(t *testing.T) {
+		s, replier := newServer(t)
+		var initParams InitializeParams
+		initParams.InitializationOptions = map[string]any{"logLevel": "debug"}
+		initialize(t, s, initParams)
+		require.Eventually(t, func() bool {
+			return len(notifications(replier, "window/logMessage")) > 0
+		}, time.Second, time.Millisecond)
+
+		var params LogMessageParams
+		require.NoError(t, json.Unmarshal(notifications(replier, "window/logMessage")[0].Params(), &params))
+		assert.Equal(t, Log, params.Type)
+		assert.True(t, strings.HasPrefix(params.Message, `handled request method=initialize id=1 duration=`), params.Message)
+	})
+}
+
+// lockedBuffer is a [bytes.Buffer] safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"runtime"
+)
+
+// MemoryUsage represents the memory usage of the server.
+type MemoryUsage struct {
+	// HeapBytes is the size of the allocated heap objects of the whole
+	// process.
+	HeapBytes uint64 `json:"heapBytes"`
+
+	// Files is the number of files in the workspace.
+	Files int `json:"files"`
+
+	// FileBytes is the total size of the files in the workspace.
+	FileBytes int64 `json:"fileBytes"`
+
+	// FileCaches is the number of caches derived from files, e.g., ASTs.
+	FileCaches int `json:"fileCaches"`
+
+	// FileCacheBytes is the estimated size of the caches derived from files.
+	FileCacheBytes int64 `json:"fileCacheBytes"`
+
+	// MemoryBudget is the configured [Settings.MemoryBudget], 0 if unlimited.
+	MemoryBudget int64 `json:"memoryBudget"`
+
+	// Evictions is the number of caches evicted so far to stay within the
+	// budget.
+	Evictions int64 `json:"evictions"`
+}
+
+// xgoMemoryUsage handles the xgo/memoryUsage request, which returns the memory
+// usage of the server for clients to display.
+func (s *Server) xgoMemoryUsage(ctx context.Context) (*MemoryUsage, error) {
+	usage := s.getProj().MemoryUsage()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return &MemoryUsage{
+		HeapBytes:      memStats.HeapAlloc,
+		Files:          usage.Files,
+		FileBytes:      usage.FileBytes,
+		FileCaches:     usage.FileCaches,
+		FileCacheBytes: usage.FileCacheBytes,
+		MemoryBudget:   usage.Budget,
+		Evictions:      usage.Evictions,
+	}, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMemoryUsage(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`onStart => {}`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+
+	t.Run("Unlimited", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		_, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		_, err = s.compile(context.Background())
+		require.NoError(t, err)
+
+		usage, err := s.xgoMemoryUsage(context.Background())
+		require.NoError(t, err)
+		assert.NotZero(t, usage.HeapBytes)
+		assert.Equal(t, len(m), usage.Files)
+		assert.NotZero(t, usage.FileBytes)
+		assert.NotZero(t, usage.FileCaches)
+		assert.NotZero(t, usage.FileCacheBytes)
+		assert.Zero(t, usage.MemoryBudget)
+		assert.Zero(t, usage.Evictions)
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		m := maps.Clone(m)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{"memoryBudget": 1}
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+		_, err = s.compile(context.Background())
+		require.NoError(t, err)
+
+		usage, err := s.xgoMemoryUsage(context.Background())
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, usage.MemoryBudget)
+		assert.NotZero(t, usage.FileCaches)
+		assert.Zero(t, usage.Evictions)
+		fileCaches := usage.FileCaches
+
+		// The budget is enforced once a file changes and a new snapshot is
+		// taken. The AST of the changed file, kept along with the type info
+		// until then, is dropped rather than evicted.
+		m["MySprite.spx"] = []byte(`onStart => { echo "hi" }`)
+		s.InvalidateFiles("MySprite.spx")
+
+		usage, err = s.xgoMemoryUsage(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, usage.FileCaches)
+		assert.Zero(t, usage.FileCacheBytes)
+		assert.EqualValues(t, fileCaches-1, usage.Evictions)
+	})
+}
@@ -0,0 +1,410 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// addUndefinedIdentQuickFixes adds the quick fixes of the given diagnostic of
+// the undefined identifier with the given name at the given position in the
+// given spx source file. They declare it as a local variable or a class field
+// of the type its uses expect, or as a function with the parameters of its
+// call.
+func (r *compileResult) addUndefinedIdentQuickFixes(spxFile string, diagnostic Diagnostic, pos goptoken.Pos, name string) {
+	astFile := getASTPkg(r.proj).Files[spxFile]
+	if astFile == nil || !goptoken.IsIdentifier(name) {
+		return // E.g., an undefined qualified identifier like fmt.Foo.
+	}
+	path, _ := goputil.PathEnclosingInterval(r.proj, pos, pos+goptoken.Pos(len(name)))
+	if len(path) < 2 {
+		return
+	}
+	ident, ok := path[0].(*gopast.Ident)
+	if !ok || ident.Name != name {
+		return
+	}
+	if sel, ok := path[1].(*gopast.SelectorExpr); ok && sel.Sel == ident {
+		return
+	}
+
+	var fixes []quickFix
+	if call, ok := path[1].(*gopast.CallExpr); ok && call.Fun == ident {
+		if edit, ok := r.declareFuncEdit(astFile, path); ok {
+			fixes = append(fixes, quickFix{
+				title: fmt.Sprintf("Create function `%s`", name),
+				edits: []TextEdit{edit},
+			})
+		}
+	} else {
+		typ, _ := r.typeString(astFile, r.expectedType(path))
+		if edits := r.declareLocalVarEdits(astFile, path, typ); len(edits) > 0 {
+			fixes = append(fixes, quickFix{
+				title: fmt.Sprintf("Create local variable `%s`", name),
+				edits: edits,
+			})
+		}
+		if typ != "" && astFile.IsClass {
+			start, end, newText := r.classFieldsInsertion(astFile, goputil.ClassFieldsDecl(astFile), "\t"+name+" "+typ+"\n")
+			tokenFile := r.proj.Fset.File(astFile.Pos())
+			fixes = append(fixes, quickFix{
+				title: fmt.Sprintf("Create field `%s`", name),
+				edits: []TextEdit{{
+					Range:   r.rangeForStartEnd(astFile, tokenFile.Pos(start), tokenFile.Pos(end)),
+					NewText: newText,
+				}},
+			})
+		}
+	}
+
+	documentURI := r.documentURIs[spxFile]
+	for _, fix := range fixes {
+		fix.diagnostic = diagnostic
+		fix.isPreferred = len(fixes) == 1
+		r.quickFixes[documentURI] = append(r.quickFixes[documentURI], fix)
+	}
+}
+
+// declareLocalVarEdits returns the edits that declare the undefined identifier
+// of the given path of [goputil.PathEnclosingInterval] as a local variable of the
+// given type in the innermost statement list containing it. An assignment of
+// it alone becomes its declaration, which needs no type. It returns nil if the
+// identifier is not in a statement list, or if its type is unknown.
+func (r *compileResult) declareLocalVarEdits(astFile *gopast.File, path []gopast.Node, typ string) []TextEdit {
+	var stmt gopast.Stmt
+	for i, node := range path[1:] {
+		if s, ok := node.(gopast.Stmt); ok && i+2 < len(path) && stmtListNode(path[i+2]) != nil {
+			switch s.(type) {
+			case *gopast.CaseClause, *gopast.CommClause:
+			default:
+				stmt = s
+			}
+		}
+		if stmt != nil {
+			break
+		}
+	}
+	if stmt == nil {
+		return nil
+	}
+
+	if assign, ok := stmt.(*gopast.AssignStmt); ok && assign.Tok == goptoken.ASSIGN && len(assign.Lhs) == 1 && assign.Lhs[0] == path[0] {
+		return []TextEdit{{
+			Range:   r.rangeForStartEnd(astFile, assign.TokPos, assign.TokPos+1),
+			NewText: ":=",
+		}}
+	}
+	if typ == "" {
+		return nil
+	}
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	indent := lineIndent(astFile.Code, tokenFile.Offset(stmt.Pos()))
+	return []TextEdit{{
+		Range:   r.rangeForStartEnd(astFile, stmt.Pos(), stmt.Pos()),
+		NewText: fmt.Sprintf("var %s %s\n%s", path[0].(*gopast.Ident).Name, typ, indent),
+	}}
+}
+
+// declareFuncEdit returns the edit that declares the undefined function called
+// by the call of the given path of [goputil.PathEnclosingInterval], with a
+// parameter for each argument of the call and a result if its value is used.
+// The function is declared before the declaration containing the call.
+func (r *compileResult) declareFuncEdit(astFile *gopast.File, path []gopast.Node) (TextEdit, bool) {
+	ident, call := path[0].(*gopast.Ident), path[1].(*gopast.CallExpr)
+	var enclosingDecl *gopast.FuncDecl
+	for _, decl := range astFile.Decls {
+		if decl.Pos() <= call.Pos() && call.End() <= decl.End() || isShadowEntryStartingBefore(decl, call.Pos()) {
+			enclosingDecl, _ = decl.(*gopast.FuncDecl)
+		}
+	}
+	if enclosingDecl == nil {
+		return TextEdit{}, false // E.g., a call in the class fields declaration.
+	}
+
+	anyType := types.Universe.Lookup("any").Type()
+	params := make([]string, 0, len(call.Args))
+	names := make(map[string]struct{}, len(call.Args))
+	for i, arg := range call.Args {
+		typ := r.exprType(astFile, arg)
+		if typ != nil {
+			typ = types.Default(typ)
+		}
+		if typ == nil || typ == types.Typ[types.UntypedNil] || typ == types.Typ[types.Invalid] {
+			typ = anyType
+		}
+		typeString, ok := r.typeString(astFile, typ)
+		if !ok {
+			typeString, typ = "any", anyType
+		}
+		if i == len(call.Args)-1 && call.Ellipsis.IsValid() {
+			slice, ok := typ.Underlying().(*types.Slice)
+			if !ok {
+				return TextEdit{}, false
+			}
+			typeString, _ = r.typeString(astFile, slice.Elem())
+			typeString = "..." + typeString
+		}
+
+		name := ""
+		if argIdent, ok := arg.(*gopast.Ident); ok && types.Universe.Lookup(argIdent.Name) == nil {
+			name = argIdent.Name
+		} else {
+			name = placeholderName(arg, typ)
+		}
+		newName := name
+		for j := 1; ; j++ {
+			if _, ok := names[newName]; !ok && newName != ident.Name {
+				break
+			}
+			newName = name + strconv.Itoa(j)
+		}
+		names[newName] = struct{}{}
+		params = append(params, newName+" "+typeString)
+	}
+
+	var result string
+	if _, ok := path[2].(*gopast.ExprStmt); !ok {
+		result = "any"
+		if typ, ok := r.typeString(astFile, r.expectedType(path[1:])); ok && typ != "" {
+			result = typ
+		}
+		result = " " + result
+	}
+
+	offset := r.declStartOffset(astFile, enclosingDecl)
+	pos := r.proj.Fset.File(astFile.Pos()).Pos(offset)
+	return TextEdit{
+		Range:   r.rangeForStartEnd(astFile, pos, pos),
+		NewText: fmt.Sprintf("func %s(%s)%s {\n\tpanic(\"unimplemented\")\n}\n\n", ident.Name, strings.Join(params, ", "), result),
+	}, true
+}
+
+// expectedType returns the type that the context of the expression of the
+// given path of [goputil.PathEnclosingInterval] expects it to have, or nil if it
+// is unknown. Untyped types are converted to their default types.
+func (r *compileResult) expectedType(path []gopast.Node) types.Type {
+	expr, ok := path[0].(gopast.Expr)
+	if !ok || len(path) < 2 {
+		return nil
+	}
+	astFile := r.nodeASTFile(expr)
+	var typ types.Type
+	switch parent := path[1].(type) {
+	case *gopast.ParenExpr:
+		return r.expectedType(path[1:])
+	case *gopast.AssignStmt:
+		if len(parent.Lhs) != len(parent.Rhs) {
+			return nil
+		}
+		for i := range parent.Lhs {
+			switch expr {
+			case parent.Lhs[i]:
+				typ = r.exprType(astFile, parent.Rhs[i])
+			case parent.Rhs[i]:
+				typ = r.exprType(astFile, parent.Lhs[i])
+			}
+		}
+	case *gopast.ValueSpec:
+		if parent.Type != nil && slices.Contains(parent.Values, expr) {
+			typ = getTypeInfo(r.proj).TypeOf(parent.Type)
+		}
+	case *gopast.BinaryExpr:
+		switch parent.Op {
+		case goptoken.LAND, goptoken.LOR:
+			typ = types.Typ[types.Bool]
+		case goptoken.SHL, goptoken.SHR:
+			if expr == parent.Y {
+				typ = types.Typ[types.Uint]
+			}
+		default:
+			other := parent.X
+			if expr == parent.X {
+				other = parent.Y
+			}
+			typ = r.exprType(astFile, other)
+		}
+	case *gopast.UnaryExpr:
+		if parent.Op == goptoken.NOT {
+			typ = types.Typ[types.Bool]
+		}
+	case *gopast.IncDecStmt:
+		typ = types.Typ[types.Int]
+	case *gopast.IfStmt:
+		if expr == parent.Cond {
+			typ = types.Typ[types.Bool]
+		}
+	case *gopast.ForStmt:
+		if expr == parent.Cond {
+			typ = types.Typ[types.Bool]
+		}
+	case *gopast.CallExpr:
+		if i := slices.Index(parent.Args, expr); i >= 0 {
+			typ = r.paramType(parent, i)
+		}
+	}
+	if typ == nil {
+		return nil
+	}
+	typ = types.Default(typ)
+	if basic, ok := typ.(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 || typ == types.Typ[types.Invalid] {
+		return nil // E.g., untyped nil.
+	}
+	if _, ok := typ.(*types.Tuple); ok {
+		return nil
+	}
+	return typ
+}
+
+// paramType returns the type of the parameter of the function called by the
+// given call for its argument at the given index, or nil if it is unknown or
+// differs between the overloads of the function.
+func (r *compileResult) paramType(call *gopast.CallExpr, index int) types.Type {
+	var funIdent *gopast.Ident
+	switch fun := call.Fun.(type) {
+	case *gopast.Ident:
+		funIdent = fun
+	case *gopast.SelectorExpr:
+		funIdent = fun.Sel
+	default:
+		return nil
+	}
+	var sigs []*types.Signature
+	switch obj := getTypeInfo(r.proj).ObjectOf(funIdent).(type) {
+	case *types.Func:
+		overloads, _ := goputil.OverloadsOf(obj)
+		for _, overload := range overloads {
+			sigs = append(sigs, overload.Type().(*types.Signature))
+		}
+	case *types.Var:
+		if sig, ok := obj.Type().Underlying().(*types.Signature); ok {
+			sigs = append(sigs, sig)
+		}
+	}
+
+	var typ types.Type
+	for _, sig := range sigs {
+		params := sig.Params()
+		nargs := len(call.Args)
+		if nargs != params.Len() && !(sig.Variadic() && nargs >= params.Len()-1) {
+			continue
+		}
+		var paramType types.Type
+		if sig.Variadic() && index >= params.Len()-1 {
+			paramType = params.At(params.Len() - 1).Type()
+			if !call.Ellipsis.IsValid() {
+				paramType = paramType.(*types.Slice).Elem()
+			}
+		} else {
+			paramType = params.At(index).Type()
+		}
+		if typ != nil && !types.Identical(typ, paramType) {
+			return nil
+		}
+		typ = paramType
+	}
+	return typ
+}
+
+// exprType returns the type of the given expression in the given AST file,
+// or nil if it is unknown. Types of expressions in statements with type
+// errors are not recorded, so they are inferred from literals and the objects
+// of identifiers as well.
+func (r *compileResult) exprType(astFile *gopast.File, expr gopast.Expr) types.Type {
+	typeInfo := getTypeInfo(r.proj)
+	if typ := typeInfo.TypeOf(expr); typ != nil {
+		return typ
+	}
+	switch expr := expr.(type) {
+	case *gopast.ParenExpr:
+		return r.exprType(astFile, expr.X)
+	case *gopast.BasicLit:
+		switch expr.Kind {
+		case goptoken.INT:
+			return types.Typ[types.UntypedInt]
+		case goptoken.FLOAT:
+			return types.Typ[types.UntypedFloat]
+		case goptoken.IMAG:
+			return types.Typ[types.UntypedComplex]
+		case goptoken.CHAR:
+			return types.Typ[types.UntypedRune]
+		case goptoken.STRING:
+			return types.Typ[types.UntypedString]
+		}
+	case *gopast.Ident:
+		if scope := r.innermostScopeAt(expr.Pos()); scope != nil {
+			if _, obj := scope.LookupParent(expr.Name, goptoken.NoPos); obj != nil {
+				return obj.Type()
+			}
+		}
+		if classFieldsDecl := goputil.ClassFieldsDecl(astFile); classFieldsDecl != nil {
+			for _, spec := range classFieldsDecl.Specs {
+				valueSpec, ok := spec.(*gopast.ValueSpec)
+				if !ok {
+					continue
+				}
+				for _, name := range valueSpec.Names {
+					if name.Name == expr.Name {
+						if obj := typeInfo.Defs[name]; obj != nil {
+							return obj.Type()
+						}
+					}
+				}
+			}
+		}
+	case *gopast.UnaryExpr:
+		if expr.Op == goptoken.NOT {
+			return types.Typ[types.Bool]
+		}
+		if expr.Op == goptoken.SUB || expr.Op == goptoken.ADD || expr.Op == goptoken.XOR {
+			return r.exprType(astFile, expr.X)
+		}
+	case *gopast.BinaryExpr:
+		switch expr.Op {
+		case goptoken.EQL, goptoken.NEQ, goptoken.LSS, goptoken.LEQ, goptoken.GTR, goptoken.GEQ, goptoken.LAND, goptoken.LOR:
+			return types.Typ[types.Bool]
+		case goptoken.SHL, goptoken.SHR:
+			return r.exprType(astFile, expr.X)
+		}
+		if typ := r.exprType(astFile, expr.X); typ != nil {
+			return typ
+		}
+		return r.exprType(astFile, expr.Y)
+	}
+	return nil
+}
+
+// typeString returns the string of the given type in the given AST file, or
+// "" if the type is nil. It reports false if the type refers to a package that
+// is not imported by the file.
+func (r *compileResult) typeString(astFile *gopast.File, typ types.Type) (string, bool) {
+	if typ == nil {
+		return "", true
+	}
+	pkg := getPkg(r.proj)
+	accessible := true
+	s := types.TypeString(typ, func(p *types.Package) string {
+		if p == pkg || p == GetSpxPkg() {
+			return "" // Spx objects are accessible without qualifiers in spx source files.
+		}
+		for _, imp := range astFile.Imports {
+			if path, err := strconv.Unquote(imp.Path.Value); err == nil && path == p.Path() {
+				if imp.Name != nil {
+					return imp.Name.Name
+				}
+				return p.Name()
+			}
+		}
+		accessible = false
+		return p.Name()
+	})
+	if !accessible {
+		return "", false
+	}
+	return s, true
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerUndefinedIdentQuickFixes(t *testing.T) {
+	quickFixes := func(t *testing.T, file, content string, position Position) map[string]string {
+		m := map[string][]byte{"main.spx": []byte("echo \"main\"\n"), file: []byte(content)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: DocumentURI("file:///" + file)},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		results := make(map[string]string, len(codeActions))
+		for _, codeAction := range codeActions {
+			require.NotNil(t, codeAction.Edit)
+			require.Len(t, codeAction.Diagnostics, 1)
+			assert.Contains(t, codeAction.Diagnostics[0].Message, "undefined: ")
+			results[codeAction.Title] = applyTextEdits(m[file], codeAction.Edit.Changes[DocumentURI("file:///"+file)])
+		}
+		return results
+	}
+
+	t.Run("Variable", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", `onClick => {
+	if count > 3 {
+		echo "many"
+	}
+}
+`, Position{Line: 1, Character: 4})
+		assert.Equal(t, map[string]string{
+			"Create local variable `count`": `onClick => {
+	var count int
+	if count > 3 {
+		echo "many"
+	}
+}
+`,
+			"Create field `count`": `var (
+	count int
+)
+
+onClick => {
+	if count > 3 {
+		echo "many"
+	}
+}
+`,
+		}, got)
+	})
+
+	t.Run("Assignment", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", `var (
+	speed float64
+)
+
+onStart => {
+	step = speed * 2
+	echo step
+}
+`, Position{Line: 5, Character: 1})
+		assert.Equal(t, map[string]string{
+			"Create local variable `step`": `var (
+	speed float64
+)
+
+onStart => {
+	step := speed * 2
+	echo step
+}
+`,
+			"Create field `step`": `var (
+	speed float64
+	step float64
+)
+
+onStart => {
+	step = speed * 2
+	echo step
+}
+`,
+		}, got)
+	})
+
+	t.Run("CallArgument", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", `func greet(name string) {
+	echo name
+}
+
+onStart => {
+	greet who
+}
+`, Position{Line: 5, Character: 7})
+		assert.Equal(t, map[string]string{
+			"Create local variable `who`": `func greet(name string) {
+	echo name
+}
+
+onStart => {
+	var who string
+	greet who
+}
+`,
+			"Create field `who`": `var (
+	who string
+)
+
+func greet(name string) {
+	echo name
+}
+
+onStart => {
+	greet who
+}
+`,
+		}, got)
+	})
+
+	t.Run("Function", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", `var (
+	score int
+)
+
+onStart => {
+	addPoints score, "bonus", 1.5
+	n := 1 + double(score)
+	echo n
+}
+`, Position{Line: 5, Character: 1})
+		assert.Equal(t, map[string]string{
+			"Create function `addPoints`": `var (
+	score int
+)
+
+func addPoints(score int, s string, f float64) {
+	panic("unimplemented")
+}
+
+onStart => {
+	addPoints score, "bonus", 1.5
+	n := 1 + double(score)
+	echo n
+}
+`,
+		}, got)
+
+		got = quickFixes(t, "MySprite.spx", `func play() {
+	n := 1 + double(2)
+	echo n
+}
+`, Position{Line: 1, Character: 10})
+		assert.Equal(t, map[string]string{
+			"Create function `double`": `func double(n int) int {
+	panic("unimplemented")
+}
+
+func play() {
+	n := 1 + double(2)
+	echo n
+}
+`,
+		}, got)
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", `onStart => {
+	x := unknown
+	echo x
+}
+`, Position{Line: 1, Character: 6})
+		assert.Empty(t, got)
+	})
+}
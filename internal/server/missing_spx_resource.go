@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// addSpxMissingResourceDiagnostic adds the given diagnostic of the missing
+// spx resource referenced by the given expression. If the expression is a
+// string literal and one of the given names of existing resources of the same
+// kind is close to it, the diagnostic suggests that name instead, with a quick
+// fix replacing the literal.
+func (r *compileResult) addSpxMissingResourceDiagnostic(expr gopast.Expr, diagnostic Diagnostic, names iter.Seq[string]) {
+	documentURI := r.nodeDocumentURI(expr)
+	lit, ok := expr.(*gopast.BasicLit)
+	if !ok || lit.Kind != goptoken.STRING {
+		r.addDiagnostics(documentURI, diagnostic)
+		return
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		r.addDiagnostics(documentURI, diagnostic)
+		return
+	}
+	suggestion, ok := closestSpxResourceName(name, names)
+	if !ok {
+		r.addDiagnostics(documentURI, diagnostic)
+		return
+	}
+
+	diagnostic.Message += fmt.Sprintf("; did you mean %q?", suggestion)
+	r.addDiagnostics(documentURI, diagnostic)
+	r.quickFixes[documentURI] = append(r.quickFixes[documentURI], quickFix{
+		diagnostic: diagnostic,
+		title:      fmt.Sprintf("Change to %q", suggestion),
+		edits: []TextEdit{{
+			Range:   r.rangeForNode(lit),
+			NewText: strconv.Quote(suggestion),
+		}},
+		isPreferred: true,
+	})
+}
+
+// closestSpxResourceName returns the name among the given names of spx
+// resources that is closest to the given misspelled name by edit distance,
+// ignoring case. It reports false if no name is close enough to be a likely
+// correction, i.e., within one edit per three characters of the misspelled
+// name. Ties are broken by the case-sensitive distance, then by name.
+func closestSpxResourceName(name string, names iter.Seq[string]) (string, bool) {
+	maxDistance := max(1, utf8.RuneCountInString(name)/3)
+	lowerName := strings.ToLower(name)
+
+	var (
+		closest                     string
+		closestDistance, closestTie int
+		found                       bool
+	)
+	for candidate := range names {
+		if candidate == name {
+			continue
+		}
+		distance := editDistance(lowerName, strings.ToLower(candidate))
+		if distance > maxDistance {
+			continue
+		}
+		tie := editDistance(name, candidate)
+		if !found ||
+			distance < closestDistance ||
+			distance == closestDistance && (tie < closestTie || tie == closestTie && candidate < closest) {
+			closest, closestDistance, closestTie, found = candidate, distance, tie, true
+		}
+	}
+	return closest, found
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b, i.e., the number of rune insertions, deletions, substitutions and
+// transpositions of adjacent runes needed to change a into b, where no
+// substring is edited more than once.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// rows[i%3][j] is the distance between ra[:i] and rb[:j].
+	var rows [3][]int
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev2, prev, cur := rows[(i+1)%3], rows[(i+2)%3], rows[i%3]
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)%3][len(rb)]
+}
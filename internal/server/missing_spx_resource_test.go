@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxMissingResourceQuickFixes(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+play "jmup"
+play "boom"
+startBackdrop "Backdrop2"
+`),
+		"MySprite.spx": []byte(`
+onClick => {
+	setCostume "wlak1"
+	MySprite.setCostume "Walk2"
+	animate "rn"
+}
+`),
+		"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1"}]}`),
+		"assets/sounds/jump/index.json":      []byte(`{}`),
+		"assets/sounds/jumping/index.json":   []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"walk1"},{"name":"walk2"}],"fAnimations":{"run":{"frameFrom":"walk1","frameTo":"walk2"}}}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	quickFixes := func(t *testing.T, file string, position Position) map[string]string {
+		documentURI := DocumentURI("file:///" + file)
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: documentURI},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		results := make(map[string]string, len(codeActions))
+		for _, codeAction := range codeActions {
+			require.NotNil(t, codeAction.Edit)
+			require.Len(t, codeAction.Diagnostics, 1)
+			assert.Contains(t, codeAction.Diagnostics[0].Message, "did you mean")
+			assert.True(t, codeAction.IsPreferred)
+			results[codeAction.Title] = applyTextEdits(m[file], codeAction.Edit.Changes[documentURI])
+		}
+		return results
+	}
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		var messages []string
+		for _, diagnostic := range report.Value.(RelatedFullDocumentDiagnosticReport).Items {
+			messages = append(messages, diagnostic.Message)
+		}
+		assert.Contains(t, messages, `sound "jmup" does not exist; did you mean "jump"?`)
+		assert.Contains(t, messages, `sound "boom" does not exist`)
+		assert.Contains(t, messages, `backdrop "Backdrop2" does not exist; did you mean "backdrop1"?`)
+	})
+
+	t.Run("Sound", func(t *testing.T) {
+		got := quickFixes(t, "main.spx", Position{Line: 4, Character: 7})
+		assert.Equal(t, map[string]string{
+			`Change to "jump"`: `
+var (
+	MySprite Sprite
+)
+play "jump"
+play "boom"
+startBackdrop "Backdrop2"
+`,
+		}, got)
+	})
+
+	t.Run("NoCloseName", func(t *testing.T) {
+		assert.Empty(t, quickFixes(t, "main.spx", Position{Line: 5, Character: 7}))
+	})
+
+	t.Run("Costume", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", Position{Line: 2, Character: 15})
+		assert.Len(t, got, 1)
+		assert.Contains(t, got[`Change to "walk1"`], `setCostume "walk1"`)
+
+		got = quickFixes(t, "MySprite.spx", Position{Line: 3, Character: 24})
+		assert.Len(t, got, 1)
+		assert.Contains(t, got[`Change to "walk2"`], `MySprite.setCostume "walk2"`)
+	})
+
+	t.Run("Animation", func(t *testing.T) {
+		got := quickFixes(t, "MySprite.spx", Position{Line: 4, Character: 11})
+		assert.Len(t, got, 1)
+		assert.Contains(t, got[`Change to "run"`], `animate "run"`)
+	})
+}
+
+func TestClosestSpxResourceName(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		names  []string
+		want   string
+		wantOK bool
+	}{
+		{"jmup", []string{"jump", "jumping"}, "jump", true},
+		{"Jump", []string{"jump", "jumq"}, "jump", true},
+		{"jum", []string{"jump", "jumq"}, "jump", true},
+		{"walk3", []string{"walk1", "walk2"}, "walk1", true},
+		{"boom", []string{"jump"}, "", false},
+		{"a", []string{"b"}, "b", true},
+		{"ab", []string{"cd"}, "", false},
+		{"你好", []string{"你们"}, "你们", true},
+		{"jump", nil, "", false},
+	} {
+		got, ok := closestSpxResourceName(tt.name, slices.Values(tt.names))
+		assert.Equal(t, tt.wantOK, ok, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"abc", "acb", 1},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+		{"你好", "好你", 1},
+	} {
+		assert.Equal(t, tt.want, editDistance(tt.a, tt.b), "%s, %s", tt.a, tt.b)
+		assert.Equal(t, tt.want, editDistance(tt.b, tt.a), "%s, %s", tt.b, tt.a)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_willSaveWaitUntil
+func (s *Server) textDocumentWillSaveWaitUntil(ctx context.Context, params *WillSaveTextDocumentParams) ([]TextEdit, error) {
+	if params.Reason == AfterDelay {
+		// Auto-saves happen while typing, when code should not move around.
+		return nil, nil
+	}
+	return s.spxFormattingEdits(ctx, params.TextDocument.URI, s.applySpxOnSaveActions)
+}
+
+// applySpxOnSaveActions applies the configured on-save actions to an spx
+// source file in order, each to the result of the previous one. Actions that
+// fail are skipped, so that the file can always be saved. Files with syntax
+// errors are left as is, as code that fails to parse may use imports that
+// seem unused.
+func (s *Server) applySpxOnSaveActions(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	actions := s.getOnSaveActions()
+	var formatters []spxFormatter
+	if actions.fixAll {
+		formatters = append(formatters, s.fixAllSpx)
+	}
+	if actions.organizeImports {
+		formatters = append(formatters, s.organizeImportsSpx)
+	}
+	if actions.format {
+		formatters = append(formatters, s.formatSpx)
+	}
+
+	formatted, err := vfs.ReadFile(snapshot, spxFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := snapshot.AST(spxFile); err != nil {
+		return formatted, nil
+	}
+	for _, formatter := range formatters {
+		subFormatted, err := formatter(ctx, snapshot, spxFile)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil || subFormatted == nil || bytes.Equal(subFormatted, formatted) {
+			continue
+		}
+		snapshot = vfs.WithOverlay(snapshot, map[string]vfs.MapFile{
+			spxFile: {
+				Content: subFormatted,
+				ModTime: time.Now(),
+			},
+		})
+		formatted = subFormatted
+	}
+	return formatted, nil
+}
+
+// fixAllSpx applies the fixes of the source.fixAll code action to an spx
+// source file. It returns nil if there is nothing to fix.
+func (s *Server) fixAllSpx(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	result, err := s.compileAt(ctx, snapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	edits := result.fixAllEdits[result.documentURIs[spxFile]]
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	content, err := vfs.ReadFile(snapshot, spxFile)
+	if err != nil {
+		return nil, err
+	}
+	return applyTextEditsToContent(result.posEncoding, content, edits), nil
+}
+
+// organizeImportsSpx organizes the imports of an spx source file. It returns
+// nil if the imports are already organized.
+func (s *Server) organizeImportsSpx(ctx context.Context, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	result, err := s.compileAt(ctx, snapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	astFile, ok := getASTPkg(snapshot).Files[spxFile]
+	if !ok {
+		return nil, nil
+	}
+	return result.organizeImports(astFile), nil
+}
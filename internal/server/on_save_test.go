@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentWillSaveWaitUntil(t *testing.T) {
+	content := []byte(`import (
+	"fmt"
+	"math"
+)
+
+onStart => {
+	a := 1
+	echo   math.Pi
+}
+`)
+	willSave := func(t *testing.T, onSave *OnSaveSettings, reason TextDocumentSaveReason, content []byte) string {
+		m := map[string][]byte{"main.spx": content}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		require.NoError(t, s.applySettings(&Settings{OnSave: onSave}))
+		edits, err := s.textDocumentWillSaveWaitUntil(context.Background(), &WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Reason:       reason,
+		})
+		require.NoError(t, err)
+		return applyTextEdits(content, edits)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, `import "math"
+
+onStart => {
+	a := 1
+	echo math.Pi
+}
+`, willSave(t, nil, Manual, content))
+	})
+
+	t.Run("FixAll", func(t *testing.T) {
+		assert.Equal(t, `import "math"
+
+onStart => {
+	echo math.Pi
+}
+`, willSave(t, &OnSaveSettings{FixAll: util.ToPtr(true)}, FocusOut, content))
+	})
+
+	t.Run("FormatOnly", func(t *testing.T) {
+		assert.Equal(t, `import (
+	"fmt"
+	"math"
+)
+
+onStart => {
+	a := 1
+	echo math.Pi
+}
+`, willSave(t, &OnSaveSettings{OrganizeImports: util.ToPtr(false)}, Manual, content))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		onSave := &OnSaveSettings{OrganizeImports: util.ToPtr(false), Format: util.ToPtr(false)}
+		assert.Equal(t, string(content), willSave(t, onSave, Manual, content))
+	})
+
+	t.Run("AfterDelay", func(t *testing.T) {
+		assert.Equal(t, string(content), willSave(t, nil, AfterDelay, content))
+	})
+
+	t.Run("SyntaxError", func(t *testing.T) {
+		content := []byte(`import (
+	"fmt"
+	"math"
+)
+
+onStart => {
+	echo   math.Pi +
+}
+`)
+		assert.Equal(t, string(content), willSave(t, nil, Manual, content))
+	})
+}
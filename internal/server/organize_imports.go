@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+	"github.com/goplus/goxlsw/internal/pkgdata"
+)
+
+// spxOrganizeImportsEdits returns the edits that organize the imports of the
+// spx source file of the given document URI. It adds missing imports, removes
+// unused ones, and sorts them by import path, standard library packages first.
+func (s *Server) spxOrganizeImportsEdits(ctx context.Context, uri DocumentURI) ([]TextEdit, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	organized := result.organizeImports(astFile)
+	if organized == nil {
+		return nil, nil // No changes.
+	}
+	return computeTextEdits(result.posEncoding, astFile.Code, organized), nil
+}
+
+// organizeImports returns the content of the given AST file with organized
+// imports. It returns nil if the imports are already organized. See
+// [goputil.OrganizeImports].
+func (r *compileResult) organizeImports(astFile *gopast.File) []byte {
+	return goputil.OrganizeImports(r.proj, astFile, findPkgPathForSelector)
+}
+
+// addImportEdits returns the edits that add an import of the given package
+// path to the given AST file. It returns nil if the package is already
+// imported.
+func (r *compileResult) addImportEdits(astFile *gopast.File, pkgPath string) []TextEdit {
+	var lastImportDecl *gopast.GenDecl
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*gopast.GenDecl)
+		if !ok || genDecl.Tok != goptoken.IMPORT {
+			continue
+		}
+		lastImportDecl = genDecl
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*gopast.ImportSpec)
+			if path, err := strconv.Unquote(importSpec.Path.Value); err == nil && path == pkgPath {
+				return nil
+			}
+		}
+	}
+
+	// Append to the last import declaration, or insert a new one at the top
+	// of the file.
+	code := astFile.Code
+	fset := r.proj.Fset
+	importSrc := strconv.Quote(pkgPath)
+	var offset int
+	switch {
+	case lastImportDecl != nil && lastImportDecl.Lparen.IsValid():
+		offset = fset.Position(lastImportDecl.Rparen).Offset
+		importSrc = "\t" + importSrc + "\n"
+	case lastImportDecl != nil:
+		offset = fset.Position(lastImportDecl.End()).Offset
+		importSrc = "\nimport " + importSrc
+	case astFile.Package.IsValid():
+		offset = fset.Position(astFile.Name.End()).Offset
+		importSrc = "\n\nimport " + importSrc
+	default:
+		importSrc = "import " + importSrc + "\n\n"
+	}
+	return computeTextEdits(r.posEncoding, code, slices.Concat(code[:offset], []byte(importSrc), code[offset:]))
+}
+
+// findPkgPathForSelector finds the path of a package named pkgName that
+// exports the given member. It prefers the shortest path if there are
+// multiple candidates.
+func findPkgPathForSelector(pkgName, member string) (string, bool) {
+	pkgPaths, err := pkgdata.ListPkgs()
+	if err != nil {
+		return "", false
+	}
+	var bestPkgPath string
+	for _, pkgPath := range pkgPaths {
+		pkgDoc, err := pkgdata.GetPkgDoc(pkgPath)
+		if err != nil || pkgDoc.Name != pkgName {
+			continue
+		}
+		_, isVar := pkgDoc.Vars[member]
+		_, isConst := pkgDoc.Consts[member]
+		_, isType := pkgDoc.Types[member]
+		_, isFunc := pkgDoc.Funcs[member]
+		if !isVar && !isConst && !isType && !isFunc {
+			continue
+		}
+		if bestPkgPath == "" ||
+			len(pkgPath) < len(bestPkgPath) ||
+			(len(pkgPath) == len(bestPkgPath) && pkgPath < bestPkgPath) {
+			bestPkgPath = pkgPath
+		}
+	}
+	return bestPkgPath, bestPkgPath != ""
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// workDoneProgress reports the progress of a long-running operation initiated
+// by the server to the client with $/progress notifications.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#serverInitiatedProgress
+//
+// All methods of a nil *workDoneProgress do nothing, so that operations can
+// report their progress regardless of whether the client supports it.
+type workDoneProgress struct {
+	s     *Server
+	token ProgressToken
+}
+
+// workDoneProgressBegin is [protocol.WorkDoneProgressBegin] with the
+// percentage always present, as an omitted one means the progress is
+// indeterminate.
+type workDoneProgressBegin struct {
+	Kind       string `json:"kind"`
+	Title      string `json:"title"`
+	Message    string `json:"message,omitempty"`
+	Percentage uint32 `json:"percentage"`
+}
+
+// beginWorkDoneProgress asks the client to create a work done progress and
+// begins it with the given title. It returns nil if the client does not
+// support server-initiated progress or fails to create it.
+func (s *Server) beginWorkDoneProgress(ctx context.Context, title string) *workDoneProgress {
+	if !s.getWorkDoneProgressSupport() {
+		return nil
+	}
+	token := ProgressToken(fmt.Sprintf("goxlsw/%d", s.lastWorkDoneProgressID.Add(1)))
+	if _, err := s.callClient(ctx, "window/workDoneProgress/create", &WorkDoneProgressCreateParams{Token: token}); err != nil {
+		return nil
+	}
+	p := &workDoneProgress{s: s, token: token}
+	p.notify(&workDoneProgressBegin{Kind: "begin", Title: title})
+	return p
+}
+
+// report reports the current step of the operation and its percentage of
+// completion, which should be steadily rising.
+func (p *workDoneProgress) report(message string, percentage uint32) {
+	if p == nil {
+		return
+	}
+	p.notify(&WorkDoneProgressReport{Kind: "report", Message: message, Percentage: percentage})
+}
+
+// end ends the progress with an optional final message.
+func (p *workDoneProgress) end(message string) {
+	if p == nil {
+		return
+	}
+	p.notify(&WorkDoneProgressEnd{Kind: "end", Message: message})
+}
+
+// notify sends a $/progress notification with the given value. Progress is
+// informational only, so failures are ignored.
+func (p *workDoneProgress) notify(value any) {
+	n, err := jsonrpc2.NewNotification("$/progress", &ProgressParams{Token: p.token, Value: value})
+	if err != nil {
+		return
+	}
+	_ = p.s.replier.ReplyMessage(n)
+}
+
+// partialResultProgress streams the result of a request to the client in
+// chunks with $/progress notifications, using the partial result token sent
+// by the client.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#partialResults
+type partialResultProgress struct {
+	s     *Server
+	token ProgressToken
+}
+
+// newPartialResultProgress returns a partial result progress for the given
+// params. It returns nil if the client did not send a partial result token.
+func (s *Server) newPartialResultProgress(params PartialResultParams) *partialResultProgress {
+	if params.PartialResultToken == nil || *params.PartialResultToken == nil {
+		return nil
+	}
+	return &partialResultProgress{s: s, token: *params.PartialResultToken}
+}
+
+// sendPartialResultsByDocument sends items to the client in chunks, one per
+// document in order of first occurrence, checking ctx between chunks. As the
+// whole result has been sent by then, it returns an empty slice for the final
+// response of the request.
+func sendPartialResultsByDocument[T any](ctx context.Context, p *partialResultProgress, items []T, documentURI func(T) DocumentURI) ([]T, error) {
+	var uris []DocumentURI
+	chunks := make(map[DocumentURI][]T)
+	for _, item := range items {
+		uri := documentURI(item)
+		if _, ok := chunks[uri]; !ok {
+			uris = append(uris, uri)
+		}
+		chunks[uri] = append(chunks[uri], item)
+	}
+	for _, uri := range uris {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := jsonrpc2.NewNotification("$/progress", &ProgressParams{Token: p.token, Value: chunks[uri]})
+		if err != nil {
+			return nil, err
+		}
+		if err := p.s.replier.ReplyMessage(n); err != nil {
+			return nil, fmt.Errorf("failed to send partial result: %w", err)
+		}
+	}
+	return []T{}, nil
+}
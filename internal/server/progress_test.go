@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressClientReplier is a [recordingReplier] that responds to
+// window/workDoneProgress/create calls like a client.
+type progressClientReplier struct {
+	recordingReplier
+	s      *Server
+	refuse bool
+}
+
+func (r *progressClientReplier) ReplyMessage(m jsonrpc2.Message) error {
+	if err := r.recordingReplier.ReplyMessage(m); err != nil {
+		return err
+	}
+	call, ok := m.(*jsonrpc2.Call)
+	if !ok || call.Method() != "window/workDoneProgress/create" {
+		return nil
+	}
+	var err error
+	if r.refuse {
+		err = errors.New("refused")
+	}
+	resp, err := jsonrpc2.NewResponse(call.ID(), nil, err)
+	if err != nil {
+		return err
+	}
+	return r.s.HandleMessage(resp)
+}
+
+func TestServerWorkDoneProgress(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`onStart => {}`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+	newServer := func(t *testing.T, workDoneProgress, refuse bool) (*Server, *progressClientReplier) {
+		replier := &progressClientReplier{refuse: refuse}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		replier.s = s
+		var params InitializeParams
+		params.Capabilities.Window.WorkDoneProgress = workDoneProgress
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+		return s, replier
+	}
+	progressValues := func(t *testing.T, replier *progressClientReplier) (methods []string, values []map[string]any) {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		for _, msg := range replier.messages {
+			switch msg := msg.(type) {
+			case *jsonrpc2.Call:
+				methods = append(methods, msg.Method())
+			case *jsonrpc2.Notification:
+				methods = append(methods, msg.Method())
+				if msg.Method() != "$/progress" {
+					continue
+				}
+				var params struct {
+					Token string         `json:"token"`
+					Value map[string]any `json:"value"`
+				}
+				require.NoError(t, json.Unmarshal(msg.Params(), &params))
+				assert.Equal(t, "goxlsw/1", params.Token)
+				values = append(values, params.Value)
+			}
+		}
+		return
+	}
+
+	t.Run("Supported", func(t *testing.T) {
+		s, replier := newServer(t, true, false)
+
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		methods, values := progressValues(t, replier)
+		require.NotEmpty(t, methods)
+		assert.Equal(t, "window/workDoneProgress/create", methods[0])
+		require.GreaterOrEqual(t, len(values), 3)
+		assert.Equal(t, map[string]any{"kind": "begin", "title": "Checking project", "percentage": 0.0}, values[0])
+		assert.Equal(t, map[string]any{"kind": "end"}, values[len(values)-1])
+
+		var messages []string
+		var lastPercentage float64
+		for _, value := range values[1 : len(values)-1] {
+			assert.Equal(t, "report", value["kind"])
+			percentage, _ := value["percentage"].(float64) // 0 is omitted.
+			assert.GreaterOrEqual(t, percentage, lastPercentage)
+			lastPercentage = percentage
+			messages = append(messages, value["message"].(string))
+		}
+		require.Len(t, messages, 5)
+		assert.Regexp(t, `^Parsing (main|MySprite)\.spx \(1/2\)$`, messages[0])
+		assert.Regexp(t, `^Parsing (main|MySprite)\.spx \(2/2\)$`, messages[1])
+		assert.Equal(t, []string{"Type checking", "Checking resources", "Running analyzers"}, messages[2:])
+
+		// Diagnostics are published while the progress is in progress.
+		assert.Contains(t, methods, "textDocument/publishDiagnostics")
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		s, replier := newServer(t, false, false)
+
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		methods, values := progressValues(t, replier)
+		assert.NotContains(t, methods, "window/workDoneProgress/create")
+		assert.Empty(t, values)
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		s, replier := newServer(t, true, true)
+
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		methods, values := progressValues(t, replier)
+		assert.Contains(t, methods, "window/workDoneProgress/create")
+		assert.Empty(t, values)
+	})
+
+	t.Run("CanceledCreate", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		var params InitializeParams
+		params.Capabilities.Window.WorkDoneProgress = true
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+
+		// The client never responds, so creating the progress is given up.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Nil(t, s.beginWorkDoneProgress(ctx, "Checking project"))
+		s.clientCallsMu.Lock()
+		defer s.clientCallsMu.Unlock()
+		assert.Empty(t, s.clientCalls)
+	})
+}
+
+// partialResults returns the partial results with the given token sent to
+// the client recorded by replier, decoded as chunks of T.
+func partialResults[T any](t *testing.T, replier *recordingReplier, token string) [][]T {
+	replier.mu.Lock()
+	defer replier.mu.Unlock()
+	var chunks [][]T
+	for _, msg := range replier.messages {
+		n, ok := msg.(*jsonrpc2.Notification)
+		if !ok || n.Method() != "$/progress" {
+			continue
+		}
+		var params struct {
+			Token string `json:"token"`
+			Value []T    `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(n.Params(), &params))
+		if params.Token == token {
+			chunks = append(chunks, params.Value)
+		}
+	}
+	return chunks
+}
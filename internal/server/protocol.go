@@ -19,36 +19,53 @@ type (
 	TextEdit      = protocol.TextEdit
 	WorkspaceEdit = protocol.WorkspaceEdit
 
+	DocumentChange                          = protocol.DocumentChange
+	TextDocumentEdit                        = protocol.TextDocumentEdit
+	RenameFile                              = protocol.RenameFile
+	OptionalVersionedTextDocumentIdentifier = protocol.OptionalVersionedTextDocumentIdentifier
+	Or_TextDocumentEdit_edits_Elem          = protocol.Or_TextDocumentEdit_edits_Elem
+
 	TextDocumentPositionParams = protocol.TextDocumentPositionParams
 	TextDocumentIdentifier     = protocol.TextDocumentIdentifier
 
 	InsertTextFormat = protocol.InsertTextFormat
 
 	MarkupContent = protocol.MarkupContent
+	MarkupKind    = protocol.MarkupKind
 
 	DocumentHighlightParams = protocol.DocumentHighlightParams
 	DocumentHighlight       = protocol.DocumentHighlight
 
-	DocumentFormattingParams = protocol.DocumentFormattingParams
+	DocumentFormattingParams       = protocol.DocumentFormattingParams
+	DocumentRangeFormattingParams  = protocol.DocumentRangeFormattingParams
+	DocumentOnTypeFormattingParams = protocol.DocumentOnTypeFormattingParams
 
 	PrepareRenameParams = protocol.PrepareRenameParams
 	RenameParams        = protocol.RenameParams
 
-	Diagnostic                            = protocol.Diagnostic
-	DocumentDiagnosticParams              = protocol.DocumentDiagnosticParams
-	WorkspaceDiagnosticParams             = protocol.WorkspaceDiagnosticParams
-	DocumentDiagnosticReport              = protocol.DocumentDiagnosticReport
-	FullDocumentDiagnosticReport          = protocol.FullDocumentDiagnosticReport
-	RelatedFullDocumentDiagnosticReport   = protocol.RelatedFullDocumentDiagnosticReport
-	WorkspaceDiagnosticReport             = protocol.WorkspaceDiagnosticReport
-	WorkspaceDocumentDiagnosticReport     = protocol.WorkspaceDocumentDiagnosticReport
-	WorkspaceFullDocumentDiagnosticReport = protocol.WorkspaceFullDocumentDiagnosticReport
-	PublishDiagnosticsParams              = protocol.PublishDiagnosticsParams
+	Diagnostic                                 = protocol.Diagnostic
+	DiagnosticSeverity                         = protocol.DiagnosticSeverity
+	DiagnosticTag                              = protocol.DiagnosticTag
+	DiagnosticRelatedInformation               = protocol.DiagnosticRelatedInformation
+	DocumentDiagnosticParams                   = protocol.DocumentDiagnosticParams
+	WorkspaceDiagnosticParams                  = protocol.WorkspaceDiagnosticParams
+	PreviousResultID                           = protocol.PreviousResultID
+	DocumentDiagnosticReport                   = protocol.DocumentDiagnosticReport
+	FullDocumentDiagnosticReport               = protocol.FullDocumentDiagnosticReport
+	RelatedFullDocumentDiagnosticReport        = protocol.RelatedFullDocumentDiagnosticReport
+	UnchangedDocumentDiagnosticReport          = protocol.UnchangedDocumentDiagnosticReport
+	RelatedUnchangedDocumentDiagnosticReport   = protocol.RelatedUnchangedDocumentDiagnosticReport
+	WorkspaceDiagnosticReport                  = protocol.WorkspaceDiagnosticReport
+	WorkspaceDocumentDiagnosticReport          = protocol.WorkspaceDocumentDiagnosticReport
+	WorkspaceFullDocumentDiagnosticReport      = protocol.WorkspaceFullDocumentDiagnosticReport
+	WorkspaceUnchangedDocumentDiagnosticReport = protocol.WorkspaceUnchangedDocumentDiagnosticReport
+	PublishDiagnosticsParams                   = protocol.PublishDiagnosticsParams
 
 	CompletionParams                = protocol.CompletionParams
 	CompletionItemKind              = protocol.CompletionItemKind
 	CompletionItem                  = protocol.CompletionItem
 	Or_CompletionItem_documentation = protocol.Or_CompletionItem_documentation
+	Or_CompletionItem_textEdit      = protocol.Or_CompletionItem_textEdit
 
 	DocumentLinkParams = protocol.DocumentLinkParams
 	DocumentLink       = protocol.DocumentLink
@@ -60,34 +77,156 @@ type (
 	ReferenceParams  = protocol.ReferenceParams
 	ReferenceContext = protocol.ReferenceContext
 
-	HoverParams = protocol.HoverParams
-	Hover       = protocol.Hover
+	HoverParams             = protocol.HoverParams
+	Hover                   = protocol.Hover
+	HoverClientCapabilities = protocol.HoverClientCapabilities
 
 	ImplementationParams = protocol.ImplementationParams
 
-	SemanticTokenTypes     = protocol.SemanticTokenTypes
-	SemanticTokenModifiers = protocol.SemanticTokenModifiers
-	SemanticTokensParams   = protocol.SemanticTokensParams
-	SemanticTokens         = protocol.SemanticTokens
+	InlayHintParams                   = protocol.InlayHintParams
+	InlayHint                         = protocol.InlayHint
+	InlayHintLabelPart                = protocol.InlayHintLabelPart
+	OrPTooltip_textDocument_inlayHint = protocol.OrPTooltip_textDocument_inlayHint
+
+	CallHierarchyPrepareParams       = protocol.CallHierarchyPrepareParams
+	CallHierarchyItem                = protocol.CallHierarchyItem
+	CallHierarchyIncomingCallsParams = protocol.CallHierarchyIncomingCallsParams
+	CallHierarchyIncomingCall        = protocol.CallHierarchyIncomingCall
+	CallHierarchyOutgoingCallsParams = protocol.CallHierarchyOutgoingCallsParams
+	CallHierarchyOutgoingCall        = protocol.CallHierarchyOutgoingCall
+
+	WorkspaceSymbolParams = protocol.WorkspaceSymbolParams
+	SymbolInformation     = protocol.SymbolInformation
+	SymbolKind            = protocol.SymbolKind
+
+	SemanticTokenTypes        = protocol.SemanticTokenTypes
+	SemanticTokenModifiers    = protocol.SemanticTokenModifiers
+	SemanticTokensParams      = protocol.SemanticTokensParams
+	SemanticTokensRangeParams = protocol.SemanticTokensRangeParams
+	SemanticTokensDeltaParams = protocol.SemanticTokensDeltaParams
+	SemanticTokensDelta       = protocol.SemanticTokensDelta
+	SemanticTokensEdit        = protocol.SemanticTokensEdit
+	SemanticTokens            = protocol.SemanticTokens
 
 	SignatureHelpParams  = protocol.SignatureHelpParams
 	SignatureHelp        = protocol.SignatureHelp
 	SignatureInformation = protocol.SignatureInformation
 	ParameterInformation = protocol.ParameterInformation
 
-	InitializeParams     = protocol.InitializeParams
-	InitializedParams    = protocol.InitializedParams
-	ExecuteCommandParams = protocol.ExecuteCommandParams
+	LinkedEditingRangeParams = protocol.LinkedEditingRangeParams
+	LinkedEditingRanges      = protocol.LinkedEditingRanges
+
+	SelectionRangeParams = protocol.SelectionRangeParams
+	SelectionRange       = protocol.SelectionRange
+
+	FoldingRangeParams = protocol.FoldingRangeParams
+	FoldingRange       = protocol.FoldingRange
+
+	DocumentColorParams     = protocol.DocumentColorParams
+	ColorInformation        = protocol.ColorInformation
+	ColorPresentationParams = protocol.ColorPresentationParams
+	ColorPresentation       = protocol.ColorPresentation
+	Color                   = protocol.Color
+
+	CodeActionParams  = protocol.CodeActionParams
+	CodeActionContext = protocol.CodeActionContext
+	CodeAction        = protocol.CodeAction
+	CodeActionKind    = protocol.CodeActionKind
+
+	CodeLensParams = protocol.CodeLensParams
+	CodeLens       = protocol.CodeLens
+	Command        = protocol.Command
+
+	InitializeParams      = protocol.InitializeParams
+	InitializeResult      = protocol.InitializeResult
+	ServerInfo            = protocol.ServerInfo
+	InitializedParams     = protocol.InitializedParams
+	ExecuteCommandParams  = protocol.ExecuteCommandParams
+	ExecuteCommandOptions = protocol.ExecuteCommandOptions
+
+	ServerCapabilities      = protocol.ServerCapabilities
+	TextDocumentSyncOptions = protocol.TextDocumentSyncOptions
+	PositionEncodingKind    = protocol.PositionEncodingKind
+
+	CompletionOptions               = protocol.CompletionOptions
+	SignatureHelpOptions            = protocol.SignatureHelpOptions
+	CodeActionOptions               = protocol.CodeActionOptions
+	CodeLensOptions                 = protocol.CodeLensOptions
+	DocumentLinkOptions             = protocol.DocumentLinkOptions
+	DocumentOnTypeFormattingOptions = protocol.DocumentOnTypeFormattingOptions
+	RenameOptions                   = protocol.RenameOptions
+	InlayHintOptions                = protocol.InlayHintOptions
+	DiagnosticOptions               = protocol.DiagnosticOptions
+	SemanticTokensOptions           = protocol.SemanticTokensOptions
+	SemanticTokensLegend            = protocol.SemanticTokensLegend
+	SemanticTokensFullDelta         = protocol.SemanticTokensFullDelta
+
+	Or_ServerCapabilities_hoverProvider                   = protocol.Or_ServerCapabilities_hoverProvider
+	Or_ServerCapabilities_declarationProvider             = protocol.Or_ServerCapabilities_declarationProvider
+	Or_ServerCapabilities_definitionProvider              = protocol.Or_ServerCapabilities_definitionProvider
+	Or_ServerCapabilities_typeDefinitionProvider          = protocol.Or_ServerCapabilities_typeDefinitionProvider
+	Or_ServerCapabilities_implementationProvider          = protocol.Or_ServerCapabilities_implementationProvider
+	Or_ServerCapabilities_referencesProvider              = protocol.Or_ServerCapabilities_referencesProvider
+	Or_ServerCapabilities_documentHighlightProvider       = protocol.Or_ServerCapabilities_documentHighlightProvider
+	Or_ServerCapabilities_colorProvider                   = protocol.Or_ServerCapabilities_colorProvider
+	Or_ServerCapabilities_workspaceSymbolProvider         = protocol.Or_ServerCapabilities_workspaceSymbolProvider
+	Or_ServerCapabilities_documentFormattingProvider      = protocol.Or_ServerCapabilities_documentFormattingProvider
+	Or_ServerCapabilities_documentRangeFormattingProvider = protocol.Or_ServerCapabilities_documentRangeFormattingProvider
+	Or_ServerCapabilities_foldingRangeProvider            = protocol.Or_ServerCapabilities_foldingRangeProvider
+	Or_ServerCapabilities_selectionRangeProvider          = protocol.Or_ServerCapabilities_selectionRangeProvider
+	Or_ServerCapabilities_callHierarchyProvider           = protocol.Or_ServerCapabilities_callHierarchyProvider
+	Or_ServerCapabilities_linkedEditingRangeProvider      = protocol.Or_ServerCapabilities_linkedEditingRangeProvider
+	Or_ServerCapabilities_diagnosticProvider              = protocol.Or_ServerCapabilities_diagnosticProvider
+	Or_SemanticTokensOptions_full                         = protocol.Or_SemanticTokensOptions_full
+	Or_SemanticTokensOptions_range                        = protocol.Or_SemanticTokensOptions_range
+
+	ClientCapabilities        = protocol.ClientCapabilities
+	GeneralClientCapabilities = protocol.GeneralClientCapabilities
+	WindowClientCapabilities  = protocol.WindowClientCapabilities
+
+	ProgressToken                = protocol.ProgressToken
+	ProgressParams               = protocol.ProgressParams
+	WorkDoneProgressCreateParams = protocol.WorkDoneProgressCreateParams
+	WorkDoneProgressReport       = protocol.WorkDoneProgressReport
+	WorkDoneProgressEnd          = protocol.WorkDoneProgressEnd
+	PartialResultParams          = protocol.PartialResultParams
+
+	SetTraceParams    = protocol.SetTraceParams
+	TraceValue        = protocol.TraceValue
+	LogTraceParams    = protocol.LogTraceParams
+	LogMessageParams  = protocol.LogMessageParams
+	ShowMessageParams = protocol.ShowMessageParams
+	MessageType       = protocol.MessageType
+
+	DidChangeConfigurationParams = protocol.DidChangeConfigurationParams
+	DidChangeWatchedFilesParams  = protocol.DidChangeWatchedFilesParams
+	FileEvent                    = protocol.FileEvent
+
+	WorkspaceFolder                 = protocol.WorkspaceFolder
+	DidChangeWorkspaceFoldersParams = protocol.DidChangeWorkspaceFoldersParams
+	WorkspaceFoldersChangeEvent     = protocol.WorkspaceFoldersChangeEvent
+	WorkspaceOptions                = protocol.WorkspaceOptions
+	WorkspaceFolders5Gn             = protocol.WorkspaceFolders5Gn
 
 	DidOpenTextDocumentParams   = protocol.DidOpenTextDocumentParams
 	DidChangeTextDocumentParams = protocol.DidChangeTextDocumentParams
 	DidCloseTextDocumentParams  = protocol.DidCloseTextDocumentParams
 	DidSaveTextDocumentParams   = protocol.DidSaveTextDocumentParams
+	WillSaveTextDocumentParams  = protocol.WillSaveTextDocumentParams
+	TextDocumentSaveReason      = protocol.TextDocumentSaveReason
+
+	TextDocumentItem                = protocol.TextDocumentItem
+	VersionedTextDocumentIdentifier = protocol.VersionedTextDocumentIdentifier
+	TextDocumentContentChangeEvent  = protocol.TextDocumentContentChangeEvent
 )
 
 const (
-	SeverityError   = protocol.SeverityError
-	SeverityWarning = protocol.SeverityWarning
+	SeverityError       = protocol.SeverityError
+	SeverityWarning     = protocol.SeverityWarning
+	SeverityInformation = protocol.SeverityInformation
+	SeverityHint        = protocol.SeverityHint
+
+	Unnecessary = protocol.Unnecessary
 
 	TextCompletion      = protocol.TextCompletion
 	ClassCompletion     = protocol.ClassCompletion
@@ -100,15 +239,50 @@ const (
 	MethodCompletion    = protocol.MethodCompletion
 	FunctionCompletion  = protocol.FunctionCompletion
 	ModuleCompletion    = protocol.ModuleCompletion
+	SnippetCompletion   = protocol.SnippetCompletion
+
+	PropertyCompletion   = protocol.PropertyCompletion
+	ValueCompletion      = protocol.ValueCompletion
+	EnumMemberCompletion = protocol.EnumMemberCompletion
+
+	DiagnosticFull      = protocol.DiagnosticFull
+	DiagnosticUnchanged = protocol.DiagnosticUnchanged
+
+	Incremental = protocol.Incremental
 
-	DiagnosticFull = protocol.DiagnosticFull
+	Manual     = protocol.Manual
+	AfterDelay = protocol.AfterDelay
+	FocusOut   = protocol.FocusOut
 
-	Markdown = protocol.Markdown
-	Text     = protocol.Text
+	Markdown  = protocol.Markdown
+	PlainText = protocol.PlainText
+	Text      = protocol.Text
 
 	Write = protocol.Write
 	Read  = protocol.Read
 
+	Off      = protocol.Off
+	Messages = protocol.Messages
+	Verbose  = protocol.Verbose
+
+	Error   = protocol.Error
+	Warning = protocol.Warning
+	Info    = protocol.Info
+	Log     = protocol.Log
+
+	Created = protocol.Created
+	Changed = protocol.Changed
+	Deleted = protocol.Deleted
+
+	Comment = protocol.Comment
+
+	QuickFix              = protocol.QuickFix
+	RefactorExtract       = protocol.RefactorExtract
+	RefactorInline        = protocol.RefactorInline
+	RefactorRewrite       = protocol.RefactorRewrite
+	SourceFixAll          = protocol.SourceFixAll
+	SourceOrganizeImports = protocol.SourceOrganizeImports
+
 	PlainTextTextFormat = protocol.PlainTextTextFormat
 	SnippetTextFormat   = protocol.SnippetTextFormat
 
@@ -135,6 +309,19 @@ const (
 	ModStatic         = protocol.ModStatic
 	ModDefinition     = protocol.ModDefinition
 	ModDefaultLibrary = protocol.ModDefaultLibrary
+
+	TypeInlayHint = protocol.Type
+
+	Class     = protocol.Class
+	Method    = protocol.Method
+	Field     = protocol.Field
+	Interface = protocol.Interface
+	Function  = protocol.Function
+	Variable  = protocol.Variable
+	Constant  = protocol.Constant
+	Struct    = protocol.Struct
+	Event     = protocol.Event
+	File      = protocol.File
 )
 
 // UnmarshalJSON unmarshals msg into the variable pointed to by params.
@@ -161,6 +348,35 @@ type SpxRenameResourceParams struct {
 	NewName string `json:"newName"`
 }
 
+// SpxResourceReferences represents all references to an spx resource in the
+// workspace.
+type SpxResourceReferences struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+	// The references, sorted by document URI and position.
+	References []SpxResourceReference `json:"references"`
+}
+
+// SpxResourceReference represents a reference to an spx resource.
+type SpxResourceReference struct {
+	Location
+
+	// The kind of the reference.
+	Kind SpxResourceRefKind `json:"kind"`
+}
+
+// SpxRenameResourcePreview reports the impact of renaming an spx resource.
+type SpxRenameResourcePreview struct {
+	// The code locations that would be edited, sorted by document URI and
+	// position.
+	Locations []Location `json:"locations"`
+	// The conflicts that prevent the rename. Empty if the rename can be
+	// applied.
+	Conflicts []string `json:"conflicts"`
+	// Whether the rename changes the name of an auto-binding variable.
+	ChangesAutoBinding bool `json:"changesAutoBinding"`
+}
+
 // SpxResourceIdentifier identifies an spx resource.
 type SpxResourceIdentifier struct {
 	// The spx resource's URI.
@@ -175,6 +391,76 @@ func (u SpxResourceURI) HTML() string {
 	return fmt.Sprintf("<resource-preview resource=%s />\n", attr(string(u)))
 }
 
+// SpxRunSpriteParams represents parameters to run a single sprite of the
+// project.
+type SpxRunSpriteParams struct {
+	// The spx sprite resource.
+	Sprite SpxResourceIdentifier `json:"sprite"`
+}
+
+// SpxAddDependencyParams represents parameters to add a dependency providing a
+// package that is not available to the project.
+type SpxAddDependencyParams struct {
+	// The import path of the package.
+	ImportPath string `json:"importPath"`
+
+	// The directory to provide the package in, within the vendor directory.
+	Dir string `json:"dir"`
+}
+
+// SpxGetResourceDetailParams represents parameters to get the detail of an spx
+// resource.
+type SpxGetResourceDetailParams struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+}
+
+// SpxResourceDetail represents the detail of an spx resource.
+type SpxResourceDetail struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+	// The kind of the spx resource, e.g., "Costume".
+	Kind string `json:"kind"`
+	// The location of the metadata entry of the spx resource.
+	Location *Location `json:"location,omitempty"`
+	// The image of the spx resource. Only set for backdrops, scenes, sprites
+	// and costumes that have images.
+	Image *SpxResourceImageDetail `json:"image,omitempty"`
+	// The costumes covered by the spx resource in frame order. Only set for
+	// animations whose frame range is valid.
+	Frames []SpxResourceIdentifier `json:"frames,omitempty"`
+}
+
+// SpxResourceImageDetail represents the detail of the image of an spx
+// resource. Sizes and positions are in stage pixels.
+type SpxResourceImageDetail struct {
+	// The URI of the image file.
+	URI DocumentURI `json:"uri"`
+	// The size of the image. Zero if the image file cannot be decoded.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// The number of image pixels per stage pixel.
+	BitmapResolution int `json:"bitmapResolution"`
+	// The rotation center from the top-left corner of the image. Only set for
+	// costumes, including the default costume of sprites.
+	Pivot *SpxPoint `json:"pivot,omitempty"`
+	// The angle in degrees to turn the image so that it faces right.
+	FaceRight float64 `json:"faceRight"`
+}
+
+// SpxPoint represents a point in stage pixels.
+type SpxPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// SpxOrganizeImportsParams represents parameters to organize the imports of a
+// document.
+type SpxOrganizeImportsParams struct {
+	// The document to organize imports for.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
 // SpxGetDefinitionsParams represents parameters to get definitions at a
 // specific position in a document.
 type SpxGetDefinitionsParams struct {
@@ -232,4 +518,8 @@ type SpxResourceRefDocumentLinkData struct {
 type CompletionItemData struct {
 	// The corresponding definition of the completion item.
 	Definition *SpxDefinitionIdentifier `json:"definition,omitempty"`
+
+	// The corresponding spx resource of the completion item, for resource
+	// name completions.
+	Resource *SpxResourceIdentifier `json:"resource,omitempty"`
 }
@@ -0,0 +1,150 @@
+// Package ranking implements fuzzy matching and ranking of completion
+// candidates.
+package ranking
+
+import (
+	"cmp"
+	"slices"
+	"unicode"
+)
+
+// Per-character score components of a match.
+const (
+	matchScore       = 1.0 // for every matched character
+	wordStartBonus   = 2.0 // for matching at the start of a word
+	consecutiveBonus = 2.0 // for matching right after the previous match
+	exactCaseBonus   = 0.5 // for matching with the same case
+
+	// maxCharScore is the maximum score of a matched character. The word
+	// start and consecutive bonuses do not add up.
+	maxCharScore = matchScore + max(wordStartBonus, consecutiveBonus) + exactCaseBonus
+)
+
+// Matcher matches candidates against a pattern.
+//
+// The pattern matches a candidate if its characters appear in the candidate in
+// order, ignoring case. Matches at the start of words, e.g. "tS" in "turnSpeed"
+// or "ts" in "turn_speed", and runs of consecutive characters score higher.
+type Matcher struct {
+	pattern      []rune
+	lowerPattern []rune
+}
+
+// NewMatcher creates a new [Matcher] for the given pattern.
+func NewMatcher(pattern string) *Matcher {
+	p := []rune(pattern)
+	lower := make([]rune, len(p))
+	for i, r := range p {
+		lower[i] = unicode.ToLower(r)
+	}
+	return &Matcher{pattern: p, lowerPattern: lower}
+}
+
+// Score returns the match score of the candidate in the range [0, 1], where 0
+// means no match. An empty pattern matches any candidate with score 1.
+func (m *Matcher) Score(candidate string) float64 {
+	if len(m.pattern) == 0 {
+		return 1
+	}
+	c := []rune(candidate)
+	if len(c) < len(m.pattern) {
+		return 0
+	}
+
+	// best[j] is the best score of matching the pattern so far with its
+	// last matched character at c[j], or -1 if there is no such match.
+	best := make([]float64, len(c))
+	next := make([]float64, len(c))
+	for i, pr := range m.lowerPattern {
+		bestBefore := -1.0 // best score of the previous row before j-1
+		for j, cr := range c {
+			next[j] = -1
+			if j > 1 && i > 0 {
+				bestBefore = max(bestBefore, best[j-2])
+			}
+			if unicode.ToLower(cr) != pr {
+				continue
+			}
+
+			score := matchScore
+			if cr == m.pattern[i] {
+				score += exactCaseBonus
+			}
+			var wordStart float64
+			if isWordStart(c, j) {
+				wordStart = wordStartBonus
+			}
+			if i == 0 {
+				next[j] = score + wordStart
+				continue
+			}
+			if bestBefore >= 0 {
+				next[j] = bestBefore + score + wordStart
+			}
+			if j > 0 && best[j-1] >= 0 {
+				next[j] = max(next[j], best[j-1]+score+max(wordStart, consecutiveBonus))
+			}
+		}
+		best, next = next, best
+	}
+
+	total := slices.Max(best)
+	if total < 0 {
+		return 0
+	}
+	// Prefer shorter candidates among equally good matches.
+	lengthFactor := 0.9 + 0.1*float64(len(m.pattern))/float64(len(c))
+	return total / (maxCharScore * float64(len(m.pattern))) * lengthFactor
+}
+
+// isWordStart reports whether c[j] starts a word, i.e., it is the first
+// character, follows a non-alphanumeric character, or is an uppercase letter
+// following a lowercase letter or a digit.
+func isWordStart(c []rune, j int) bool {
+	if j == 0 {
+		return true
+	}
+	prev, cur := c[j-1], c[j]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(cur) && !unicode.IsUpper(prev)
+}
+
+// Candidate is a candidate to be ranked.
+type Candidate struct {
+	// Text is the text matched against the pattern.
+	Text string
+
+	// Boost is added to the match score of the candidate if it matches. It
+	// can be negative to deprioritize the candidate.
+	Boost float64
+}
+
+// Rank returns the indices of the candidates that match the pattern, ordered
+// from the best to the worst by their match scores plus boosts. Equally ranked
+// candidates keep their relative order.
+func Rank(pattern string, candidates []Candidate) []int {
+	m := NewMatcher(pattern)
+	type ranked struct {
+		index int
+		score float64
+	}
+	rankeds := make([]ranked, 0, len(candidates))
+	for i, c := range candidates {
+		score := m.Score(c.Text)
+		if score == 0 {
+			continue
+		}
+		rankeds = append(rankeds, ranked{index: i, score: score + c.Boost})
+	}
+	slices.SortStableFunc(rankeds, func(a, b ranked) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	indices := make([]int, len(rankeds))
+	for i, r := range rankeds {
+		indices[i] = r.index
+	}
+	return indices
+}
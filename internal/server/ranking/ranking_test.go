@@ -0,0 +1,78 @@
+package ranking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcherScore(t *testing.T) {
+	t.Run("NoMatch", func(t *testing.T) {
+		m := NewMatcher("xyz")
+		assert.Zero(t, m.Score("turn"))
+		assert.Zero(t, m.Score("xy"))
+	})
+
+	t.Run("EmptyPattern", func(t *testing.T) {
+		assert.Equal(t, 1.0, NewMatcher("").Score("turn"))
+	})
+
+	t.Run("ExactMatchScoresHighest", func(t *testing.T) {
+		m := NewMatcher("turn")
+		assert.InDelta(t, 1.0, m.Score("turn"), 1e-9)
+		assert.Greater(t, m.Score("turn"), m.Score("turnTo"))
+	})
+
+	t.Run("WordStarts", func(t *testing.T) {
+		m := NewMatcher("tS")
+		assert.Greater(t, m.Score("turnSpeed"), m.Score("toast"))
+		assert.Greater(t, NewMatcher("ts").Score("turn_speed"), NewMatcher("ts").Score("toast"))
+	})
+
+	t.Run("Consecutive", func(t *testing.T) {
+		m := NewMatcher("cos")
+		assert.Greater(t, m.Score("costume"), m.Score("clones"))
+	})
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		m := NewMatcher("setcos")
+		assert.NotZero(t, m.Score("setCostume"))
+		assert.Greater(t, NewMatcher("setCos").Score("setCostume"), m.Score("setCostume"))
+	})
+}
+
+func TestRank(t *testing.T) {
+	t.Run("ByScore", func(t *testing.T) {
+		assert.Equal(t, []int{1, 2, 0}, Rank("cos", []Candidate{
+			{Text: "clones"},
+			{Text: "costume"},
+			{Text: "setCostume"},
+		}))
+	})
+
+	t.Run("Boost", func(t *testing.T) {
+		assert.Equal(t, []int{1, 0}, Rank("cos", []Candidate{
+			{Text: "costume"},
+			{Text: "setCostume", Boost: 0.5},
+		}))
+		assert.Equal(t, []int{1, 0}, Rank("", []Candidate{
+			{Text: "Classfname", Boost: -0.5},
+			{Text: "costume"},
+		}))
+	})
+
+	t.Run("Stable", func(t *testing.T) {
+		assert.Equal(t, []int{0, 1, 2}, Rank("", []Candidate{
+			{Text: "c"},
+			{Text: "b"},
+			{Text: "a"},
+		}))
+	})
+
+	t.Run("DropsNonMatching", func(t *testing.T) {
+		assert.Equal(t, []int{1}, Rank("tu", []Candidate{
+			{Text: "say"},
+			{Text: "turn"},
+		}))
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"go/types"
 
 	gopast "github.com/goplus/gop/ast"
@@ -9,8 +10,8 @@ import (
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_references
-func (s *Server) textDocumentReferences(params *ReferenceParams) ([]Location, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentReferences(ctx context.Context, params *ReferenceParams) ([]Location, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -19,14 +20,22 @@ func (s *Server) textDocumentReferences(params *ReferenceParams) ([]Location, er
 	}
 	position := result.toPosition(astFile, params.Position)
 
+	var locations []Location
+
+	spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position)
+	if spxResourceRef != nil && spxResourceRef.Kind != SpxResourceRefKindConstantReference {
+		locations = append(locations, s.findSpxResourceRefLocations(result, spxResourceRef.ID, params.Context.IncludeDeclaration)...)
+	}
+
 	typeInfo := getTypeInfo(result.proj)
 	obj := typeInfo.ObjectOf(result.identAtASTFilePosition(astFile, position))
 	if obj == nil {
-		return nil, nil
+		if len(locations) == 0 {
+			return nil, nil
+		}
+		return s.referenceLocationsResult(ctx, params, locations)
 	}
 
-	var locations []Location
-
 	locations = append(locations, s.findReferenceLocations(result, obj)...)
 
 	if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
@@ -46,7 +55,20 @@ func (s *Server) textDocumentReferences(params *ReferenceParams) ([]Location, er
 		}
 	}
 
-	return deduplicateLocations(locations), nil
+	return s.referenceLocationsResult(ctx, params, locations)
+}
+
+// referenceLocationsResult returns the deduplicated locations as the result of
+// the given textDocument/references request, streaming them to the client by
+// document if it asked for partial results.
+func (s *Server) referenceLocationsResult(ctx context.Context, params *ReferenceParams, locations []Location) ([]Location, error) {
+	locations = deduplicateLocations(locations)
+	if p := s.newPartialResultProgress(params.PartialResultParams); p != nil {
+		return sendPartialResultsByDocument(ctx, p, locations, func(loc Location) DocumentURI {
+			return loc.URI
+		})
+	}
+	return locations, nil
 }
 
 // findReferenceLocations returns all locations where the given object is referenced.
@@ -62,6 +84,26 @@ func (s *Server) findReferenceLocations(result *compileResult, obj types.Object)
 	return locations
 }
 
+// findSpxResourceRefLocations returns all locations where the spx resource
+// identified by id is referenced. Auto-binding declarations are only included
+// if includeDeclaration is true.
+func (s *Server) findSpxResourceRefLocations(result *compileResult, id SpxResourceID, includeDeclaration bool) []Location {
+	var locations []Location
+	for _, ref := range result.spxResourceRefs {
+		if ref.ID != id {
+			continue
+		}
+		if ref.Kind == SpxResourceRefKindAutoBinding && !includeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{
+			URI:   result.nodeDocumentURI(ref.Node),
+			Range: result.spxResourceRefRange(ref),
+		})
+	}
+	return locations
+}
+
 // handleMethodReferences finds all references to a method, including interface
 // implementations and interface method references.
 func (s *Server) handleMethodReferences(result *compileResult, fn *types.Func) []Location {
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mainSpxMySpriteRef, err := s.textDocumentReferences(&ReferenceParams{
+		mainSpxMySpriteRef, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 2},
@@ -61,7 +62,7 @@ onStart => {
 			},
 		})
 
-		mainSpxTurnRef, err := s.textDocumentReferences(&ReferenceParams{
+		mainSpxTurnRef, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 9},
@@ -89,13 +90,83 @@ onStart => {
 		})
 	})
 
+	t.Run("SpxResource", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	Sound1 Sound
+)
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	play Sound1
+	play "Sound1"
+}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		refs, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, refs, 3)
+		assert.Contains(t, refs, Location{
+			URI: "file:///main.spx",
+			Range: Range{
+				Start: Position{Line: 4, Character: 6},
+				End:   Position{Line: 4, Character: 12},
+			},
+		})
+		assert.Contains(t, refs, Location{
+			URI: "file:///MySprite.spx",
+			Range: Range{
+				Start: Position{Line: 2, Character: 6},
+				End:   Position{Line: 2, Character: 12},
+			},
+		})
+		assert.Contains(t, refs, Location{
+			URI: "file:///MySprite.spx",
+			Range: Range{
+				Start: Position{Line: 3, Character: 7},
+				End:   Position{Line: 3, Character: 13},
+			},
+		})
+
+		refsWithDecl, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 3, Character: 8},
+			},
+			Context: ReferenceContext{
+				IncludeDeclaration: true,
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, refsWithDecl, 4)
+		assert.Contains(t, refsWithDecl, Location{
+			URI: "file:///main.spx",
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 7},
+			},
+		})
+	})
+
 	t.Run("InvalidPosition", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`var x int`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		refs, err := s.textDocumentReferences(&ReferenceParams{
+		refs, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 99, Character: 99},
@@ -112,4 +183,65 @@ onStart => {
 			},
 		})
 	})
+	t.Run("PartialResults", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Right
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+
+		var token ProgressToken = "refs"
+		refs, err := s.textDocumentReferences(context.Background(), &ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 2},
+			},
+			Context:             ReferenceContext{IncludeDeclaration: true},
+			PartialResultParams: PartialResultParams{PartialResultToken: &token},
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, refs)
+		assert.Empty(t, refs)
+
+		chunks := partialResults[Location](t, replier, "refs")
+		require.Len(t, chunks, 2)
+		var all []Location
+		for _, chunk := range chunks {
+			require.NotEmpty(t, chunk)
+			for _, loc := range chunk {
+				assert.Equal(t, chunk[0].URI, loc.URI)
+			}
+			all = append(all, chunk...)
+		}
+		assert.Len(t, all, 3)
+		assert.Contains(t, all, Location{
+			URI: "file:///MySprite.spx",
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 9},
+			},
+		})
+
+		t.Run("Canceled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := sendPartialResultsByDocument(ctx, s.newPartialResultProgress(PartialResultParams{PartialResultToken: &token}), all, func(loc Location) DocumentURI {
+				return loc.URI
+			})
+			assert.ErrorIs(t, err, context.Canceled)
+		})
+	})
 }
@@ -1,17 +1,27 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go/types"
+	"maps"
+	"path"
 	"slices"
+	"strings"
 
 	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/internal/vfs"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_prepareRename
-func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*Range, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentPrepareRename(ctx context.Context, params *PrepareRenameParams) (*Range, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +30,10 @@ func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*Range,
 	}
 	position := result.toPosition(astFile, params.Position)
 
+	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil {
+		return util.ToPtr(result.spxResourceRefRange(*spxResourceRef)), nil
+	}
+
 	ident := result.identAtASTFilePosition(astFile, position)
 	if ident == nil {
 		return nil, nil
@@ -38,8 +52,20 @@ func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*Range,
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_rename
-func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentRename(ctx context.Context, params *RenameParams) (*WorkspaceEdit, error) {
+	edit, err := s.renameEdit(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkWritable(edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// renameEdit returns the edit of the rename of the given params.
+func (s *Server) renameEdit(ctx context.Context, params *RenameParams) (*WorkspaceEdit, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -49,12 +75,19 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 	position := result.toPosition(astFile, params.Position)
 
 	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil {
-		return s.spxRenameResourcesWithCompileResult(result, []SpxRenameResourceParams{{
+		param := SpxRenameResourceParams{
 			Resource: SpxResourceIdentifier{
 				URI: spxResourceRef.ID.URI(),
 			},
 			NewName: params.NewName,
-		}})
+		}
+		switch spxResourceRef.Kind {
+		case SpxResourceRefKindAutoBinding, SpxResourceRefKindAutoBindingReference:
+			// Renaming an auto-binding variable alone would break its
+			// binding, so the resource metadata is renamed as well.
+			return s.spxRenameResourceWithCompileResult(result, param)
+		}
+		return s.spxRenameResourcesWithCompileResult(result, []SpxRenameResourceParams{param})
 	}
 
 	typeInfo := getTypeInfo(result.proj)
@@ -62,6 +95,9 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 	if !isRenameableObject(obj) {
 		return nil, nil
 	}
+	if !goptoken.IsIdentifier(params.NewName) {
+		return nil, fmt.Errorf("new name %q is not a valid identifier", params.NewName)
+	}
 	defIdent := result.defIdentFor(obj)
 	if defIdent == nil || !result.isInFset(defIdent.Pos()) {
 		return nil, fmt.Errorf("failed to find definition of object %q", obj.Name())
@@ -88,6 +124,93 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 	return &workspaceEdit, nil
 }
 
+// checkSpxResourceAutoBindingRename reports an error if renaming the spx
+// resource identified by id to newName would break its auto-binding, i.e. the
+// resource is auto-bound to a variable and newName is not a valid identifier.
+func checkSpxResourceAutoBindingRename(result *compileResult, id SpxResourceID, newName string) error {
+	if goptoken.IsIdentifier(newName) || !hasSpxResourceAutoBinding(result, id) {
+		return nil
+	}
+	return fmt.Errorf("new name %q is not a valid identifier for auto-binding variable %q", newName, id.Name())
+}
+
+// hasSpxResourceAutoBinding reports whether the spx resource identified by id
+// is auto-bound to a variable.
+func hasSpxResourceAutoBinding(result *compileResult, id SpxResourceID) bool {
+	return slices.ContainsFunc(result.spxResourceRefs, func(ref SpxResourceRef) bool {
+		return ref.ID == id && ref.Kind == SpxResourceRefKindAutoBinding
+	})
+}
+
+// checkSpxResourceName returns an error if the given new name of an spx
+// resource is empty or is not a single path element, as names are used as
+// the names of resource directories and files.
+func checkSpxResourceName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("new name cannot be empty")
+	case name == "." || name == ".." || strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("new name %q must not be a path", name)
+	}
+	return nil
+}
+
+// spxResourceRenameConflicts returns the conflicts that prevent renaming the
+// spx resource identified by id to newName. Unlike the rename itself, it
+// collects all conflicts instead of stopping at the first one.
+func spxResourceRenameConflicts(result *compileResult, id SpxResourceID, newName string) []string {
+	if !result.spxResourceSet.Contains(id) {
+		return []string{fmt.Sprintf("resource %q does not exist", id.URI())}
+	}
+	if err := checkSpxResourceName(newName); err != nil {
+		return []string{err.Error()}
+	}
+
+	var (
+		conflicts []string
+		newID     SpxResourceID
+	)
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		newID = SpxBackdropResourceID{BackdropName: newName}
+	case SpxSoundResourceID:
+		newID = SpxSoundResourceID{SoundName: newName}
+	case SpxSpriteResourceID:
+		newID = SpxSpriteResourceID{SpriteName: newName}
+	case SpxSpriteCostumeResourceID:
+		newID = SpxSpriteCostumeResourceID{SpriteName: id.SpriteName, CostumeName: newName}
+	case SpxSpriteAnimationResourceID:
+		newID = SpxSpriteAnimationResourceID{SpriteName: id.SpriteName, AnimationName: newName}
+	case SpxWidgetResourceID:
+		newID = SpxWidgetResourceID{WidgetName: newName}
+	default:
+		return []string{fmt.Sprintf("renaming resource %q is not supported", id.URI())}
+	}
+	if newID != id && result.spxResourceSet.Contains(newID) {
+		conflicts = append(conflicts, fmt.Sprintf("resource %q already exists", newID.URI()))
+	}
+
+	if err := checkSpxResourceAutoBindingRename(result, id, newName); err != nil {
+		conflicts = append(conflicts, err.Error())
+	} else if newID != id && hasSpxResourceAutoBinding(result, id) {
+		// The auto-binding variable is a field of Game, so it must not
+		// collide with other fields or methods of Game.
+		if game, ok := getPkg(result.proj).Scope().Lookup("Game").(*types.TypeName); ok {
+			if obj, _, _ := types.LookupFieldOrMethod(game.Type(), true, game.Pkg(), newName); obj != nil {
+				conflicts = append(conflicts, fmt.Sprintf("new name %q conflicts with existing Game member %q", newName, obj.Name()))
+			}
+		}
+	}
+
+	// The class type of a sprite is named after the sprite.
+	if _, ok := id.(SpxSpriteResourceID); ok && newID != id {
+		if obj := getPkg(result.proj).Scope().Lookup(newName); obj != nil {
+			conflicts = append(conflicts, fmt.Sprintf("new name %q conflicts with existing declaration %q in the main package", newName, obj.Name()))
+		}
+	}
+	return conflicts
+}
+
 // spxRenameResourceAtRefs updates spx resource names at reference locations by
 // matching the spx resource ID.
 func (s *Server) spxRenameResourceAtRefs(result *compileResult, id SpxResourceID, newName string) map[DocumentURI][]TextEdit {
@@ -160,6 +283,9 @@ func (s *Server) spxRenameSoundResource(result *compileResult, id SpxSoundResour
 	if result.spxResourceSet.Sound(newName) != nil {
 		return nil, fmt.Errorf("sound resource %q already exists", newName)
 	}
+	if err := checkSpxResourceAutoBindingRename(result, id, newName); err != nil {
+		return nil, err
+	}
 	return s.spxRenameResourceAtRefs(result, id, newName), nil
 }
 
@@ -168,6 +294,15 @@ func (s *Server) spxRenameSpriteResource(result *compileResult, id SpxSpriteReso
 	if result.spxResourceSet.Sprite(newName) != nil {
 		return nil, fmt.Errorf("sprite resource %q already exists", newName)
 	}
+	if err := checkSpxResourceAutoBindingRename(result, id, newName); err != nil {
+		return nil, err
+	}
+	return s.spxRenameSpriteResourceEdits(result, id, newName), nil
+}
+
+// spxRenameSpriteResourceEdits returns the text edits renaming an spx sprite
+// resource at its references and at the references to its class type.
+func (s *Server) spxRenameSpriteResourceEdits(result *compileResult, id SpxSpriteResourceID, newName string) map[DocumentURI][]TextEdit {
 	changes := s.spxRenameResourceAtRefs(result, id, newName)
 	seenTextEdits := make(map[DocumentURI]map[TextEdit]struct{})
 	typeInfo := getTypeInfo(result.proj)
@@ -193,7 +328,7 @@ func (s *Server) spxRenameSpriteResource(result *compileResult, id SpxSpriteReso
 			changes[documentURI] = append(changes[documentURI], textEdit)
 		}
 	}
-	return changes, nil
+	return changes
 }
 
 // spxRenameSpriteCostumeResource renames an spx sprite costume resource.
@@ -231,3 +366,274 @@ func (s *Server) spxRenameWidgetResource(result *compileResult, id SpxWidgetReso
 	}
 	return s.spxRenameResourceAtRefs(result, id, newName), nil
 }
+
+// spxRenameResourceMetadata returns the document changes required to rename
+// the metadata files of the spx resource identified by id.
+//
+// Resource names are stored in index.json files: backdrops and widgets in the
+// resource root index.json, costumes and animations in the index.json of
+// their sprite. Sprites and sounds are instead stored as directories named
+// after the resource, so those directories are renamed. Sprites additionally
+// have their names listed in the zorder of the resource root index.json and
+// their code lives in a sprite file named after the sprite, while sounds may
+// be played by sprite animations.
+func (s *Server) spxRenameResourceMetadata(result *compileResult, id SpxResourceID, newName string) ([]DocumentChange, error) {
+	rootDir := result.spxResourceRootDir
+	indexJSONFile := path.Join(rootDir, "index.json")
+	spriteIndexJSONFile := func(spriteName string) string {
+		return path.Join(rootDir, "sprites", spriteName, "index.json")
+	}
+
+	var changes []DocumentChange
+	// editJSON appends the change replacing the ranges found by find in the
+	// JSON file at name with newName, if any.
+	editJSON := func(name string, find func(data []byte) ([]Range, error)) error {
+		data, err := vfs.ReadFile(result.proj, name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		ranges, err := find(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if len(ranges) > 0 {
+			textEdits := make([]TextEdit, 0, len(ranges))
+			for _, r := range ranges {
+				textEdits = append(textEdits, TextEdit{Range: r, NewText: newName})
+			}
+			changes = append(changes, newTextDocumentEditChange(s.toDocumentURI(name), textEdits))
+		}
+		return nil
+	}
+
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		if result.spxResourceSet.Backdrop(id.BackdropName) == nil {
+			return nil, fmt.Errorf("backdrop resource %q not found", id.BackdropName)
+		}
+		if err := editJSON(indexJSONFile, func(data []byte) ([]Range, error) {
+			return jsonStringValueRanges(result.posEncoding, data, id.BackdropName, func(path []string) bool {
+				return len(path) == 3 && path[0] == "backdrops" && path[2] == "name"
+			})
+		}); err != nil {
+			return nil, err
+		}
+	case SpxSoundResourceID:
+		if result.spxResourceSet.Sound(id.SoundName) == nil {
+			return nil, fmt.Errorf("sound resource %q not found", id.SoundName)
+		}
+		for _, spriteName := range slices.Sorted(maps.Keys(result.spxResourceSet.sprites)) {
+			if err := editJSON(spriteIndexJSONFile(spriteName), func(data []byte) ([]Range, error) {
+				return jsonStringValueRanges(result.posEncoding, data, id.SoundName, func(path []string) bool {
+					return len(path) == 4 && path[0] == "fAnimations" && (path[2] == "onStart" || path[2] == "onPlay") && path[3] == "play"
+				})
+			}); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, newRenameFileChange(
+			s.toDocumentURI(path.Join(rootDir, "sounds", id.SoundName)),
+			s.toDocumentURI(path.Join(rootDir, "sounds", newName)),
+		))
+	case SpxSpriteResourceID:
+		if result.spxResourceSet.Sprite(id.SpriteName) == nil {
+			return nil, fmt.Errorf("sprite resource %q not found", id.SpriteName)
+		}
+		if err := editJSON(indexJSONFile, func(data []byte) ([]Range, error) {
+			return spxResourceIndexZorderNameRanges(result.posEncoding, data, id.SpriteName)
+		}); err != nil {
+			return nil, err
+		}
+		changes = append(changes, newRenameFileChange(
+			s.toDocumentURI(path.Join(rootDir, "sprites", id.SpriteName)),
+			s.toDocumentURI(path.Join(rootDir, "sprites", newName)),
+		))
+		if oldSpxFile := id.SpriteName + ".spx"; result.documentURIs[oldSpxFile] != "" {
+			changes = append(changes, newRenameFileChange(
+				result.documentURIs[oldSpxFile],
+				s.toDocumentURI(newName+".spx"),
+			))
+		}
+	case SpxSpriteCostumeResourceID:
+		sprite := result.spxResourceSet.Sprite(id.SpriteName)
+		if sprite == nil {
+			return nil, fmt.Errorf("sprite resource %q not found", id.SpriteName)
+		}
+		if sprite.Costume(id.CostumeName) == nil {
+			return nil, fmt.Errorf("sprite costume resource %q not found", id.CostumeName)
+		}
+		if err := editJSON(spriteIndexJSONFile(id.SpriteName), func(data []byte) ([]Range, error) {
+			ranges, err := jsonStringValueRanges(result.posEncoding, data, id.CostumeName, func(path []string) bool {
+				return len(path) == 3 && path[0] == "costumes" && path[2] == "name"
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(ranges) == 0 {
+				// The costume is cut from a costume group, so its name is
+				// derived from the group instead of being listed.
+				return nil, fmt.Errorf("sprite costume resource %q is part of a costume group and cannot be renamed", id.CostumeName)
+			}
+			frameRanges, err := jsonStringValueRanges(result.posEncoding, data, id.CostumeName, func(path []string) bool {
+				return len(path) == 3 && path[0] == "fAnimations" && (path[2] == "frameFrom" || path[2] == "frameTo")
+			})
+			if err != nil {
+				return nil, err
+			}
+			return append(ranges, frameRanges...), nil
+		}); err != nil {
+			return nil, err
+		}
+	case SpxSpriteAnimationResourceID:
+		sprite := result.spxResourceSet.Sprite(id.SpriteName)
+		if sprite == nil {
+			return nil, fmt.Errorf("sprite resource %q not found", id.SpriteName)
+		}
+		if sprite.Animation(id.AnimationName) == nil {
+			return nil, fmt.Errorf("sprite animation resource %q not found", id.AnimationName)
+		}
+		if err := editJSON(spriteIndexJSONFile(id.SpriteName), func(data []byte) ([]Range, error) {
+			ranges, err := jsonObjectKeyRanges(result.posEncoding, data, id.AnimationName, func(path []string) bool {
+				return len(path) == 1 && path[0] == "fAnimations"
+			})
+			if err != nil {
+				return nil, err
+			}
+			refRanges, err := jsonStringValueRanges(result.posEncoding, data, id.AnimationName, func(path []string) bool {
+				return (len(path) == 1 && path[0] == "defaultAnimation") ||
+					(len(path) == 2 && path[0] == "animBindings")
+			})
+			if err != nil {
+				return nil, err
+			}
+			return append(ranges, refRanges...), nil
+		}); err != nil {
+			return nil, err
+		}
+	case SpxWidgetResourceID:
+		if result.spxResourceSet.Widget(id.WidgetName) == nil {
+			return nil, fmt.Errorf("widget resource %q not found", id.WidgetName)
+		}
+		if err := editJSON(indexJSONFile, func(data []byte) ([]Range, error) {
+			return jsonStringValueRanges(result.posEncoding, data, id.WidgetName, func(path []string) bool {
+				return len(path) == 3 && path[0] == "zorder" && path[2] == "name"
+			})
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spx resource type: %T", id)
+	}
+	return changes, nil
+}
+
+// spxResourceIndexZorderNameRanges returns the ranges of all string entries in
+// the zorder of the given resource root index.json content that are equal to
+// name. The ranges exclude the surrounding quotes.
+func spxResourceIndexZorderNameRanges(posEncoding position.Encoding, indexJSON []byte, name string) ([]Range, error) {
+	return jsonStringValueRanges(posEncoding, indexJSON, name, func(path []string) bool {
+		return len(path) == 2 && path[0] == "zorder"
+	})
+}
+
+// jsonStringValueRanges returns the ranges of all string values in the given
+// JSON document that are equal to value and whose paths are accepted by match.
+// The ranges exclude the surrounding quotes and are in posEncoding.
+func jsonStringValueRanges(posEncoding position.Encoding, data []byte, value string, match func(path []string) bool) ([]Range, error) {
+	var ranges []Range
+	err := walkJSON(data, func(path []string, raw json.RawMessage, start, end int) bool {
+		if !match(path) {
+			return true
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil && s == value {
+			ranges = append(ranges, Range{
+				Start: posEncoding.Position(data, start+1),
+				End:   posEncoding.Position(data, end-1),
+			})
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// jsonObjectKeyRanges returns the ranges of all keys in the given JSON
+// document that are equal to key and that belong to objects whose paths are
+// accepted by match. The ranges exclude the surrounding quotes and are in
+// posEncoding.
+func jsonObjectKeyRanges(posEncoding position.Encoding, data []byte, key string, match func(path []string) bool) ([]Range, error) {
+	var (
+		ranges  []Range
+		keysErr error
+	)
+	err := walkJSON(data, func(path []string, raw json.RawMessage, start, end int) bool {
+		if !match(path) {
+			return true
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return false
+		}
+		for dec.More() {
+			keyStart := int(dec.InputOffset())
+			keyTok, err := dec.Token()
+			if err != nil {
+				keysErr = err
+				return false
+			}
+			keyEnd := int(dec.InputOffset())
+			keyStart += bytes.IndexByte(raw[keyStart:keyEnd], '"')
+			if keyTok.(string) == key {
+				ranges = append(ranges, Range{
+					Start: posEncoding.Position(data, start+keyStart+1),
+					End:   posEncoding.Position(data, start+keyEnd-1),
+				})
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				keysErr = err
+				return false
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if keysErr != nil {
+		return nil, keysErr
+	}
+	return ranges, nil
+}
+
+// newTextDocumentEditChange creates a [DocumentChange] that applies the given
+// text edits to the document identified by documentURI.
+func newTextDocumentEditChange(documentURI DocumentURI, textEdits []TextEdit) DocumentChange {
+	edits := make([]Or_TextDocumentEdit_edits_Elem, 0, len(textEdits))
+	for _, textEdit := range textEdits {
+		edits = append(edits, Or_TextDocumentEdit_edits_Elem{Value: textEdit})
+	}
+	return DocumentChange{
+		TextDocumentEdit: &TextDocumentEdit{
+			TextDocument: OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: documentURI},
+			},
+			Edits: edits,
+		},
+	}
+}
+
+// newRenameFileChange creates a [DocumentChange] that renames the file or
+// directory at oldURI to newURI.
+func newRenameFileChange(oldURI, newURI DocumentURI) DocumentChange {
+	return DocumentChange{
+		RenameFile: &RenameFile{
+			Kind:   "rename",
+			OldURI: oldURI,
+			NewURI: newURI,
+		},
+	}
+}
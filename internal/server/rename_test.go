@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		range1, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+		range1, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -40,7 +41,7 @@ onStart => {
 			End:   Position{Line: 2, Character: 9},
 		}, *range1)
 
-		range2, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+		range2, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 0},
@@ -53,7 +54,7 @@ onStart => {
 			End:   Position{Line: 4, Character: 8},
 		}, *range2)
 
-		range3, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+		range3, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 10},
@@ -63,6 +64,31 @@ onStart => {
 		require.Nil(t, range3)
 	})
 
+	t.Run("SpxResourceStringLiteral", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		range1, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, range1)
+		assert.Equal(t, Range{
+			Start: Position{Line: 1, Character: 6},
+			End:   Position{Line: 1, Character: 12},
+		}, *range1)
+	})
+
 	t.Run("ThisPtr", func(t *testing.T) {
 		m := map[string][]byte{
 			"main.spx": []byte(`
@@ -81,7 +107,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		range1, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+		range1, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 5},
@@ -90,7 +116,7 @@ onClick => {
 		require.NoError(t, err)
 		require.Nil(t, range1)
 
-		range2, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+		range2, err := s.textDocumentPrepareRename(context.Background(), &PrepareRenameParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 5},
@@ -123,7 +149,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 			Position:     Position{Line: 4, Character: 6},
 			NewName:      "Bar",
@@ -174,7 +200,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 			Position:     Position{Line: 1, Character: 9},
 			NewName:      "Bar",
@@ -218,21 +244,22 @@ onStart => {
 	MySprite.turn Right
 }
 `),
-			"assets/index.json":                  []byte(`{}`),
+			"assets/index.json":                  []byte(`{"zorder": ["MySprite"]}`),
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 			Position:     Position{Line: 2, Character: 4},
 			NewName:      "NewSprite",
 		})
 		require.NoError(t, err)
 		require.NotNil(t, workspaceEdit)
-		require.NotNil(t, workspaceEdit.Changes)
+		assert.Nil(t, workspaceEdit.Changes)
 
-		mainSpxChanges := workspaceEdit.Changes["file:///main.spx"]
+		changes, renameFiles := splitDocumentChanges(t, workspaceEdit.DocumentChanges)
+		mainSpxChanges := changes["file:///main.spx"]
 		require.Len(t, mainSpxChanges, 2)
 		assert.Contains(t, mainSpxChanges, TextEdit{
 			Range: Range{
@@ -249,7 +276,7 @@ onStart => {
 			NewText: "NewSprite",
 		})
 
-		mySpriteSpxChanges := workspaceEdit.Changes["file:///MySprite.spx"]
+		mySpriteSpxChanges := changes["file:///MySprite.spx"]
 		require.Len(t, mySpriteSpxChanges, 1)
 		assert.Contains(t, mySpriteSpxChanges, TextEdit{
 			Range: Range{
@@ -258,6 +285,77 @@ onStart => {
 			},
 			NewText: "NewSprite",
 		})
+
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 0, Character: 13},
+					End:   Position{Line: 0, Character: 21},
+				},
+				NewText: "NewSprite",
+			},
+		}, changes["file:///assets/index.json"])
+
+		assert.Equal(t, []*RenameFile{
+			{
+				Kind:   "rename",
+				OldURI: "file:///assets/sprites/MySprite",
+				NewURI: "file:///assets/sprites/NewSprite",
+			},
+			{
+				Kind:   "rename",
+				OldURI: "file:///MySprite.spx",
+				NewURI: "file:///NewSprite.spx",
+			},
+		}, renameFiles)
+	})
+
+	t.Run("SpxResourceInvalidAutoBindingName", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Right
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+			Position:     Position{Line: 2, Character: 4},
+			NewName:      "My Sprite",
+		})
+		require.EqualError(t, err, `failed to rename spx resource "spx://resources/sprites/MySprite": new name "My Sprite" is not a valid identifier for auto-binding variable "MySprite"`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("InvalidIdentifier", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+const Foo = "bar"
+println Foo
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 1, Character: 6},
+			NewName:      "func",
+		})
+		require.EqualError(t, err, `new name "func" is not a valid identifier`)
+		require.Nil(t, workspaceEdit)
 	})
 
 	t.Run("SpxResourceInOtherSpriteFiles", func(t *testing.T) {
@@ -298,16 +396,17 @@ onCloned => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+		workspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///Bullet.spx"},
 			Position:     Position{Line: 2, Character: 10},
 			NewName:      "Jet",
 		})
 		require.NoError(t, err)
 		require.NotNil(t, workspaceEdit)
-		require.NotNil(t, workspaceEdit.Changes)
+		assert.Nil(t, workspaceEdit.Changes)
 
-		mainSpxChanges := workspaceEdit.Changes["file:///main.spx"]
+		changes, renameFiles := splitDocumentChanges(t, workspaceEdit.DocumentChanges)
+		mainSpxChanges := changes["file:///main.spx"]
 		require.Len(t, mainSpxChanges, 2)
 		assert.Contains(t, mainSpxChanges, TextEdit{
 			Range: Range{
@@ -324,7 +423,7 @@ onCloned => {
 			NewText: "Jet",
 		})
 
-		bulletSpxChanges := workspaceEdit.Changes["file:///Bullet.spx"]
+		bulletSpxChanges := changes["file:///Bullet.spx"]
 		require.Len(t, bulletSpxChanges, 2)
 		assert.Contains(t, bulletSpxChanges, TextEdit{
 			Range: Range{
@@ -340,6 +439,19 @@ onCloned => {
 			},
 			NewText: "Jet",
 		})
+
+		assert.Equal(t, []*RenameFile{
+			{
+				Kind:   "rename",
+				OldURI: "file:///assets/sprites/MyAircraft",
+				NewURI: "file:///assets/sprites/Jet",
+			},
+			{
+				Kind:   "rename",
+				OldURI: "file:///MyAircraft.spx",
+				NewURI: "file:///Jet.spx",
+			},
+		}, renameFiles)
 	})
 
 	t.Run("ThisPtr", func(t *testing.T) {
@@ -360,7 +472,7 @@ onClick => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mainSpxWorkspaceEdit, err := s.textDocumentRename(&RenameParams{
+		mainSpxWorkspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 			Position:     Position{Line: 2, Character: 5},
 			NewName:      "that",
@@ -368,7 +480,7 @@ onClick => {
 		require.EqualError(t, err, `failed to find definition of object "this"`)
 		require.Nil(t, mainSpxWorkspaceEdit)
 
-		mySpriteSpxWorkspaceEdit, err := s.textDocumentRename(&RenameParams{
+		mySpriteSpxWorkspaceEdit, err := s.textDocumentRename(context.Background(), &RenameParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 			Position:     Position{Line: 2, Character: 5},
 			NewName:      "that",
@@ -393,7 +505,7 @@ onStart => {
 			"assets/index.json": []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -440,7 +552,7 @@ onStart => {
 			"assets/index.json": []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -480,7 +592,7 @@ onStart => {
 			"assets/index.json": []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -528,7 +640,7 @@ run "assets", {Title: "My Game"}
 			"assets/index.json": []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"},{"name":"backdrop2","path":"backdrop2.png"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -560,7 +672,7 @@ onStart => {
 			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -610,7 +722,7 @@ run "assets", {Title: "My Game"}
 			"assets/sounds/Sound2/index.json": []byte(`{"path":"sound2.wav"}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -642,7 +754,7 @@ onStart => {
 			"assets/sprites/Sprite1/index.json": []byte(`{}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -699,7 +811,7 @@ onStart => {
 			"assets/sprites/Sprite1/index.json": []byte(`{}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -771,7 +883,7 @@ onStart => {
 			"assets/sprites/Sprite2/index.json": []byte(`{}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -803,7 +915,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -853,7 +965,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -883,7 +995,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -915,7 +1027,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"fAnimations":{"anim1":{}}}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -965,7 +1077,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"fAnimations":{"anim1":{},"anim2":{}}}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -995,7 +1107,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{"fAnimations":{"anim1":{}}}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -1019,10 +1131,10 @@ onStart => {
 	getWidget Monitor, "widget1"
 }
 `),
-			"assets/index.json": []byte(`{"zorder":[{"name":"widget1"}]}`),
+			"assets/index.json": []byte(`{"zorder":[{"name":"widget1","type":"monitor"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -1054,10 +1166,10 @@ onStart => {
 	getWidget Monitor, "widget1"
 }
 `),
-			"assets/index.json": []byte(`{"zorder":[{"name":"widget1"},{"name":"widget2"}]}`),
+			"assets/index.json": []byte(`{"zorder":[{"name":"widget1","type":"monitor"},{"name":"widget2","type":"monitor"}]}`),
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
-		result, err := s.compile()
+		result, err := s.compile(context.Background())
 		require.NoError(t, err)
 		require.False(t, result.hasErrorSeverityDiagnostic)
 
@@ -1069,3 +1181,26 @@ onStart => {
 		require.Nil(t, changes)
 	})
 }
+
+// splitDocumentChanges splits the given document changes into the text edits
+// of each document and the file renames.
+func splitDocumentChanges(t *testing.T, documentChanges []DocumentChange) (map[DocumentURI][]TextEdit, []*RenameFile) {
+	changes := make(map[DocumentURI][]TextEdit)
+	var renameFiles []*RenameFile
+	for _, change := range documentChanges {
+		switch {
+		case change.TextDocumentEdit != nil:
+			documentURI := change.TextDocumentEdit.TextDocument.URI
+			for _, edit := range change.TextDocumentEdit.Edits {
+				textEdit, ok := edit.Value.(TextEdit)
+				require.True(t, ok)
+				changes[documentURI] = append(changes[documentURI], textEdit)
+			}
+		case change.RenameFile != nil:
+			renameFiles = append(renameFiles, change.RenameFile)
+		default:
+			require.Fail(t, "unexpected document change")
+		}
+	}
+	return changes, renameFiles
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// requestKind is the kind of a request or notification, which determines how
+// it is scheduled by [requestScheduler].
+type requestKind int
+
+const (
+	// requestKindRead is the kind of messages that only read the workspace
+	// state, e.g., hover and completion. Reads run concurrently, each against
+	// an immutable snapshot of the workspace.
+	requestKindRead requestKind = iota
+
+	// requestKindMutation is the kind of messages that mutate the workspace
+	// state, e.g., didChange. Mutations run one at a time, in the order they
+	// are received.
+	requestKindMutation
+)
+
+// requestKindOf returns the kind of the request or notification with the
+// given method.
+func requestKindOf(method string) requestKind {
+	switch method {
+	case "initialize",
+		"workspace/didChangeConfiguration",
+		"workspace/didChangeWatchedFiles",
+		"textDocument/didOpen",
+		"textDocument/didChange",
+		"textDocument/didClose":
+		return requestKindMutation
+	}
+	return requestKindRead
+}
+
+// requestScheduler serializes mutations of the workspace state and keeps the
+// latest snapshot of the workspace for reads.
+//
+// Snapshots are never modified once handed out. A mutation that changes files
+// replaces the latest snapshot with an updated copy of it instead, which
+// shares the caches of the unchanged files, so that reads still running
+// against the previous snapshot are not affected.
+type requestScheduler struct {
+	mu       sync.Mutex // held while a mutation runs
+	snapshot *vfs.MapFS // latest snapshot of the workspace, guarded by mu
+}
+
+// newRequestScheduler creates a new request scheduler with the given initial
+// snapshot of the workspace.
+func newRequestScheduler(snapshot *vfs.MapFS) *requestScheduler {
+	return &requestScheduler{snapshot: snapshot}
+}
+
+// mutate runs fn as a mutation, after any running mutation completes. fn may
+// replace q.snapshot.
+func (q *requestScheduler) mutate(fn func() error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return fn()
+}
+
+// snapshot returns the latest snapshot of the workspace for a read. The
+// snapshot is brought up to date with the files from the file map getter and
+// open documents first, which is a mutation itself.
+func (s *Server) snapshot() *vfs.MapFS {
+	var snapshot *vfs.MapFS
+	s.scheduler.mutate(func() error {
+		s.scheduler.snapshot = s.spxResources.updateFiles(s.scheduler.snapshot, s.getFiles())
+		snapshot = s.scheduler.snapshot
+		return nil
+	})
+	return snapshot
+}
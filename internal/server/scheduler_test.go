@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestKindOf(t *testing.T) {
+	for _, method := range []string{"textDocument/hover", "textDocument/completion", "workspace/executeCommand", "initialized", "$/cancelRequest"} {
+		assert.Equal(t, requestKindRead, requestKindOf(method), method)
+	}
+	for _, method := range []string{"initialize", "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose", "workspace/didChangeConfiguration", "workspace/didChangeWatchedFiles"} {
+		assert.Equal(t, requestKindMutation, requestKindOf(method), method)
+	}
+}
+
+func TestServerSnapshot(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+echo "hello"
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), &recordingReplier{}, fileMapGetter(m))
+	initializeServer(t, s)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		snapshot := s.snapshot()
+		assert.Same(t, snapshot, s.snapshot())
+		assert.Same(t, snapshot, s.getProj())
+	})
+
+	t.Run("ChangedByMutation", func(t *testing.T) {
+		snapshot := s.snapshot()
+		result1, err := s.compileAt(context.Background(), snapshot, nil)
+		require.NoError(t, err)
+
+		n, err := jsonrpc2.NewNotification("textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{
+				URI:     "file:///main.spx",
+				Version: 1,
+				Text:    "echo \"world\"\nrun \"assets\", {Title: \"My Game\"}\n",
+			},
+		})
+		require.NoError(t, err)
+		s.diagnosticScheduler.delay = time.Hour
+		require.NoError(t, s.HandleMessage(n))
+
+		// Reads in flight keep their snapshot, while new reads see the
+		// change.
+		content, err := vfs.ReadFile(snapshot, "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, string(m["main.spx"]), string(content))
+		newSnapshot := s.snapshot()
+		require.NotSame(t, snapshot, newSnapshot)
+		content, err = vfs.ReadFile(newSnapshot, "main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "world")
+
+		// The previous snapshot keeps its caches.
+		result2, err := s.compileAt(context.Background(), snapshot, nil)
+		require.NoError(t, err)
+		assert.Same(t, getASTPkg(result1.proj).Files["main.spx"], getASTPkg(result2.proj).Files["main.spx"])
+
+		result3, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, newSnapshot, result3.proj)
+	})
+}
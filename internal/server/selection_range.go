@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_selectionRange
+func (s *Server) textDocumentSelectionRange(ctx context.Context, params *SelectionRangeParams) ([]SelectionRange, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	selectionRanges := make([]SelectionRange, 0, len(params.Positions))
+	for _, position := range params.Positions {
+		pos := result.posAt(astFile, position)
+		if !pos.IsValid() {
+			selectionRanges = append(selectionRanges, SelectionRange{
+				Range: Range{Start: position, End: position},
+			})
+			continue
+		}
+
+		// Build the selection range chain from the outermost node (the
+		// file) to the innermost node at the position.
+		var selectionRange *SelectionRange
+		pushRange := func(rng Range) {
+			if selectionRange != nil && selectionRange.Range == rng {
+				return
+			}
+			selectionRange = &SelectionRange{Range: rng, Parent: selectionRange}
+		}
+		pushRange(Range{End: result.posEncoding.Position(astFile.Code, len(astFile.Code))})
+
+		path := goputil.NodeAt(result.proj, pos)
+		for _, node := range slices.Backward(path) {
+			if _, ok := node.(*gopast.File); ok {
+				continue
+			}
+			if !node.Pos().IsValid() || !node.End().IsValid() {
+				// The ShadowEntry body of Go+ files has no brackets and
+				// therefore no valid start position.
+				continue
+			}
+			pushRange(result.rangeForASTFileNode(astFile, node))
+		}
+		selectionRanges = append(selectionRanges, *selectionRange)
+	}
+	return selectionRanges, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentSelectionRange(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+func greet(name string) {
+	echo "Hi", name
+}
+
+onStart => {
+	greet "Go+"
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		selectionRanges, err := s.textDocumentSelectionRange(context.Background(), &SelectionRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Positions: []Position{
+				{Line: 2, Character: 13},
+				{Line: 6, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, selectionRanges, 2)
+
+		var funcDeclRanges []Range
+		for sr := &selectionRanges[0]; sr != nil; sr = sr.Parent {
+			funcDeclRanges = append(funcDeclRanges, sr.Range)
+		}
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 2, Character: 12}, End: Position{Line: 2, Character: 16}}, // name
+			{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 16}},  // echo "Hi", name
+			{Start: Position{Line: 1, Character: 24}, End: Position{Line: 3, Character: 1}},  // {...}
+			{Start: Position{Line: 1, Character: 0}, End: Position{Line: 3, Character: 1}},   // func greet...
+			{Start: Position{Line: 0, Character: 0}, End: Position{Line: 8, Character: 0}},   // file
+		}, funcDeclRanges)
+
+		var eventHandlerRanges []Range
+		for sr := &selectionRanges[1]; sr != nil; sr = sr.Parent {
+			eventHandlerRanges = append(eventHandlerRanges, sr.Range)
+		}
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 6}},  // greet
+			{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 12}}, // greet "Go+"
+			{Start: Position{Line: 5, Character: 11}, End: Position{Line: 7, Character: 1}}, // {...}
+			{Start: Position{Line: 5, Character: 8}, End: Position{Line: 7, Character: 1}},  // => {...}
+			{Start: Position{Line: 5, Character: 0}, End: Position{Line: 7, Character: 1}},  // onStart => {...}
+			{Start: Position{Line: 0, Character: 0}, End: Position{Line: 8, Character: 0}},  // file
+		}, eventHandlerRanges)
+	})
+
+	t.Run("NonExistentDocument", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		selectionRanges, err := s.textDocumentSelectionRange(context.Background(), &SelectionRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
+			Positions:    []Position{{Line: 0, Character: 0}},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, selectionRanges)
+	})
+}
@@ -1,13 +1,18 @@
 package server
 
 import (
+	"context"
+	"encoding/binary"
 	"go/types"
+	"hash/fnv"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	gopast "github.com/goplus/gop/ast"
 	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/vfs"
 )
 
 var (
@@ -38,9 +43,47 @@ var (
 		ModReadonly,
 		ModStatic,
 		ModDefaultLibrary,
+		ModSpxBackdropResource,
+		ModSpxSoundResource,
+		ModSpxSpriteResource,
+		ModSpxSpriteCostumeResource,
+		ModSpxSpriteAnimationResource,
+		ModSpxWidgetResource,
+		ModSpxSceneResource,
+		ModSpxFontResource,
+		ModSpxStageResource,
 	}
 )
 
+// Semantic token modifiers for spx resource references.
+const (
+	ModSpxBackdropResource        SemanticTokenModifiers = "spxBackdropResource"
+	ModSpxSoundResource           SemanticTokenModifiers = "spxSoundResource"
+	ModSpxSpriteResource          SemanticTokenModifiers = "spxSpriteResource"
+	ModSpxSpriteCostumeResource   SemanticTokenModifiers = "spxSpriteCostumeResource"
+	ModSpxSpriteAnimationResource SemanticTokenModifiers = "spxSpriteAnimationResource"
+	ModSpxWidgetResource          SemanticTokenModifiers = "spxWidgetResource"
+	ModSpxSceneResource           SemanticTokenModifiers = "spxSceneResource"
+	ModSpxFontResource            SemanticTokenModifiers = "spxFontResource"
+	ModSpxStageResource           SemanticTokenModifiers = "spxStageResource"
+)
+
+// semanticTokensLegend returns the legend advertised to the client, which
+// maps token type indexes and modifier bits back to their names.
+func semanticTokensLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{
+		TokenTypes:     make([]string, 0, len(semanticTokenTypesLegend)),
+		TokenModifiers: make([]string, 0, len(semanticTokenModifiersLegend)),
+	}
+	for _, tokenType := range semanticTokenTypesLegend {
+		legend.TokenTypes = append(legend.TokenTypes, string(tokenType))
+	}
+	for _, mod := range semanticTokenModifiersLegend {
+		legend.TokenModifiers = append(legend.TokenModifiers, string(mod))
+	}
+	return legend
+}
+
 // getSemanticTokenTypeIndex returns the index of the given token type in the legend.
 func getSemanticTokenTypeIndex(tokenType SemanticTokenTypes) uint32 {
 	idx := slices.Index(semanticTokenTypesLegend, tokenType)
@@ -69,9 +112,27 @@ type semanticTokenInfo struct {
 	tokenModifiers []SemanticTokenModifiers
 }
 
-// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_semanticTokens
-func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (tokens *SemanticTokens, err error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+// semanticTokensResult is the latest semantic tokens of a document, along
+// with the snapshot of the workspace they were computed at.
+type semanticTokensResult struct {
+	snapshot *vfs.MapFS
+	tokens   *SemanticTokens
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#semanticTokens_fullRequest
+//
+// The tokens of a document are reused until the snapshot of the workspace
+// changes, so that requests against an unchanged workspace, e.g. when the
+// client requests them again after switching editors, neither compile nor
+// tokenize.
+func (s *Server) textDocumentSemanticTokensFull(ctx context.Context, params *SemanticTokensParams) (*SemanticTokens, error) {
+	if prev, ok := s.semanticTokensResults.Load(params.TextDocument.URI); ok {
+		if prev := prev.(*semanticTokensResult); prev.snapshot == s.snapshot() {
+			return prev.tokens, nil
+		}
+	}
+
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -79,20 +140,138 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		return nil, nil
 	}
 
-	if tokensIface, ok := result.computedCache.semanticTokens.Load(params.TextDocument.URI); ok {
-		return &SemanticTokens{
-			Data: tokensIface.([]uint32),
-		}, nil
+	tokenInfos := s.semanticTokenInfosForSpxFile(result, spxFile, astFile)
+	tokens := &SemanticTokens{
+		Data: result.encodeSemanticTokenInfos(astFile, tokenInfos),
 	}
-	defer func() {
-		if err == nil {
-			result.computedCache.semanticTokens.Store(params.TextDocument.URI, slices.Clip(tokens.Data))
+	tokens.ResultID = semanticTokensResultID(tokens.Data)
+	s.semanticTokensResults.Store(params.TextDocument.URI, &semanticTokensResult{
+		snapshot: result.proj,
+		tokens:   tokens,
+	})
+	return tokens, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#semanticTokens_deltaRequest
+//
+// The tokens are those of [Server.textDocumentSemanticTokensFull], so an
+// unchanged workspace yields an empty delta without compiling. Otherwise the
+// tokens are computed for the whole document, as they depend on the types of
+// the whole workspace, and only the tokens between the common prefix and
+// suffix with the previous result are sent.
+func (s *Server) textDocumentSemanticTokensFullDelta(ctx context.Context, params *SemanticTokensDeltaParams) (any, error) {
+	prevIface, hasPrev := s.semanticTokensResults.Load(params.TextDocument.URI)
+
+	tokens, err := s.textDocumentSemanticTokensFull(ctx, &SemanticTokensParams{
+		TextDocument: params.TextDocument,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		return nil, nil
+	}
+	if !hasPrev {
+		return tokens, nil
+	}
+	prevTokens := prevIface.(*semanticTokensResult).tokens
+	if prevTokens.ResultID != params.PreviousResultID {
+		// The client's previous result is unknown to us, fall back to a
+		// full response.
+		return tokens, nil
+	}
+
+	delta := &SemanticTokensDelta{
+		ResultID: tokens.ResultID,
+		Edits:    []SemanticTokensEdit{},
+	}
+	if edit, ok := semanticTokensEditBetween(prevTokens.Data, tokens.Data); ok {
+		delta.Edits = append(delta.Edits, edit)
+	}
+	return delta, nil
+}
+
+// semanticTokensResultID returns the result ID for the given semantic tokens
+// data. The same data always yields the same result ID.
+func semanticTokensResultID(data []uint32) string {
+	h := fnv.New64a()
+	var buf [4]byte
+	for _, v := range data {
+		binary.LittleEndian.PutUint32(buf[:], v)
+		h.Write(buf[:])
+	}
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// semanticTokensEditBetween returns the single edit that transforms prev into
+// next by replacing everything between their common prefix and suffix. It
+// reports false if prev and next are equal.
+func semanticTokensEditBetween(prev, next []uint32) (SemanticTokensEdit, bool) {
+	// Only compare whole tokens, so that an edit never splits a token.
+	const tokenSize = 5
+
+	prefix := 0
+	for prefix < len(prev) && prefix < len(next) && prev[prefix] == next[prefix] {
+		prefix++
+	}
+	prefix -= prefix % tokenSize
+	if prefix == len(prev) && prefix == len(next) {
+		return SemanticTokensEdit{}, false
+	}
+
+	suffix := 0
+	for suffix < len(prev)-prefix && suffix < len(next)-prefix &&
+		prev[len(prev)-1-suffix] == next[len(next)-1-suffix] {
+		suffix++
+	}
+	suffix -= suffix % tokenSize
+
+	return SemanticTokensEdit{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(prev) - prefix - suffix),
+		Data:        slices.Clone(next[prefix : len(next)-suffix]),
+	}, true
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#semanticTokens_rangeRequest
+func (s *Server) textDocumentSemanticTokensRange(ctx context.Context, params *SemanticTokensRangeParams) (*SemanticTokens, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	rangeStart := result.posAt(astFile, params.Range.Start)
+	rangeEnd := result.posAt(astFile, params.Range.End)
+	var tokenInfos []semanticTokenInfo
+	for _, info := range s.semanticTokenInfosForSpxFile(result, spxFile, astFile) {
+		if info.startPos >= rangeEnd {
+			break
+		}
+		if info.endPos > rangeStart {
+			tokenInfos = append(tokenInfos, info)
 		}
+	}
+	return &SemanticTokens{
+		Data: result.encodeSemanticTokenInfos(astFile, tokenInfos),
+	}, nil
+}
+
+// semanticTokenInfosForSpxFile returns the semantic token infos for the given
+// spx file, sorted by position.
+func (s *Server) semanticTokenInfosForSpxFile(result *compileResult, spxFile string, astFile *gopast.File) (tokenInfos []semanticTokenInfo) {
+	documentURI := result.documentURIs[spxFile]
+	if tokenInfosIface, ok := result.computedCache.semanticTokens.Load(documentURI); ok {
+		return tokenInfosIface.([]semanticTokenInfo)
+	}
+	defer func() {
+		result.computedCache.semanticTokens.Store(documentURI, slices.Clip(tokenInfos))
 	}()
 
 	var fset = result.proj.Fset
 	var typeInfo = getTypeInfo(result.proj)
-	var tokenInfos []semanticTokenInfo
 	addToken := func(startPos, endPos goptoken.Pos, tokenType SemanticTokenTypes, tokenModifiers []SemanticTokenModifiers) {
 		if !startPos.IsValid() || !endPos.IsValid() {
 			return
@@ -112,6 +291,20 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		})
 	}
 
+	// Collect spx resource modifiers for spx resource references in this
+	// file, so they can be highlighted differently from plain identifiers and
+	// string literals.
+	spxResourceModifiers := make(map[gopast.Node][]SemanticTokenModifiers)
+	for _, spxResourceRef := range result.spxResourceRefs {
+		if result.nodeFilename(spxResourceRef.Node) != spxFile {
+			continue
+		}
+		mod := semanticTokenModifierForSpxResourceID(spxResourceRef.ID)
+		if !slices.Contains(spxResourceModifiers[spxResourceRef.Node], mod) {
+			spxResourceModifiers[spxResourceRef.Node] = append(spxResourceModifiers[spxResourceRef.Node], mod)
+		}
+	}
+
 	gopast.Inspect(astFile, func(node gopast.Node) bool {
 		if node == nil || !node.Pos().IsValid() {
 			return true
@@ -195,6 +388,7 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 			if obj.Pkg() != nil && obj.Pkg().Path() != "main" && !strings.Contains(obj.Pkg().Path(), ".") {
 				modifiers = append(modifiers, ModDefaultLibrary)
 			}
+			modifiers = append(modifiers, spxResourceModifiers[node]...)
 			addToken(node.Pos(), node.End(), tokenType, modifiers)
 		case *gopast.BasicLit:
 			var tokenType SemanticTokenTypes
@@ -204,7 +398,7 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 			case goptoken.INT, goptoken.FLOAT, goptoken.IMAG, goptoken.RAT:
 				tokenType = NumberType
 			}
-			addToken(node.ValuePos, node.ValuePos+goptoken.Pos(len(node.Value)), tokenType, nil)
+			addToken(node.ValuePos, node.ValuePos+goptoken.Pos(len(node.Value)), tokenType, spxResourceModifiers[node])
 
 			if node.Extra != nil && len(node.Extra.Parts) > 0 {
 				pos := node.ValuePos
@@ -477,18 +671,24 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		}
 		return tokenInfos[i].endPos < tokenInfos[j].endPos
 	})
+	return
+}
 
+// encodeSemanticTokenInfos encodes the given sorted semantic token infos in
+// astFile into the relative integer format defined by the protocol.
+func (r *compileResult) encodeSemanticTokenInfos(astFile *gopast.File, tokenInfos []semanticTokenInfo) []uint32 {
 	var (
-		tokensData         = make([]uint32, 0, len(tokenInfos))
+		tokensData         = make([]uint32, 0, 5*len(tokenInfos))
 		prevLine, prevChar uint32
 	)
 	for _, info := range tokenInfos {
-		start := fset.Position(info.startPos)
-		end := fset.Position(info.endPos)
+		start := r.proj.Fset.Position(info.startPos)
+		end := r.proj.Fset.Position(info.endPos)
 
+		lineStart := start.Offset - (start.Column - 1)
 		line := uint32(start.Line - 1)
-		char := uint32(start.Column - 1)
-		length := uint32(end.Offset - start.Offset)
+		char := uint32(r.posEncoding.Len(astFile.Code[lineStart:start.Offset]))
+		length := uint32(r.posEncoding.Len(astFile.Code[start.Offset:end.Offset]))
 		if line < prevLine || (line == prevLine && char < prevChar) {
 			continue
 		}
@@ -505,7 +705,31 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		prevLine = line
 		prevChar = char
 	}
-	return &SemanticTokens{
-		Data: tokensData,
-	}, nil
+	return tokensData
+}
+
+// semanticTokenModifierForSpxResourceID returns the semantic token modifier
+// for references to the spx resource identified by the given ID.
+func semanticTokenModifierForSpxResourceID(id SpxResourceID) SemanticTokenModifiers {
+	switch id.(type) {
+	case SpxBackdropResourceID:
+		return ModSpxBackdropResource
+	case SpxSoundResourceID:
+		return ModSpxSoundResource
+	case SpxSpriteResourceID:
+		return ModSpxSpriteResource
+	case SpxSpriteCostumeResourceID:
+		return ModSpxSpriteCostumeResource
+	case SpxSpriteAnimationResourceID:
+		return ModSpxSpriteAnimationResource
+	case SpxWidgetResourceID:
+		return ModSpxWidgetResource
+	case SpxSceneResourceID:
+		return ModSpxSceneResource
+	case SpxFontResourceID:
+		return ModSpxFontResource
+	case SpxStageResourceID:
+		return ModSpxStageResource
+	}
+	return ""
 }
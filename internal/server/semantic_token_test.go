@@ -1,8 +1,11 @@
 package server
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -27,7 +30,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		mainSpxTokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+		mainSpxTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		})
 		require.NoError(t, err)
@@ -35,16 +38,16 @@ onStart => {
 		assert.Equal(t, []uint32{
 			1, 0, 3, 9, 0, // var
 			0, 4, 1, 13, 0, // (
-			1, 1, 8, 5, 1, // MySprite
+			1, 1, 8, 5, 65, // MySprite
 			0, 9, 6, 1, 0, // Sprite
 			0, 0, 6, 2, 0, // Sprite
 			1, 0, 1, 13, 0, // )
-			1, 0, 8, 5, 0, // MySprite
+			1, 0, 8, 5, 64, // MySprite
 			0, 8, 1, 13, 0, // .
 			0, 1, 4, 8, 0, // turn
 			0, 5, 4, 5, 6, // Left
 			1, 0, 3, 7, 0, // run
-			0, 4, 8, 11, 0, // assets
+			0, 4, 8, 11, 4096, // assets
 			0, 10, 1, 13, 0, // {
 			0, 1, 5, 6, 0, // Title
 			0, 5, 1, 13, 0, // :
@@ -52,7 +55,7 @@ onStart => {
 			0, 9, 1, 13, 0, // }
 		}, mainSpxTokens.Data)
 
-		mySpriteTokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+		mySpriteTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
 			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 		})
 		require.NoError(t, err)
@@ -61,11 +64,246 @@ onStart => {
 			1, 0, 7, 8, 0, // onStart
 			0, 8, 2, 13, 0, // =>
 			0, 3, 1, 13, 0, // {
-			1, 1, 8, 5, 0, // MySprite
+			1, 1, 8, 5, 64, // MySprite
 			0, 8, 1, 13, 0, // .
 			0, 1, 4, 8, 0, // turn
 			0, 5, 5, 5, 6, // Right
 			1, 0, 1, 13, 0, // }
 		}, mySpriteTokens.Data)
 	})
+
+	t.Run("NonASCII", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`println "你好😀", 1
+`),
+		}
+		for _, tt := range []struct {
+			name        string
+			posEncoding position.Encoding
+			want        []uint32
+		}{
+			{"UTF16", position.UTF16, []uint32{
+				0, 0, 7, 7, 8, // println
+				0, 8, 6, 11, 0, // "你好😀"
+				0, 8, 1, 12, 0, // 1
+			}},
+			{"UTF8", position.UTF8, []uint32{
+				0, 0, 7, 7, 8, // println
+				0, 8, 12, 11, 0, // "你好😀"
+				0, 14, 1, 12, 0, // 1
+			}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+				s.positionEncoding = tt.posEncoding
+
+				tokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				})
+				require.NoError(t, err)
+				require.NotNil(t, tokens)
+				assert.Equal(t, tt.want, tokens.Data)
+			})
+		}
+	})
+}
+
+func TestServerTextDocumentSemanticTokensRange(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+play "MySound"
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+			"assets/sounds/MySound/index.json":   []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		tokens, err := s.textDocumentSemanticTokensRange(context.Background(), &SemanticTokensRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 4, Character: 0},
+				End:   Position{Line: 5, Character: 13},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, tokens)
+		assert.Equal(t, []uint32{
+			4, 0, 4, 8, 0, // play
+			0, 5, 9, 11, 32, // "MySound"
+			1, 0, 8, 5, 64, // MySprite
+			0, 8, 1, 13, 0, // .
+			0, 1, 4, 8, 0, // turn
+		}, tokens.Data)
+	})
+}
+
+func TestServerTextDocumentSemanticTokensFullDelta(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		fullTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, fullTokens)
+		require.NotEmpty(t, fullTokens.ResultID)
+
+		unchanged, err := s.textDocumentSemanticTokensFullDelta(context.Background(), &SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: fullTokens.ResultID,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokensDelta{}, unchanged)
+		assert.Equal(t, fullTokens.ResultID, unchanged.(*SemanticTokensDelta).ResultID)
+		assert.Empty(t, unchanged.(*SemanticTokensDelta).Edits)
+
+		m["main.spx"] = []byte(`
+MySprite.turn Right
+run "assets", {Title: "My Game"}
+`)
+		s.diagnosticScheduler.delay = time.Hour
+		s.InvalidateFiles("main.spx")
+		changed, err := s.textDocumentSemanticTokensFullDelta(context.Background(), &SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: fullTokens.ResultID,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokensDelta{}, changed)
+		changedDelta := changed.(*SemanticTokensDelta)
+		assert.NotEqual(t, fullTokens.ResultID, changedDelta.ResultID)
+		assert.Equal(t, []SemanticTokensEdit{
+			{Start: 15, DeleteCount: 5, Data: []uint32{
+				0, 5, 5, 5, 6, // Right
+			}},
+		}, changedDelta.Edits)
+	})
+
+	t.Run("UnchangedSnapshot", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+
+		fullTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, fullTokens)
+
+		// The tokens are reused as long as the snapshot is unchanged.
+		againTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Same(t, fullTokens, againTokens)
+
+		// Other documents of the same snapshot are computed separately.
+		spriteTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		assert.NotSame(t, fullTokens, spriteTokens)
+
+		// Changing any file of the workspace invalidates the tokens, as they
+		// depend on its types.
+		m["MySprite.spx"] = []byte(`var X int`)
+		s.InvalidateFiles("MySprite.spx")
+		changedTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.NotSame(t, fullTokens, changedTokens)
+		assert.Equal(t, fullTokens.ResultID, changedTokens.ResultID)
+	})
+
+	t.Run("AfterDidClose", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		fullTokens, err := s.textDocumentSemanticTokensFull(context.Background(), &SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.textDocumentDidClose(&DidCloseTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}))
+		_, ok := s.semanticTokensResults.Load(DocumentURI("file:///main.spx"))
+		assert.False(t, ok)
+
+		tokens, err := s.textDocumentSemanticTokensFullDelta(context.Background(), &SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: fullTokens.ResultID,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokens{}, tokens)
+	})
+
+	t.Run("UnknownPreviousResultID", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		tokens, err := s.textDocumentSemanticTokensFullDelta(context.Background(), &SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: "unknown",
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokens{}, tokens)
+		assert.NotEmpty(t, tokens.(*SemanticTokens).Data)
+	})
+}
+
+func TestSemanticTokensEditBetween(t *testing.T) {
+	_, ok := semanticTokensEditBetween([]uint32{1, 2, 3, 4, 5}, []uint32{1, 2, 3, 4, 5})
+	assert.False(t, ok)
+
+	edit, ok := semanticTokensEditBetween(
+		[]uint32{1, 0, 3, 0, 0, 0, 4, 2, 0, 0},
+		[]uint32{1, 0, 3, 0, 0, 1, 0, 2, 0, 0, 0, 4, 2, 0, 0},
+	)
+	assert.True(t, ok)
+	assert.Equal(t, SemanticTokensEdit{Start: 5, DeleteCount: 0, Data: []uint32{1, 0, 2, 0, 0}}, edit)
+
+	edit, ok = semanticTokensEditBetween(
+		[]uint32{1, 0, 3, 0, 0, 0, 4, 2, 0, 0},
+		[]uint32{1, 0, 3, 0, 0},
+	)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(5), edit.Start)
+	assert.Equal(t, uint32(5), edit.DeleteCount)
+	assert.Empty(t, edit.Data)
 }
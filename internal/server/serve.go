@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"io"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// streamReplier is a [MessageReplier] that writes messages to a stream.
+type streamReplier struct {
+	stream jsonrpc2.Stream
+}
+
+// ReplyMessage implements [MessageReplier].
+func (r streamReplier) ReplyMessage(m jsonrpc2.Message) error {
+	return r.stream.Write(m)
+}
+
+// ServeOptions are the options of [Serve].
+type ServeOptions struct {
+	// OnError, if not nil, is called with failures to handle individual
+	// messages, which do not end the session.
+	OnError func(error)
+
+	// TraceWriter, if not nil, is the writer to which all messages of the
+	// session are mirrored. See [Server.SetTraceWriter].
+	TraceWriter io.Writer
+
+	// Telemetry, if not nil, receives the spans and metrics of the session.
+	// See [Server.SetTelemetry].
+	Telemetry Telemetry
+
+	// Folders, if not nil, returns the project of each workspace folder of
+	// multi-root workspaces, which are then served with a server per folder.
+	// The workspace given to [Serve] is then only the one of clients that
+	// open no folder.
+	Folders FolderFactory
+}
+
+// Serve runs a session of a new server for the given workspace over stream,
+// which is transport-agnostic, e.g., stdio, TCP or WebSocket. It returns when
+// the client sends the "exit" notification or closes the stream, or reading
+// the stream fails. Exiting without the "shutdown" request first fails with
+// [ErrExitWithoutShutdown]. The options may be nil.
+func Serve(stream jsonrpc2.Stream, mapFS *vfs.MapFS, fileMapGetter FileMapGetter, opts *ServeOptions) error {
+	if opts == nil {
+		opts = &ServeOptions{}
+	}
+	configure := func(s *Server) {
+		if opts.TraceWriter != nil {
+			s.SetTraceWriter(opts.TraceWriter)
+		}
+		if opts.Telemetry != nil {
+			s.SetTelemetry(opts.Telemetry)
+		}
+	}
+	var h messageHandler
+	if opts.Folders != nil {
+		h = newWorkspaceRouter(streamReplier{stream}, func(uri URI) (*vfs.MapFS, FileMapGetter, error) {
+			if uri == defaultWorkspaceRootURI {
+				return mapFS, fileMapGetter, nil
+			}
+			return opts.Folders(uri)
+		}, configure)
+	} else {
+		s := New(mapFS, streamReplier{stream}, fileMapGetter)
+		configure(s)
+		h = s
+	}
+	for {
+		m, err := stream.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := h.HandleMessage(m); err != nil && opts.OnError != nil {
+			opts.OnError(err)
+		}
+		if n, ok := m.(*jsonrpc2.Notification); ok && n.Method() == "exit" {
+			if !h.isShutDown() {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+	}
+}
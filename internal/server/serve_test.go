@@ -0,0 +1,108 @@
+package server
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx":          []byte(`echo "hello"`),
+		"assets/index.json": []byte(`{}`),
+	}
+	for _, tt := range []struct {
+		name      string
+		newStream func(io.ReadWriteCloser) jsonrpc2.Stream
+	}{
+		{"Header", jsonrpc2.NewHeaderStream},
+		{"Raw", jsonrpc2.NewRawStream},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConn, clientConn := net.Pipe()
+			client := tt.newStream(clientConn)
+			defer client.Close()
+
+			var (
+				mu     sync.Mutex
+				errs   []error
+				served = make(chan error, 1)
+			)
+			go func() {
+				served <- Serve(tt.newStream(serverConn), newMapFSWithoutModTime(m), fileMapGetter(m), &ServeOptions{
+					OnError: func(err error) {
+						mu.Lock()
+						defer mu.Unlock()
+						errs = append(errs, err)
+					},
+				})
+			}()
+
+			call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "initialize", InitializeParams{})
+			require.NoError(t, err)
+			require.NoError(t, client.Write(call))
+			msg, err := client.Read()
+			require.NoError(t, err)
+			resp, ok := msg.(*jsonrpc2.Response)
+			require.True(t, ok)
+			assert.Equal(t, jsonrpc2.NewIntID(1), resp.ID())
+			require.NoError(t, resp.Err())
+			var result InitializeResult
+			require.NoError(t, UnmarshalJSON(resp.Result(), &result))
+			assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+
+			// A message that fails to be handled does not end the session.
+			n, err := jsonrpc2.NewNotification("textDocument/didOpen", []int{1})
+			require.NoError(t, err)
+			require.NoError(t, client.Write(n))
+
+			shutdown, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(2), "shutdown", nil)
+			require.NoError(t, err)
+			require.NoError(t, client.Write(shutdown))
+			msg, err = client.Read()
+			require.NoError(t, err)
+			resp, ok = msg.(*jsonrpc2.Response)
+			require.True(t, ok)
+			assert.Equal(t, jsonrpc2.NewIntID(2), resp.ID())
+			require.NoError(t, resp.Err())
+
+			exit, err := jsonrpc2.NewNotification("exit", nil)
+			require.NoError(t, err)
+			require.NoError(t, client.Write(exit))
+			require.NoError(t, <-served)
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, errs, 1)
+			assert.ErrorContains(t, errs[0], "failed to parse didOpen params")
+		})
+	}
+
+	t.Run("ExitWithoutShutdown", func(t *testing.T) {
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		served := make(chan error, 1)
+		go func() {
+			served <- Serve(jsonrpc2.NewHeaderStream(serverConn), newMapFSWithoutModTime(m), fileMapGetter(m), nil)
+		}()
+		exit, err := jsonrpc2.NewNotification("exit", nil)
+		require.NoError(t, err)
+		require.NoError(t, jsonrpc2.NewHeaderStream(clientConn).Write(exit))
+		assert.ErrorIs(t, <-served, ErrExitWithoutShutdown)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		serverConn, clientConn := net.Pipe()
+		served := make(chan error, 1)
+		go func() {
+			served <- Serve(jsonrpc2.NewHeaderStream(serverConn), newMapFSWithoutModTime(m), fileMapGetter(m), nil)
+		}()
+		require.NoError(t, clientConn.Close())
+		assert.NoError(t, <-served)
+	})
+}
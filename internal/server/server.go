@@ -1,14 +1,21 @@
 package server
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"maps"
-	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 
 	"github.com/goplus/goxlsw/gop"
 	"github.com/goplus/goxlsw/internal/analysis"
+	"github.com/goplus/goxlsw/internal/analysis/driver"
+	"github.com/goplus/goxlsw/internal/pkgdata"
+	"github.com/goplus/goxlsw/internal/position"
 	"github.com/goplus/goxlsw/internal/vfs"
 	"github.com/goplus/goxlsw/jsonrpc2"
 )
@@ -20,6 +27,8 @@ type MessageReplier interface {
 	// The message can be one of:
 	//   - [jsonrpc2.Response]: sent in response to a call.
 	//   - [jsonrpc2.Notification]: sent for server-initiated notifications.
+	//   - [jsonrpc2.Call]: sent for server-initiated requests, whose responses
+	//     are expected to be passed back to [Server.HandleMessage].
 	ReplyMessage(m jsonrpc2.Message) error
 }
 
@@ -29,48 +38,168 @@ type FileMapGetter func() map[string]vfs.MapFile
 // Server is the core language server implementation that handles LSP messages.
 type Server struct {
 	workspaceRootURI DocumentURI
-	workspaceRootFS  *vfs.MapFS
-	replier          MessageReplier
-	analyzers        []*analysis.Analyzer
+	replier          MessageReplier // traces messages, see [tracingReplier]
+	clientReplier    MessageReplier // replier given to New
+	logger           *slog.Logger
+	logLevel         slog.LevelVar // see [Settings.LogLevel]
+	tracer           tracer
+	telemetry        Telemetry
+	analyzerCrashes  analyzerCrashes
+	lifecycle        atomic.Int32 // see [lifecycleState]
+	analysisDriver   *driver.Driver
 	fileMapGetter    FileMapGetter // TODO(wyvern): Remove this field.
+
+	semanticTokensResults sync.Map // map[DocumentURI]*semanticTokensResult, of open documents
+
+	lastCompletion  atomic.Pointer[completionResolveState]
+	lastDiagnostics atomic.Pointer[snapshotDiagnostics] // see [Server.pullDiagnostics]
+
+	scheduler           *requestScheduler
+	diagnosticScheduler *diagnosticScheduler
+	indexScheduler      *diagnosticScheduler
+	indexProgress       atomic.Pointer[indexProgress] // progress of the latest indexing
+
+	callsMu   sync.Mutex
+	calls     map[jsonrpc2.ID]context.CancelFunc // cancel functions of in-flight calls
+	callsDone chan struct{}                      // closed once no calls are in flight, nil if none are
+
+	clientCallsMu    sync.Mutex
+	lastClientCallID int64
+	clientCalls      map[jsonrpc2.ID]chan *jsonrpc2.Response // pending calls to the client
+
+	lastWorkDoneProgressID atomic.Int64
+
+	openDocuments vfs.Overlay // contents of open documents
+
+	publishedDiagnosticsMu sync.Mutex
+	publishedDiagnostics   map[DocumentURI]struct{} // documents with non-empty published diagnostics
+
+	spxResources spxResourceSetTracker
+
+	availableAnalyzers map[string]*analysis.Analyzer
+
+	settingsMu       sync.RWMutex      // guards the fields below
+	settingsVersion  uint64            // see [Server.getSettingsVersion]
+	analyzers        []analyzerConfig  // enabled analyzers
+	loopYieldCall    string            // see [Settings.LoopYieldCall]
+	onSave           onSaveActions     // see [Settings.OnSave]
+	positionEncoding position.Encoding // negotiated in initialize
+	workDoneProgress bool              // whether the client supports server-initiated progress
+
+	clientCapabilities clientCapabilities // negotiated in initialize
+	fileLimits         vfs.Limits         // see [Server.SetFileLimits]
+	readOnly           vfs.ReadOnly       // see [Server.SetReadOnly]
+
+	spriteScriptTemplate *template.Template // see [Server.SetSpriteScriptTemplate]
+
+	fileLimitErrorMu sync.Mutex
+	fileLimitError   string // last error shown by [Server.showFileLimitError]
 }
 
+// getProj returns the latest snapshot of the workspace as is. See
+// [Server.snapshot] for one that is up to date.
 func (s *Server) getProj() *gop.Project {
-	return s.workspaceRootFS
+	s.scheduler.mu.Lock()
+	defer s.scheduler.mu.Unlock()
+	return s.scheduler.snapshot
 }
 
 // New creates a new Server instance.
 func New(mapFS *vfs.MapFS, replier MessageReplier, fileMapGetter FileMapGetter) *Server {
-	return &Server{
-		// TODO(spxls): Initialize request should set workspaceRootURI value
-		workspaceRootURI: "file:///",
-		workspaceRootFS:  mapFS,
-		replier:          replier,
-		analyzers:        initAnalyzers(true),
-		fileMapGetter:    fileMapGetter,
+	mapFS.InitCache(workspaceSymbolIndexCacheKind, buildWorkspaceSymbolIndex)
+	mapFS.InitFileCache(spxResourceMetadataCacheKind, buildSpxResourceMetadataCache)
+	mapFS.SetVendorDir(defaultVendorDir)
+	availableAnalyzers := initAnalyzers(true)
+	analyzers, _ := configureAnalyzers(availableAnalyzers, nil) // Never fails without settings.
+	s := &Server{
+		workspaceRootURI:   defaultWorkspaceRootURI,
+		clientReplier:      replier,
+		scheduler:          newRequestScheduler(mapFS),
+		analysisDriver:     driver.New(),
+		fileMapGetter:      fileMapGetter,
+		availableAnalyzers: availableAnalyzers,
+		analyzers:          analyzers,
+		loopYieldCall:      defaultLoopYieldCall,
+		onSave:             defaultOnSaveActions,
+		positionEncoding:   position.UTF16,
+		clientCapabilities: fullClientCapabilities,
 	}
+	s.replier = tracingReplier{s}
+	s.logger = slog.New(&clientLogHandler{s: s})
+	s.logLevel.Set(logLevelOff)
+	s.telemetry = noopTelemetry{}
+	s.spxResources.setLatest(mapFS)
+	s.diagnosticScheduler = newDiagnosticScheduler(diagnosticDelay, func(ctx context.Context) {
+		defer s.recoverPanic("publishing diagnostics", nil)
+
+		// The next run retries anyway, so failures are only logged.
+		if err := s.publishAllDiagnostics(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("failed to publish diagnostics", "error", err)
+		}
+	})
+	s.indexScheduler = newDiagnosticScheduler(indexDelay, func(ctx context.Context) {
+		defer s.recoverPanic("indexing", nil)
+		s.indexWorkspace(ctx)
+	})
+
+	// Load the bundled documentation ahead of the first hover or completion.
+	// Failures surface again on lookup.
+	go pkgdata.LoadPkgDocs()
+	return s
 }
 
-// InitAnalyzers initializes the analyzers for the server.
-func initAnalyzers(staticcheck bool) []*analysis.Analyzer {
-	analyzers := slices.Collect(maps.Values(analysis.DefaultAnalyzers))
+// initAnalyzers returns the analyzers available to the server, keyed by name.
+func initAnalyzers(staticcheck bool) map[string]*analysis.Analyzer {
+	analyzers := maps.Clone(analysis.DefaultAnalyzers)
 	if staticcheck {
-		analyzers = slices.AppendSeq(analyzers, maps.Values(analysis.StaticcheckAnalyzers))
+		maps.Copy(analyzers, analysis.StaticcheckAnalyzers)
 	}
 	return analyzers
 }
 
 // HandleMessage handles an incoming LSP message.
+//
+// Messages that mutate the workspace state are handled synchronously, one at
+// a time, while calls that only read it are handled concurrently in the
+// background. See [requestKindOf].
 func (s *Server) HandleMessage(m jsonrpc2.Message) error {
+	s.traceReceived(m)
 	switch m := m.(type) {
 	case *jsonrpc2.Call:
-		return s.handleCall(m)
+		if err := s.lifecycleCallError(m.Method()); err != nil {
+			return s.replyError(m.ID(), err)
+		}
+		if requestKindOf(m.Method()) == requestKindMutation {
+			return s.scheduler.mutate(func() error { return s.handleCallSafely(m) })
+		}
+		return s.handleCallSafely(m)
 	case *jsonrpc2.Notification:
-		return s.handleNotification(m)
+		if s.dropsNotification(m.Method()) {
+			return nil
+		}
+		if requestKindOf(m.Method()) == requestKindMutation {
+			return s.scheduler.mutate(func() error { return s.handleNotificationSafely(m) })
+		}
+		return s.handleNotificationSafely(m)
+	case *jsonrpc2.Response:
+		s.handleResponse(m)
+		return nil
 	}
 	return fmt.Errorf("unsupported message type: %T", m)
 }
 
+// handleCallSafely is like [Server.handleCall], but recovers from a panic,
+// replying to the call with the error.
+func (s *Server) handleCallSafely(c *jsonrpc2.Call) (err error) {
+	defer func() {
+		if _, ok := err.(*panicError); ok {
+			s.replyError(c.ID(), err)
+		}
+	}()
+	defer s.recoverPanic(c.Method(), &err)
+	return s.handleCall(c)
+}
+
 // handleCall handles a call message.
 func (s *Server) handleCall(c *jsonrpc2.Call) error {
 	switch c.Method() {
@@ -79,146 +208,329 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		return errors.New("TODO")
+		result, err := s.initialize(&params)
+		return s.reply(c.ID(), result, err)
 	case "shutdown":
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return nil, nil // Protocol conformance only.
+		s.lifecycle.Store(int32(lifecycleShutdown))
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			s.shutdown()
+			return nil, nil
 		})
 	case "textDocument/hover":
 		var params HoverParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentHover(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentHover(ctx, &params)
 		})
 	case "textDocument/completion":
 		var params CompletionParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentCompletion(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentCompletion(ctx, &params)
+		})
+	case "completionItem/resolve":
+		var params CompletionItem
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.completionItemResolve(ctx, &params)
 		})
 	case "textDocument/signatureHelp":
 		var params SignatureHelpParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentSignatureHelp(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentSignatureHelp(ctx, &params)
 		})
 	case "textDocument/declaration":
 		var params DeclarationParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDeclaration(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDeclaration(ctx, &params)
 		})
 	case "textDocument/definition":
 		var params DefinitionParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDefinition(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDefinition(ctx, &params)
 		})
 	case "textDocument/typeDefinition":
 		var params TypeDefinitionParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentTypeDefinition(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentTypeDefinition(ctx, &params)
 		})
 	case "textDocument/implementation":
 		var params ImplementationParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentImplementation(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentImplementation(ctx, &params)
 		})
 	case "textDocument/references":
 		var params ReferenceParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentReferences(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentReferences(ctx, &params)
+		})
+	case "textDocument/prepareCallHierarchy":
+		var params CallHierarchyPrepareParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentPrepareCallHierarchy(ctx, &params)
+		})
+	case "callHierarchy/incomingCalls":
+		var params CallHierarchyIncomingCallsParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.callHierarchyIncomingCalls(ctx, &params)
+		})
+	case "callHierarchy/outgoingCalls":
+		var params CallHierarchyOutgoingCallsParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.callHierarchyOutgoingCalls(ctx, &params)
+		})
+	case "textDocument/linkedEditingRange":
+		var params LinkedEditingRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentLinkedEditingRange(ctx, &params)
+		})
+	case "textDocument/selectionRange":
+		var params SelectionRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentSelectionRange(ctx, &params)
+		})
+	case "textDocument/foldingRange":
+		var params FoldingRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentFoldingRange(ctx, &params)
+		})
+	case "textDocument/documentColor":
+		var params DocumentColorParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDocumentColor(ctx, &params)
+		})
+	case "textDocument/colorPresentation":
+		var params ColorPresentationParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentColorPresentation(ctx, &params)
+		})
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentCodeAction(ctx, &params)
+		})
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentCodeLens(ctx, &params)
 		})
 	case "textDocument/documentHighlight":
 		var params DocumentHighlightParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDocumentHighlight(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDocumentHighlight(ctx, &params)
+		})
+	case "textDocument/inlayHint":
+		var params InlayHintParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentInlayHint(ctx, &params)
 		})
 	case "textDocument/documentLink":
 		var params DocumentLinkParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDocumentLink(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDocumentLink(ctx, &params)
 		})
 	case "textDocument/diagnostic":
 		var params DocumentDiagnosticParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDiagnostic(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentDiagnostic(ctx, &params)
 		})
 	case "workspace/diagnostic":
 		var params WorkspaceDiagnosticParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.workspaceDiagnostic(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.workspaceDiagnostic(ctx, &params)
 		})
 	case "textDocument/formatting":
 		var params DocumentFormattingParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentFormatting(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentFormatting(ctx, &params)
+		})
+	case "textDocument/willSaveWaitUntil":
+		var params WillSaveTextDocumentParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentWillSaveWaitUntil(ctx, &params)
+		})
+	case "textDocument/rangeFormatting":
+		var params DocumentRangeFormattingParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentRangeFormatting(ctx, &params)
+		})
+	case "textDocument/onTypeFormatting":
+		var params DocumentOnTypeFormattingParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentOnTypeFormatting(ctx, &params)
 		})
 	case "textDocument/prepareRename":
 		var params PrepareRenameParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentPrepareRename(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentPrepareRename(ctx, &params)
 		})
 	case "textDocument/rename":
 		var params RenameParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentRename(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentRename(ctx, &params)
 		})
 	case "textDocument/semanticTokens/full":
 		var params SemanticTokensParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentSemanticTokensFull(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentSemanticTokensFull(ctx, &params)
+		})
+	case "textDocument/semanticTokens/full/delta":
+		var params SemanticTokensDeltaParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentSemanticTokensFullDelta(ctx, &params)
+		})
+	case "textDocument/semanticTokens/range":
+		var params SemanticTokensRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.textDocumentSemanticTokensRange(ctx, &params)
+		})
+	case "workspace/symbol":
+		var params WorkspaceSymbolParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.workspaceSymbol(ctx, &params)
 		})
 	case "workspace/executeCommand":
 		var params ExecuteCommandParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.workspaceExecuteCommand(&params)
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.workspaceExecuteCommand(ctx, &params)
+		})
+	case "spx/newSpriteScript":
+		var params SpxNewSpriteScriptParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.spxNewSpriteScript(ctx, &params)
+		})
+	case "xgo/memoryUsage":
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.xgoMemoryUsage(ctx)
+		})
+	case "xgo/workspaceStatus":
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.xgoWorkspaceStatus(ctx)
+		})
+	case "xgo/translateToGo":
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.xgoTranslateToGo(ctx)
+		})
+	case "xgo/mapStackTrace":
+		var params XGoMapStackTraceParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.xgoMapStackTrace(ctx, &params)
+		})
+	case "xgo/evaluate":
+		var params XGoEvaluateParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c.ID(), func(ctx context.Context) (any, error) {
+			return s.xgoEvaluate(ctx, &params)
 		})
 	default:
 		return s.replyMethodNotFound(c.ID(), c.Method())
@@ -226,6 +538,13 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 	return nil
 }
 
+// handleNotificationSafely is like [Server.handleNotification], but recovers
+// from a panic, returning it as an error.
+func (s *Server) handleNotificationSafely(n *jsonrpc2.Notification) (err error) {
+	defer s.recoverPanic(n.Method(), &err)
+	return s.handleNotification(n)
+}
+
 // handleNotification handles a notification message.
 func (s *Server) handleNotification(n *jsonrpc2.Notification) error {
 	switch n.Method() {
@@ -234,33 +553,71 @@ func (s *Server) handleNotification(n *jsonrpc2.Notification) error {
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse initialized params: %w", err)
 		}
-		return errors.New("TODO")
+		// Load the workspace and publish the initial diagnostics.
+		s.scheduleWorkspaceChecks()
 	case "exit":
-		return nil // Protocol conformance only.
+		return nil // Handled by the host, see [Serve].
+	case "$/setTrace":
+		var params SetTraceParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse setTrace params: %w", err)
+		}
+		s.setTrace(params.Value)
+	case "$/cancelRequest":
+		var params cancelParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse cancelRequest params: %w", err)
+		}
+		s.cancelCall(params.ID)
+	case "workspace/didChangeConfiguration":
+		var params DidChangeConfigurationParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeConfiguration params: %w", err)
+		}
+		return s.workspaceDidChangeConfiguration(&params)
+	case "workspace/didChangeWatchedFiles":
+		var params DidChangeWatchedFilesParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeWatchedFiles params: %w", err)
+		}
+		return s.workspaceDidChangeWatchedFiles(&params)
 	case "textDocument/didOpen":
 		var params DidOpenTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didOpen params: %w", err)
 		}
-		return errors.New("TODO")
+		if err := s.textDocumentDidOpen(&params); err != nil {
+			return err
+		}
+		s.scheduleWorkspaceChecks()
 	case "textDocument/didChange":
 		var params DidChangeTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didChange params: %w", err)
 		}
-		return errors.New("TODO")
+		_, end := s.startSpan(context.Background(), "textDocument/didChange",
+			slog.Int("changes", len(params.ContentChanges)))
+		err := s.textDocumentDidChange(&params)
+		end(err)
+		if err != nil {
+			return err
+		}
+		s.scheduleWorkspaceChecks()
 	case "textDocument/didSave":
 		var params DidSaveTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didSave params: %w", err)
 		}
-		return errors.New("TODO")
+		s.scheduleWorkspaceChecks()
 	case "textDocument/didClose":
 		var params DidCloseTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didClose params: %w", err)
 		}
-		return errors.New("TODO")
+		if err := s.textDocumentDidClose(&params); err != nil {
+			return err
+		}
+		s.scheduleWorkspaceChecks()
 	}
 	return nil
 }
@@ -279,19 +636,40 @@ func (s *Server) publishDiagnostics(uri DocumentURI, diagnostics []Diagnostic) e
 }
 
 // run runs the given function in a goroutine and replies to the client with any
-// errors.
+// errors, including a panic of the function.
 func (s *Server) run(id jsonrpc2.ID, fn func() error) {
 	go func() {
-		if err := fn(); err != nil {
+		err := func() (err error) {
+			defer s.recoverPanic(s.tracer.receivedMethod(id), &err)
+			return fn()
+		}()
+		if err != nil {
 			s.replyError(id, err)
 		}
 	}()
 }
 
-// runWithResponse runs the given function in a goroutine and handles the response.
-func (s *Server) runWithResponse(id jsonrpc2.ID, fn func() (any, error)) {
+// runWithResponse runs the given function in a goroutine and handles the
+// response. The context passed to the function is canceled once the client
+// cancels the call, which then fails with [jsonrpc2.ErrRequestCancelled].
+func (s *Server) runWithResponse(id jsonrpc2.ID, fn func(ctx context.Context) (any, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.callsMu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[jsonrpc2.ID]context.CancelFunc)
+	}
+	s.calls[id] = cancel
+	if s.callsDone == nil {
+		s.callsDone = make(chan struct{})
+	}
+	s.callsMu.Unlock()
+
 	s.run(id, func() error {
-		result, err := fn()
+		defer s.cancelCall(id)
+		result, err := fn(ctx)
+		if ctx.Err() != nil {
+			result, err = nil, jsonrpc2.ErrRequestCancelled
+		}
 		resp, err := jsonrpc2.NewResponse(id, result, err)
 		if err != nil {
 			return err
@@ -300,15 +678,99 @@ func (s *Server) runWithResponse(id jsonrpc2.ID, fn func() (any, error)) {
 	})
 }
 
-// replyError replies to the client with an error response.
-func (s *Server) replyError(id jsonrpc2.ID, err error) error {
-	resp, err := jsonrpc2.NewResponse(id, nil, err)
+// callClient sends a call to the client and waits for its response. It gives
+// up as soon as ctx is done, returning the error of ctx.
+func (s *Server) callClient(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	s.clientCallsMu.Lock()
+	s.lastClientCallID++
+	id := jsonrpc2.NewIntID(s.lastClientCallID)
+	respCh := make(chan *jsonrpc2.Response, 1)
+	if s.clientCalls == nil {
+		s.clientCalls = make(map[jsonrpc2.ID]chan *jsonrpc2.Response)
+	}
+	s.clientCalls[id] = respCh
+	s.clientCallsMu.Unlock()
+	defer func() {
+		s.clientCallsMu.Lock()
+		defer s.clientCallsMu.Unlock()
+		delete(s.clientCalls, id)
+	}()
+
+	call, err := jsonrpc2.NewCall(id, method, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s call: %w", method, err)
+	}
+	if err := s.replier.ReplyMessage(call); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respCh:
+		return resp.Result(), resp.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleResponse handles a response to a call sent by [Server.callClient].
+// Responses to calls that were given up are dropped.
+func (s *Server) handleResponse(r *jsonrpc2.Response) {
+	s.clientCallsMu.Lock()
+	defer s.clientCallsMu.Unlock()
+	if respCh, ok := s.clientCalls[r.ID()]; ok {
+		respCh <- r
+		delete(s.clientCalls, r.ID())
+	}
+}
+
+// cancelParams is [CancelParams] with the ID decoded as a [jsonrpc2.ID].
+type cancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
+// cancelCall cancels the in-flight call with the given ID. It does nothing if
+// there is no such call, e.g. because it has already completed.
+func (s *Server) cancelCall(id jsonrpc2.ID) {
+	s.callsMu.Lock()
+	defer s.callsMu.Unlock()
+	if cancel, ok := s.calls[id]; ok {
+		cancel()
+		delete(s.calls, id)
+	}
+	if len(s.calls) == 0 && s.callsDone != nil {
+		close(s.callsDone)
+		s.callsDone = nil
+	}
+}
+
+// waitForNoCalls blocks until no call from the client is in flight, or ctx is
+// done, in which case it returns the error of ctx.
+func (s *Server) waitForNoCalls(ctx context.Context) error {
+	s.callsMu.Lock()
+	done := s.callsDone
+	s.callsMu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+// reply replies to the client with a response of the given result or error.
+func (s *Server) reply(id jsonrpc2.ID, result any, err error) error {
+	resp, err := jsonrpc2.NewResponse(id, result, err)
 	if err != nil {
 		return err
 	}
 	return s.replier.ReplyMessage(resp)
 }
 
+// replyError replies to the client with an error response.
+func (s *Server) replyError(id jsonrpc2.ID, err error) error {
+	return s.reply(id, nil, err)
+}
+
 // replyMethodNotFound replies to the client with a method not found error response.
 func (s *Server) replyMethodNotFound(id jsonrpc2.ID, method string) error {
 	return s.replyError(id, fmt.Errorf("%w: %s", jsonrpc2.ErrMethodNotFound, method))
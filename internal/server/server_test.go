@@ -1,8 +1,16 @@
 package server
 
 import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/goplus/goxlsw/gop"
 	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newMapFSWithoutModTime(files map[string][]byte) *vfs.MapFS {
@@ -24,3 +32,99 @@ func fileMapGetter(files map[string][]byte) func() map[string]vfs.MapFile {
 		return fileMap
 	}
 }
+
+// recordingReplier is a [MessageReplier] that records all messages.
+type recordingReplier struct {
+	mu       sync.Mutex
+	messages []jsonrpc2.Message
+}
+
+func (r *recordingReplier) ReplyMessage(m jsonrpc2.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+// initializeServer initializes s like a client without capabilities, so
+// that it serves messages.
+func initializeServer(t *testing.T, s *Server) {
+	t.Helper()
+	_, err := s.initialize(&InitializeParams{})
+	require.NoError(t, err)
+}
+
+func TestServerCancelRequest(t *testing.T) {
+	response := func(t *testing.T, replier *recordingReplier) *jsonrpc2.Response {
+		var resp *jsonrpc2.Response
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			if len(replier.messages) == 0 {
+				return false
+			}
+			resp, _ = replier.messages[0].(*jsonrpc2.Response)
+			return true
+		}, time.Second, time.Millisecond)
+		require.NotNil(t, resp)
+		return resp
+	}
+
+	t.Run("InFlight", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(nil), replier, fileMapGetter(nil))
+		initializeServer(t, s)
+
+		started := make(chan struct{})
+		s.runWithResponse(jsonrpc2.NewIntID(1), func(ctx context.Context) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return "partial", nil
+		})
+		<-started
+		n, err := jsonrpc2.NewNotification("$/cancelRequest", map[string]any{"id": 1})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+
+		resp := response(t, replier)
+		assert.Equal(t, jsonrpc2.NewIntID(1), resp.ID())
+		assert.ErrorIs(t, resp.Err(), jsonrpc2.ErrRequestCancelled)
+		s.callsMu.Lock()
+		defer s.callsMu.Unlock()
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(nil), replier, fileMapGetter(nil))
+		initializeServer(t, s)
+
+		s.runWithResponse(jsonrpc2.NewStringID("a"), func(ctx context.Context) (any, error) {
+			return "done", nil
+		})
+		resp := response(t, replier)
+		require.NoError(t, resp.Err())
+		assert.JSONEq(t, `"done"`, string(resp.Result()))
+
+		// Cancelling a completed or unknown call does nothing.
+		n, err := jsonrpc2.NewNotification("$/cancelRequest", map[string]any{"id": "a"})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+	})
+
+	t.Run("Compile", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`echo "Hello, spx!"`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := s.compile(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.NotNil(t, result)
+	})
+}
@@ -0,0 +1,426 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/analysis"
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// Settings represents the user settings of the server. The client sends them
+// through the initializationOptions of the initialize request, and through
+// the settings of the workspace/didChangeConfiguration notification.
+type Settings struct {
+	// Per-analyzer settings, keyed by analyzer name.
+	Analyzers map[string]AnalyzerSettings `json:"analyzers,omitempty"`
+
+	// The statement inserted by the quick fix for infinite loops in event
+	// handlers to yield to the spx runtime. If omitted, "waitNextFrame" is
+	// used.
+	LoopYieldCall string `json:"loopYieldCall,omitempty"`
+
+	// The minimum level of the server logs sent to the client with
+	// window/logMessage. One of "error", "warn", "info", "debug" and "off".
+	// If omitted, nothing is logged.
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// The budget in bytes for the estimated size of the caches derived from
+	// files, e.g., ASTs. Once exceeded, the least recently used caches are
+	// evicted. If omitted or 0, the caches are unlimited.
+	MemoryBudget int64 `json:"memoryBudget,omitempty"`
+
+	// The directory of vendored packages in the workspace, from which packages
+	// beyond the builtin ones are imported, e.g., vendor/github.com/foo/bar
+	// for "github.com/foo/bar". If omitted, "vendor" is used.
+	VendorDir string `json:"vendorDir,omitempty"`
+
+	// The actions applied to spx source files before they are saved, with
+	// textDocument/willSaveWaitUntil.
+	OnSave *OnSaveSettings `json:"onSave,omitempty"`
+}
+
+// OnSaveSettings represents the settings of the actions applied to spx source
+// files before they are saved. The actions run in the order of the fields.
+type OnSaveSettings struct {
+	// Whether to apply the fixes of the source.fixAll code action, i.e.,
+	// removing unused imports and local variables. If omitted, they are not
+	// applied.
+	FixAll *bool `json:"fixAll,omitempty"`
+
+	// Whether to organize imports. If omitted, imports are organized.
+	OrganizeImports *bool `json:"organizeImports,omitempty"`
+
+	// Whether to format the file. If omitted, the file is formatted.
+	Format *bool `json:"format,omitempty"`
+}
+
+// defaultLoopYieldCall is the default of [Settings.LoopYieldCall].
+const defaultLoopYieldCall = "waitNextFrame"
+
+// onSaveActions are the actions applied to spx source files before they are
+// saved, see [OnSaveSettings].
+type onSaveActions struct {
+	fixAll          bool
+	organizeImports bool
+	format          bool
+}
+
+// defaultOnSaveActions are the on-save actions if [Settings.OnSave] is
+// omitted.
+var defaultOnSaveActions = onSaveActions{organizeImports: true, format: true}
+
+// AnalyzerSettings represents the settings of an analyzer.
+type AnalyzerSettings struct {
+	// Whether the analyzer is enabled. If omitted, the analyzer is enabled
+	// only if it is enabled by default.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// The severity of diagnostics reported by the analyzer. One of "error",
+	// "warning", "info" and "hint". If omitted, the default severity of the
+	// analyzer is used.
+	Severity string `json:"severity,omitempty"`
+}
+
+// analyzerConfig is an analyzer configured by [Settings].
+type analyzerConfig struct {
+	// analyzer is the configured analyzer.
+	analyzer *analysis.Analyzer
+
+	// severity is the severity of diagnostics reported by the analyzer.
+	severity DiagnosticSeverity
+}
+
+// parseDiagnosticSeverity parses a severity name of [AnalyzerSettings].
+func parseDiagnosticSeverity(name string) (DiagnosticSeverity, error) {
+	switch name {
+	case "error":
+		return SeverityError, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "info":
+		return SeverityInformation, nil
+	case "hint":
+		return SeverityHint, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// decodeSettings decodes v, which is a settings object decoded from JSON,
+// into [Settings]. It returns nil if v is nil.
+func decodeSettings(v any) (*Settings, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var settings Settings
+	if err := UnmarshalJSON(b, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// configureAnalyzers returns the analyzers enabled by the given settings,
+// sorted by name. A nil settings enables the default analyzers. Settings of
+// unknown analyzers are ignored, see [unknownAnalyzerNames].
+func configureAnalyzers(analyzers map[string]*analysis.Analyzer, settings *Settings) ([]analyzerConfig, error) {
+	var analyzerSettings map[string]AnalyzerSettings
+	if settings != nil {
+		analyzerSettings = settings.Analyzers
+	}
+
+	configs := make([]analyzerConfig, 0, len(analyzers))
+	for name, a := range analyzers {
+		config := analyzerConfig{
+			analyzer: a,
+			severity: DiagnosticSeverity(a.Severity()),
+		}
+		enabled := a.EnabledByDefault()
+		if s, ok := analyzerSettings[name]; ok {
+			if s.Enabled != nil {
+				enabled = *s.Enabled
+			}
+			if s.Severity != "" {
+				severity, err := parseDiagnosticSeverity(s.Severity)
+				if err != nil {
+					return nil, fmt.Errorf("analyzer %q: %w", name, err)
+				}
+				config.severity = severity
+			}
+		}
+		if enabled {
+			configs = append(configs, config)
+		}
+	}
+	slices.SortFunc(configs, func(a, b analyzerConfig) int {
+		return strings.Compare(a.analyzer.String(), b.analyzer.String())
+	})
+	return configs, nil
+}
+
+// unknownAnalyzerNames returns the sorted names of the analyzers configured
+// by the given settings that are not among the given analyzers, e.g., of
+// analyzers of newer versions of the server.
+func unknownAnalyzerNames(analyzers map[string]*analysis.Analyzer, settings *Settings) []string {
+	if settings == nil {
+		return nil
+	}
+	var names []string
+	for name := range settings.Analyzers {
+		if _, ok := analyzers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// showUnknownAnalyzers shows the given names of unknown analyzers, whose
+// settings are ignored, to the user.
+func (s *Server) showUnknownAnalyzers(names []string) {
+	s.logger.Warn("settings of unknown analyzers ignored", "analyzers", names)
+	n, err := jsonrpc2.NewNotification("window/showMessage", &ShowMessageParams{
+		Type:    Warning,
+		Message: "Settings of unknown analyzers are ignored: " + strings.Join(names, ", "),
+	})
+	if err != nil {
+		return
+	}
+	_ = s.replier.ReplyMessage(n)
+}
+
+// applySettings applies the given settings to the server.
+func (s *Server) applySettings(settings *Settings) error {
+	analyzers, err := configureAnalyzers(s.availableAnalyzers, settings)
+	if err != nil {
+		return err
+	}
+
+	loopYieldCall := defaultLoopYieldCall
+	logLevel := logLevelOff
+	var memoryBudget int64
+	vendorDir := defaultVendorDir
+	onSave := defaultOnSaveActions
+	if settings != nil {
+		if settings.LoopYieldCall != "" {
+			loopYieldCall = settings.LoopYieldCall
+		}
+		if logLevel, err = parseLogLevel(settings.LogLevel); err != nil {
+			return err
+		}
+		if settings.MemoryBudget < 0 {
+			return fmt.Errorf("memoryBudget must not be negative, got %d", settings.MemoryBudget)
+		}
+		memoryBudget = settings.MemoryBudget
+		if settings.VendorDir != "" {
+			if !fs.ValidPath(settings.VendorDir) || settings.VendorDir == "." {
+				return fmt.Errorf("vendorDir must be a relative path in the workspace, got %q", settings.VendorDir)
+			}
+			vendorDir = settings.VendorDir
+		}
+		if settings.OnSave != nil {
+			if settings.OnSave.FixAll != nil {
+				onSave.fixAll = *settings.OnSave.FixAll
+			}
+			if settings.OnSave.OrganizeImports != nil {
+				onSave.organizeImports = *settings.OnSave.OrganizeImports
+			}
+			if settings.OnSave.Format != nil {
+				onSave.format = *settings.OnSave.Format
+			}
+		}
+	}
+
+	if names := unknownAnalyzerNames(s.availableAnalyzers, settings); len(names) > 0 {
+		s.showUnknownAnalyzers(names)
+	}
+
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.settingsVersion++
+	s.analyzers = analyzers
+	s.loopYieldCall = loopYieldCall
+	s.onSave = onSave
+	s.logLevel.Set(logLevel)
+
+	// Settings are applied by mutations, so the latest snapshot can be
+	// updated, whose budget is inherited by the snapshots taken from now on.
+	s.scheduler.snapshot.SetMemoryBudget(memoryBudget)
+
+	// Unlike the budget, the vendor directory changes the results of type
+	// checking, so it is set on a new snapshot instead, leaving the ones in
+	// use intact.
+	if s.scheduler.snapshot.VendorDir() != vendorDir {
+		snapshot := s.scheduler.snapshot.Snapshot()
+		snapshot.SetVendorDir(vendorDir)
+		s.scheduler.snapshot = snapshot
+		s.spxResources.setLatest(snapshot)
+	}
+	return nil
+}
+
+// getAnalyzers returns the currently enabled analyzers, except those disabled
+// for the session because they kept panicking.
+func (s *Server) getAnalyzers() []analyzerConfig {
+	s.settingsMu.RLock()
+	analyzers := s.analyzers
+	s.settingsMu.RUnlock()
+	return slices.DeleteFunc(slices.Clone(analyzers), func(config analyzerConfig) bool {
+		return s.isAnalyzerDisabled(config.analyzer.String())
+	})
+}
+
+// getLoopYieldCall returns the currently configured [Settings.LoopYieldCall].
+func (s *Server) getLoopYieldCall() string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.loopYieldCall
+}
+
+// getOnSaveActions returns the currently configured on-save actions.
+func (s *Server) getOnSaveActions() onSaveActions {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.onSave
+}
+
+// getSettingsVersion returns the version of the settings, which changes
+// whenever settings that affect the results of compilation may have changed,
+// e.g., the enabled analyzers.
+func (s *Server) getSettingsVersion() uint64 {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settingsVersion
+}
+
+// getPositionEncoding returns the position encoding negotiated with the
+// client, in which the character offsets of all protocol positions are
+// counted.
+func (s *Server) getPositionEncoding() position.Encoding {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.positionEncoding
+}
+
+// getWorkDoneProgressSupport reports whether the client supports
+// server-initiated work done progress.
+func (s *Server) getWorkDoneProgressSupport() bool {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.workDoneProgress
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#initialize
+func (s *Server) initialize(params *InitializeParams) (*InitializeResult, error) {
+	settings, err := decodeSettings(params.InitializationOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applySettings(settings); err != nil {
+		return nil, err
+	}
+	if params.Trace != nil {
+		s.setTrace(*params.Trace)
+	}
+
+	var clientPositionEncodings []PositionEncodingKind
+	if general := params.Capabilities.General; general != nil {
+		clientPositionEncodings = general.PositionEncodings
+	}
+	posEncoding := position.Negotiate(clientPositionEncodings)
+	s.settingsMu.Lock()
+	s.settingsVersion++
+	s.positionEncoding = posEncoding
+	s.workDoneProgress = params.Capabilities.Window.WorkDoneProgress
+	s.clientCapabilities = clientCapabilitiesOf(params)
+	s.settingsMu.Unlock()
+	s.lifecycle.Store(int32(lifecycleInitialized))
+	positionEncodingKind := PositionEncodingKind(posEncoding)
+
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding: &positionEncodingKind,
+			TextDocumentSync: TextDocumentSyncOptions{
+				OpenClose:         true,
+				Change:            Incremental,
+				WillSaveWaitUntil: true,
+			},
+			CompletionProvider: &CompletionOptions{
+				TriggerCharacters: []string{"."},
+				ResolveProvider:   true,
+			},
+			HoverProvider: &Or_ServerCapabilities_hoverProvider{Value: true},
+			SignatureHelpProvider: &SignatureHelpOptions{
+				TriggerCharacters: []string{"(", ","},
+			},
+			DeclarationProvider:       &Or_ServerCapabilities_declarationProvider{Value: true},
+			DefinitionProvider:        &Or_ServerCapabilities_definitionProvider{Value: true},
+			TypeDefinitionProvider:    &Or_ServerCapabilities_typeDefinitionProvider{Value: true},
+			ImplementationProvider:    &Or_ServerCapabilities_implementationProvider{Value: true},
+			ReferencesProvider:        &Or_ServerCapabilities_referencesProvider{Value: true},
+			DocumentHighlightProvider: &Or_ServerCapabilities_documentHighlightProvider{Value: true},
+			CodeActionProvider: CodeActionOptions{
+				CodeActionKinds: []CodeActionKind{
+					QuickFix,
+					RefactorExtract,
+					RefactorInline,
+					RefactorRewrite,
+					SourceOrganizeImports,
+					SourceFixAll,
+				},
+			},
+			CodeLensProvider:                &CodeLensOptions{},
+			DocumentLinkProvider:            &DocumentLinkOptions{},
+			ColorProvider:                   &Or_ServerCapabilities_colorProvider{Value: true},
+			WorkspaceSymbolProvider:         &Or_ServerCapabilities_workspaceSymbolProvider{Value: true},
+			DocumentFormattingProvider:      &Or_ServerCapabilities_documentFormattingProvider{Value: true},
+			DocumentRangeFormattingProvider: &Or_ServerCapabilities_documentRangeFormattingProvider{Value: true},
+			DocumentOnTypeFormattingProvider: &DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: "\n",
+				MoreTriggerCharacter:  []string{"}"},
+			},
+			RenameProvider:             RenameOptions{PrepareProvider: true},
+			FoldingRangeProvider:       &Or_ServerCapabilities_foldingRangeProvider{Value: true},
+			SelectionRangeProvider:     &Or_ServerCapabilities_selectionRangeProvider{Value: true},
+			CallHierarchyProvider:      &Or_ServerCapabilities_callHierarchyProvider{Value: true},
+			LinkedEditingRangeProvider: &Or_ServerCapabilities_linkedEditingRangeProvider{Value: true},
+			SemanticTokensProvider: SemanticTokensOptions{
+				Legend: semanticTokensLegend(),
+				Full:   &Or_SemanticTokensOptions_full{Value: SemanticTokensFullDelta{Delta: true}},
+				Range:  &Or_SemanticTokensOptions_range{Value: true},
+			},
+			InlayHintProvider: InlayHintOptions{},
+			DiagnosticProvider: &Or_ServerCapabilities_diagnosticProvider{Value: DiagnosticOptions{
+				InterFileDependencies: true,
+				WorkspaceDiagnostics:  true,
+			}},
+			ExecuteCommandProvider: &ExecuteCommandOptions{
+				Commands: spxCommandNames(),
+			},
+			Experimental: map[string]any{
+				"spxCommands": spxCommandDescriptions(),
+			},
+		},
+		ServerInfo: &ServerInfo{Name: "goxlsw"},
+	}, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_didChangeConfiguration
+func (s *Server) workspaceDidChangeConfiguration(params *DidChangeConfigurationParams) error {
+	settings, err := decodeSettings(params.Settings)
+	if err != nil {
+		return err
+	}
+	if err := s.applySettings(settings); err != nil {
+		return err
+	}
+	s.scheduleWorkspaceChecks()
+	return nil
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func analyzerSeverities(s *Server) map[string]DiagnosticSeverity {
+	severities := make(map[string]DiagnosticSeverity)
+	for _, config := range s.getAnalyzers() {
+		severities[config.analyzer.String()] = config.severity
+	}
+	return severities
+}
+
+func TestServerInitialize(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		result, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+		assert.Equal(t, TextDocumentSyncOptions{OpenClose: true, Change: Incremental, WillSaveWaitUntil: true}, result.Capabilities.TextDocumentSync)
+		require.NotNil(t, result.Capabilities.PositionEncoding)
+		assert.Equal(t, PositionEncodingKind("utf-16"), *result.Capabilities.PositionEncoding)
+		assert.Equal(t, position.UTF16, s.getPositionEncoding())
+
+		severities := analyzerSeverities(s)
+		assert.Equal(t, SeverityWarning, severities["printf"])
+		assert.Equal(t, SeverityHint, severities["unreachable"])
+		assert.NotContains(t, severities, "shadow")
+	})
+
+	t.Run("Capabilities", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		result, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		caps := result.Capabilities
+
+		require.NotNil(t, caps.CompletionProvider)
+		assert.True(t, caps.CompletionProvider.ResolveProvider)
+		assert.Equal(t, []string{"."}, caps.CompletionProvider.TriggerCharacters)
+		require.NotNil(t, caps.SignatureHelpProvider)
+		assert.Equal(t, []string{"(", ","}, caps.SignatureHelpProvider.TriggerCharacters)
+		require.NotNil(t, caps.DocumentOnTypeFormattingProvider)
+		assert.Equal(t, "\n", caps.DocumentOnTypeFormattingProvider.FirstTriggerCharacter)
+		assert.Equal(t, []string{"}"}, caps.DocumentOnTypeFormattingProvider.MoreTriggerCharacter)
+		assert.Equal(t, RenameOptions{PrepareProvider: true}, caps.RenameProvider)
+
+		semanticTokens, ok := caps.SemanticTokensProvider.(SemanticTokensOptions)
+		require.True(t, ok)
+		require.NotNil(t, semanticTokens.Full)
+		assert.Equal(t, SemanticTokensFullDelta{Delta: true}, semanticTokens.Full.Value)
+		require.NotNil(t, semanticTokens.Range)
+		assert.Equal(t, true, semanticTokens.Range.Value)
+		assert.Len(t, semanticTokens.Legend.TokenTypes, len(semanticTokenTypesLegend))
+		assert.Len(t, semanticTokens.Legend.TokenModifiers, len(semanticTokenModifiersLegend))
+		assert.Equal(t, "namespace", semanticTokens.Legend.TokenTypes[0])
+
+		require.NotNil(t, caps.DiagnosticProvider)
+		assert.Equal(t, DiagnosticOptions{InterFileDependencies: true, WorkspaceDiagnostics: true}, caps.DiagnosticProvider.Value)
+
+		codeActions, ok := caps.CodeActionProvider.(CodeActionOptions)
+		require.True(t, ok)
+		assert.Contains(t, codeActions.CodeActionKinds, QuickFix)
+		assert.Contains(t, codeActions.CodeActionKinds, RefactorExtract)
+
+		// Every other request the server handles is advertised.
+		for name, provider := range map[string]any{
+			"hover":                   caps.HoverProvider,
+			"declaration":             caps.DeclarationProvider,
+			"definition":              caps.DefinitionProvider,
+			"typeDefinition":          caps.TypeDefinitionProvider,
+			"implementation":          caps.ImplementationProvider,
+			"references":              caps.ReferencesProvider,
+			"documentHighlight":       caps.DocumentHighlightProvider,
+			"codeLens":                caps.CodeLensProvider,
+			"documentLink":            caps.DocumentLinkProvider,
+			"color":                   caps.ColorProvider,
+			"workspaceSymbol":         caps.WorkspaceSymbolProvider,
+			"documentFormatting":      caps.DocumentFormattingProvider,
+			"documentRangeFormatting": caps.DocumentRangeFormattingProvider,
+			"foldingRange":            caps.FoldingRangeProvider,
+			"selectionRange":          caps.SelectionRangeProvider,
+			"callHierarchy":           caps.CallHierarchyProvider,
+			"linkedEditingRange":      caps.LinkedEditingRangeProvider,
+			"inlayHint":               caps.InlayHintProvider,
+		} {
+			assert.NotNil(t, provider, name)
+		}
+
+		// The capabilities must survive the trip to the client.
+		_, err = json.Marshal(result)
+		require.NoError(t, err)
+	})
+
+	t.Run("InitializationOptions", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		var params InitializeParams
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"initializationOptions": {
+				"analyzers": {
+					"shadow": {"enabled": true},
+					"printf": {"severity": "error"},
+					"unreachable": {"enabled": false}
+				}
+			}
+		}`), &params))
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+
+		severities := analyzerSeverities(s)
+		assert.Equal(t, SeverityError, severities["printf"])
+		assert.Equal(t, SeverityWarning, severities["shadow"])
+		assert.NotContains(t, severities, "unreachable")
+	})
+
+	t.Run("UnknownAnalyzer", func(t *testing.T) {
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(nil), replier, fileMapGetter(nil))
+
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{
+			"analyzers": map[string]any{
+				"unknown": map[string]any{"enabled": true},
+				"printf":  map[string]any{"severity": "error"},
+			},
+		}
+		_, err := s.initialize(&params)
+		require.NoError(t, err)
+
+		// Known analyzers are still configured.
+		severities := analyzerSeverities(s)
+		assert.Equal(t, SeverityError, severities["printf"])
+		assert.NotContains(t, severities, "unknown")
+
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		require.Len(t, replier.messages, 1)
+		n, ok := replier.messages[0].(*jsonrpc2.Notification)
+		require.True(t, ok)
+		assert.Equal(t, "window/showMessage", n.Method())
+		var msg ShowMessageParams
+		require.NoError(t, UnmarshalJSON(n.Params(), &msg))
+		assert.Equal(t, Warning, msg.Type)
+		assert.Contains(t, msg.Message, "unknown")
+	})
+
+	t.Run("InvalidSeverity", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{
+			"analyzers": map[string]any{"printf": map[string]any{"severity": "fatal"}},
+		}
+		_, err := s.initialize(&params)
+		require.EqualError(t, err, `analyzer "printf": unknown severity "fatal"`)
+	})
+
+	t.Run("NegativeMemoryBudget", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{"memoryBudget": -1}
+		_, err := s.initialize(&params)
+		require.EqualError(t, err, `memoryBudget must not be negative, got -1`)
+	})
+
+	t.Run("UnknownLogLevel", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{"logLevel": "verbose"}
+		_, err := s.initialize(&params)
+		require.EqualError(t, err, `unknown log level "verbose"`)
+	})
+
+	t.Run("InvalidVendorDir", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+		var params InitializeParams
+		params.InitializationOptions = map[string]any{"vendorDir": "../vendor"}
+		_, err := s.initialize(&params)
+		require.EqualError(t, err, `vendorDir must be a relative path in the workspace, got "../vendor"`)
+	})
+
+	t.Run("PositionEncoding", func(t *testing.T) {
+		for _, tt := range []struct {
+			name            string
+			clientEncodings []PositionEncodingKind
+			want            position.Encoding
+		}{
+			{"UTF8", []PositionEncodingKind{"utf-16", "utf-8"}, position.UTF8},
+			{"UTF16", []PositionEncodingKind{"utf-16"}, position.UTF16},
+			{"UTF32Only", []PositionEncodingKind{"utf-32"}, position.UTF16},
+			{"None", nil, position.UTF16},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				s := New(newMapFSWithoutModTime(nil), nil, fileMapGetter(nil))
+
+				var params InitializeParams
+				params.Capabilities = ClientCapabilities{
+					General: &GeneralClientCapabilities{PositionEncodings: tt.clientEncodings},
+				}
+				result, err := s.initialize(&params)
+				require.NoError(t, err)
+				require.NotNil(t, result.Capabilities.PositionEncoding)
+				assert.Equal(t, PositionEncodingKind(tt.want), *result.Capabilities.PositionEncoding)
+				assert.Equal(t, tt.want, s.getPositionEncoding())
+			})
+		}
+	})
+}
+
+func TestServerWorkspaceDidChangeConfiguration(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+onStart => {
+	printf "%d\n", "hello"
+	return
+	echo "unreachable"
+}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	replier := &recordingReplier{}
+	s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+	initializeServer(t, s)
+
+	n, err := jsonrpc2.NewNotification("workspace/didChangeConfiguration", map[string]any{
+		"settings": map[string]any{
+			"analyzers": map[string]any{
+				"printf":      map[string]any{"severity": "error"},
+				"unreachable": map[string]any{"enabled": false},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.HandleMessage(n))
+
+	require.Eventually(t, func() bool {
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		return len(replier.messages) > 0
+	}, time.Second, time.Millisecond)
+	replier.mu.Lock()
+	defer replier.mu.Unlock()
+	require.Len(t, replier.messages, 1)
+	published, ok := replier.messages[0].(*jsonrpc2.Notification)
+	require.True(t, ok)
+	assert.Equal(t, "textDocument/publishDiagnostics", published.Method())
+
+	var params PublishDiagnosticsParams
+	require.NoError(t, json.Unmarshal(published.Params(), &params))
+	assert.Equal(t, DocumentURI("file:///main.spx"), params.URI)
+	assert.Equal(t, []Diagnostic{
+		{
+			Severity: SeverityError,
+			Source:   "printf",
+			Message:  `printf format %d has arg "hello" of wrong type untyped string`,
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 23},
+			},
+		},
+	}, params.Diagnostics)
+}
@@ -1,13 +1,19 @@
 package server
 
 import (
+	"context"
 	"go/types"
+	"slices"
 	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_signatureHelp
-func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*SignatureHelp, error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentSignatureHelp(ctx context.Context, params *SignatureHelpParams) (*SignatureHelp, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -15,21 +21,68 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		return nil, nil
 	}
 	position := result.toPosition(astFile, params.Position)
+	pos := result.posAt(astFile, params.Position)
 
-	obj := getTypeInfo(result.proj).ObjectOf(result.identAtASTFilePosition(astFile, position))
-	if obj == nil {
-		return nil, nil
+	typeInfo := getTypeInfo(result.proj)
+	callExpr := callExprAtPos(result.proj.Fset, astFile, pos)
+	var funIdent *gopast.Ident
+	if callExpr != nil {
+		funIdent = funcIdentOfCallExpr(callExpr)
+	} else {
+		funIdent = result.identAtASTFilePosition(astFile, position)
 	}
-
-	fun, ok := obj.(*types.Func)
+	fun, ok := typeInfo.ObjectOf(funIdent).(*types.Func)
 	if !ok {
 		return nil, nil
 	}
-	sig, ok := fun.Type().(*types.Signature)
-	if !ok {
+
+	overloads, activeOverload := goputil.OverloadsOf(fun)
+	if len(overloads) == 0 {
 		return nil, nil
 	}
 
+	var activeParam int
+	if callExpr != nil {
+		for _, arg := range callExpr.Args {
+			if arg.End() < pos {
+				activeParam++
+			}
+		}
+		if activeOverload < 0 {
+			// The call has not been resolved to a specific overload yet, pick
+			// the first one that can accept the arguments typed so far.
+			activeOverload = slices.IndexFunc(overloads, func(overload *types.Func) bool {
+				sig := overload.Type().(*types.Signature)
+				return sig.Variadic() || sig.Params().Len() > activeParam
+			})
+		}
+	}
+	activeOverload = max(activeOverload, 0)
+
+	signatures := make([]SignatureInformation, 0, len(overloads))
+	for _, overload := range overloads {
+		sig := overload.Type().(*types.Signature)
+		signature := signatureInformationFor(overload)
+		if n := sig.Params().Len(); n > 0 {
+			signature.ActiveParameter = uint32(activeParam)
+			if sig.Variadic() && activeParam >= n {
+				signature.ActiveParameter = uint32(n - 1)
+			}
+		}
+		signatures = append(signatures, signature)
+	}
+	return &SignatureHelp{
+		Signatures:      signatures,
+		ActiveSignature: uint32(activeOverload),
+		ActiveParameter: signatures[activeOverload].ActiveParameter,
+	}, nil
+}
+
+// signatureInformationFor returns the signature information for the given
+// function.
+func signatureInformationFor(fun *types.Func) SignatureInformation {
+	sig := fun.Type().(*types.Signature)
+
 	var paramsInfo []ParameterInformation
 	for i := range sig.Params().Len() {
 		param := sig.Params().At(i)
@@ -39,7 +92,11 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		})
 	}
 
-	label := fun.Name() + "("
+	name := fun.Name()
+	if isGopOverloadedFuncName(name) {
+		name, _ = parseGopFuncName(name)
+	}
+	label := name + "("
 	if sig.Params().Len() > 0 {
 		var paramLabels []string
 		for _, p := range paramsInfo {
@@ -57,11 +114,49 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		label += " (" + strings.Join(returnTypes, ", ") + ")"
 	}
 
-	return &SignatureHelp{
-		Signatures: []SignatureInformation{{
-			Label: label,
-			// TODO: Add documentation.
-			Parameters: paramsInfo,
-		}},
-	}, nil
+	return SignatureInformation{
+		Label: label,
+		// TODO: Add documentation.
+		Parameters: paramsInfo,
+	}
+}
+
+// callExprAtPos returns the innermost call expression whose arguments enclose
+// the given position. Command-style calls without parentheses also enclose
+// positions after their last argument on the same line.
+func callExprAtPos(fset *goptoken.FileSet, astFile *gopast.File, pos goptoken.Pos) (callExpr *gopast.CallExpr) {
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if node == nil || node.Pos() > pos {
+			return false
+		}
+		ce, ok := node.(*gopast.CallExpr)
+		if !ok {
+			return true
+		}
+		if pos <= ce.Fun.End() {
+			return true
+		}
+		if ce.IsCommand() {
+			if pos <= ce.End() || fset.Position(pos).Line == fset.Position(ce.End()).Line {
+				callExpr = ce
+			}
+		} else if pos <= ce.Rparen || !ce.Rparen.IsValid() {
+			callExpr = ce
+		}
+		return true
+	})
+	return
+}
+
+// funcIdentOfCallExpr returns the identifier of the function being called by
+// the given call expression. It returns nil if the callee is not a plain or
+// qualified identifier.
+func funcIdentOfCallExpr(callExpr *gopast.CallExpr) *gopast.Ident {
+	switch fun := callExpr.Fun.(type) {
+	case *gopast.Ident:
+		return fun
+	case *gopast.SelectorExpr:
+		return fun.Sel
+	}
+	return nil
 }
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,7 +31,7 @@ onStart => {
 		}
 		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
 
-		help, err := s.textDocumentSignatureHelp(&SignatureHelpParams{
+		help, err := s.textDocumentSignatureHelp(context.Background(), &SignatureHelpParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 11},
@@ -48,3 +50,65 @@ onStart => {
 		}, help.Signatures[0])
 	})
 }
+
+func TestTextDocumentSignatureHelpCommandStyle(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+play "MySound", true
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(``),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+		"assets/sounds/MySound/index.json":   []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	t.Run("ActiveParameter", func(t *testing.T) {
+		help, err := s.textDocumentSignatureHelp(context.Background(), &SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 17},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, help)
+		require.Greater(t, len(help.Signatures), 1)
+		activeSignature := help.Signatures[help.ActiveSignature]
+		assert.Equal(t, "play(media SoundName, wait bool)", activeSignature.Label)
+		assert.Equal(t, uint32(1), help.ActiveParameter)
+		assert.Equal(t, uint32(1), activeSignature.ActiveParameter)
+	})
+
+	t.Run("Overload", func(t *testing.T) {
+		help, err := s.textDocumentSignatureHelp(context.Background(), &SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 15},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, help)
+		require.Greater(t, len(help.Signatures), 1)
+		for _, signature := range help.Signatures {
+			assert.True(t, strings.HasPrefix(signature.Label, "turn("))
+		}
+		assert.Equal(t, "turn(dir specialDir)", help.Signatures[help.ActiveSignature].Label)
+		assert.Equal(t, uint32(0), help.ActiveParameter)
+	})
+
+	t.Run("OutsideCall", func(t *testing.T) {
+		help, err := s.textDocumentSignatureHelp(context.Background(), &SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, help)
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generatedGoFile is the name of the file the gop command writes the Go code
+// translated from Go+ to. Positions in it are mapped as lines of
+// [GoTranslation.Code], as the Go code has positions in Go+ source files
+// instead of its own ones if it is generated with line directives.
+const generatedGoFile = "gop_autogen.go"
+
+// XGoMapStackTraceParams represents parameters to map the positions in a
+// runtime stack trace back to Go+ source files.
+type XGoMapStackTraceParams struct {
+	// The stack trace, e.g., of a panic of the spx runtime. It may also be a
+	// single position like "gop_autogen.go:12".
+	StackTrace string `json:"stackTrace"`
+}
+
+// StackTraceLocation represents a position in a stack trace mapped to a Go+
+// source file.
+type StackTraceLocation struct {
+	// Range is the range of the position in the stack trace, e.g.,
+	// "main.spx:3:2", where lines are the ones of the stack trace.
+	Range Range `json:"range"`
+
+	// Location is the location in the Go+ source file.
+	Location Location `json:"location"`
+}
+
+// stackTracePositionRE matches a position of the form "file:line" or
+// "file:line:column" in a stack trace.
+var stackTracePositionRE = regexp.MustCompile(`((?:[A-Za-z]:)?[^\s:()"']+\.(?:go|spx|gop|gox)):(\d+)(?::(\d+))?`)
+
+// xgoMapStackTrace handles the xgo/mapStackTrace request, which maps the
+// positions in a runtime stack trace back to Go+ source files, e.g., for
+// clients to link crashes to code. Positions that cannot be mapped, e.g., the
+// ones in Go packages, are omitted.
+func (s *Server) xgoMapStackTrace(ctx context.Context, params *XGoMapStackTraceParams) ([]StackTraceLocation, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	posEncoding := s.getPositionEncoding()
+
+	var (
+		translation    *GoTranslation
+		translationErr error
+	)
+	getTranslation := func() (*GoTranslation, error) {
+		if translation == nil && translationErr == nil {
+			translation, translationErr = s.translateToGo(ctx, result)
+		}
+		return translation, translationErr
+	}
+
+	locations := []StackTraceLocation{}
+	for traceLine, line := range strings.Split(params.StackTrace, "\n") {
+		for _, m := range stackTracePositionRE.FindAllStringSubmatchIndex(line, -1) {
+			file := line[m[2]:m[3]]
+			lineNum, err := strconv.Atoi(line[m[4]:m[5]])
+			if err != nil || lineNum <= 0 {
+				continue
+			}
+			column := 0
+			if m[6] >= 0 {
+				column, _ = strconv.Atoi(line[m[6]:m[7]])
+			}
+
+			var (
+				location Location
+				ok       bool
+			)
+			if path.Base(file) == generatedGoFile {
+				translation, err := getTranslation()
+				if err != nil {
+					return nil, err
+				}
+				var spxLine uint32
+				location.URI, spxLine, ok = translation.sourceLine(uint32(lineNum - 1))
+				if ok {
+					location.Range = result.lineStartRange(location.URI, int(spxLine)+1)
+				}
+			} else if spxFile := result.spxFileOf(file); spxFile != "" {
+				location.URI = result.documentURIs[spxFile]
+				location.Range, ok = result.rangeForLineColumn(spxFile, lineNum, column)
+			}
+			if !ok {
+				continue
+			}
+			locations = append(locations, StackTraceLocation{
+				Range: Range{
+					Start: Position{Line: uint32(traceLine), Character: uint32(posEncoding.FromUTF8([]byte(line), m[0]))},
+					End:   Position{Line: uint32(traceLine), Character: uint32(posEncoding.FromUTF8([]byte(line), m[1]))},
+				},
+				Location: location,
+			})
+		}
+	}
+	return locations, nil
+}
+
+// sourceLine returns the URI of the Go+ source file and the zero-based line
+// in it that the given zero-based line of the Go code maps to.
+func (t *GoTranslation) sourceLine(goLine uint32) (uri DocumentURI, line uint32, ok bool) {
+	i := sort.Search(len(t.Mappings), func(i int) bool {
+		return t.Mappings[i].GoLine > goLine
+	})
+	if i == 0 {
+		return "", 0, false
+	}
+	m := t.Mappings[i-1]
+	if goLine >= m.GoLine+m.LineCount {
+		return "", 0, false
+	}
+	return m.URI, m.Line + goLine - m.GoLine, true
+}
+
+// spxFileOf returns the spx file the given file path in a stack trace refers
+// to, which may be absolute, or "" if there is none.
+func (r *compileResult) spxFileOf(file string) (spxFile string) {
+	file = strings.ReplaceAll(file, "\\", "/")
+	for f := range r.documentURIs {
+		if (file == f || strings.HasSuffix(file, "/"+f)) && len(f) > len(spxFile) {
+			spxFile = f
+		}
+	}
+	return
+}
+
+// rangeForLineColumn returns an empty [Range] at the given one-based line and
+// column of the given spx file. If column is 0, the range is at the first
+// non-blank character of the line. It reports false if the line is out of
+// the file.
+func (r *compileResult) rangeForLineColumn(spxFile string, line, column int) (Range, bool) {
+	if getASTPkg(r.proj).Files[spxFile] == nil {
+		return Range{}, false
+	}
+	idx, err := r.proj.LineIndex(spxFile)
+	if err != nil || line < 1 || line > idx.LineCount() {
+		return Range{}, false
+	}
+	lineContent := idx.Line(line - 1)
+	offset := len(lineContent) - len(bytes.TrimLeft(lineContent, " \t"))
+	if column > 0 {
+		offset = column - 1
+	}
+	p := idx.LinePosition(r.posEncoding, line-1, min(offset, len(lineContent)))
+	return Range{Start: p, End: p}, true
+}
+
+// lineStartRange returns an empty [Range] at the first non-blank character of
+// the given one-based line of the spx file with the given URI.
+func (r *compileResult) lineStartRange(uri DocumentURI, line int) Range {
+	for spxFile, u := range r.documentURIs {
+		if u == uri {
+			rng, _ := r.rangeForLineColumn(spxFile, line, 0)
+			return rng
+		}
+	}
+	return Range{}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMapStackTrace(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`var (
+	nums []int
+)
+
+onStart => {
+	echo nums[3]
+}
+`),
+		"MySprite.spx": []byte(`onClick => {
+	say "hi"
+}
+`),
+	}
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	}
+
+	t.Run("GeneratedGo", func(t *testing.T) {
+		s := newServer()
+		translation, err := s.xgoTranslateToGo(context.Background())
+		require.NoError(t, err)
+		var goLine int
+		for i, line := range strings.Split(translation.Code, "\n") {
+			if strings.Contains(line, "this.nums[3]") {
+				goLine = i + 1
+			}
+		}
+		require.NotZero(t, goLine)
+
+		stackTrace := fmt.Sprintf(`panic: runtime error: index out of range [3] with length 0
+
+goroutine 1 [running]:
+main.(*Game).MainEntry.func1()
+	/app/gop_autogen.go:%d +0x1d
+github.com/goplus/spx.(*Game).OnStart.func1()
+	/go/pkg/mod/github.com/goplus/spx/game.go:1024 +0x24
+main.(*Game).Main()
+	/app/gop_autogen.go:1 +0x1d
+`, goLine)
+		locations, err := s.xgoMapStackTrace(context.Background(), &XGoMapStackTraceParams{StackTrace: stackTrace})
+		require.NoError(t, err)
+		n := len(fmt.Sprint(goLine))
+		assert.Equal(t, []StackTraceLocation{
+			{
+				Range: Range{
+					Start: Position{Line: 4, Character: 1},
+					End:   Position{Line: 4, Character: uint32(1 + len("/app/gop_autogen.go:") + n)},
+				},
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 5, Character: 1},
+						End:   Position{Line: 5, Character: 1},
+					},
+				},
+			},
+		}, locations)
+	})
+
+	t.Run("GoPlusFile", func(t *testing.T) {
+		s := newServer()
+		locations, err := s.xgoMapStackTrace(context.Background(), &XGoMapStackTraceParams{
+			StackTrace: "error at /app/MySprite.spx:2:6 (main.spx:100)",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []StackTraceLocation{
+			{
+				Range: Range{
+					Start: Position{Line: 0, Character: 9},
+					End:   Position{Line: 0, Character: 30},
+				},
+				Location: Location{
+					URI: "file:///MySprite.spx",
+					Range: Range{
+						Start: Position{Line: 1, Character: 5},
+						End:   Position{Line: 1, Character: 5},
+					},
+				},
+			},
+		}, locations)
+	})
+
+	t.Run("NoPosition", func(t *testing.T) {
+		s := newServer()
+		locations, err := s.xgoMapStackTrace(context.Background(), &XGoMapStackTraceParams{StackTrace: "panic: oops"})
+		require.NoError(t, err)
+		assert.Empty(t, locations)
+	})
+}
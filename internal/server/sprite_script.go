@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// SpxNewSpriteScriptParams represents parameters to generate the initial spx
+// source file of a sprite resource.
+type SpxNewSpriteScriptParams struct {
+	// The sprite resource.
+	Sprite SpxResourceIdentifier `json:"sprite"`
+}
+
+// SpxNewSpriteScript represents the initial spx source file of a sprite
+// resource.
+type SpxNewSpriteScript struct {
+	// URI is the URI of the spx source file, which is named after the sprite.
+	URI DocumentURI `json:"uri"`
+
+	// Content is the content of the spx source file.
+	Content string `json:"content"`
+}
+
+// SpxSpriteScriptTemplateData is the data a sprite script template is
+// executed with, see [Server.SetSpriteScriptTemplate].
+type SpxSpriteScriptTemplateData struct {
+	// SpriteName is the name of the sprite, which is also the name of the
+	// class of its spx source file.
+	SpriteName string
+
+	// Costumes are the names of the costumes of the sprite, excluding the
+	// costumes of animations.
+	Costumes []string
+
+	// DefaultCostume is the name of the costume the sprite starts with, or ""
+	// if it has no costumes.
+	DefaultCostume string
+
+	// Animations are the names of the animations of the sprite.
+	Animations []string
+}
+
+// defaultSpriteScriptTemplate is the sprite script template used unless the
+// host of the server sets another one. It binds the sprite to its default
+// costume once the game starts.
+var defaultSpriteScriptTemplate = template.Must(template.New("sprite").Parse(`onStart => {
+{{- with .DefaultCostume}}
+	setCostume {{printf "%q" .}}
+{{- end}}
+}
+`))
+
+// SetSpriteScriptTemplate sets the template of the initial spx source files of
+// sprites generated by spx/newSpriteScript, e.g., for hosts to start sprites
+// of beginners with more scaffolding. The template is executed with
+// [SpxSpriteScriptTemplateData]. If t is nil, the default template is used.
+func (s *Server) SetSpriteScriptTemplate(t *template.Template) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.spriteScriptTemplate = t
+}
+
+// spxNewSpriteScript handles the spx/newSpriteScript request, which returns the
+// initial spx source file of a newly created sprite resource. The client is
+// expected to create the file, which must not exist yet.
+func (s *Server) spxNewSpriteScript(ctx context.Context, params *SpxNewSpriteScriptParams) (*SpxNewSpriteScript, error) {
+	id, err := ParseSpxResourceURI(params.Sprite.URI)
+	if err != nil {
+		return nil, err
+	}
+	spriteID, ok := id.(SpxSpriteResourceID)
+	if !ok {
+		return nil, fmt.Errorf("expected spx sprite resource, got %T", id)
+	}
+
+	snapshot := s.snapshot()
+	result, err := s.compileAt(ctx, snapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	sprite := result.spxResourceSet.Sprite(spriteID.SpriteName)
+	if sprite == nil {
+		return nil, fmt.Errorf("sprite resource %q not found", spriteID.SpriteName)
+	}
+	spxFile := sprite.Name + ".spx"
+	if _, err := vfs.ReadFile(snapshot, spxFile); err == nil {
+		return nil, fmt.Errorf("spx source file of sprite %q already exists", sprite.Name)
+	}
+
+	data := SpxSpriteScriptTemplateData{SpriteName: sprite.Name}
+	for _, costume := range sprite.NormalCostumes {
+		data.Costumes = append(data.Costumes, costume.Name)
+	}
+	if idx := sprite.CostumeIndex; idx >= 0 && idx < len(sprite.Costumes) {
+		data.DefaultCostume = sprite.Costumes[idx].Name
+	}
+	for _, animation := range sprite.Animations {
+		data.Animations = append(data.Animations, animation.Name)
+	}
+
+	s.settingsMu.RLock()
+	tmpl := s.spriteScriptTemplate
+	s.settingsMu.RUnlock()
+	if tmpl == nil {
+		tmpl = defaultSpriteScriptTemplate
+	}
+	var content strings.Builder
+	if err := tmpl.Execute(&content, data); err != nil {
+		return nil, fmt.Errorf("failed to execute sprite script template: %w", err)
+	}
+
+	// Format the content the same way as textDocument/formatting, so that
+	// templates do not have to care about it. Content that fails to format,
+	// e.g., a template that is not valid spx code, is returned as is.
+	formatted, err := s.formatSpx(ctx, vfs.WithOverlay(snapshot, map[string]vfs.MapFile{
+		spxFile: {
+			Content: []byte(content.String()),
+			ModTime: time.Now(),
+		},
+	}), spxFile)
+	if err == nil && formatted != nil {
+		content.Reset()
+		content.Write(formatted)
+	}
+	return &SpxNewSpriteScript{
+		URI:     s.toDocumentURI(spxFile),
+		Content: content.String(),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxNewSpriteScript(t *testing.T) {
+	newServer := func() *Server {
+		m := map[string][]byte{
+			"main.spx":                            []byte(`echo "main"`),
+			"Existing.spx":                        []byte(`onStart => {}`),
+			"assets/index.json":                   []byte(`{}`),
+			"assets/sprites/Existing/index.json":  []byte(`{}`),
+			"assets/sprites/NoCostume/index.json": []byte(`{}`),
+			"assets/sprites/MySprite/index.json":  []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"},{"name":"walk1"},{"name":"walk2"}],"costumeIndex":1,"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk2"}}}`),
+		}
+		return New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	}
+	newSpriteScript := func(s *Server, spriteName string) (*SpxNewSpriteScript, error) {
+		return s.spxNewSpriteScript(context.Background(), &SpxNewSpriteScriptParams{
+			Sprite: SpxResourceIdentifier{URI: SpxSpriteResourceID{SpriteName: spriteName}.URI()},
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		script, err := newSpriteScript(newServer(), "MySprite")
+		require.NoError(t, err)
+		assert.Equal(t, &SpxNewSpriteScript{
+			URI: "file:///MySprite.spx",
+			Content: `onStart => {
+	setCostume "costume2"
+}
+`,
+		}, script)
+	})
+
+	t.Run("NoCostume", func(t *testing.T) {
+		script, err := newSpriteScript(newServer(), "NoCostume")
+		require.NoError(t, err)
+		assert.Equal(t, "onStart => {\n}\n", script.Content)
+	})
+
+	t.Run("CustomTemplate", func(t *testing.T) {
+		s := newServer()
+		s.SetSpriteScriptTemplate(template.Must(template.New("sprite").Parse(`// {{.SpriteName}}
+onStart => {
+{{range .Costumes}}  echo {{printf "%q" .}}
+{{end}}}
+onClick => { animate {{printf "%q" (index .Animations 0)}} }
+`)))
+		script, err := newSpriteScript(s, "MySprite")
+		require.NoError(t, err)
+		assert.Equal(t, `// MySprite
+
+onStart => {
+	echo "costume1"
+	echo "costume2"
+}
+onClick => {
+	animate "walk"
+}
+`, script.Content)
+
+		s.SetSpriteScriptTemplate(nil)
+		script, err = newSpriteScript(s, "MySprite")
+		require.NoError(t, err)
+		assert.Contains(t, script.Content, `setCostume "costume2"`)
+	})
+
+	t.Run("InvalidTemplateOutput", func(t *testing.T) {
+		s := newServer()
+		s.SetSpriteScriptTemplate(template.Must(template.New("sprite").Parse(`onStart => {`)))
+		script, err := newSpriteScript(s, "MySprite")
+		require.NoError(t, err)
+		assert.Equal(t, `onStart => {`, script.Content)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		s := newServer()
+		_, err := newSpriteScript(s, "Existing")
+		assert.EqualError(t, err, `spx source file of sprite "Existing" already exists`)
+		_, err = newSpriteScript(s, "Unknown")
+		assert.EqualError(t, err, `sprite resource "Unknown" not found`)
+		_, err = s.spxNewSpriteScript(context.Background(), &SpxNewSpriteScriptParams{
+			Sprite: SpxResourceIdentifier{URI: "spx://resources/sounds/MySound"},
+		})
+		assert.EqualError(t, err, "expected spx sprite resource, got server.SpxSoundResourceID")
+	})
+}
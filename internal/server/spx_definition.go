@@ -34,12 +34,12 @@ func (def SpxDefinition) HTML() string {
 	return fmt.Sprintf("<definition-item def-id=%s overview=%s>\n%s</definition-item>\n", attr(def.ID.String()), attr(def.Overview), def.Detail)
 }
 
-// CompletionItem constructs a [CompletionItem] from the definition.
+// CompletionItem constructs a [CompletionItem] from the definition. The
+// documentation is left out, see [Server.completionItemResolve].
 func (def SpxDefinition) CompletionItem() CompletionItem {
 	return CompletionItem{
 		Label:            def.CompletionItemLabel,
 		Kind:             def.CompletionItemKind,
-		Documentation:    &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: def.HTML()}},
 		InsertText:       def.CompletionItemInsertText,
 		InsertTextFormat: &def.CompletionItemInsertTextFormat,
 		Data: &CompletionItemData{
@@ -138,6 +138,62 @@ var (
 		},
 	}
 
+	// SpxEventHandlerSnippetDefinitions are spx definitions of event handler
+	// snippets. Each is only available in spx files whose class has the
+	// event handler method named after its completion item label.
+	SpxEventHandlerSnippetDefinitions = []SpxDefinition{
+		{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onStart_snippet")},
+			Overview: "onStart => { ... }",
+			Detail:   "Listen to game start",
+
+			CompletionItemLabel:            "onStart",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onStart => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		},
+		{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onClick_snippet")},
+			Overview: "onClick => { ... }",
+			Detail:   "Listen to click",
+
+			CompletionItemLabel:            "onClick",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onClick => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		},
+		{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onMsg_snippet")},
+			Overview: "onMsg msg, => { ... }",
+			Detail:   "Listen to specific message broadcasted",
+
+			CompletionItemLabel:            "onMsg",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onMsg \"${1:msg}\", => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		},
+		{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onKey_snippet")},
+			Overview: "onKey key, => { ... }",
+			Detail:   "Listen to specific key pressed",
+
+			CompletionItemLabel:            "onKey",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onKey ${1:KeySpace}, => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		},
+		{
+			ID:       SpxDefinitionIdentifier{Name: util.ToPtr("onTouchStart_snippet")},
+			Overview: "onTouchStart sprite, => { ... }",
+			Detail:   "Listen to sprite touching start",
+
+			CompletionItemLabel:            "onTouchStart",
+			CompletionItemKind:             SnippetCompletion,
+			CompletionItemInsertText:       "onTouchStart \"${1:sprite}\", => {\n\t$0\n}",
+			CompletionItemInsertTextFormat: SnippetTextFormat,
+		},
+	}
+
 	// builtinSpxDefinitionOverviews contains overview descriptions for
 	// builtin spx definitions.
 	builtinSpxDefinitionOverviews = map[string]string{
@@ -437,6 +493,19 @@ var (
 		return spxPkg.Scope().Lookup("WidgetName").Type().(*types.Alias)
 	})
 
+	// GetSpxFontNameType returns the [spx.FontName] type. It returns nil if
+	// the spx package does not declare it, as custom fonts are only supported
+	// by newer versions of spx.
+	GetSpxFontNameType = sync.OnceValue(func() *types.Alias {
+		spxPkg := GetSpxPkg()
+		obj := spxPkg.Scope().Lookup("FontName")
+		if obj == nil {
+			return nil
+		}
+		alias, _ := obj.Type().(*types.Alias)
+		return alias
+	})
+
 	// GetSpxPkgDefinitions returns the spx definitions for the spx package.
 	GetSpxPkgDefinitions = sync.OnceValue(func() []SpxDefinition {
 		spxPkg := GetSpxPkg()
@@ -1,14 +1,25 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io/fs"
+	"iter"
+	"maps"
+	"math"
 	"net/url"
 	"path"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	gopast "github.com/goplus/gop/ast"
 	"github.com/goplus/goxlsw/internal/vfs"
@@ -46,7 +57,13 @@ func ParseSpxResourceURI(uri SpxResourceURI) (SpxResourceID, error) {
 	}
 	pathParts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
 	pathPartCount := len(pathParts)
-	if u.Scheme != "spx" || u.Host != "resources" || path.Clean(u.Path) != u.Path || pathPartCount < 2 {
+	if u.Scheme != "spx" || u.Host != "resources" || path.Clean(u.Path) != u.Path || pathParts[0] == "" {
+		return nil, fmt.Errorf("invalid spx resource URI: %s", uri)
+	}
+	if pathPartCount == 1 {
+		if pathParts[0] == "stage" {
+			return SpxStageResourceID{}, nil
+		}
 		return nil, fmt.Errorf("invalid spx resource URI: %s", uri)
 	}
 	switch pathParts[0] {
@@ -68,6 +85,10 @@ func ParseSpxResourceURI(uri SpxResourceURI) (SpxResourceID, error) {
 		}
 	case "widgets":
 		return SpxWidgetResourceID{WidgetName: pathParts[1]}, nil
+	case "scenes":
+		return SpxSceneResourceID{SceneName: pathParts[1]}, nil
+	case "fonts":
+		return SpxFontResourceID{FontName: pathParts[1]}, nil
 	}
 	return nil, fmt.Errorf("unsupported or malformed spx resource type in URI: %s", uri)
 }
@@ -78,45 +99,193 @@ type SpxResourceSet struct {
 	sounds    map[string]*SpxSoundResource
 	sprites   map[string]*SpxSpriteResource
 	widgets   map[string]*SpxWidgetResource
+	scenes    map[string]*SpxSceneResource
+	fonts     map[string]*SpxFontResource
+	stage     *SpxStageResource
+
+	// defaultBackdrop is the name of the backdrop shown when the game starts.
+	defaultBackdrop string
 }
 
-// NewSpxResourceSet creates a new spx resource set.
-func NewSpxResourceSet(rootFS vfs.SubFS) (*SpxResourceSet, error) {
-	set := &SpxResourceSet{
-		backdrops: make(map[string]*SpxBackdropResource),
-		sounds:    make(map[string]*SpxSoundResource),
-		sprites:   make(map[string]*SpxSpriteResource),
-		widgets:   make(map[string]*SpxWidgetResource),
-	}
+// spxResourceMetadataCacheKind is the file cache kind of parsed spx resource
+// metadata files, i.e., the index.json files of the resource root, sounds,
+// sprites and fonts. Caching them per file means that only the resources whose metadata
+// files changed are parsed again when the resource set is rebuilt.
+const spxResourceMetadataCacheKind = "spxResourceMetadata"
 
-	// Read and parse the main index.json for backdrops and widgets.
-	metadata, err := rootFS.ReadFile("index.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read index.json: %w", err)
+// spxResourceIndex is the parsed index.json of the spx resource root.
+type spxResourceIndex struct {
+	backdrops       []*SpxBackdropResource
+	defaultBackdrop string
+	widgets         []*SpxWidgetResource
+	scenes          []*SpxSceneResource
+	stage           *SpxStageResource
+}
+
+// buildSpxResourceMetadataCache parses the spx resource metadata file at the
+// given path. Depending on the path, it returns a [*SpxSoundResource], a
+// [*SpxSpriteResource], a [*SpxFontResource] or a [*spxResourceIndex].
+func buildSpxResourceMetadataCache(proj *vfs.MapFS, name string, file vfs.MapFile) (any, error) {
+	resourceDir := path.Dir(name)
+	resourceName := path.Base(resourceDir)
+	switch path.Base(path.Dir(resourceDir)) {
+	case "sounds":
+		return parseSpxSoundResource(resourceName, file.Content)
+	case "sprites":
+		return parseSpxSpriteResource(resourceName, file.Content)
+	case "fonts":
+		return parseSpxFontResource(resourceName, file.Content)
 	}
+	return parseSpxResourceIndex(file.Content)
+}
 
+// parseSpxResourceIndex parses the index.json of the spx resource root for
+// backdrops, scenes, widgets and the stage.
+func parseSpxResourceIndex(metadata []byte) (*spxResourceIndex, error) {
 	var assets struct {
-		Backdrops []SpxBackdropResource `json:"backdrops"`
-		Zorder    []json.RawMessage     `json:"zorder"`
+		Backdrops     []SpxBackdropResource `json:"backdrops"`
+		BackdropIndex int                   `json:"backdropIndex"`
+		Scenes        []SpxSceneResource    `json:"scenes"`
+		Zorder        []json.RawMessage     `json:"zorder"`
 	}
 	if err := json.Unmarshal(metadata, &assets); err != nil {
 		return nil, fmt.Errorf("failed to parse index.json: %w", err)
 	}
+	stage := &SpxStageResource{ID: SpxStageResourceID{}}
+	if err := json.Unmarshal(metadata, stage); err != nil {
+		return nil, fmt.Errorf("failed to parse index.json: %w", err)
+	}
+
+	index := &spxResourceIndex{
+		backdrops: make([]*SpxBackdropResource, 0, len(assets.Backdrops)),
+		scenes:    make([]*SpxSceneResource, 0, len(assets.Scenes)),
+		stage:     stage,
+	}
 
 	// Process backdrops.
 	for _, backdrop := range assets.Backdrops {
 		backdrop.ID = SpxBackdropResourceID{BackdropName: backdrop.Name}
-		set.backdrops[backdrop.Name] = &backdrop
+		index.backdrops = append(index.backdrops, &backdrop)
+	}
+	if idx := assets.BackdropIndex; idx >= 0 && idx < len(assets.Backdrops) {
+		index.defaultBackdrop = assets.Backdrops[idx].Name
+	}
+
+	// Process scenes.
+	for _, scene := range assets.Scenes {
+		scene.ID = SpxSceneResourceID{SceneName: scene.Name}
+		index.scenes = append(index.scenes, &scene)
 	}
 
 	// Process widgets from zorder.
 	for _, item := range assets.Zorder {
 		var widget SpxWidgetResource
-		if err := json.Unmarshal(item, &widget); err == nil && widget.Name != "" {
-			widget.ID = SpxWidgetResourceID{WidgetName: widget.Name}
-			set.widgets[widget.Name] = &widget
+		if err := json.Unmarshal(item, &widget); err != nil || widget.Name == "" || slices.Contains(spxNonWidgetShapeTypes, widget.Type) {
+			continue
+		}
+		widget.ID = SpxWidgetResourceID{WidgetName: widget.Name}
+		if widget.WidgetType = LookupSpxWidgetType(widget.Type); widget.WidgetType != nil {
+			// Invalid type-specific fields are reported by the metadata
+			// validation, so the widget is kept without them.
+			_ = widget.WidgetType.parse(&widget, item)
 		}
+		index.widgets = append(index.widgets, &widget)
+	}
+	return index, nil
+}
+
+// parseSpxSoundResource parses the index.json of the sound with the given
+// name.
+func parseSpxSoundResource(soundName string, metadata []byte) (*SpxSoundResource, error) {
+	var sound SpxSoundResource
+	if err := json.Unmarshal(metadata, &sound); err != nil {
+		return nil, fmt.Errorf("failed to parse sound metadata: %w", err)
+	}
+	sound.Name = soundName
+	sound.ID = SpxSoundResourceID{SoundName: soundName}
+	return &sound, nil
+}
+
+// parseSpxFontResource parses the index.json of the font with the given name.
+func parseSpxFontResource(fontName string, metadata []byte) (*SpxFontResource, error) {
+	var font SpxFontResource
+	if err := json.Unmarshal(metadata, &font); err != nil {
+		return nil, fmt.Errorf("failed to parse font metadata: %w", err)
+	}
+	font.Name = fontName
+	font.ID = SpxFontResourceID{FontName: fontName}
+	return &font, nil
+}
+
+// parseSpxSpriteResource parses the index.json of the sprite with the given
+// name.
+func parseSpxSpriteResource(spriteName string, metadata []byte) (*SpxSpriteResource, error) {
+	sprite := SpxSpriteResource{
+		ID:   SpxSpriteResourceID{SpriteName: spriteName},
+		Name: spriteName,
+	}
+	if err := json.Unmarshal(metadata, &sprite); err != nil {
+		return nil, fmt.Errorf("failed to parse sprite metadata: %w", err)
+	}
+
+	// Process costumes.
+	if len(sprite.Costumes) == 0 {
+		sprite.Costumes = sprite.expandCostumeGroups()
+	}
+	for i, costume := range sprite.Costumes {
+		sprite.Costumes[i].ID = SpxSpriteCostumeResourceID{
+			SpriteName:  spriteName,
+			CostumeName: costume.Name,
+		}
+	}
+
+	// Process animations.
+	sprite.Animations = make([]SpxSpriteAnimationResource, 0, len(sprite.FAnimations))
+	for animName, fAnim := range sprite.FAnimations {
+		sprite.Animations = append(sprite.Animations, SpxSpriteAnimationResource{
+			ID:        SpxSpriteAnimationResourceID{SpriteName: spriteName, AnimationName: animName},
+			Name:      animName,
+			FrameFrom: fAnim.FrameFrom,
+			FrameTo:   fAnim.FrameTo,
+			FromIndex: getCostumeIndex(fAnim.FrameFrom, sprite.Costumes),
+			ToIndex:   getCostumeIndex(fAnim.FrameTo, sprite.Costumes),
+		})
+	}
+
+	// Process normal costumes.
+	sprite.NormalCostumes = make([]SpxSpriteCostumeResource, 0, len(sprite.Costumes))
+	for i, costume := range sprite.Costumes {
+		isAnimation := slices.ContainsFunc(sprite.Animations, func(anim SpxSpriteAnimationResource) bool {
+			return anim.includeCostume(i)
+		})
+		if !isAnimation {
+			sprite.NormalCostumes = append(sprite.NormalCostumes, costume)
+		}
+	}
+	return &sprite, nil
+}
+
+// NewSpxResourceSet creates a new spx resource set.
+//
+// Parsed metadata files are cached in the file cache of rootFS, and the
+// resources built from them are shared by all sets built from unchanged
+// metadata files. Callers must not modify the resources.
+func NewSpxResourceSet(rootFS vfs.SubFS) (*SpxResourceSet, error) {
+	set := &SpxResourceSet{
+		backdrops: make(map[string]*SpxBackdropResource),
+		sounds:    make(map[string]*SpxSoundResource),
+		sprites:   make(map[string]*SpxSpriteResource),
+		widgets:   make(map[string]*SpxWidgetResource),
+		scenes:    make(map[string]*SpxSceneResource),
+		fonts:     make(map[string]*SpxFontResource),
+	}
+
+	// Read the main index.json for backdrops, scenes, widgets and the stage.
+	index, err := loadSpxResourceMetadata[*spxResourceIndex](rootFS, "index.json")
+	if err != nil {
+		return nil, err
 	}
+	set.setIndex(index)
 
 	// Read sounds directory.
 	soundEntries, err := rootFS.Readdir("sounds")
@@ -129,18 +298,11 @@ func NewSpxResourceSet(rootFS vfs.SubFS) (*SpxResourceSet, error) {
 		}
 
 		soundName := entry.Name()
-		soundMetadata, err := rootFS.ReadFile(path.Join("sounds", soundName, "index.json"))
+		sound, err := loadSpxResourceMetadata[*SpxSoundResource](rootFS, path.Join("sounds", soundName, "index.json"))
 		if err != nil {
-			return nil, fmt.Errorf("failed to read sound metadata: %w", err)
+			return nil, err
 		}
-
-		var sound SpxSoundResource
-		if err := json.Unmarshal(soundMetadata, &sound); err != nil {
-			return nil, fmt.Errorf("failed to parse sound metadata: %w", err)
-		}
-		sound.Name = soundName
-		sound.ID = SpxSoundResourceID{SoundName: soundName}
-		set.sounds[soundName] = &sound
+		set.sounds[soundName] = sound
 	}
 
 	// Read sprites directory.
@@ -154,53 +316,130 @@ func NewSpxResourceSet(rootFS vfs.SubFS) (*SpxResourceSet, error) {
 		}
 
 		spriteName := entry.Name()
-		spriteMetadata, err := rootFS.ReadFile(path.Join("sprites", spriteName, "index.json"))
+		sprite, err := loadSpxResourceMetadata[*SpxSpriteResource](rootFS, path.Join("sprites", spriteName, "index.json"))
 		if err != nil {
-			return nil, fmt.Errorf("failed to read sprite metadata: %w", err)
+			return nil, err
 		}
+		set.sprites[spriteName] = sprite
+	}
 
-		sprite := SpxSpriteResource{
-			ID:   SpxSpriteResourceID{SpriteName: spriteName},
-			Name: spriteName,
-		}
-		if err := json.Unmarshal(spriteMetadata, &sprite); err != nil {
-			return nil, fmt.Errorf("failed to parse sprite metadata: %w", err)
+	// Read fonts directory.
+	fontEntries, err := rootFS.Readdir("fonts")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read fonts directory: %w", err)
+	}
+	for _, entry := range fontEntries {
+		if !entry.IsDir() {
+			continue
 		}
 
-		// Process costumes.
-		for i, costume := range sprite.Costumes {
-			sprite.Costumes[i].ID = SpxSpriteCostumeResourceID{
-				SpriteName:  spriteName,
-				CostumeName: costume.Name,
-			}
+		fontName := entry.Name()
+		font, err := loadSpxResourceMetadata[*SpxFontResource](rootFS, path.Join("fonts", fontName, "index.json"))
+		if err != nil {
+			return nil, err
 		}
+		set.fonts[fontName] = font
+	}
 
-		// Process animations.
-		sprite.Animations = make([]SpxSpriteAnimationResource, 0, len(sprite.FAnimations))
-		for animName, fAnim := range sprite.FAnimations {
-			sprite.Animations = append(sprite.Animations, SpxSpriteAnimationResource{
-				ID:        SpxSpriteAnimationResourceID{SpriteName: spriteName, AnimationName: animName},
-				Name:      animName,
-				FromIndex: getCostumeIndex(fAnim.FrameFrom, sprite.Costumes),
-				ToIndex:   getCostumeIndex(fAnim.FrameTo, sprite.Costumes),
-			})
-		}
+	return set, nil
+}
 
-		// Process normal costumes.
-		sprite.NormalCostumes = make([]SpxSpriteCostumeResource, 0, len(sprite.Costumes))
-		for i, costume := range sprite.Costumes {
-			isAnimation := slices.ContainsFunc(sprite.Animations, func(anim SpxSpriteAnimationResource) bool {
-				return anim.includeCostume(i)
-			})
-			if !isAnimation {
-				sprite.NormalCostumes = append(sprite.NormalCostumes, costume)
-			}
+// setIndex replaces the resources of the set that are defined in the
+// index.json of the resource root with the ones of index.
+func (set *SpxResourceSet) setIndex(index *spxResourceIndex) {
+	clear(set.backdrops)
+	for _, backdrop := range index.backdrops {
+		set.backdrops[backdrop.Name] = backdrop
+	}
+	set.defaultBackdrop = index.defaultBackdrop
+	clear(set.scenes)
+	for _, scene := range index.scenes {
+		set.scenes[scene.Name] = scene
+	}
+	clear(set.widgets)
+	for _, widget := range index.widgets {
+		set.widgets[widget.Name] = widget
+	}
+	set.stage = index.stage
+}
+
+// UpdateSpxResource updates the set in place for a change of the spx resource
+// file or directory at the given path relative to the resource root, e.g.,
+// "sprites/MySprite/index.json" or "sounds/MySound". Only the affected
+// resource is loaded again: the backdrops, scenes, widgets and stage for the
+// resource root index.json, or the sprite, sound or font the path belongs to.
+// A sprite, sound or font whose index.json no longer exists is removed. Changes of other files, e.g.,
+// costume images, do not affect the set.
+func (set *SpxResourceSet) UpdateSpxResource(rootFS vfs.SubFS, name string) error {
+	parts := strings.Split(name, "/")
+	switch {
+	case name == "index.json":
+		index, err := loadSpxResourceMetadata[*spxResourceIndex](rootFS, name)
+		if err != nil {
+			return err
 		}
+		set.setIndex(index)
+	case len(parts) >= 2 && parts[0] == "sounds":
+		soundName := parts[1]
+		sound, err := loadSpxResourceMetadata[*SpxSoundResource](rootFS, path.Join("sounds", soundName, "index.json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(set.sounds, soundName)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		set.sounds[soundName] = sound
+	case len(parts) >= 2 && parts[0] == "sprites":
+		spriteName := parts[1]
+		sprite, err := loadSpxResourceMetadata[*SpxSpriteResource](rootFS, path.Join("sprites", spriteName, "index.json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(set.sprites, spriteName)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		set.sprites[spriteName] = sprite
+	case len(parts) >= 2 && parts[0] == "fonts":
+		fontName := parts[1]
+		font, err := loadSpxResourceMetadata[*SpxFontResource](rootFS, path.Join("fonts", fontName, "index.json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(set.fonts, fontName)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		set.fonts[fontName] = font
+	}
+	return nil
+}
 
-		set.sprites[spriteName] = &sprite
+// clone returns a shallow copy of the set. The resources are shared, but the
+// copy can be updated without affecting the original set.
+func (set *SpxResourceSet) clone() *SpxResourceSet {
+	return &SpxResourceSet{
+		backdrops:       maps.Clone(set.backdrops),
+		sounds:          maps.Clone(set.sounds),
+		sprites:         maps.Clone(set.sprites),
+		widgets:         maps.Clone(set.widgets),
+		scenes:          maps.Clone(set.scenes),
+		fonts:           maps.Clone(set.fonts),
+		stage:           set.stage,
+		defaultBackdrop: set.defaultBackdrop,
 	}
+}
 
-	return set, nil
+// loadSpxResourceMetadata loads the parsed spx resource metadata file at the
+// given path from the file cache of rootFS.
+func loadSpxResourceMetadata[T any](rootFS vfs.SubFS, name string) (T, error) {
+	var zero T
+	metadata, err := rootFS.FileCache(spxResourceMetadataCacheKind, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return zero, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return zero, err
+	}
+	return metadata.(T), nil
 }
 
 // Backdrop returns the backdrop with the given name. It returns nil if not found.
@@ -211,6 +450,12 @@ func (set *SpxResourceSet) Backdrop(name string) *SpxBackdropResource {
 	return set.backdrops[name]
 }
 
+// DefaultBackdrop returns the backdrop shown when the game starts. It returns
+// nil if there is no backdrop.
+func (set *SpxResourceSet) DefaultBackdrop() *SpxBackdropResource {
+	return set.Backdrop(set.defaultBackdrop)
+}
+
 // Sound returns the sound with the given name. It returns nil if not found.
 func (set *SpxResourceSet) Sound(name string) *SpxSoundResource {
 	if set.sounds == nil {
@@ -227,6 +472,33 @@ func (set *SpxResourceSet) Sprite(name string) *SpxSpriteResource {
 	return set.sprites[name]
 }
 
+// Contains reports whether the resource identified by id exists in the set.
+func (set *SpxResourceSet) Contains(id SpxResourceID) bool {
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		return set.Backdrop(id.BackdropName) != nil
+	case SpxSoundResourceID:
+		return set.Sound(id.SoundName) != nil
+	case SpxSpriteResourceID:
+		return set.Sprite(id.SpriteName) != nil
+	case SpxSpriteCostumeResourceID:
+		sprite := set.Sprite(id.SpriteName)
+		return sprite != nil && sprite.Costume(id.CostumeName) != nil
+	case SpxSpriteAnimationResourceID:
+		sprite := set.Sprite(id.SpriteName)
+		return sprite != nil && sprite.Animation(id.AnimationName) != nil
+	case SpxWidgetResourceID:
+		return set.Widget(id.WidgetName) != nil
+	case SpxSceneResourceID:
+		return set.Scene(id.SceneName) != nil
+	case SpxFontResourceID:
+		return set.Font(id.FontName) != nil
+	case SpxStageResourceID:
+		return set.Stage() != nil
+	}
+	return false
+}
+
 // Widget returns the widget with the given name. It returns nil if not found.
 func (set *SpxResourceSet) Widget(name string) *SpxWidgetResource {
 	if set.widgets == nil {
@@ -235,11 +507,45 @@ func (set *SpxResourceSet) Widget(name string) *SpxWidgetResource {
 	return set.widgets[name]
 }
 
+// Scene returns the scene with the given name. It returns nil if not found.
+func (set *SpxResourceSet) Scene(name string) *SpxSceneResource {
+	if set.scenes == nil {
+		return nil
+	}
+	return set.scenes[name]
+}
+
+// Font returns the font with the given name. It returns nil if not found.
+func (set *SpxResourceSet) Font(name string) *SpxFontResource {
+	if set.fonts == nil {
+		return nil
+	}
+	return set.fonts[name]
+}
+
+// Stage returns the stage configuration. It returns nil if the set was not
+// loaded from a resource root.
+func (set *SpxResourceSet) Stage() *SpxStageResource {
+	return set.stage
+}
+
 // SpxBackdropResource represents a backdrop resource in spx.
 type SpxBackdropResource struct {
 	ID   SpxBackdropResourceID `json:"-"`
 	Name string                `json:"name"`
 	Path string                `json:"path"`
+
+	// BitmapResolution is the number of image pixels per stage pixel.
+	BitmapResolution int `json:"bitmapResolution"`
+
+	// X and Y are the rotation center in image pixels from the top-left
+	// corner of the image.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+
+	// FaceRight is the angle in degrees to turn the image so that it faces
+	// right.
+	FaceRight float64 `json:"faceRight"`
 }
 
 // SpxBackdropResourceID is the ID of an spx backdrop resource.
@@ -259,9 +565,20 @@ func (id SpxBackdropResourceID) URI() SpxResourceURI {
 
 // SpxSoundResource represents a sound resource in spx.
 type SpxSoundResource struct {
-	ID   SpxSoundResourceID `json:"-"`
-	Name string             `json:"name"`
-	Path string             `json:"path"`
+	ID          SpxSoundResourceID `json:"-"`
+	Name        string             `json:"name"`
+	Path        string             `json:"path"`
+	Rate        int                `json:"rate"`
+	SampleCount int                `json:"sampleCount"`
+}
+
+// Duration returns the duration of the sound. It returns 0 if the sound
+// metadata does not include the sample rate and count.
+func (sound *SpxSoundResource) Duration() time.Duration {
+	if sound.Rate <= 0 || sound.SampleCount <= 0 {
+		return 0
+	}
+	return time.Duration(sound.SampleCount) * time.Second / time.Duration(sound.Rate)
 }
 
 // SpxSoundResourceID is the ID of an spx sound resource.
@@ -295,6 +612,80 @@ type SpxSpriteResource struct {
 	FAnimations      map[string]spxSpriteFAnimation `json:"fAnimations"`
 	Animations       []SpxSpriteAnimationResource   `json:"-"`
 	DefaultAnimation string                         `json:"defaultAnimation"`
+
+	// CostumeSet and CostumeMPSet are costume groups cut from a single image,
+	// which are used instead of Costumes if there are none.
+	CostumeSet   *spxCostumeSet   `json:"costumeSet"`
+	CostumeMPSet *spxCostumeMPSet `json:"costumeMPSet"`
+}
+
+// spxCostumeSetItem names a run of n costumes of a costume group, e.g.,
+// "walk0", "walk1" and "walk2" for the name prefix "walk" and n = 3.
+type spxCostumeSetItem struct {
+	NamePrefix string `json:"namePrefix"`
+	N          int    `json:"n"`
+}
+
+// spxCostumeSetPart is a row of nx costumes of a multi-part costume group.
+type spxCostumeSetPart struct {
+	Nx    int                 `json:"nx"`
+	Items []spxCostumeSetItem `json:"items"`
+}
+
+// spxCostumeSet is a group of nx costumes cut from the image at path.
+type spxCostumeSet struct {
+	Path             string              `json:"path"`
+	FaceRight        float64             `json:"faceRight"`
+	BitmapResolution int                 `json:"bitmapResolution"`
+	Nx               int                 `json:"nx"`
+	Items            []spxCostumeSetItem `json:"items"`
+}
+
+// spxCostumeMPSet is a group of costumes cut from multiple parts of the image
+// at path.
+type spxCostumeMPSet struct {
+	Path             string              `json:"path"`
+	FaceRight        float64             `json:"faceRight"`
+	BitmapResolution int                 `json:"bitmapResolution"`
+	Parts            []spxCostumeSetPart `json:"parts"`
+}
+
+// expandCostumeGroups returns the costumes of the costume group of the
+// sprite, named the same way as spx names them.
+func (sprite *SpxSpriteResource) expandCostumeGroups() []SpxSpriteCostumeResource {
+	var costumes []SpxSpriteCostumeResource
+	addPart := func(path string, faceRight float64, bitmapResolution, nx int, items []spxCostumeSetItem) {
+		add := func(name string) {
+			costumes = append(costumes, SpxSpriteCostumeResource{
+				Name:             name,
+				Path:             path,
+				BitmapResolution: bitmapResolution,
+				FaceRight:        faceRight,
+			})
+		}
+		if nx == 1 || items == nil {
+			for range max(nx, 1) {
+				add(strconv.Itoa(len(costumes)))
+			}
+			return
+		}
+		for _, item := range items {
+			for i := range item.N {
+				add(item.NamePrefix + strconv.Itoa(i))
+			}
+		}
+	}
+	switch {
+	case sprite.CostumeSet != nil:
+		set := sprite.CostumeSet
+		addPart(set.Path, set.FaceRight, set.BitmapResolution, set.Nx, set.Items)
+	case sprite.CostumeMPSet != nil:
+		set := sprite.CostumeMPSet
+		for _, part := range set.Parts {
+			addPart(set.Path, set.FaceRight, set.BitmapResolution, part.Nx, part.Items)
+		}
+	}
+	return costumes
 }
 
 // SpxSpriteResourceID is the ID of an spx sprite resource.
@@ -334,11 +725,45 @@ func (sprite *SpxSpriteResource) Animation(name string) *SpxSpriteAnimationResou
 	return &sprite.Animations[idx]
 }
 
+// costumeNames returns the names of the costumes of the sprite.
+func (sprite *SpxSpriteResource) costumeNames() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, costume := range sprite.Costumes {
+			if !yield(costume.Name) {
+				return
+			}
+		}
+	}
+}
+
+// animationNames returns the names of the animations of the sprite.
+func (sprite *SpxSpriteResource) animationNames() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, animation := range sprite.Animations {
+			if !yield(animation.Name) {
+				return
+			}
+		}
+	}
+}
+
 // SpxSpriteCostumeResource represents an spx sprite costume resource.
 type SpxSpriteCostumeResource struct {
 	ID   SpxSpriteCostumeResourceID `json:"-"`
 	Name string                     `json:"name"`
 	Path string                     `json:"path"`
+
+	// BitmapResolution is the number of image pixels per stage pixel.
+	BitmapResolution int `json:"bitmapResolution"`
+
+	// X and Y are the rotation center in image pixels from the top-left
+	// corner of the image.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+
+	// FaceRight is the angle in degrees to turn the image so that it faces
+	// right.
+	FaceRight float64 `json:"faceRight"`
 }
 
 // SpxSpriteCostumeResourceID is the ID of an spx sprite costume resource.
@@ -361,6 +786,8 @@ func (id SpxSpriteCostumeResourceID) URI() SpxResourceURI {
 type SpxSpriteAnimationResource struct {
 	ID        SpxSpriteAnimationResourceID `json:"-"`
 	Name      string                       `json:"name"`
+	FrameFrom string                       `json:"-"`
+	FrameTo   string                       `json:"-"`
 	FromIndex *int                         `json:"-"`
 	ToIndex   *int                         `json:"-"`
 }
@@ -372,6 +799,22 @@ func (a *SpxSpriteAnimationResource) includeCostume(index int) bool {
 	return *a.FromIndex <= index && index <= *a.ToIndex
 }
 
+// HasMissingFrames reports whether the frame range of the animation refers to
+// costumes that do not exist, e.g., because they have been deleted.
+func (a *SpxSpriteAnimationResource) HasMissingFrames() bool {
+	return (a.FrameFrom != "" && a.FromIndex == nil) || (a.FrameTo != "" && a.ToIndex == nil)
+}
+
+// Costumes returns the costumes of sprite covered by the animation, in frame
+// order. It returns nil if the frame range of the animation is incomplete,
+// refers to costumes that do not exist or is reversed.
+func (a *SpxSpriteAnimationResource) Costumes(sprite *SpxSpriteResource) []SpxSpriteCostumeResource {
+	if a.FromIndex == nil || a.ToIndex == nil || *a.FromIndex > *a.ToIndex || *a.ToIndex >= len(sprite.Costumes) {
+		return nil
+	}
+	return sprite.Costumes[*a.FromIndex : *a.ToIndex+1]
+}
+
 // SpxSpriteAnimationResourceID is the ID of an spx sprite animation resource.
 type SpxSpriteAnimationResourceID struct {
 	SpriteName    string
@@ -395,6 +838,14 @@ type SpxWidgetResource struct {
 	Type  string              `json:"type"`
 	Label string              `json:"label"`
 	Val   string              `json:"val"`
+
+	// WidgetType is the registered type of the widget. It is nil if Type is
+	// not a known widget type.
+	WidgetType *SpxWidgetType `json:"-"`
+
+	// Monitor holds the fields of a monitor widget. It is nil for widgets of
+	// other types.
+	Monitor *SpxMonitorWidget `json:"-"`
 }
 
 // SpxWidgetResourceID is the ID of an spx widget resource.
@@ -412,6 +863,84 @@ func (id SpxWidgetResourceID) URI() SpxResourceURI {
 	return SpxResourceURI(fmt.Sprintf("spx://resources/widgets/%s", id.WidgetName))
 }
 
+// SpxSceneResource represents a scene resource in spx. Scenes are the legacy
+// form of backdrops, which spx still loads if the index.json of the resource
+// root declares no backdrops.
+type SpxSceneResource struct {
+	ID   SpxSceneResourceID `json:"-"`
+	Name string             `json:"name"`
+	Path string             `json:"path"`
+
+	// BitmapResolution is the number of image pixels per stage pixel.
+	BitmapResolution int `json:"bitmapResolution"`
+}
+
+// SpxSceneResourceID is the ID of an spx scene resource.
+type SpxSceneResourceID struct {
+	SceneName string
+}
+
+// Name implements [SpxResourceID].
+func (id SpxSceneResourceID) Name() string {
+	return id.SceneName
+}
+
+// URI implements [SpxResourceID].
+func (id SpxSceneResourceID) URI() SpxResourceURI {
+	return SpxResourceURI(fmt.Sprintf("spx://resources/scenes/%s", id.SceneName))
+}
+
+// SpxFontResource represents a custom font resource in spx.
+type SpxFontResource struct {
+	ID   SpxFontResourceID `json:"-"`
+	Name string            `json:"name"`
+	Path string            `json:"path"`
+}
+
+// SpxFontResourceID is the ID of an spx font resource.
+type SpxFontResourceID struct {
+	FontName string
+}
+
+// Name implements [SpxResourceID].
+func (id SpxFontResourceID) Name() string {
+	return id.FontName
+}
+
+// URI implements [SpxResourceID].
+func (id SpxFontResourceID) URI() SpxResourceURI {
+	return SpxResourceURI(fmt.Sprintf("spx://resources/fonts/%s", id.FontName))
+}
+
+// SpxStageResource represents the stage configuration in the index.json of
+// the spx resource root.
+type SpxStageResource struct {
+	ID  SpxStageResourceID `json:"-"`
+	Map struct {
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		Mode   string `json:"mode"`
+	} `json:"map"`
+	Run struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"run"`
+}
+
+// SpxStageResourceID is the ID of the spx stage resource. There is only one
+// stage per project.
+type SpxStageResourceID struct{}
+
+// Name implements [SpxResourceID].
+func (id SpxStageResourceID) Name() string {
+	return "stage"
+}
+
+// URI implements [SpxResourceID].
+func (id SpxStageResourceID) URI() SpxResourceURI {
+	return "spx://resources/stage"
+}
+
 func getCostumeIndex(name string, costumes []SpxSpriteCostumeResource) *int {
 	for i, costume := range costumes {
 		if costume.Name == name {
@@ -420,3 +949,156 @@ func getCostumeIndex(name string, costumes []SpxSpriteCostumeResource) *int {
 	}
 	return nil
 }
+
+// spxResourceImage describes the image of an spx resource.
+type spxResourceImage struct {
+	path             string // relative to the workspace root
+	name             string
+	bitmapResolution int
+	pivotX, pivotY   float64 // in image pixels
+	faceRight        float64
+	hasPivot         bool // only costumes have rotation centers
+}
+
+// spxResourceImageOf returns the image of the spx resource identified by id.
+// It returns nil if the resource does not exist or has no image.
+func spxResourceImageOf(result *compileResult, id SpxResourceID) *spxResourceImage {
+	rootDir := result.spxResourceRootDir
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		if backdrop := result.spxResourceSet.Backdrop(id.BackdropName); backdrop != nil && backdrop.Path != "" {
+			return &spxResourceImage{
+				path:             path.Join(rootDir, backdrop.Path),
+				name:             backdrop.Name,
+				bitmapResolution: backdrop.BitmapResolution,
+				faceRight:        backdrop.FaceRight,
+			}
+		}
+	case SpxSceneResourceID:
+		if scene := result.spxResourceSet.Scene(id.SceneName); scene != nil && scene.Path != "" {
+			return &spxResourceImage{
+				path:             path.Join(rootDir, scene.Path),
+				name:             scene.Name,
+				bitmapResolution: scene.BitmapResolution,
+			}
+		}
+	case SpxSpriteResourceID:
+		if sprite := result.spxResourceSet.Sprite(id.SpriteName); sprite != nil {
+			if idx := sprite.CostumeIndex; idx >= 0 && idx < len(sprite.Costumes) {
+				if image := spxCostumeImage(rootDir, sprite, &sprite.Costumes[idx]); image != nil {
+					image.name = sprite.Name
+					return image
+				}
+			}
+		}
+	case SpxSpriteCostumeResourceID:
+		if sprite := result.spxResourceSet.Sprite(id.SpriteName); sprite != nil {
+			if costume := sprite.Costume(id.CostumeName); costume != nil {
+				return spxCostumeImage(rootDir, sprite, costume)
+			}
+		}
+	}
+	return nil
+}
+
+// spxCostumeImage returns the image of the given costume of sprite. It
+// returns nil if the costume has no image.
+func spxCostumeImage(rootDir string, sprite *SpxSpriteResource, costume *SpxSpriteCostumeResource) *spxResourceImage {
+	if costume.Path == "" {
+		return nil
+	}
+	return &spxResourceImage{
+		path:             path.Join(rootDir, "sprites", sprite.Name, costume.Path),
+		name:             costume.Name,
+		bitmapResolution: costume.BitmapResolution,
+		pivotX:           costume.X,
+		pivotY:           costume.Y,
+		faceRight:        costume.FaceRight,
+		hasPivot:         true,
+	}
+}
+
+// scale returns the number of image pixels per stage pixel, which is at
+// least 1.
+func (image *spxResourceImage) scale() int {
+	return max(image.bitmapResolution, 1)
+}
+
+// size returns the size of the image in stage pixels. It returns false if the
+// size cannot be determined.
+func (image *spxResourceImage) size(proj *vfs.MapFS) (width, height int, ok bool) {
+	data, err := vfs.ReadFile(proj, image.path)
+	if err != nil {
+		return 0, 0, false
+	}
+	width, height, ok = spxImageSize(image.path, data)
+	if !ok {
+		return 0, 0, false
+	}
+	return width / image.scale(), height / image.scale(), true
+}
+
+// pivot returns the rotation center of the image in stage pixels from its
+// top-left corner.
+func (image *spxResourceImage) pivot() (x, y float64) {
+	scale := float64(image.scale())
+	return image.pivotX / scale, image.pivotY / scale
+}
+
+// spxImageSize returns the size in pixels of the given spx image resource
+// file, which is either an SVG image or a raster image in GIF, JPEG or PNG
+// format. It returns false if the size cannot be determined.
+func spxImageSize(name string, data []byte) (width, height int, ok bool) {
+	if strings.EqualFold(path.Ext(name), ".svg") {
+		return svgSize(data)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// svgSize returns the size of the given SVG image from the width and height
+// attributes of its root element, or from its viewBox if they are absent.
+func svgSize(data []byte) (width, height int, ok bool) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		root, isStart := tok.(xml.StartElement)
+		if !isStart {
+			continue
+		}
+		if root.Name.Local != "svg" {
+			return 0, 0, false
+		}
+
+		var widthAttr, heightAttr, viewBox string
+		for _, attr := range root.Attr {
+			switch attr.Name.Local {
+			case "width":
+				widthAttr = attr.Value
+			case "height":
+				heightAttr = attr.Value
+			case "viewBox":
+				viewBox = attr.Value
+			}
+		}
+		w, wErr := strconv.ParseFloat(strings.TrimSuffix(widthAttr, "px"), 64)
+		h, hErr := strconv.ParseFloat(strings.TrimSuffix(heightAttr, "px"), 64)
+		if wErr == nil && hErr == nil {
+			return int(math.Round(w)), int(math.Round(h)), true
+		}
+		if fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " ")); len(fields) == 4 {
+			w, wErr := strconv.ParseFloat(fields[2], 64)
+			h, hErr := strconv.ParseFloat(fields[3], 64)
+			if wErr == nil && hErr == nil {
+				return int(math.Round(w)), int(math.Round(h)), true
+			}
+		}
+		return 0, 0, false
+	}
+}
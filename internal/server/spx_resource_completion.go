@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// jsonCursorContext is the context of a cursor position in a JSON document,
+// which may be incomplete while being edited.
+type jsonCursorContext struct {
+	// path is the path of the value at the cursor, like [walkJSON]. For a
+	// key, it is the path of the object containing the key.
+	path []string
+
+	// inKey reports whether the cursor is at a key of an object, whose other
+	// keys are in keys.
+	inKey bool
+	keys  map[string]struct{}
+
+	// start and end are the byte offsets of the string or literal at the
+	// cursor, which completion replaces. They are equal if there is none.
+	start, end int
+
+	// hasColon reports whether the key at the cursor is followed by a colon.
+	hasColon bool
+}
+
+// jsonScanFrame is an object or array open at some point of a JSON document.
+type jsonScanFrame struct {
+	path      []string
+	isObject  bool
+	index     int                 // index of the current element of an array
+	key       string              // current key of an object
+	expectKey bool                // whether the next string of an object is a key
+	hasValue  bool                // whether the current member or element has a value
+	keys      map[string]struct{} // keys of an object
+}
+
+// valuePath returns the path of the current member or element of the frame.
+func (f *jsonScanFrame) valuePath() []string {
+	if f.isObject {
+		return append(slices.Clip(f.path), f.key)
+	}
+	return append(slices.Clip(f.path), strconv.Itoa(f.index))
+}
+
+// scanJSONCursorContext returns the context of the cursor at the given byte
+// offset in the JSON document in data. Unlike [walkJSON], it tolerates
+// syntax errors, e.g., unterminated strings and missing commas. It returns
+// false if the cursor is neither at a key nor at a value, e.g., right after a
+// value.
+func scanJSONCursorContext(data []byte, offset int) (jsonCursorContext, bool) {
+	var (
+		stack    []*jsonScanFrame
+		cc       jsonCursorContext
+		ok       bool
+		captured bool
+		keyFrame *jsonScanFrame // object containing the key at the cursor
+	)
+	capture := func(f *jsonScanFrame, start, end int) {
+		captured = true
+		switch {
+		case f == nil, start == end && f.hasValue:
+			return
+		case f.isObject && f.expectKey:
+			cc = jsonCursorContext{path: f.path, inKey: true}
+			keyFrame = f
+		default:
+			cc = jsonCursorContext{path: f.valuePath()}
+		}
+		cc.start, cc.end = start, end
+		ok = true
+	}
+
+	// Once the cursor is captured, scanning goes on to the end of the object
+	// containing the key at the cursor, if any, to collect its other keys.
+	for i := 0; i < len(data) && (!captured || keyFrame != nil); {
+		c := data[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+		var f *jsonScanFrame
+		if len(stack) > 0 {
+			f = stack[len(stack)-1]
+		}
+		if !captured && offset <= i {
+			capture(f, offset, offset)
+		}
+		switch c {
+		case '{', '[':
+			frame := &jsonScanFrame{isObject: c == '{', expectKey: true, keys: make(map[string]struct{})}
+			if f != nil {
+				frame.path = f.valuePath()
+				f.hasValue = true
+			}
+			stack = append(stack, frame)
+			i++
+		case '}', ']':
+			if f != nil {
+				stack = stack[:len(stack)-1]
+				if f == keyFrame {
+					cc.keys = f.keys
+					keyFrame = nil
+				}
+			}
+			i++
+		case ',':
+			if f != nil {
+				f.index++
+				f.key, f.expectKey, f.hasValue = "", true, false
+			}
+			i++
+		case ':':
+			if f != nil {
+				f.expectKey = false
+			}
+			i++
+		case '"':
+			start := i
+			terminated := false
+			for i++; i < len(data) && data[i] != '\n'; i++ {
+				if data[i] == '\\' {
+					i++
+				} else if data[i] == '"' {
+					i++
+					terminated = true
+					break
+				}
+			}
+			end := min(i, len(data))
+			atCursor := !captured && start < offset && (offset < end || !terminated && offset == end)
+			if atCursor {
+				if terminated {
+					capture(f, start, end)
+				} else {
+					// The rest of the line may be anything, e.g., a closing
+					// brace, which is kept.
+					capture(f, start, offset)
+				}
+			}
+			if f == nil || !f.isObject || !f.expectKey {
+				if f != nil {
+					f.hasValue = true
+				}
+				continue
+			}
+			if atCursor {
+				rest := bytes.TrimLeft(data[end:], " \t")
+				cc.hasColon = len(rest) > 0 && rest[0] == ':'
+			} else if key, err := strconv.Unquote(string(data[start:end])); err == nil {
+				f.keys[key] = struct{}{}
+				f.key = key
+			}
+			f.expectKey = false
+		default:
+			start := i
+			for i < len(data) && !bytes.ContainsRune([]byte(" \t\r\n,:{}[]\""), rune(data[i])) {
+				i++
+			}
+			if !captured && start <= offset && offset <= i {
+				capture(f, start, i)
+			}
+			if f != nil {
+				f.hasValue = true
+			}
+		}
+	}
+	if !captured {
+		var f *jsonScanFrame
+		if len(stack) > 0 {
+			f = stack[len(stack)-1]
+		}
+		capture(f, offset, offset)
+	}
+	if keyFrame != nil {
+		cc.keys = keyFrame.keys // The object is not closed.
+	}
+	return cc, ok
+}
+
+// completeSpxResourceMetadata returns the completion items at the given
+// position of an spx resource metadata file, i.e., the known keys of objects
+// and the known values of strings and booleans. It returns nil if the file is
+// not a metadata file.
+func (s *Server) completeSpxResourceMetadata(ctx context.Context, params *CompletionParams) ([]CompletionItem, error) {
+	file, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file path from document URI %q: %w", params.TextDocument.URI, err)
+	}
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile: %w", err)
+	}
+	schema := spxMetadataSchemaOf(result.spxResourceRootDir, file)
+	if schema == nil {
+		return nil, nil
+	}
+	data, err := vfs.ReadFile(result.proj, file)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, ok := scanJSONCursorContext(data, result.posEncoding.Offset(data, params.Position))
+	if !ok {
+		return nil, nil
+	}
+	schema = schema.at(cc.path)
+	if schema == nil {
+		return nil, nil
+	}
+	rng := Range{
+		Start: result.posEncoding.Position(data, cc.start),
+		End:   result.posEncoding.Position(data, cc.end),
+	}
+	newItem := func(label string, kind CompletionItemKind, doc string, newText string) CompletionItem {
+		item := CompletionItem{
+			Label:            label,
+			Kind:             kind,
+			InsertTextFormat: util.ToPtr(SnippetTextFormat),
+			TextEdit:         &Or_CompletionItem_textEdit{Value: TextEdit{Range: rng, NewText: newText}},
+		}
+		if doc != "" {
+			item.Documentation = &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: doc}}
+		}
+		return item
+	}
+
+	var items []CompletionItem
+	if cc.inKey {
+		for _, key := range slices.Sorted(maps.Keys(schema.properties)) {
+			if _, ok := cc.keys[key]; ok {
+				continue
+			}
+			prop := schema.properties[key]
+			newText := strconv.Quote(key)
+			if !cc.hasColon {
+				newText += ": " + jsonValueSnippet(prop)
+			}
+			item := newItem(key, PropertyCompletion, prop.doc, newText)
+			item.Detail = prop.kind
+			items = append(items, item)
+		}
+		return s.adaptCompletionItems(items), nil
+	}
+
+	switch {
+	case len(schema.enum) > 0:
+		for _, value := range schema.enum {
+			items = append(items, newItem(strconv.Quote(value), EnumMemberCompletion, "", strconv.Quote(value)))
+		}
+	case schema.kind == "boolean":
+		for _, value := range []string{"true", "false"} {
+			items = append(items, newItem(value, ValueCompletion, "", value))
+		}
+	}
+	return s.adaptCompletionItems(items), nil
+}
+
+// jsonValueSnippet returns the snippet of an empty value of the given schema,
+// with the cursor inside it.
+func jsonValueSnippet(schema *spxMetadataSchema) string {
+	switch schema.kind {
+	case "string":
+		return `"$1"`
+	case "object":
+		return "{$1}"
+	case "array":
+		return "[$1]"
+	}
+	return "$1"
+}
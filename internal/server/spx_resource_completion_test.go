@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanJSONCursorContext(t *testing.T) {
+	scan := func(content string) (jsonCursorContext, bool) {
+		offset := strings.Index(content, "|")
+		return scanJSONCursorContext([]byte(content[:offset]+content[offset+1:]), offset)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    jsonCursorContext
+	}{
+		{"EmptyObject", `{|}`, jsonCursorContext{inKey: true, keys: map[string]struct{}{}, start: 1, end: 1}},
+		{"NewKey", `{"x": 1, |, "y": 2}`, jsonCursorContext{inKey: true, keys: map[string]struct{}{"x": {}, "y": {}}, start: 9, end: 9}},
+		{"UnterminatedKey", `{"x": 1, "ro|}`, jsonCursorContext{inKey: true, keys: map[string]struct{}{"x": {}}, start: 9, end: 12}},
+		{"ExistingKey", `{"ro|tationStyle": "none"}`, jsonCursorContext{inKey: true, keys: map[string]struct{}{}, start: 1, end: 16, hasColon: true}},
+		{"NestedKey", `{"map": {"width": 1, |}}`, jsonCursorContext{path: []string{"map"}, inKey: true, keys: map[string]struct{}{"width": {}}, start: 21, end: 21}},
+		{"UnclosedObject", "{\"map\": {\n  |\n", jsonCursorContext{path: []string{"map"}, inKey: true, keys: map[string]struct{}{}, start: 12, end: 12}},
+		{"Value", `{"rotationStyle": |}`, jsonCursorContext{path: []string{"rotationStyle"}, start: 18, end: 18}},
+		{"StringValue", `{"rotationStyle": "no|ne"}`, jsonCursorContext{path: []string{"rotationStyle"}, start: 18, end: 24}},
+		{"LiteralValue", `{"visible": tr|}`, jsonCursorContext{path: []string{"visible"}, start: 12, end: 14}},
+		{"ArrayElement", `{"zorder": ["A", {"type": "|"}]}`, jsonCursorContext{path: []string{"zorder", "1", "type"}, start: 26, end: 28}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cc, ok := scan(tt.content)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, cc)
+		})
+	}
+
+	t.Run("AfterValue", func(t *testing.T) {
+		_, ok := scan(`{"x": 1 |}`)
+		assert.False(t, ok)
+	})
+
+	t.Run("TopLevel", func(t *testing.T) {
+		_, ok := scan(` |{}`)
+		assert.False(t, ok)
+	})
+}
+
+func TestServerCompleteSpxResourceMetadata(t *testing.T) {
+	complete := func(t *testing.T, file, content string) []CompletionItem {
+		offset := strings.Index(content, "|")
+		data := []byte(content[:offset] + content[offset+1:])
+		m := map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"MySprite.spx":      []byte(`onStart => {}`),
+			"assets/index.json": []byte(`{}`),
+			file:                data,
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: s.toDocumentURI(file)},
+				Position:     position.UTF16.Position(data, offset),
+			},
+		})
+		require.NoError(t, err)
+		return items
+	}
+	labels := func(items []CompletionItem) []string {
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	newText := func(t *testing.T, items []CompletionItem, label string) string {
+		for _, item := range items {
+			if item.Label == label {
+				return item.TextEdit.Value.(TextEdit).NewText
+			}
+		}
+		t.Fatalf("no completion item %q", label)
+		return ""
+	}
+
+	t.Run("SpriteKeys", func(t *testing.T) {
+		items := complete(t, "assets/sprites/MySprite/index.json", `{"costumes": [], "x": 0, |}`)
+		assert.Contains(t, labels(items), "rotationStyle")
+		assert.Contains(t, labels(items), "fAnimations")
+		assert.NotContains(t, labels(items), "costumes")
+		assert.NotContains(t, labels(items), "x")
+		assert.Equal(t, `"rotationStyle": "$1"`, newText(t, items, "rotationStyle"))
+		assert.Equal(t, `"fAnimations": {$1}`, newText(t, items, "fAnimations"))
+		assert.Equal(t, `"visible": $1`, newText(t, items, "visible"))
+	})
+
+	t.Run("AnimationKeys", func(t *testing.T) {
+		items := complete(t, "assets/sprites/MySprite/index.json", `{"fAnimations": {"walk": {"frameFrom": "walk1", "fr|": 1}}}`)
+		assert.Equal(t, []string{"duration", "frameFps", "frameTo", "isKeepOnStop", "isLoop", "onPlay", "onStart"}, labels(items))
+		assert.Equal(t, `"frameTo"`, newText(t, items, "frameTo"))
+		assert.Equal(t, Range{
+			Start: Position{Line: 0, Character: 48},
+			End:   Position{Line: 0, Character: 52},
+		}, items[0].TextEdit.Value.(TextEdit).Range)
+	})
+
+	t.Run("WidgetTypes", func(t *testing.T) {
+		items := complete(t, "assets/index.json", `{"zorder": ["MySprite", {"name": "score", "type": "m|"}]}`)
+		assert.Equal(t, []string{`"monitor"`, `"measure"`, `"sprite"`, `"sprites"`}, labels(items))
+		assert.Equal(t, EnumMemberCompletion, items[0].Kind)
+	})
+
+	t.Run("IndexKeys", func(t *testing.T) {
+		items := complete(t, "assets/index.json", "{\n  \"map\": {\"mode\": \"fill\"},\n  |\n}")
+		assert.Equal(t, []string{"backdropIndex", "backdrops", "camera", "run", "sceneIndex", "scenes", "zorder"}, labels(items))
+	})
+
+	t.Run("Boolean", func(t *testing.T) {
+		items := complete(t, "assets/sounds/MySound/index.json", `{"path": |}`)
+		assert.Empty(t, items)
+		items = complete(t, "assets/sprites/MySprite/index.json", `{"visible": |}`)
+		assert.Equal(t, []string{"true", "false"}, labels(items))
+	})
+
+	t.Run("NotMetadata", func(t *testing.T) {
+		items := complete(t, "assets/sprites/MySprite/costumes.json", `{|}`)
+		assert.Empty(t, items)
+	})
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"path"
+	"strings"
+)
+
+// spxMetadataSchema describes a JSON value of the metadata files of spx
+// resources, for completion and validation of the files edited by hand. It is
+// a small subset of JSON Schema, covering what spx reads from the files.
+type spxMetadataSchema struct {
+	// kind is the JSON type of the value, i.e., "object", "array", "string",
+	// "number", "integer" or "boolean", or "" for any type.
+	kind string
+
+	// doc is the documentation of the value.
+	doc string
+
+	// properties are the schemas of the known members of an object.
+	properties map[string]*spxMetadataSchema
+
+	// additionalProperties is the schema of the members of an object not in
+	// properties, e.g., the animations of a sprite, keyed by name.
+	additionalProperties *spxMetadataSchema
+
+	// items is the schema of the elements of an array.
+	items *spxMetadataSchema
+
+	// enum are the allowed values of a string, if limited. Unless the value
+	// must be one of them, the first one is the default that spx falls back
+	// to for unknown values.
+	enum []string
+}
+
+// at returns the schema of the value at the given path relative to the value
+// of schema, with object members and array elements as path elements like
+// [walkJSON]. It returns nil if the value is unknown.
+func (schema *spxMetadataSchema) at(path []string) *spxMetadataSchema {
+	for _, elem := range path {
+		if schema == nil {
+			return nil
+		}
+		switch {
+		case schema.items != nil:
+			schema = schema.items
+		case schema.properties[elem] != nil:
+			schema = schema.properties[elem]
+		default:
+			schema = schema.additionalProperties
+		}
+	}
+	return schema
+}
+
+// spxMetadataSchema helpers, each describing a value of a kind.
+func spxMetadataString(doc string, enum ...string) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "string", doc: doc, enum: enum}
+}
+
+func spxMetadataNumber(doc string) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "number", doc: doc}
+}
+
+func spxMetadataInteger(doc string) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "integer", doc: doc}
+}
+
+func spxMetadataBoolean(doc string) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "boolean", doc: doc}
+}
+
+func spxMetadataObject(doc string, properties map[string]*spxMetadataSchema) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "object", doc: doc, properties: properties}
+}
+
+func spxMetadataArray(doc string, items *spxMetadataSchema) *spxMetadataSchema {
+	return &spxMetadataSchema{kind: "array", doc: doc, items: items}
+}
+
+// spxCostumeMetadataSchema is the schema of a costume or backdrop.
+var spxCostumeMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"name":             spxMetadataString("The name of the costume."),
+	"path":             spxMetadataString("The path of the image, relative to the metadata file."),
+	"x":                spxMetadataNumber("The x coordinate of the rotation center in the image."),
+	"y":                spxMetadataNumber("The y coordinate of the rotation center in the image."),
+	"faceRight":        spxMetadataNumber("The heading the image faces, in degrees."),
+	"bitmapResolution": spxMetadataInteger("The number of image pixels per stage pixel."),
+})
+
+// spxCostumeSetMetadataSchema is the schema of a costume group cut from a
+// single image, with the given members in addition to the common ones.
+func spxCostumeSetMetadataSchema(doc string, properties map[string]*spxMetadataSchema) *spxMetadataSchema {
+	properties["path"] = spxMetadataString("The path of the image, relative to the metadata file.")
+	properties["faceRight"] = spxMetadataNumber("The heading the image faces, in degrees.")
+	properties["bitmapResolution"] = spxMetadataInteger("The number of image pixels per stage pixel.")
+	return spxMetadataObject(doc, properties)
+}
+
+// spxCostumeSetItemsMetadataSchema is the schema of the named runs of
+// costumes of a costume group.
+var spxCostumeSetItemsMetadataSchema = spxMetadataArray("The named runs of costumes.", spxMetadataObject("", map[string]*spxMetadataSchema{
+	"namePrefix": spxMetadataString("The name prefix of the costumes, followed by their indexes."),
+	"n":          spxMetadataInteger("The number of costumes."),
+}))
+
+// spxZorderEntryMetadataSchema is the schema of an entry of the zorder of the
+// resource root index.json, which is either a sprite name or a shape, e.g.,
+// a widget.
+var spxZorderEntryMetadataSchema = func() *spxMetadataSchema {
+	var types []string
+	for _, typ := range spxWidgetTypes {
+		types = append(types, typ.Name)
+	}
+	types = append(types, spxNonWidgetShapeTypes...)
+	return &spxMetadataSchema{
+		doc: "A sprite name, or a shape drawn on the stage, e.g., a widget.",
+		properties: map[string]*spxMetadataSchema{
+			"type":       spxMetadataString("The type of the shape.", types...),
+			"name":       spxMetadataString("The name of the widget, as passed to getWidget."),
+			"target":     spxMetadataString("The name of the sprite whose variable the monitor displays, or empty for a variable of the game."),
+			"val":        spxMetadataString("The value the monitor displays, e.g., `getVar:score`."),
+			"label":      spxMetadataString("The label of the monitor."),
+			"color":      spxMetadataNumber("The color of the monitor, as an RGB integer."),
+			"mode":       spxMetadataInteger("The display mode of the monitor: 1 for normal, 2 for large and 3 for slider."),
+			"x":          spxMetadataNumber("The x coordinate of the shape."),
+			"y":          spxMetadataNumber("The y coordinate of the shape."),
+			"size":       spxMetadataNumber("The size of the shape."),
+			"visible":    spxMetadataBoolean("Whether the shape is shown."),
+			"sliderMin":  spxMetadataNumber("The minimum value of the slider of the monitor."),
+			"sliderMax":  spxMetadataNumber("The maximum value of the slider of the monitor."),
+			"isDiscrete": spxMetadataBoolean("Whether the slider of the monitor only takes integer values."),
+		},
+	}
+}()
+
+// spxResourceIndexMetadataSchema is the schema of the index.json of the
+// resource root.
+var spxResourceIndexMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"backdrops":     spxMetadataArray("The backdrops of the stage.", spxCostumeMetadataSchema),
+	"backdropIndex": spxMetadataInteger("The index of the backdrop shown when the game starts."),
+	"scenes":        spxMetadataArray("The scenes of the stage, superseded by backdrops.", spxCostumeMetadataSchema),
+	"sceneIndex":    spxMetadataInteger("The index of the scene shown when the game starts, superseded by backdropIndex."),
+	"zorder":        spxMetadataArray("The sprites and shapes on the stage, from back to front.", spxZorderEntryMetadataSchema),
+	"map": spxMetadataObject("The map the stage shows a part of.", map[string]*spxMetadataSchema{
+		"width":  spxMetadataInteger("The width of the map."),
+		"height": spxMetadataInteger("The height of the map."),
+		"mode":   spxMetadataString("How backdrops fill the map.", "fill", "repeat", "fillRatio", "fillCut"),
+	}),
+	"camera": spxMetadataObject("The camera of the stage.", map[string]*spxMetadataSchema{
+		"on": spxMetadataString("The name of the sprite the camera follows."),
+	}),
+	"run": spxMetadataObject("The options to run the game with.", map[string]*spxMetadataSchema{
+		"title":            spxMetadataString("The title of the game window."),
+		"width":            spxMetadataInteger("The width of the game window."),
+		"height":           spxMetadataInteger("The height of the game window."),
+		"keyDuration":      spxMetadataInteger("The duration of key presses, in milliseconds."),
+		"screenshotKey":    spxMetadataString("The key to capture screenshots with."),
+		"fullScreen":       spxMetadataBoolean("Whether the game runs in full screen."),
+		"pauseOnUnfocused": spxMetadataBoolean("Whether the game pauses while the window is unfocused."),
+	}),
+})
+
+// spxAnimationActionMetadataSchema is the schema of an action of an
+// animation.
+var spxAnimationActionMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"play": spxMetadataString("The name of the sound played."),
+})
+
+// spxSpriteMetadataSchema is the schema of the index.json of a sprite.
+var spxSpriteMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"heading":       spxMetadataNumber("The heading of the sprite when the game starts, in degrees."),
+	"x":             spxMetadataNumber("The x coordinate of the sprite when the game starts."),
+	"y":             spxMetadataNumber("The y coordinate of the sprite when the game starts."),
+	"size":          spxMetadataNumber("The size of the sprite, relative to its costumes."),
+	"rotationStyle": spxMetadataString("How the sprite rotates to its heading.", "normal", "left-right", "none"),
+	"costumes":      spxMetadataArray("The costumes of the sprite.", spxCostumeMetadataSchema),
+	"costumeSet": spxCostumeSetMetadataSchema("The costumes of the sprite cut from a single image, used if there are no costumes.", map[string]*spxMetadataSchema{
+		"nx":    spxMetadataInteger("The number of costumes in the image."),
+		"items": spxCostumeSetItemsMetadataSchema,
+	}),
+	"costumeMPSet": spxCostumeSetMetadataSchema("The costumes of the sprite cut from rows of a single image, used if there are no costumes.", map[string]*spxMetadataSchema{
+		"parts": spxMetadataArray("The rows of costumes.", spxMetadataObject("", map[string]*spxMetadataSchema{
+			"nx":    spxMetadataInteger("The number of costumes in the row."),
+			"items": spxCostumeSetItemsMetadataSchema,
+		})),
+	}),
+	"costumeIndex": spxMetadataInteger("The index of the costume the sprite starts with."),
+	"fAnimations": {
+		kind: "object",
+		doc:  "The frame animations of the sprite, keyed by name.",
+		additionalProperties: spxMetadataObject("", map[string]*spxMetadataSchema{
+			"frameFrom":    spxMetadataString("The name of the first costume of the animation."),
+			"frameTo":      spxMetadataString("The name of the last costume of the animation."),
+			"frameFps":     spxMetadataInteger("The number of frames per second."),
+			"duration":     spxMetadataNumber("The duration of the animation, in seconds."),
+			"isLoop":       spxMetadataBoolean("Whether the animation loops."),
+			"isKeepOnStop": spxMetadataBoolean("Whether the sprite keeps the last frame once the animation stops."),
+			"onStart":      spxAnimationActionMetadataSchema,
+			"onPlay":       spxAnimationActionMetadataSchema,
+		}),
+	},
+	"defaultAnimation": spxMetadataString("The name of the animation played while the sprite is idle."),
+	"animBindings": {
+		kind:                 "object",
+		doc:                  "The animations played for the actions of the sprite, e.g., `step`, keyed by action.",
+		additionalProperties: spxMetadataString("The name of the animation."),
+	},
+	"visible":     spxMetadataBoolean("Whether the sprite is shown when the game starts."),
+	"isDraggable": spxMetadataBoolean("Whether the sprite can be dragged."),
+	"pivot": spxMetadataObject("The offset of the pivot of the sprite.", map[string]*spxMetadataSchema{
+		"x": spxMetadataNumber("The x offset."),
+		"y": spxMetadataNumber("The y offset."),
+	}),
+})
+
+// spxSoundMetadataSchema is the schema of the index.json of a sound.
+var spxSoundMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"path":        spxMetadataString("The path of the audio file, relative to the metadata file."),
+	"rate":        spxMetadataInteger("The sample rate of the sound."),
+	"sampleCount": spxMetadataInteger("The number of samples of the sound."),
+})
+
+// spxFontMetadataSchema is the schema of the index.json of a font.
+var spxFontMetadataSchema = spxMetadataObject("", map[string]*spxMetadataSchema{
+	"path": spxMetadataString("The path of the font file, relative to the metadata file."),
+})
+
+// spxMetadataSchemaOf returns the schema of the metadata file with the given
+// path in the resource root directory rootDir. It returns nil if the file is
+// not a metadata file.
+func spxMetadataSchemaOf(rootDir, file string) *spxMetadataSchema {
+	rel, ok := strings.CutPrefix(file, rootDir+"/")
+	if !ok || path.Base(rel) != "index.json" {
+		return nil
+	}
+	if rel == "index.json" {
+		return spxResourceIndexMetadataSchema
+	}
+	parts := strings.Split(rel, "/")
+	if len(parts) != 3 {
+		return nil
+	}
+	switch parts[0] {
+	case "sprites":
+		return spxSpriteMetadataSchema
+	case "sounds":
+		return spxSoundMetadataSchema
+	case "fonts":
+		return spxFontMetadataSchema
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpxResourceURI(t *testing.T) {
+	for _, tt := range []struct {
+		uri  SpxResourceURI
+		want SpxResourceID
+	}{
+		{"spx://resources/backdrops/backdrop1", SpxBackdropResourceID{BackdropName: "backdrop1"}},
+		{"spx://resources/sounds/sound1", SpxSoundResourceID{SoundName: "sound1"}},
+		{"spx://resources/sprites/MySprite", SpxSpriteResourceID{SpriteName: "MySprite"}},
+		{"spx://resources/sprites/MySprite/costumes/costume1", SpxSpriteCostumeResourceID{SpriteName: "MySprite", CostumeName: "costume1"}},
+		{"spx://resources/sprites/MySprite/animations/walk", SpxSpriteAnimationResourceID{SpriteName: "MySprite", AnimationName: "walk"}},
+		{"spx://resources/widgets/widget1", SpxWidgetResourceID{WidgetName: "widget1"}},
+		{"spx://resources/scenes/scene1", SpxSceneResourceID{SceneName: "scene1"}},
+		{"spx://resources/fonts/font1", SpxFontResourceID{FontName: "font1"}},
+		{"spx://resources/stage", SpxStageResourceID{}},
+	} {
+		t.Run(string(tt.uri), func(t *testing.T) {
+			got, err := ParseSpxResourceURI(tt.uri)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.uri, got.URI())
+		})
+	}
+
+	for _, uri := range []SpxResourceURI{
+		"spx://resources/",
+		"spx://resources/sounds",
+		"spx://resources/stage/main",
+		"spx://resources/unknown/name",
+		"file:///assets/index.json",
+	} {
+		t.Run("Invalid/"+string(uri), func(t *testing.T) {
+			_, err := ParseSpxResourceURI(uri)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewSpxResourceSet(t *testing.T) {
+	m := map[string][]byte{
+		"assets/index.json":               []byte(`{"scenes":[{"name":"scene1","path":"scene1.png"}],"map":{"width":480,"height":360,"mode":"fillRatio"},"run":{"width":960,"height":720}}`),
+		"assets/fonts/font1/index.json":   []byte(`{"path":"font1.ttf"}`),
+		"assets/sounds/sound1/index.json": []byte(`{}`),
+	}
+	proj := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)).getProj()
+	rootFS := vfs.Sub(proj, "assets")
+
+	set, err := NewSpxResourceSet(rootFS)
+	require.NoError(t, err)
+	require.NotNil(t, set.Scene("scene1"))
+	assert.Equal(t, "scene1.png", set.Scene("scene1").Path)
+	require.NotNil(t, set.Font("font1"))
+	assert.Equal(t, "font1.ttf", set.Font("font1").Path)
+	require.NotNil(t, set.Stage())
+	assert.Equal(t, 480, set.Stage().Map.Width)
+	assert.Equal(t, "fillRatio", set.Stage().Map.Mode)
+	assert.Equal(t, 720, set.Stage().Run.Height)
+	assert.True(t, set.Contains(SpxSceneResourceID{SceneName: "scene1"}))
+	assert.True(t, set.Contains(SpxFontResourceID{FontName: "font1"}))
+	assert.True(t, set.Contains(SpxStageResourceID{}))
+	assert.False(t, set.Contains(SpxFontResourceID{FontName: "font2"}))
+
+	t.Run("UpdateFont", func(t *testing.T) {
+		updated := set.clone()
+		proj.PutFile("assets/fonts/font2/index.json", &vfs.MapFileImpl{Content: []byte(`{"path":"font2.ttf"}`)})
+		require.NoError(t, updated.UpdateSpxResource(rootFS, "fonts/font2/index.json"))
+		assert.NotNil(t, updated.Font("font2"))
+		assert.Nil(t, set.Font("font2"))
+
+		proj.DeleteFile("assets/fonts/font1/index.json")
+		require.NoError(t, updated.UpdateSpxResource(rootFS, "fonts/font1"))
+		assert.Nil(t, updated.Font("font1"))
+		assert.NotNil(t, set.Font("font1"))
+	})
+}
+
+func TestSpxSpriteResourceCostumeGroups(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		metadata string
+		want     []string
+	}{
+		{"CostumeSet", `{"costumeSet":{"path":"walk.png","nx":4,"items":[{"namePrefix":"walk","n":3}]}}`, []string{"walk0", "walk1", "walk2"}},
+		{"CostumeSetWithoutItems", `{"costumeSet":{"path":"walk.png","nx":2}}`, []string{"0", "1"}},
+		{"CostumeSetOfOne", `{"costumeSet":{"path":"walk.png","nx":1,"items":[{"namePrefix":"walk","n":1}]}}`, []string{"0"}},
+		{"CostumeMPSet", `{"costumeMPSet":{"path":"walk.png","parts":[{"nx":2,"items":[{"namePrefix":"left","n":2}]},{"nx":2,"items":[{"namePrefix":"right","n":2}]}]}}`, []string{"left0", "left1", "right0", "right1"}},
+		{"CostumesTakePrecedence", `{"costumes":[{"name":"costume1"}],"costumeSet":{"path":"walk.png","nx":2}}`, []string{"costume1"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := map[string][]byte{
+				"assets/index.json":                  []byte(`{}`),
+				"assets/sprites/MySprite/index.json": []byte(tt.metadata),
+			}
+			proj := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)).getProj()
+
+			set, err := NewSpxResourceSet(vfs.Sub(proj, "assets"))
+			require.NoError(t, err)
+			sprite := set.Sprite("MySprite")
+			require.NotNil(t, sprite)
+			var names []string
+			for _, costume := range sprite.Costumes {
+				names = append(names, costume.Name)
+				assert.NotNil(t, sprite.Costume(costume.Name))
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+
+	t.Run("SetMetadata", func(t *testing.T) {
+		m := map[string][]byte{
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumeSet":{"path":"walk.png","faceRight":90,"bitmapResolution":2,"nx":2}}`),
+		}
+		proj := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)).getProj()
+
+		set, err := NewSpxResourceSet(vfs.Sub(proj, "assets"))
+		require.NoError(t, err)
+		costume := set.Sprite("MySprite").Costume("1")
+		require.NotNil(t, costume)
+		assert.Equal(t, SpxSpriteCostumeResource{
+			ID:               SpxSpriteCostumeResourceID{SpriteName: "MySprite", CostumeName: "1"},
+			Name:             "1",
+			Path:             "walk.png",
+			BitmapResolution: 2,
+			FaceRight:        90,
+		}, *costume)
+	})
+}
+
+func TestSpxSpriteAnimationResourceCostumes(t *testing.T) {
+	m := map[string][]byte{
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"walk1"},{"name":"walk2"},{"name":"walk3"}],"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk3"},"still":{"frameFrom":"walk2","frameTo":"walk2"},"reversed":{"frameFrom":"walk3","frameTo":"walk1"},"broken":{"frameFrom":"walk1","frameTo":"walk4"},"empty":{}}}`),
+	}
+	proj := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)).getProj()
+	set, err := NewSpxResourceSet(vfs.Sub(proj, "assets"))
+	require.NoError(t, err)
+	sprite := set.Sprite("MySprite")
+	require.NotNil(t, sprite)
+
+	costumeNames := func(animName string) []string {
+		anim := sprite.Animation(animName)
+		require.NotNil(t, anim)
+		var names []string
+		for _, costume := range anim.Costumes(sprite) {
+			names = append(names, costume.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"walk1", "walk2", "walk3"}, costumeNames("walk"))
+	assert.Equal(t, []string{"walk2"}, costumeNames("still"))
+	assert.Nil(t, costumeNames("reversed"))
+	assert.Nil(t, costumeNames("broken"))
+	assert.Nil(t, costumeNames("empty"))
+
+	assert.False(t, sprite.Animation("walk").HasMissingFrames())
+	assert.False(t, sprite.Animation("reversed").HasMissingFrames())
+	assert.True(t, sprite.Animation("broken").HasMissingFrames())
+	assert.False(t, sprite.Animation("empty").HasMissingFrames())
+}
+
+func TestServerSpxAnimationWithMissingFrames(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.animate "walk"
+MySprite.animate "jump"
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(``),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"walk1"},{"name":"walk2"}],"costumeIndex":0,"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk2"},"jump":{"frameFrom":"jump1","frameTo":"walk2"}}}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	result, err := s.compile(context.Background())
+	require.NoError(t, err)
+	diags := result.diagnostics["file:///main.spx"]
+	require.Len(t, diags, 1)
+	assert.Equal(t, SeverityError, diags[0].Severity)
+	assert.Equal(t, `animation "jump" of sprite "MySprite" refers to costumes that do not exist`, diags[0].Message)
+	assert.Equal(t, Range{
+		Start: Position{Line: 5, Character: 17},
+		End:   Position{Line: 5, Character: 23},
+	}, diags[0].Range)
+}
+
+func TestServerSpxSceneResourceRef(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+startBackdrop "scene1"
+startBackdrop "scene2"
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{"scenes":[{"name":"scene1"}]}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+	result, err := s.compile(context.Background())
+	require.NoError(t, err)
+	var ids []SpxResourceID
+	for _, ref := range result.spxResourceRefs {
+		ids = append(ids, ref.ID)
+	}
+	assert.ElementsMatch(t, []SpxResourceID{
+		SpxStageResourceID{},
+		SpxSceneResourceID{SceneName: "scene1"},
+		SpxBackdropResourceID{BackdropName: "scene2"},
+	}, ids)
+
+	diags := result.diagnostics["file:///main.spx"]
+	require.Len(t, diags, 1)
+	assert.Equal(t, `backdrop "scene2" does not exist`, diags[0].Message)
+
+	def, err := s.spxResourceDefinitionLocation(result, SpxSceneResourceID{SceneName: "scene1"})
+	require.NoError(t, err)
+	assert.Equal(t, Location{
+		URI: "file:///assets/index.json",
+		Range: Range{
+			Start: Position{Line: 0, Character: 20},
+			End:   Position{Line: 0, Character: 26},
+		},
+	}, def)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// spxResourceSetTracker keeps the latest spx resource set of the workspace and
+// tracks the files changed since it was built, so that the next set can be
+// derived from it by updating only the changed resources instead of loading
+// all of them again.
+//
+// The tracked set is only used for the latest snapshot of the workspace, as
+// the changes are tracked relative to it. Sets for other projects, e.g.,
+// previous snapshots still in use by reads, are always built from scratch.
+type spxResourceSetTracker struct {
+	mu      sync.Mutex
+	latest  *vfs.MapFS          // latest snapshot of the workspace
+	rootDir string              // resource root of set
+	set     *SpxResourceSet     // nil if a full rebuild is required
+	dirty   map[string]struct{} // paths changed since set was built
+}
+
+// setLatest makes snapshot the latest snapshot of the workspace, with the
+// files or directories at the given paths relative to the workspace root
+// changed since the previous one.
+func (t *spxResourceSetTracker) setLatest(snapshot *vfs.MapFS, paths ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.setLatestLocked(snapshot, paths...)
+}
+
+func (t *spxResourceSetTracker) setLatestLocked(snapshot *vfs.MapFS, paths ...string) {
+	t.latest = snapshot
+	if t.dirty == nil {
+		t.dirty = make(map[string]struct{})
+	}
+	for _, path := range paths {
+		t.dirty[path] = struct{}{}
+	}
+}
+
+// updateFiles returns a snapshot of proj updated with files like
+// [vfs.MapFS.UpdateFiles], which becomes the latest snapshot. It returns proj
+// itself if no files changed.
+func (t *spxResourceSetTracker) updateFiles(proj *vfs.MapFS, files map[string]vfs.MapFile) *vfs.MapFS {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changed []string
+	proj.RangeFiles(func(path string) bool {
+		if _, ok := files[path]; !ok {
+			changed = append(changed, path)
+		}
+		return true
+	})
+	for path, file := range files {
+		if oldFile, ok := proj.File(path); !ok || !oldFile.ModTime.Equal(file.ModTime) {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return proj
+	}
+
+	snapshot := proj.Snapshot()
+	snapshot.UpdateFiles(files)
+	t.setLatestLocked(snapshot, changed...)
+	return snapshot
+}
+
+// get returns the spx resource set at the given resource root of proj. The
+// returned set must not be modified.
+func (t *spxResourceSetTracker) get(proj *vfs.MapFS, rootDir string) (*SpxResourceSet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rootFS := vfs.Sub(proj, rootDir)
+	if proj != t.latest {
+		return NewSpxResourceSet(rootFS)
+	}
+	set, err := t.update(rootFS, rootDir)
+	if err != nil {
+		t.set = nil
+		return nil, err
+	}
+	t.rootDir = rootDir
+	t.set = set
+	clear(t.dirty)
+	return set, nil
+}
+
+// update returns the tracked set updated for the dirty paths, or a newly built
+// set if there is no tracked set for rootDir.
+func (t *spxResourceSetTracker) update(rootFS vfs.SubFS, rootDir string) (*SpxResourceSet, error) {
+	if t.set == nil || t.rootDir != rootDir {
+		return NewSpxResourceSet(rootFS)
+	}
+	if len(t.dirty) == 0 {
+		return t.set, nil
+	}
+
+	// Sets may be in use by previous compile results, so the tracked set is
+	// never updated in place.
+	set := t.set.clone()
+	prefix := rootDir + "/"
+	for path := range t.dirty {
+		if path == "" || strings.HasPrefix(prefix, path+"/") {
+			// The whole resource root changed.
+			return NewSpxResourceSet(rootFS)
+		}
+		name, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+		if err := set.UpdateSpxResource(rootFS, name); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
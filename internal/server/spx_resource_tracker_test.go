@@ -0,0 +1,141 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpxResourceSetTracker(t *testing.T) {
+	newFiles := func() map[string][]byte {
+		return map[string][]byte{
+			"main.spx":                          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json":                 []byte(`{"backdrops":[{"name":"backdrop1"}],"zorder":[{"name":"widget1","type":"monitor"}]}`),
+			"assets/sounds/sound1/index.json":   []byte(`{}`),
+			"assets/sounds/sound2/index.json":   []byte(`{}`),
+			"assets/sprites/Sprite1/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+			"assets/sprites/Sprite2/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+		}
+	}
+	newTracker := func(t *testing.T, m map[string][]byte) (*spxResourceSetTracker, *vfs.MapFS, *SpxResourceSet) {
+		proj := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)).getProj()
+		tracker := &spxResourceSetTracker{}
+		tracker.setLatest(proj)
+		set, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		return tracker, proj, set
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		tracker, proj, set1 := newTracker(t, newFiles())
+		set2, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.Same(t, set1, set2)
+	})
+
+	t.Run("ChangedSprite", func(t *testing.T) {
+		m := newFiles()
+		tracker, proj, set1 := newTracker(t, m)
+
+		proj.PutFile("assets/sprites/Sprite1/index.json", &vfs.MapFileImpl{Content: []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`)})
+		tracker.setLatest(proj, "assets/sprites/Sprite1/index.json")
+		set2, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.NotSame(t, set1, set2)
+		assert.NotNil(t, set2.Sprite("Sprite1").Costume("costume2"))
+		assert.Same(t, set1.Sprite("Sprite2"), set2.Sprite("Sprite2"))
+		assert.Same(t, set1.Sound("sound1"), set2.Sound("sound1"))
+
+		// Previous sets are never modified.
+		assert.Nil(t, set1.Sprite("Sprite1").Costume("costume2"))
+	})
+
+	t.Run("DeletedSound", func(t *testing.T) {
+		tracker, proj, set1 := newTracker(t, newFiles())
+
+		require.NoError(t, proj.DeleteFile("assets/sounds/sound2/index.json"))
+		tracker.setLatest(proj, "assets/sounds/sound2")
+		set2, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.Nil(t, set2.Sound("sound2"))
+		assert.NotNil(t, set1.Sound("sound2"))
+	})
+
+	t.Run("ChangedIndex", func(t *testing.T) {
+		tracker, proj, _ := newTracker(t, newFiles())
+
+		proj.PutFile("assets/index.json", &vfs.MapFileImpl{Content: []byte(`{"backdrops":[{"name":"backdrop2"}],"zorder":[{"name":"widget2","type":"monitor"}]}`)})
+		tracker.setLatest(proj, "assets/index.json")
+		set, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.Nil(t, set.Backdrop("backdrop1"))
+		assert.NotNil(t, set.Backdrop("backdrop2"))
+		assert.Nil(t, set.Widget("widget1"))
+		assert.NotNil(t, set.Widget("widget2"))
+	})
+
+	t.Run("UpdateFiles", func(t *testing.T) {
+		m := newFiles()
+		tracker, proj, set1 := newTracker(t, m)
+
+		assert.Same(t, proj, tracker.updateFiles(proj, fileMapGetter(m)()))
+
+		m["assets/sounds/sound3/index.json"] = []byte(`{}`)
+		snapshot := tracker.updateFiles(proj, fileMapGetter(m)())
+		require.NotSame(t, proj, snapshot)
+		set2, err := tracker.get(snapshot, "assets")
+		require.NoError(t, err)
+		assert.NotNil(t, set2.Sound("sound3"))
+		assert.Same(t, set1.Sprite("Sprite1"), set2.Sprite("Sprite1"))
+
+		// The previous snapshot is unchanged.
+		_, ok := proj.File("assets/sounds/sound3/index.json")
+		assert.False(t, ok)
+	})
+
+	t.Run("PreviousSnapshot", func(t *testing.T) {
+		m := newFiles()
+		tracker, proj, set1 := newTracker(t, m)
+
+		m["assets/sounds/sound3/index.json"] = []byte(`{}`)
+		snapshot := tracker.updateFiles(proj, fileMapGetter(m)())
+
+		// Sets for previous snapshots are built from scratch, leaving the
+		// changes tracked for the latest one.
+		set2, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.NotSame(t, set1, set2)
+		assert.Nil(t, set2.Sound("sound3"))
+		set3, err := tracker.get(snapshot, "assets")
+		require.NoError(t, err)
+		assert.NotNil(t, set3.Sound("sound3"))
+		assert.Same(t, set1.Sprite("Sprite1"), set3.Sprite("Sprite1"))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		tracker, proj, _ := newTracker(t, newFiles())
+
+		proj.PutFile("assets/sprites/Sprite1/index.json", &vfs.MapFileImpl{Content: []byte(`{`)})
+		tracker.setLatest(proj, "assets/sprites/Sprite1/index.json")
+		_, err := tracker.get(proj, "assets")
+		require.ErrorContains(t, err, "failed to parse sprite metadata")
+
+		// A failed update requires a full rebuild.
+		proj.PutFile("assets/sprites/Sprite1/index.json", &vfs.MapFileImpl{Content: []byte(`{}`)})
+		set, err := tracker.get(proj, "assets")
+		require.NoError(t, err)
+		assert.NotNil(t, set.Sprite("Sprite1"))
+	})
+
+	t.Run("ChangedRootDir", func(t *testing.T) {
+		m := newFiles()
+		m["other/index.json"] = []byte(`{}`)
+		tracker, proj, _ := newTracker(t, m)
+
+		set, err := tracker.get(proj, "other")
+		require.NoError(t, err)
+		assert.Nil(t, set.Sprite("Sprite1"))
+	})
+}
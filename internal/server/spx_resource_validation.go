@@ -0,0 +1,412 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// jsonNode is a JSON value with its byte offsets in the containing document.
+type jsonNode struct {
+	raw        json.RawMessage
+	start, end int
+}
+
+// jsonDocument is a parsed JSON document for validation, with all nodes
+// indexed by their slash-separated paths, e.g., "costumes/0/name".
+type jsonDocument struct {
+	data        []byte
+	posEncoding position.Encoding
+	nodes       map[string]jsonNode
+	diags       []Diagnostic
+}
+
+// newJSONDocument parses the given JSON document, whose diagnostics are
+// reported in posEncoding. It returns a diagnostic instead if the document is
+// not valid JSON or not a JSON object.
+func newJSONDocument(data []byte, posEncoding position.Encoding) (*jsonDocument, *Diagnostic) {
+	doc := &jsonDocument{data: data, posEncoding: posEncoding, nodes: make(map[string]jsonNode)}
+	var top any
+	if err := json.Unmarshal(data, &top); err != nil {
+		diag := Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("invalid JSON: %v", err)}
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			pos := posEncoding.Position(data, int(syntaxErr.Offset))
+			diag.Range = Range{Start: pos, End: pos}
+		}
+		return nil, &diag
+	}
+	if _, ok := top.(map[string]any); !ok {
+		return nil, &Diagnostic{
+			Severity: SeverityError,
+			Range:    doc.rangeForOffsets(0, len(data)),
+			Message:  "metadata must be a JSON object",
+		}
+	}
+	if err := walkJSON(data, func(path []string, raw json.RawMessage, start, end int) bool {
+		doc.nodes[strings.Join(path, "/")] = jsonNode{raw: raw, start: start, end: end}
+		return true
+	}); err != nil {
+		return nil, &Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return doc, nil
+}
+
+// rangeForOffsets returns the range between the given byte offsets.
+func (doc *jsonDocument) rangeForOffsets(start, end int) Range {
+	return Range{
+		Start: doc.posEncoding.Position(doc.data, start),
+		End:   doc.posEncoding.Position(doc.data, end),
+	}
+}
+
+// report adds a diagnostic at the node at the given path, or at the start of
+// the document if there is no such node.
+func (doc *jsonDocument) report(severity DiagnosticSeverity, path, format string, args ...any) {
+	var rng Range
+	if node, ok := doc.nodes[path]; ok {
+		rng = doc.rangeForOffsets(node.start, node.end)
+	}
+	doc.diags = append(doc.diags, Diagnostic{
+		Severity: severity,
+		Range:    rng,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// node returns the node at the given path.
+func (doc *jsonDocument) node(path string) (jsonNode, bool) {
+	node, ok := doc.nodes[path]
+	return node, ok
+}
+
+// len returns the number of elements of the array at the given path, or -1 if
+// there is no array at the path.
+func (doc *jsonDocument) len(path string) int {
+	node, ok := doc.nodes[path]
+	if !ok || len(node.raw) == 0 || node.raw[0] != '[' {
+		return -1
+	}
+	n := 0
+	for {
+		if _, ok := doc.nodes[path+"/"+strconv.Itoa(n)]; !ok {
+			return n
+		}
+		n++
+	}
+}
+
+// string returns the string at the given path. It reports an error if the
+// value at the path is not a string, and, if required, if there is no value.
+func (doc *jsonDocument) string(path string, required bool) (string, bool) {
+	node, ok := doc.nodes[path]
+	if !ok {
+		if required {
+			doc.report(SeverityError, path[:max(strings.LastIndex(path, "/"), 0)], "missing %s", path)
+		}
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(node.raw, &s); err != nil {
+		doc.report(SeverityError, path, "%s must be a string", path)
+		return "", false
+	}
+	return s, true
+}
+
+// enum returns the string at the given path, which the given schema limits to
+// its enum values. It reports a warning if the string is not one of them, as
+// spx falls back to the first one.
+func (doc *jsonDocument) enum(path string, schema *spxMetadataSchema) (string, bool) {
+	s, ok := doc.string(path, false)
+	if ok && !slices.Contains(schema.enum, s) {
+		doc.report(SeverityWarning, path, "unknown %s %q, %q is used", path, s, schema.enum[0])
+		return "", false
+	}
+	return s, ok
+}
+
+// int returns the integer at the given path. It reports an error if the value
+// at the path is not a non-negative integer.
+func (doc *jsonDocument) int(path string) (int, bool) {
+	node, ok := doc.nodes[path]
+	if !ok {
+		return 0, false
+	}
+	var n int
+	if err := json.Unmarshal(node.raw, &n); err != nil || n < 0 {
+		doc.report(SeverityError, path, "%s must be a non-negative integer", path)
+		return 0, false
+	}
+	return n, true
+}
+
+// number returns the number at the given path. It reports an error if the
+// value at the path is not a number.
+func (doc *jsonDocument) number(path string) (float64, bool) {
+	node, ok := doc.nodes[path]
+	if !ok {
+		return 0, false
+	}
+	var n float64
+	if err := json.Unmarshal(node.raw, &n); err != nil {
+		doc.report(SeverityError, path, "%s must be a number", path)
+		return 0, false
+	}
+	return n, true
+}
+
+// bool returns the boolean at the given path. It reports an error if the
+// value at the path is not a boolean.
+func (doc *jsonDocument) bool(path string) (bool, bool) {
+	node, ok := doc.nodes[path]
+	if !ok {
+		return false, false
+	}
+	var b bool
+	if err := json.Unmarshal(node.raw, &b); err != nil {
+		doc.report(SeverityError, path, "%s must be a boolean", path)
+		return false, false
+	}
+	return b, true
+}
+
+// array reports an error if there is a value at the given path that is not an
+// array. It returns the number of elements of the array.
+func (doc *jsonDocument) array(path string) int {
+	if _, ok := doc.nodes[path]; !ok {
+		return 0
+	}
+	n := doc.len(path)
+	if n < 0 {
+		doc.report(SeverityError, path, "%s must be an array", path)
+		return 0
+	}
+	return n
+}
+
+// inspectForSpxResourceMetadata validates the metadata files of spx resources,
+// i.e., the index.json files of the resource root, sounds, sprites and fonts, and
+// adds diagnostics to those files for anything the spx runtime would choke on.
+//
+// Unlike spx files, metadata files are only added to the diagnostics of the
+// compile result if they have any.
+func (s *Server) inspectForSpxResourceMetadata(result *compileResult) {
+	rootDir := result.spxResourceRootDir
+	rootFS := vfs.Sub(result.proj, rootDir)
+
+	var spriteNames []string
+	spriteEntries, err := rootFS.Readdir("sprites")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	for _, entry := range spriteEntries {
+		if entry.IsDir() {
+			spriteNames = append(spriteNames, entry.Name())
+		}
+	}
+
+	validate := func(name string, validateDoc func(doc *jsonDocument)) {
+		data, err := rootFS.ReadFile(name)
+		if err != nil {
+			return
+		}
+		documentURI := s.toDocumentURI(path.Join(rootDir, name))
+		doc, diag := newJSONDocument(data, result.posEncoding)
+		if diag != nil {
+			result.addDiagnostics(documentURI, *diag)
+			return
+		}
+		validateDoc(doc)
+		if len(doc.diags) > 0 {
+			result.addDiagnostics(documentURI, doc.diags...)
+		}
+	}
+
+	validate("index.json", func(doc *jsonDocument) {
+		validateSpxResourceIndex(doc, spriteNames)
+	})
+	soundEntries, _ := rootFS.Readdir("sounds")
+	for _, entry := range soundEntries {
+		if entry.IsDir() {
+			validate(path.Join("sounds", entry.Name(), "index.json"), validateSpxSoundMetadata)
+		}
+	}
+	for _, spriteName := range spriteNames {
+		validate(path.Join("sprites", spriteName, "index.json"), validateSpxSpriteMetadata)
+	}
+	fontEntries, _ := rootFS.Readdir("fonts")
+	for _, entry := range fontEntries {
+		if entry.IsDir() {
+			validate(path.Join("fonts", entry.Name(), "index.json"), validateSpxFontMetadata)
+		}
+	}
+}
+
+// validateSpxResourceIndex validates the index.json of the resource root.
+func validateSpxResourceIndex(doc *jsonDocument, spriteNames []string) {
+	backdropNames := make(map[string]struct{})
+	backdropCount := doc.array("backdrops")
+	for i := range backdropCount {
+		name, ok := doc.string(fmt.Sprintf("backdrops/%d/name", i), true)
+		if !ok {
+			continue
+		}
+		if _, ok := backdropNames[name]; ok {
+			doc.report(SeverityError, fmt.Sprintf("backdrops/%d/name", i), "duplicate backdrop %q", name)
+		}
+		backdropNames[name] = struct{}{}
+		doc.string(fmt.Sprintf("backdrops/%d/path", i), false)
+	}
+	if idx, ok := doc.int("backdropIndex"); ok && backdropCount > 0 && idx >= backdropCount {
+		doc.report(SeverityError, "backdropIndex", "backdropIndex %d is out of range of %d backdrops", idx, backdropCount)
+	}
+
+	sceneNames := make(map[string]struct{})
+	sceneCount := doc.array("scenes")
+	for i := range sceneCount {
+		name, ok := doc.string(fmt.Sprintf("scenes/%d/name", i), true)
+		if !ok {
+			continue
+		}
+		if _, ok := sceneNames[name]; ok {
+			doc.report(SeverityError, fmt.Sprintf("scenes/%d/name", i), "duplicate scene %q", name)
+		}
+		sceneNames[name] = struct{}{}
+		doc.string(fmt.Sprintf("scenes/%d/path", i), false)
+	}
+	if idx, ok := doc.int("sceneIndex"); ok && sceneCount > 0 && idx >= sceneCount {
+		doc.report(SeverityError, "sceneIndex", "sceneIndex %d is out of range of %d scenes", idx, sceneCount)
+	}
+	doc.enum("map/mode", spxResourceIndexMetadataSchema.at([]string{"map", "mode"}))
+
+	seenSprites := make(map[string]struct{})
+	for i := range doc.array("zorder") {
+		entryPath := fmt.Sprintf("zorder/%d", i)
+		entry, _ := doc.node(entryPath)
+		switch entry.raw[0] {
+		case '"':
+			name, _ := doc.string(entryPath, true)
+			if !slices.Contains(spriteNames, name) {
+				doc.report(SeverityError, entryPath, "sprite %q in zorder does not exist", name)
+			} else if _, ok := seenSprites[name]; ok {
+				doc.report(SeverityWarning, entryPath, "duplicate sprite %q in zorder", name)
+			}
+			seenSprites[name] = struct{}{}
+		case '{':
+			typ, ok := doc.string(entryPath+"/type", true)
+			if !ok || slices.Contains(spxNonWidgetShapeTypes, typ) {
+				continue
+			}
+			widgetType := LookupSpxWidgetType(typ)
+			if widgetType == nil {
+				doc.report(SeverityError, entryPath+"/type", "unknown widget type %q", typ)
+				continue
+			}
+			doc.string(entryPath+"/name", true)
+			widgetType.validate(doc, entryPath, spriteNames)
+		default:
+			doc.report(SeverityError, entryPath, "zorder entry must be a sprite name or a widget, got %s", entry.raw)
+		}
+	}
+}
+
+// validateSpxSoundMetadata validates the index.json of a sound.
+func validateSpxSoundMetadata(doc *jsonDocument) {
+	doc.string("path", false)
+	doc.int("rate")
+	doc.int("sampleCount")
+}
+
+// validateSpxFontMetadata validates the index.json of a font.
+func validateSpxFontMetadata(doc *jsonDocument) {
+	doc.string("path", false)
+}
+
+// validateSpxSpriteMetadata validates the index.json of a sprite.
+func validateSpxSpriteMetadata(doc *jsonDocument) {
+	costumeIndexes := make(map[string]int)
+	costumeCount := doc.array("costumes")
+	for i := range costumeCount {
+		name, ok := doc.string(fmt.Sprintf("costumes/%d/name", i), true)
+		if !ok {
+			continue
+		}
+		if _, ok := costumeIndexes[name]; ok {
+			doc.report(SeverityError, fmt.Sprintf("costumes/%d/name", i), "duplicate costume %q", name)
+		} else {
+			costumeIndexes[name] = i
+		}
+		doc.string(fmt.Sprintf("costumes/%d/path", i), false)
+		doc.int(fmt.Sprintf("costumes/%d/bitmapResolution", i))
+		for _, key := range []string{"x", "y", "faceRight"} {
+			doc.number(fmt.Sprintf("costumes/%d/%s", i, key))
+		}
+	}
+	if costumeCount == 0 {
+		// Costumes of costume groups are named by spx, so there is nothing to
+		// validate but the animations referring to them.
+		var sprite SpxSpriteResource
+		if err := json.Unmarshal(doc.data, &sprite); err == nil {
+			for i, costume := range sprite.expandCostumeGroups() {
+				if _, ok := costumeIndexes[costume.Name]; !ok {
+					costumeIndexes[costume.Name] = i
+				}
+			}
+		}
+	}
+
+	doc.enum("rotationStyle", spxSpriteMetadataSchema.properties["rotationStyle"])
+
+	if idx, ok := doc.int("costumeIndex"); ok {
+		if costumeCount > 0 && idx >= costumeCount {
+			doc.report(SeverityError, "costumeIndex", "costumeIndex %d is out of range of %d costumes", idx, costumeCount)
+		}
+	} else if _, exists := doc.node("costumeIndex"); !exists && costumeCount > 0 {
+		doc.report(SeverityWarning, "", "missing costumeIndex, the first costume is used by default")
+	}
+
+	fAnimations, ok := doc.node("fAnimations")
+	if !ok {
+		return
+	}
+	if fAnimations.raw[0] != '{' {
+		doc.report(SeverityError, "fAnimations", "fAnimations must be an object")
+		return
+	}
+	var animations map[string]json.RawMessage
+	if err := json.Unmarshal(fAnimations.raw, &animations); err != nil {
+		return
+	}
+	for _, animName := range slices.Sorted(maps.Keys(animations)) {
+		var (
+			frames       [2]string
+			frameIndexes [2]int
+			framesFound  = true
+		)
+		for i, key := range []string{"frameFrom", "frameTo"} {
+			framePath := "fAnimations/" + animName + "/" + key
+			frame, ok := doc.string(framePath, false)
+			if !ok {
+				framesFound = false
+				continue
+			}
+			frames[i] = frame
+			if frameIndexes[i], ok = costumeIndexes[frame]; !ok {
+				doc.report(SeverityError, framePath, "%s costume %q of animation %q does not exist", key, frame, animName)
+				framesFound = false
+			}
+		}
+		if framesFound && frameIndexes[0] > frameIndexes[1] {
+			doc.report(SeverityError, "fAnimations/"+animName+"/frameTo", "frameTo costume %q of animation %q comes before frameFrom costume %q", frames[1], animName, frames[0])
+		}
+	}
+}
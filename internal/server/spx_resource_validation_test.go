@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForSpxResourceMetadata(t *testing.T) {
+	newFileMap := func() map[string][]byte {
+		return map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"}],"backdropIndex":0,"zorder":["MySprite",{"name":"MyWidget","type":"monitor"}]}`),
+			"assets/sounds/MySound/index.json":   []byte(`{"path":"sound.wav","rate":44100,"sampleCount":22050}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumeIndex":0,"costumes":[{"name":"costume1","path":"costume1.png"}],"fAnimations":{"walk":{"frameFrom":"costume1","frameTo":"costume1"}}}`),
+		}
+	}
+	messages := func(diags []Diagnostic) []string {
+		var msgs []string
+		for _, diag := range diags {
+			msgs = append(msgs, diag.Message)
+		}
+		return msgs
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		m := newFileMap()
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.NotContains(t, result.diagnostics, DocumentURI("file:///assets/index.json"))
+		assert.NotContains(t, result.diagnostics, DocumentURI("file:///assets/sounds/MySound/index.json"))
+		assert.NotContains(t, result.diagnostics, DocumentURI("file:///assets/sprites/MySprite/index.json"))
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sounds/MySound/index.json"] = []byte(`{"path":`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		diags := result.diagnostics["file:///assets/sounds/MySound/index.json"]
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityError, diags[0].Severity)
+		assert.Contains(t, diags[0].Message, "invalid JSON")
+	})
+
+	t.Run("NotObject", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sounds/MySound/index.json"] = []byte(`[]`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"metadata must be a JSON object"}, messages(result.diagnostics["file:///assets/sounds/MySound/index.json"]))
+	})
+
+	t.Run("ResourceIndex", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/index.json"] = []byte(`{
+  "backdrops": [{"name": "backdrop1"}, {"name": "backdrop1"}, {"path": "backdrop3.png"}],
+  "backdropIndex": 3,
+  "zorder": ["MySprite", "MySprite", "Ghost", 42, {"type": "monitor"}]
+}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		diags := result.diagnostics["file:///assets/index.json"]
+		assert.Equal(t, []string{
+			`duplicate backdrop "backdrop1"`,
+			"missing backdrops/2/name",
+			"backdropIndex 3 is out of range of 3 backdrops",
+			`duplicate sprite "MySprite" in zorder`,
+			`sprite "Ghost" in zorder does not exist`,
+			"zorder entry must be a sprite name or a widget, got 42",
+			"missing zorder/4/name",
+		}, messages(diags))
+		require.Len(t, diags, 7)
+		assert.Equal(t, SeverityWarning, diags[3].Severity)
+		assert.Equal(t, Range{
+			Start: Position{Line: 2, Character: 19},
+			End:   Position{Line: 2, Character: 20},
+		}, diags[2].Range)
+	})
+
+	t.Run("Widgets", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/index.json"] = []byte(`{"zorder":[
+  {"type":"monitor","name":"score","target":"","val":"getVar:score","mode":3,"sliderMin":0,"sliderMax":100,"visible":true},
+  {"type":"stageMonitor","name":"ghost","target":"Ghost","val":"score","mode":4,"sliderMin":10,"sliderMax":1,"visible":"yes"},
+  {"type":"measure","size":10},
+  {"type":"knob","name":"knob"},
+  {"name":"untyped"}
+]}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		diags := result.diagnostics["file:///assets/index.json"]
+		assert.Equal(t, []string{
+			`monitor target "Ghost" is not a sprite, the monitor is not shown`,
+			`monitor val "score" must be "getVar:" followed by a variable name, the monitor is not shown`,
+			"unknown monitor mode 4",
+			"sliderMax 1 is less than sliderMin 10",
+			"zorder/1/visible must be a boolean",
+			`unknown widget type "knob"`,
+			"missing zorder/4/type",
+		}, messages(diags))
+		require.Len(t, diags, 7)
+		assert.Equal(t, SeverityWarning, diags[0].Severity)
+		assert.Equal(t, SeverityWarning, diags[1].Severity)
+		assert.Equal(t, SeverityError, diags[2].Severity)
+
+		score := result.spxResourceSet.Widget("score")
+		require.NotNil(t, score)
+		assert.Same(t, LookupSpxWidgetType("monitor"), score.WidgetType)
+		require.NotNil(t, score.Monitor)
+		assert.Equal(t, SpxMonitorModeSlider, score.Monitor.Mode)
+		assert.Equal(t, 100.0, score.Monitor.SliderMax)
+		assert.Nil(t, result.spxResourceSet.Widget("untyped").WidgetType)
+	})
+
+	t.Run("SoundMetadata", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sounds/MySound/index.json"] = []byte(`{"path":1,"rate":-1,"sampleCount":"many"}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"path must be a string",
+			"rate must be a non-negative integer",
+			"sampleCount must be a non-negative integer",
+		}, messages(result.diagnostics["file:///assets/sounds/MySound/index.json"]))
+	})
+
+	t.Run("SpriteMetadata", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumes":[{"name":"costume1"},{"name":"costume1","x":"center"}],"fAnimations":{"walk":{"frameFrom":"costume1","frameTo":"costume3"}}}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		diags := result.diagnostics["file:///assets/sprites/MySprite/index.json"]
+		assert.Equal(t, []string{
+			`duplicate costume "costume1"`,
+			"costumes/1/x must be a number",
+			"missing costumeIndex, the first costume is used by default",
+			`frameTo costume "costume3" of animation "walk" does not exist`,
+		}, messages(diags))
+		require.Len(t, diags, 4)
+		assert.Equal(t, SeverityWarning, diags[2].Severity)
+	})
+
+	t.Run("CostumeGroup", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeSet":{"path":"walk.png","nx":2,"items":[{"namePrefix":"walk","n":2}]},"fAnimations":{"walk":{"frameFrom":"walk0","frameTo":"walk2"}}}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`frameTo costume "walk2" of animation "walk" does not exist`,
+		}, messages(result.diagnostics["file:///assets/sprites/MySprite/index.json"]))
+	})
+
+	t.Run("ReversedAnimation", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeIndex":0,"costumes":[{"name":"walk1"},{"name":"walk2"}],"fAnimations":{"walk":{"frameFrom":"walk2","frameTo":"walk1"}}}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`frameTo costume "walk1" of animation "walk" comes before frameFrom costume "walk2"`,
+		}, messages(result.diagnostics["file:///assets/sprites/MySprite/index.json"]))
+	})
+
+	t.Run("CostumeIndexOutOfRange", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeIndex":1,"costumes":[{"name":"costume1"}]}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"costumeIndex 1 is out of range of 1 costumes",
+		}, messages(result.diagnostics["file:///assets/sprites/MySprite/index.json"]))
+	})
+
+	t.Run("UnknownEnumValues", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/index.json"] = []byte(`{"map":{"width":480,"height":360,"mode":"stretch"}}`)
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeIndex":0,"costumes":[{"name":"costume1"}],"rotationStyle":"all around"}`)
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`unknown map/mode "stretch", "fill" is used`,
+		}, messages(result.diagnostics["file:///assets/index.json"]))
+		diags := result.diagnostics["file:///assets/sprites/MySprite/index.json"]
+		assert.Equal(t, []string{
+			`unknown rotationStyle "all around", "normal" is used`,
+		}, messages(diags))
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityWarning, diags[0].Severity)
+	})
+
+	t.Run("ClearedWhenFixed", func(t *testing.T) {
+		m := newFileMap()
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeIndex":1,"costumes":[{"name":"costume1"}]}`)
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+
+		published := func() map[DocumentURI][]Diagnostic {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			diagsByURI := make(map[DocumentURI][]Diagnostic)
+			for _, msg := range replier.messages {
+				n, ok := msg.(*jsonrpc2.Notification)
+				require.True(t, ok)
+				var params PublishDiagnosticsParams
+				require.NoError(t, json.Unmarshal(n.Params(), &params))
+				diagsByURI[params.URI] = params.Diagnostics
+			}
+			replier.messages = nil
+			return diagsByURI
+		}
+
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		assert.Len(t, published()["file:///assets/sprites/MySprite/index.json"], 1)
+
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumeIndex":0,"costumes":[{"name":"costume1"}]}`)
+		s.InvalidateFiles("assets/sprites/MySprite/index.json")
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		diags, ok := published()["file:///assets/sprites/MySprite/index.json"]
+		assert.True(t, ok)
+		assert.Empty(t, diags)
+
+		require.NoError(t, s.publishAllDiagnostics(context.Background()))
+		assert.NotContains(t, published(), DocumentURI("file:///assets/sprites/MySprite/index.json"))
+	})
+
+	t.Run("ClearedInWorkspaceDiagnostic", func(t *testing.T) {
+		m := newFileMap()
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{
+			PreviousResultIds: []PreviousResultID{{URI: "file:///assets/index.json", Value: "stale"}},
+		})
+		require.NoError(t, err)
+		var found bool
+		for _, item := range report.Items {
+			fullReport, ok := item.Value.(WorkspaceFullDocumentDiagnosticReport)
+			if !ok || fullReport.URI != "file:///assets/index.json" {
+				continue
+			}
+			found = true
+			assert.Empty(t, fullReport.Items)
+		}
+		assert.True(t, found)
+	})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SpxWidgetType is a type of spx widgets, i.e., the shapes declared in the
+// zorder of the resource root index.json that can be retrieved by name with
+// getWidget.
+type SpxWidgetType struct {
+	// Name is the type of the widget in index.json, e.g., "monitor".
+	Name string
+
+	// Aliases are other names of the type accepted in index.json.
+	Aliases []string
+
+	// GoTypeName is the name of the widget type in the spx package, e.g.,
+	// "Monitor", as passed to getWidget.
+	GoTypeName string
+
+	// parse parses the type-specific fields of a widget from its zorder entry.
+	parse func(widget *SpxWidgetResource, metadata json.RawMessage) error
+
+	// validate validates the type-specific fields of the zorder entry at the
+	// given path.
+	validate func(doc *jsonDocument, entryPath string, spriteNames []string)
+}
+
+// spxWidgetTypes is the registry of spx widget types.
+var spxWidgetTypes = []*SpxWidgetType{
+	{
+		Name:       "monitor",
+		Aliases:    []string{"stageMonitor"},
+		GoTypeName: "Monitor",
+		parse:      parseSpxMonitorWidget,
+		validate:   validateSpxMonitorWidget,
+	},
+}
+
+// spxNonWidgetShapeTypes are the types of zorder entries that are valid
+// shapes in spx but not widgets.
+var spxNonWidgetShapeTypes = []string{"measure", "sprite", "sprites"}
+
+// LookupSpxWidgetType returns the widget type with the given name or alias in
+// index.json. It returns nil if there is no such widget type.
+func LookupSpxWidgetType(name string) *SpxWidgetType {
+	for _, typ := range spxWidgetTypes {
+		if typ.Name == name || slices.Contains(typ.Aliases, name) {
+			return typ
+		}
+	}
+	return nil
+}
+
+// lookupSpxWidgetTypeByGoTypeName returns the widget type whose type in the
+// spx package has the given name. It returns nil if there is no such widget
+// type.
+func lookupSpxWidgetTypeByGoTypeName(goTypeName string) *SpxWidgetType {
+	for _, typ := range spxWidgetTypes {
+		if typ.GoTypeName == goTypeName {
+			return typ
+		}
+	}
+	return nil
+}
+
+// SpxMonitorMode is the display mode of an spx monitor widget.
+type SpxMonitorMode int
+
+const (
+	SpxMonitorModeNormal SpxMonitorMode = 1
+	SpxMonitorModeLarge  SpxMonitorMode = 2
+	SpxMonitorModeSlider SpxMonitorMode = 3
+)
+
+// spxMonitorValPrefix is the prefix of the val of monitors that display a
+// variable, which is the only kind of monitors supported by spx.
+const spxMonitorValPrefix = "getVar:"
+
+// SpxMonitorWidget holds the fields specific to monitor widgets.
+type SpxMonitorWidget struct {
+	// Target is the name of the sprite whose variable is displayed, or empty
+	// for a variable of the game.
+	Target     string         `json:"target"`
+	Mode       SpxMonitorMode `json:"mode"`
+	SliderMin  float64        `json:"sliderMin"`
+	SliderMax  float64        `json:"sliderMax"`
+	IsDiscrete bool           `json:"isDiscrete"`
+	Visible    bool           `json:"visible"`
+}
+
+// spxMonitorVariable returns the name of the variable displayed by a monitor
+// with the given val. It returns false if val does not display a variable.
+func spxMonitorVariable(val string) (string, bool) {
+	name, ok := strings.CutPrefix(val, spxMonitorValPrefix)
+	return name, ok && name != ""
+}
+
+// parseSpxMonitorWidget parses the fields of a monitor widget.
+func parseSpxMonitorWidget(widget *SpxWidgetResource, metadata json.RawMessage) error {
+	var monitor SpxMonitorWidget
+	if err := json.Unmarshal(metadata, &monitor); err != nil {
+		return fmt.Errorf("failed to parse monitor %q: %w", widget.Name, err)
+	}
+	widget.Monitor = &monitor
+	return nil
+}
+
+// validateSpxMonitorWidget validates the fields of a monitor widget.
+func validateSpxMonitorWidget(doc *jsonDocument, entryPath string, spriteNames []string) {
+	if target, ok := doc.string(entryPath+"/target", false); ok && target != "" && !slices.Contains(spriteNames, target) {
+		doc.report(SeverityWarning, entryPath+"/target", "monitor target %q is not a sprite, the monitor is not shown", target)
+	}
+	if val, ok := doc.string(entryPath+"/val", false); ok {
+		if _, ok := spxMonitorVariable(val); !ok {
+			doc.report(SeverityWarning, entryPath+"/val", "monitor val %q must be %q followed by a variable name, the monitor is not shown", val, spxMonitorValPrefix)
+		}
+	}
+	doc.string(entryPath+"/label", false)
+	if mode, ok := doc.int(entryPath + "/mode"); ok {
+		switch SpxMonitorMode(mode) {
+		case SpxMonitorModeNormal, SpxMonitorModeLarge, SpxMonitorModeSlider:
+		default:
+			doc.report(SeverityError, entryPath+"/mode", "unknown monitor mode %d", mode)
+		}
+	}
+	sliderMin, minOK := doc.number(entryPath + "/sliderMin")
+	sliderMax, maxOK := doc.number(entryPath + "/sliderMax")
+	if minOK && maxOK && sliderMin > sliderMax {
+		doc.report(SeverityError, entryPath+"/sliderMax", "sliderMax %v is less than sliderMin %v", sliderMax, sliderMin)
+	}
+	doc.bool(entryPath + "/isDiscrete")
+	doc.bool(entryPath + "/visible")
+}
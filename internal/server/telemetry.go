@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Telemetry receives spans and metrics of key operations of the server, e.g.,
+// to measure their tail latency in hosted deployments. Implementations are
+// usually adapters to OpenTelemetry, with attributes converted from
+// [slog.Attr]. Methods may be called concurrently.
+//
+// The spans are:
+//   - "compile": compiling the workspace, with the child spans "parse",
+//     "typecheck" and "analysis" for its phases.
+//   - "textDocument/completion": handling a completion request.
+//   - "textDocument/didChange": applying the changes of a document.
+//
+// The duration of each span in seconds is also recorded as the metric named
+// after it with a ".duration" suffix, e.g., "compile.duration".
+type Telemetry interface {
+	// StartSpan starts a span with the given name and attributes as a child
+	// of the span of ctx, if any, and returns a context with the new span.
+	StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+
+	// RecordMetric records the given value of the metric with the given name
+	// and attributes.
+	RecordMetric(ctx context.Context, name string, value float64, attrs ...slog.Attr)
+}
+
+// Span is a span started by [Telemetry.StartSpan].
+type Span interface {
+	// End ends the span, which failed with err if not nil.
+	End(err error)
+}
+
+// noopTelemetry is the default [Telemetry], which does nothing.
+type noopTelemetry struct{}
+
+func (noopTelemetry) StartSpan(ctx context.Context, _ string, _ ...slog.Attr) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTelemetry) RecordMetric(context.Context, string, float64, ...slog.Attr) {}
+
+// noopSpan is the [Span] of [noopTelemetry].
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// SetTelemetry sets the telemetry of the server, which is a no-op by default.
+// It must be called before the server handles any message.
+func (s *Server) SetTelemetry(t Telemetry) {
+	if t == nil {
+		t = noopTelemetry{}
+	}
+	s.telemetry = t
+}
+
+// startSpan starts a span with the given name and attributes, see
+// [Telemetry]. The returned function ends the span with the error it is
+// given, and records the duration of the span.
+func (s *Server) startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := s.telemetry.StartSpan(ctx, name, attrs...)
+	return ctx, func(err error) {
+		span.End(err)
+		s.telemetry.RecordMetric(ctx, name+".duration", time.Since(start).Seconds(), attrs...)
+	}
+}
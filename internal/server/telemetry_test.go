@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTelemetry is a [Telemetry] that records the paths of ended spans,
+// e.g., "compile/parse", and the names of recorded metrics.
+type recordingTelemetry struct {
+	mu      sync.Mutex
+	spans   []string
+	metrics []string
+}
+
+type recordingSpanKey struct{}
+
+type recordingSpan struct {
+	t    *recordingTelemetry
+	path string
+}
+
+func (t *recordingTelemetry) StartSpan(ctx context.Context, name string, _ ...slog.Attr) (context.Context, Span) {
+	path := name
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		path = parent.path + "/" + name
+	}
+	span := &recordingSpan{t: t, path: path}
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+func (t *recordingTelemetry) RecordMetric(_ context.Context, name string, _ float64, _ ...slog.Attr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.metrics = append(t.metrics, name)
+}
+
+func (s *recordingSpan) End(error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.spans = append(s.t.spans, s.path)
+}
+
+func TestServerTelemetry(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(``),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		_, err := s.compile(context.Background())
+		require.NoError(t, err)
+	})
+
+	t.Run("Spans", func(t *testing.T) {
+		telemetry := &recordingTelemetry{}
+		s := New(newMapFSWithoutModTime(m), &recordingReplier{}, fileMapGetter(m))
+		initializeServer(t, s)
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		s.SetTelemetry(telemetry)
+
+		_, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		n, err := jsonrpc2.NewNotification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": "file:///main.spx", "version": 1},
+			"contentChanges": []any{},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+
+		telemetry.mu.Lock()
+		defer telemetry.mu.Unlock()
+		assert.Equal(t, []string{
+			"textDocument/completion/compile/parse",
+			"textDocument/completion/compile/typecheck",
+			"textDocument/completion/compile/analysis",
+			"textDocument/completion/compile",
+			"textDocument/completion",
+			"textDocument/didChange",
+		}, telemetry.spans)
+		assert.Equal(t, []string{
+			"parse.duration",
+			"typecheck.duration",
+			"analysis.duration",
+			"compile.duration",
+			"textDocument/completion.duration",
+			"textDocument/didChange.duration",
+		}, telemetry.metrics)
+	})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_didOpen
+func (s *Server) textDocumentDidOpen(params *DidOpenTextDocumentParams) error {
+	path, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document URI %q: %w", params.TextDocument.URI, err)
+	}
+
+	// Until the document is closed, the client owns its content, which takes
+	// precedence over the file map getter.
+	s.openDocuments.Put(path, []byte(params.TextDocument.Text))
+	return nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_didChange
+func (s *Server) textDocumentDidChange(params *DidChangeTextDocumentParams) error {
+	path, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document URI %q: %w", params.TextDocument.URI, err)
+	}
+
+	content, ok := s.openDocuments.Content(path)
+	if !ok {
+		// The content of documents that were not opened is owned by the file
+		// map getter, which is kept up to date by the client.
+		return nil
+	}
+	posEncoding := s.getPositionEncoding()
+	for _, change := range params.ContentChanges {
+		content = applyContentChange(posEncoding, content, change)
+	}
+	s.openDocuments.Put(path, content)
+	return nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_didClose
+func (s *Server) textDocumentDidClose(params *DidCloseTextDocumentParams) error {
+	path, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document URI %q: %w", params.TextDocument.URI, err)
+	}
+
+	s.openDocuments.Delete(path)
+	s.semanticTokensResults.Delete(params.TextDocument.URI)
+	return nil
+}
+
+// getFiles returns the files of the workspace from the file map getter, with
+// the contents of open documents in place of their files, within the limits
+// set by [Server.SetFileLimits].
+func (s *Server) getFiles() map[string]vfs.MapFile {
+	return s.applyFileLimits(s.openDocuments.Files(s.fileMapGetter()))
+}
+
+// openDocumentPaths returns the sorted paths of the open documents relative to
+// the workspace root.
+func (s *Server) openDocumentPaths() []string {
+	return s.openDocuments.Paths()
+}
+
+// applyContentChange returns content with the given change, whose range is in
+// posEncoding, applied. A change without a range replaces the whole content.
+func applyContentChange(posEncoding position.Encoding, content []byte, change TextDocumentContentChangeEvent) []byte {
+	if change.Range == nil {
+		return []byte(change.Text)
+	}
+	start := posEncoding.Offset(content, change.Range.Start)
+	end := max(posEncoding.Offset(content, change.Range.End), start)
+	newContent := make([]byte, 0, len(content)-(end-start)+len(change.Text))
+	newContent = append(newContent, content[:start]...)
+	newContent = append(newContent, change.Text...)
+	newContent = append(newContent, content[end:]...)
+	return newContent
+}
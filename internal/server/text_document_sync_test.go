@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/position"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyContentChange(t *testing.T) {
+	change := func(startLine, startChar, endLine, endChar uint32, text string) TextDocumentContentChangeEvent {
+		return TextDocumentContentChangeEvent{
+			Range: &Range{
+				Start: Position{Line: startLine, Character: startChar},
+				End:   Position{Line: endLine, Character: endChar},
+			},
+			Text: text,
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		change  TextDocumentContentChangeEvent
+		want    string
+	}{
+		{"Full", "echo 1\n", TextDocumentContentChangeEvent{Text: "echo 2\n"}, "echo 2\n"},
+		{"Insert", "echo 1\n", change(0, 5, 0, 5, "-"), "echo -1\n"},
+		{"Replace", "echo 1\necho 2\n", change(1, 5, 1, 6, "3"), "echo 1\necho 3\n"},
+		{"DeleteAcrossLines", "echo 1\necho 2\necho 3\n", change(0, 6, 2, 6, ""), "echo 1\n"},
+		{"UTF16", "echo \"你好😀!\"\n", change(0, 10, 0, 11, "?"), "echo \"你好😀?\"\n"},
+		{"PastEndOfLine", "echo 1\necho 2\n", change(0, 100, 0, 100, " + 1"), "echo 1 + 1\necho 2\n"},
+		{"PastEndOfContent", "echo 1", change(5, 0, 5, 0, "\necho 2"), "echo 1\necho 2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(applyContentChange(position.UTF16, []byte(tt.content), tt.change)))
+		})
+	}
+
+	t.Run("UTF8", func(t *testing.T) {
+		got := applyContentChange(position.UTF8, []byte("echo \"你好😀!\"\n"), change(0, 16, 0, 17, "?"))
+		assert.Equal(t, "echo \"你好😀?\"\n", string(got))
+	})
+}
+
+func TestServerTextDocumentSync(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+echo "Hi"
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	initializeServer(t, s)
+	s.diagnosticScheduler.delay = time.Hour
+
+	notify := func(method string, params any) {
+		n, err := jsonrpc2.NewNotification(method, params)
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+	}
+	diagnosticMessages := func() []string {
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		var msgs []string
+		for _, diag := range result.diagnostics["file:///main.spx"] {
+			msgs = append(msgs, diag.Message)
+		}
+		return msgs
+	}
+
+	notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:     "file:///main.spx",
+			Version: 1,
+			Text: `
+echo undefinedVar
+run "assets", {Title: "My Game"}
+`,
+		},
+	})
+	assert.Equal(t, []string{"undefined: undefinedVar"}, diagnosticMessages())
+
+	notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Version:                2,
+		},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{
+				Range: &Range{
+					Start: Position{Line: 1, Character: 5},
+					End:   Position{Line: 1, Character: 17},
+				},
+				Text: "definedVar",
+			},
+			{
+				Range: &Range{
+					Start: Position{Line: 0, Character: 0},
+					End:   Position{Line: 0, Character: 0},
+				},
+				Text: "var definedVar int\n",
+			},
+		},
+	})
+	assert.Empty(t, diagnosticMessages())
+	assert.Equal(t, `var definedVar int
+
+echo definedVar
+run "assets", {Title: "My Game"}
+`, string(s.getFiles()["main.spx"].Content))
+
+	notify("textDocument/didClose", DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+	})
+	assert.Equal(t, string(m["main.spx"]), string(s.getFiles()["main.spx"].Content))
+	assert.Empty(t, diagnosticMessages())
+
+	t.Run("NotOpen", func(t *testing.T) {
+		notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Version:                3,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: "echo undefinedVar\n"}},
+		})
+		assert.Equal(t, string(m["main.spx"]), string(s.getFiles()["main.spx"].Content))
+	})
+}
+
+func TestServerTextDocumentSyncOverlay(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	snapshot := s.snapshot()
+
+	// Opening a document with its content on disk invalidates nothing.
+	require.NoError(t, s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: "file:///assets/index.json", Text: `{}`},
+	}))
+	assert.Same(t, snapshot, s.snapshot())
+	require.NoError(t, s.textDocumentDidClose(&DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///assets/index.json"},
+	}))
+	assert.Same(t, snapshot, s.snapshot())
+
+	// Unsaved resource metadata is seen by the spx resource set, until the
+	// document is closed.
+	require.NoError(t, s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: "file:///assets/sprites/MySprite/index.json", Text: `{}`},
+	}))
+	set, err := s.spxResources.get(s.snapshot(), "assets")
+	require.NoError(t, err)
+	assert.NotNil(t, set.Sprite("MySprite"))
+
+	require.NoError(t, s.textDocumentDidClose(&DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///assets/sprites/MySprite/index.json"},
+	}))
+	set, err = s.spxResources.get(s.snapshot(), "assets")
+	require.NoError(t, err)
+	assert.Nil(t, set.Sprite("MySprite"))
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+)
+
+// GoTranslation represents the Go source code translated from the Go+ source
+// code of the workspace.
+type GoTranslation struct {
+	// Code is the Go source code, without line directives.
+	Code string `json:"code"`
+
+	// Mappings maps lines of Code to lines of the Go+ source files, in the
+	// order of lines of Code. Lines of synthesized code, e.g., type
+	// declarations of classes, are not mapped.
+	Mappings []GoLineMapping `json:"mappings"`
+
+	// Incomplete reports whether the Go+ source code has errors, in which
+	// case Code may be incomplete.
+	Incomplete bool `json:"incomplete"`
+}
+
+// GoLineMapping maps consecutive lines of the Go source code to the same
+// number of consecutive lines of a Go+ source file.
+type GoLineMapping struct {
+	// GoLine is the zero-based number of the first line in the Go source
+	// code.
+	GoLine uint32 `json:"goLine"`
+
+	// LineCount is the number of mapped lines.
+	LineCount uint32 `json:"lineCount"`
+
+	// URI is the URI of the Go+ source file.
+	URI DocumentURI `json:"uri"`
+
+	// Line is the zero-based number of the first line in the Go+ source file.
+	Line uint32 `json:"line"`
+}
+
+// xgoTranslateToGo handles the xgo/translateToGo request, which returns the Go
+// source code translated from the Go+ source code of the workspace, e.g., for
+// clients to display or to map positions in Go back to Go+.
+func (s *Server) xgoTranslateToGo(ctx context.Context) (*GoTranslation, error) {
+	result, err := s.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.translateToGo(ctx, result)
+}
+
+// translateToGo returns the Go source code translated from the Go+ source
+// code of the given compile result.
+func (s *Server) translateToGo(ctx context.Context, result *compileResult) (*GoTranslation, error) {
+	if result.mainSpxFile == "" {
+		return nil, errNoMainSpxFile
+	}
+	code, err := result.proj.GoCodeContext(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if code == nil {
+		return nil, err
+	}
+	translation := s.stripLineDirectives(code)
+	translation.Incomplete = err != nil
+	return translation, nil
+}
+
+// stripLineDirectives removes the line directives from the given Go source
+// code, mapping the lines following each of them to the position it refers
+// to. A mapping ends at the next directive, or before a closing brace at the
+// beginning of a line, which ends a top-level declaration and is synthesized
+// if the declaration is.
+func (s *Server) stripLineDirectives(code []byte) *GoTranslation {
+	var (
+		buf      strings.Builder
+		mappings []GoLineMapping
+		current  *GoLineMapping
+		goLine   uint32
+	)
+	for len(code) > 0 {
+		line := code
+		if i := bytes.IndexByte(code, '\n'); i >= 0 {
+			line, code = code[:i+1], code[i+1:]
+		} else {
+			code = nil
+		}
+		if path, lineNum, ok := parseLineDirective(line); ok {
+			mappings = append(mappings, GoLineMapping{
+				GoLine: goLine,
+				URI:    s.toDocumentURI(path),
+				Line:   uint32(lineNum - 1),
+			})
+			current = &mappings[len(mappings)-1]
+			continue
+		}
+		if current != nil && bytes.HasPrefix(line, []byte("}")) {
+			current = nil
+		}
+		if current != nil {
+			current.LineCount++
+		}
+		buf.Write(line)
+		goLine++
+	}
+
+	// Drop mappings of no lines, e.g., the ones of directives followed by
+	// others.
+	n := 0
+	for _, m := range mappings {
+		if m.LineCount > 0 {
+			mappings[n] = m
+			n++
+		}
+	}
+	return &GoTranslation{
+		Code:     buf.String(),
+		Mappings: mappings[:n],
+	}
+}
+
+// parseLineDirective parses the given line as a line directive of the form
+// "//line filename:line" or "//line filename:line:col", returning the file
+// name and the one-based line number.
+func parseLineDirective(line []byte) (filename string, lineNum int, ok bool) {
+	text, ok := strings.CutPrefix(strings.TrimRight(string(line), "\r\n"), "//line ")
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(text, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+	// The column is optional, so the last number is the line number unless
+	// it is preceded by another one.
+	if j := strings.LastIndexByte(text[:i], ':'); j >= 0 {
+		if n, err := strconv.Atoi(text[j+1 : i]); err == nil && n > 0 {
+			text, i = text[:i], j
+		}
+	}
+	n, err := strconv.Atoi(text[i+1:])
+	if err != nil || n <= 0 || i == 0 {
+		return "", 0, false
+	}
+	return text[:i], n, true
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTranslateToGo(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`var (
+	score int
+)
+
+func add(n int) {
+	score += n
+}
+
+onStart => {
+	add 1
+	echo score
+}
+`),
+			"MySprite.spx": []byte(`onClick => {
+	say "hi"
+}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		translation, err := s.xgoTranslateToGo(context.Background())
+		require.NoError(t, err)
+		assert.False(t, translation.Incomplete)
+		assert.NotContains(t, translation.Code, "//line")
+		lines := strings.Split(translation.Code, "\n")
+		goLine := func(line string) uint32 {
+			for i, l := range lines {
+				if strings.TrimSpace(l) == line {
+					return uint32(i)
+				}
+			}
+			t.Fatalf("line %q not found in:\n%s", line, translation.Code)
+			return 0
+		}
+
+		assert.Contains(t, translation.Mappings, GoLineMapping{
+			GoLine:    goLine("func (this *Game) add(n int) {"),
+			LineCount: 1,
+			URI:       "file:///main.spx",
+			Line:      4,
+		})
+		assert.Contains(t, translation.Mappings, GoLineMapping{
+			GoLine:    goLine("this.score += n"),
+			LineCount: 1,
+			URI:       "file:///main.spx",
+			Line:      5,
+		})
+		assert.Contains(t, translation.Mappings, GoLineMapping{
+			GoLine:    goLine("fmt.Println(this.score)"),
+			LineCount: 2,
+			URI:       "file:///main.spx",
+			Line:      10,
+		})
+		assert.Contains(t, translation.Mappings, GoLineMapping{
+			GoLine:    goLine(`this.Say__0("hi")`),
+			LineCount: 2,
+			URI:       "file:///MySprite.spx",
+			Line:      1,
+		})
+
+		// Synthesized code is not mapped.
+		mainLine := goLine("func main() {")
+		for _, mapping := range translation.Mappings {
+			assert.False(t, mapping.GoLine <= mainLine && mainLine < mapping.GoLine+mapping.LineCount)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`echo undefined
+echo "hi"
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		translation, err := s.xgoTranslateToGo(context.Background())
+		require.NoError(t, err)
+		assert.True(t, translation.Incomplete)
+		assert.Contains(t, translation.Code, `fmt.Println("hi")`)
+	})
+}
+
+func TestParseLineDirective(t *testing.T) {
+	for _, tt := range []struct {
+		line     string
+		filename string
+		lineNum  int
+		ok       bool
+	}{
+		{"//line main.spx:5:1\n", "main.spx", 5, true},
+		{"//line main.spx:9\n", "main.spx", 9, true},
+		{"//line C:/a.spx:3\n", "C:/a.spx", 3, true},
+		{"//line a:b.spx:2:7", "a:b.spx", 2, true},
+		{"//line main.spx\n", "", 0, false},
+		{"//line :1\n", "", 0, false},
+		{"// line main.spx:1\n", "", 0, false},
+		{"\tfmt.Println()\n", "", 0, false},
+	} {
+		filename, lineNum, ok := parseLineDirective([]byte(tt.line))
+		assert.Equal(t, tt.filename, filename, tt.line)
+		assert.Equal(t, tt.lineNum, lineNum, tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+	}
+}
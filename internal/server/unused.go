@@ -0,0 +1,329 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/gop/goputil"
+)
+
+// inspectForUnusedDecls inspects for imports and local variables that are
+// declared but not used. Unlike the Go type checker, the Go+ one does not
+// report them, so they are reported as warnings with quick fixes that remove
+// them or keep them with blank identifiers. Removals are also applied all at
+// once by the source.fixAll code action. Files with parse or type errors are
+// skipped.
+func (s *Server) inspectForUnusedDecls(result *compileResult) {
+	typeInfo := getTypeInfo(result.proj)
+	usedPkgNames := make(map[*types.PkgName]struct{})
+	varRefs := make(map[*types.Var][]*gopast.Ident)
+	for ident, obj := range typeInfo.Uses {
+		switch obj := obj.(type) {
+		case *types.PkgName:
+			usedPkgNames[obj] = struct{}{}
+		case *types.Var:
+			varRefs[obj] = append(varRefs[obj], ident)
+		}
+	}
+
+	astPkg := getASTPkg(result.proj)
+	for _, spxFile := range slices.Sorted(maps.Keys(astPkg.Files)) {
+		// Uses in statements with type errors are not recorded.
+		if slices.ContainsFunc(result.diagnostics[result.documentURIs[spxFile]], func(diagnostic Diagnostic) bool {
+			return diagnostic.Severity == SeverityError
+		}) {
+			continue
+		}
+		astFile := astPkg.Files[spxFile]
+		result.inspectForUnusedImports(astFile, usedPkgNames)
+		result.inspectForUnusedVars(astFile, varRefs)
+	}
+}
+
+// inspectForUnusedImports inspects for imports of the given AST file whose
+// package names are not in usedPkgNames.
+func (r *compileResult) inspectForUnusedImports(astFile *gopast.File, usedPkgNames map[*types.PkgName]struct{}) {
+	typeInfo := getTypeInfo(r.proj)
+	documentURI := r.nodeDocumentURI(astFile)
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*gopast.GenDecl)
+		if !ok || genDecl.Tok != goptoken.IMPORT {
+			continue
+		}
+
+		var fixAllEdits []TextEdit
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*gopast.ImportSpec)
+			var pkgName *types.PkgName
+			if importSpec.Name != nil {
+				if importSpec.Name.Name == "_" || importSpec.Name.Name == "." {
+					continue
+				}
+				pkgName, _ = typeInfo.Defs[importSpec.Name].(*types.PkgName)
+			} else {
+				pkgName, _ = typeInfo.Implicits[importSpec].(*types.PkgName)
+			}
+			if pkgName == nil {
+				continue // E.g., a package that is not available.
+			}
+			if _, ok := usedPkgNames[pkgName]; ok {
+				continue
+			}
+
+			message := fmt.Sprintf("%s imported and not used", importSpec.Path.Value)
+			if pkgName.Name() != pkgName.Imported().Name() {
+				message = fmt.Sprintf("%s imported as %s and not used", importSpec.Path.Value, pkgName.Name())
+			}
+			diagnostic := Diagnostic{
+				Severity: SeverityWarning,
+				Range:    r.rangeForNode(importSpec),
+				Message:  message,
+				Tags:     []DiagnosticTag{Unnecessary},
+			}
+			r.addDiagnostics(documentURI, diagnostic)
+
+			var removed gopast.Node = importSpec
+			if len(genDecl.Specs) == 1 {
+				removed = genDecl
+			}
+			removeEdit := r.removeNodeEdit(astFile, removed)
+			blankEdit := TextEdit{
+				Range:   r.rangeForStartEnd(astFile, importSpec.Path.Pos(), importSpec.Path.Pos()),
+				NewText: "_ ",
+			}
+			if importSpec.Name != nil {
+				blankEdit = TextEdit{
+					Range:   r.rangeForNode(importSpec.Name),
+					NewText: "_",
+				}
+			}
+			r.quickFixes[documentURI] = append(r.quickFixes[documentURI], quickFix{
+				diagnostic:  diagnostic,
+				title:       fmt.Sprintf("Remove import %s", importSpec.Path.Value),
+				edits:       []TextEdit{removeEdit},
+				isPreferred: true,
+			}, quickFix{
+				diagnostic: diagnostic,
+				title:      fmt.Sprintf("Change to blank import %s", importSpec.Path.Value),
+				edits:      []TextEdit{blankEdit},
+			})
+			fixAllEdits = append(fixAllEdits, removeEdit)
+		}
+		if len(fixAllEdits) > 0 && len(fixAllEdits) == len(genDecl.Specs) {
+			// Remove the whole declaration instead of leaving it empty.
+			fixAllEdits = []TextEdit{r.removeNodeEdit(astFile, genDecl)}
+		}
+		r.fixAllEdits[documentURI] = append(r.fixAllEdits[documentURI], fixAllEdits...)
+	}
+}
+
+// inspectForUnusedVars inspects for local variables declared in the given AST
+// file that are only referenced by the given references as assignment
+// targets, if at all.
+func (r *compileResult) inspectForUnusedVars(astFile *gopast.File, varRefs map[*types.Var][]*gopast.Ident) {
+	typeInfo := getTypeInfo(r.proj)
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		var (
+			stmt   gopast.Stmt
+			idents []*gopast.Ident
+			value  gopast.Expr
+		)
+		switch node := node.(type) {
+		case *gopast.AssignStmt:
+			if node.Tok != goptoken.DEFINE {
+				return true
+			}
+			for _, lhs := range node.Lhs {
+				if ident, ok := lhs.(*gopast.Ident); ok {
+					idents = append(idents, ident)
+				}
+			}
+			stmt = node
+			if len(node.Lhs) == 1 && len(node.Rhs) == 1 {
+				value = node.Rhs[0]
+			}
+		case *gopast.DeclStmt:
+			genDecl, ok := node.Decl.(*gopast.GenDecl)
+			if !ok || genDecl.Tok != goptoken.VAR {
+				return true
+			}
+			for _, spec := range genDecl.Specs {
+				idents = append(idents, spec.(*gopast.ValueSpec).Names...)
+			}
+			stmt = node
+			if len(genDecl.Specs) == 1 {
+				if values := genDecl.Specs[0].(*gopast.ValueSpec).Values; len(values) == 1 {
+					value = values[0]
+				}
+			}
+		default:
+			return true
+		}
+
+		for _, ident := range idents {
+			if ident.Name == "_" {
+				continue
+			}
+			v, ok := typeInfo.Defs[ident].(*types.Var)
+			if !ok {
+				continue
+			}
+			refs := varRefs[v]
+			if slices.ContainsFunc(refs, func(ref *gopast.Ident) bool {
+				return !isReassignedIdent(r.proj, ref)
+			}) {
+				continue
+			}
+			r.addUnusedVarDiagnostic(astFile, stmt, ident, len(idents) == 1 && len(refs) == 0, value)
+		}
+		return true
+	})
+}
+
+// addUnusedVarDiagnostic adds the diagnostic of the unused variable of the
+// given identifier declared by the given statement, with quick fixes that
+// assign it to the blank identifier after the statement, and that remove the
+// statement if removable, keeping the evaluation of its initial value if it
+// may have side effects.
+func (r *compileResult) addUnusedVarDiagnostic(astFile *gopast.File, stmt gopast.Stmt, ident *gopast.Ident, removable bool, value gopast.Expr) {
+	documentURI := r.nodeDocumentURI(astFile)
+	diagnostic := Diagnostic{
+		Severity: SeverityWarning,
+		Range:    r.rangeForNode(ident),
+		Message:  fmt.Sprintf("declared and not used: %s", ident.Name),
+		Tags:     []DiagnosticTag{Unnecessary},
+	}
+	r.addDiagnostics(documentURI, diagnostic)
+
+	// Statements not in statement lists, e.g., the init statement of an if
+	// statement, can not be removed or followed by others.
+	path := goputil.NodePath(r.proj, stmt)
+	i := slices.Index(path, gopast.Node(stmt))
+	if i < 0 || i+1 >= len(path) || stmtListNode(path[i+1]) == nil {
+		return
+	}
+
+	if removable {
+		var removeEdit TextEdit
+		switch {
+		case value == nil || !r.hasSideEffects(value):
+			removeEdit = r.removeNodeEdit(astFile, stmt)
+		case r.isStmtExpr(value):
+			removeEdit = TextEdit{
+				Range:   r.rangeForStartEnd(astFile, stmt.Pos(), value.Pos()),
+				NewText: "",
+			}
+		default:
+			removable = false
+		}
+		if removable {
+			r.quickFixes[documentURI] = append(r.quickFixes[documentURI], quickFix{
+				diagnostic:  diagnostic,
+				title:       fmt.Sprintf("Remove variable `%s`", ident.Name),
+				edits:       []TextEdit{removeEdit},
+				isPreferred: true,
+			})
+			r.fixAllEdits[documentURI] = append(r.fixAllEdits[documentURI], removeEdit)
+		}
+	}
+
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	indent := lineIndent(astFile.Code, tokenFile.Offset(stmt.Pos()))
+	r.quickFixes[documentURI] = append(r.quickFixes[documentURI], quickFix{
+		diagnostic: diagnostic,
+		title:      fmt.Sprintf("Add `_ = %s`", ident.Name),
+		edits: []TextEdit{{
+			Range:   r.rangeForStartEnd(astFile, stmt.End(), stmt.End()),
+			NewText: "\n" + indent + "_ = " + ident.Name,
+		}},
+		isPreferred: !removable,
+	})
+}
+
+// removeNodeEdit returns the edit that removes the given node of the given AST
+// file, with its lines if nothing else is on them. An empty line following a
+// removed declaration is removed as well.
+func (r *compileResult) removeNodeEdit(astFile *gopast.File, node gopast.Node) TextEdit {
+	tokenFile := r.proj.Fset.File(astFile.Pos())
+	code := astFile.Code
+	start, end := wholeLinesRange(code, tokenFile.Offset(node.Pos()), tokenFile.Offset(node.End()))
+	if _, ok := node.(gopast.Decl); ok && end > 0 && code[end-1] == '\n' {
+		if next := end + len(lineIndent(code, end)); next < len(code) && code[next] == '\n' {
+			end = next + 1
+		}
+	}
+	return TextEdit{
+		Range:   r.rangeForStartEnd(astFile, tokenFile.Pos(start), tokenFile.Pos(end)),
+		NewText: "",
+	}
+}
+
+// pureBuiltinFuncs are the names of builtin functions without side effects.
+var pureBuiltinFuncs = map[string]struct{}{
+	"append":  {},
+	"cap":     {},
+	"complex": {},
+	"imag":    {},
+	"len":     {},
+	"make":    {},
+	"max":     {},
+	"min":     {},
+	"new":     {},
+	"real":    {},
+}
+
+// hasSideEffects reports whether evaluating the given expression may have
+// side effects, i.e., whether it calls functions other than conversions and
+// pure builtin functions, or receives from channels.
+func (r *compileResult) hasSideEffects(expr gopast.Expr) bool {
+	typeInfo := getTypeInfo(r.proj)
+	hasSideEffects := false
+	gopast.Inspect(expr, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return false // Not called.
+		case *gopast.CallExpr:
+			if tv, ok := typeInfo.Types[node.Fun]; ok && tv.IsType() {
+				return true
+			}
+			if ident, ok := node.Fun.(*gopast.Ident); ok && isBuiltinObject(typeInfo.ObjectOf(ident)) {
+				if _, ok := pureBuiltinFuncs[ident.Name]; ok {
+					return true
+				}
+			}
+			hasSideEffects = true
+		case *gopast.UnaryExpr:
+			if node.Op == goptoken.ARROW {
+				hasSideEffects = true
+			}
+		}
+		return !hasSideEffects
+	})
+	return hasSideEffects
+}
+
+// isStmtExpr reports whether the given expression may be used as an
+// expression statement, i.e., whether it is a call of a function other than
+// builtin ones or a receive operation, possibly parenthesized.
+func (r *compileResult) isStmtExpr(expr gopast.Expr) bool {
+	switch expr := expr.(type) {
+	case *gopast.ParenExpr:
+		return r.isStmtExpr(expr.X)
+	case *gopast.CallExpr:
+		typeInfo := getTypeInfo(r.proj)
+		if tv, ok := typeInfo.Types[expr.Fun]; ok && tv.IsType() {
+			return false
+		}
+		if ident, ok := expr.Fun.(*gopast.Ident); ok && isBuiltinObject(typeInfo.ObjectOf(ident)) {
+			return false
+		}
+		return true
+	case *gopast.UnaryExpr:
+		return expr.Op == goptoken.ARROW
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerUnusedDecls(t *testing.T) {
+	const content = `import (
+	"fmt"
+	str "strings"
+	"math"
+)
+
+func getScore() int {
+	return 1
+}
+
+onStart => {
+	a := 1
+	b := getScore()
+	var c int
+	c = 2
+	d := math.Pi
+	echo d
+	e, f := 1, 2
+	echo e
+}
+`
+	newServer := func() (*Server, map[string][]byte) {
+		m := map[string][]byte{"main.spx": []byte(content)}
+		return New(newMapFSWithoutModTime(m), nil, fileMapGetter(m)), m
+	}
+	quickFixes := func(t *testing.T, position Position) map[string]string {
+		s, m := newServer()
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		results := make(map[string]string, len(codeActions))
+		for _, codeAction := range codeActions {
+			require.NotNil(t, codeAction.Edit)
+			results[codeAction.Title] = applyTextEdits(m["main.spx"], codeAction.Edit.Changes["file:///main.spx"])
+		}
+		return results
+	}
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		s, _ := newServer()
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		var messages []string
+		for _, diagnostic := range result.diagnostics["file:///main.spx"] {
+			if diagnostic.Severity == SeverityWarning {
+				assert.Equal(t, []DiagnosticTag{Unnecessary}, diagnostic.Tags)
+				messages = append(messages, diagnostic.Message)
+			}
+		}
+		assert.Equal(t, []string{
+			`"fmt" imported and not used`,
+			`"strings" imported as str and not used`,
+			"declared and not used: a",
+			"declared and not used: b",
+			"declared and not used: c",
+			"declared and not used: f",
+		}, messages)
+	})
+
+	t.Run("UnusedImport", func(t *testing.T) {
+		got := quickFixes(t, Position{Line: 2, Character: 2})
+		assert.Equal(t, map[string]string{
+			`Remove import "strings"`:          strings.Replace(content, "\tstr \"strings\"\n", "", 1),
+			`Change to blank import "strings"`: strings.Replace(content, `str "strings"`, `_ "strings"`, 1),
+		}, got)
+	})
+
+	t.Run("UnusedVariable", func(t *testing.T) {
+		got := quickFixes(t, Position{Line: 11, Character: 1})
+		assert.Contains(t, got["Remove variable `a`"], "onStart => {\n\tb := getScore()\n")
+		assert.Contains(t, got["Add `_ = a`"], "\ta := 1\n\t_ = a\n\tb := getScore()\n")
+
+		// The call is kept for its side effects.
+		got = quickFixes(t, Position{Line: 12, Character: 1})
+		assert.Contains(t, got["Remove variable `b`"], "\tgetScore()\n")
+
+		// Variables assigned later can not be removed.
+		got = quickFixes(t, Position{Line: 13, Character: 5})
+		assert.Equal(t, []string{"Add `_ = c`"}, slices.Sorted(maps.Keys(got)))
+
+		// Variables declared with others can not be removed.
+		got = quickFixes(t, Position{Line: 17, Character: 4})
+		assert.Equal(t, []string{"Add `_ = f`"}, slices.Sorted(maps.Keys(got)))
+	})
+
+	t.Run("FixAll", func(t *testing.T) {
+		s, m := newServer()
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceFixAll}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, "Fix all", codeActions[0].Title)
+		require.NotNil(t, codeActions[0].Edit)
+		assert.Equal(t, `import (
+	"math"
+)
+
+func getScore() int {
+	return 1
+}
+
+onStart => {
+	getScore()
+	var c int
+	c = 2
+	d := math.Pi
+	echo d
+	e, f := 1, 2
+	echo e
+}
+`, applyTextEdits(m["main.spx"], codeActions[0].Edit.Changes["file:///main.spx"]))
+	})
+
+	t.Run("RemoveDeclaration", func(t *testing.T) {
+		m := map[string][]byte{"main.spx": []byte(`import "fmt"
+
+echo "hi"
+`)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		codeActions, err := s.textDocumentCodeAction(context.Background(), &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceFixAll}},
+		})
+		require.NoError(t, err)
+		require.Len(t, codeActions, 1)
+		assert.Equal(t, `echo "hi"
+`, applyTextEdits(m["main.spx"], codeActions[0].Edit.Changes["file:///main.spx"]))
+	})
+
+	t.Run("TypeErrors", func(t *testing.T) {
+		m := map[string][]byte{"main.spx": []byte(`import "math"
+
+echo undefined, math.Pi
+`)}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		for _, diagnostic := range result.diagnostics["file:///main.spx"] {
+			assert.NotContains(t, diagnostic.Message, "not used")
+		}
+	})
+}
@@ -1,14 +1,15 @@
 package server
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/constant"
 	"go/types"
 	"html/template"
 	"regexp"
+	"slices"
 	"strconv"
-	"unicode/utf16"
-	"unicode/utf8"
 
 	"github.com/goplus/gogen"
 	gopast "github.com/goplus/gop/ast"
@@ -306,36 +307,47 @@ func attr(value string) string {
 	return fmt.Sprintf(`"%s"`, template.HTMLEscapeString(value))
 }
 
-// utf16OffsetToUTF8 converts a UTF-16 offset to a UTF-8 offset in the given string.
-func utf16OffsetToUTF8(s string, utf16Offset int) int {
-	if utf16Offset <= 0 {
-		return 0
-	}
-
-	var utf16Units, utf8Bytes int
-	for _, r := range s {
-		if utf16Units >= utf16Offset {
-			break
+// walkJSON walks the JSON document in data and calls visit for each object
+// member and array element with its path, its raw value and the byte offsets
+// of the raw value in data. Object members use their keys as path elements,
+// while array elements use their decimal indexes. If visit returns false, the
+// children of the current value are skipped.
+func walkJSON(data []byte, visit func(path []string, raw json.RawMessage, start, end int) bool) error {
+	var walk func(path []string, base int, raw json.RawMessage) error
+	walk = func(path []string, base int, raw json.RawMessage) error {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		tok, err := dec.Token()
+		if err != nil {
+			return err
 		}
-		utf16Units += utf16.RuneLen(r)
-		utf8Bytes += utf8.RuneLen(r)
-	}
-	return utf8Bytes
-}
-
-// utf8OffsetToUTF16 converts a UTF-8 offset to a UTF-16 offset in the given string.
-func utf8OffsetToUTF16(s string, utf8Offset int) int {
-	if utf8Offset <= 0 {
-		return 0
-	}
-
-	var utf8Bytes, utf16Units int
-	for _, r := range s {
-		if utf8Bytes >= utf8Offset {
-			break
+		delim, ok := tok.(json.Delim)
+		if !ok || (delim != '{' && delim != '[') {
+			return nil
+		}
+		for i := 0; dec.More(); i++ {
+			key := strconv.Itoa(i)
+			if delim == '{' {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key = keyTok.(string)
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			end := base + int(dec.InputOffset())
+			start := end - len(value)
+			valuePath := append(slices.Clip(path), key)
+			if visit(valuePath, value, start, end) {
+				if err := walk(valuePath, start, value); err != nil {
+					return err
+				}
+			}
 		}
-		utf8Bytes += utf8.RuneLen(r)
-		utf16Units += utf16.RuneLen(r)
+		_, err = dec.Token()
+		return err
 	}
-	return utf16Units
+	return walk(nil, 0, data)
 }
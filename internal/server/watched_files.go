@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_didChangeWatchedFiles
+func (s *Server) workspaceDidChangeWatchedFiles(params *DidChangeWatchedFilesParams) error {
+	paths := make([]string, 0, len(params.Changes))
+	for _, change := range params.Changes {
+		path, err := s.fromDocumentURI(change.URI)
+		if err != nil {
+			return fmt.Errorf("failed to get file path from document URI %q: %w", change.URI, err)
+		}
+		paths = append(paths, path)
+	}
+	s.invalidateFiles(paths...)
+	s.scheduleWorkspaceChecks()
+	return nil
+}
+
+// InvalidateFiles reloads the files or directories at the given paths relative
+// to the workspace root from the file map getter, and republishes diagnostics
+// once changes settle.
+//
+// Files are normally reloaded only when their modification times change.
+// InvalidateFiles reloads them regardless, dropping everything derived from
+// them, e.g., the parsed metadata of the spx resources they describe.
+func (s *Server) InvalidateFiles(paths ...string) {
+	s.scheduler.mutate(func() error {
+		s.invalidateFiles(paths...)
+		return nil
+	})
+	s.scheduleWorkspaceChecks()
+}
+
+// Watch reloads the files or directories reported as changed by w, like
+// [Server.InvalidateFiles], until the returned stop function is called. It
+// lets embedding applications whose file map getter knows of changes, e.g.,
+// pushed asset updates, keep the workspace up to date without the client
+// watching files. Reads handled after a change is reported see it.
+func (s *Server) Watch(w vfs.Watcher) (stop func()) {
+	return w.Watch(func(paths []string) {
+		s.InvalidateFiles(paths...)
+	})
+}
+
+// invalidateFiles replaces the latest snapshot of the workspace with one that
+// has the files or directories at the given paths reloaded. It must run as a
+// mutation.
+func (s *Server) invalidateFiles(paths ...string) {
+	files := s.getFiles()
+	isInvalidated := func(name string) bool {
+		for _, path := range paths {
+			if name == path || strings.HasPrefix(name, path+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	snapshot := s.scheduler.snapshot.Snapshot()
+	var stale []string
+	snapshot.RangeFiles(func(name string) bool {
+		if isInvalidated(name) {
+			stale = append(stale, name)
+		}
+		return true
+	})
+	for _, name := range stale {
+		if _, ok := files[name]; !ok {
+			snapshot.DeleteFile(name)
+		}
+	}
+	for name, file := range files {
+		if isInvalidated(name) {
+			snapshot.PutFile(name, file)
+		}
+	}
+	s.scheduler.snapshot = snapshot
+	s.spxResources.setLatest(snapshot, paths...)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerWorkspaceDidChangeWatchedFiles(t *testing.T) {
+	t.Run("ReloadChangedMetadata", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(`setCostume "costume2"`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sounds/MySound/index.json":   []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+		}
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+		initializeServer(t, s)
+		s.diagnosticScheduler.delay = 0
+
+		result1, err := s.compile(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, result1.spxResourceSet.Sprite("MySprite"))
+		assert.Nil(t, result1.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+
+		// Files without changed modification times are not reloaded until
+		// they are reported as changed.
+		m["assets/sprites/MySprite/index.json"] = []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`)
+		result2, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, result2.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+
+		n, err := jsonrpc2.NewNotification("workspace/didChangeWatchedFiles", DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sprites/MySprite/index.json", Type: Changed}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			for _, msg := range replier.messages {
+				if n, ok := msg.(*jsonrpc2.Notification); ok && n.Method() == "textDocument/publishDiagnostics" {
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+
+		result3, err := s.compile(context.Background())
+		require.NoError(t, err)
+		assert.NotNil(t, result3.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+		assert.Same(t, result1.spxResourceSet.Sound("MySound"), result3.spxResourceSet.Sound("MySound"))
+	})
+
+	t.Run("DeletedDirectory", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+play "MySound"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                []byte(`{}`),
+			"assets/sounds/MySound/index.json": []byte(`{}`),
+			"assets/sounds/MySound/sound.wav":  []byte(`RIFF`),
+		}
+		s := New(newMapFSWithoutModTime(m), &recordingReplier{}, fileMapGetter(m))
+		initializeServer(t, s)
+		s.diagnosticScheduler.delay = time.Hour
+
+		result, err := s.compile(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, result.spxResourceSet.Sound("MySound"))
+
+		delete(m, "assets/sounds/MySound/index.json")
+		delete(m, "assets/sounds/MySound/sound.wav")
+		require.NoError(t, s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sounds/MySound", Type: Deleted}},
+		}))
+		_, ok := s.getProj().File("assets/sounds/MySound/sound.wav")
+		assert.False(t, ok)
+
+		result, err = s.compile(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, result.spxResourceSet.Sound("MySound"))
+	})
+
+	t.Run("InvalidURI", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil, fileMapGetter(map[string][]byte{}))
+		initializeServer(t, s)
+		err := s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "https://example.com/index.json", Type: Changed}},
+		})
+		assert.ErrorContains(t, err, "failed to get file path from document URI")
+	})
+}
+
+func TestServerWatch(t *testing.T) {
+	m := map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(``),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+	}
+	s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+	s.diagnosticScheduler.delay = time.Hour
+	var notifier vfs.Notifier
+	stop := s.Watch(&notifier)
+
+	result, err := s.compile(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, result.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+
+	// Pushed changes are seen once reported, even with unchanged
+	// modification times.
+	m["assets/sprites/MySprite/index.json"] = []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"}]}`)
+	notifier.Notify("assets/sprites/MySprite")
+	result, err = s.compile(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, result.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+
+	stop()
+	m["assets/sprites/MySprite/index.json"] = []byte(`{}`)
+	notifier.Notify("assets/sprites/MySprite")
+	result, err = s.compile(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, result.spxResourceSet.Sprite("MySprite").Costume("costume2"))
+}
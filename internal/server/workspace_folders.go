@@ -0,0 +1,661 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+)
+
+// A multi-root workspace is served by a [Server] per workspace folder, each
+// with its own project, behind a router that passes each message from the
+// client to the servers it concerns:
+//   - messages about a document go to the server of the innermost folder
+//     containing it,
+//   - workspace-wide calls, e.g., workspace/symbol or xgo/workspaceStatus, go
+//     to all servers, and their results are merged,
+//   - other calls and notifications referring to no document, e.g., commands
+//     with spx resource URIs, go to the server of the only folder, and are
+//     rejected as ambiguous if there are several folders, unless they refer
+//     to a folder by its URI,
+//   - and session-wide notifications, e.g., workspace/didChangeConfiguration,
+//     go to all servers.
+//
+// Calls from the servers to the client are given IDs unique to the session,
+// so that their responses can be passed back.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_workspaceFolders
+
+// defaultWorkspaceRootURI is the root URI of the workspace of clients that
+// open no folder.
+const defaultWorkspaceRootURI = "file:///"
+
+// routerIDPrefix is the prefix of the IDs of calls made by the router.
+const routerIDPrefix = "goxlsw.router/"
+
+// FolderFactory returns the project of the workspace folder with the given
+// URI, and the getter of its files. See [ServeOptions.Folders].
+type FolderFactory func(uri URI) (*vfs.MapFS, FileMapGetter, error)
+
+// messageHandler handles the messages of a session. See [Serve].
+type messageHandler interface {
+	HandleMessage(m jsonrpc2.Message) error
+
+	// isShutDown reports whether the client sent the shutdown request.
+	isShutDown() bool
+}
+
+// workspaceRouter serves a multi-root workspace with a [Server] per
+// workspace folder.
+type workspaceRouter struct {
+	replier   MessageReplier
+	newFolder FolderFactory
+	configure func(s *Server) // applied to the server of each folder
+
+	mu             sync.Mutex
+	initParams     *InitializeParams // nil before initialize, with the latest trace and settings
+	folders        []*workspaceFolder
+	lastCompletion *workspaceFolder // folder of the last completion, for completionItem/resolve
+	shutDown       bool
+	lastID         int64
+	serverCalls    map[jsonrpc2.ID]serverCall // calls from servers to the client by routed ID
+	fanOuts        map[jsonrpc2.ID]*fanOut    // calls to several servers by routed ID
+}
+
+// workspaceFolder is a workspace folder and its server.
+type workspaceFolder struct {
+	uri    URI
+	server *Server
+}
+
+// serverCall is a call from the server of a folder to the client.
+type serverCall struct {
+	folder *workspaceFolder
+	id     jsonrpc2.ID
+}
+
+// fanOut is a call to the servers of several folders, whose results are
+// merged into the response to the client call, if any.
+type fanOut struct {
+	clientID *jsonrpc2.ID // nil if the call is made by the router itself
+	method   string
+	pending  int
+	results  []json.RawMessage
+	err      error
+}
+
+// newWorkspaceRouter creates a router that sends messages to the client with
+// replier, and creates the server of each workspace folder with the project
+// returned by newFolder, applying configure to it if not nil.
+func newWorkspaceRouter(replier MessageReplier, newFolder FolderFactory, configure func(s *Server)) *workspaceRouter {
+	return &workspaceRouter{
+		replier:     replier,
+		newFolder:   newFolder,
+		configure:   configure,
+		serverCalls: make(map[jsonrpc2.ID]serverCall),
+		fanOuts:     make(map[jsonrpc2.ID]*fanOut),
+	}
+}
+
+// isShutDown implements [messageHandler].
+func (r *workspaceRouter) isShutDown() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.shutDown
+}
+
+// HandleMessage handles an incoming LSP message, passing it to the servers of
+// the folders it concerns.
+func (r *workspaceRouter) HandleMessage(m jsonrpc2.Message) error {
+	switch m := m.(type) {
+	case *jsonrpc2.Call:
+		return r.handleCall(m)
+	case *jsonrpc2.Notification:
+		return r.handleNotification(m)
+	case *jsonrpc2.Response:
+		r.handleResponse(m)
+		return nil
+	}
+	return fmt.Errorf("unsupported message type: %T", m)
+}
+
+// handleCall handles a call message.
+func (r *workspaceRouter) handleCall(c *jsonrpc2.Call) error {
+	r.mu.Lock()
+	initialized := r.initParams != nil
+	r.mu.Unlock()
+	if !initialized && c.Method() != "initialize" {
+		return r.reply(c.ID(), nil, errServerNotInitialized)
+	}
+
+	id := c.ID()
+	switch c.Method() {
+	case "initialize":
+		if initialized {
+			return r.reply(c.ID(), nil, fmt.Errorf("%w: server already initialized", jsonrpc2.ErrInvalidRequest))
+		}
+		var params InitializeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return r.reply(c.ID(), nil, fmt.Errorf("%w: %s", jsonrpc2.ErrParse, err))
+		}
+		folders, err := r.initialize(&params)
+		if err != nil {
+			return r.reply(c.ID(), nil, err)
+		}
+		return r.fanOut(&id, c.Method(), c.Params(), folders)
+	case "shutdown":
+		r.mu.Lock()
+		r.shutDown = true
+		r.mu.Unlock()
+		return r.fanOut(&id, c.Method(), c.Params(), r.allFolders())
+	case "workspace/symbol", "workspace/diagnostic", "xgo/workspaceStatus", "xgo/memoryUsage":
+		return r.fanOut(&id, c.Method(), c.Params(), r.allFolders())
+	}
+
+	var folder *workspaceFolder
+	if c.Method() == "completionItem/resolve" {
+		r.mu.Lock()
+		folder = r.lastCompletion
+		r.mu.Unlock()
+		if folder == nil {
+			return r.reply(c.ID(), nil, fmt.Errorf("%w: no workspace folder", jsonrpc2.ErrInvalidRequest))
+		}
+	} else {
+		var err error
+		if folder, err = r.folderOfParams(c.Method(), c.Params()); err != nil {
+			return r.reply(c.ID(), nil, fmt.Errorf("%w: %s", jsonrpc2.ErrInvalidRequest, err))
+		}
+	}
+	if c.Method() == "textDocument/completion" {
+		r.mu.Lock()
+		r.lastCompletion = folder
+		r.mu.Unlock()
+	}
+	return folder.server.HandleMessage(c)
+}
+
+// handleNotification handles a notification message.
+func (r *workspaceRouter) handleNotification(n *jsonrpc2.Notification) error {
+	r.mu.Lock()
+	initialized := r.initParams != nil
+	r.mu.Unlock()
+	if !initialized && n.Method() != "exit" {
+		return nil
+	}
+
+	switch n.Method() {
+	case "initialized", "exit":
+		return r.broadcast(n)
+	case "$/setTrace":
+		var params SetTraceParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse setTrace params: %w", err)
+		}
+		r.mu.Lock()
+		r.initParams.Trace = &params.Value
+		r.mu.Unlock()
+		return r.broadcast(n)
+	case "workspace/didChangeConfiguration":
+		var params DidChangeConfigurationParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeConfiguration params: %w", err)
+		}
+		r.mu.Lock()
+		r.initParams.InitializationOptions = params.Settings
+		r.mu.Unlock()
+		return r.broadcast(n)
+	case "$/cancelRequest":
+		return r.cancel(n)
+	case "workspace/didChangeWatchedFiles":
+		var params DidChangeWatchedFilesParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeWatchedFiles params: %w", err)
+		}
+		return r.didChangeWatchedFiles(&params)
+	case "workspace/didChangeWorkspaceFolders":
+		var params DidChangeWorkspaceFoldersParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeWorkspaceFolders params: %w", err)
+		}
+		return r.didChangeWorkspaceFolders(&params)
+	}
+
+	folder, err := r.folderOfParams(n.Method(), n.Params())
+	if err != nil {
+		return err
+	}
+	return folder.server.HandleMessage(n)
+}
+
+// handleResponse passes a response from the client back to the server that
+// made the call.
+func (r *workspaceRouter) handleResponse(resp *jsonrpc2.Response) {
+	r.mu.Lock()
+	call, ok := r.serverCalls[resp.ID()]
+	delete(r.serverCalls, resp.ID())
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	routed, err := jsonrpc2.NewResponse(call.id, resp.Result(), resp.Err())
+	if err != nil {
+		return
+	}
+	call.folder.server.HandleMessage(routed)
+}
+
+// initialize creates the servers of the workspace folders of the given
+// params, or of the root URI if the client does not support workspace
+// folders, and returns them.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#initialize
+func (r *workspaceRouter) initialize(params *InitializeParams) ([]*workspaceFolder, error) {
+	uris := make([]URI, 0, len(params.WorkspaceFolders))
+	for _, folder := range params.WorkspaceFolders {
+		uris = append(uris, folder.URI)
+	}
+	if len(uris) == 0 {
+		if params.RootURI != "" {
+			uris = append(uris, URI(params.RootURI))
+		} else {
+			uris = append(uris, defaultWorkspaceRootURI)
+		}
+	}
+
+	folders := make([]*workspaceFolder, 0, len(uris))
+	for _, uri := range uris {
+		folder, err := r.newWorkspaceFolder(uri)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.initParams = params
+	r.folders = folders
+	return folders, nil
+}
+
+// newWorkspaceFolder creates the server of the workspace folder with the
+// given URI.
+func (r *workspaceRouter) newWorkspaceFolder(uri URI) (*workspaceFolder, error) {
+	mapFS, fileMapGetter, err := r.newFolder(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace folder %q: %w", uri, err)
+	}
+	folder := &workspaceFolder{uri: uri}
+	folder.server = New(mapFS, folderReplier{r: r, folder: folder}, fileMapGetter)
+	folder.server.workspaceRootURI = DocumentURI(strings.TrimSuffix(string(uri), "/") + "/")
+	if r.configure != nil {
+		r.configure(folder.server)
+	}
+	return folder, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_didChangeWorkspaceFolders
+func (r *workspaceRouter) didChangeWorkspaceFolders(params *DidChangeWorkspaceFoldersParams) error {
+	var removed []*workspaceFolder
+	r.mu.Lock()
+	for _, folder := range params.Event.Removed {
+		i := slices.IndexFunc(r.folders, func(f *workspaceFolder) bool { return f.uri == folder.URI })
+		if i < 0 {
+			continue
+		}
+		if r.lastCompletion == r.folders[i] {
+			r.lastCompletion = nil
+		}
+		removed = append(removed, r.folders[i])
+		r.folders = slices.Delete(r.folders, i, i+1)
+	}
+	initParams := *r.initParams
+	r.mu.Unlock()
+
+	var errs []error
+	if len(removed) > 0 {
+		errs = append(errs, r.fanOut(nil, "shutdown", nil, removed))
+	}
+	for _, folder := range params.Event.Added {
+		f, err := r.newWorkspaceFolder(folder.URI)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.mu.Lock()
+		r.folders = append(r.folders, f)
+		r.mu.Unlock()
+
+		folders := []*workspaceFolder{f}
+		errs = append(errs, r.fanOut(nil, "initialize", initParams, folders))
+		n, err := jsonrpc2.NewNotification("initialized", &InitializedParams{})
+		if err != nil {
+			return err
+		}
+		errs = append(errs, f.server.HandleMessage(n))
+	}
+	return errors.Join(errs...)
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_didChangeWatchedFiles
+func (r *workspaceRouter) didChangeWatchedFiles(params *DidChangeWatchedFilesParams) error {
+	changes := make(map[*workspaceFolder][]FileEvent)
+	var folders []*workspaceFolder
+	for _, change := range params.Changes {
+		folder := r.folderOf(change.URI)
+		if folder == nil {
+			continue
+		}
+		if _, ok := changes[folder]; !ok {
+			folders = append(folders, folder)
+		}
+		changes[folder] = append(changes[folder], change)
+	}
+
+	var errs []error
+	for _, folder := range folders {
+		n, err := jsonrpc2.NewNotification("workspace/didChangeWatchedFiles", &DidChangeWatchedFilesParams{Changes: changes[folder]})
+		if err != nil {
+			return err
+		}
+		errs = append(errs, folder.server.HandleMessage(n))
+	}
+	return errors.Join(errs...)
+}
+
+// cancel passes a $/cancelRequest notification to all servers, with the
+// routed ID if the call was passed to several servers.
+func (r *workspaceRouter) cancel(n *jsonrpc2.Notification) error {
+	var params cancelParams
+	if err := UnmarshalJSON(n.Params(), &params); err != nil {
+		return fmt.Errorf("failed to parse cancelRequest params: %w", err)
+	}
+	r.mu.Lock()
+	for id, f := range r.fanOuts {
+		if f.clientID != nil && *f.clientID == params.ID {
+			params.ID = id
+			break
+		}
+	}
+	r.mu.Unlock()
+	routed, err := jsonrpc2.NewNotification(n.Method(), &params)
+	if err != nil {
+		return err
+	}
+	return r.broadcast(routed)
+}
+
+// broadcast passes a notification to all servers.
+func (r *workspaceRouter) broadcast(n *jsonrpc2.Notification) error {
+	var errs []error
+	for _, folder := range r.allFolders() {
+		errs = append(errs, folder.server.HandleMessage(n))
+	}
+	return errors.Join(errs...)
+}
+
+// fanOut calls the given method on the servers of the given folders, and
+// replies to the client call with the given ID, if not nil, with their merged
+// results once all of them responded.
+func (r *workspaceRouter) fanOut(clientID *jsonrpc2.ID, method string, params any, folders []*workspaceFolder) error {
+	f := &fanOut{clientID: clientID, method: method, pending: len(folders)}
+	if len(folders) == 0 {
+		return r.replyFanOut(f)
+	}
+	r.mu.Lock()
+	id := r.nextIDLocked()
+	r.fanOuts[id] = f
+	r.mu.Unlock()
+
+	call, err := jsonrpc2.NewCall(id, method, params)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, folder := range folders {
+		errs = append(errs, folder.server.HandleMessage(call))
+	}
+	return errors.Join(errs...)
+}
+
+// collect collects the response of a server to a call of [workspaceRouter.fanOut].
+// It reports false if the response is not to such a call.
+func (r *workspaceRouter) collect(resp *jsonrpc2.Response) bool {
+	r.mu.Lock()
+	f, ok := r.fanOuts[resp.ID()]
+	if !ok {
+		r.mu.Unlock()
+		return false
+	}
+	if err := resp.Err(); err != nil {
+		if f.err == nil {
+			f.err = err
+		}
+	} else if result := resp.Result(); len(result) > 0 && string(result) != "null" {
+		f.results = append(f.results, result)
+	}
+	f.pending--
+	done := f.pending == 0
+	if done {
+		delete(r.fanOuts, resp.ID())
+	}
+	r.mu.Unlock()
+
+	if done {
+		r.replyFanOut(f)
+	}
+	return true
+}
+
+// replyFanOut replies to the client call of f, if any, with the merged
+// results of the servers.
+func (r *workspaceRouter) replyFanOut(f *fanOut) error {
+	if f.clientID == nil {
+		return nil
+	}
+	if f.err != nil {
+		return r.reply(*f.clientID, nil, f.err)
+	}
+	result, err := mergeResults(f.method, f.results)
+	return r.reply(*f.clientID, result, err)
+}
+
+// mergeResults merges the results of the servers of several folders to a
+// call of the given method.
+func mergeResults(method string, results []json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var result InitializeResult
+		if len(results) > 0 {
+			if err := UnmarshalJSON(results[0], &result); err != nil {
+				return nil, err
+			}
+		}
+		if result.Capabilities.Workspace == nil {
+			result.Capabilities.Workspace = &WorkspaceOptions{}
+		}
+		result.Capabilities.Workspace.WorkspaceFolders = &WorkspaceFolders5Gn{
+			Supported:           true,
+			ChangeNotifications: "workspace/didChangeWorkspaceFolders",
+		}
+		return &result, nil
+	case "workspace/symbol":
+		symbols := []json.RawMessage{}
+		for _, result := range results {
+			var s []json.RawMessage
+			if err := UnmarshalJSON(result, &s); err != nil {
+				return nil, err
+			}
+			symbols = append(symbols, s...)
+		}
+		return symbols, nil
+	case "workspace/diagnostic":
+		report := WorkspaceDiagnosticReport{Items: []WorkspaceDocumentDiagnosticReport{}}
+		for _, result := range results {
+			var r WorkspaceDiagnosticReport
+			if err := UnmarshalJSON(result, &r); err != nil {
+				return nil, err
+			}
+			report.Items = append(report.Items, r.Items...)
+		}
+		return &report, nil
+	case "xgo/workspaceStatus":
+		status := XGoWorkspaceStatus{Load: XGoLoadProgress{Done: true}}
+		for _, result := range results {
+			var s XGoWorkspaceStatus
+			if err := UnmarshalJSON(result, &s); err != nil {
+				return nil, err
+			}
+			status.ParseErrors += s.ParseErrors
+			status.TypeErrors += s.TypeErrors
+			status.AnalyzerFindings.Errors += s.AnalyzerFindings.Errors
+			status.AnalyzerFindings.Warnings += s.AnalyzerFindings.Warnings
+			status.AnalyzerFindings.Information += s.AnalyzerFindings.Information
+			status.AnalyzerFindings.Hints += s.AnalyzerFindings.Hints
+			status.UnusedResources += s.UnusedResources
+			status.Load.Files += s.Load.Files
+			status.Load.ParsedFiles += s.Load.ParsedFiles
+			status.Load.Done = status.Load.Done && s.Load.Done
+		}
+		return &status, nil
+	case "xgo/memoryUsage":
+		var usage MemoryUsage
+		for _, result := range results {
+			var u MemoryUsage
+			if err := UnmarshalJSON(result, &u); err != nil {
+				return nil, err
+			}
+			// The heap is shared by the servers of all folders, which have
+			// the same settings.
+			usage.HeapBytes = max(usage.HeapBytes, u.HeapBytes)
+			usage.MemoryBudget = max(usage.MemoryBudget, u.MemoryBudget)
+			usage.Files += u.Files
+			usage.FileBytes += u.FileBytes
+			usage.FileCaches += u.FileCaches
+			usage.FileCacheBytes += u.FileCacheBytes
+			usage.Evictions += u.Evictions
+		}
+		return &usage, nil
+	}
+	return nil, nil
+}
+
+// folderOfParams returns the folder of the first document or folder URI found
+// in the given params of a message of the given method. If there is none,
+// e.g., for commands with spx resource URIs, it returns the only folder, and
+// an error if there are several, as the message is ambiguous.
+func (r *workspaceRouter) folderOfParams(method string, params json.RawMessage) (*workspaceFolder, error) {
+	var v any
+	if err := json.Unmarshal(params, &v); err == nil {
+		if folder := r.findFolder(v); folder != nil {
+			return folder, nil
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch len(r.folders) {
+	case 0:
+		return nil, fmt.Errorf("no workspace folder for %s", method)
+	case 1:
+		return r.folders[0], nil
+	}
+	return nil, fmt.Errorf("%s refers to no document or folder of the %d workspace folders", method, len(r.folders))
+}
+
+// findFolder returns the folder of the first "uri" field of the given decoded
+// JSON value, in depth-first order, that is in a folder.
+func (r *workspaceRouter) findFolder(v any) *workspaceFolder {
+	switch v := v.(type) {
+	case map[string]any:
+		if uri, ok := v["uri"].(string); ok {
+			if folder := r.folderOf(DocumentURI(uri)); folder != nil {
+				return folder
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if folder := r.findFolder(v[key]); folder != nil {
+				return folder
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if folder := r.findFolder(e); folder != nil {
+				return folder
+			}
+		}
+	}
+	return nil
+}
+
+// folderOf returns the innermost folder containing the document with the
+// given URI, or being the folder with the given URI, or nil if there is none.
+func (r *workspaceRouter) folderOf(uri DocumentURI) *workspaceFolder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found *workspaceFolder
+	for _, folder := range r.folders {
+		root := folder.server.workspaceRootURI
+		if strings.HasPrefix(strings.TrimSuffix(string(uri), "/")+"/", string(root)) && (found == nil || len(root) > len(found.server.workspaceRootURI)) {
+			found = folder
+		}
+	}
+	return found
+}
+
+// allFolders returns all folders.
+func (r *workspaceRouter) allFolders() []*workspaceFolder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.folders)
+}
+
+// nextIDLocked returns a new ID of a call made by the router. r.mu must be
+// held.
+func (r *workspaceRouter) nextIDLocked() jsonrpc2.ID {
+	r.lastID++
+	return jsonrpc2.NewStringID(routerIDPrefix + strconv.FormatInt(r.lastID, 10))
+}
+
+// reply replies to the client with a response of the given result or error.
+func (r *workspaceRouter) reply(id jsonrpc2.ID, result any, err error) error {
+	resp, err := jsonrpc2.NewResponse(id, result, err)
+	if err != nil {
+		return err
+	}
+	return r.replier.ReplyMessage(resp)
+}
+
+// folderReplier is the [MessageReplier] of the server of a folder.
+type folderReplier struct {
+	r      *workspaceRouter
+	folder *workspaceFolder
+}
+
+// ReplyMessage implements [MessageReplier]. Responses to calls made by the
+// router are collected, and calls to the client are given routed IDs.
+func (fr folderReplier) ReplyMessage(m jsonrpc2.Message) error {
+	r := fr.r
+	switch m := m.(type) {
+	case *jsonrpc2.Response:
+		if r.collect(m) {
+			return nil
+		}
+	case *jsonrpc2.Call:
+		r.mu.Lock()
+		id := r.nextIDLocked()
+		r.serverCalls[id] = serverCall{folder: fr.folder, id: m.ID()}
+		r.mu.Unlock()
+		routed, err := jsonrpc2.NewCall(id, m.Method(), m.Params())
+		if err != nil {
+			return err
+		}
+		return r.replier.ReplyMessage(routed)
+	}
+	return r.replier.ReplyMessage(m)
+}
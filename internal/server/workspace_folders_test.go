@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/goplus/goxlsw/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceRouter(t *testing.T) {
+	newProject := func(name string) map[string][]byte {
+		return map[string][]byte{
+			"main.spx": []byte(fmt.Sprintf(`
+var (
+	// %s is a variable.
+	%s int
+)
+run "assets", {Title: "My Game"}
+`, name, name)),
+			"assets/index.json": []byte(`{}`),
+		}
+	}
+	projects := map[URI]map[string][]byte{
+		"file:///alice":     newProject("alice"),
+		"file:///bob":       newProject("bob"),
+		"file:///alice/sub": newProject("carol"),
+	}
+	newRouter := func(t *testing.T) (*workspaceRouter, *recordingReplier) {
+		replier := &recordingReplier{}
+		r := newWorkspaceRouter(replier, func(uri URI) (*vfs.MapFS, FileMapGetter, error) {
+			m, ok := projects[uri]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown folder %s", uri)
+			}
+			return newMapFSWithoutModTime(m), fileMapGetter(m), nil
+		}, func(s *Server) {
+			s.diagnosticScheduler.delay = time.Hour
+			s.indexScheduler.delay = time.Hour
+		})
+		return r, replier
+	}
+	call := func(t *testing.T, r *workspaceRouter, replier *recordingReplier, id int64, method string, params any) *jsonrpc2.Response {
+		c, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(id), method, params)
+		require.NoError(t, err)
+		require.NoError(t, r.HandleMessage(c))
+		var resp *jsonrpc2.Response
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			for _, m := range replier.messages {
+				if resp_, ok := m.(*jsonrpc2.Response); ok && resp_.ID() == jsonrpc2.NewIntID(id) {
+					resp = resp_
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+		return resp
+	}
+	notify := func(t *testing.T, r *workspaceRouter, method string, params any) {
+		n, err := jsonrpc2.NewNotification(method, params)
+		require.NoError(t, err)
+		require.NoError(t, r.HandleMessage(n))
+	}
+	hover := func(t *testing.T, r *workspaceRouter, replier *recordingReplier, id int64, uri DocumentURI) (string, error) {
+		resp := call(t, r, replier, id, "textDocument/hover", &HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Position:     Position{Line: 3, Character: 1},
+			},
+		})
+		if err := resp.Err(); err != nil {
+			return "", err
+		}
+		var result Hover
+		require.NoError(t, UnmarshalJSON(resp.Result(), &result))
+		return result.Contents.Value, nil
+	}
+	initialize := func(t *testing.T, r *workspaceRouter, replier *recordingReplier, folders ...URI) {
+		var params InitializeParams
+		for _, uri := range folders {
+			params.WorkspaceFolders = append(params.WorkspaceFolders, WorkspaceFolder{URI: uri})
+		}
+		resp := call(t, r, replier, 1, "initialize", params)
+		require.NoError(t, resp.Err())
+		var result InitializeResult
+		require.NoError(t, UnmarshalJSON(resp.Result(), &result))
+		assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+		require.NotNil(t, result.Capabilities.Workspace)
+		assert.Equal(t, &WorkspaceFolders5Gn{
+			Supported:           true,
+			ChangeNotifications: "workspace/didChangeWorkspaceFolders",
+		}, result.Capabilities.Workspace.WorkspaceFolders)
+		notify(t, r, "initialized", InitializedParams{})
+	}
+
+	t.Run("Routing", func(t *testing.T) {
+		r, replier := newRouter(t)
+		initialize(t, r, replier, "file:///alice", "file:///bob", "file:///alice/sub")
+
+		value, err := hover(t, r, replier, 2, "file:///alice/main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, value, "alice is a variable.")
+		value, err = hover(t, r, replier, 3, "file:///bob/main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, value, "bob is a variable.")
+		// The innermost folder serves nested documents.
+		value, err = hover(t, r, replier, 4, "file:///alice/sub/main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, value, "carol is a variable.")
+
+		// Workspace-wide calls are merged.
+		resp := call(t, r, replier, 5, "workspace/symbol", &WorkspaceSymbolParams{Query: ""})
+		require.NoError(t, resp.Err())
+		var symbols []SymbolInformation
+		require.NoError(t, UnmarshalJSON(resp.Result(), &symbols))
+		var names []string
+		for _, symbol := range symbols {
+			names = append(names, symbol.Name)
+		}
+		assert.Subset(t, names, []string{"alice", "bob", "carol"})
+
+		resp = call(t, r, replier, 6, "shutdown", nil)
+		require.NoError(t, resp.Err())
+		assert.True(t, r.isShutDown())
+	})
+
+	t.Run("NoDocument", func(t *testing.T) {
+		r, replier := newRouter(t)
+		initialize(t, r, replier, "file:///alice", "file:///bob")
+
+		// Workspace-wide calls are merged.
+		resp := call(t, r, replier, 2, "xgo/memoryUsage", nil)
+		require.NoError(t, resp.Err())
+		var usage MemoryUsage
+		require.NoError(t, UnmarshalJSON(resp.Result(), &usage))
+		assert.Equal(t, 4, usage.Files)
+		resp = call(t, r, replier, 3, "xgo/workspaceStatus", nil)
+		require.NoError(t, resp.Err())
+
+		// Other calls referring to no document are ambiguous, unless they
+		// refer to a folder.
+		resp = call(t, r, replier, 4, "xgo/translateToGo", nil)
+		assert.ErrorIs(t, resp.Err(), jsonrpc2.ErrInvalidRequest)
+		resp = call(t, r, replier, 5, "xgo/translateToGo", map[string]any{"uri": "file:///bob"})
+		require.NoError(t, resp.Err())
+		var translation GoTranslation
+		require.NoError(t, UnmarshalJSON(resp.Result(), &translation))
+		assert.Contains(t, translation.Code, "bob")
+	})
+
+	t.Run("DidChangeWorkspaceFolders", func(t *testing.T) {
+		r, replier := newRouter(t)
+		initialize(t, r, replier, "file:///alice")
+
+		_, err := hover(t, r, replier, 2, "file:///bob/main.spx")
+		assert.Error(t, err)
+
+		notify(t, r, "workspace/didChangeWorkspaceFolders", &DidChangeWorkspaceFoldersParams{
+			Event: WorkspaceFoldersChangeEvent{
+				Added:   []WorkspaceFolder{{URI: "file:///bob"}},
+				Removed: []WorkspaceFolder{{URI: "file:///alice"}},
+			},
+		})
+		value, err := hover(t, r, replier, 3, "file:///bob/main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, value, "bob is a variable.")
+		_, err = hover(t, r, replier, 4, "file:///alice/main.spx")
+		assert.Error(t, err)
+
+		// Responses of the servers to calls of the router are not sent.
+		replier.mu.Lock()
+		defer replier.mu.Unlock()
+		for _, m := range replier.messages {
+			if resp, ok := m.(*jsonrpc2.Response); ok {
+				assert.NotContains(t, fmt.Sprint(resp.ID()), routerIDPrefix)
+			}
+		}
+	})
+
+	t.Run("ServerCalls", func(t *testing.T) {
+		r, replier := newRouter(t)
+		initialize(t, r, replier, "file:///alice", "file:///bob")
+
+		results := make(chan string, 2)
+		for _, folder := range r.allFolders() {
+			go func() {
+				raw, err := folder.server.callClient(context.Background(), "window/workDoneProgress/create", &WorkDoneProgressCreateParams{Token: "goxlsw/1"})
+				assert.NoError(t, err)
+				var result string
+				assert.NoError(t, UnmarshalJSON(raw, &result))
+				results <- result
+			}()
+		}
+
+		// The calls of both servers get distinct IDs.
+		var calls []*jsonrpc2.Call
+		require.Eventually(t, func() bool {
+			replier.mu.Lock()
+			defer replier.mu.Unlock()
+			calls = nil
+			for _, m := range replier.messages {
+				if c, ok := m.(*jsonrpc2.Call); ok {
+					calls = append(calls, c)
+				}
+			}
+			return len(calls) == 2
+		}, time.Second, time.Millisecond)
+		assert.NotEqual(t, calls[0].ID(), calls[1].ID())
+		for i, c := range calls {
+			assert.True(t, strings.HasPrefix(fmt.Sprint(c.ID()), routerIDPrefix))
+			resp, err := jsonrpc2.NewResponse(c.ID(), fmt.Sprint("response ", i), nil)
+			require.NoError(t, err)
+			require.NoError(t, r.HandleMessage(resp))
+		}
+		got := map[string]bool{}
+		for range calls {
+			got[<-results] = true
+		}
+		assert.Equal(t, map[string]bool{"response 0": true, "response 1": true}, got)
+	})
+
+	t.Run("BeforeInitialize", func(t *testing.T) {
+		r, replier := newRouter(t)
+		_, err := hover(t, r, replier, 1, "file:///alice/main.spx")
+		assert.ErrorIs(t, err, errServerNotInitialized)
+	})
+
+	t.Run("NoFolder", func(t *testing.T) {
+		replier := &recordingReplier{}
+		m := newProject("dave")
+		r := newWorkspaceRouter(replier, func(uri URI) (*vfs.MapFS, FileMapGetter, error) {
+			require.Equal(t, URI(defaultWorkspaceRootURI), uri)
+			return newMapFSWithoutModTime(m), fileMapGetter(m), nil
+		}, nil)
+		resp := call(t, r, replier, 1, "initialize", InitializeParams{})
+		require.NoError(t, resp.Err())
+		value, err := hover(t, r, replier, 2, "file:///main.spx")
+		require.NoError(t, err)
+		assert.Contains(t, value, "dave is a variable.")
+	})
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// XGoWorkspaceStatus represents a summary of the health of the workspace.
+type XGoWorkspaceStatus struct {
+	// ParseErrors is the number of syntax errors in spx source files.
+	ParseErrors int `json:"parseErrors"`
+
+	// TypeErrors is the number of type errors.
+	TypeErrors int `json:"typeErrors"`
+
+	// AnalyzerFindings is the number of diagnostics reported by analyzers
+	// for each severity.
+	AnalyzerFindings XGoSeverityCounts `json:"analyzerFindings"`
+
+	// UnusedResources is the number of resources never referenced from code,
+	// see the spx.getUnusedResources command.
+	UnusedResources int `json:"unusedResources"`
+
+	// Load is the progress of loading the workspace in the background.
+	Load XGoLoadProgress `json:"load"`
+}
+
+// XGoSeverityCounts represents numbers of diagnostics by severity.
+type XGoSeverityCounts struct {
+	Errors      int `json:"errors"`
+	Warnings    int `json:"warnings"`
+	Information int `json:"information"`
+	Hints       int `json:"hints"`
+}
+
+// XGoLoadProgress represents the progress of loading the workspace, i.e.,
+// parsing, type-checking and indexing it in the background, which makes
+// later requests faster.
+type XGoLoadProgress struct {
+	// Files is the number of spx source files.
+	Files int `json:"files"`
+
+	// ParsedFiles is the number of spx source files parsed so far.
+	ParsedFiles int `json:"parsedFiles"`
+
+	// Done is true once the workspace is fully loaded.
+	Done bool `json:"done"`
+}
+
+// xgoWorkspaceStatus handles the xgo/workspaceStatus request, which returns a
+// summary of the health of the workspace, e.g., for clients to display a
+// badge without pulling all diagnostics.
+func (s *Server) xgoWorkspaceStatus(ctx context.Context) (*XGoWorkspaceStatus, error) {
+	snapshot := s.snapshot()
+
+	// The load progress is taken before compiling, which loads the workspace
+	// as a side effect.
+	var status XGoWorkspaceStatus
+	if progress := s.indexProgress.Load(); progress != nil && progress.snapshot == snapshot {
+		status.Load = XGoLoadProgress{
+			Files:       progress.files,
+			ParsedFiles: int(progress.parsedFiles.Load()),
+			Done:        progress.done.Load(),
+		}
+	} else {
+		spxFiles, err := vfs.ListSpxFiles(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		status.Load.Files = len(spxFiles)
+	}
+
+	result, err := s.compileAt(ctx, snapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	status.ParseErrors = result.parseErrorCount
+	status.TypeErrors = result.typeErrorCount
+	for _, diagnostics := range result.diagnostics {
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Source == "" {
+				continue // Not reported by an analyzer.
+			}
+			switch diagnostic.Severity {
+			case SeverityError:
+				status.AnalyzerFindings.Errors++
+			case SeverityWarning:
+				status.AnalyzerFindings.Warnings++
+			case SeverityInformation:
+				status.AnalyzerFindings.Information++
+			case SeverityHint:
+				status.AnalyzerFindings.Hints++
+			}
+		}
+	}
+	status.UnusedResources = len(result.unusedSpxResources())
+	return &status, nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerWorkspaceStatus(t *testing.T) {
+	newServer := func(m map[string][]byte) *Server {
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+		s.diagnosticScheduler.delay = time.Hour
+		s.indexScheduler.delay = time.Hour
+		return s
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	printf "%d\n", "hello"
+	return
+	echo "unreachable"
+}
+`),
+			"MySprite.spx":                       []byte(`onStart => {}`),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1"},{"name":"backdrop2"}]}`),
+			"assets/sounds/MySound/index.json":   []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		})
+
+		status, err := s.xgoWorkspaceStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &XGoWorkspaceStatus{
+			AnalyzerFindings: XGoSeverityCounts{Warnings: 1, Hints: 1},
+			UnusedResources:  2, // backdrop2 and MySound; backdrop1 is the default backdrop.
+			Load:             XGoLoadProgress{Files: 2},
+		}, status)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	var n int = "zero"
+	echo n, undefinedVar
+}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	echo 1)
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		})
+
+		status, err := s.xgoWorkspaceStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.ParseErrors)
+		assert.Equal(t, 2, status.TypeErrors)
+		assert.Zero(t, status.UnusedResources)
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx":          []byte(`echo "main"`),
+			"MySprite.spx":      []byte(`onStart => {}`),
+			"assets/index.json": []byte(`{}`),
+		})
+
+		s.indexWorkspace(context.Background())
+		status, err := s.xgoWorkspaceStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, XGoLoadProgress{Files: 2, ParsedFiles: 2, Done: true}, status.Load)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.indexWorkspace(ctx)
+		status, err = s.xgoWorkspaceStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, XGoLoadProgress{Files: 2}, status.Load)
+	})
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"go/types"
+	"path"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/gop"
+	"github.com/goplus/goxlsw/gop/goputil"
+	"github.com/goplus/goxlsw/internal/server/ranking"
+)
+
+// workspaceSymbolIndexCacheKind is the project cache kind of the workspace
+// symbol index.
+const workspaceSymbolIndexCacheKind = "workspaceSymbolIndex"
+
+// workspaceSymbolIndexEntry is an entry of the workspace symbol index.
+type workspaceSymbolIndexEntry struct {
+	// ident is the identifier that declares the symbol.
+	ident *gopast.Ident
+
+	// spxFile is the path of the spx file that declares the symbol.
+	spxFile string
+
+	// kind is the symbol kind derived from the AST.
+	kind SymbolKind
+
+	// containerName is the name of the symbol that contains this symbol.
+	containerName string
+
+	// astFile is the AST file that declares the symbol.
+	astFile *gopast.File
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_symbol
+func (s *Server) workspaceSymbol(ctx context.Context, params *WorkspaceSymbolParams) ([]SymbolInformation, error) {
+	proj := s.snapshot()
+
+	// TypeInfo is only used to refine the symbol kinds. The index itself is
+	// built from ASTs, so a project that cannot be compiled still gets
+	// symbols.
+	var typeInfo *typesutil.Info
+	if result, err := s.compile(ctx); err == nil {
+		proj = result.proj
+		typeInfo = getTypeInfo(proj)
+	}
+
+	index, err := getWorkspaceSymbolIndex(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ranges depend on the negotiated position encoding, so they are not part
+	// of the index.
+	r := newCompileResult(proj, s.getPositionEncoding())
+	candidates := make([]ranking.Candidate, len(index))
+	for i, entry := range index {
+		candidates[i] = ranking.Candidate{Text: entry.ident.Name}
+	}
+	// Spaces in the query are ignored, e.g. "move to" matches "moveTo".
+	query := strings.Join(strings.Fields(params.Query), "")
+	var symbols []SymbolInformation
+	for _, i := range ranking.Rank(query, candidates) {
+		entry := index[i]
+		kind := entry.kind
+		if typeInfo != nil {
+			kind = refineWorkspaceSymbolKind(typeInfo, entry.ident, kind)
+		}
+		symbols = append(symbols, SymbolInformation{
+			Name:          entry.ident.Name,
+			Kind:          kind,
+			ContainerName: entry.containerName,
+			Location: Location{
+				URI:   s.toDocumentURI(entry.spxFile),
+				Range: r.rangeForASTFileNode(entry.astFile, entry.ident),
+			},
+		})
+	}
+	if p := s.newPartialResultProgress(params.PartialResultParams); p != nil {
+		return sendPartialResultsByDocument(ctx, p, symbols, func(symbol SymbolInformation) DocumentURI {
+			return symbol.Location.URI
+		})
+	}
+	return symbols, nil
+}
+
+// getWorkspaceSymbolIndex returns the workspace symbol index of the given
+// project.
+func getWorkspaceSymbolIndex(proj *gop.Project) ([]workspaceSymbolIndexEntry, error) {
+	index, err := proj.Cache(workspaceSymbolIndexCacheKind)
+	if err != nil {
+		return nil, err
+	}
+	return index.([]workspaceSymbolIndexEntry), nil
+}
+
+// buildWorkspaceSymbolIndex builds the workspace symbol index from all AST
+// files of the given project. It never fails on parse errors, as partial ASTs
+// still contain useful declarations.
+func buildWorkspaceSymbolIndex(proj *gop.Project) (any, error) {
+	var index []workspaceSymbolIndexEntry
+	proj.RangeASTFiles(func(spxFile string, astFile *gopast.File) {
+		if path.Ext(spxFile) != ".spx" {
+			return
+		}
+
+		var className string
+		if astFile.IsClass {
+			className = strings.TrimSuffix(path.Base(spxFile), ".spx")
+			if className == "main" {
+				className = "Game"
+			}
+		}
+		addEntry := func(ident *gopast.Ident, kind SymbolKind, containerName string) {
+			if ident == nil || ident.Name == "_" || !ident.Pos().IsValid() {
+				return
+			}
+			index = append(index, workspaceSymbolIndexEntry{
+				ident:         ident,
+				spxFile:       spxFile,
+				kind:          kind,
+				containerName: containerName,
+				astFile:       astFile,
+			})
+		}
+
+		classFieldsDecl := goputil.ClassFieldsDecl(astFile)
+		for _, decl := range astFile.Decls {
+			switch decl := decl.(type) {
+			case *gopast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *gopast.TypeSpec:
+						addEntry(spec.Name, workspaceSymbolKindForTypeExpr(spec.Type), className)
+					case *gopast.ValueSpec:
+						kind, containerName := Variable, ""
+						switch {
+						case decl.Tok == goptoken.CONST:
+							kind = Constant
+						case decl == classFieldsDecl:
+							kind, containerName = Field, className
+						}
+						for _, name := range spec.Names {
+							addEntry(name, kind, containerName)
+						}
+					}
+				}
+			case *gopast.FuncDecl:
+				if decl.Shadow {
+					continue
+				}
+				switch {
+				case decl.Recv != nil && len(decl.Recv.List) > 0:
+					addEntry(decl.Name, Method, recvTypeName(decl.Recv.List[0].Type))
+				case decl.IsClass || className != "":
+					addEntry(decl.Name, Method, className)
+				default:
+					addEntry(decl.Name, Function, "")
+				}
+			}
+		}
+	})
+	slices.SortStableFunc(index, func(a, b workspaceSymbolIndexEntry) int {
+		if c := strings.Compare(a.ident.Name, b.ident.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.spxFile, b.spxFile)
+	})
+	return index, nil
+}
+
+// workspaceSymbolKindForTypeExpr returns the symbol kind for a type spec with
+// the given type expression.
+func workspaceSymbolKindForTypeExpr(expr gopast.Expr) SymbolKind {
+	switch expr.(type) {
+	case *gopast.StructType:
+		return Struct
+	case *gopast.InterfaceType:
+		return Interface
+	}
+	return Class
+}
+
+// refineWorkspaceSymbolKind refines the AST-derived symbol kind of the given
+// identifier using type information. It returns kind as is if the identifier
+// has no type information.
+func refineWorkspaceSymbolKind(typeInfo *typesutil.Info, ident *gopast.Ident, kind SymbolKind) SymbolKind {
+	obj := typeInfo.Defs[ident]
+	if obj == nil {
+		return kind
+	}
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		switch obj.Type().Underlying().(type) {
+		case *types.Struct:
+			return Struct
+		case *types.Interface:
+			return Interface
+		}
+	case *types.Var:
+		if kind == Variable {
+			if _, ok := obj.Type().Underlying().(*types.Signature); ok {
+				return Function
+			}
+		}
+	}
+	return kind
+}
+
+// recvTypeName returns the type name of the given receiver type expression.
+func recvTypeName(expr gopast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *gopast.StarExpr:
+			expr = e.X
+		case *gopast.ParenExpr:
+			expr = e.X
+		case *gopast.IndexExpr:
+			expr = e.X
+		case *gopast.IndexListExpr:
+			expr = e.X
+		case *gopast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerWorkspaceSymbol(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+	score    int
+)
+
+type Point struct {
+	X, Y int
+}
+
+const maxScore = 100
+
+func reset() {
+	score = 0
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+var (
+	speed int
+)
+
+func move() {
+	speed++
+}
+
+onStart => {
+	move
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{})
+		require.NoError(t, err)
+		assert.Len(t, symbols, 7)
+		assert.Contains(t, symbols, SymbolInformation{
+			Name:          "MySprite",
+			Kind:          Field,
+			ContainerName: "Game",
+			Location: Location{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 9},
+				},
+			},
+		})
+		assert.Contains(t, symbols, SymbolInformation{
+			Name:          "Point",
+			Kind:          Struct,
+			ContainerName: "Game",
+			Location: Location{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 6, Character: 5},
+					End:   Position{Line: 6, Character: 10},
+				},
+			},
+		})
+		assert.Contains(t, symbols, SymbolInformation{
+			Name:          "move",
+			Kind:          Method,
+			ContainerName: "MySprite",
+			Location: Location{
+				URI: "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 5, Character: 5},
+					End:   Position{Line: 5, Character: 9},
+				},
+			},
+		})
+
+		symbols, err = s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "MXSC"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "maxScore", symbols[0].Name)
+		assert.Equal(t, Constant, symbols[0].Kind)
+
+		// Better matches come first.
+		symbols, err = s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "sco"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 2)
+		assert.Equal(t, "score", symbols[0].Name)
+		assert.Equal(t, "maxScore", symbols[1].Name)
+	})
+
+	t.Run("NoMainSpxFile", func(t *testing.T) {
+		m := map[string][]byte{
+			"MySprite.spx": []byte(`
+func move() {}
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "mv"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "move", symbols[0].Name)
+		assert.Equal(t, Method, symbols[0].Kind)
+		assert.Equal(t, "MySprite", symbols[0].ContainerName)
+	})
+
+	t.Run("ParseError", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+func reset() {}
+
+func broken( {
+`),
+		}
+		s := New(newMapFSWithoutModTime(m), nil, fileMapGetter(m))
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "reset"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "reset", symbols[0].Name)
+	})
+	t.Run("PartialResults", func(t *testing.T) {
+		m := map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+func reset() {}
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func move() {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}
+		replier := &recordingReplier{}
+		s := New(newMapFSWithoutModTime(m), replier, fileMapGetter(m))
+
+		var token ProgressToken = "symbols"
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{
+			PartialResultParams: PartialResultParams{PartialResultToken: &token},
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, symbols)
+		assert.Empty(t, symbols)
+
+		chunks := partialResults[SymbolInformation](t, replier, "symbols")
+		require.Len(t, chunks, 2)
+		var names []string
+		for _, chunk := range chunks {
+			for _, symbol := range chunk {
+				assert.Equal(t, chunk[0].Location.URI, symbol.Location.URI)
+				names = append(names, symbol.Name)
+			}
+		}
+		assert.ElementsMatch(t, []string{"MySprite", "reset", "move"}, names)
+	})
+}
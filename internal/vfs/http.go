@@ -0,0 +1,226 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxConcurrentFetches is the maximum number of files fetched at once by
+// [HTTPDir].
+const maxConcurrentFetches = 8
+
+// HTTPDir provides the files of a project served over HTTP, e.g., a Builder
+// cloud project, without a local checkout. The project is described by a
+// listing: a JSON object at the listing URL that maps the slash-separated
+// path of each file to the URL of its content, e.g., a signed URL of an
+// object storage, resolved relative to the listing URL:
+//
+//	{
+//		"main.spx": "main.spx",
+//		"assets/index.json": "https://cdn.example.com/a1b2c3?sign=..."
+//	}
+//
+// Files are read through a cache. Once older than MaxAge, the listing and the
+// files are revalidated with the ETags of their previous responses, and files
+// whose contents did not change are kept as is, so that projects reload only
+// the changed files.
+type HTTPDir struct {
+	// Client is the client of the requests. If nil, [http.DefaultClient] is
+	// used.
+	Client *http.Client
+
+	// MaxAge is how long files are used without revalidation.
+	MaxAge time.Duration
+
+	// Limits are the limits of the sizes of the files, see [HTTPDir.Files].
+	Limits Limits
+
+	listingURL *url.URL
+
+	mu       sync.Mutex
+	listing  *httpEntry
+	cache    map[string]*httpEntry // by path
+	files    map[string]MapFile    // as of the last successful sync
+	limitErr error                 // of the files left out by the last successful sync
+	synced   time.Time             // time of the last successful sync
+}
+
+// httpEntry is a cached response.
+type httpEntry struct {
+	etag string
+	file *MapFileImpl
+}
+
+// NewHTTPDir creates an [HTTPDir] of the project with the given listing URL.
+func NewHTTPDir(listingURL string) (*HTTPDir, error) {
+	u, err := url.Parse(listingURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported listing URL scheme %q", u.Scheme)
+	}
+	return &HTTPDir{listingURL: u}, nil
+}
+
+// Files returns the files of the project by slash-separated path, fetching
+// or revalidating them first if they are older than d.MaxAge. If that fails,
+// it returns the files of the last successful sync, if any, with the error.
+//
+// Files exceeding d.Limits are left out as by [Limits.Apply], and returned
+// with the [*LimitError] of each of them, joined with [errors.Join]. Files
+// exceeding d.Limits.MaxFileSize are never read in full.
+func (d *HTTPDir) Files(ctx context.Context) (map[string]MapFile, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.files != nil && time.Since(d.synced) < d.MaxAge {
+		return d.files, d.limitErr
+	}
+	files, limitErr, err := d.sync(ctx)
+	if err != nil {
+		return d.files, err
+	}
+	d.files = files
+	d.limitErr = limitErr
+	d.synced = time.Now()
+	return files, limitErr
+}
+
+// sync fetches or revalidates the listing and the files of the project, and
+// returns the files within d.Limits with the errors of the others. d.mu must
+// be held.
+func (d *HTTPDir) sync(ctx context.Context) (files map[string]MapFile, limitErr, err error) {
+	// The listing is provided by the host, unlike the files, so it is not
+	// limited.
+	listing, err := d.fetch(ctx, d.listingURL, d.listing, "", Limits{})
+	if err != nil {
+		return nil, nil, err
+	}
+	var urls map[string]string
+	if err := json.Unmarshal(listing.file.Content, &urls); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse listing %s: %w", redactURL(d.listingURL), err)
+	}
+
+	type result struct {
+		path  string
+		entry *httpEntry
+		err   error
+	}
+	results := make(chan result, len(urls))
+	sem := make(chan struct{}, maxConcurrentFetches)
+	var wg sync.WaitGroup
+	for path, rawURL := range urls {
+		if !fs.ValidPath(path) {
+			continue
+		}
+		u, err := d.listingURL.Parse(rawURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid URL of %s: %w", path, err)
+		}
+		prev := d.cache[path]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			entry, err := d.fetch(ctx, u, prev, path, d.Limits)
+			results <- result{path: path, entry: entry, err: err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	cache := make(map[string]*httpEntry, len(urls))
+	files = make(map[string]MapFile, len(urls))
+	var errs, limitErrs []error
+	for r := range results {
+		if r.err != nil {
+			if _, ok := r.err.(*LimitError); ok {
+				limitErrs = append(limitErrs, r.err)
+			} else {
+				errs = append(errs, fmt.Errorf("failed to fetch %s: %w", r.path, r.err))
+			}
+			continue
+		}
+		cache[r.path] = r.entry
+		files[r.path] = r.entry.file
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	d.listing = listing
+	d.cache = cache
+
+	// The files fetched are within the file size limit, but may exceed the
+	// project size limit together.
+	kept, err := d.Limits.Apply(files)
+	if kept == nil {
+		kept = make(map[string]MapFile)
+	}
+	return kept, errors.Join(append(limitErrs, err)...), nil
+}
+
+// fetch fetches the content of the file at path from u, revalidating the
+// previous entry, if any. It returns prev if the content did not change, and
+// a [*LimitError] if the content exceeds limits.MaxFileSize.
+func (d *HTTPDir) fetch(ctx context.Context, u *url.URL, prev *httpEntry, path string, limits Limits) (*httpEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && prev.etag != "" {
+		req.Header.Set("If-None-Match", prev.etag)
+	}
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Errors of the client contain the URL, whose query may be a
+		// signature.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("GET %s: %w", redactURL(u), err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && prev != nil:
+		return prev, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GET %s: %s", redactURL(u), resp.Status)
+	}
+	if limits.MaxFileSize > 0 && resp.ContentLength > limits.MaxFileSize {
+		return nil, &LimitError{Path: path, Size: resp.ContentLength, Limit: limits.MaxFileSize}
+	}
+	content, err := limits.readFile(path, resp.Body)
+	if err != nil {
+		if _, ok := err.(*LimitError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("GET %s: %w", redactURL(u), err)
+	}
+	entry := &httpEntry{etag: resp.Header.Get("ETag")}
+	if prev != nil && bytes.Equal(prev.file.Content, content) {
+		entry.file = prev.file
+	} else {
+		entry.file = &MapFileImpl{Content: content, ModTime: time.Now()}
+	}
+	return entry, nil
+}
+
+// redactURL returns u without its query and user info, which may contain
+// credentials, e.g., the signature of a signed URL.
+func redactURL(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
@@ -0,0 +1,127 @@
+package vfs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPDir(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		contents = map[string]string{
+			"/listing":       `{"main.spx": "main.spx?sign=secret", "assets/index.json": "/objects/index"}`,
+			"/main.spx":      "echo 1",
+			"/objects/index": "{}",
+		}
+		versions = map[string]int{}
+		fetches  = map[string]int{} // full responses by path
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		content, ok := contents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + strconv.Itoa(versions[r.URL.Path]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches[r.URL.Path]++
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	update := func(path, content string) {
+		mu.Lock()
+		defer mu.Unlock()
+		contents[path] = content
+		versions[path]++
+	}
+
+	d, err := NewHTTPDir(srv.URL + "/listing")
+	require.NoError(t, err)
+	ctx := context.Background()
+	files, err := d.Files(ctx)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "echo 1", string(files["main.spx"].Content))
+	assert.Equal(t, "{}", string(files["assets/index.json"].Content))
+
+	// Unchanged files are revalidated, and kept as is.
+	update("/main.spx", "echo 2")
+	files2, err := d.Files(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "echo 2", string(files2["main.spx"].Content))
+	assert.False(t, files2["main.spx"].ModTime.Equal(files["main.spx"].ModTime))
+	assert.Same(t, files["assets/index.json"], files2["assets/index.json"])
+	mu.Lock()
+	assert.Equal(t, map[string]int{"/listing": 1, "/main.spx": 2, "/objects/index": 1}, fetches)
+	mu.Unlock()
+
+	// Files are cached for MaxAge.
+	d.MaxAge = time.Hour
+	update("/main.spx", "echo 3")
+	files3, err := d.Files(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "echo 2", string(files3["main.spx"].Content))
+
+	// Failures keep the last files, and do not leak signatures.
+	d.MaxAge = 0
+	update("/listing", `{"main.spx": "/missing?sign=secret"}`)
+	files4, err := d.Files(ctx)
+	assert.ErrorContains(t, err, "404")
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Equal(t, files2, files4)
+}
+
+func TestHTTPDirLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/listing":
+			w.Write([]byte(`{"a.spx": "a", "b.spx": "b", "c.spx": "c"}`))
+		case "/a":
+			w.Write([]byte("1234"))
+		case "/b":
+			// Known size, which is checked before reading.
+			w.Write([]byte("12345678"))
+		case "/c":
+			// Unknown size, as the response is chunked.
+			w.(http.Flusher).Flush()
+			w.Write([]byte("123456"))
+		}
+	}))
+	defer srv.Close()
+
+	d, err := NewHTTPDir(srv.URL + "/listing")
+	require.NoError(t, err)
+	d.Limits = Limits{MaxFileSize: 4}
+	d.MaxAge = time.Hour
+	files, err := d.Files(context.Background())
+	require.Len(t, files, 1)
+	assert.Equal(t, "1234", string(files["a.spx"].Content))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.ErrorContains(t, err, "b.spx: file size of 8 bytes exceeds the limit of 4 bytes")
+	assert.ErrorContains(t, err, "c.spx: file size exceeds the limit of 4 bytes")
+
+	// Cached files are returned with the errors of the files left out.
+	files2, err2 := d.Files(context.Background())
+	assert.Equal(t, files, files2)
+	assert.Equal(t, err, err2)
+}
+
+func TestNewHTTPDir(t *testing.T) {
+	_, err := NewHTTPDir("ftp://example.com/listing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Limits are limits of the sizes of the files of a project, e.g., for hosting
+// untrusted projects. Zero values mean no limit.
+type Limits struct {
+	// MaxFileSize is the maximum size of a file in bytes.
+	MaxFileSize int64
+
+	// MaxProjectSize is the maximum total size of the files of a project in
+	// bytes.
+	MaxProjectSize int64
+}
+
+// LimitError is the error of a file or project exceeding [Limits].
+type LimitError struct {
+	// Path is the path of the file, or empty if the project exceeds
+	// [Limits.MaxProjectSize].
+	Path string
+
+	// Size is the size in bytes, or -1 if unknown, e.g., if the file was
+	// only read up to the limit.
+	Size  int64
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("project size of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+	}
+	if e.Size < 0 {
+		return fmt.Sprintf("%s: file size exceeds the limit of %d bytes", e.Path, e.Limit)
+	}
+	return fmt.Sprintf("%s: file size of %d bytes exceeds the limit of %d bytes", e.Path, e.Size, e.Limit)
+}
+
+// Apply returns files without the files exceeding the limits, and a
+// [*LimitError] for each of them, joined with [errors.Join]. Files are kept
+// in path order until the project size limit is reached, and those that do
+// not fit are left out together. It returns files itself if all are within
+// the limits, and never modifies it.
+func (l Limits) Apply(files map[string]MapFile) (map[string]MapFile, error) {
+	if l.MaxFileSize <= 0 && l.MaxProjectSize <= 0 {
+		return files, nil
+	}
+	sizes := make(map[string]int64, len(files))
+	for path, file := range files {
+		sizes[path] = int64(len(file.Content))
+	}
+	paths, err := l.keep(sizes)
+	if err == nil {
+		return files, nil
+	}
+	var kept map[string]MapFile
+	for _, path := range paths {
+		if kept == nil {
+			kept = make(map[string]MapFile, len(paths))
+		}
+		kept[path] = files[path]
+	}
+	return kept, err
+}
+
+// keep returns the sorted paths of the files within the limits given the
+// sizes of the files by path, and a [*LimitError] for each of the others,
+// joined with [errors.Join]. See [Limits.Apply] for which files are kept.
+func (l Limits) keep(sizes map[string]int64) ([]string, error) {
+	var (
+		kept  []string
+		errs  []error
+		total int64
+		over  int64 // total size of the files left out for the project limit
+		full  bool  // whether the project size limit is reached
+	)
+	for _, path := range slices.Sorted(maps.Keys(sizes)) {
+		size := sizes[path]
+		if l.MaxFileSize > 0 && size > l.MaxFileSize {
+			errs = append(errs, &LimitError{Path: path, Size: size, Limit: l.MaxFileSize})
+			continue
+		}
+		if l.MaxProjectSize > 0 && (full || size > l.MaxProjectSize-total) {
+			full = true
+			over = addSizes(over, size)
+			continue
+		}
+		total += size
+		kept = append(kept, path)
+	}
+	if full {
+		errs = append(errs, &LimitError{Size: addSizes(total, over), Limit: l.MaxProjectSize})
+	}
+	return kept, errors.Join(errs...)
+}
+
+// addSizes returns a + b for non-negative sizes, saturating at math.MaxInt64
+// instead of overflowing, e.g., for bogus sizes declared by zip archives.
+func addSizes(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// readFile reads the content of the file at path from r, reading at most one
+// byte beyond l.MaxFileSize, so that files exceeding it are never read in
+// full. It returns a [*LimitError] for those files.
+func (l Limits) readFile(path string, r io.Reader) ([]byte, error) {
+	if l.MaxFileSize <= 0 {
+		return io.ReadAll(r)
+	}
+	content, err := io.ReadAll(io.LimitReader(r, l.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > l.MaxFileSize {
+		return nil, &LimitError{Path: path, Size: -1, Limit: l.MaxFileSize}
+	}
+	return content, nil
+}
+
+// ReadOnly is a set of read-only mounts of a project, i.e., the slash-separated
+// paths of files or directories that must not be changed, e.g., assets shared
+// by several projects.
+type ReadOnly []string
+
+// ReadOnlyError is the error of a change to a file in a read-only mount.
+type ReadOnlyError struct {
+	Path string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return e.Path + " is read-only"
+}
+
+// Contains reports whether the file or directory at path is in a read-only
+// mount.
+func (ro ReadOnly) Contains(path string) bool {
+	for _, mount := range ro {
+		if mount == "" || path == mount || strings.HasPrefix(path, mount+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a [*ReadOnlyError] if the file or directory at path is in a
+// read-only mount, or nil otherwise.
+func (ro ReadOnly) Check(path string) error {
+	if ro.Contains(path) {
+		return &ReadOnlyError{Path: path}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsApply(t *testing.T) {
+	files := map[string]MapFile{
+		"a.spx":             &MapFileImpl{Content: []byte("1234")},
+		"b.spx":             &MapFileImpl{Content: []byte("12345678")},
+		"c.spx":             &MapFileImpl{Content: []byte("12")},
+		"assets/index.json": &MapFileImpl{Content: []byte("{}")},
+	}
+
+	t.Run("NoLimit", func(t *testing.T) {
+		got, err := Limits{}.Apply(files)
+		require.NoError(t, err)
+		assert.Equal(t, files, got)
+	})
+
+	t.Run("WithinLimits", func(t *testing.T) {
+		got, err := Limits{MaxFileSize: 8, MaxProjectSize: 16}.Apply(files)
+		require.NoError(t, err)
+		assert.Equal(t, files, got)
+	})
+
+	t.Run("MaxFileSize", func(t *testing.T) {
+		got, err := Limits{MaxFileSize: 4}.Apply(files)
+		assert.Equal(t, map[string]MapFile{
+			"a.spx":             files["a.spx"],
+			"c.spx":             files["c.spx"],
+			"assets/index.json": files["assets/index.json"],
+		}, got)
+		var limitErr *LimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, &LimitError{Path: "b.spx", Size: 8, Limit: 4}, limitErr)
+		assert.EqualError(t, err, "b.spx: file size of 8 bytes exceeds the limit of 4 bytes")
+		assert.Len(t, files, 4, "files modified")
+	})
+
+	t.Run("MaxProjectSize", func(t *testing.T) {
+		// Files are kept in path order, and those after the first one that
+		// does not fit are left out together.
+		got, err := Limits{MaxProjectSize: 8}.Apply(files)
+		assert.Equal(t, map[string]MapFile{
+			"a.spx":             files["a.spx"],
+			"assets/index.json": files["assets/index.json"],
+		}, got)
+		assert.EqualError(t, err, "project size of 16 bytes exceeds the limit of 8 bytes")
+	})
+
+	t.Run("Both", func(t *testing.T) {
+		got, err := Limits{MaxFileSize: 4, MaxProjectSize: 4}.Apply(files)
+		assert.Equal(t, map[string]MapFile{
+			"a.spx": files["a.spx"],
+		}, got)
+		assert.EqualError(t, err, "b.spx: file size of 8 bytes exceeds the limit of 4 bytes\n"+
+			"project size of 8 bytes exceeds the limit of 4 bytes")
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	ro := ReadOnly{"assets/sounds", "main.spx"}
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{"main.spx", true},
+		{"main.spx.bak", false},
+		{"assets/sounds", true},
+		{"assets/sounds/MySound/index.json", true},
+		{"assets/soundsX/index.json", false},
+		{"assets/index.json", false},
+	} {
+		assert.Equal(t, tt.want, ro.Contains(tt.path), tt.path)
+	}
+
+	err := ro.Check("assets/sounds/MySound/index.json")
+	var roErr *ReadOnlyError
+	require.True(t, errors.As(err, &roErr))
+	assert.Equal(t, "assets/sounds/MySound/index.json", roErr.Path)
+	assert.EqualError(t, err, "assets/sounds/MySound/index.json is read-only")
+	assert.NoError(t, ro.Check("assets/index.json"))
+
+	assert.True(t, ReadOnly{""}.Contains("main.spx"), "root mount")
+	assert.False(t, ReadOnly(nil).Contains("main.spx"))
+}
@@ -77,6 +77,10 @@ func (fs SubFS) ReadFile(name string) ([]byte, error) {
 	return ReadFile(fs.root, fs.base+"/"+name)
 }
 
+func (fs SubFS) FileCache(kind, name string) (any, error) {
+	return fs.root.FileCache(kind, fs.base+"/"+name)
+}
+
 func (fs SubFS) Readdir(name string) (ret []fs.FileInfo, err error) {
 	prefix := fs.base + "/" + name + "/"
 	entries := map[string]int{}
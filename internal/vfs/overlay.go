@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Overlay layers the contents of unsaved editor buffers, e.g., of documents
+// opened in a code editor, over the files of a workspace. It is safe for
+// concurrent use.
+//
+// Projects are updated with the files returned by [Overlay.Files], and reload
+// only the files whose modification times changed, so invalidation is kept
+// precise: each overlaid content gets a modification time different from
+// those of the previous content and of the file it covers, while an overlay
+// with the same content as the file it covers yields that file as is, so that
+// adding or removing it invalidates nothing.
+type Overlay struct {
+	mu    sync.Mutex
+	files map[string]*MapFileImpl // by path relative to the workspace root
+}
+
+// Put sets the content overlaid at path.
+func (o *Overlay) Put(path string, content []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	modTime := time.Now()
+	if prev, ok := o.files[path]; ok && !modTime.After(prev.ModTime) {
+		modTime = prev.ModTime.Add(time.Nanosecond)
+	}
+	if o.files == nil {
+		o.files = make(map[string]*MapFileImpl)
+	}
+	o.files[path] = &MapFileImpl{Content: content, ModTime: modTime}
+}
+
+// Content returns the content overlaid at path, if any. It must not be
+// modified.
+func (o *Overlay) Content(path string) ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	file, ok := o.files[path]
+	if !ok {
+		return nil, false
+	}
+	return file.Content, true
+}
+
+// Delete removes the content overlaid at path, if any, uncovering the file of
+// the workspace.
+func (o *Overlay) Delete(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.files, path)
+}
+
+// Paths returns the sorted paths of the overlaid contents.
+func (o *Overlay) Paths() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Sorted(maps.Keys(o.files))
+}
+
+// Files returns the given files of the workspace with the overlaid contents
+// in place of the files at their paths. It returns base itself if there is no
+// overlaid content, and never modifies it.
+func (o *Overlay) Files(base map[string]MapFile) map[string]MapFile {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.files) == 0 {
+		return base
+	}
+	files := make(map[string]MapFile, len(base)+len(o.files))
+	maps.Copy(files, base)
+	for path, file := range o.files {
+		baseFile, ok := base[path]
+		if ok && bytes.Equal(baseFile.Content, file.Content) {
+			continue
+		}
+		if ok && baseFile.ModTime.Equal(file.ModTime) {
+			// Files may be in use by projects, so they are never modified.
+			file = &MapFileImpl{Content: file.Content, ModTime: file.ModTime.Add(time.Nanosecond)}
+			o.files[path] = file
+		}
+		files[path] = file
+	}
+	return files
+}
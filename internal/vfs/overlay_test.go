@@ -0,0 +1,74 @@
+package vfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	modTime := time.Now()
+	base := map[string]MapFile{
+		"main.spx":          &MapFileImpl{Content: []byte("echo 1"), ModTime: modTime},
+		"assets/index.json": &MapFileImpl{Content: []byte("{}"), ModTime: modTime},
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		var o Overlay
+		files := o.Files(base)
+		assert.Equal(t, base, files)
+		assert.Empty(t, o.Paths())
+	})
+
+	t.Run("PutAndDelete", func(t *testing.T) {
+		var o Overlay
+		o.Put("main.spx", []byte("echo 2"))
+		o.Put("new.spx", []byte("echo 3"))
+		assert.Equal(t, []string{"main.spx", "new.spx"}, o.Paths())
+		content, ok := o.Content("main.spx")
+		require.True(t, ok)
+		assert.Equal(t, "echo 2", string(content))
+
+		files := o.Files(base)
+		assert.Equal(t, "echo 2", string(files["main.spx"].Content))
+		assert.Equal(t, "echo 3", string(files["new.spx"].Content))
+		assert.Same(t, base["assets/index.json"], files["assets/index.json"])
+		assert.False(t, files["main.spx"].ModTime.Equal(modTime))
+		assert.Len(t, base, 2, "base files modified")
+
+		// Each content has a modification time of its own.
+		prevModTime := files["main.spx"].ModTime
+		o.Put("main.spx", []byte("echo 4"))
+		assert.True(t, o.Files(base)["main.spx"].ModTime.After(prevModTime))
+
+		o.Delete("main.spx")
+		o.Delete("new.spx")
+		files = o.Files(base)
+		assert.Same(t, base["main.spx"], files["main.spx"])
+		assert.NotContains(t, files, "new.spx")
+	})
+
+	t.Run("SameContent", func(t *testing.T) {
+		// An overlay with the content of the file it covers invalidates
+		// nothing.
+		var o Overlay
+		o.Put("main.spx", []byte("echo 1"))
+		assert.Same(t, base["main.spx"], o.Files(base)["main.spx"])
+	})
+
+	t.Run("SameModTime", func(t *testing.T) {
+		var o Overlay
+		o.Put("main.spx", []byte("echo 2"))
+		content, _ := o.Content("main.spx")
+		overlaid := o.Files(base)["main.spx"]
+		base := map[string]MapFile{
+			"main.spx": &MapFileImpl{Content: []byte("echo 1"), ModTime: overlaid.ModTime},
+		}
+		file := o.Files(base)["main.spx"]
+		assert.Equal(t, content, file.Content)
+		assert.False(t, file.ModTime.Equal(overlaid.ModTime))
+		assert.True(t, overlaid.ModTime.Equal(base["main.spx"].ModTime), "files in use modified")
+	})
+}
@@ -0,0 +1,84 @@
+package vfs
+
+import (
+	"strings"
+	"sync"
+)
+
+// Watcher is an optional interface of providers of the files of a workspace
+// that report changes themselves, e.g., an embedding application pushing
+// asset updates, so that the files are reloaded without the client watching
+// them.
+type Watcher interface {
+	// Watch calls fn with the slash-separated paths of the files or
+	// directories that changed, relative to the workspace root, until the
+	// returned stop function is called. Calls of fn do not overlap.
+	Watch(fn func(paths []string)) (stop func())
+}
+
+// Notifier is a [Watcher] to which changes are reported with
+// [Notifier.Notify]. The zero value is ready to use.
+type Notifier struct {
+	mu     sync.Mutex // held while notifying, so that calls do not overlap
+	lastID int
+	subs   map[int]func(paths []string)
+}
+
+// Watch implements [Watcher].
+func (n *Notifier) Watch(fn func(paths []string)) (stop func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subs == nil {
+		n.subs = make(map[int]func(paths []string))
+	}
+	n.lastID++
+	id := n.lastID
+	n.subs[id] = fn
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subs, id)
+	}
+}
+
+// Notify reports that the files or directories at the given paths changed
+// to all watchers. It returns once they have all been called.
+func (n *Notifier) Notify(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, fn := range n.subs {
+		fn(paths)
+	}
+}
+
+// SubWatcher returns a [Watcher] of the changes reported by w under the
+// directory base, with paths relative to it like those of [SubFS]. A change
+// of base itself, or of one of its parents, is reported as a change of ".".
+func SubWatcher(w Watcher, base string) Watcher {
+	return subWatcher{w: w, base: base}
+}
+
+type subWatcher struct {
+	w    Watcher
+	base string
+}
+
+func (w subWatcher) Watch(fn func(paths []string)) (stop func()) {
+	return w.w.Watch(func(paths []string) {
+		var names []string
+		for _, path := range paths {
+			switch {
+			case path == w.base || path == "" || strings.HasPrefix(w.base, path+"/"):
+				names = append(names, ".")
+			case strings.HasPrefix(path, w.base+"/"):
+				names = append(names, path[len(w.base)+1:])
+			}
+		}
+		if len(names) > 0 {
+			fn(names)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package vfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifier(t *testing.T) {
+	var n Notifier
+	n.Notify("main.spx") // No watchers.
+
+	var got1, got2 [][]string
+	stop1 := n.Watch(func(paths []string) { got1 = append(got1, paths) })
+	n.Watch(func(paths []string) { got2 = append(got2, paths) })
+	n.Notify("main.spx", "assets")
+	n.Notify()
+	stop1()
+	n.Notify("MySprite.spx")
+	assert.Equal(t, [][]string{{"main.spx", "assets"}}, got1)
+	assert.Equal(t, [][]string{{"main.spx", "assets"}, {"MySprite.spx"}}, got2)
+}
+
+func TestSubWatcher(t *testing.T) {
+	var n Notifier
+	var got [][]string
+	SubWatcher(&n, "assets").Watch(func(names []string) { got = append(got, names) })
+	n.Notify("main.spx")
+	n.Notify("assets/index.json", "assetsx/index.json", "assets/sprites/MySprite")
+	n.Notify("assets")
+	n.Notify("")
+	assert.Equal(t, [][]string{
+		{"index.json", "sprites/MySprite"},
+		{"."},
+		{"."},
+	}, got)
+}
@@ -0,0 +1,75 @@
+package vfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"strings"
+)
+
+// ZipFiles returns the files of the project in the given zip archive, e.g.,
+// a project exported from Builder, by slash-separated path. If all files are
+// in a single top-level directory, paths are relative to it. Entries with
+// invalid paths, e.g., containing "..", are skipped.
+//
+// Files exceeding limits are left out as by [Limits.Apply], and returned with
+// the [*LimitError] of each of them, joined with [errors.Join]. They are left
+// out by the sizes declared in the archive, so they are never decompressed.
+func ZipFiles(r io.ReaderAt, size int64, limits Limits) (map[string]MapFile, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !fs.ValidPath(f.Name) {
+			continue
+		}
+		entries[f.Name] = f
+	}
+	entries = trimTopLevelDir(entries)
+
+	sizes := make(map[string]int64, len(entries))
+	for path, f := range entries {
+		sizes[path] = int64(min(f.UncompressedSize64, math.MaxInt64))
+	}
+	paths, limitErr := limits.keep(sizes)
+	files := make(map[string]MapFile, len(paths))
+	for _, path := range paths {
+		f := entries[path]
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		content, err := limits.readFile(path, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		files[path] = &MapFileImpl{Content: content, ModTime: f.Modified}
+	}
+	return files, limitErr
+}
+
+// trimTopLevelDir returns files with paths relative to their single top-level
+// directory, if any.
+func trimTopLevelDir[F any](files map[string]F) map[string]F {
+	var dir string
+	for path := range files {
+		top, _, ok := strings.Cut(path, "/")
+		if !ok || dir != "" && top != dir {
+			return files
+		}
+		dir = top
+	}
+	if dir == "" {
+		return files
+	}
+	trimmed := make(map[string]F, len(files))
+	for path, file := range files {
+		trimmed[path[len(dir)+1:]] = file
+	}
+	return trimmed
+}
@@ -0,0 +1,84 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Modified: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestZipFiles(t *testing.T) {
+	contents := func(files map[string]MapFile) map[string]string {
+		m := make(map[string]string, len(files))
+		for path, file := range files {
+			m[path] = string(file.Content)
+		}
+		return m
+	}
+
+	t.Run("Flat", func(t *testing.T) {
+		data := newZip(t, map[string]string{
+			"main.spx":          "echo 1",
+			"assets/":           "",
+			"assets/index.json": "{}",
+			"../evil.spx":       "echo 2",
+		})
+		files, err := ZipFiles(bytes.NewReader(data), int64(len(data)), Limits{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"main.spx":          "echo 1",
+			"assets/index.json": "{}",
+		}, contents(files))
+		assert.True(t, files["main.spx"].ModTime.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	t.Run("TopLevelDir", func(t *testing.T) {
+		data := newZip(t, map[string]string{
+			"MyGame/main.spx":          "echo 1",
+			"MyGame/assets/index.json": "{}",
+		})
+		files, err := ZipFiles(bytes.NewReader(data), int64(len(data)), Limits{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"main.spx":          "echo 1",
+			"assets/index.json": "{}",
+		}, contents(files))
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		data := newZip(t, map[string]string{
+			"a.spx":             "1234",
+			"b.spx":             "12345678",
+			"c.spx":             "12",
+			"assets/index.json": "{}",
+		})
+		files, err := ZipFiles(bytes.NewReader(data), int64(len(data)), Limits{MaxFileSize: 4, MaxProjectSize: 6})
+		assert.Equal(t, map[string]string{
+			"a.spx":             "1234",
+			"assets/index.json": "{}",
+		}, contents(files))
+		assert.EqualError(t, err, "b.spx: file size of 8 bytes exceeds the limit of 4 bytes\n"+
+			"project size of 8 bytes exceeds the limit of 6 bytes")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ZipFiles(bytes.NewReader([]byte("not a zip")), 9, Limits{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Stream abstracts the transport mechanics from the JSON RPC protocol.
+// A Stream reads and writes whole messages on top of a byte stream.
+// It is safe to call Read and Write concurrently with each other, and Write
+// from multiple goroutines.
+type Stream interface {
+	// Read gets the next message from the stream.
+	Read() (Message, error)
+	// Write sends a message to the stream.
+	Write(Message) error
+	// Close closes the underlying connection.
+	Close() error
+}
+
+// NewRawStream returns a Stream built on top of an io.ReadWriteCloser.
+// The messages are sent with no wrapping, and rely on json decode consistency
+// to determine message boundaries. Each message is written with a single
+// call to Write, which makes it suitable for message based connections such
+// as WebSocket.
+func NewRawStream(conn io.ReadWriteCloser) Stream {
+	return &rawStream{
+		conn: conn,
+		in:   json.NewDecoder(conn),
+	}
+}
+
+type rawStream struct {
+	conn io.ReadWriteCloser
+	in   *json.Decoder
+	mu   sync.Mutex // guards writes to conn
+}
+
+func (s *rawStream) Read() (Message, error) {
+	var raw json.RawMessage
+	if err := s.in.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return DecodeMessage(raw)
+}
+
+func (s *rawStream) Write(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.conn.Write(data)
+	return err
+}
+
+func (s *rawStream) Close() error {
+	return s.conn.Close()
+}
+
+// NewHeaderStream returns a Stream built on top of an io.ReadWriteCloser.
+// The messages are sent with HTTP content length and MIME type headers.
+// This is the format used by LSP and others.
+func NewHeaderStream(conn io.ReadWriteCloser) Stream {
+	return &headerStream{
+		conn: conn,
+		in:   bufio.NewReader(conn),
+	}
+}
+
+type headerStream struct {
+	conn io.ReadWriteCloser
+	in   *bufio.Reader
+	mu   sync.Mutex // guards writes to conn
+}
+
+func (s *headerStream) Read() (Message, error) {
+	var length int64
+	// read the header, stop on the first empty line
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed reading header line: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		// check we have a header line
+		if line == "" {
+			break
+		}
+		colon := strings.IndexRune(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		name, value := line[:colon], strings.TrimSpace(line[colon+1:])
+		switch name {
+		case "Content-Length":
+			if length, err = strconv.ParseInt(value, 10, 32); err != nil {
+				return nil, fmt.Errorf("failed parsing Content-Length: %v", value)
+			}
+			if length <= 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %v", length)
+			}
+		default:
+			// ignoring unknown headers
+		}
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	return DecodeMessage(data)
+}
+
+func (s *headerStream) Write(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.conn, "Content-Length: %v\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = s.conn.Write(data)
+	return err
+}
+
+func (s *headerStream) Close() error {
+	return s.conn.Close()
+}
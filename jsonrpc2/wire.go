@@ -31,6 +31,14 @@ var (
 	//ErrServerOverloaded is returned when a message was refused due to a
 	//server being temporarily unable to accept any new messages.
 	ErrServerOverloaded = NewError(-32000, "JSON RPC overloaded")
+
+	// ErrRequestCancelled is returned when a request was cancelled by the
+	// client, e.g. with the LSP $/cancelRequest notification.
+	ErrRequestCancelled = NewError(-32800, "JSON RPC request cancelled")
+
+	// ErrRequestFailed is returned when a request was valid but failed, e.g.
+	// as the LSP RequestFailed error.
+	ErrRequestFailed = NewError(-32803, "JSON RPC request failed")
 )
 
 // wireRequest is sent to a server to represent a Call or Notify operation.
@@ -44,7 +44,8 @@ func NewSpxls(this js.Value, args []js.Value) any {
 	}
 	s.server = server.New(gop.NewProject(nil, filesMapGetter, gop.FeatAll), s, filesMapGetter)
 	return js.ValueOf(map[string]any{
-		"handleMessage": JSFuncOfWithError(s.HandleMessage),
+		"handleMessage":   JSFuncOfWithError(s.HandleMessage),
+		"invalidateFiles": JSFuncOfWithError(s.InvalidateFiles),
 	})
 }
 
@@ -67,6 +68,27 @@ func (s *Spxls) HandleMessage(this js.Value, args []js.Value) any {
 	return nil
 }
 
+// InvalidateFiles reloads the given files or directories from the files
+// provider, even if their modification times are unchanged.
+func (s *Spxls) InvalidateFiles(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return errors.New("Spxls.InvalidateFiles: expected 1 argument")
+	}
+	if args[0].Type() != js.TypeObject || !js.Global().Get("Array").Call("isArray", args[0]).Bool() {
+		return errors.New("Spxls.InvalidateFiles: paths argument must be an array")
+	}
+	paths := make([]string, args[0].Length())
+	for i := range paths {
+		path := args[0].Index(i)
+		if path.Type() != js.TypeString {
+			return errors.New("Spxls.InvalidateFiles: paths argument must only contain strings")
+		}
+		paths[i] = path.String()
+	}
+	s.server.InvalidateFiles(paths...)
+	return nil
+}
+
 // ReplyMessage sends a message back to the client via s.messageReplier.
 func (s *Spxls) ReplyMessage(m jsonrpc2.Message) (err error) {
 	rawMessage, err := json.Marshal(m)
@@ -0,0 +1,69 @@
+/// <reference lib="webworker" />
+
+import { type Files, type Spxls, type WorkerInboundMessage, type WorkerOutboundMessage } from '.'
+
+/**
+ * Web Worker entry that runs the spxls, so that it does not block the main thread. It bridges the messages described
+ * by {@link WorkerInboundMessage} and {@link WorkerOutboundMessage} to the API of the WebAssembly module.
+ *
+ * As the filesProvider of the language server must be synchronous, the worker keeps a copy of the workspace files,
+ * which the main thread updates with `files` messages.
+ */
+
+declare const self: DedicatedWorkerGlobalScope
+
+/** The Go runtime support defined by `wasm_exec.js`. */
+declare class Go {
+  importObject: WebAssembly.Imports
+  run(instance: WebAssembly.Instance): Promise<void>
+}
+
+let files: Files = {}
+let ls: Spxls | null = null
+
+function post(message: WorkerOutboundMessage): void {
+  self.postMessage(message)
+}
+
+async function init(wasmURL: string, wasmExecURL: string): Promise<Spxls> {
+  await import(wasmExecURL) // Defines Go, and works in both classic and module workers unlike importScripts.
+  const go = new Go()
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(wasmURL), go.importObject)
+  go.run(instance) // Never resolves, as the module keeps running to serve calls.
+  const ls = NewSpxls(() => files, message => post({ type: 'message', message }))
+  if (ls instanceof Error) throw ls
+  return ls
+}
+
+async function handle(message: WorkerInboundMessage): Promise<void> {
+  switch (message.type) {
+    case 'init':
+      if (ls != null) throw new Error('already initialized')
+      files = message.files
+      ls = await init(message.wasmURL, message.wasmExecURL)
+      post({ type: 'ready' })
+      return
+    case 'files': {
+      files = message.files
+      if (ls == null || message.paths == null) return
+      const err = ls.invalidateFiles(message.paths)
+      if (err != null) throw err
+      return
+    }
+    case 'message': {
+      if (ls == null) throw new Error('not initialized')
+      const err = ls.handleMessage(message.message)
+      if (err != null) throw err
+      return
+    }
+  }
+}
+
+// Messages are handled one at a time and in order, even while the language server is loading.
+let queue = Promise.resolve()
+
+self.onmessage = (event: MessageEvent<WorkerInboundMessage>) => {
+  queue = queue.then(() => handle(event.data)).catch(err => {
+    post({ type: 'error', error: err instanceof Error ? err.message : String(err) })
+  })
+}